@@ -0,0 +1,179 @@
+package apigw
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"s3proxy/logger"
+)
+
+// accessLogTimeFormat - формат времени запроса в журнале доступа (как в журналах доступа S3)
+const accessLogTimeFormat = "02/Jan/2006:15:04:05 -0700"
+
+// accessLog пишет журнал доступа: по одной строке на запрос, поля разделены пробелами
+// в порядке, близком к журналам доступа сервера S3:
+//
+//	[время] IP пользователь request-id операция бакет ключ "запрос" статус отправлено получено мс "User-Agent"
+//
+// Пустые поля выводятся как "-", ключ - в URL-кодировке, строка запроса и User-Agent - в кавычках
+// с экранированием Go (strconv.Quote). Отправлено и получено - байты тела ответа и тела запроса.
+type accessLog struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+// newAccessLog создает журнал доступа, пишущий в w. nil - журнал выключен (nil).
+func newAccessLog(w io.Writer) *accessLog {
+	if w == nil {
+		return nil
+	}
+	return &accessLog{w: w}
+}
+
+// accessRecord собирает сведения о запросе для журнала доступа. Оборачивает
+// http.ResponseWriter, чтобы учесть статус и число отправленных байт.
+type accessRecord struct {
+	http.ResponseWriter
+
+	start       time.Time
+	requestID   string
+	remoteIP    string
+	requestLine string
+	userAgent   string
+	body        *countingBody // Тело запроса (nil, если его нет)
+	req         *S3Request    // Распарсенный запрос (nil, если до разбора не дошло)
+
+	status    int
+	bytesSent int64
+}
+
+// newAccessRecord начинает запись о запросе r и подменяет его тело счетчиком прочитанных байт
+func newAccessRecord(w http.ResponseWriter, r *http.Request, requestID string, start time.Time) *accessRecord {
+	record := &accessRecord{
+		ResponseWriter: w,
+		start:          start,
+		requestID:      requestID,
+		remoteIP:       clientIP(r),
+		requestLine:    r.Method + " " + accessLogURI(r) + " " + r.Proto,
+		userAgent:      r.UserAgent(),
+	}
+	if r.Body != nil {
+		record.body = &countingBody{ReadCloser: r.Body}
+		r.Body = record.body
+	}
+	return record
+}
+
+// accessLogURI возвращает путь и строку запроса для журнала доступа. Подпись и токен
+// presigned URL заменяются на logger.RedactedValue, поэтому исходный RequestURI не используется.
+func accessLogURI(r *http.Request) string {
+	uri := r.URL.EscapedPath()
+	if r.URL.RawQuery != "" {
+		uri += "?" + logger.RedactQuery(r.URL.Query()).Encode()
+	}
+	return uri
+}
+
+// setRequest запоминает распарсенный запрос: операция, бакет, ключ и пользователь
+// берутся из него при записи в журнал
+func (a *accessRecord) setRequest(req *S3Request) {
+	if a != nil {
+		a.req = req
+	}
+}
+
+// WriteHeader запоминает статус ответа
+func (a *accessRecord) WriteHeader(statusCode int) {
+	if a.status == 0 {
+		a.status = statusCode
+	}
+	a.ResponseWriter.WriteHeader(statusCode)
+}
+
+// Write учитывает отправленные байты тела ответа
+func (a *accessRecord) Write(p []byte) (int, error) {
+	if a.status == 0 {
+		a.status = http.StatusOK
+	}
+	n, err := a.ResponseWriter.Write(p)
+	a.bytesSent += int64(n)
+	return n, err
+}
+
+// Unwrap возвращает исходный http.ResponseWriter (для http.ResponseController)
+func (a *accessRecord) Unwrap() http.ResponseWriter {
+	return a.ResponseWriter
+}
+
+// countingBody считает байты, прочитанные из тела запроса. Тело может дочитываться
+// в фоне после ответа клиенту (ack=one), поэтому счетчик атомарный.
+type countingBody struct {
+	io.ReadCloser
+	n atomic.Int64
+}
+
+func (b *countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	b.n.Add(int64(n))
+	return n, err
+}
+
+// write выводит строку журнала доступа для завершенного запроса
+func (l *accessLog) write(record *accessRecord) {
+	operation, bucket, key, user := unknownOperation, "", "", ""
+	if record.req != nil {
+		operation = record.req.Operation.String()
+		bucket, key, user = record.req.Bucket, record.req.Key, record.req.AccessKey
+	}
+	status := record.status
+	if status == 0 {
+		status = http.StatusOK // net/http отвечает 200, если обработчик ничего не записал
+	}
+	var bytesReceived int64
+	if record.body != nil {
+		bytesReceived = record.body.n.Load()
+	}
+
+	line := fmt.Sprintf("[%s] %s %s %s %s %s %s %s %d %d %d %.3f %s\n",
+		record.start.Format(accessLogTimeFormat),
+		accessLogField(record.remoteIP),
+		accessLogField(user),
+		record.requestID,
+		operation,
+		accessLogField(bucket),
+		accessLogField(escapeLogKey(key)),
+		strconv.Quote(record.requestLine),
+		status,
+		record.bytesSent,
+		bytesReceived,
+		float64(time.Since(record.start).Microseconds())/1000.0,
+		strconv.Quote(record.userAgent),
+	)
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if _, err := io.WriteString(l.w, line); err != nil {
+		logger.Warn("[%s] Failed to write access log: %v", record.requestID, err)
+	}
+}
+
+// accessLogField возвращает значение поля журнала доступа или "-" для пустого
+func accessLogField(value string) string {
+	if value == "" {
+		return "-"
+	}
+	return value
+}
+
+// escapeLogKey кодирует ключ объекта для журнала доступа, сохраняя "/" для читаемости:
+// пробелы и управляющие символы в ключе не должны ломать разбор строки
+func escapeLogKey(key string) string {
+	return strings.ReplaceAll(url.PathEscape(key), "%2F", "/")
+}
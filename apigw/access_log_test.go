@@ -0,0 +1,123 @@
+package apigw
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strings"
+	"testing"
+
+	"s3proxy/logger"
+)
+
+// authenticatedObjectHandler отмечает пользователя запроса, как это делает Policy & Routing
+// Engine, и отдает тело объекта
+type authenticatedObjectHandler struct {
+	body string
+}
+
+func (h authenticatedObjectHandler) Handle(req *S3Request) *S3Response {
+	req.AccessKey = "AKIATESTUSER"
+	if req.Body != nil {
+		io.Copy(io.Discard, req.Body)
+	}
+	headers := make(http.Header)
+	headers.Set("Content-Type", "text/plain")
+	return &S3Response{StatusCode: http.StatusOK, Headers: headers, Body: io.NopCloser(strings.NewReader(h.body))}
+}
+
+func TestGateway_AccessLog(t *testing.T) {
+	var accessLog bytes.Buffer
+	config := DefaultConfig()
+	config.AccessLog = &accessLog
+	gw := New(config, authenticatedObjectHandler{body: "hello world"})
+
+	req := httptest.NewRequest(http.MethodGet, "/my-bucket/dir/my%20file.txt", nil)
+	req.RemoteAddr = "192.0.2.10:53124"
+	req.Header.Set("User-Agent", "test-agent/1.0")
+	rec := httptest.NewRecorder()
+	gw.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rec.Code)
+	}
+
+	line := accessLog.String()
+	if strings.Count(line, "\n") != 1 {
+		t.Fatalf("Expected exactly one access log line, got %q", line)
+	}
+	pattern := regexp.MustCompile(`^\[\d{2}/\w{3}/\d{4}:\d{2}:\d{2}:\d{2} [+-]\d{4}\] ` +
+		`192\.0\.2\.10 AKIATESTUSER (\S+) GET_OBJECT my-bucket dir/my%20file\.txt ` +
+		`"GET /my-bucket/dir/my%20file\.txt HTTP/1\.1" 200 11 0 \d+\.\d{3} "test-agent/1\.0"\n$`)
+	match := pattern.FindStringSubmatch(line)
+	if match == nil {
+		t.Fatalf("Access log line does not match expected format: %q", line)
+	}
+	if requestID := rec.Header().Get(RequestIDHeader); match[1] != requestID {
+		t.Errorf("Expected request ID %s in access log, got %s", requestID, match[1])
+	}
+}
+
+func TestGateway_AccessLogBytesReceivedAndRejected(t *testing.T) {
+	var accessLog bytes.Buffer
+	config := DefaultConfig()
+	config.AccessLog = &accessLog
+	config.MaxObjectSize = 4
+	gw := New(config, authenticatedObjectHandler{})
+
+	// Принятый PUT: учитываются байты тела запроса
+	gw.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPut, "/my-bucket/a.txt", strings.NewReader("abc")))
+	// Отклоненный до разбора запрос тоже попадает в журнал, без пользователя и операции
+	gw.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPut, "/my-bucket/b.txt", strings.NewReader("too large")))
+
+	lines := strings.Split(strings.TrimSuffix(accessLog.String(), "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("Expected 2 access log lines, got %q", accessLog.String())
+	}
+	if !strings.Contains(lines[0], ` AKIATESTUSER `) || !strings.Contains(lines[0], ` PUT_OBJECT my-bucket a.txt "PUT /my-bucket/a.txt HTTP/1.1" 200 0 3 `) {
+		t.Errorf("Unexpected access log line for accepted PUT: %q", lines[0])
+	}
+	if !strings.Contains(lines[1], ` - `) || !strings.Contains(lines[1], ` UNKNOWN - - "PUT /my-bucket/b.txt HTTP/1.1" 400 `) {
+		t.Errorf("Unexpected access log line for rejected PUT: %q", lines[1])
+	}
+}
+
+func TestGateway_AccessLogDisabled(t *testing.T) {
+	gw := New(DefaultConfig(), authenticatedObjectHandler{body: "hello"})
+	if gw.accessLog != nil {
+		t.Fatal("Expected access log to be disabled without writer")
+	}
+	rec := httptest.NewRecorder()
+	gw.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/my-bucket/a.txt", nil))
+	if rec.Body.String() != "hello" {
+		t.Errorf("Expected body to be written without access log, got %q", rec.Body.String())
+	}
+}
+
+func TestGateway_AccessLogRedactsPresignedQuery(t *testing.T) {
+	var accessLog bytes.Buffer
+	config := DefaultConfig()
+	config.AccessLog = &accessLog
+	gw := New(config, authenticatedObjectHandler{body: "hello"})
+
+	target := "/my-bucket/a.txt?X-Amz-Algorithm=AWS4-HMAC-SHA256&X-Amz-Credential=AKIATESTUSER%2F20250101%2Fus-east-1%2Fs3%2Faws4_request" +
+		"&X-Amz-Date=20250101T000000Z&X-Amz-Expires=300&X-Amz-SignedHeaders=host" +
+		"&X-Amz-Security-Token=secret-session-token&X-Amz-Signature=0123456789abcdef"
+	gw.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, target, nil))
+
+	line := accessLog.String()
+	for _, secret := range []string{"secret-session-token", "0123456789abcdef"} {
+		if strings.Contains(line, secret) {
+			t.Errorf("Access log line leaks presigned URL secret %q: %q", secret, line)
+		}
+	}
+	if !strings.Contains(line, `"GET /my-bucket/a.txt?`) || !strings.Contains(line, "X-Amz-Expires=300") {
+		t.Errorf("Expected request line with path and non-secret query parameters, got %q", line)
+	}
+	if !strings.Contains(line, "X-Amz-Signature="+url.QueryEscape(logger.RedactedValue)) {
+		t.Errorf("Expected redacted signature in access log, got %q", line)
+	}
+}
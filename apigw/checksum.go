@@ -0,0 +1,41 @@
+package apigw
+
+import (
+	"crypto/sha1"
+	"crypto/sha256"
+	"hash"
+	"hash/crc32"
+	"hash/crc64"
+	"strings"
+)
+
+// ChecksumHeaders - заголовки контрольных сумм объекта (x-amz-checksum-*), которые клиент
+// передает при записи и получает при чтении с x-amz-checksum-mode: ENABLED. Значения - base64.
+var ChecksumHeaders = []string{
+	"x-amz-checksum-crc32",
+	"x-amz-checksum-crc32c",
+	"x-amz-checksum-crc64nvme",
+	"x-amz-checksum-sha1",
+	"x-amz-checksum-sha256",
+}
+
+// crc64NVMEPolynomial - полином CRC-64/NVME в обратной записи, как у таблиц hash/crc64
+const crc64NVMEPolynomial = 0x9a6c9329ac4bc9b5
+
+// NewChecksumHash возвращает функцию хэширования для заголовка контрольной суммы
+// (x-amz-checksum-*) или nil, если алгоритм не поддерживается
+func NewChecksumHash(header string) hash.Hash {
+	switch strings.ToLower(header) {
+	case "x-amz-checksum-crc32":
+		return crc32.NewIEEE()
+	case "x-amz-checksum-crc32c":
+		return crc32.New(crc32.MakeTable(crc32.Castagnoli))
+	case "x-amz-checksum-crc64nvme":
+		return crc64.New(crc64.MakeTable(crc64NVMEPolynomial))
+	case "x-amz-checksum-sha1":
+		return sha1.New()
+	case "x-amz-checksum-sha256":
+		return sha256.New()
+	}
+	return nil
+}
@@ -0,0 +1,214 @@
+package apigw
+
+import (
+	"bufio"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync/atomic"
+)
+
+// Режимы обработки трейлера с контрольной суммой (x-amz-trailer)
+const (
+	// TrailerChecksumVerify - контрольная сумма из трейлера сверяется с вычисленной по телу
+	TrailerChecksumVerify = "verify"
+	// TrailerChecksumIgnore - трейлер вычитывается, но не проверяется
+	TrailerChecksumIgnore = "ignore"
+)
+
+// ErrBadDigest возвращается при чтении aws-chunked тела, контрольная сумма
+// которого не совпала с переданной в трейлере
+var ErrBadDigest = errors.New("trailing checksum does not match request body")
+
+// errMalformedChunk возвращается при нарушении формата aws-chunked
+var errMalformedChunk = errors.New("malformed aws-chunked body")
+
+// isAWSChunked сообщает, что тело запроса передано в формате aws-chunked
+func isAWSChunked(r *http.Request) bool {
+	return strings.Contains(r.Header.Get("Content-Encoding"), "aws-chunked") ||
+		strings.HasPrefix(r.Header.Get("X-Amz-Content-Sha256"), "STREAMING-")
+}
+
+// chunkedBody декодирует тело в формате aws-chunked: последовательность
+// "<hex-размер>[;chunk-signature=...]\r\n<данные>\r\n", завершающуюся чанком
+// нулевого размера и трейлерами "<имя>:<значение>\r\n".
+// Если объявлен трейлер с контрольной суммой (x-amz-trailer) и включена проверка,
+// сумма вычисляется по данным и сверяется с трейлером. Последний блок данных
+// отдается только после проверки, чтобы при несовпадении потребитель
+// получил ErrBadDigest, а не полное тело.
+type chunkedBody struct {
+	body      io.ReadCloser
+	reader    *bufio.Reader
+	remaining int64 // Непрочитанные байты текущего чанка
+	started   bool
+	finished  bool
+	err       error
+
+	trailer  string    // Объявленный трейлер с контрольной суммой
+	checksum hash.Hash // nil - проверка не выполняется
+
+	badDigest atomic.Bool
+}
+
+// newChunkedBody создает декодер aws-chunked тела. trailer - значение заголовка
+// x-amz-trailer, verify включает проверку контрольной суммы.
+func newChunkedBody(body io.ReadCloser, trailer string, verify bool) *chunkedBody {
+	c := &chunkedBody{
+		body:    body,
+		reader:  bufio.NewReader(body),
+		trailer: strings.ToLower(strings.TrimSpace(trailer)),
+	}
+	if verify && c.trailer != "" {
+		c.checksum = NewChecksumHash(c.trailer)
+	}
+	return c
+}
+
+// Read возвращает декодированные данные объекта
+func (c *chunkedBody) Read(p []byte) (int, error) {
+	if c.err != nil {
+		return 0, c.err
+	}
+	if !c.started {
+		c.started = true
+		if err := c.nextChunk(); err != nil {
+			return 0, c.fail(err)
+		}
+	}
+	if c.finished {
+		return 0, io.EOF
+	}
+	if len(p) == 0 {
+		return 0, nil
+	}
+
+	last := int64(len(p)) >= c.remaining
+	if !last {
+		n, err := c.reader.Read(p)
+		c.consume(p[:n])
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		if err != nil {
+			return n, c.fail(err)
+		}
+		return n, nil
+	}
+
+	// Дочитываем чанк целиком и заглядываем в следующий: если он завершающий,
+	// данные отдаются только после проверки трейлера
+	n, err := io.ReadFull(c.reader, p[:c.remaining])
+	c.consume(p[:n])
+	if err != nil {
+		if err == io.EOF {
+			err = io.ErrUnexpectedEOF
+		}
+		return n, c.fail(err)
+	}
+	if err := c.readCRLF(); err != nil {
+		return 0, c.fail(err)
+	}
+	if err := c.nextChunk(); err != nil {
+		return 0, c.fail(err)
+	}
+	return n, nil
+}
+
+// Close закрывает исходное тело запроса
+func (c *chunkedBody) Close() error {
+	return c.body.Close()
+}
+
+// BadDigest сообщает, что контрольная сумма тела не совпала с трейлером
+func (c *chunkedBody) BadDigest() bool {
+	return c.badDigest.Load()
+}
+
+// consume учитывает прочитанные данные текущего чанка
+func (c *chunkedBody) consume(data []byte) {
+	c.remaining -= int64(len(data))
+	if c.checksum != nil {
+		c.checksum.Write(data)
+	}
+}
+
+// fail запоминает ошибку, которая будет возвращаться всеми последующими вызовами Read
+func (c *chunkedBody) fail(err error) error {
+	if errors.Is(err, ErrBadDigest) {
+		c.badDigest.Store(true)
+	}
+	c.err = err
+	return err
+}
+
+// nextChunk читает заголовок очередного чанка. Чанк нулевого размера
+// завершает тело: за ним читаются и проверяются трейлеры.
+func (c *chunkedBody) nextChunk() error {
+	line, err := c.reader.ReadString('\n')
+	if err != nil {
+		return fmt.Errorf("%w: %v", errMalformedChunk, err)
+	}
+	sizeStr := strings.TrimSpace(line)
+	if idx := strings.Index(sizeStr, ";"); idx >= 0 {
+		sizeStr = sizeStr[:idx]
+	}
+	size, err := strconv.ParseInt(sizeStr, 16, 64)
+	if err != nil || size < 0 {
+		return fmt.Errorf("%w: invalid chunk size %q", errMalformedChunk, sizeStr)
+	}
+	if size > 0 {
+		c.remaining = size
+		return nil
+	}
+
+	c.finished = true
+	return c.readTrailers()
+}
+
+// readTrailers читает трейлеры после завершающего чанка и сверяет контрольную сумму
+func (c *chunkedBody) readTrailers() error {
+	var received string
+	for {
+		line, err := c.reader.ReadString('\n')
+		line = strings.TrimSpace(line)
+		if name, value, found := strings.Cut(line, ":"); found &&
+			strings.EqualFold(strings.TrimSpace(name), c.trailer) {
+			received = strings.TrimSpace(value)
+		}
+		if line == "" || err != nil {
+			break
+		}
+	}
+
+	if c.checksum == nil {
+		return nil
+	}
+	expected := base64.StdEncoding.EncodeToString(c.checksum.Sum(nil))
+	if received != expected {
+		return fmt.Errorf("%w: %s expected %q, got %q", ErrBadDigest, c.trailer, expected, received)
+	}
+	return nil
+}
+
+// readCRLF читает разделитель после данных чанка
+func (c *chunkedBody) readCRLF() error {
+	line, err := c.reader.ReadString('\n')
+	if err != nil {
+		return fmt.Errorf("%w: %v", errMalformedChunk, err)
+	}
+	if strings.TrimSpace(line) != "" {
+		return fmt.Errorf("%w: missing chunk terminator", errMalformedChunk)
+	}
+	return nil
+}
+
+// badDigestResponse формирует S3 ответ BadDigest
+func badDigestResponse(requestID, resource string) *S3Response {
+	return NewErrorResponse(requestID, resource, http.StatusBadRequest, "BadDigest",
+		"The checksum you specified did not match what we received.")
+}
@@ -0,0 +1,110 @@
+package apigw
+
+import (
+	"encoding/base64"
+	"encoding/binary"
+	"hash/crc32"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// capturingHandler сохраняет прочитанное тело запроса
+type capturingHandler struct {
+	body          string
+	contentLength int64
+}
+
+func (h *capturingHandler) Handle(req *S3Request) *S3Response {
+	h.contentLength = req.ContentLength
+	data, err := io.ReadAll(req.Body)
+	h.body = string(data)
+	if err != nil {
+		return &S3Response{StatusCode: http.StatusInternalServerError, Error: err}
+	}
+	return &S3Response{StatusCode: http.StatusOK}
+}
+
+// crc32Base64 вычисляет CRC32 в формате заголовка x-amz-checksum-crc32
+func crc32Base64(data string) string {
+	sum := make([]byte, 4)
+	binary.BigEndian.PutUint32(sum, crc32.ChecksumIEEE([]byte(data)))
+	return base64.StdEncoding.EncodeToString(sum)
+}
+
+// newTrailerRequest создает aws-chunked PUT запрос из двух чанков с трейлером x-amz-checksum-crc32
+func newTrailerRequest(data, checksum string) *http.Request {
+	half := len(data) / 2
+	body := strconv.FormatInt(int64(half), 16) + "\r\n" + data[:half] + "\r\n" +
+		strconv.FormatInt(int64(len(data)-half), 16) + "\r\n" + data[half:] + "\r\n" +
+		"0\r\n" +
+		"x-amz-checksum-crc32:" + checksum + "\r\n" +
+		"\r\n"
+
+	req := httptest.NewRequest(http.MethodPut, "/my-bucket/object.txt", strings.NewReader(body))
+	req.Header.Set("Content-Encoding", "aws-chunked")
+	req.Header.Set("X-Amz-Content-Sha256", "STREAMING-UNSIGNED-PAYLOAD-TRAILER")
+	req.Header.Set("X-Amz-Decoded-Content-Length", strconv.Itoa(len(data)))
+	req.Header.Set("X-Amz-Trailer", "x-amz-checksum-crc32")
+	return req
+}
+
+func TestGateway_TrailerChecksumMatches(t *testing.T) {
+	handler := &capturingHandler{}
+	gw := New(DefaultConfig(), handler)
+
+	data := "hello, trailing checksum"
+	rec := httptest.NewRecorder()
+	gw.ServeHTTP(rec, newTrailerRequest(data, crc32Base64(data)))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+	}
+	if handler.body != data {
+		t.Errorf("Expected decoded body %q, got %q", data, handler.body)
+	}
+	if handler.contentLength != int64(len(data)) {
+		t.Errorf("Expected decoded content length %d, got %d", len(data), handler.contentLength)
+	}
+}
+
+func TestGateway_TrailerChecksumMismatch(t *testing.T) {
+	handler := &capturingHandler{}
+	gw := New(DefaultConfig(), handler)
+
+	data := "hello, trailing checksum"
+	rec := httptest.NewRecorder()
+	gw.ServeHTTP(rec, newTrailerRequest(data, crc32Base64("something else")))
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("Expected status 400, got %d", rec.Code)
+	}
+	if !strings.Contains(rec.Body.String(), "<Code>BadDigest</Code>") {
+		t.Errorf("Expected BadDigest error, got %s", rec.Body.String())
+	}
+	// Последний блок данных не отдается до проверки трейлера
+	if handler.body == data {
+		t.Error("Body with mismatching checksum must not be delivered completely")
+	}
+}
+
+func TestGateway_TrailerChecksumIgnored(t *testing.T) {
+	config := DefaultConfig()
+	config.TrailerChecksum = TrailerChecksumIgnore
+	handler := &capturingHandler{}
+	gw := New(config, handler)
+
+	data := "hello, trailing checksum"
+	rec := httptest.NewRecorder()
+	gw.ServeHTTP(rec, newTrailerRequest(data, crc32Base64("something else")))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200 with trailer_checksum=ignore, got %d", rec.Code)
+	}
+	if handler.body != data {
+		t.Errorf("Expected decoded body %q, got %q", data, handler.body)
+	}
+}
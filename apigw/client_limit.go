@@ -0,0 +1,65 @@
+package apigw
+
+import (
+	"net"
+	"net/http"
+	"sync"
+)
+
+// clientLimiter ограничивает число одновременно обрабатываемых запросов с одного
+// IP-адреса клиента. Лимит не затрагивает других клиентов.
+type clientLimiter struct {
+	mu       sync.Mutex
+	limit    int
+	inFlight map[string]int // IP клиента -> число запросов в обработке
+}
+
+// newClientLimiter создает ограничитель или возвращает nil, если лимит не задан
+func newClientLimiter(limit int) *clientLimiter {
+	if limit <= 0 {
+		return nil
+	}
+	return &clientLimiter{
+		limit:    limit,
+		inFlight: make(map[string]int),
+	}
+}
+
+// acquire занимает слот клиента. Возвращает false, если у клиента уже limit запросов в обработке.
+func (l *clientLimiter) acquire(ip string) bool {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.inFlight[ip] >= l.limit {
+		return false
+	}
+	l.inFlight[ip]++
+	return true
+}
+
+// release освобождает слот, занятый acquire
+func (l *clientLimiter) release(ip string) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.inFlight[ip] <= 1 {
+		// Не храним записи о клиентах без активных запросов
+		delete(l.inFlight, ip)
+		return
+	}
+	l.inFlight[ip]--
+}
+
+// clientIP возвращает IP-адрес клиента из адреса соединения. Заголовки вроде
+// X-Forwarded-For не учитываются: клиент может подставить в них любое значение.
+func clientIP(r *http.Request) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	return host
+}
+
+// slowDownResponse формирует S3 ответ SlowDown для клиента, превысившего лимит
+func slowDownResponse(requestID, resource string) *S3Response {
+	return NewErrorResponse(requestID, resource, http.StatusServiceUnavailable, "SlowDown",
+		"Please reduce your request rate.")
+}
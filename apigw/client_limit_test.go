@@ -0,0 +1,71 @@
+package apigw
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// slowKeyHandler задерживает ответ на запрос объекта "slow" до закрытия release,
+// остальные запросы обрабатывает сразу
+type slowKeyHandler struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func (h *slowKeyHandler) Handle(req *S3Request) *S3Response {
+	if req.Key == "slow" {
+		close(h.started)
+		<-h.release
+	}
+	return &S3Response{StatusCode: http.StatusOK}
+}
+
+// serveFrom выполняет запрос от имени клиента с адресом remoteAddr
+func serveFrom(gw *Gateway, remoteAddr, path string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	req.RemoteAddr = remoteAddr
+	rec := httptest.NewRecorder()
+	gw.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestGateway_MaxRequestsPerClient(t *testing.T) {
+	config := DefaultConfig()
+	config.MaxRequestsPerClient = 1
+	handler := &slowKeyHandler{started: make(chan struct{}), release: make(chan struct{})}
+	gw := New(config, handler)
+
+	// Клиент A занимает свой единственный слот долгим запросом
+	done := make(chan *httptest.ResponseRecorder, 1)
+	go func() { done <- serveFrom(gw, "10.0.0.1:40001", "/my-bucket/slow") }()
+	<-handler.started
+
+	// Второй запрос клиента A (с другого порта) превышает лимит
+	rec := serveFrom(gw, "10.0.0.1:40002", "/my-bucket/other")
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("Expected status 503 for client over its limit, got %d", rec.Code)
+	}
+	if !strings.Contains(rec.Body.String(), "<Code>SlowDown</Code>") {
+		t.Errorf("Expected SlowDown error, got %s", rec.Body.String())
+	}
+
+	// Клиент B лимит клиента A не затрагивает
+	if rec := serveFrom(gw, "10.0.0.2:40001", "/my-bucket/other"); rec.Code != http.StatusOK {
+		t.Errorf("Expected status 200 for another client, got %d", rec.Code)
+	}
+
+	close(handler.release)
+	if rec := <-done; rec.Code != http.StatusOK {
+		t.Errorf("Expected slow request to complete with 200, got %d", rec.Code)
+	}
+
+	// Завершенный запрос освобождает слот клиента A
+	if rec := serveFrom(gw, "10.0.0.1:40003", "/my-bucket/other"); rec.Code != http.StatusOK {
+		t.Errorf("Expected status 200 after slot was released, got %d", rec.Code)
+	}
+	if len(gw.clientLimiter.inFlight) != 0 {
+		t.Errorf("Expected no tracked clients after all requests completed, got %v", gw.clientLimiter.inFlight)
+	}
+}
@@ -1,6 +1,9 @@
 package apigw
 
-import "time"
+import (
+	"io"
+	"time"
+)
 
 // Config содержит конфигурацию для API Gateway
 type Config struct {
@@ -18,6 +21,45 @@ type Config struct {
 
 	// WriteTimeout - таймаут на запись всего ответа
 	WriteTimeout time.Duration
+
+	// BaseDomain - домен эндпоинта прокси для virtual-hosted адресации
+	// (bucket.BaseDomain/key). Если не задан, поддерживается только path-style.
+	BaseDomain string
+
+	// MaxObjectSize - максимальный размер тела запроса в байтах (0 - без ограничения).
+	// Запросы большего размера отклоняются с ошибкой EntityTooLarge.
+	MaxObjectSize int64
+
+	// MaxRequestsPerClient - максимальное число одновременно обрабатываемых запросов
+	// с одного IP-адреса клиента (0 - без ограничения). Запросы сверх лимита
+	// отклоняются с ошибкой SlowDown (503).
+	MaxRequestsPerClient int
+
+	// TrailerChecksum - обработка контрольной суммы из трейлера aws-chunked загрузок (x-amz-trailer):
+	// "verify" (по умолчанию) - сверять с телом и отвечать BadDigest при несовпадении,
+	// "ignore" - только вычитывать трейлер
+	TrailerChecksum string
+
+	// BodyLengthCheck - сверка декодированного тела aws-chunked с X-Amz-Decoded-Content-Length:
+	// "verify" (по умолчанию) - тело длиннее заявленного отклоняется с ошибкой IncompleteBody,
+	// "ignore" - не проверять. Обычное тело net/http сам ограничивает значением Content-Length
+	// (лишние байты читаются как следующий запрос соединения), поэтому его не сверяем.
+	BodyLengthCheck string
+
+	// OptionsResponse - ответ на OPTIONS без CORS заголовков: "allow" (по умолчанию) -
+	// 200 с заголовком Allow, "reject" - 405 с заголовком Allow
+	OptionsResponse string
+
+	// CORS - настройки CORS для браузерных клиентов (по умолчанию выключен)
+	CORS CORSConfig
+
+	// RecentRequests - сколько последних обработанных запросов хранить в памяти для отладки
+	// (см. Gateway.RecentRequests). 0 - не хранить.
+	RecentRequests int
+
+	// AccessLog - назначение журнала доступа: строка на каждый обработанный запрос
+	// (IP, пользователь, операция, бакет, ключ, статус, байты, время). nil - журнал выключен.
+	AccessLog io.Writer
 }
 
 // DefaultConfig возвращает конфигурацию по умолчанию
@@ -0,0 +1,141 @@
+package apigw
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"s3proxy/logger"
+)
+
+// CORSConfig содержит настройки CORS для браузерных S3 клиентов.
+// CORS включен, если задан хотя бы один разрешенный Origin.
+type CORSConfig struct {
+	// AllowedOrigins - разрешенные значения заголовка Origin. "*" разрешает любой Origin
+	AllowedOrigins []string `yaml:"allowed_origins"`
+
+	// AllowedMethods - методы, разрешенные в preflight запросах (например, GET, PUT)
+	AllowedMethods []string `yaml:"allowed_methods"`
+
+	// AllowedHeaders - заголовки, разрешенные в preflight запросах. "*" разрешает любые заголовки
+	AllowedHeaders []string `yaml:"allowed_headers"`
+
+	// ExposeHeaders - заголовки ответа, доступные JavaScript (например, ETag)
+	ExposeHeaders []string `yaml:"expose_headers"`
+
+	// MaxAge - время кэширования результата preflight браузером в секундах (0 - не передавать)
+	MaxAge int `yaml:"max_age"`
+}
+
+// Enabled сообщает, включен ли CORS
+func (c *CORSConfig) Enabled() bool {
+	return len(c.AllowedOrigins) > 0
+}
+
+// allowedOrigin возвращает значение для Access-Control-Allow-Origin или пустую строку,
+// если Origin не разрешен
+func (c *CORSConfig) allowedOrigin(origin string) string {
+	if origin == "" {
+		return ""
+	}
+	for _, allowed := range c.AllowedOrigins {
+		if allowed == "*" {
+			return "*"
+		}
+		if strings.EqualFold(allowed, origin) {
+			return origin
+		}
+	}
+	return ""
+}
+
+// methodAllowed проверяет, разрешен ли метод
+func (c *CORSConfig) methodAllowed(method string) bool {
+	for _, allowed := range c.AllowedMethods {
+		if strings.EqualFold(allowed, method) {
+			return true
+		}
+	}
+	return false
+}
+
+// headersAllowed проверяет, разрешены ли все заголовки из Access-Control-Request-Headers
+func (c *CORSConfig) headersAllowed(requested []string) bool {
+	for _, header := range requested {
+		allowed := false
+		for _, candidate := range c.AllowedHeaders {
+			if candidate == "*" || strings.EqualFold(candidate, header) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return false
+		}
+	}
+	return true
+}
+
+// handlePreflight отвечает на CORS preflight (OPTIONS) запрос, не передавая его обработчику
+func (gw *Gateway) handlePreflight(w http.ResponseWriter, r *http.Request) int {
+	cors := &gw.config.CORS
+
+	origin := r.Header.Get("Origin")
+	method := r.Header.Get("Access-Control-Request-Method")
+	requestedHeaders := splitHeaderList(r.Header.Get("Access-Control-Request-Headers"))
+
+	allowOrigin := cors.allowedOrigin(origin)
+	if allowOrigin == "" || method == "" || !cors.methodAllowed(method) || !cors.headersAllowed(requestedHeaders) {
+		logger.Debug("CORS preflight rejected: origin=%q, method=%q, headers=%v", origin, method, requestedHeaders)
+		w.Header().Set("Vary", "Origin")
+		s3resp := NewErrorResponse(w.Header().Get(RequestIDHeader), r.URL.Path, http.StatusForbidden,
+			"AccessForbidden", "CORSResponse: This CORS request is not allowed.")
+		gw.responseWriter.WriteResponse(w, s3resp)
+		return http.StatusForbidden
+	}
+
+	headers := w.Header()
+	headers.Set("Access-Control-Allow-Origin", allowOrigin)
+	headers.Set("Access-Control-Allow-Methods", strings.Join(cors.AllowedMethods, ", "))
+	if len(requestedHeaders) > 0 {
+		headers.Set("Access-Control-Allow-Headers", strings.Join(requestedHeaders, ", "))
+	}
+	if len(cors.ExposeHeaders) > 0 {
+		headers.Set("Access-Control-Expose-Headers", strings.Join(cors.ExposeHeaders, ", "))
+	}
+	if cors.MaxAge > 0 {
+		headers.Set("Access-Control-Max-Age", strconv.Itoa(cors.MaxAge))
+	}
+	headers.Set("Vary", "Origin")
+
+	logger.Debug("CORS preflight allowed: origin=%q, method=%q", origin, method)
+	w.WriteHeader(http.StatusOK)
+	return http.StatusOK
+}
+
+// setCORSHeaders добавляет CORS заголовки к ответу на обычный (не preflight) запрос
+func (gw *Gateway) setCORSHeaders(w http.ResponseWriter, r *http.Request) {
+	cors := &gw.config.CORS
+	allowOrigin := cors.allowedOrigin(r.Header.Get("Origin"))
+	if allowOrigin == "" {
+		return
+	}
+
+	headers := w.Header()
+	headers.Set("Access-Control-Allow-Origin", allowOrigin)
+	if len(cors.ExposeHeaders) > 0 {
+		headers.Set("Access-Control-Expose-Headers", strings.Join(cors.ExposeHeaders, ", "))
+	}
+	headers.Add("Vary", "Origin")
+}
+
+// splitHeaderList разбирает список заголовков, разделенных запятыми
+func splitHeaderList(value string) []string {
+	var result []string
+	for _, part := range strings.Split(value, ",") {
+		if part = strings.TrimSpace(part); part != "" {
+			result = append(result, part)
+		}
+	}
+	return result
+}
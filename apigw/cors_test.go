@@ -0,0 +1,156 @@
+package apigw
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// recordingHandler запоминает, вызывался ли обработчик
+type recordingHandler struct {
+	called bool
+}
+
+func (h *recordingHandler) Handle(req *S3Request) *S3Response {
+	h.called = true
+	headers := make(http.Header)
+	headers.Set("ETag", `"test-etag"`)
+	return &S3Response{StatusCode: http.StatusOK, Headers: headers}
+}
+
+func newCORSTestGateway() (*Gateway, *recordingHandler) {
+	config := DefaultConfig()
+	config.CORS = CORSConfig{
+		AllowedOrigins: []string{"https://app.example.com"},
+		AllowedMethods: []string{"GET", "PUT", "HEAD"},
+		AllowedHeaders: []string{"Content-Type", "Authorization", "X-Amz-Date", "X-Amz-Content-Sha256"},
+		ExposeHeaders:  []string{"ETag"},
+		MaxAge:         3000,
+	}
+	handler := &recordingHandler{}
+	return New(config, handler), handler
+}
+
+func TestGateway_CORSPreflight(t *testing.T) {
+	gw, handler := newCORSTestGateway()
+
+	req := httptest.NewRequest(http.MethodOptions, "/my-bucket/object.txt", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	req.Header.Set("Access-Control-Request-Method", "PUT")
+	req.Header.Set("Access-Control-Request-Headers", "content-type, x-amz-date")
+
+	rec := httptest.NewRecorder()
+	gw.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rec.Code)
+	}
+	if handler.called {
+		t.Error("Preflight request must not be routed to the handler")
+	}
+
+	expected := map[string]string{
+		"Access-Control-Allow-Origin":   "https://app.example.com",
+		"Access-Control-Allow-Methods":  "GET, PUT, HEAD",
+		"Access-Control-Allow-Headers":  "content-type, x-amz-date",
+		"Access-Control-Expose-Headers": "ETag",
+		"Access-Control-Max-Age":        "3000",
+		"Vary":                          "Origin",
+	}
+	for header, value := range expected {
+		if got := rec.Header().Get(header); got != value {
+			t.Errorf("Expected %s %q, got %q", header, value, got)
+		}
+	}
+}
+
+func TestGateway_CORSPreflightRejected(t *testing.T) {
+	gw, handler := newCORSTestGateway()
+
+	tests := []struct {
+		name    string
+		origin  string
+		method  string
+		headers string
+	}{
+		{name: "Unknown origin", origin: "https://evil.example.com", method: "PUT"},
+		{name: "Method not allowed", origin: "https://app.example.com", method: "DELETE"},
+		{name: "Header not allowed", origin: "https://app.example.com", method: "PUT", headers: "x-custom-header"},
+		{name: "Missing request method", origin: "https://app.example.com"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodOptions, "/my-bucket/object.txt", nil)
+			req.Header.Set("Origin", tt.origin)
+			if tt.method != "" {
+				req.Header.Set("Access-Control-Request-Method", tt.method)
+			}
+			if tt.headers != "" {
+				req.Header.Set("Access-Control-Request-Headers", tt.headers)
+			}
+
+			rec := httptest.NewRecorder()
+			gw.ServeHTTP(rec, req)
+
+			if rec.Code != http.StatusForbidden {
+				t.Errorf("Expected status 403, got %d", rec.Code)
+			}
+			if rec.Header().Get("Access-Control-Allow-Origin") != "" {
+				t.Error("Rejected preflight must not contain Access-Control-Allow-Origin")
+			}
+		})
+	}
+
+	if handler.called {
+		t.Error("Preflight request must not be routed to the handler")
+	}
+}
+
+func TestGateway_CORSHeadersOnResponse(t *testing.T) {
+	gw, handler := newCORSTestGateway()
+
+	req := httptest.NewRequest(http.MethodGet, "/my-bucket/object.txt", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+
+	rec := httptest.NewRecorder()
+	gw.ServeHTTP(rec, req)
+
+	if !handler.called {
+		t.Fatal("Expected request to be routed to the handler")
+	}
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "https://app.example.com" {
+		t.Errorf("Expected Access-Control-Allow-Origin to echo origin, got %q", got)
+	}
+	if got := rec.Header().Get("Access-Control-Expose-Headers"); got != "ETag" {
+		t.Errorf("Expected Access-Control-Expose-Headers ETag, got %q", got)
+	}
+
+	// Запрос без Origin не получает CORS заголовков
+	req = httptest.NewRequest(http.MethodGet, "/my-bucket/object.txt", nil)
+	rec = httptest.NewRecorder()
+	gw.ServeHTTP(rec, req)
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "" {
+		t.Errorf("Expected no CORS headers without Origin, got %q", got)
+	}
+}
+
+func TestGateway_OptionsWithoutCORS(t *testing.T) {
+	handler := &recordingHandler{}
+	gw := New(DefaultConfig(), handler)
+
+	req := httptest.NewRequest(http.MethodOptions, "/my-bucket/object.txt", nil)
+	req.Header.Set("Origin", "https://app.example.com")
+	req.Header.Set("Access-Control-Request-Method", "PUT")
+
+	rec := httptest.NewRecorder()
+	gw.ServeHTTP(rec, req)
+
+	// Без настроенного CORS OPTIONS по-прежнему не поддерживается
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("Expected status 400, got %d", rec.Code)
+	}
+	if handler.called {
+		t.Error("Unsupported request must not be routed to the handler")
+	}
+}
@@ -2,9 +2,13 @@ package apigw
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net"
 	"net/http"
 	"strconv"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"s3proxy/logger"
@@ -16,98 +20,298 @@ type Gateway struct {
 	handler        RequestHandler
 	parser         *RequestParser
 	responseWriter *ResponseWriter
-	server         *http.Server // Добавляем поле для сервера
-	metrics        *Metrics     // Добавляем поле для метрик
+	server         *http.Server    // Добавляем поле для сервера
+	metrics        *Metrics        // Добавляем поле для метрик
+	clientLimiter  *clientLimiter  // Лимит одновременных запросов с одного IP (nil - без ограничения)
+	recent         *RecentRequests // Последние обработанные запросы (nil - выключено)
+	accessLog      *accessLog      // Журнал доступа (nil - выключен)
+
+	mu       sync.Mutex   // Защищает server: Start и Stop вызываются из разных горутин
+	inFlight atomic.Int64 // Количество обрабатываемых запросов
 }
 
+// ErrShutdownTimeout возвращается Stop, если к дедлайну остались незавершенные запросы
+var ErrShutdownTimeout = errors.New("gateway shutdown deadline exceeded with requests in flight")
+
 // New создает новый экземпляр API Gateway
 func New(config Config, handler RequestHandler) *Gateway {
 	return &Gateway{
 		config:         config,
 		handler:        handler,
-		parser:         NewRequestParser(),
+		parser:         NewRequestParser(config.BaseDomain),
 		responseWriter: NewResponseWriter(),
 		metrics:        NewMetrics(),
+		clientLimiter:  newClientLimiter(config.MaxRequestsPerClient),
+		recent:         NewRecentRequests(config.RecentRequests),
+		accessLog:      newAccessLog(config.AccessLog),
 	}
 }
 
+// RecentRequests возвращает буфер последних обработанных запросов или nil,
+// если Config.RecentRequests не задан
+func (gw *Gateway) RecentRequests() *RecentRequests {
+	return gw.recent
+}
+
 // ServeHTTP реализует интерфейс http.Handler
 func (gw *Gateway) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	start := time.Now()
-	var latency float64
+	gw.inFlight.Add(1)
+	defer gw.inFlight.Add(-1)
+
+	// Назначаем запросу уникальный идентификатор для корреляции ответов и логов
+	requestID := newRequestID()
+	w.Header().Set(RequestIDHeader, requestID)
+
 	// Логируем входящий запрос
-	logger.Info("Incoming request: %s %s", r.Method, r.URL.Path)
-	logger.Debug("Request headers: %+v", r.Header)
+	logger.Info("[%s] Incoming request: %s %s", requestID, r.Method, r.URL.Path)
+	logger.Debug("[%s] Request headers: %+v", requestID, logger.RedactHeaders(r.Header))
+
+	// Корневой спан запроса; его контекст передается обработчику и бэкендам
+	r, span := startRequestSpan(r)
+	defer span.End()
+
+	// Журнал доступа пишется после ответа; статус и объем данных учитывают обертки
+	// http.ResponseWriter и тела запроса
+	var access *accessRecord
+	if gw.accessLog != nil {
+		access = newAccessRecord(w, r, requestID, start)
+		w = access
+		defer gw.accessLog.write(access)
+	}
+
+	// Клиент, у которого уже обрабатывается максимум запросов, получает SlowDown,
+	// не занимая обработчик и бэкенды
+	if gw.clientLimiter != nil {
+		ip := clientIP(r)
+		if !gw.clientLimiter.acquire(ip) {
+			logger.Warn("[%s] Client %s exceeded %d concurrent requests", requestID, ip, gw.config.MaxRequestsPerClient)
+			s3resp := slowDownResponse(requestID, r.URL.Path)
+			gw.responseWriter.WriteResponse(w, s3resp)
+			gw.observeRequest(span, r.Method, unknownOperation, s3resp.StatusCode, time.Since(start))
+			return
+		}
+		defer gw.clientLimiter.release(ip)
+	}
+
+	// OPTIONS без CORS заголовков получает список поддерживаемых методов
+	if isBareOptions(r) {
+		status := gw.handleBareOptions(w)
+		gw.observeRequest(span, r.Method, unknownOperation, status, time.Since(start))
+		return
+	}
+
+	// CORS: preflight запросы обрабатываются самим шлюзом, без передачи обработчику
+	if gw.config.CORS.Enabled() {
+		if r.Method == http.MethodOptions {
+			status := gw.handlePreflight(w, r)
+			gw.observeRequest(span, r.Method, unknownOperation, status, time.Since(start))
+			return
+		}
+		gw.setCORSHeaders(w, r)
+	}
+
+	// Expect: 100-continue: net/http отправляет клиенту 100 Continue при первом чтении тела.
+	// Проверки лимита и аутентификация тело не читают, поэтому отказ уходит клиенту
+	// финальным статусом до передачи тела, а 100 Continue получает только принятый запрос.
+	//
+	// Отклоняем запросы, заявленный размер которых превышает лимит, не читая тело
+	if limit := gw.config.MaxObjectSize; limit > 0 && declaredBodySize(r) > limit {
+		logger.Warn("[%s] Request body too large: declared %d bytes, limit %d", requestID, declaredBodySize(r), limit)
+		s3resp := entityTooLargeResponse(requestID, r.URL.Path, limit)
+		gw.responseWriter.WriteResponse(w, s3resp)
+		gw.observeRequest(span, r.Method, unknownOperation, s3resp.StatusCode, time.Since(start))
+		return
+	}
 
 	// Парсим запрос
 	s3req, err := gw.parser.Parse(r)
 	if err != nil {
-		logger.Error("Failed to parse request: %v", err)
+		logger.Error("[%s] Failed to parse request: %v", requestID, err)
 		// Создаем ответ об ошибке парсинга
-		s3resp := &S3Response{
-			StatusCode: http.StatusBadRequest,
-			Error:      fmt.Errorf("invalid request: %v", err),
-		}
+		s3resp := NewErrorResponse(requestID, r.URL.Path, http.StatusBadRequest, "InvalidRequest",
+			fmt.Sprintf("invalid request: %v", err))
 		gw.responseWriter.WriteResponse(w, s3resp)
-
-		latency := time.Since(start).Seconds()
-		gw.metrics.RequestsTotal.WithLabelValues(r.Method, strconv.Itoa(s3resp.StatusCode)).Inc()
-		gw.metrics.RequestLatency.WithLabelValues(r.Method).Observe(latency)
+		gw.observeRequest(span, r.Method, unknownOperation, s3resp.StatusCode, time.Since(start))
 		return
 	}
 
+	s3req.RequestID = requestID
+	access.setRequest(s3req)
+	s3req.Context = ContextWithRequestID(s3req.Context, requestID)
+	var trace *backendTrace
+	if gw.recent != nil {
+		s3req.Context, trace = contextWithBackendTrace(s3req.Context)
+	}
+
 	// Логируем распарсенную операцию
-	logger.Debug("Parsed S3 request: %+v", s3req)
-	logger.Debug("Parsed operation: %s, Bucket: %s, Key: %s",
-		s3req.Operation.String(), s3req.Bucket, s3req.Key)
+	logger.Debug("[%s] Parsed operation: %s, Bucket: %s, Key: %s",
+		requestID, s3req.Operation.String(), s3req.Bucket, s3req.Key)
+
+	// Тело в формате aws-chunked декодируем, объявленный трейлер с контрольной суммой проверяем.
+	// Заголовки не меняются: они нужны для проверки подписи запроса.
+	var dechunker *chunkedBody
+	if isAWSChunked(r) && s3req.Body != nil {
+		verify := gw.config.TrailerChecksum != TrailerChecksumIgnore
+		dechunker = newChunkedBody(s3req.Body, r.Header.Get("X-Amz-Trailer"), verify)
+		s3req.Body = dechunker
+		s3req.ContentLength = -1
+		if decoded, err := strconv.ParseInt(r.Header.Get("X-Amz-Decoded-Content-Length"), 10, 64); err == nil && decoded >= 0 {
+			s3req.ContentLength = decoded
+		}
+	}
+
+	// Тело неизвестной длины (chunked) ограничиваем при чтении
+	var limiter *limitedBody
+	if limit := gw.config.MaxObjectSize; limit > 0 && r.ContentLength < 0 && s3req.Body != nil {
+		limiter = newLimitedBody(s3req.Body, limit)
+		s3req.Body = limiter
+	}
+
+	// Декодированное тело aws-chunked длиннее X-Amz-Decoded-Content-Length не должно молча
+	// обрезаться до него. Обычное тело net/http сам ограничивает значением Content-Length.
+	var lengthChecker *limitedBody
+	if dechunker != nil && gw.config.BodyLengthCheck != BodyLengthIgnore && s3req.ContentLength >= 0 {
+		lengthChecker = newDeclaredLengthBody(s3req.Body, s3req.ContentLength)
+		s3req.Body = lengthChecker
+	}
 
 	// Передаем управление обработчику
 	s3resp := gw.handler.Handle(s3req)
-	logger.Debug("Handler response: %+v", s3resp)
+	logger.Debug("[%s] Handler response: %+v", requestID, s3resp)
+
+	if limiter != nil && limiter.Exceeded() {
+		logger.Warn("[%s] Request body exceeded limit of %d bytes while streaming", requestID, gw.config.MaxObjectSize)
+		if s3resp.Body != nil {
+			s3resp.Body.Close()
+		}
+		s3resp = entityTooLargeResponse(requestID, r.URL.Path, gw.config.MaxObjectSize)
+	}
+
+	if lengthChecker != nil && lengthChecker.Exceeded() {
+		logger.Warn("[%s] Decoded aws-chunked body is longer than declared %d bytes", requestID, s3req.ContentLength)
+		if s3resp.Body != nil {
+			s3resp.Body.Close()
+		}
+		s3resp = incompleteBodyResponse(requestID, r.URL.Path)
+	}
+
+	if dechunker != nil && dechunker.BadDigest() {
+		logger.Warn("[%s] Trailing checksum %s does not match request body", requestID, r.Header.Get("X-Amz-Trailer"))
+		if s3resp.Body != nil {
+			s3resp.Body.Close()
+		}
+		s3resp = badDigestResponse(requestID, r.URL.Path)
+	}
+
+	// Идентификатор запроса назначает шлюз, а не бэкенд
+	if s3resp.Headers != nil {
+		s3resp.Headers.Del(RequestIDHeader)
+	}
 
 	// Отправляем ответ клиенту
-	if err := gw.responseWriter.WriteResponse(w, s3resp); err != nil {
-		logger.Error("Failed to write response: %v", err)
+	if err := gw.responseWriter.WriteResponseFor(w, s3resp, r.URL.Path); err != nil {
+		logger.Error("[%s] Failed to write response: %v", requestID, err)
 	}
 
 	// Логируем ответ
-	logger.Info("Response sent: %d, %.3f ms", s3resp.StatusCode, float64(time.Since(start).Microseconds())/1000.0)
+	logger.Info("[%s] Response sent: %d, %.3f ms", requestID, s3resp.StatusCode, float64(time.Since(start).Microseconds())/1000.0)
 
 	// Updateing metric
-	latency = time.Since(start).Seconds()
-	gw.metrics.RequestsTotal.WithLabelValues(r.Method, strconv.Itoa(s3resp.StatusCode)).Inc()
-	gw.metrics.RequestLatency.WithLabelValues(r.Method).Observe(latency)
+	gw.observeRequest(span, r.Method, s3req.Operation.String(), s3resp.StatusCode, time.Since(start))
+
+	if gw.recent != nil {
+		gw.recent.add(RecentRequest{
+			Time:      start,
+			RequestID: requestID,
+			Method:    r.Method,
+			Operation: s3req.Operation.String(),
+			Bucket:    s3req.Bucket,
+			Key:       s3req.Key,
+			Status:    s3resp.StatusCode,
+			LatencyMs: float64(time.Since(start).Microseconds()) / 1000.0,
+			Backends:  trace.backends(),
+			Error:     responseErrorSummary(s3resp),
+		})
+	}
 }
 
-// Start запускает сервер
+// responseErrorSummary возвращает описание ошибки ответа для буфера последних запросов
+func responseErrorSummary(resp *S3Response) string {
+	if resp.Error != nil {
+		return resp.Error.Error()
+	}
+	return resp.errorSummary
+}
+
+// Start начинает прослушивание ListenAddress и обслуживает запросы до вызова Stop.
+// После штатной остановки (Stop) возвращает nil.
 func (gw *Gateway) Start() error {
+	listener, err := net.Listen("tcp", gw.config.ListenAddress)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", gw.config.ListenAddress, err)
+	}
+	return gw.Serve(listener)
+}
 
+// Serve обслуживает запросы на переданном listener'е до вызова Stop.
+// После штатной остановки (Stop) возвращает nil.
+func (gw *Gateway) Serve(listener net.Listener) error {
 	// HTTP server
-	gw.server = &http.Server{
+	server := &http.Server{
 		Addr:         gw.config.ListenAddress,
 		Handler:      gw,
 		ReadTimeout:  gw.config.ReadTimeout,
 		WriteTimeout: gw.config.WriteTimeout,
 	}
+	gw.mu.Lock()
+	gw.server = server
+	gw.mu.Unlock()
 
-	logger.Info("Starting API Gateway on %s", gw.config.ListenAddress)
+	logger.Info("Starting API Gateway on %s", listener.Addr())
 
+	var err error
 	// Проверяем, нужно ли использовать TLS
 	if gw.config.TLSCertFile != "" && gw.config.TLSKeyFile != "" {
 		logger.Info("Starting HTTPS server with TLS")
-		return gw.server.ListenAndServeTLS(gw.config.TLSCertFile, gw.config.TLSKeyFile)
+		err = server.ServeTLS(listener, gw.config.TLSCertFile, gw.config.TLSKeyFile)
+	} else {
+		logger.Info("Starting HTTP server")
+		err = server.Serve(listener)
 	}
 
-	logger.Info("Starting HTTP server")
-	return gw.server.ListenAndServe()
+	// Serve возвращает ErrServerClosed сразу после начала Shutdown, не дожидаясь
+	// завершения активных запросов: их дожидается Stop
+	if errors.Is(err, http.ErrServerClosed) {
+		return nil
+	}
+	return err
 }
 
-// Stop останавливает сервер
+// Stop плавно останавливает шлюз: новые соединения больше не принимаются, простаивающие
+// соединения закрываются, а активные запросы (например, загрузка частей multipart или
+// отдача большого объекта) дорабатывают до конца, но не дольше дедлайна ctx.
+// Если к дедлайну запросы еще выполняются, их соединения принудительно закрываются
+// и возвращается ошибка, оборачивающая ErrShutdownTimeout.
 func (gw *Gateway) Stop(ctx context.Context) error {
-	if gw.server == nil {
+	gw.mu.Lock()
+	server := gw.server
+	gw.mu.Unlock()
+	if server == nil {
 		return nil
 	}
 
-	logger.Info("Stopping API Gateway...")
-	return gw.server.Shutdown(ctx)
+	logger.Info("Stopping API Gateway, waiting for %d in-flight request(s)...", gw.inFlight.Load())
+	err := server.Shutdown(ctx)
+	if err == nil {
+		logger.Info("API Gateway stopped, all requests completed")
+		return nil
+	}
+
+	inFlight := gw.inFlight.Load()
+	server.Close()
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, context.Canceled) {
+		return fmt.Errorf("%w: %d request(s) still running: %v", ErrShutdownTimeout, inFlight, err)
+	}
+	return err
 }
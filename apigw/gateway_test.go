@@ -0,0 +1,230 @@
+package apigw
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// blockingHandler отвечает только после закрытия release
+type blockingHandler struct {
+	started chan struct{}
+	release chan struct{}
+}
+
+func newBlockingHandler() *blockingHandler {
+	return &blockingHandler{started: make(chan struct{}), release: make(chan struct{})}
+}
+
+func (h *blockingHandler) Handle(req *S3Request) *S3Response {
+	close(h.started)
+	<-h.release
+	return &S3Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader("done"))}
+}
+
+// startTestGateway запускает шлюз на случайном порту и возвращает его адрес
+func startTestGateway(t *testing.T, handler RequestHandler) (*Gateway, string) {
+	t.Helper()
+	return startTestGatewayWithConfig(t, DefaultConfig(), handler)
+}
+
+// startTestGatewayWithConfig запускает шлюз с заданной конфигурацией на случайном порту
+func startTestGatewayWithConfig(t *testing.T, config Config, handler RequestHandler) (*Gateway, string) {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	gw := New(config, handler)
+	go gw.Serve(listener)
+	return gw, "http://" + listener.Addr().String()
+}
+
+// requestResult - результат клиентского запроса, выполненного в горутине
+type requestResult struct {
+	status int
+	body   string
+	err    error
+}
+
+func getAsync(url string) <-chan requestResult {
+	results := make(chan requestResult, 1)
+	go func() {
+		resp, err := http.Get(url)
+		if err != nil {
+			results <- requestResult{err: err}
+			return
+		}
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		results <- requestResult{status: resp.StatusCode, body: string(body), err: err}
+	}()
+	return results
+}
+
+func TestGateway_StopWaitsForInFlightRequests(t *testing.T) {
+	handler := newBlockingHandler()
+	gw, addr := startTestGateway(t, handler)
+
+	results := getAsync(addr + "/my-bucket/slow-object")
+	<-handler.started
+
+	stopped := make(chan error, 1)
+	go func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+		stopped <- gw.Stop(ctx)
+	}()
+
+	// Пока запрос выполняется, Stop не завершается
+	select {
+	case err := <-stopped:
+		t.Fatalf("Stop returned before in-flight request completed: %v", err)
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	// Новые соединения уже не принимаются
+	if _, err := http.Get(addr + "/my-bucket/other"); err == nil {
+		t.Error("Expected new connections to be refused during shutdown")
+	}
+
+	close(handler.release)
+
+	res := <-results
+	if res.err != nil {
+		t.Fatalf("In-flight request failed during shutdown: %v", res.err)
+	}
+	if res.status != http.StatusOK || res.body != "done" {
+		t.Errorf("Expected 200 \"done\", got %d %q", res.status, res.body)
+	}
+	if err := <-stopped; err != nil {
+		t.Errorf("Expected clean shutdown, got %v", err)
+	}
+}
+
+func TestGateway_StopDeadlineExceeded(t *testing.T) {
+	handler := newBlockingHandler()
+	defer close(handler.release)
+	gw, addr := startTestGateway(t, handler)
+
+	results := getAsync(addr + "/my-bucket/slow-object")
+	<-handler.started
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	err := gw.Stop(ctx)
+	if !errors.Is(err, ErrShutdownTimeout) {
+		t.Fatalf("Expected ErrShutdownTimeout, got %v", err)
+	}
+	if !strings.Contains(err.Error(), "1 request(s) still running") {
+		t.Errorf("Expected in-flight count in error, got %v", err)
+	}
+
+	// Соединение незавершенного запроса закрыто принудительно
+	if res := <-results; res.err == nil {
+		t.Error("Expected the cut request to fail on the client")
+	}
+}
+
+// rejectingHandler отвечает 403, не читая тело запроса (как Engine при ошибке аутентификации)
+type rejectingHandler struct{}
+
+func (rejectingHandler) Handle(req *S3Request) *S3Response {
+	return NewErrorResponse(req.RequestID, ResourcePath(req.Bucket, req.Key), http.StatusForbidden,
+		"AccessDenied", "Access Denied")
+}
+
+// sendExpectContinue отправляет заголовки PUT с Expect: 100-continue без тела
+// и возвращает первый ответ сервера
+func sendExpectContinue(t *testing.T, conn net.Conn, reader *bufio.Reader, size int) *http.Response {
+	t.Helper()
+	fmt.Fprintf(conn, "PUT /my-bucket/large.bin HTTP/1.1\r\nHost: s3proxy\r\nContent-Length: %d\r\nExpect: 100-continue\r\n\r\n", size)
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	resp, err := http.ReadResponse(reader, nil)
+	if err != nil {
+		t.Fatalf("Failed to read response: %v", err)
+	}
+	return resp
+}
+
+func TestGateway_ExpectContinue(t *testing.T) {
+	const size = 1 << 20
+
+	dial := func(t *testing.T, addr string) (net.Conn, *bufio.Reader) {
+		conn, err := net.Dial("tcp", strings.TrimPrefix(addr, "http://"))
+		if err != nil {
+			t.Fatalf("Failed to dial: %v", err)
+		}
+		t.Cleanup(func() { conn.Close() })
+		return conn, bufio.NewReader(conn)
+	}
+
+	t.Run("accepted", func(t *testing.T) {
+		handler := &bodyReadingHandler{}
+		gw, addr := startTestGateway(t, handler)
+		defer gw.Stop(context.Background())
+		conn, reader := dial(t, addr)
+
+		// Тело запрашивается у клиента, только когда обработчик начинает его читать
+		resp := sendExpectContinue(t, conn, reader, size)
+		if resp.StatusCode != http.StatusContinue {
+			t.Fatalf("Expected 100 Continue, got %d", resp.StatusCode)
+		}
+		if _, err := conn.Write(bytes.Repeat([]byte("a"), size)); err != nil {
+			t.Fatalf("Failed to send body: %v", err)
+		}
+		resp, err := http.ReadResponse(reader, nil)
+		if err != nil {
+			t.Fatalf("Failed to read final response: %v", err)
+		}
+		resp.Body.Close()
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("Expected 200, got %d", resp.StatusCode)
+		}
+		if handler.read != size {
+			t.Errorf("Expected handler to read %d bytes, got %d", size, handler.read)
+		}
+	})
+
+	t.Run("rejected by handler", func(t *testing.T) {
+		gw, addr := startTestGateway(t, rejectingHandler{})
+		defer gw.Stop(context.Background())
+		conn, reader := dial(t, addr)
+
+		// Отказ приходит сразу, без 100 Continue: клиент не передает тело
+		resp := sendExpectContinue(t, conn, reader, size)
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusForbidden {
+			t.Fatalf("Expected 403 without 100 Continue, got %d", resp.StatusCode)
+		}
+		if !resp.Close {
+			t.Error("Expected connection to be closed after rejecting an unsent body")
+		}
+	})
+
+	t.Run("rejected by size limit", func(t *testing.T) {
+		config := DefaultConfig()
+		config.MaxObjectSize = size / 2
+		handler := &bodyReadingHandler{}
+		gw, addr := startTestGatewayWithConfig(t, config, handler)
+		defer gw.Stop(context.Background())
+		conn, reader := dial(t, addr)
+
+		resp := sendExpectContinue(t, conn, reader, size)
+		defer resp.Body.Close()
+		if resp.StatusCode != http.StatusBadRequest {
+			t.Fatalf("Expected 400 EntityTooLarge without 100 Continue, got %d", resp.StatusCode)
+		}
+		if handler.called {
+			t.Error("Oversized request must not be routed to the handler")
+		}
+	})
+}
@@ -0,0 +1,99 @@
+package apigw
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+)
+
+// ErrEntityTooLarge возвращается при чтении тела запроса, превысившего MaxObjectSize
+var ErrEntityTooLarge = errors.New("request body exceeds maximum allowed size")
+
+// ErrBodyLengthMismatch возвращается при чтении декодированного тела aws-chunked, которое
+// длиннее заявленного клиентом X-Amz-Decoded-Content-Length
+var ErrBodyLengthMismatch = errors.New("request body is longer than declared content length")
+
+const (
+	// BodyLengthVerify - тело длиннее заявленного размера отклоняется с ошибкой IncompleteBody
+	BodyLengthVerify = "verify"
+	// BodyLengthIgnore - размер тела не сверяется с заявленным
+	BodyLengthIgnore = "ignore"
+)
+
+// limitedBody ограничивает объем данных, читаемых из тела запроса.
+// В отличие от io.LimitReader, превышение лимита возвращает ошибку, а не EOF,
+// чтобы обработчик не принял обрезанное тело за полный объект.
+type limitedBody struct {
+	body      io.ReadCloser
+	remaining int64
+	err       error // Ошибка чтения при превышении лимита
+	exceeded  atomic.Bool
+}
+
+// newLimitedBody создает ограничивающую обертку над телом запроса
+func newLimitedBody(body io.ReadCloser, limit int64) *limitedBody {
+	return &limitedBody{body: body, remaining: limit, err: ErrEntityTooLarge}
+}
+
+// newDeclaredLengthBody создает обертку, которая не дает прочитать больше заявленного
+// размера тела: иначе обработчик молча обрезал бы объект до заявленной длины
+func newDeclaredLengthBody(body io.ReadCloser, declared int64) *limitedBody {
+	return &limitedBody{body: body, remaining: declared, err: ErrBodyLengthMismatch}
+}
+
+// Read читает данные, пока не превышен лимит
+func (l *limitedBody) Read(p []byte) (int, error) {
+	if l.exceeded.Load() {
+		return 0, l.err
+	}
+
+	// Читаем на байт больше остатка, чтобы обнаружить превышение
+	if int64(len(p)) > l.remaining+1 {
+		p = p[:l.remaining+1]
+	}
+
+	n, err := l.body.Read(p)
+	if int64(n) > l.remaining {
+		l.exceeded.Store(true)
+		n = int(l.remaining)
+		l.remaining = 0
+		return n, l.err
+	}
+	l.remaining -= int64(n)
+	return n, err
+}
+
+// Close закрывает исходное тело запроса
+func (l *limitedBody) Close() error {
+	return l.body.Close()
+}
+
+// Exceeded сообщает, было ли превышено ограничение
+func (l *limitedBody) Exceeded() bool {
+	return l.exceeded.Load()
+}
+
+// declaredBodySize возвращает заявленный клиентом размер объекта или -1, если он неизвестен.
+// Для aws-chunked загрузок размер объекта передается в X-Amz-Decoded-Content-Length.
+func declaredBodySize(r *http.Request) int64 {
+	if decoded := r.Header.Get("X-Amz-Decoded-Content-Length"); decoded != "" {
+		if size, err := strconv.ParseInt(decoded, 10, 64); err == nil && size >= 0 {
+			return size
+		}
+	}
+	return r.ContentLength
+}
+
+// entityTooLargeResponse формирует S3 ответ EntityTooLarge
+func entityTooLargeResponse(requestID, resource string, limit int64) *S3Response {
+	return NewErrorResponse(requestID, resource, http.StatusBadRequest, "EntityTooLarge",
+		"Your proposed upload exceeds the maximum allowed size of "+strconv.FormatInt(limit, 10)+" bytes")
+}
+
+// incompleteBodyResponse формирует S3 ответ IncompleteBody для тела длиннее заявленного размера
+func incompleteBodyResponse(requestID, resource string) *S3Response {
+	return NewErrorResponse(requestID, resource, http.StatusBadRequest, "IncompleteBody",
+		"The request body is longer than the number of bytes specified by the Content-Length HTTP header")
+}
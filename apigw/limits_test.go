@@ -0,0 +1,201 @@
+package apigw
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// bodyReadingHandler вычитывает тело запроса, как это делает реплицирующий обработчик
+type bodyReadingHandler struct {
+	called  bool
+	read    int64
+	readErr error
+}
+
+func (h *bodyReadingHandler) Handle(req *S3Request) *S3Response {
+	h.called = true
+	h.read, h.readErr = io.Copy(io.Discard, req.Body)
+	if h.readErr != nil {
+		return &S3Response{StatusCode: http.StatusInternalServerError, Error: h.readErr}
+	}
+	return &S3Response{StatusCode: http.StatusOK}
+}
+
+func newLimitTestGateway(limit int64) (*Gateway, *bodyReadingHandler) {
+	config := DefaultConfig()
+	config.MaxObjectSize = limit
+	handler := &bodyReadingHandler{}
+	return New(config, handler), handler
+}
+
+// newChunkedRequest создает PUT запрос с телом неизвестной длины
+func newChunkedRequest(body string) *http.Request {
+	req := httptest.NewRequest(http.MethodPut, "/my-bucket/object.txt", nil)
+	req.Body = io.NopCloser(strings.NewReader(body))
+	req.ContentLength = -1
+	req.TransferEncoding = []string{"chunked"}
+	return req
+}
+
+func assertEntityTooLarge(t *testing.T, rec *httptest.ResponseRecorder) {
+	t.Helper()
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("Expected status 400, got %d", rec.Code)
+	}
+	if !strings.Contains(rec.Body.String(), "<Code>EntityTooLarge</Code>") {
+		t.Errorf("Expected EntityTooLarge error, got %s", rec.Body.String())
+	}
+}
+
+func TestGateway_MaxObjectSizeDeclaredLength(t *testing.T) {
+	gw, handler := newLimitTestGateway(10)
+
+	req := httptest.NewRequest(http.MethodPut, "/my-bucket/object.txt", strings.NewReader(strings.Repeat("a", 11)))
+	rec := httptest.NewRecorder()
+	gw.ServeHTTP(rec, req)
+
+	assertEntityTooLarge(t, rec)
+	if handler.called {
+		t.Error("Oversized request must not be routed to the handler")
+	}
+}
+
+func TestGateway_MaxObjectSizeDecodedLength(t *testing.T) {
+	gw, handler := newLimitTestGateway(10)
+
+	// aws-chunked: Content-Length включает служебные данные, размер объекта - в X-Amz-Decoded-Content-Length
+	req := httptest.NewRequest(http.MethodPut, "/my-bucket/object.txt", strings.NewReader(strings.Repeat("a", 5)))
+	req.Header.Set("X-Amz-Decoded-Content-Length", "20")
+	rec := httptest.NewRecorder()
+	gw.ServeHTTP(rec, req)
+
+	assertEntityTooLarge(t, rec)
+	if handler.called {
+		t.Error("Oversized request must not be routed to the handler")
+	}
+}
+
+func TestGateway_MaxObjectSizeChunked(t *testing.T) {
+	gw, handler := newLimitTestGateway(10)
+
+	rec := httptest.NewRecorder()
+	gw.ServeHTTP(rec, newChunkedRequest(strings.Repeat("a", 64)))
+
+	assertEntityTooLarge(t, rec)
+	if !handler.called {
+		t.Fatal("Expected streaming request to be routed to the handler")
+	}
+	if handler.read > 10 {
+		t.Errorf("Handler read %d bytes, expected at most 10", handler.read)
+	}
+}
+
+func TestGateway_MaxObjectSizeWithinLimit(t *testing.T) {
+	tests := []struct {
+		name string
+		req  *http.Request
+	}{
+		{name: "Declared length", req: httptest.NewRequest(http.MethodPut, "/my-bucket/object.txt", strings.NewReader(strings.Repeat("a", 10)))},
+		{name: "Chunked", req: newChunkedRequest(strings.Repeat("a", 10))},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gw, handler := newLimitTestGateway(10)
+
+			rec := httptest.NewRecorder()
+			gw.ServeHTTP(rec, tt.req)
+
+			if rec.Code != http.StatusOK {
+				t.Fatalf("Expected status 200, got %d: %s", rec.Code, rec.Body.String())
+			}
+			if handler.readErr != nil || handler.read != 10 {
+				t.Errorf("Expected handler to read 10 bytes, got %d (err: %v)", handler.read, handler.readErr)
+			}
+		})
+	}
+}
+
+func TestGateway_MaxObjectSizeDisabled(t *testing.T) {
+	gw, handler := newLimitTestGateway(0)
+
+	rec := httptest.NewRecorder()
+	gw.ServeHTTP(rec, newChunkedRequest(strings.Repeat("a", 1024)))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rec.Code)
+	}
+	if handler.read != 1024 {
+		t.Errorf("Expected handler to read 1024 bytes, got %d", handler.read)
+	}
+}
+
+// sendMismatchedChunkedRequest отправляет серверу с gw aws-chunked PUT, в котором
+// X-Amz-Decoded-Content-Length меньше размера данных в чанках. Запрос идет через
+// настоящий HTTP сервер: для обычного тела net/http сам ограничивает r.Body
+// значением Content-Length, и расхождение возможно только в aws-chunked.
+func sendMismatchedChunkedRequest(t *testing.T, gw *Gateway, data string, decoded int64) (int, string) {
+	t.Helper()
+
+	server := httptest.NewServer(gw)
+	defer server.Close()
+
+	body := strconv.FormatInt(int64(len(data)), 16) + "\r\n" + data + "\r\n0\r\n\r\n"
+	req, err := http.NewRequest(http.MethodPut, server.URL+"/my-bucket/object.txt", strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("Failed to create request: %v", err)
+	}
+	req.Header.Set("Content-Encoding", "aws-chunked")
+	req.Header.Set("X-Amz-Content-Sha256", "STREAMING-UNSIGNED-PAYLOAD-TRAILER")
+	req.Header.Set("X-Amz-Decoded-Content-Length", strconv.FormatInt(decoded, 10))
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatalf("Request failed: %v", err)
+	}
+	defer resp.Body.Close()
+	respBody, _ := io.ReadAll(resp.Body)
+	return resp.StatusCode, string(respBody)
+}
+
+func TestGateway_BodyLongerThanDeclaredLength(t *testing.T) {
+	for _, declared := range []int64{0, 5} {
+		gw, handler := newLimitTestGateway(0)
+
+		status, body := sendMismatchedChunkedRequest(t, gw, "hello world", declared)
+
+		if status != http.StatusBadRequest {
+			t.Fatalf("Declared %d: expected status 400, got %d", declared, status)
+		}
+		if !strings.Contains(body, "<Code>IncompleteBody</Code>") {
+			t.Errorf("Declared %d: expected IncompleteBody error, got %s", declared, body)
+		}
+		if !errors.Is(handler.readErr, ErrBodyLengthMismatch) {
+			t.Errorf("Declared %d: expected handler read to fail with ErrBodyLengthMismatch, got %v", declared, handler.readErr)
+		}
+		if handler.read > declared {
+			t.Errorf("Declared %d: handler read %d bytes beyond declared length", declared, handler.read)
+		}
+	}
+}
+
+func TestGateway_BodyLengthCheckIgnore(t *testing.T) {
+	config := DefaultConfig()
+	config.BodyLengthCheck = BodyLengthIgnore
+	handler := &bodyReadingHandler{}
+	gw := New(config, handler)
+
+	status, _ := sendMismatchedChunkedRequest(t, gw, "hello world", 0)
+
+	if status != http.StatusOK {
+		t.Fatalf("Expected status 200 with body_length_check: ignore, got %d", status)
+	}
+	if handler.read != int64(len("hello world")) {
+		t.Errorf("Expected handler to read the whole body, got %d bytes", handler.read)
+	}
+}
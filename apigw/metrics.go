@@ -1,6 +1,10 @@
 package apigw
 
 import (
+	"strconv"
+	"sync"
+	"time"
+
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
 )
@@ -9,19 +13,41 @@ type Metrics struct {
 	// Общие метрики запросов
 	RequestsTotal  *prometheus.CounterVec   // Общее количество обработанных S3 запросов
 	RequestLatency *prometheus.HistogramVec // Латентность S3 запросов
+
+	// Метрики запросов по S3 операциям
+	OperationRequestsTotal *prometheus.CounterVec   // Запросы клиентов по операции и коду ответа
+	OperationLatency       *prometheus.HistogramVec // Время обработки запроса клиента по операции и коду ответа
 }
 
+// unknownOperation - метка operation для запросов, отклоненных до определения операции
+const unknownOperation = "UNKNOWN"
+
+var (
+	metricsOnce     sync.Once
+	metricsInstance *Metrics
+)
+
+// NewMetrics возвращает метрики модуля, общие для всех экземпляров (см. backend.NewMetrics)
 func NewMetrics() *Metrics {
+	metricsOnce.Do(func() {
+		metricsInstance = newMetrics(prometheus.DefaultRegisterer)
+	})
+	return metricsInstance
+}
+
+// newMetrics создает метрики и регистрирует их в reg
+func newMetrics(reg prometheus.Registerer) *Metrics {
+	factory := promauto.With(reg)
 	return &Metrics{
 		// Общие метрики запросов
-		RequestsTotal: promauto.NewCounterVec(
+		RequestsTotal: factory.NewCounterVec(
 			prometheus.CounterOpts{
 				Name: "s3proxy_apigw_requests_total",
 				Help: "Total number of processed S3 requests",
 			},
 			[]string{"method", "code"},
 		),
-		RequestLatency: promauto.NewHistogramVec(
+		RequestLatency: factory.NewHistogramVec(
 			prometheus.HistogramOpts{
 				Name:    "s3proxy_apigw_request_latency_seconds",
 				Help:    "Latency of S3 requests in seconds",
@@ -29,9 +55,36 @@ func NewMetrics() *Metrics {
 			},
 			[]string{"method"},
 		),
+
+		// Метрики запросов по S3 операциям
+		OperationRequestsTotal: factory.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "s3proxy_requests_total",
+				Help: "Total number of processed S3 requests",
+			},
+			[]string{"operation", "code"},
+		),
+		OperationLatency: factory.NewHistogramVec(
+			prometheus.HistogramOpts{
+				Name:    "s3proxy_request_latency_seconds",
+				Help:    "End-to-end latency of S3 requests in seconds",
+				Buckets: prometheus.DefBuckets,
+			},
+			[]string{"operation", "code"},
+		),
 	}
 }
 
+// observeRequest учитывает обработанный запрос клиента: method - HTTP метод,
+// operation - S3 операция (unknownOperation, если она еще не определена)
+func (m *Metrics) observeRequest(method, operation string, status int, latency time.Duration) {
+	code := strconv.Itoa(status)
+	m.RequestsTotal.WithLabelValues(method, code).Inc()
+	m.RequestLatency.WithLabelValues(method).Observe(latency.Seconds())
+	m.OperationRequestsTotal.WithLabelValues(operation, code).Inc()
+	m.OperationLatency.WithLabelValues(operation, code).Observe(latency.Seconds())
+}
+
 // // ObserveBackendRequestLatency записывает время выполнения запроса к бэкенду
 // func (m *Metrics) ObserveBackendRequestLatency(backendID, operation string, latency float64) {
 // 	m.BackendLatency.WithLabelValues(backendID, operation).Observe(latency)
@@ -0,0 +1,51 @@
+package apigw
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+// statusHandler отвечает заданным статусом: 403 имитирует отказ аутентификации
+type statusHandler struct {
+	status int
+}
+
+func (h *statusHandler) Handle(req *S3Request) *S3Response {
+	return &S3Response{StatusCode: h.status}
+}
+
+func TestGateway_OperationMetrics(t *testing.T) {
+	handler := &statusHandler{status: http.StatusOK}
+	gw := New(DefaultConfig(), handler)
+	gw.metrics = newMetrics(prometheus.NewRegistry())
+
+	gw.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/my-bucket/object.txt", nil))
+	gw.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/my-bucket/object.txt", nil))
+
+	handler.status = http.StatusForbidden
+	gw.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPut, "/my-bucket/object.txt", nil))
+
+	// PATCH отклоняется парсером до определения операции
+	gw.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodPatch, "/my-bucket/object.txt", nil))
+
+	requests := gw.metrics.OperationRequestsTotal
+	if got := testutil.ToFloat64(requests.WithLabelValues("GET_OBJECT", "200")); got != 2 {
+		t.Errorf("Expected 2 GET_OBJECT/200 requests, got %v", got)
+	}
+	if got := testutil.ToFloat64(requests.WithLabelValues("PUT_OBJECT", "403")); got != 1 {
+		t.Errorf("Expected 1 PUT_OBJECT/403 request, got %v", got)
+	}
+	if got := testutil.ToFloat64(requests.WithLabelValues(unknownOperation, "400")); got != 1 {
+		t.Errorf("Expected 1 %s/400 request, got %v", unknownOperation, got)
+	}
+	if got := testutil.CollectAndCount(gw.metrics.OperationLatency); got != 3 {
+		t.Errorf("Expected latency series for 3 operation/code pairs, got %d", got)
+	}
+	if got := testutil.ToFloat64(gw.metrics.RequestsTotal.WithLabelValues(http.MethodGet, "200")); got != 2 {
+		t.Errorf("Expected 2 GET/200 requests by method, got %v", got)
+	}
+}
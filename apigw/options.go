@@ -0,0 +1,34 @@
+package apigw
+
+import "net/http"
+
+// Режимы ответа на OPTIONS без CORS заголовков (не preflight)
+const (
+	// OptionsAllow - 200 с заголовком Allow (по умолчанию)
+	OptionsAllow = "allow"
+	// OptionsReject - 405 Method Not Allowed с заголовком Allow
+	OptionsReject = "reject"
+)
+
+// allowedMethods - методы, которые поддерживает шлюз (значение заголовка Allow)
+const allowedMethods = "GET, HEAD, PUT, POST, DELETE, OPTIONS"
+
+// isBareOptions сообщает, что запрос - OPTIONS без Origin и Access-Control-Request-Method,
+// то есть не CORS preflight
+func isBareOptions(r *http.Request) bool {
+	return r.Method == http.MethodOptions &&
+		r.Header.Get("Origin") == "" &&
+		r.Header.Get("Access-Control-Request-Method") == ""
+}
+
+// handleBareOptions отвечает на OPTIONS, не являющийся CORS preflight, списком
+// поддерживаемых методов. Запрос не передается обработчику.
+func (gw *Gateway) handleBareOptions(w http.ResponseWriter) int {
+	w.Header().Set("Allow", allowedMethods)
+	status := http.StatusOK
+	if gw.config.OptionsResponse == OptionsReject {
+		status = http.StatusMethodNotAllowed
+	}
+	w.WriteHeader(status)
+	return status
+}
@@ -0,0 +1,43 @@
+package apigw
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestGateway_BareOptions(t *testing.T) {
+	handler := &recordingHandler{}
+	gw := New(DefaultConfig(), handler)
+
+	req := httptest.NewRequest(http.MethodOptions, "/my-bucket/object.txt", nil)
+	rec := httptest.NewRecorder()
+	gw.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", rec.Code)
+	}
+	if got := rec.Header().Get("Allow"); got != allowedMethods {
+		t.Errorf("Expected Allow %q, got %q", allowedMethods, got)
+	}
+	if handler.called {
+		t.Error("OPTIONS must not be routed to the handler")
+	}
+}
+
+func TestGateway_BareOptionsRejected(t *testing.T) {
+	config := DefaultConfig()
+	config.OptionsResponse = OptionsReject
+	gw := New(config, &recordingHandler{})
+
+	req := httptest.NewRequest(http.MethodOptions, "/", nil)
+	rec := httptest.NewRecorder()
+	gw.ServeHTTP(rec, req)
+
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Errorf("Expected status 405, got %d", rec.Code)
+	}
+	if rec.Header().Get("Allow") == "" {
+		t.Error("Expected Allow header in 405 response")
+	}
+}
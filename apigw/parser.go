@@ -2,6 +2,7 @@ package apigw
 
 import (
 	"fmt"
+	"net"
 	"net/http"
 	"strconv"
 	"strings"
@@ -10,18 +11,24 @@ import (
 )
 
 // RequestParser отвечает за парсинг HTTP запросов в S3Request
-type RequestParser struct{}
+type RequestParser struct {
+	// baseDomain - домен эндпоинта для virtual-hosted адресации (например, "s3.example.com").
+	// Пустая строка отключает virtual-hosted адресацию.
+	baseDomain string
+}
 
-// NewRequestParser создает новый экземпляр парсера
-func NewRequestParser() *RequestParser {
-	return &RequestParser{}
+// NewRequestParser создает новый экземпляр парсера.
+// Если baseDomain не пустой, кроме path-style поддерживается virtual-hosted адресация
+// вида bucket.baseDomain.
+func NewRequestParser(baseDomain string) *RequestParser {
+	return &RequestParser{baseDomain: strings.ToLower(strings.TrimSuffix(baseDomain, "."))}
 }
 
 // Parse анализирует HTTP запрос и создает S3Request
 func (p *RequestParser) Parse(r *http.Request) (*S3Request, error) {
 	logger.Debug("Parsing HTTP request: %s %s", r.Method, r.URL.Path)
-	logger.Debug("Query parameters: %v", r.URL.Query())
-	logger.Debug("Request headers: %+v", r.Header)
+	logger.Debug("Query parameters: %v", logger.RedactQuery(r.URL.Query()))
+	logger.Debug("Request headers: %+v", logger.RedactHeaders(r.Header))
 
 	// Определяем схему из запроса
 	scheme := "http"
@@ -52,8 +59,12 @@ func (p *RequestParser) Parse(r *http.Request) (*S3Request, error) {
 		}
 	}
 
-	// Парсим путь для извлечения bucket и key
-	if err := p.parsePath(r.URL.Path, s3req); err != nil {
+	// Определяем bucket из Host (virtual-hosted style) или из пути (path-style)
+	if bucket, ok := p.bucketFromHost(r.Host); ok {
+		logger.Debug("Virtual-hosted style request, bucket from host: %s", bucket)
+		s3req.Bucket = bucket
+		s3req.Key = strings.TrimPrefix(r.URL.Path, "/")
+	} else if err := p.parsePath(r.URL.Path, s3req); err != nil {
 		logger.Debug("Failed to parse path: %v", err)
 		return nil, err
 	}
@@ -67,10 +78,31 @@ func (p *RequestParser) Parse(r *http.Request) (*S3Request, error) {
 	}
 
 	logger.Debug("Determined operation: %s", s3req.Operation.String())
-	logger.Debug("Created S3Request: %+v", s3req)
+	logger.Debug("Created S3Request: operation=%s, bucket=%s, key=%s, content-length=%d, headers=%+v",
+		s3req.Operation.String(), s3req.Bucket, s3req.Key, s3req.ContentLength, logger.RedactHeaders(s3req.Headers))
 	return s3req, nil
 }
 
+// bucketFromHost извлекает bucket из заголовка Host для virtual-hosted адресации.
+// Возвращает false, если virtual-hosted адресация отключена или Host - это сам эндпоинт.
+func (p *RequestParser) bucketFromHost(host string) (string, bool) {
+	if p.baseDomain == "" || host == "" {
+		return "", false
+	}
+
+	// Убираем порт, если он указан
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+
+	bucket, found := strings.CutSuffix(host, "."+p.baseDomain)
+	if !found || bucket == "" {
+		return "", false
+	}
+	return bucket, true
+}
+
 // parsePath извлекает bucket и key из пути URL
 func (p *RequestParser) parsePath(path string, s3req *S3Request) error {
 	// Убираем ведущий слеш
@@ -118,6 +150,13 @@ func (p *RequestParser) determineOperation(method string, s3req *S3Request) erro
 
 // determineGetOperation определяет GET операции
 func (p *RequestParser) determineGetOperation(s3req *S3Request, query map[string][]string) error {
+	// Сессия S3 Express One Zone (?session) - прокси ее не поддерживает, но распознает,
+	// чтобы не принять запрос за листинг бакета
+	if _, hasSession := query["session"]; hasSession && s3req.Bucket != "" {
+		s3req.Operation = CreateSession
+		return nil
+	}
+
 	// Проверяем специальные query параметры
 	if _, hasUploads := query["uploads"]; hasUploads {
 		s3req.Operation = ListMultipartUploads
@@ -130,6 +169,31 @@ func (p *RequestParser) determineGetOperation(s3req *S3Request, query map[string
 		return nil
 	}
 
+	// Версии объектов (?versions) и состояние версионирования бакета (?versioning).
+	// Без этой проверки запросы приняли бы за ListObjectsV2 и получили бы не тот XML.
+	if s3req.Key == "" {
+		if _, hasVersions := query["versions"]; hasVersions {
+			s3req.Operation = ListObjectVersions
+			return nil
+		}
+		if _, hasVersioning := query["versioning"]; hasVersioning {
+			s3req.Operation = GetBucketVersioning
+			return nil
+		}
+	}
+
+	// Список загруженных частей multipart upload (?uploadId)
+	if _, hasUploadId := query["uploadId"]; hasUploadId && s3req.Key != "" {
+		s3req.Operation = ListParts
+		return nil
+	}
+
+	// Теги объекта (?tagging)
+	if _, hasTagging := query["tagging"]; hasTagging && s3req.Key != "" {
+		s3req.Operation = GetObjectTagging
+		return nil
+	}
+
 	// Если нет key или key заканчивается на "/", это список объектов
 	if s3req.Key == "" || strings.HasSuffix(s3req.Key, "/") {
 		s3req.Operation = ListObjectsV2
@@ -153,12 +217,31 @@ func (p *RequestParser) determinePutOperation(s3req *S3Request, query map[string
 		}
 	}
 
+	// Установка тегов объекта (?tagging)
+	if _, hasTagging := query["tagging"]; hasTagging && s3req.Bucket != "" && s3req.Key != "" {
+		s3req.Operation = PutObjectTagging
+		return nil
+	}
+
 	// Обычная загрузка объекта
 	if s3req.Bucket != "" && s3req.Key != "" {
 		s3req.Operation = PutObject
 		return nil
 	}
 
+	// Включение версионирования (?versioning) прокси не поддерживает; запрос не должен
+	// превратиться в создание бакета. Engine ответит NotImplemented.
+	if _, hasVersioning := query["versioning"]; hasVersioning && s3req.Bucket != "" && s3req.Key == "" {
+		s3req.Operation = UnsupportedOperation
+		return nil
+	}
+
+	// Создание бакета (только bucket, без key)
+	if s3req.Bucket != "" {
+		s3req.Operation = CreateBucket
+		return nil
+	}
+
 	s3req.Operation = UnsupportedOperation
 	return fmt.Errorf("unsupported PUT operation")
 }
@@ -193,12 +276,24 @@ func (p *RequestParser) determineDeleteOperation(s3req *S3Request, query map[str
 		}
 	}
 
+	// Удаление тегов объекта (?tagging)
+	if _, hasTagging := query["tagging"]; hasTagging && s3req.Bucket != "" && s3req.Key != "" {
+		s3req.Operation = DeleteObjectTagging
+		return nil
+	}
+
 	// Удаление объекта
 	if s3req.Bucket != "" && s3req.Key != "" {
 		s3req.Operation = DeleteObject
 		return nil
 	}
 
+	// Удаление бакета (только bucket, без key)
+	if s3req.Bucket != "" {
+		s3req.Operation = DeleteBucket
+		return nil
+	}
+
 	s3req.Operation = UnsupportedOperation
 	return fmt.Errorf("unsupported DELETE operation")
 }
@@ -217,6 +312,12 @@ func (p *RequestParser) determineHeadOperation(s3req *S3Request, query map[strin
 		return nil
 	}
 
+	// HEAD на корень сервиса - используется SDK для определения региона
+	if s3req.Bucket == "" {
+		s3req.Operation = HeadService
+		return nil
+	}
+
 	s3req.Operation = UnsupportedOperation
 	return fmt.Errorf("unsupported HEAD operation")
 }
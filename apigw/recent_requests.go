@@ -0,0 +1,120 @@
+package apigw
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+
+	"s3proxy/logger"
+)
+
+// RecentRequest - запись о обработанном запросе в буфере последних запросов
+type RecentRequest struct {
+	Time      time.Time `json:"time"`
+	RequestID string    `json:"request_id"`
+	Method    string    `json:"method"`
+	Operation string    `json:"operation"`
+	Bucket    string    `json:"bucket,omitempty"`
+	Key       string    `json:"key,omitempty"`
+	Status    int       `json:"status"`
+	LatencyMs float64   `json:"latency_ms"`
+	Backends  []string  `json:"backends,omitempty"` // Бэкенды, к которым обращался запрос
+	Error     string    `json:"error,omitempty"`
+}
+
+// RecentRequests - кольцевой буфер последних обработанных запросов для отладки без
+// полноценной трассировки. Хранит не больше size записей, самые старые вытесняются.
+type RecentRequests struct {
+	mu      sync.Mutex
+	entries []RecentRequest
+	next    int // Позиция следующей записи
+	full    bool
+}
+
+// NewRecentRequests создает буфер на size последних запросов. size <= 0 - буфер выключен (nil).
+func NewRecentRequests(size int) *RecentRequests {
+	if size <= 0 {
+		return nil
+	}
+	return &RecentRequests{entries: make([]RecentRequest, size)}
+}
+
+// add сохраняет запись, вытесняя самую старую при заполненном буфере
+func (b *RecentRequests) add(entry RecentRequest) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.entries[b.next] = entry
+	b.next = (b.next + 1) % len(b.entries)
+	if b.next == 0 {
+		b.full = true
+	}
+}
+
+// Snapshot возвращает копию записей буфера, начиная с самой новой
+func (b *RecentRequests) Snapshot() []RecentRequest {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	count := b.next
+	if b.full {
+		count = len(b.entries)
+	}
+	result := make([]RecentRequest, 0, count)
+	for i := 1; i <= count; i++ {
+		entry := b.entries[(b.next-i+len(b.entries))%len(b.entries)]
+		entry.Backends = slices.Clone(entry.Backends)
+		result = append(result, entry)
+	}
+	return result
+}
+
+// ServeHTTP отдает последние запросы в JSON (самые новые первыми)
+func (b *RecentRequests) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(b.Snapshot()); err != nil {
+		logger.Warn("RecentRequests: failed to write response: %v", err)
+	}
+}
+
+// backendTraceKey - ключ списка затронутых бэкендов в context.Context
+type backendTraceKey struct{}
+
+// backendTrace - бэкенды, к которым обращался запрос. Обращения продолжаются
+// в фоне после ответа клиенту (ack=one), поэтому доступ защищен мьютексом.
+type backendTrace struct {
+	mu  sync.Mutex
+	ids []string
+}
+
+// contextWithBackendTrace возвращает контекст, в котором RecordBackend запоминает бэкенды
+func contextWithBackendTrace(ctx context.Context) (context.Context, *backendTrace) {
+	trace := &backendTrace{}
+	return context.WithValue(ctx, backendTraceKey{}, trace), trace
+}
+
+// RecordBackend отмечает обращение запроса к бэкенду backendID. Вызывается клиентами
+// бэкендов; если буфер последних запросов выключен, ничего не делает.
+func RecordBackend(ctx context.Context, backendID string) {
+	if ctx == nil {
+		return
+	}
+	trace, ok := ctx.Value(backendTraceKey{}).(*backendTrace)
+	if !ok {
+		return
+	}
+	trace.mu.Lock()
+	defer trace.mu.Unlock()
+	if !slices.Contains(trace.ids, backendID) {
+		trace.ids = append(trace.ids, backendID)
+	}
+}
+
+// backends возвращает копию списка затронутых бэкендов
+func (t *backendTrace) backends() []string {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return slices.Clone(t.ids)
+}
@@ -0,0 +1,80 @@
+package apigw
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// backendTouchingHandler отмечает обращение к бэкендам, как это делают клиенты S3,
+// и отвечает ошибкой для ключа missing.txt
+type backendTouchingHandler struct{}
+
+func (backendTouchingHandler) Handle(req *S3Request) *S3Response {
+	RecordBackend(req.Context, "backend-1")
+	RecordBackend(req.Context, "backend-2")
+	RecordBackend(req.Context, "backend-1")
+	if req.Key == "missing.txt" {
+		return NewErrorResponse(req.RequestID, ResourcePath(req.Bucket, req.Key), http.StatusNotFound,
+			"NoSuchKey", "The specified key does not exist.")
+	}
+	return &S3Response{StatusCode: http.StatusOK}
+}
+
+func TestGateway_RecentRequests(t *testing.T) {
+	config := DefaultConfig()
+	config.RecentRequests = 3
+	gw := New(config, backendTouchingHandler{})
+
+	for _, key := range []string{"a.txt", "b.txt", "c.txt", "missing.txt"} {
+		gw.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/my-bucket/"+key, nil))
+	}
+
+	// Самая старая запись вытеснена, самые новые - первыми
+	entries := gw.RecentRequests().Snapshot()
+	if len(entries) != 3 {
+		t.Fatalf("Expected 3 entries in buffer, got %d", len(entries))
+	}
+	for i, key := range []string{"missing.txt", "c.txt", "b.txt"} {
+		if entries[i].Key != key {
+			t.Errorf("Entry %d: expected key %s, got %s", i, key, entries[i].Key)
+		}
+	}
+
+	latest := entries[0]
+	if latest.Bucket != "my-bucket" || latest.Method != http.MethodGet || latest.Operation != GetObject.String() {
+		t.Errorf("Unexpected request fields: %+v", latest)
+	}
+	if latest.Status != http.StatusNotFound || latest.Error != "NoSuchKey: The specified key does not exist." {
+		t.Errorf("Expected 404 NoSuchKey entry, got status %d error %q", latest.Status, latest.Error)
+	}
+	if len(latest.Backends) != 2 || latest.Backends[0] != "backend-1" || latest.Backends[1] != "backend-2" {
+		t.Errorf("Expected backends [backend-1 backend-2], got %v", latest.Backends)
+	}
+	if latest.RequestID == "" || latest.Time.IsZero() {
+		t.Errorf("Expected request ID and time to be recorded, got %+v", latest)
+	}
+	if entries[1].Error != "" {
+		t.Errorf("Expected no error for successful request, got %q", entries[1].Error)
+	}
+
+	// Эндпоинт отдает те же записи в JSON
+	rec := httptest.NewRecorder()
+	gw.RecentRequests().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/requests", nil))
+	var served []RecentRequest
+	if err := json.Unmarshal(rec.Body.Bytes(), &served); err != nil {
+		t.Fatalf("Failed to decode /requests response: %v", err)
+	}
+	if len(served) != 3 || served[0].Key != "missing.txt" {
+		t.Errorf("Unexpected /requests response: %s", rec.Body.String())
+	}
+}
+
+func TestGateway_RecentRequestsDisabled(t *testing.T) {
+	gw := New(DefaultConfig(), backendTouchingHandler{})
+	gw.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/my-bucket/a.txt", nil))
+	if gw.RecentRequests() != nil {
+		t.Error("Expected no recent requests buffer by default")
+	}
+}
@@ -0,0 +1,45 @@
+package apigw
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+// RequestIDHeader - заголовок ответа с идентификатором запроса
+const RequestIDHeader = "X-Amz-Request-Id"
+
+// requestIDKey - ключ идентификатора запроса в context.Context
+type requestIDKey struct{}
+
+// requestCounter используется, если системный генератор случайных чисел недоступен
+var requestCounter atomic.Uint64
+
+// newRequestID генерирует уникальный идентификатор запроса в формате, похожем на S3
+// (16 шестнадцатеричных символов в верхнем регистре)
+func newRequestID() string {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		id := uint64(time.Now().UnixNano()) ^ requestCounter.Add(1)
+		return strings.ToUpper(strconv.FormatUint(id, 16))
+	}
+	return strings.ToUpper(hex.EncodeToString(buf))
+}
+
+// ContextWithRequestID возвращает контекст, содержащий идентификатор запроса
+func ContextWithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, requestIDKey{}, requestID)
+}
+
+// RequestIDFromContext возвращает идентификатор запроса из контекста или пустую строку
+func RequestIDFromContext(ctx context.Context) string {
+	if ctx == nil {
+		return ""
+	}
+	requestID, _ := ctx.Value(requestIDKey{}).(string)
+	return requestID
+}
@@ -0,0 +1,71 @@
+package apigw
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// requestIDHandler запоминает идентификатор запроса, полученный обработчиком
+type requestIDHandler struct {
+	requestID        string
+	contextRequestID string
+}
+
+func (h *requestIDHandler) Handle(req *S3Request) *S3Response {
+	h.requestID = req.RequestID
+	h.contextRequestID = RequestIDFromContext(req.Context)
+
+	headers := make(http.Header)
+	headers.Set(RequestIDHeader, "BACKEND-REQUEST-ID")
+	return &S3Response{StatusCode: http.StatusOK, Headers: headers}
+}
+
+func TestGateway_RequestIDPropagation(t *testing.T) {
+	handler := &requestIDHandler{}
+	gw := New(DefaultConfig(), handler)
+
+	rec := httptest.NewRecorder()
+	gw.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/my-bucket/object.txt", nil))
+
+	requestID := rec.Header().Get(RequestIDHeader)
+	if len(requestID) != 16 {
+		t.Fatalf("Expected 16-character request ID, got %q", requestID)
+	}
+	if handler.requestID != requestID {
+		t.Errorf("Expected handler to receive request ID %q, got %q", requestID, handler.requestID)
+	}
+	if handler.contextRequestID != requestID {
+		t.Errorf("Expected request context to carry request ID %q, got %q", requestID, handler.contextRequestID)
+	}
+	if values := rec.Header().Values(RequestIDHeader); len(values) != 1 {
+		t.Errorf("Expected a single %s header, got %v", RequestIDHeader, values)
+	}
+
+	rec = httptest.NewRecorder()
+	gw.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/my-bucket/object.txt", nil))
+	if rec.Header().Get(RequestIDHeader) == requestID {
+		t.Error("Expected unique request ID for each request")
+	}
+}
+
+func TestGateway_RequestIDInErrorResponse(t *testing.T) {
+	handler := &requestIDHandler{}
+	gw := New(DefaultConfig(), handler)
+
+	// PATCH не поддерживается парсером - ответ об ошибке формирует шлюз
+	rec := httptest.NewRecorder()
+	gw.ServeHTTP(rec, httptest.NewRequest(http.MethodPatch, "/my-bucket/object.txt", nil))
+
+	if rec.Code != http.StatusBadRequest {
+		t.Fatalf("Expected status 400, got %d", rec.Code)
+	}
+	requestID := rec.Header().Get(RequestIDHeader)
+	if requestID == "" {
+		t.Fatal("Expected request ID header on error response")
+	}
+	if !strings.Contains(rec.Body.String(), "<RequestId>"+requestID+"</RequestId>") {
+		t.Errorf("Expected error body to contain request ID %s, got %s", requestID, rec.Body.String())
+	}
+}
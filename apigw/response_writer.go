@@ -1,11 +1,14 @@
 package apigw
 
 import (
+	"bytes"
 	"encoding/xml"
 	"fmt"
 	"io"
 	"net/http"
+	"strconv"
 	"strings"
+	"time"
 
 	"s3proxy/logger"
 )
@@ -20,13 +23,19 @@ func NewResponseWriter() *ResponseWriter {
 
 // WriteResponse записывает S3Response в http.ResponseWriter
 func (rw *ResponseWriter) WriteResponse(w http.ResponseWriter, s3resp *S3Response) error {
+	return rw.WriteResponseFor(w, s3resp, "")
+}
+
+// WriteResponseFor записывает S3Response в http.ResponseWriter. resource - путь ресурса
+// запроса для элемента Resource, если ответ об ошибке формируется из S3Response.Error.
+func (rw *ResponseWriter) WriteResponseFor(w http.ResponseWriter, s3resp *S3Response, resource string) error {
 	logger.Debug("Writing response: status=%d, hasBody=%t, hasError=%t", 
 		s3resp.StatusCode, s3resp.Body != nil, s3resp.Error != nil)
 	
 	// Если есть ошибка, формируем XML ответ об ошибке
 	if s3resp.Error != nil {
 		logger.Debug("Writing error response: %v", s3resp.Error)
-		return rw.writeErrorResponse(w, s3resp.Error)
+		return rw.writeErrorResponse(w, s3resp.StatusCode, s3resp.Error, resource)
 	}
 
 	// Копируем заголовки
@@ -59,26 +68,28 @@ func (rw *ResponseWriter) WriteResponse(w http.ResponseWriter, s3resp *S3Respons
 }
 
 // writeErrorResponse записывает стандартный S3 XML ответ об ошибке
-func (rw *ResponseWriter) writeErrorResponse(w http.ResponseWriter, err error) error {
+// statusCode - статус из S3Response: если он задан и отличается от выведенного из текста
+// ошибки, он сохраняется, а код ошибки берется по статусу, чтобы они не расходились.
+func (rw *ResponseWriter) writeErrorResponse(w http.ResponseWriter, statusCode int, err error, resource string) error {
 	logger.Debug("Writing error response for error: %v", err)
 	
 	// Определяем код ошибки и HTTP статус на основе типа ошибки
 	errorCode, httpStatus := rw.mapErrorToS3Error(err)
+	if statusCode >= http.StatusBadRequest && statusCode != httpStatus {
+		if code, ok := statusErrorCodes[statusCode]; ok {
+			errorCode = code
+		}
+		httpStatus = statusCode
+	}
 	logger.Debug("Mapped error to S3 error: code=%s, status=%d", errorCode, httpStatus)
 
 	// Создаем XML структуру ошибки
-	s3Error := S3Error{
-		Code:    errorCode,
-		Message: err.Error(),
-	}
-
-	// Маршалим в XML
-	xmlData, xmlErr := xml.Marshal(s3Error)
-	if xmlErr != nil {
-		// Если не можем создать XML, отправляем простой текстовый ответ
-		http.Error(w, err.Error(), http.StatusInternalServerError)
-		return xmlErr
-	}
+	xmlData := marshalError(S3Error{
+		Code:      errorCode,
+		Message:   err.Error(),
+		Resource:  resource,
+		RequestID: w.Header().Get(RequestIDHeader),
+	})
 
 	// Устанавливаем заголовки
 	w.Header().Set("Content-Type", "application/xml")
@@ -92,6 +103,19 @@ func (rw *ResponseWriter) writeErrorResponse(w http.ResponseWriter, err error) e
 	return writeErr
 }
 
+// statusErrorCodes - S3 коды ошибок по умолчанию для HTTP статусов
+var statusErrorCodes = map[int]string{
+	http.StatusBadRequest:          "InvalidRequest",
+	http.StatusForbidden:           "AccessDenied",
+	http.StatusNotFound:            "NoSuchKey",
+	http.StatusMethodNotAllowed:    "MethodNotAllowed",
+	http.StatusConflict:            "OperationAborted",
+	http.StatusPreconditionFailed:  "PreconditionFailed",
+	http.StatusInternalServerError: "InternalError",
+	http.StatusNotImplemented:      "NotImplemented",
+	http.StatusServiceUnavailable:  "ServiceUnavailable",
+}
+
 // mapErrorToS3Error сопоставляет Go ошибки с S3 кодами ошибок
 func (rw *ResponseWriter) mapErrorToS3Error(err error) (string, int) {
 	errMsg := strings.ToLower(err.Error())
@@ -116,7 +140,63 @@ func (rw *ResponseWriter) mapErrorToS3Error(err error) (string, int) {
 
 // S3Error представляет структуру XML ошибки S3
 type S3Error struct {
-	XMLName xml.Name `xml:"Error"`
-	Code    string   `xml:"Code"`
-	Message string   `xml:"Message"`
+	XMLName   xml.Name `xml:"Error"`
+	Code      string   `xml:"Code"`
+	Message   string   `xml:"Message"`
+	Resource  string   `xml:"Resource,omitempty"`
+	RequestID string   `xml:"RequestId,omitempty"`
+}
+
+// ResourcePath возвращает путь ресурса запроса ("/bucket/key", "/bucket" или "/")
+// для элемента Resource ответа об ошибке
+func ResourcePath(bucket, key string) string {
+	switch {
+	case bucket == "":
+		return "/"
+	case key == "":
+		return "/" + bucket
+	default:
+		return "/" + bucket + "/" + key
+	}
+}
+
+// marshalError формирует XML тело ошибки S3 с заголовком <?xml ...?>
+func marshalError(s3Error S3Error) []byte {
+	xmlData, _ := xml.Marshal(s3Error)
+	return append([]byte(xml.Header), xmlData...)
+}
+
+// DefaultRetryAfter - значение Retry-After ответа ServiceUnavailable по умолчанию
+const DefaultRetryAfter = 5 * time.Second
+
+// NewServiceUnavailableResponse формирует ответ 503 ServiceUnavailable с XML телом ошибки
+// и заголовком Retry-After (в целых секундах, не меньше 1). retryAfter <= 0 - DefaultRetryAfter.
+// Без Retry-After клиенты повторяют запрос сразу и нагружают прокси, пока бэкенды недоступны.
+func NewServiceUnavailableResponse(requestID, resource, message string, retryAfter time.Duration) *S3Response {
+	if retryAfter <= 0 {
+		retryAfter = DefaultRetryAfter
+	}
+	seconds := int64((retryAfter + time.Second - 1) / time.Second)
+
+	response := NewErrorResponse(requestID, resource, http.StatusServiceUnavailable, "ServiceUnavailable", message)
+	response.Headers.Set("Retry-After", strconv.FormatInt(seconds, 10))
+	return response
+}
+
+// NewErrorResponse формирует S3Response с XML телом ошибки (Code, Message, Resource,
+// RequestId), заголовком Content-Type: application/xml и заданным HTTP статусом.
+// В отличие от S3Response.Error, код ошибки не выводится из текста сообщения.
+func NewErrorResponse(requestID, resource string, statusCode int, code, message string) *S3Response {
+	body := marshalError(S3Error{Code: code, Message: message, Resource: resource, RequestID: requestID})
+
+	headers := make(http.Header)
+	headers.Set("Content-Type", "application/xml")
+	headers.Set("Content-Length", fmt.Sprintf("%d", len(body)))
+
+	return &S3Response{
+		StatusCode:   statusCode,
+		Headers:      headers,
+		Body:         io.NopCloser(bytes.NewReader(body)),
+		errorSummary: code + ": " + message,
+	}
 }
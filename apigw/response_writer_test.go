@@ -0,0 +1,134 @@
+package apigw
+
+import (
+	"encoding/xml"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// staticHandler возвращает заранее заданный ответ
+type staticHandler struct {
+	response *S3Response
+}
+
+func (h *staticHandler) Handle(req *S3Request) *S3Response {
+	return h.response
+}
+
+// assertErrorResponse проверяет, что ответ - корректно сформированная S3 XML ошибка
+// с ожидаемыми статусом, кодом и ресурсом и с RequestId из заголовка ответа
+func assertErrorResponse(t *testing.T, rec *httptest.ResponseRecorder, status int, code, resource string) {
+	t.Helper()
+	if rec.Code != status {
+		t.Fatalf("Expected status %d, got %d: %s", status, rec.Code, rec.Body.String())
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/xml" {
+		t.Errorf("Expected Content-Type application/xml, got %q", ct)
+	}
+	if !strings.HasPrefix(rec.Body.String(), "<?xml") {
+		t.Errorf("Expected XML declaration, got %s", rec.Body.String())
+	}
+
+	var s3Error S3Error
+	if err := xml.Unmarshal(rec.Body.Bytes(), &s3Error); err != nil {
+		t.Fatalf("Malformed error body: %v: %s", err, rec.Body.String())
+	}
+	if s3Error.Code != code {
+		t.Errorf("Expected Code %s, got %s", code, s3Error.Code)
+	}
+	if s3Error.Message == "" {
+		t.Error("Expected non-empty Message")
+	}
+	if s3Error.Resource != resource {
+		t.Errorf("Expected Resource %q, got %q", resource, s3Error.Resource)
+	}
+	if requestID := rec.Header().Get(RequestIDHeader); s3Error.RequestID == "" || s3Error.RequestID != requestID {
+		t.Errorf("Expected RequestId %q, got %q", requestID, s3Error.RequestID)
+	}
+}
+
+func TestGateway_ErrorResponses(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   func(*Config)
+		response *S3Response
+		request  func() *http.Request
+		status   int
+		code     string
+	}{
+		{
+			name:    "Parser error",
+			request: func() *http.Request { return httptest.NewRequest(http.MethodPatch, "/my-bucket/object.txt", nil) },
+			status:  http.StatusBadRequest,
+			code:    "InvalidRequest",
+		},
+		{
+			name:   "Entity too large",
+			config: func(c *Config) { c.MaxObjectSize = 4 },
+			request: func() *http.Request {
+				return httptest.NewRequest(http.MethodPut, "/my-bucket/object.txt", strings.NewReader("too large body"))
+			},
+			status: http.StatusBadRequest,
+			code:   "EntityTooLarge",
+		},
+		{
+			name: "CORS preflight rejected",
+			config: func(c *Config) {
+				c.CORS = CORSConfig{AllowedOrigins: []string{"https://app.example.com"}, AllowedMethods: []string{"GET"}}
+			},
+			request: func() *http.Request {
+				req := httptest.NewRequest(http.MethodOptions, "/my-bucket/object.txt", nil)
+				req.Header.Set("Origin", "https://evil.example.com")
+				req.Header.Set("Access-Control-Request-Method", "GET")
+				return req
+			},
+			status: http.StatusForbidden,
+			code:   "AccessForbidden",
+		},
+		{
+			name:     "Handler error keeps status",
+			response: &S3Response{StatusCode: http.StatusServiceUnavailable, Error: errors.New("no live backends available")},
+			request:  func() *http.Request { return httptest.NewRequest(http.MethodGet, "/my-bucket/object.txt", nil) },
+			status:   http.StatusServiceUnavailable,
+			code:     "ServiceUnavailable",
+		},
+		{
+			name:     "Handler error mapped from message",
+			response: &S3Response{StatusCode: http.StatusNotFound, Error: errors.New("object not found on any backend")},
+			request:  func() *http.Request { return httptest.NewRequest(http.MethodGet, "/my-bucket/object.txt", nil) },
+			status:   http.StatusNotFound,
+			code:     "NoSuchKey",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := DefaultConfig()
+			if tt.config != nil {
+				tt.config(&config)
+			}
+			gw := New(config, &staticHandler{response: tt.response})
+
+			rec := httptest.NewRecorder()
+			gw.ServeHTTP(rec, tt.request())
+
+			assertErrorResponse(t, rec, tt.status, tt.code, "/my-bucket/object.txt")
+		})
+	}
+}
+
+func TestNewErrorResponse_EscapesMessage(t *testing.T) {
+	resp := NewErrorResponse("REQ1", ResourcePath("bucket", "a&b.txt"), http.StatusBadRequest,
+		"InvalidArgument", `value "<x>" & more`)
+
+	rec := httptest.NewRecorder()
+	rec.Header().Set(RequestIDHeader, "REQ1")
+	if err := NewResponseWriter().WriteResponse(rec, resp); err != nil {
+		t.Fatalf("WriteResponse failed: %v", err)
+	}
+
+	assertErrorResponse(t, rec, http.StatusBadRequest, "InvalidArgument", "/bucket/a&b.txt")
+}
@@ -0,0 +1,42 @@
+package apigw
+
+import (
+	"net/http"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName - имя трассировщика шлюза
+const tracerName = "s3proxy/apigw"
+
+// startRequestSpan открывает корневой спан запроса клиента. Контекст трассировки из
+// заголовка traceparent (если он есть) становится родительским. Возвращает запрос
+// с контекстом спана: из него парсер берет S3Request.Context.
+func startRequestSpan(r *http.Request) (*http.Request, trace.Span) {
+	ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+	ctx, span := otel.Tracer(tracerName).Start(ctx, "s3proxy "+r.Method,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			attribute.String("http.request.method", r.Method),
+			attribute.String("url.path", r.URL.Path),
+		))
+	return r.WithContext(ctx), span
+}
+
+// observeRequest записывает метрики запроса и его итог в спан
+func (gw *Gateway) observeRequest(span trace.Span, method, operation string, status int, latency time.Duration) {
+	gw.metrics.observeRequest(method, operation, status, latency)
+
+	span.SetAttributes(
+		attribute.String("s3.operation", operation),
+		attribute.Int("http.response.status_code", status),
+	)
+	if status >= http.StatusInternalServerError {
+		span.SetStatus(codes.Error, http.StatusText(status))
+	}
+}
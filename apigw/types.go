@@ -2,6 +2,7 @@ package apigw
 
 import (
 	"context"
+	"encoding/xml"
 	"io"
 	"net/http"
 	"net/url"
@@ -25,6 +26,16 @@ const (
 	AbortMultipartUpload
 	ListMultipartUploads
 	ListBuckets
+	CreateBucket
+	DeleteBucket
+	HeadService
+	GetObjectTagging
+	PutObjectTagging
+	DeleteObjectTagging
+	ListParts
+	CreateSession
+	ListObjectVersions
+	GetBucketVersioning
 )
 
 // String возвращает строковое представление операции
@@ -54,6 +65,26 @@ func (op S3Operation) String() string {
 		return "LIST_MULTIPART_UPLOADS"
 	case ListBuckets:
 		return "LIST_BUCKETS"
+	case CreateBucket:
+		return "CREATE_BUCKET"
+	case DeleteBucket:
+		return "DELETE_BUCKET"
+	case HeadService:
+		return "HEAD_SERVICE"
+	case GetObjectTagging:
+		return "GET_OBJECT_TAGGING"
+	case PutObjectTagging:
+		return "PUT_OBJECT_TAGGING"
+	case DeleteObjectTagging:
+		return "DELETE_OBJECT_TAGGING"
+	case ListParts:
+		return "LIST_PARTS"
+	case CreateSession:
+		return "CREATE_SESSION"
+	case ListObjectVersions:
+		return "LIST_OBJECT_VERSIONS"
+	case GetBucketVersioning:
+		return "GET_BUCKET_VERSIONING"
 	default:
 		return "UNSUPPORTED_OPERATION"
 	}
@@ -91,7 +122,25 @@ type S3Request struct {
 	ContentLength int64
 
 	// Оригинальный контекст запроса для поддержки таймаутов и отмены.
+	// Содержит идентификатор запроса (см. RequestIDFromContext).
 	Context context.Context
+
+	// Уникальный идентификатор запроса, назначенный API Gateway.
+	// Возвращается клиенту в заголовке x-amz-request-id и в XML-ответах об ошибках.
+	RequestID string
+
+	// Access Key пользователя, прошедшего аутентификацию. Заполняется обработчиком;
+	// пусто для анонимных запросов и до аутентификации. Выводится в журнал доступа.
+	AccessKey string
+}
+
+// SessionTokenHeader - заголовок с токеном сессии S3 Express One Zone (CreateSession)
+const SessionTokenHeader = "X-Amz-S3session-Token"
+
+// UsesSessionAuth сообщает, что запрос подписан учетными данными сессии S3 Express One Zone
+// (токен в заголовке или в query presigned URL)
+func (r *S3Request) UsesSessionAuth() bool {
+	return r.Headers.Get(SessionTokenHeader) != "" || r.Query.Get(SessionTokenHeader) != ""
 }
 
 // S3Response - это стандартизированное внутреннее представление ответа.
@@ -110,6 +159,10 @@ type S3Response struct {
 	// Ошибка, возникшая при обработке. Если не nil, Body игнорируется.
 	// Используется для формирования стандартного S3 XML-ответа об ошибке.
 	Error error
+
+	// errorSummary - код и сообщение ошибки, сформированной NewErrorResponse
+	// (тело уже сериализовано в XML). Используется буфером последних запросов.
+	errorSummary string
 }
 
 // RequestHandler - это интерфейс, который должен реализовывать
@@ -119,3 +172,16 @@ type RequestHandler interface {
 	// возвращая S3Response, готовый для отправки клиенту.
 	Handle(req *S3Request) *S3Response
 }
+
+// Tagging - XML представление тегов объекта (тело GET и PUT ?tagging)
+type Tagging struct {
+	XMLName xml.Name `xml:"Tagging"`
+	Xmlns   string   `xml:"xmlns,attr,omitempty"`
+	TagSet  []Tag    `xml:"TagSet>Tag"`
+}
+
+// Tag - один тег объекта
+type Tag struct {
+	Key   string `xml:"Key"`
+	Value string `xml:"Value"`
+}
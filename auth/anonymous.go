@@ -0,0 +1,65 @@
+package auth
+
+import (
+	"sync"
+
+	"s3proxy/apigw"
+)
+
+// AnonymousDisplayName - отображаемое имя анонимного пользователя
+const AnonymousDisplayName = "anonymous"
+
+// AnonymousAuthenticator оборачивает аутентификатор и пропускает запросы без подписи
+// как анонимные. Подписанные запросы передаются вложенному аутентификатору без изменений.
+// Анонимному пользователю разрешено только чтение публичных бакетов (см. Authorize).
+type AnonymousAuthenticator struct {
+	next Authenticator
+
+	mu                sync.RWMutex
+	allowAnonymous    bool
+	publicReadBuckets []string
+}
+
+// NewAnonymousAuthenticator создает аутентификатор с анонимным доступом на чтение
+// к бакетам publicReadBuckets (пустой список - все бакеты)
+func NewAnonymousAuthenticator(next Authenticator, publicReadBuckets []string) *AnonymousAuthenticator {
+	return &AnonymousAuthenticator{
+		next:              next,
+		allowAnonymous:    true,
+		publicReadBuckets: publicReadBuckets,
+	}
+}
+
+// UpdateConfig применяет новые настройки анонимного доступа (при перезагрузке)
+func (a *AnonymousAuthenticator) UpdateConfig(allowAnonymous bool, publicReadBuckets []string) {
+	a.mu.Lock()
+	a.allowAnonymous = allowAnonymous
+	a.publicReadBuckets = publicReadBuckets
+	a.mu.Unlock()
+}
+
+// Authenticate реализует интерфейс Authenticator
+func (a *AnonymousAuthenticator) Authenticate(req *apigw.S3Request) (*UserIdentity, error) {
+	a.mu.RLock()
+	allowAnonymous, publicReadBuckets := a.allowAnonymous, a.publicReadBuckets
+	a.mu.RUnlock()
+
+	if !allowAnonymous || isSignedRequest(req) {
+		return a.next.Authenticate(req)
+	}
+
+	return &UserIdentity{
+		DisplayName:       AnonymousDisplayName,
+		Anonymous:         true,
+		AllowedBuckets:    publicReadBuckets,
+		AllowedOperations: []string{OperationGroupRead},
+	}, nil
+}
+
+// isSignedRequest сообщает, что запрос содержит подпись в заголовке или в query (presigned URL)
+func isSignedRequest(req *apigw.S3Request) bool {
+	if req.Headers.Get("Authorization") != "" {
+		return true
+	}
+	return req.Query != nil && req.Query.Get("X-Amz-Signature") != ""
+}
@@ -0,0 +1,87 @@
+package auth
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+
+	"s3proxy/apigw"
+)
+
+func newAnonymousTestAuthenticator(t *testing.T, publicReadBuckets []string) *AnonymousAuthenticator {
+	t.Helper()
+
+	static, err := NewStaticAuthenticator(map[string]SecretKey{
+		"AKIDEXAMPLE": {SecretAccessKey: "secret", DisplayName: "user"},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create static authenticator: %v", err)
+	}
+	return NewAnonymousAuthenticator(static, publicReadBuckets)
+}
+
+func TestAnonymousAuthenticator_Access(t *testing.T) {
+	authenticator := newAnonymousTestAuthenticator(t, []string{"public-*"})
+
+	tests := []struct {
+		name      string
+		operation apigw.S3Operation
+		bucket    string
+		allowed   bool
+	}{
+		{name: "Anonymous GET on public bucket", operation: apigw.GetObject, bucket: "public-data", allowed: true},
+		{name: "Anonymous list on public bucket", operation: apigw.ListObjectsV2, bucket: "public-data", allowed: true},
+		{name: "Anonymous PUT is denied", operation: apigw.PutObject, bucket: "public-data", allowed: false},
+		{name: "Anonymous GET on private bucket", operation: apigw.GetObject, bucket: "private", allowed: false},
+		{name: "Anonymous ListBuckets is denied", operation: apigw.ListBuckets, allowed: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := &apigw.S3Request{Operation: tt.operation, Bucket: tt.bucket, Headers: make(http.Header), Query: make(url.Values)}
+
+			identity, err := authenticator.Authenticate(req)
+			if err != nil {
+				t.Fatalf("Expected anonymous identity, got %v", err)
+			}
+			if !identity.Anonymous {
+				t.Fatal("Expected identity to be anonymous")
+			}
+
+			err = Authorize(identity, req)
+			if tt.allowed && err != nil {
+				t.Errorf("Expected access to be allowed, got %v", err)
+			}
+			if !tt.allowed && err != ErrAccessDenied {
+				t.Errorf("Expected ErrAccessDenied, got %v", err)
+			}
+		})
+	}
+}
+
+func TestAnonymousAuthenticator_SignedRequests(t *testing.T) {
+	authenticator := newAnonymousTestAuthenticator(t, nil)
+
+	identity, err := authenticator.Authenticate(newSignedS3Request(t, "AKIDEXAMPLE", "secret"))
+	if err != nil {
+		t.Fatalf("Expected signed request to authenticate, got %v", err)
+	}
+	if identity.Anonymous || identity.AccessKey != "AKIDEXAMPLE" {
+		t.Errorf("Expected identity AKIDEXAMPLE, got %+v", identity)
+	}
+
+	// Неверная подпись не превращается в анонимный доступ
+	if _, err := authenticator.Authenticate(newSignedS3Request(t, "AKIDEXAMPLE", "wrong")); err != ErrSignatureMismatch {
+		t.Errorf("Expected ErrSignatureMismatch, got %v", err)
+	}
+}
+
+func TestAnonymousAuthenticator_Disabled(t *testing.T) {
+	authenticator := newAnonymousTestAuthenticator(t, nil)
+	authenticator.UpdateConfig(false, nil)
+
+	req := &apigw.S3Request{Operation: apigw.GetObject, Bucket: "data", Headers: make(http.Header), Query: make(url.Values)}
+	if _, err := authenticator.Authenticate(req); err != ErrMissingAuthHeader {
+		t.Errorf("Expected ErrMissingAuthHeader, got %v", err)
+	}
+}
@@ -0,0 +1,133 @@
+package auth
+
+import (
+	"fmt"
+	"path"
+	"strings"
+
+	"s3proxy/apigw"
+)
+
+// Группы операций, которые можно указывать в AllowedOperations
+const (
+	OperationGroupRead  = "read"
+	OperationGroupWrite = "write"
+	OperationGroupAll   = "*"
+)
+
+// operationNames сопоставляет имена операций S3 (как в API AWS) с операциями шлюза
+var operationNames = map[string]apigw.S3Operation{
+	"putobject":               apigw.PutObject,
+	"getobject":               apigw.GetObject,
+	"headobject":              apigw.HeadObject,
+	"headbucket":              apigw.HeadBucket,
+	"deleteobject":            apigw.DeleteObject,
+	"listobjectsv2":           apigw.ListObjectsV2,
+	"createmultipartupload":   apigw.CreateMultipartUpload,
+	"uploadpart":              apigw.UploadPart,
+	"completemultipartupload": apigw.CompleteMultipartUpload,
+	"abortmultipartupload":    apigw.AbortMultipartUpload,
+	"listmultipartuploads":    apigw.ListMultipartUploads,
+	"listbuckets":             apigw.ListBuckets,
+	"createbucket":            apigw.CreateBucket,
+	"deletebucket":            apigw.DeleteBucket,
+	"headservice":             apigw.HeadService,
+	"getobjecttagging":        apigw.GetObjectTagging,
+	"putobjecttagging":        apigw.PutObjectTagging,
+	"deleteobjecttagging":     apigw.DeleteObjectTagging,
+	"listparts":               apigw.ListParts,
+	"listobjectversions":      apigw.ListObjectVersions,
+	"getbucketversioning":     apigw.GetBucketVersioning,
+}
+
+// normalizeOperationName приводит "PutObject" и "PUT_OBJECT" к одному виду
+func normalizeOperationName(name string) string {
+	return strings.ToLower(strings.ReplaceAll(name, "_", ""))
+}
+
+// isReadOperation сообщает, что операция не изменяет данные
+func isReadOperation(op apigw.S3Operation) bool {
+	switch op {
+	case apigw.GetObject, apigw.HeadObject, apigw.HeadBucket, apigw.ListObjectsV2,
+		apigw.ListMultipartUploads, apigw.ListBuckets, apigw.HeadService, apigw.GetObjectTagging,
+		apigw.ListParts, apigw.ListObjectVersions, apigw.GetBucketVersioning:
+		return true
+	default:
+		return false
+	}
+}
+
+// Authorize проверяет, разрешена ли пользователю операция над бакетом запроса.
+// Пользователь без ограничений (пустые AllowedBuckets и AllowedOperations) имеет полный доступ.
+// Запросы без бакета (ListBuckets, HEAD /) проверяются только по списку операций
+// и недоступны анонимному пользователю.
+func Authorize(identity *UserIdentity, req *apigw.S3Request) error {
+	if identity.Anonymous && req.Bucket == "" {
+		return ErrAccessDenied
+	}
+	if !operationAllowed(identity.AllowedOperations, req.Operation) {
+		return ErrAccessDenied
+	}
+	if req.Bucket != "" && !bucketAllowed(identity.AllowedBuckets, req.Bucket) {
+		return ErrAccessDenied
+	}
+	return nil
+}
+
+// operationAllowed проверяет операцию по списку разрешенных операций и групп
+func operationAllowed(allowed []string, op apigw.S3Operation) bool {
+	if len(allowed) == 0 {
+		return true
+	}
+	for _, entry := range allowed {
+		switch normalizeOperationName(entry) {
+		case OperationGroupAll:
+			return true
+		case OperationGroupRead:
+			if isReadOperation(op) {
+				return true
+			}
+		case OperationGroupWrite:
+			if !isReadOperation(op) {
+				return true
+			}
+		default:
+			if mapped, ok := operationNames[normalizeOperationName(entry)]; ok && mapped == op {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// bucketAllowed проверяет бакет по списку шаблонов
+func bucketAllowed(patterns []string, bucket string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, pattern := range patterns {
+		if matched, err := path.Match(pattern, bucket); err == nil && matched {
+			return true
+		}
+	}
+	return false
+}
+
+// validateUserPolicy проверяет ограничения доступа пользователя
+func validateUserPolicy(user *UserConfig) error {
+	for _, pattern := range user.AllowedBuckets {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("invalid allowed_buckets pattern %q: %w", pattern, err)
+		}
+	}
+	for _, entry := range user.AllowedOperations {
+		name := normalizeOperationName(entry)
+		if name == OperationGroupAll || name == OperationGroupRead || name == OperationGroupWrite {
+			continue
+		}
+		if _, ok := operationNames[name]; !ok {
+			return fmt.Errorf("unknown operation %q in allowed_operations", entry)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,44 @@
+package auth
+
+import (
+	"testing"
+
+	"s3proxy/apigw"
+)
+
+func TestAuthorize(t *testing.T) {
+	tests := []struct {
+		name       string
+		buckets    []string
+		operations []string
+		operation  apigw.S3Operation
+		bucket     string
+		allowed    bool
+	}{
+		{name: "No restrictions", operation: apigw.DeleteBucket, bucket: "any", allowed: true},
+		{name: "Read group allows GetObject", operations: []string{"read"}, operation: apigw.GetObject, bucket: "data", allowed: true},
+		{name: "Read group denies PutObject", operations: []string{"read"}, operation: apigw.PutObject, bucket: "data", allowed: false},
+		{name: "Write group allows UploadPart", operations: []string{"write"}, operation: apigw.UploadPart, bucket: "data", allowed: true},
+		{name: "Operation by AWS name", operations: []string{"PutObject"}, operation: apigw.PutObject, bucket: "data", allowed: true},
+		{name: "Operation by gateway name", operations: []string{"LIST_OBJECTS_V2"}, operation: apigw.ListObjectsV2, bucket: "data", allowed: true},
+		{name: "Operation not listed", operations: []string{"GetObject"}, operation: apigw.HeadObject, bucket: "data", allowed: false},
+		{name: "Bucket pattern match", buckets: []string{"logs-*"}, operation: apigw.GetObject, bucket: "logs-2024", allowed: true},
+		{name: "Bucket not allowed", buckets: []string{"logs-*"}, operation: apigw.GetObject, bucket: "billing", allowed: false},
+		{name: "Request without bucket", buckets: []string{"logs-*"}, operation: apigw.ListBuckets, allowed: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			identity := &UserIdentity{AccessKey: "user", AllowedBuckets: tt.buckets, AllowedOperations: tt.operations}
+			req := &apigw.S3Request{Operation: tt.operation, Bucket: tt.bucket}
+
+			err := Authorize(identity, req)
+			if tt.allowed && err != nil {
+				t.Errorf("Expected access to be allowed, got %v", err)
+			}
+			if !tt.allowed && err != ErrAccessDenied {
+				t.Errorf("Expected ErrAccessDenied, got %v", err)
+			}
+		})
+	}
+}
@@ -1,6 +1,8 @@
 package auth
 
 import (
+	"os"
+	"path/filepath"
 	"testing"
 )
 
@@ -154,3 +156,73 @@ func TestUserConfig(t *testing.T) {
 		t.Error("DisplayName should not be empty")
 	}
 }
+
+func TestStaticConfig_CredentialsFiles(t *testing.T) {
+	dir := t.TempDir()
+	readers := filepath.Join(dir, "readers.yaml")
+	content := `users:
+  - access_key: READERKEY
+    secret_key: reader-secret
+    display_name: reader
+    allowed_buckets: ["public-*"]
+    allowed_operations: ["read"]
+`
+	if err := os.WriteFile(readers, []byte(content), 0600); err != nil {
+		t.Fatalf("Failed to write credentials file: %v", err)
+	}
+
+	config := &Config{
+		Provider: "static",
+		Static: &StaticConfig{
+			Users: []UserConfig{
+				{AccessKey: "ADMINKEY", SecretKey: "admin-secret", DisplayName: "admin"},
+			},
+			CredentialsFiles: []string{readers},
+		},
+	}
+
+	if err := config.Validate(); err != nil {
+		t.Fatalf("Expected valid config, got %v", err)
+	}
+
+	authenticator, err := NewAuthenticatorFromConfig(config)
+	if err != nil {
+		t.Fatalf("Expected no error, got %v", err)
+	}
+	staticAuth := authenticator.(*StaticAuthenticator)
+	if len(staticAuth.credentials) != 2 {
+		t.Fatalf("Expected 2 credentials, got %d", len(staticAuth.credentials))
+	}
+	reader := staticAuth.credentials["READERKEY"]
+	if len(reader.AllowedOperations) != 1 || reader.AllowedOperations[0] != "read" {
+		t.Errorf("Expected reader operations [read], got %v", reader.AllowedOperations)
+	}
+
+	t.Run("DuplicateAccessKeyAcrossFiles", func(t *testing.T) {
+		duplicate := *config.Static
+		duplicate.CredentialsFiles = []string{readers, readers}
+		if err := (&Config{Provider: "static", Static: &duplicate}).Validate(); err == nil {
+			t.Error("Expected error for duplicate access key")
+		}
+	})
+
+	t.Run("MissingFile", func(t *testing.T) {
+		missing := *config.Static
+		missing.CredentialsFiles = []string{filepath.Join(dir, "missing.yaml")}
+		if err := (&Config{Provider: "static", Static: &missing}).Validate(); err == nil {
+			t.Error("Expected error for missing credentials file")
+		}
+	})
+
+	t.Run("UnknownOperation", func(t *testing.T) {
+		invalid := &Config{
+			Provider: "static",
+			Static: &StaticConfig{Users: []UserConfig{
+				{AccessKey: "KEY", SecretKey: "secret", AllowedOperations: []string{"PutBucketPolicy"}},
+			}},
+		}
+		if err := invalid.Validate(); err == nil {
+			t.Error("Expected error for unknown operation")
+		}
+	})
+}
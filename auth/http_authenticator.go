@@ -0,0 +1,253 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"s3proxy/apigw"
+	"s3proxy/logger"
+)
+
+// Значения по умолчанию для HTTPConfig
+const (
+	DefaultHTTPAuthTimeout  = 5 * time.Second
+	DefaultHTTPAuthCacheTTL = time.Minute
+
+	DefaultHTTPAuthNegativeCacheTTL = 10 * time.Second
+)
+
+// HTTPConfig содержит конфигурацию для HTTPAuthenticator
+type HTTPConfig struct {
+	// Endpoint - URL сервиса ключей. Прокси выполняет GET {endpoint}?access_key=<ключ>
+	Endpoint string `yaml:"endpoint" json:"endpoint"`
+
+	// Token - если задан, передается сервису в заголовке "Authorization: Bearer <token>"
+	Token string `yaml:"token,omitempty" json:"token,omitempty"`
+
+	// Timeout - таймаут запроса к сервису ключей (по умолчанию 5s)
+	Timeout time.Duration `yaml:"timeout" json:"timeout"`
+
+	// CacheTTL - время кэширования полученного ключа (по умолчанию 1m)
+	CacheTTL time.Duration `yaml:"cache_ttl" json:"cache_ttl"`
+
+	// NegativeCacheTTL - время кэширования ответа 404 о неизвестном ключе (по умолчанию 10s).
+	// Без него запросы с несуществующим ключом каждый раз обращались бы к сервису
+	NegativeCacheTTL time.Duration `yaml:"negative_cache_ttl" json:"negative_cache_ttl"`
+}
+
+// httpKeyResponse - ответ сервиса ключей
+type httpKeyResponse struct {
+	SecretKey         string   `json:"secret_key"`
+	DisplayName       string   `json:"display_name"`
+	AllowedBuckets    []string `json:"allowed_buckets"`
+	AllowedOperations []string `json:"allowed_operations"`
+}
+
+// cachedKey - ключ из кэша HTTPAuthenticator. unknown отмечает ключ, о котором
+// сервис ответил 404
+type cachedKey struct {
+	key     SecretKey
+	unknown bool
+	expires time.Time
+}
+
+// HTTPAuthenticator реализует интерфейс Authenticator, запрашивая секретный ключ
+// по access key у внешнего HTTP сервиса. Подпись проверяется так же, как в StaticAuthenticator.
+//
+// Сервис отвечает 200 с JSON {"secret_key": ..., "display_name": ..., "allowed_buckets": [...],
+// "allowed_operations": [...]} или 404, если ключ неизвестен. Любой другой ответ или
+// недоступность сервиса приводят к ErrAuthServiceUnavailable.
+type HTTPAuthenticator struct {
+	sigV4Verifier
+
+	mu     sync.RWMutex
+	config HTTPConfig
+	cache  map[string]cachedKey
+
+	client  *http.Client
+	metrics *Metrics
+}
+
+// NewHTTPAuthenticator создает аутентификатор, использующий внешний сервис ключей
+func NewHTTPAuthenticator(config *HTTPConfig) (*HTTPAuthenticator, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	a := &HTTPAuthenticator{
+		cache:   make(map[string]cachedKey),
+		client:  &http.Client{},
+		metrics: NewMetrics(),
+	}
+	a.setConfig(config)
+	return a, nil
+}
+
+// Validate проверяет конфигурацию HTTPAuthenticator
+func (c *HTTPConfig) Validate() error {
+	if c.Endpoint == "" {
+		return errors.New("http auth endpoint cannot be empty")
+	}
+	u, err := url.Parse(c.Endpoint)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid http auth endpoint %q", c.Endpoint)
+	}
+	if c.Timeout < 0 {
+		return errors.New("http auth timeout cannot be negative")
+	}
+	if c.CacheTTL < 0 {
+		return errors.New("http auth cache_ttl cannot be negative")
+	}
+	if c.NegativeCacheTTL < 0 {
+		return errors.New("http auth negative_cache_ttl cannot be negative")
+	}
+	return nil
+}
+
+// UpdateConfig применяет новую конфигурацию (при перезагрузке) и сбрасывает кэш ключей
+func (a *HTTPAuthenticator) UpdateConfig(config *HTTPConfig) error {
+	if err := config.Validate(); err != nil {
+		return err
+	}
+	a.setConfig(config)
+	return nil
+}
+
+// setConfig заменяет конфигурацию, подставляя значения по умолчанию, и очищает кэш
+func (a *HTTPAuthenticator) setConfig(config *HTTPConfig) {
+	cfg := *config
+	if cfg.Timeout == 0 {
+		cfg.Timeout = DefaultHTTPAuthTimeout
+	}
+	if cfg.CacheTTL == 0 {
+		cfg.CacheTTL = DefaultHTTPAuthCacheTTL
+	}
+	if cfg.NegativeCacheTTL == 0 {
+		cfg.NegativeCacheTTL = DefaultHTTPAuthNegativeCacheTTL
+	}
+
+	a.mu.Lock()
+	a.config = cfg
+	a.cache = make(map[string]cachedKey)
+	a.mu.Unlock()
+}
+
+// Authenticate реализует интерфейс Authenticator
+func (a *HTTPAuthenticator) Authenticate(req *apigw.S3Request) (*UserIdentity, error) {
+	start := time.Now()
+
+	authData, err := a.parseRequest(req)
+	if err != nil {
+		a.metrics.AuthRequestsTotal.WithLabelValues("error").Inc()
+		return nil, err
+	}
+
+	secretKey, err := a.lookupKey(authData.AccessKey)
+	if err != nil {
+		logger.Debug("[%s] Failed to obtain secret key for %s: %v", req.RequestID, authData.AccessKey, err)
+		a.metrics.AuthRequestsTotal.WithLabelValues("error").Inc()
+		return nil, err
+	}
+
+	if err := a.verify(req, authData, secretKey.SecretAccessKey); err != nil {
+		a.metrics.AuthRequestsTotal.WithLabelValues("error").Inc()
+		return nil, err
+	}
+
+	a.metrics.AuthRequestsTotal.WithLabelValues("success").Inc()
+	a.metrics.AuthLatency.WithLabelValues("success").Observe(time.Since(start).Seconds())
+
+	return &UserIdentity{
+		AccessKey:         authData.AccessKey,
+		DisplayName:       secretKey.DisplayName,
+		AllowedBuckets:    secretKey.AllowedBuckets,
+		AllowedOperations: secretKey.AllowedOperations,
+	}, nil
+}
+
+// lookupKey возвращает ключ из кэша или запрашивает его у сервиса
+func (a *HTTPAuthenticator) lookupKey(accessKey string) (SecretKey, error) {
+	a.mu.RLock()
+	cached, ok := a.cache[accessKey]
+	config := a.config
+	a.mu.RUnlock()
+
+	if ok && time.Now().Before(cached.expires) {
+		if cached.unknown {
+			return SecretKey{}, ErrInvalidAccessKeyID
+		}
+		return cached.key, nil
+	}
+
+	key, err := a.fetchKey(&config, accessKey)
+	if err == ErrInvalidAccessKeyID {
+		a.mu.Lock()
+		a.cache[accessKey] = cachedKey{unknown: true, expires: time.Now().Add(config.NegativeCacheTTL)}
+		a.mu.Unlock()
+		return SecretKey{}, err
+	}
+	if err != nil {
+		return SecretKey{}, err
+	}
+
+	a.mu.Lock()
+	a.cache[accessKey] = cachedKey{key: key, expires: time.Now().Add(config.CacheTTL)}
+	a.mu.Unlock()
+	return key, nil
+}
+
+// fetchKey запрашивает ключ у сервиса
+func (a *HTTPAuthenticator) fetchKey(config *HTTPConfig, accessKey string) (SecretKey, error) {
+	u, err := url.Parse(config.Endpoint)
+	if err != nil {
+		return SecretKey{}, fmt.Errorf("%w: %v", ErrAuthServiceUnavailable, err)
+	}
+	query := u.Query()
+	query.Set("access_key", accessKey)
+	u.RawQuery = query.Encode()
+
+	httpReq, err := http.NewRequest(http.MethodGet, u.String(), nil)
+	if err != nil {
+		return SecretKey{}, fmt.Errorf("%w: %v", ErrAuthServiceUnavailable, err)
+	}
+	httpReq.Header.Set("Accept", "application/json")
+	if config.Token != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+config.Token)
+	}
+
+	client := *a.client
+	client.Timeout = config.Timeout
+	resp, err := client.Do(httpReq)
+	if err != nil {
+		return SecretKey{}, fmt.Errorf("%w: %v", ErrAuthServiceUnavailable, err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return SecretKey{}, ErrInvalidAccessKeyID
+	default:
+		return SecretKey{}, fmt.Errorf("%w: unexpected status %d", ErrAuthServiceUnavailable, resp.StatusCode)
+	}
+
+	var body httpKeyResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return SecretKey{}, fmt.Errorf("%w: invalid response: %v", ErrAuthServiceUnavailable, err)
+	}
+	if body.SecretKey == "" {
+		return SecretKey{}, fmt.Errorf("%w: response without secret_key", ErrAuthServiceUnavailable)
+	}
+
+	return SecretKey{
+		SecretAccessKey:   body.SecretKey,
+		DisplayName:       body.DisplayName,
+		AllowedBuckets:    body.AllowedBuckets,
+		AllowedOperations: body.AllowedOperations,
+	}, nil
+}
@@ -0,0 +1,172 @@
+package auth
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+
+	"s3proxy/apigw"
+)
+
+// newSignedS3Request создает подписанный SigV4 GET запрос в том виде, в каком его передает API Gateway
+func newSignedS3Request(t *testing.T, accessKey, secretKey string) *apigw.S3Request {
+	t.Helper()
+
+	const payloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+	r := httptest.NewRequest(http.MethodGet, "http://localhost:9000/test-bucket/object.txt", nil)
+	r.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	creds := aws.Credentials{AccessKeyID: accessKey, SecretAccessKey: secretKey}
+	if err := v4.NewSigner().SignHTTP(context.Background(), creds, r, payloadHash, "s3", "us-east-1", time.Now()); err != nil {
+		t.Fatalf("Failed to sign request: %v", err)
+	}
+
+	req, err := apigw.NewRequestParser("").Parse(r)
+	if err != nil {
+		t.Fatalf("Failed to parse request: %v", err)
+	}
+	return req
+}
+
+// newKeyService запускает сервис ключей с одним известным пользователем
+func newKeyService(t *testing.T, requests *int32) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(requests, 1)
+		if r.Header.Get("Authorization") != "Bearer service-token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		if r.URL.Query().Get("access_key") != "CENTRALKEY" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"secret_key":         "central-secret",
+			"display_name":       "central-user",
+			"allowed_operations": []string{"read"},
+		})
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestHTTPAuthenticator_Authenticate(t *testing.T) {
+	var requests int32
+	server := newKeyService(t, &requests)
+
+	authenticator, err := NewHTTPAuthenticator(&HTTPConfig{Endpoint: server.URL + "/keys", Token: "service-token"})
+	if err != nil {
+		t.Fatalf("Failed to create authenticator: %v", err)
+	}
+
+	t.Run("ValidSignature", func(t *testing.T) {
+		identity, err := authenticator.Authenticate(newSignedS3Request(t, "CENTRALKEY", "central-secret"))
+		if err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if identity.DisplayName != "central-user" {
+			t.Errorf("Expected display name central-user, got %s", identity.DisplayName)
+		}
+		if len(identity.AllowedOperations) != 1 || identity.AllowedOperations[0] != "read" {
+			t.Errorf("Expected allowed operations [read], got %v", identity.AllowedOperations)
+		}
+	})
+
+	t.Run("CachedKey", func(t *testing.T) {
+		before := atomic.LoadInt32(&requests)
+		if _, err := authenticator.Authenticate(newSignedS3Request(t, "CENTRALKEY", "central-secret")); err != nil {
+			t.Fatalf("Expected no error, got %v", err)
+		}
+		if after := atomic.LoadInt32(&requests); after != before {
+			t.Errorf("Expected cached key to be used, got %d service requests", after-before)
+		}
+	})
+
+	t.Run("SignatureMismatch", func(t *testing.T) {
+		if _, err := authenticator.Authenticate(newSignedS3Request(t, "CENTRALKEY", "wrong-secret")); err != ErrSignatureMismatch {
+			t.Errorf("Expected ErrSignatureMismatch, got %v", err)
+		}
+	})
+
+	t.Run("UnknownAccessKey", func(t *testing.T) {
+		if _, err := authenticator.Authenticate(newSignedS3Request(t, "UNKNOWNKEY", "secret")); err != ErrInvalidAccessKeyID {
+			t.Errorf("Expected ErrInvalidAccessKeyID, got %v", err)
+		}
+	})
+
+	t.Run("CachedUnknownAccessKey", func(t *testing.T) {
+		before := atomic.LoadInt32(&requests)
+		if _, err := authenticator.Authenticate(newSignedS3Request(t, "UNKNOWNKEY", "secret")); err != ErrInvalidAccessKeyID {
+			t.Errorf("Expected ErrInvalidAccessKeyID, got %v", err)
+		}
+		if after := atomic.LoadInt32(&requests); after != before {
+			t.Errorf("Expected cached 404 to be used, got %d service requests", after-before)
+		}
+	})
+}
+
+func TestHTTPAuthenticator_ServiceUnavailable(t *testing.T) {
+	var requests int32
+	server := newKeyService(t, &requests)
+
+	t.Run("UnexpectedStatus", func(t *testing.T) {
+		authenticator, err := NewHTTPAuthenticator(&HTTPConfig{Endpoint: server.URL, Token: "wrong-token"})
+		if err != nil {
+			t.Fatalf("Failed to create authenticator: %v", err)
+		}
+		_, err = authenticator.Authenticate(newSignedS3Request(t, "CENTRALKEY", "central-secret"))
+		if !errors.Is(err, ErrAuthServiceUnavailable) {
+			t.Errorf("Expected ErrAuthServiceUnavailable, got %v", err)
+		}
+	})
+
+	t.Run("Unreachable", func(t *testing.T) {
+		authenticator, err := NewHTTPAuthenticator(&HTTPConfig{Endpoint: server.URL, Token: "service-token", Timeout: time.Second})
+		if err != nil {
+			t.Fatalf("Failed to create authenticator: %v", err)
+		}
+		server.Close()
+
+		_, err = authenticator.Authenticate(newSignedS3Request(t, "CENTRALKEY", "central-secret"))
+		if !errors.Is(err, ErrAuthServiceUnavailable) {
+			t.Errorf("Expected ErrAuthServiceUnavailable, got %v", err)
+		}
+	})
+}
+
+func TestHTTPConfig_Validate(t *testing.T) {
+	tests := []struct {
+		name   string
+		config HTTPConfig
+		valid  bool
+	}{
+		{name: "Valid", config: HTTPConfig{Endpoint: "https://keys.example.com/v1/keys"}, valid: true},
+		{name: "Empty endpoint", config: HTTPConfig{}, valid: false},
+		{name: "Relative endpoint", config: HTTPConfig{Endpoint: "/keys"}, valid: false},
+		{name: "Negative timeout", config: HTTPConfig{Endpoint: "http://keys", Timeout: -time.Second}, valid: false},
+		{name: "Negative negative_cache_ttl", config: HTTPConfig{Endpoint: "http://keys", NegativeCacheTTL: -time.Second}, valid: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.config.Validate()
+			if tt.valid && err != nil {
+				t.Errorf("Expected valid config, got %v", err)
+			}
+			if !tt.valid && err == nil {
+				t.Error("Expected validation error")
+			}
+		})
+	}
+}
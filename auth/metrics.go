@@ -1,6 +1,8 @@
 package auth
 
 import (
+	"sync"
+
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
 )
@@ -11,7 +13,20 @@ type Metrics struct {
 	AuthLatency       *prometheus.HistogramVec // Латентность аутентификации
 }
 
+var (
+	metricsOnce     sync.Once
+	metricsInstance *Metrics
+)
+
+// NewMetrics возвращает метрики модуля, общие для всех экземпляров (см. backend.NewMetrics)
 func NewMetrics() *Metrics {
+	metricsOnce.Do(func() {
+		metricsInstance = newMetrics()
+	})
+	return metricsInstance
+}
+
+func newMetrics() *Metrics {
 	return &Metrics{
 		// Общие метрики запросов
 		AuthRequestsTotal: promauto.NewCounterVec(
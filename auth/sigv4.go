@@ -0,0 +1,225 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"crypto/subtle"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+
+	"s3proxy/apigw"
+	"s3proxy/logger"
+)
+
+// sigV4Verifier проверяет подпись AWS SigV4 по известному секретному ключу.
+// Встраивается в аутентификаторы, которые различаются только способом получения ключа.
+type sigV4Verifier struct{}
+
+// parseRequest извлекает и разбирает заголовок Authorization запроса
+func (v *sigV4Verifier) parseRequest(req *apigw.S3Request) (*authorizationData, error) {
+	authHeader := req.Headers.Get("Authorization")
+	if authHeader == "" {
+		logger.Debug("Missing Authorization header")
+		return nil, ErrMissingAuthHeader
+	}
+
+	authData, err := v.parseAuthorizationHeader(authHeader, req.Headers.Get("x-amz-date"))
+	if err != nil {
+		logger.Debug("Failed to parse authorization header: %v", err)
+		return nil, err
+	}
+	return authData, nil
+}
+
+// verify вычисляет ожидаемую подпись запроса и сравнивает ее с подписью клиента
+func (v *sigV4Verifier) verify(req *apigw.S3Request, authData *authorizationData, secretAccessKey string) error {
+	// Ручное построение канонического запроса (Canonical Request)
+	canonicalRequest, err := v.buildCanonicalRequest(req, authData)
+	if err != nil {
+		logger.Debug("Failed to build canonical request: %v", err)
+		return err
+	}
+	logger.Debug("Built Canonical Request:\n%s", canonicalRequest)
+
+	// Построение строки для подписи (String to Sign)
+	stringToSign := v.buildStringToSign(canonicalRequest, authData)
+	logger.Debug("Built String to Sign:\n%s", stringToSign)
+
+	// Вычисление ожидаемой подписи
+	expectedSignature := v.calculateSignature(stringToSign, secretAccessKey, authData)
+	logger.Debug("Client signature:   %s", authData.Signature)
+	logger.Debug("Expected signature: %s", expectedSignature)
+
+	// Сравнение подписей (безопасное от атак по времени)
+	if subtle.ConstantTimeCompare([]byte(authData.Signature), []byte(expectedSignature)) != 1 {
+		logger.Debug("Signature mismatch")
+		return ErrSignatureMismatch
+	}
+	return nil
+}
+
+// buildCanonicalRequest создает строку канонического запроса согласно спецификации AWS.
+func (v *sigV4Verifier) buildCanonicalRequest(req *apigw.S3Request, authData *authorizationData) (string, error) {
+	httpMethod := v.getHTTPMethod(req.Operation)
+	canonicalURI := v.getRequestPath(req.Bucket, req.Key)
+	canonicalQueryString := req.Query.Encode()
+
+	var canonicalHeaders strings.Builder
+	headerKeys := make([]string, 0, len(authData.SignedHeaders))
+	headerValues := make(map[string]string)
+
+	for _, key := range authData.SignedHeaders {
+		lowerKey := strings.ToLower(key)
+		headerKeys = append(headerKeys, lowerKey)
+		if lowerKey == "host" {
+			headerValues[lowerKey] = req.Host
+		} else {
+			// Значения повторяющегося заголовка объединяются через запятую в порядке следования
+			values := req.Headers.Values(key)
+			trimmed := make([]string, len(values))
+			for i, val := range values {
+				trimmed[i] = collapseSpaces(val)
+			}
+			headerValues[lowerKey] = strings.Join(trimmed, ",")
+		}
+	}
+	sort.Strings(headerKeys)
+
+	for _, key := range headerKeys {
+		fmt.Fprintf(&canonicalHeaders, "%s:%s\n", key, headerValues[key])
+	}
+
+	signedHeaders := strings.Join(authData.SignedHeaders, ";")
+	payloadHash := req.Headers.Get("x-amz-content-sha256")
+	if payloadHash == "" {
+		payloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+	}
+
+	return fmt.Sprintf("%s\n%s\n%s\n%s\n%s\n%s",
+		httpMethod, canonicalURI, canonicalQueryString, canonicalHeaders.String(), signedHeaders, payloadHash), nil
+}
+
+// collapseSpaces обрезает пробелы по краям значения заголовка и заменяет последовательности
+// пробелов внутри одним пробелом. Как и подписчики AWS SDK, пробелы в кавычках не выделяются.
+func collapseSpaces(value string) string {
+	value = strings.TrimSpace(value)
+	if !strings.Contains(value, "  ") {
+		return value
+	}
+	var b strings.Builder
+	b.Grow(len(value))
+	for i := 0; i < len(value); i++ {
+		if value[i] == ' ' && i > 0 && value[i-1] == ' ' {
+			continue
+		}
+		b.WriteByte(value[i])
+	}
+	return b.String()
+}
+
+// buildStringToSign создает "строку для подписи" на основе канонического запроса.
+func (v *sigV4Verifier) buildStringToSign(canonicalRequest string, authData *authorizationData) string {
+	algorithm := authData.Algorithm
+	timestamp := authData.Timestamp
+	scope := fmt.Sprintf("%s/%s/%s/aws4_request", authData.Date, authData.Region, authData.Service)
+
+	hash := sha256.New()
+	hash.Write([]byte(canonicalRequest))
+	hashedCanonicalRequest := fmt.Sprintf("%x", hash.Sum(nil))
+
+	return fmt.Sprintf("%s\n%s\n%s\n%s",
+		algorithm, timestamp, scope, hashedCanonicalRequest)
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+func (v *sigV4Verifier) calculateSignature(stringToSign, secretKey string, authData *authorizationData) string {
+	kSecret := "AWS4" + secretKey
+	kDate := hmacSHA256([]byte(kSecret), authData.Date)
+	kRegion := hmacSHA256(kDate, authData.Region)
+	kService := hmacSHA256(kRegion, authData.Service)
+	kSigning := hmacSHA256(kService, "aws4_request")
+
+	signature := hmac.New(sha256.New, kSigning)
+	signature.Write([]byte(stringToSign))
+	return fmt.Sprintf("%x", signature.Sum(nil))
+}
+
+type authorizationData struct {
+	AccessKey, Date, Region, Service, Signature, Algorithm, Timestamp string
+	SignedHeaders                                                     []string
+}
+
+func (v *sigV4Verifier) parseAuthorizationHeader(authHeader, xAmzDate string) (*authorizationData, error) {
+	if !strings.HasPrefix(authHeader, "AWS4-HMAC-SHA256 ") {
+		return nil, ErrInvalidAuthHeader
+	}
+	authContent := strings.TrimPrefix(authHeader, "AWS4-HMAC-SHA256 ")
+	parts := strings.Split(authContent, ", ")
+	if len(parts) != 3 {
+		parts = strings.Split(authContent, ",")
+		if len(parts) != 3 {
+			return nil, ErrInvalidAuthHeader
+		}
+	}
+	authData := &authorizationData{Algorithm: "AWS4-HMAC-SHA256", Timestamp: xAmzDate}
+	for _, part := range parts {
+		part = strings.TrimSpace(part)
+		if strings.HasPrefix(part, "Credential=") {
+			credential := strings.TrimPrefix(part, "Credential=")
+			credParts := strings.Split(credential, "/")
+			if len(credParts) != 5 {
+				return nil, ErrInvalidAuthHeader
+			}
+			authData.AccessKey, authData.Date, authData.Region, authData.Service = credParts[0], credParts[1], credParts[2], credParts[3]
+		} else if strings.HasPrefix(part, "SignedHeaders=") {
+			authData.SignedHeaders = strings.Split(strings.TrimPrefix(part, "SignedHeaders="), ";")
+		} else if strings.HasPrefix(part, "Signature=") {
+			authData.Signature = strings.TrimPrefix(part, "Signature=")
+		}
+	}
+	if authData.AccessKey == "" || authData.Date == "" || authData.Region == "" || authData.Service == "" || len(authData.SignedHeaders) == 0 || authData.Signature == "" || authData.Timestamp == "" {
+		return nil, ErrInvalidAuthHeader
+	}
+	return authData, nil
+}
+
+func (v *sigV4Verifier) getHTTPMethod(operation apigw.S3Operation) string {
+	switch operation {
+	case apigw.GetObject, apigw.ListObjectsV2, apigw.ListBuckets, apigw.ListMultipartUploads, apigw.ListParts,
+		apigw.ListObjectVersions, apigw.GetBucketVersioning:
+		return "GET"
+	case apigw.PutObject, apigw.UploadPart:
+		return "PUT"
+	case apigw.HeadObject:
+		return "HEAD"
+	case apigw.DeleteObject, apigw.AbortMultipartUpload:
+		return "DELETE"
+	case apigw.CreateMultipartUpload, apigw.CompleteMultipartUpload:
+		return "POST"
+	default:
+		return "GET"
+	}
+}
+
+// getRequestPath возвращает URI-кодированный путь запроса.
+// УЛУЧШЕННАЯ ВЕРСИЯ.
+func (v *sigV4Verifier) getRequestPath(bucket, key string) string {
+	path := "/"
+	if bucket != "" {
+		path = "/" + bucket + "/"
+		if key != "" {
+			path += key
+		}
+	}
+	// Используем стандартную библиотеку для корректного URI-кодирования пути.
+	// Этот метод кодирует спецсимволы в сегментах пути, но оставляет '/' как есть,
+	// что в точности соответствует требованиям S3.
+	return (&url.URL{Path: path}).RequestURI()
+}
@@ -23,6 +23,13 @@ type UserIdentity struct {
 	// Отображаемое имя пользователя (опционально, для логов и UI).
 	DisplayName string
 
+	// Ограничения доступа пользователя (см. Authorize). Пустые списки - без ограничений.
+	AllowedBuckets    []string
+	AllowedOperations []string
+
+	// Anonymous - запрос без подписи, пропущенный AnonymousAuthenticator
+	Anonymous bool
+
 	// Можно добавить другие поля для будущих нужд, например, список ролей.
 	// Roles []string
 }
@@ -39,10 +46,19 @@ var (
 	ErrSignatureMismatch = errors.New("signature does not match")
 	// ErrRequestExpired - временная метка запроса находится за пределами допустимого окна.
 	ErrRequestExpired = errors.New("request has expired")
+	// ErrAccessDenied - пользователю не разрешен запрошенный бакет или операция.
+	ErrAccessDenied = errors.New("access denied")
+	// ErrAuthServiceUnavailable - внешний сервис ключей недоступен или вернул некорректный ответ.
+	ErrAuthServiceUnavailable = errors.New("authentication service unavailable")
 )
 
 // SecretKey представляет секретный ключ и связанные с ним данные пользователя.
 type SecretKey struct {
 	SecretAccessKey string
 	DisplayName     string
+
+	// AllowedBuckets и AllowedOperations ограничивают доступ пользователя.
+	// Пустой список - ограничения нет.
+	AllowedBuckets    []string
+	AllowedOperations []string
 }
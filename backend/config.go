@@ -2,6 +2,7 @@ package backend
 
 import (
 	"fmt"
+	"os"
 	"time"
 )
 
@@ -27,6 +28,37 @@ type ManagerConfig struct {
 
 	// InitialState - начальное состояние бэкендов при запуске
 	InitialState BackendState `yaml:"initial_state"`
+
+	// StatusCodeClasses - записывать в метку code метрики s3proxy_backend_requests_total
+	// класс кода ответа (2xx, 4xx, 5xx) вместо самого кода, чтобы ограничить число временных рядов
+	StatusCodeClasses bool `yaml:"status_code_classes"`
+
+	// DedicatedHealthCheckClient - выполнять активные проверки отдельным S3 клиентом
+	// с собственным небольшим пулом соединений, чтобы зависание запросов данных
+	// не задерживало проверки и не скрывало отказ бэкенда
+	DedicatedHealthCheckClient bool `yaml:"dedicated_health_check_client"`
+
+	// ProbeBudget - сколько клиентских запросов одновременно может получить бэкенд
+	// в состоянии PROBING. Успехи этих запросов учитываются в SuccessThreshold,
+	// любая критическая ошибка возвращает бэкенд в DOWN. 0 - бэкенд в PROBING
+	// не получает клиентских запросов, восстановление определяют только проверки.
+	ProbeBudget int `yaml:"probe_budget"`
+
+	// RequestIDHeader - заголовок, в котором бэкендам передается идентификатор клиентского
+	// запроса прокси (например, X-S3proxy-Request-Id). Пусто - идентификатор не передается.
+	// Идентификаторы запросов бэкенда (x-amz-request-id) пишутся в лог независимо от настройки.
+	RequestIDHeader string `yaml:"request_id_header"`
+
+	// ReadWarmup - сколько бэкенд должен пробыть в состоянии UP после восстановления
+	// (перехода из PROBING или DOWN), прежде чем получать чтения. Запись на него идет сразу,
+	// чтобы он успел догнать остальные бэкенды. Бэкенды, начавшие работу в UP
+	// (initial_state), прогрева не проходят. 0 - выключено.
+	ReadWarmup time.Duration `yaml:"read_warmup"`
+
+	// ForbiddenAsUnauthorized - переводить бэкенд, FailureThreshold раз подряд ответивший
+	// 403 на активную проверку, в состояние UNAUTHORIZED вместо DOWN, чтобы проблемы
+	// с ключами и правами отличались от недоступности. По умолчанию выключено.
+	ForbiddenAsUnauthorized bool `yaml:"forbidden_as_unauthorized"`
 }
 
 // Config содержит полную конфигурацию модуля
@@ -48,22 +80,6 @@ func DefaultManagerConfig() ManagerConfig {
 	}
 }
 
-// DefaultConfig возвращает конфигурацию по умолчанию
-// func DefaultConfig() *Config {
-// 	return &Config{
-// 		Manager: DefaultManagerConfig(),
-// 		Backends: map[string]BackendConfig{
-// 			"local-minio": {
-// 				Endpoint:  "http://localhost:9000",
-// 				Region:    "us-east-1",
-// 				Bucket:    "test-bucket",
-// 				AccessKey: "minioadmin",
-// 				SecretKey: "minioadmin",
-// 			},
-// 		},
-// 	}
-// }
-
 // Validate проверяет корректность конфигурации
 func (c *Config) Validate() error {
 	// Проверяем конфигурацию менеджера
@@ -116,6 +132,14 @@ func (mc *ManagerConfig) Validate() error {
 		return fmt.Errorf("circuit_breaker_threshold must be positive")
 	}
 
+	if mc.ProbeBudget < 0 {
+		return fmt.Errorf("probe_budget cannot be negative")
+	}
+
+	if mc.ReadWarmup < 0 {
+		return fmt.Errorf("read_warmup cannot be negative")
+	}
+
 	if mc.InitialState != StateUp && mc.InitialState != StateDown && mc.InitialState != StateProbing {
 		return fmt.Errorf("initial_state must be one of: UP, DOWN, PROBING")
 	}
@@ -145,5 +169,41 @@ func (bc *BackendConfig) Validate() error {
 		return fmt.Errorf("secret_key cannot be empty")
 	}
 
+	if bc.MaxAttempts < 0 {
+		return fmt.Errorf("max_attempts cannot be negative")
+	}
+
+	if bc.MaxIdleConns < 0 || bc.MaxIdleConnsPerHost < 0 {
+		return fmt.Errorf("max_idle_conns and max_idle_conns_per_host cannot be negative")
+	}
+
+	if bc.IdleConnTimeout < 0 || bc.DialTimeout < 0 || bc.TLSHandshakeTimeout < 0 {
+		return fmt.Errorf("idle_conn_timeout, dial_timeout and tls_handshake_timeout cannot be negative")
+	}
+
+	if bc.MaxRequestsPerSecond < 0 || bc.RateLimitBurst < 0 || bc.RateLimitWait < 0 {
+		return fmt.Errorf("max_requests_per_second, rate_limit_burst and rate_limit_wait cannot be negative")
+	}
+
+	if bc.MinObjectSize < 0 || bc.MaxObjectSize < 0 {
+		return fmt.Errorf("min_object_size and max_object_size cannot be negative")
+	}
+
+	if bc.MaxObjectSize > 0 && bc.MinObjectSize > bc.MaxObjectSize {
+		return fmt.Errorf("min_object_size cannot be greater than max_object_size")
+	}
+
+	if bc.CABundleFile != "" {
+		if _, err := os.Stat(bc.CABundleFile); err != nil {
+			return fmt.Errorf("ca_bundle_file: %w", err)
+		}
+	}
+
+	for i := range bc.MaintenanceWindows {
+		if err := bc.MaintenanceWindows[i].Validate(); err != nil {
+			return fmt.Errorf("maintenance_windows[%d]: %w", i, err)
+		}
+	}
+
 	return nil
 }
@@ -0,0 +1,66 @@
+package backend
+
+import (
+	"time"
+
+	"s3proxy/logger"
+)
+
+// stateChangeBuffer - емкость канала подписчика. События, не поместившиеся в буфер
+// медленного подписчика, отбрасываются, чтобы не задерживать проверки и запросы.
+const stateChangeBuffer = 64
+
+// BackendStateChange - событие смены состояния бэкенда
+type BackendStateChange struct {
+	BackendID string
+	Old       BackendState
+	New       BackendState
+	Time      time.Time
+}
+
+// Subscribe возвращает канал, в который приходят события о каждой смене состояния
+// бэкендов (активные проверки, Circuit Breaker, пассивные переходы). Отправка не
+// блокирует менеджер: если подписчик не успевает читать, события для него теряются.
+// Канал закрывается вызовом Unsubscribe.
+func (m *Manager) Subscribe() <-chan BackendStateChange {
+	ch := make(chan BackendStateChange, stateChangeBuffer)
+	m.subMu.Lock()
+	m.subscribers = append(m.subscribers, ch)
+	m.subMu.Unlock()
+	return ch
+}
+
+// Unsubscribe отменяет подписку и закрывает канал, полученный от Subscribe
+func (m *Manager) Unsubscribe(sub <-chan BackendStateChange) {
+	m.subMu.Lock()
+	defer m.subMu.Unlock()
+	for i, ch := range m.subscribers {
+		if ch == sub {
+			m.subscribers = append(m.subscribers[:i], m.subscribers[i+1:]...)
+			close(ch)
+			return
+		}
+	}
+}
+
+// publishStateChange рассылает событие всем подписчикам, не дожидаясь их
+func (m *Manager) publishStateChange(change BackendStateChange) {
+	m.subMu.Lock()
+	defer m.subMu.Unlock()
+	for _, ch := range m.subscribers {
+		select {
+		case ch <- change:
+		default:
+			logger.Warn("Backend state change %s %s -> %s dropped: subscriber is not keeping up",
+				change.BackendID, change.Old, change.New)
+		}
+	}
+}
+
+// logStateChanges пишет в лог смены состояния бэкендов до закрытия канала подписки
+func (m *Manager) logStateChanges(sub <-chan BackendStateChange) {
+	defer m.wg.Done()
+	for change := range sub {
+		logger.Info("Backend %s state changed: %s -> %s", change.BackendID, change.Old, change.New)
+	}
+}
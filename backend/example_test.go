@@ -37,34 +37,29 @@ func ExampleManager() {
 	}
 
 	// Создаем менеджер
-	manager, err := NewManager(config, nil)
+	manager, err := NewManager(config)
 	if err != nil {
 		fmt.Printf("Failed to create manager: %v\n", err)
 		return
 	}
 
-	// Запускаем активные проверки
-	err = manager.Start()
-	if err != nil {
-		fmt.Printf("Failed to start manager: %v\n", err)
-		return
-	}
-	defer manager.Stop()
+	// Активные проверки запускаются через manager.Start(). В примере они не запускаются,
+	// чтобы вывод не зависел от доступности сети.
 
 	// Получаем все бэкенды
 	allBackends := manager.GetAllBackends()
 	fmt.Printf("Total backends: %d\n", len(allBackends))
 
-	// Получаем живые бэкенды
+	// Получаем живые бэкенды (до первой успешной проверки все в PROBING)
 	liveBackends := manager.GetLiveBackends()
 	fmt.Printf("Live backends: %d\n", len(liveBackends))
 
 	// Симулируем успешную операцию
-	manager.ReportSuccess("primary")
+	manager.ReportSuccess(&BackendResult{BackendID: "primary"})
 	fmt.Println("Reported success for primary backend")
 
 	// Симулируем неудачную операцию
-	manager.ReportFailure("backup", fmt.Errorf("connection timeout"))
+	manager.ReportFailure(&BackendResult{BackendID: "backup", Err: fmt.Errorf("connection timeout")})
 	fmt.Println("Reported failure for backup backend")
 
 	// Проверяем состояние конкретного бэкенда
@@ -75,7 +70,7 @@ func ExampleManager() {
 
 	// Output:
 	// Total backends: 2
-	// Live backends: 2
+	// Live backends: 0
 	// Reported success for primary backend
 	// Reported failure for backup backend
 	// Primary backend state: PROBING
@@ -87,7 +82,7 @@ func Example_circuitBreaker() {
 	config.Manager.CircuitBreakerThreshold = 2 // Низкий порог для демонстрации
 	config.Manager.CircuitBreakerWindow = 10 * time.Second
 
-	manager, _ := NewManager(config, nil)
+	manager, _ := NewManager(config)
 
 	backendID := "local-minio"
 	testError := fmt.Errorf("network error")
@@ -101,10 +96,10 @@ func Example_circuitBreaker() {
 	fmt.Printf("Initial state: %s\n", backend.GetState())
 
 	// Отправляем ошибки
-	manager.ReportFailure(backendID, testError)
+	manager.ReportFailure(&BackendResult{BackendID: backendID, Err: testError})
 	fmt.Printf("After 1 failure: %s\n", backend.GetState())
 
-	manager.ReportFailure(backendID, testError)
+	manager.ReportFailure(&BackendResult{BackendID: backendID, Err: testError})
 	fmt.Printf("After 2 failures (circuit breaker): %s\n", backend.GetState())
 
 	// Output:
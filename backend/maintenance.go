@@ -0,0 +1,106 @@
+package backend
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// MaintenanceWindow - периодическое окно обслуживания бэкенда. Во время окна бэкенд
+// исключается из GetLiveBackends (новые запросы на него не направляются), после окна
+// возвращается автоматически. Время указывается в UTC.
+type MaintenanceWindow struct {
+	// Days - дни недели начала окна ("mon", "tue", ..., "sun"). Пусто - каждый день.
+	Days []string `yaml:"days"`
+	// Start, End - начало и конец окна в формате "HH:MM". Если End раньше Start,
+	// окно переходит через полночь (например, 23:00-01:30).
+	Start string `yaml:"start"`
+	End   string `yaml:"end"`
+}
+
+// weekdays - допустимые значения MaintenanceWindow.Days
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// parseClock разбирает время "HH:MM" в минуты от начала суток
+func parseClock(value string) (int, error) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q, expected HH:MM", value)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// Validate проверяет корректность окна обслуживания
+func (w *MaintenanceWindow) Validate() error {
+	start, err := parseClock(w.Start)
+	if err != nil {
+		return fmt.Errorf("start: %w", err)
+	}
+	end, err := parseClock(w.End)
+	if err != nil {
+		return fmt.Errorf("end: %w", err)
+	}
+	if start == end {
+		return fmt.Errorf("start and end cannot be equal")
+	}
+	for _, day := range w.Days {
+		if _, ok := weekdays[strings.ToLower(day)]; !ok {
+			return fmt.Errorf("invalid day %q, expected one of: mon, tue, wed, thu, fri, sat, sun", day)
+		}
+	}
+	return nil
+}
+
+// Active сообщает, что момент t попадает в окно обслуживания
+func (w *MaintenanceWindow) Active(t time.Time) bool {
+	start, err := parseClock(w.Start)
+	if err != nil {
+		return false
+	}
+	end, err := parseClock(w.End)
+	if err != nil {
+		return false
+	}
+
+	t = t.UTC()
+	minute := t.Hour()*60 + t.Minute()
+	if start < end {
+		return w.onDay(t.Weekday()) && minute >= start && minute < end
+	}
+	// Окно через полночь: вечерняя часть относится к дню начала, утренняя - к предыдущему дню
+	if minute >= start {
+		return w.onDay(t.Weekday())
+	}
+	return minute < end && w.onDay((t.Weekday()+6)%7)
+}
+
+// onDay сообщает, что окно может начинаться в день day
+func (w *MaintenanceWindow) onDay(day time.Weekday) bool {
+	if len(w.Days) == 0 {
+		return true
+	}
+	for _, name := range w.Days {
+		if weekdays[strings.ToLower(name)] == day {
+			return true
+		}
+	}
+	return false
+}
+
+// InMaintenance сообщает, что в момент t у бэкенда идет одно из окон обслуживания
+func (bc *BackendConfig) InMaintenance(t time.Time) bool {
+	for i := range bc.MaintenanceWindows {
+		if bc.MaintenanceWindows[i].Active(t) {
+			return true
+		}
+	}
+	return false
+}
@@ -2,8 +2,10 @@ package backend
 
 import (
 	"context"
+	"crypto/tls"
 	"errors"
 	"fmt"
+	"net"
 	"net/http"
 	"strconv"
 	"strings"
@@ -14,6 +16,7 @@ import (
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/credentials"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
@@ -28,6 +31,14 @@ type Manager struct {
 	backends map[string]*Backend
 	metrics  *Metrics // Для экспорта метрик состояния
 
+	// now возвращает текущее время для проверки окон обслуживания (подменяется в тестах)
+	now func() time.Time
+
+	// Подписчики на смену состояния бэкендов (см. Subscribe)
+	subMu       sync.Mutex
+	subscribers []chan BackendStateChange
+	stateLog    <-chan BackendStateChange // Подписка, через которую смены состояния пишутся в лог
+
 	// Управление жизненным циклом
 	mu       sync.RWMutex
 	running  bool
@@ -57,6 +68,7 @@ func NewManager(cfg *Config) (*Manager, error) {
 		backends: make(map[string]*Backend),
 		metrics:  NewMetrics(),
 		stopChan: make(chan struct{}),
+		now:      time.Now,
 	}
 
 	// Инициализируем бэкенды
@@ -79,9 +91,18 @@ func NewManager(cfg *Config) (*Manager, error) {
 // createBackend создает и настраивает один бэкенд
 // Эта функция является методом вашей структуры Manager.
 func (m *Manager) createBackend(id string, cfg BackendConfig) (*Backend, error) {
+	tlsConfig, err := newBackendTLSConfig(cfg)
+	if err != nil {
+		return nil, fmt.Errorf("failed to configure TLS for backend %s: %w", id, err)
+	}
+	if cfg.InsecureSkipVerify {
+		logger.Warn("Backend '%s': TLS certificate verification is disabled (insecure_skip_verify)", id)
+	}
+
 	// ... (код создания awsConfig без изменений) ...
 	awsConfig, err := config.LoadDefaultConfig(context.Background(),
 		config.WithRegion(cfg.Region),
+		config.WithHTTPClient(newBackendHTTPClient(cfg, tlsConfig)),
 		config.WithCredentialsProvider(credentials.NewStaticCredentialsProvider(
 			cfg.AccessKey,
 			cfg.SecretKey,
@@ -92,13 +113,29 @@ func (m *Manager) createBackend(id string, cfg BackendConfig) (*Backend, error)
 		return nil, fmt.Errorf("failed to load AWS config for backend %s: %w", id, err)
 	}
 
+	// Опции, общие для основного и потокового клиентов бэкенда: число попыток запроса,
+	// передача идентификатора запроса, спаны трассировки и ограничитель частоты (один на бэкенд)
+	sharedOptions := []func(*s3.Options){func(o *s3.Options) {
+		if cfg.MaxAttempts > 0 {
+			o.RetryMaxAttempts = cfg.MaxAttempts
+		}
+		o.APIOptions = append(o.APIOptions, requestIDMiddleware(id, m.config.RequestIDHeader), tracingMiddleware(id))
+	}}
+	if limiter := newRateLimiter(cfg); limiter != nil {
+		logger.Info("Backend '%s': rate limited to %.2f requests/s", id, cfg.MaxRequestsPerSecond)
+		sharedOptions = append(sharedOptions, func(o *s3.Options) {
+			o.APIOptions = append(o.APIOptions, rateLimitMiddleware(id, limiter, m.metrics))
+		})
+	}
+
 	// --- Создаем основной S3 клиент ---
-	defaultS3Client := s3.NewFromConfig(awsConfig, func(o *s3.Options) {
+	defaultOptions := append([]func(*s3.Options){func(o *s3.Options) {
 		o.UsePathStyle = true
 		if cfg.Endpoint != "" {
 			o.BaseEndpoint = aws.String(cfg.Endpoint)
 		}
-	})
+	}}, sharedOptions...)
+	defaultS3Client := s3.NewFromConfig(awsConfig, defaultOptions...)
 	// !!! НОВЫЙ ЛОГ !!!
 	logger.Debug("Backend '%s': created default S3 client at address [%p]", id, defaultS3Client)
 
@@ -114,24 +151,90 @@ func (m *Manager) createBackend(id string, cfg BackendConfig) (*Backend, error)
 	isHttp := cfg.Endpoint != "" && strings.HasPrefix(strings.ToLower(cfg.Endpoint), "http://")
 	if isHttp {
 		logger.Warn("Backend '%s' uses HTTP. Creating a special streaming client for PutObject.", id)
-		streamingS3Client := s3.NewFromConfig(awsConfig, func(o *s3.Options) {
+		streamingOptions := append([]func(*s3.Options){func(o *s3.Options) {
 			o.UsePathStyle = true
 			if cfg.Endpoint != "" {
 				o.BaseEndpoint = aws.String(cfg.Endpoint)
 			}
+			if cfg.StreamingSigningRegion != "" {
+				// Эндпоинт задан явно, поэтому регион влияет только на подпись
+				o.Region = cfg.StreamingSigningRegion
+			}
 			o.RequestChecksumCalculation = aws.RequestChecksumCalculationWhenRequired
 			// Удаляем middleware для вычисления SHA256. Это заставит SDK использовать UNSIGNED-PAYLOAD.
 			o.APIOptions = append(o.APIOptions, func(stack *middleware.Stack) error {
 				return v4.RemoveComputePayloadSHA256Middleware(stack)
 			})
-		})
+		}}, sharedOptions...)
+		streamingS3Client := s3.NewFromConfig(awsConfig, streamingOptions...)
 		backend.StreamingPutClient = streamingS3Client
 	}
 
+	if m.config.DedicatedHealthCheckClient {
+		backend.HealthCheckClient = m.newHealthCheckClient(awsConfig, cfg, tlsConfig)
+	}
+
 	logger.Info("Created backend '%s' (Endpoint: %s, Bucket: %s) with initial state %s", id, cfg.Endpoint, cfg.Bucket, backend.state)
 	return backend, nil
 }
 
+// newBackendHTTPClient создает HTTP клиент бэкенда с настройками транспорта из конфигурации.
+// Незаданные (нулевые) параметры остаются значениями по умолчанию AWS SDK.
+// tlsConfig (если не nil) заменяет TLS настройки транспорта.
+func newBackendHTTPClient(cfg BackendConfig, tlsConfig *tls.Config) *awshttp.BuildableClient {
+	return awshttp.NewBuildableClient().
+		WithTransportOptions(func(t *http.Transport) {
+			if tlsConfig != nil {
+				t.TLSClientConfig = tlsConfig.Clone()
+			}
+			if cfg.MaxIdleConns > 0 {
+				t.MaxIdleConns = cfg.MaxIdleConns
+			}
+			if cfg.MaxIdleConnsPerHost > 0 {
+				t.MaxIdleConnsPerHost = cfg.MaxIdleConnsPerHost
+			}
+			if cfg.IdleConnTimeout > 0 {
+				t.IdleConnTimeout = cfg.IdleConnTimeout
+			}
+			if cfg.TLSHandshakeTimeout > 0 {
+				t.TLSHandshakeTimeout = cfg.TLSHandshakeTimeout
+			}
+		}).
+		WithDialerOptions(func(d *net.Dialer) {
+			if cfg.DialTimeout > 0 {
+				d.Timeout = cfg.DialTimeout
+			}
+		})
+}
+
+// healthCheckAppID - идентификатор в User-Agent запросов отдельного клиента проверок,
+// по нему проверки можно отличить от запросов данных в логах бэкенда
+const healthCheckAppID = "s3proxy-health-check"
+
+// newHealthCheckClient создает S3 клиент для активных проверок со своим транспортом:
+// не больше двух соединений к бэкенду, без повторов, с таймаутом CheckTimeout
+func (m *Manager) newHealthCheckClient(awsConfig aws.Config, cfg BackendConfig, tlsConfig *tls.Config) *s3.Client {
+	httpClient := awshttp.NewBuildableClient().
+		WithTimeout(m.config.CheckTimeout).
+		WithTransportOptions(func(t *http.Transport) {
+			if tlsConfig != nil {
+				t.TLSClientConfig = tlsConfig.Clone()
+			}
+			t.MaxIdleConnsPerHost = 1
+			t.MaxConnsPerHost = 2
+		})
+
+	return s3.NewFromConfig(awsConfig, func(o *s3.Options) {
+		o.UsePathStyle = true
+		if cfg.Endpoint != "" {
+			o.BaseEndpoint = aws.String(cfg.Endpoint)
+		}
+		o.HTTPClient = httpClient
+		o.RetryMaxAttempts = 1
+		o.AppID = healthCheckAppID
+	})
+}
+
 // Start запускает менеджер бэкендов
 func (m *Manager) Start() error {
 	m.mu.Lock()
@@ -143,9 +246,14 @@ func (m *Manager) Start() error {
 
 	logger.Info("Starting backend manager...")
 
+	// Смены состояния логируются в одном месте - подписчиком событий
+	m.stateLog = m.Subscribe()
+	m.wg.Add(1)
+	go m.logStateChanges(m.stateLog)
+
 	// Запускаем горутину для активных проверок здоровья
 	m.wg.Add(1)
-	go m.runHealthChecks()
+	go m.runHealthChecks(m.stopChan)
 
 	m.running = true
 	logger.Info("Backend manager started")
@@ -156,24 +264,24 @@ func (m *Manager) Start() error {
 // Stop останавливает менеджер бэкендов
 func (m *Manager) Stop() error {
 	m.mu.Lock()
-	defer m.mu.Unlock()
 
 	if !m.running {
+		m.mu.Unlock()
 		return nil
 	}
 
 	logger.Info("Stopping backend manager...")
 
-	// Сигнализируем о остановке
+	// Сигнализируем о остановке и создаем новый канал для возможного повторного запуска
 	close(m.stopChan)
+	m.stopChan = make(chan struct{})
+	m.running = false
+	m.Unsubscribe(m.stateLog)
+	m.mu.Unlock()
 
-	// Ждем завершения всех горутин
+	// Ждем завершения всех горутин без блокировки: проверки здоровья берут m.mu на чтение
 	m.wg.Wait()
 
-	// Создаем новый канал для возможного повторного запуска
-	m.stopChan = make(chan struct{})
-
-	m.running = false
 	logger.Info("Backend manager stopped")
 
 	return nil
@@ -186,16 +294,29 @@ func (m *Manager) IsRunning() bool {
 	return m.running
 }
 
-// GetLiveBackends возвращает список работоспособных бэкендов
+// GetLiveBackends возвращает список работоспособных бэкендов (в состоянии UP и вне окон обслуживания).
+// Бэкенды в состоянии PROBING включаются, только если им удалось выдать слот ProbeBudget.
 func (m *Manager) GetLiveBackends() []*Backend {
 	m.mu.RLock()
 	defer m.mu.RUnlock()
 
+	now := m.now()
 	var liveBackends []*Backend
 	for _, backend := range m.backends {
-		state := backend.GetState()
-		if state == StateUp {
+		// Во время окна обслуживания бэкенд не получает новых запросов
+		if backend.Config.InMaintenance(now) {
+			logger.Debug("GetLiveBackends: backend %s is in maintenance window, skipping", backend.ID)
+			continue
+		}
+		switch backend.GetState() {
+		case StateUp:
 			liveBackends = append(liveBackends, backend)
+		case StateProbing:
+			// Восстанавливающийся бэкенд получает не больше ProbeBudget запросов одновременно
+			if m.config.ProbeBudget > 0 && backend.acquireProbeSlot(m.config.ProbeBudget, now) {
+				logger.Debug("GetLiveBackends: backend %s is probing, granted a probe slot", backend.ID)
+				liveBackends = append(liveBackends, backend)
+			}
 		}
 	}
 
@@ -203,6 +324,54 @@ func (m *Manager) GetLiveBackends() []*Backend {
 	return liveBackends
 }
 
+// GetReadBackends возвращает живые бэкенды, которым можно отдавать чтения: как GetLiveBackends,
+// но без бэкендов, недавно вернувшихся в UP и еще не прошедших прогрев ReadWarmup.
+// Если прогрев проходят все живые бэкенды, они возвращаются как есть: лучше прочитать
+// с прогревающегося бэкенда, чем ответить 503.
+func (m *Manager) GetReadBackends() []*Backend {
+	live := m.GetLiveBackends()
+	if m.config.ReadWarmup <= 0 {
+		return live
+	}
+
+	now := m.now()
+	readable := make([]*Backend, 0, len(live))
+	for _, backend := range live {
+		if backend.warmingUp(now, m.config.ReadWarmup) {
+			logger.Debug("GetReadBackends: backend %s is warming up, skipping for reads", backend.ID)
+			continue
+		}
+		readable = append(readable, backend)
+	}
+	if len(readable) == 0 {
+		return live
+	}
+	return readable
+}
+
+// warmingUp сообщает, что бэкенд вернулся в UP меньше warmup назад
+func (b *Backend) warmingUp(now time.Time, warmup time.Duration) bool {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.state == StateUp && !b.upSince.IsZero() && now.Sub(b.upSince) < warmup
+}
+
+// HasLiveBackends сообщает, что хотя бы один бэкенд в состоянии UP и вне окна обслуживания.
+// В отличие от GetLiveBackends не занимает слоты ProbeBudget, поэтому подходит для проверок
+// готовности, после которых не вызывается ReportSuccess/ReportFailure.
+func (m *Manager) HasLiveBackends() bool {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	now := m.now()
+	for _, backend := range m.backends {
+		if backend.GetState() == StateUp && !backend.Config.InMaintenance(now) {
+			return true
+		}
+	}
+	return false
+}
+
 // GetAllBackends возвращает список всех бэкендов
 func (m *Manager) GetAllBackends() []*Backend {
 	m.mu.RLock()
@@ -237,24 +406,43 @@ func isBenignError(err error) bool {
 		return true
 	}
 
-	// 2. Идиоматическая проверка на 404 Not Found для AWS SDK v2.
+	// 2. 404 Not Found означает, что бэкенд ответил корректно.
+	if isNotFoundError(err) {
+		return true
+	}
+
+	// Все остальные ошибки (5xx, 403 Forbidden, сетевые проблемы) считаются критическими.
+	return false
+}
+
+// isForbiddenError возвращает true, если ошибка означает ответ 403 Forbidden
+func isForbiddenError(err error) bool {
+	var httpErr interface{ HTTPStatusCode() int }
+	return errors.As(err, &httpErr) && httpErr.HTTPStatusCode() == http.StatusForbidden
+}
+
+// isNotFoundError возвращает true, если ошибка означает ответ 404 Not Found
+func isNotFoundError(err error) bool {
+	// Идиоматическая проверка на 404 Not Found для AWS SDK v2.
 	// Это самый надежный способ.
 	var notFoundError *types.NotFound
 	if errors.As(err, &notFoundError) {
-		return true // Это точно 404 Not Found, ошибка безопасна.
+		return true
+	}
+
+	var noSuchKeyError *types.NoSuchKey
+	if errors.As(err, &noSuchKeyError) {
+		return true
 	}
 
-	// 3. Общий fallback: проверяем всю цепочку на наличие любого типа,
+	// Общий fallback: проверяем всю цепочку на наличие любого типа,
 	// который может сообщить HTTP-код. Это делает функцию устойчивой
 	// к другим типам ошибок, которые могут вернуть 404.
 	var httpErr interface{ HTTPStatusCode() int }
 	if errors.As(err, &httpErr) {
-		if httpErr.HTTPStatusCode() == http.StatusNotFound {
-			return true
-		}
+		return httpErr.HTTPStatusCode() == http.StatusNotFound
 	}
 
-	// Все остальные ошибки (5xx, 403 Forbidden, сетевые проблемы) считаются критическими.
 	return false
 }
 
@@ -277,23 +465,49 @@ func (m *Manager) ReportSuccess(result *BackendResult) {
 	backend.consecutiveFailures = 0
 	backend.consecutiveSuccesses++
 	backend.recentFailures = 0 // Успех сбрасывает окно Circuit Breaker
+	updateAverageLatency(backend, result.Duration)
+	backend.releaseProbeSlot()
 
 	// Если бэкенд был отключен, успешный запрос возвращает его в строй.
-	if backend.state == StateDown {
+	switch backend.state {
+	case StateDown:
 		logger.Info("Backend '%s' is back online after a successful request.", result.BackendID)
 		setBackendState(m, backend, StateUp)
+	case StateProbing:
+		// Успехи запросов, пропущенных через ProbeBudget, засчитываются как успешные проверки
+		if m.config.ProbeBudget > 0 && backend.consecutiveSuccesses >= m.config.SuccessThreshold {
+			logger.Info("Backend '%s' transitioned from PROBING to UP after %d consecutive successes",
+				result.BackendID, backend.consecutiveSuccesses)
+			setBackendState(m, backend, StateUp)
+		}
 	}
 
 	logger.Debug("ReportSuccess: backend '%s', consecutive successes: %d",
 		result.BackendID, backend.consecutiveSuccesses)
 
 	// Обновляем метрики
-	m.metrics.BackendRequestsTotal.WithLabelValues(result.BackendID, result.Method, strconv.Itoa(result.StatusCode)).Inc()
+	m.metrics.BackendRequestsTotal.WithLabelValues(result.BackendID, result.Method, m.statusCodeLabel(result.StatusCode)).Inc()
 	m.metrics.BackendLatency.WithLabelValues(result.BackendID, result.Method).Observe(float64(result.Duration.Seconds()))
 	m.metrics.BackendBytesRead.WithLabelValues(result.BackendID).Add(float64(result.BytesRead))
 	m.metrics.BackendBytesWrite.WithLabelValues(result.BackendID).Add(float64(result.BytesWritten))
 }
 
+// latencySmoothing - вес нового измерения в сглаженной задержке бэкенда
+const latencySmoothing = 0.2
+
+// updateAverageLatency обновляет сглаженную задержку бэкенда.
+// Вызывающий должен удерживать backend.mu.
+func updateAverageLatency(backend *Backend, latency time.Duration) {
+	if latency <= 0 {
+		return
+	}
+	if backend.averageLatency == 0 {
+		backend.averageLatency = latency
+		return
+	}
+	backend.averageLatency += time.Duration(latencySmoothing * float64(latency-backend.averageLatency))
+}
+
 // ReportFailure сообщает о неудачной операции, учитывая тип ошибки.
 func (m *Manager) ReportFailure(result *BackendResult) {
 	m.mu.RLock()
@@ -305,13 +519,20 @@ func (m *Manager) ReportFailure(result *BackendResult) {
 		return
 	}
 
+	if result.BackendRequestID == "" {
+		result.BackendRequestID = BackendRequestID(result.Err)
+	}
+
 	// --- Новая логика классификации ошибки ---
 	if isBenignError(result.Err) {
 		// Это "безопасная" ошибка. Мы логируем ее, но не наказываем бэкенд.
 		logger.Debug("ReportFailure: Benign error on backend '%s', not affecting circuit breaker. Error: %v",
 			result.BackendID, result.Err)
+		backend.mu.Lock()
+		backend.releaseProbeSlot()
+		backend.mu.Unlock()
 		// Все равно обновляем метрики, так как запрос был
-		m.metrics.BackendRequestsTotal.WithLabelValues(result.BackendID, result.Method, strconv.Itoa(result.StatusCode)).Inc()
+		m.metrics.BackendRequestsTotal.WithLabelValues(result.BackendID, result.Method, m.statusCodeLabel(result.StatusCode)).Inc()
 		m.metrics.BackendLatency.WithLabelValues(result.BackendID, result.Method).Observe(float64(result.Duration.Seconds()))
 		return // ВАЖНО: выходим, не трогая счетчики Circuit Breaker
 	}
@@ -322,7 +543,8 @@ func (m *Manager) ReportFailure(result *BackendResult) {
 
 	backend.consecutiveSuccesses = 0
 	backend.consecutiveFailures++
-	backend.lastError = result.Err
+	setLastError(m, backend, result.Err)
+	backend.releaseProbeSlot()
 
 	// Обновляем окно Circuit Breaker
 	now := time.Now()
@@ -333,25 +555,41 @@ func (m *Manager) ReportFailure(result *BackendResult) {
 		backend.recentFailures++
 	}
 
-	logger.Warn("ReportFailure: Critical failure on backend '%s', consecutive: %d, recent: %d. Error: %v",
-		result.BackendID, backend.consecutiveFailures, backend.recentFailures, result.Err)
+	logger.Warn("ReportFailure: Critical failure on backend '%s', consecutive: %d, recent: %d, backend request id: %q. Error: %v",
+		result.BackendID, backend.consecutiveFailures, backend.recentFailures, result.BackendRequestID, result.Err)
+
+	// Восстанавливающийся бэкенд возвращается в DOWN при первой ошибке клиентского запроса
+	if m.config.ProbeBudget > 0 && backend.state == StateProbing {
+		logger.Warn("Backend '%s' transitioned from PROBING to DOWN after a failed request", result.BackendID)
+		setBackendState(m, backend, StateDown)
+	}
 
 	// Проверяем, не пора ли отключить бэкенд
-	if backend.state != StateDown && backend.recentFailures >= m.config.CircuitBreakerThreshold {
+	if backend.state != StateDown && backend.state != StateUnauthorized && backend.recentFailures >= m.config.CircuitBreakerThreshold {
 		logger.Error("Circuit breaker triggered for backend '%s': %d failures in %v. Setting state to DOWN.",
 			result.BackendID, backend.recentFailures, now.Sub(backend.windowStart))
 		setBackendState(m, backend, StateDown)
 	}
 
 	// Обновляем метрики
-	m.metrics.BackendRequestsTotal.WithLabelValues(result.BackendID, result.Method, strconv.Itoa(result.StatusCode)).Inc()
+	m.metrics.BackendRequestsTotal.WithLabelValues(result.BackendID, result.Method, m.statusCodeLabel(result.StatusCode)).Inc()
 	m.metrics.BackendLatency.WithLabelValues(result.BackendID, result.Method).Observe(float64(result.Duration.Seconds()))
 	m.metrics.BackendBytesRead.WithLabelValues(result.BackendID).Add(float64(result.BytesRead))
 	m.metrics.BackendBytesWrite.WithLabelValues(result.BackendID).Add(float64(result.BytesWritten))
 }
 
+// statusCodeLabel возвращает значение метки code для метрики запросов к бэкенду:
+// код ответа или, при включенном StatusCodeClasses, его класс (2xx, 4xx, 5xx).
+// Неизвестный код (0 - ответа не было) записывается как есть.
+func (m *Manager) statusCodeLabel(statusCode int) string {
+	if m.config.StatusCodeClasses && statusCode >= 100 && statusCode < 600 {
+		return strconv.Itoa(statusCode/100) + "xx"
+	}
+	return strconv.Itoa(statusCode)
+}
+
 // runHealthChecks выполняет активные проверки здоровья в фоновом режиме
-func (m *Manager) runHealthChecks() {
+func (m *Manager) runHealthChecks(stopChan <-chan struct{}) {
 	defer m.wg.Done()
 
 	ticker := time.NewTicker(m.config.HealthCheckInterval)
@@ -365,7 +603,7 @@ func (m *Manager) runHealthChecks() {
 		select {
 		case <-ticker.C:
 			m.performHealthChecks()
-		case <-m.stopChan:
+		case <-stopChan:
 			logger.Debug("Health check routine stopped")
 			return
 		}
@@ -404,27 +642,38 @@ func (m *Manager) checkBackend(backend *Backend) {
 
 	logger.Debug("Checking backend %s (state: %s)", backend.ID, backend.GetState())
 
-	// Выполняем легковесную проверку - HeadBucket
-	_, err := backend.S3Client.HeadBucket(ctx, &s3.HeadBucketInput{
-		Bucket: aws.String(backend.Config.Bucket),
-	})
+	err := m.probeBackend(ctx, backend)
 
 	// Обновляем состояние бэкенда
 	backend.mu.Lock()
 	defer backend.mu.Unlock()
 
 	backend.lastCheckTime = time.Now()
-	oldState := backend.state
 
 	if err != nil {
 		// Неудачная проверка
-		backend.lastError = err
+		setLastError(m, backend, err)
 		backend.consecutiveSuccesses = 0
 		backend.consecutiveFailures++
+		if isForbiddenError(err) {
+			backend.consecutiveForbidden++
+		} else {
+			backend.consecutiveForbidden = 0
+		}
 
 		logger.Debug("Backend %s health check failed: %v (consecutive failures: %d)",
 			backend.ID, err, backend.consecutiveFailures)
 
+		// Бэкенд стабильно отвечает 403: проблема с ключами или правами, а не недоступность
+		if m.config.ForbiddenAsUnauthorized && backend.consecutiveForbidden >= m.config.FailureThreshold {
+			if backend.state != StateUnauthorized {
+				logger.Warn("Backend %s transitioned from %s to UNAUTHORIZED after %d consecutive 403 responses to health checks",
+					backend.ID, backend.state, backend.consecutiveForbidden)
+				setBackendState(m, backend, StateUnauthorized)
+			}
+			return
+		}
+
 		// Логика переходов состояний при неудаче
 		switch backend.state {
 		case StateUp:
@@ -437,13 +686,18 @@ func (m *Manager) checkBackend(backend *Backend) {
 			// Из PROBING сразу в DOWN при любой неудаче
 			setBackendState(m, backend, StateDown)
 			logger.Warn("Backend %s transitioned from PROBING to DOWN after health check failure", backend.ID)
+		case StateUnauthorized:
+			// Бэкенд перестал отвечать 403, но по-прежнему не работает
+			setBackendState(m, backend, StateDown)
+			logger.Warn("Backend %s transitioned from UNAUTHORIZED to DOWN after health check failure", backend.ID)
 		case StateDown:
 			// Остаемся в DOWN
 		}
 	} else {
 		// Успешная проверка
-		backend.lastError = nil
+		setLastError(m, backend, nil)
 		backend.consecutiveFailures = 0
+		backend.consecutiveForbidden = 0
 		backend.consecutiveSuccesses++
 
 		logger.Debug("Backend %s health check succeeded (consecutive successes: %d)",
@@ -451,10 +705,10 @@ func (m *Manager) checkBackend(backend *Backend) {
 
 		// Логика переходов состояний при успехе
 		switch backend.state {
-		case StateDown:
-			// Из DOWN в PROBING при первом успехе
+		case StateDown, StateUnauthorized:
+			// Из DOWN (и UNAUTHORIZED) в PROBING при первом успехе
+			logger.Info("Backend %s transitioned from %s to PROBING after successful health check", backend.ID, backend.state)
 			setBackendState(m, backend, StateProbing)
-			logger.Info("Backend %s transitioned from DOWN to PROBING after successful health check", backend.ID)
 		case StateProbing:
 			if backend.consecutiveSuccesses >= m.config.SuccessThreshold {
 				setBackendState(m, backend, StateUp)
@@ -466,13 +720,49 @@ func (m *Manager) checkBackend(backend *Backend) {
 		}
 	}
 
-	// Логируем изменения состояния
-	if oldState != backend.state {
-		logger.Info("Backend %s state changed: %s -> %s", backend.ID, oldState, backend.state)
+}
+
+// probeBackend выполняет один запрос активной проверки.
+// По умолчанию это легковесный HeadBucket. Если задан HealthCheckKey, выполняется
+// HeadObject на этот ключ: так проверяется, что бэкенд действительно обслуживает
+// объекты, а не только отвечает на запросы к бакету. Ответы 200 и 404 считаются
+// успехом (хранилище объектов ответило), сетевые ошибки и 5xx - неудачей.
+func (m *Manager) probeBackend(ctx context.Context, backend *Backend) error {
+	client := backend.S3Client
+	if backend.HealthCheckClient != nil {
+		client = backend.HealthCheckClient
+	}
+	// Проверки не ждут токен ограничителя частоты и не расходуют его
+	ctx = withoutRateLimit(ctx)
+
+	if backend.Config.HealthCheckKey == "" {
+		_, err := client.HeadBucket(ctx, &s3.HeadBucketInput{
+			Bucket: aws.String(backend.Config.Bucket),
+		})
+		return err
+	}
+
+	_, err := client.HeadObject(ctx, &s3.HeadObjectInput{
+		Bucket: aws.String(backend.Config.Bucket),
+		Key:    aws.String(backend.Config.HealthCheckKey),
+	})
+	if err != nil && isNotFoundError(err) {
+		logger.Debug("Backend %s: health check key '%s' not found, treating as healthy",
+			backend.ID, backend.Config.HealthCheckKey)
+		return nil
 	}
+	return err
 }
 
 func setBackendState(m *Manager, backend *Backend, state BackendState) {
+	old := backend.state
 	backend.state = state
+	backend.probeLeases = nil // Слоты действуют только в пределах одного периода PROBING
+	if state == StateUp && old != StateUp {
+		backend.upSince = m.now()
+	}
 	m.metrics.BackendState.WithLabelValues(backend.ID).Set(backend.state.ToFloat64())
+	if old != state {
+		m.publishStateChange(BackendStateChange{BackendID: backend.ID, Old: old, New: state, Time: time.Now()})
+	}
 }
@@ -1,11 +1,77 @@
 package backend
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
 	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
 	"testing"
 	"time"
+
+	"s3proxy/apigw"
+	"s3proxy/internal/s3mock"
+	"s3proxy/logger"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 )
 
+// DefaultConfig возвращает тестовую конфигурацию с одним локальным бэкендом
+func DefaultConfig() *Config {
+	return &Config{
+		Manager: DefaultManagerConfig(),
+		Backends: map[string]BackendConfig{
+			"local-minio": {
+				Endpoint:  "http://localhost:9000",
+				Region:    "us-east-1",
+				Bucket:    "test-bucket",
+				AccessKey: "minioadmin",
+				SecretKey: "minioadmin",
+			},
+		},
+	}
+}
+
+// mockBackendConfig возвращает конфигурацию бэкенда, указывающую на srv, без повторов SDK
+// (аналог backendtest.BackendConfig, который пакет backend импортировать не может)
+func mockBackendConfig(srv *s3mock.Server) BackendConfig {
+	return BackendConfig{
+		Endpoint:    srv.URL,
+		Region:      "us-east-1",
+		Bucket:      srv.Bucket,
+		AccessKey:   "mock-access-key",
+		SecretKey:   "mock-secret-key",
+		MaxAttempts: 1,
+	}
+}
+
+// newMockManager создает Manager для бэкендов на s3mock.Server (аналог backendtest.NewManager):
+// без конфигурации менеджера бэкенды стартуют в UP, повторы SDK отключены
+func newMockManager(cfg *Config) (*Manager, error) {
+	if cfg.Manager == (ManagerConfig{}) {
+		cfg.Manager = DefaultManagerConfig()
+		cfg.Manager.InitialState = StateUp
+	}
+	for id, backendConfig := range cfg.Backends {
+		if backendConfig.MaxAttempts == 0 {
+			backendConfig.MaxAttempts = 1
+			cfg.Backends[id] = backendConfig
+		}
+	}
+	return NewManager(cfg)
+}
+
 func TestDefaultConfig(t *testing.T) {
 	config := DefaultConfig()
 	
@@ -124,7 +190,7 @@ func TestBackendStateToFloat64(t *testing.T) {
 func TestNewManager(t *testing.T) {
 	config := DefaultConfig()
 	
-	manager, err := NewManager(config, nil)
+	manager, err := NewManager(config)
 	if err != nil {
 		t.Fatalf("Expected no error creating manager, got: %v", err)
 	}
@@ -150,7 +216,7 @@ func TestNewManagerWithInvalidConfig(t *testing.T) {
 		Backends: map[string]BackendConfig{},
 	}
 	
-	_, err := NewManager(invalidConfig, nil)
+	_, err := NewManager(invalidConfig)
 	if err == nil {
 		t.Error("Expected error creating manager with invalid config")
 	}
@@ -162,7 +228,7 @@ func TestManagerStartStop(t *testing.T) {
 	config.Manager.HealthCheckInterval = 100 * time.Millisecond
 	config.Manager.CheckTimeout = 50 * time.Millisecond
 	
-	manager, err := NewManager(config, nil)
+	manager, err := NewManager(config)
 	if err != nil {
 		t.Fatalf("Failed to create manager: %v", err)
 	}
@@ -202,7 +268,7 @@ func TestManagerStartStop(t *testing.T) {
 
 func TestManagerDoubleStart(t *testing.T) {
 	config := DefaultConfig()
-	manager, err := NewManager(config, nil)
+	manager, err := NewManager(config)
 	if err != nil {
 		t.Fatalf("Failed to create manager: %v", err)
 	}
@@ -243,7 +309,7 @@ func TestGetBackends(t *testing.T) {
 		},
 	}
 	
-	manager, err := NewManager(config, nil)
+	manager, err := NewManager(config)
 	if err != nil {
 		t.Fatalf("Failed to create manager: %v", err)
 	}
@@ -268,16 +334,16 @@ func TestGetBackends(t *testing.T) {
 		t.Error("Expected nonexistent backend to not exist")
 	}
 	
-	// Тестируем GetLiveBackends (все должны быть в состоянии PROBING по умолчанию)
+	// Тестируем GetLiveBackends (все в состоянии PROBING по умолчанию, живыми считаются только UP)
 	liveBackends := manager.GetLiveBackends()
-	if len(liveBackends) != 2 {
-		t.Errorf("Expected 2 live backends, got %d", len(liveBackends))
+	if len(liveBackends) != 0 {
+		t.Errorf("Expected 0 live backends, got %d", len(liveBackends))
 	}
 }
 
 func TestReportSuccessFailure(t *testing.T) {
 	config := DefaultConfig()
-	manager, err := NewManager(config, nil)
+	manager, err := NewManager(config)
 	if err != nil {
 		t.Fatalf("Failed to create manager: %v", err)
 	}
@@ -298,7 +364,7 @@ func TestReportSuccessFailure(t *testing.T) {
 	}
 	
 	// Тестируем ReportSuccess
-	manager.ReportSuccess(backendID)
+	manager.ReportSuccess(&BackendResult{BackendID: backendID})
 	
 	failures, successes, _ := backend.GetStats()
 	if failures != 0 || successes != 1 {
@@ -308,7 +374,7 @@ func TestReportSuccessFailure(t *testing.T) {
 	
 	// Тестируем ReportFailure
 	testErr := fmt.Errorf("test error")
-	manager.ReportFailure(backendID, testErr)
+	manager.ReportFailure(&BackendResult{BackendID: backendID, Err: testErr})
 	
 	failures, successes, _ = backend.GetStats()
 	if failures != 1 || successes != 0 {
@@ -321,8 +387,8 @@ func TestReportSuccessFailure(t *testing.T) {
 	}
 	
 	// Тестируем с несуществующим бэкендом
-	manager.ReportSuccess("nonexistent")
-	manager.ReportFailure("nonexistent", testErr)
+	manager.ReportSuccess(&BackendResult{BackendID: "nonexistent"})
+	manager.ReportFailure(&BackendResult{BackendID: "nonexistent", Err: testErr})
 	// Не должно паниковать
 }
 
@@ -331,7 +397,7 @@ func TestCircuitBreaker(t *testing.T) {
 	config.Manager.CircuitBreakerThreshold = 3
 	config.Manager.CircuitBreakerWindow = 1 * time.Second
 	
-	manager, err := NewManager(config, nil)
+	manager, err := NewManager(config)
 	if err != nil {
 		t.Fatalf("Failed to create manager: %v", err)
 	}
@@ -348,7 +414,7 @@ func TestCircuitBreaker(t *testing.T) {
 	
 	// Отправляем несколько ошибок, но меньше порога
 	for i := 0; i < 2; i++ {
-		manager.ReportFailure(backendID, testErr)
+		manager.ReportFailure(&BackendResult{BackendID: backendID, Err: testErr})
 	}
 	
 	// Состояние должно остаться UP
@@ -357,7 +423,7 @@ func TestCircuitBreaker(t *testing.T) {
 	}
 	
 	// Отправляем еще одну ошибку - должен сработать Circuit Breaker
-	manager.ReportFailure(backendID, testErr)
+	manager.ReportFailure(&BackendResult{BackendID: backendID, Err: testErr})
 	
 	// Состояние должно стать DOWN
 	if backend.GetState() != StateDown {
@@ -367,7 +433,7 @@ func TestCircuitBreaker(t *testing.T) {
 
 func TestBackendGetters(t *testing.T) {
 	config := DefaultConfig()
-	manager, err := NewManager(config, nil)
+	manager, err := NewManager(config)
 	if err != nil {
 		t.Fatalf("Failed to create manager: %v", err)
 	}
@@ -399,3 +465,872 @@ func TestBackendGetters(t *testing.T) {
 			failures, successes, recentFailures)
 	}
 }
+
+func TestAverageLatency(t *testing.T) {
+	manager, err := NewManager(DefaultConfig())
+	if err != nil {
+		t.Fatalf("Failed to create manager: %v", err)
+	}
+
+	backendID := "local-minio"
+	backend, _ := manager.GetBackend(backendID)
+
+	if backend.GetAverageLatency() != 0 {
+		t.Errorf("Expected no latency before first request, got %v", backend.GetAverageLatency())
+	}
+
+	// Первое измерение принимается как есть
+	manager.ReportSuccess(&BackendResult{BackendID: backendID, Duration: 100 * time.Millisecond})
+	if got := backend.GetAverageLatency(); got != 100*time.Millisecond {
+		t.Errorf("Expected latency 100ms after first request, got %v", got)
+	}
+
+	// Последующие измерения сглаживаются
+	manager.ReportSuccess(&BackendResult{BackendID: backendID, Duration: 200 * time.Millisecond})
+	if got := backend.GetAverageLatency(); got != 120*time.Millisecond {
+		t.Errorf("Expected smoothed latency 120ms, got %v", got)
+	}
+
+	// Ошибки не влияют на задержку
+	manager.ReportFailure(&BackendResult{BackendID: backendID, Err: fmt.Errorf("test error"), Duration: time.Second})
+	if got := backend.GetAverageLatency(); got != 120*time.Millisecond {
+		t.Errorf("Expected latency unchanged after failure, got %v", got)
+	}
+}
+
+func TestHealthCheckKey(t *testing.T) {
+	srv := s3mock.NewServer("health-bucket")
+	defer srv.Close()
+
+	backendCfg := mockBackendConfig(srv)
+	backendCfg.HealthCheckKey = "health/probe"
+
+	config := &Config{
+		Manager: DefaultManagerConfig(),
+		Backends: map[string]BackendConfig{
+			"default": mockBackendConfig(srv),
+			"keyed":   backendCfg,
+		},
+	}
+	config.Manager.InitialState = StateUp
+	config.Manager.FailureThreshold = 1
+
+	manager, err := newMockManager(config)
+	if err != nil {
+		t.Fatalf("Failed to create manager: %v", err)
+	}
+	defaultBackend, _ := manager.GetBackend("default")
+	keyedBackend, _ := manager.GetBackend("keyed")
+
+	// Ключ отсутствует (404) - бэкенд считается здоровым
+	manager.checkBackend(keyedBackend)
+	if keyedBackend.GetState() != StateUp {
+		t.Errorf("Expected keyed backend to stay UP on 404, got %s", keyedBackend.GetState())
+	}
+
+	// Ключ существует (200)
+	srv.SetObject("health/probe", []byte("ok"), time.Time{})
+	manager.checkBackend(keyedBackend)
+	if keyedBackend.GetState() != StateUp {
+		t.Errorf("Expected keyed backend to stay UP on 200, got %s", keyedBackend.GetState())
+	}
+
+	// Хранилище объектов сломано: HeadObject возвращает 5xx, HeadBucket работает
+	srv.SetIntercept(func(w http.ResponseWriter, r *http.Request) bool {
+		if r.Method == http.MethodHead && strings.HasSuffix(r.URL.Path, "/health/probe") {
+			w.WriteHeader(http.StatusInternalServerError)
+			return true
+		}
+		return false
+	})
+	srv.ResetRequests()
+
+	manager.checkBackend(defaultBackend)
+	if defaultBackend.GetState() != StateUp {
+		t.Errorf("Expected HeadBucket-checked backend to stay UP, got %s", defaultBackend.GetState())
+	}
+
+	manager.checkBackend(keyedBackend)
+	if keyedBackend.GetState() != StateDown {
+		t.Errorf("Expected keyed backend to go DOWN on 5xx, got %s", keyedBackend.GetState())
+	}
+
+	// Проверяем, какие запросы выполнялись
+	for _, req := range srv.Requests() {
+		if req.Method != http.MethodHead {
+			t.Errorf("Expected only HEAD requests, got %s", req.Method)
+		}
+	}
+	if n := len(srv.Requests()); n != 2 {
+		t.Errorf("Expected 2 health check requests, got %d", n)
+	}
+
+	// Сетевая ошибка также считается неудачей
+	srv.SetIntercept(nil)
+	manager.checkBackend(keyedBackend)
+	if keyedBackend.GetState() != StateProbing {
+		t.Fatalf("Expected keyed backend to go PROBING after recovery, got %s", keyedBackend.GetState())
+	}
+	srv.Close()
+	manager.checkBackend(keyedBackend)
+	if keyedBackend.GetState() != StateDown {
+		t.Errorf("Expected keyed backend to go DOWN on network error, got %s", keyedBackend.GetState())
+	}
+}
+
+func TestForbiddenAsUnauthorized(t *testing.T) {
+	srv := s3mock.NewServer("forbidden-bucket")
+	defer srv.Close()
+
+	for _, tc := range []struct {
+		name     string
+		enabled  bool
+		expected BackendState
+	}{
+		{"disabled", false, StateDown},
+		{"enabled", true, StateUnauthorized},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			config := &Config{
+				Manager:  DefaultManagerConfig(),
+				Backends: map[string]BackendConfig{"forbidden": mockBackendConfig(srv)},
+			}
+			config.Manager.InitialState = StateUp
+			config.Manager.FailureThreshold = 3
+			config.Manager.ForbiddenAsUnauthorized = tc.enabled
+
+			manager, err := newMockManager(config)
+			if err != nil {
+				t.Fatalf("Failed to create manager: %v", err)
+			}
+			backend, _ := manager.GetBackend("forbidden")
+
+			srv.SetIntercept(func(w http.ResponseWriter, r *http.Request) bool {
+				w.WriteHeader(http.StatusForbidden)
+				return true
+			})
+			defer srv.SetIntercept(nil)
+
+			for i := 0; i < 2; i++ {
+				manager.checkBackend(backend)
+			}
+			if state := backend.GetState(); state != StateUp {
+				t.Fatalf("Expected backend to stay UP below failure_threshold, got %s", state)
+			}
+			for i := 0; i < 3; i++ {
+				manager.checkBackend(backend)
+			}
+			if state := backend.GetState(); state != tc.expected {
+				t.Fatalf("Expected %s after repeated 403, got %s", tc.expected, state)
+			}
+			if status := backend.Status(); status.State != string(tc.expected) {
+				t.Errorf("Expected status state %s, got %s", tc.expected, status.State)
+			}
+
+			// Circuit Breaker не переводит бэкенд с отказом в доступе в DOWN
+			for i := 0; i < config.Manager.CircuitBreakerThreshold; i++ {
+				manager.ReportFailure(&BackendResult{
+					BackendID: "forbidden", Method: "GET", StatusCode: http.StatusForbidden, Err: errors.New("forbidden"),
+				})
+			}
+			if state := backend.GetState(); state != tc.expected {
+				t.Errorf("Expected %s after request failures, got %s", tc.expected, state)
+			}
+
+			// Доступ восстановлен - бэкенд проходит обычное восстановление
+			srv.SetIntercept(nil)
+			manager.checkBackend(backend)
+			if state := backend.GetState(); state != StateProbing {
+				t.Errorf("Expected PROBING after successful health check, got %s", state)
+			}
+		})
+	}
+
+	if StateUnauthorized.ToFloat64() != -1 {
+		t.Errorf("Expected UNAUTHORIZED metric value -1, got %v", StateUnauthorized.ToFloat64())
+	}
+}
+
+func TestStatusCodeClassLabels(t *testing.T) {
+	config := DefaultConfig()
+	config.Manager.StatusCodeClasses = true
+	config.Backends = map[string]BackendConfig{
+		"status-class-backend": {
+			Endpoint:  "http://127.0.0.1:1",
+			Region:    "us-east-1",
+			Bucket:    "test-bucket",
+			AccessKey: "test",
+			SecretKey: "test",
+		},
+	}
+	manager, err := NewManager(config)
+	if err != nil {
+		t.Fatalf("Failed to create manager: %v", err)
+	}
+
+	// Метрики глобальные, поэтому проверяется прирост счетчиков (тест можно запускать с -count)
+	requests := manager.metrics.BackendRequestsTotal
+	count := func(code string) float64 {
+		return testutil.ToFloat64(requests.WithLabelValues("status-class-backend", "GET", code))
+	}
+	before2xx, before5xx, before206 := count("2xx"), count("5xx"), count("206")
+
+	manager.ReportSuccess(&BackendResult{BackendID: "status-class-backend", Method: "GET", StatusCode: http.StatusPartialContent})
+	manager.ReportFailure(&BackendResult{BackendID: "status-class-backend", Method: "GET", StatusCode: http.StatusBadGateway, Err: fmt.Errorf("bad gateway")})
+
+	if got := count("2xx") - before2xx; got != 1 {
+		t.Errorf("Expected 1 request with code=2xx, got %v", got)
+	}
+	if got := count("5xx") - before5xx; got != 1 {
+		t.Errorf("Expected 1 request with code=5xx, got %v", got)
+	}
+	if got := count("206") - before206; got != 0 {
+		t.Errorf("Expected raw status code label not to be used, got %v", got)
+	}
+
+	// Без StatusCodeClasses метка содержит сам код
+	manager.config.StatusCodeClasses = false
+	if label := manager.statusCodeLabel(http.StatusPartialContent); label != "206" {
+		t.Errorf("Expected raw code label 206, got %q", label)
+	}
+}
+
+func TestStreamingSigningRegion(t *testing.T) {
+	server := s3mock.NewServer("test-bucket")
+	defer server.Close()
+
+	backendConfig := mockBackendConfig(server)
+	backendConfig.StreamingSigningRegion = "eu-west-2"
+	manager, err := newMockManager(&Config{Backends: map[string]BackendConfig{"backend-1": backendConfig}})
+	if err != nil {
+		t.Fatalf("Failed to create manager: %v", err)
+	}
+	b, _ := manager.GetBackend("backend-1")
+	if b.StreamingPutClient == nil {
+		t.Fatal("Expected streaming client for HTTP backend")
+	}
+
+	_, err = b.StreamingPutClient.PutObject(context.Background(), &s3.PutObjectInput{
+		Bucket: aws.String("test-bucket"),
+		Key:    aws.String("signed-key"),
+		Body:   strings.NewReader("data"),
+	})
+	if err != nil {
+		t.Fatalf("PutObject failed: %v", err)
+	}
+	if _, err := b.S3Client.HeadBucket(context.Background(), &s3.HeadBucketInput{Bucket: aws.String("test-bucket")}); err != nil {
+		t.Fatalf("HeadBucket failed: %v", err)
+	}
+
+	var putAuth, headAuth string
+	for _, req := range server.Requests() {
+		switch req.Method {
+		case http.MethodPut:
+			putAuth = req.Header.Get("Authorization")
+		case http.MethodHead:
+			headAuth = req.Header.Get("Authorization")
+		}
+	}
+	if !strings.Contains(putAuth, "/eu-west-2/s3/aws4_request") {
+		t.Errorf("Expected streaming PUT signed for eu-west-2, got %q", putAuth)
+	}
+	// Основной клиент подписывает регионом бэкенда
+	if region := "/" + backendConfig.Region + "/s3/aws4_request"; !strings.Contains(headAuth, region) {
+		t.Errorf("Expected default client signed for %s, got %q", backendConfig.Region, headAuth)
+	}
+}
+
+func TestDedicatedHealthCheckClient(t *testing.T) {
+	srv := s3mock.NewServer("health-bucket")
+	defer srv.Close()
+
+	config := &Config{
+		Manager:  DefaultManagerConfig(),
+		Backends: map[string]BackendConfig{"backend-1": mockBackendConfig(srv)},
+	}
+	config.Manager.InitialState = StateUp
+	config.Manager.DedicatedHealthCheckClient = true
+
+	manager, err := newMockManager(config)
+	if err != nil {
+		t.Fatalf("Failed to create manager: %v", err)
+	}
+	b, _ := manager.GetBackend("backend-1")
+	if b.HealthCheckClient == nil || b.HealthCheckClient == b.S3Client {
+		t.Fatal("Expected a dedicated health check client")
+	}
+
+	manager.checkBackend(b)
+	if _, err := b.S3Client.HeadBucket(context.Background(), &s3.HeadBucketInput{Bucket: aws.String("health-bucket")}); err != nil {
+		t.Fatalf("HeadBucket failed: %v", err)
+	}
+
+	requests := srv.Requests()
+	if len(requests) != 2 {
+		t.Fatalf("Expected 2 requests, got %d", len(requests))
+	}
+	// Проверка идет через отдельный клиент, запрос данных - через основной
+	if ua := requests[0].Header.Get("User-Agent"); !strings.Contains(ua, "app/"+healthCheckAppID) {
+		t.Errorf("Expected health check from dedicated client, got User-Agent %q", ua)
+	}
+	if ua := requests[1].Header.Get("User-Agent"); strings.Contains(ua, healthCheckAppID) {
+		t.Errorf("Expected data request from default client, got User-Agent %q", ua)
+	}
+}
+
+func TestBackendTransportSettings(t *testing.T) {
+	srv := s3mock.NewServer("test-bucket")
+	defer srv.Close()
+
+	tuned := mockBackendConfig(srv)
+	tuned.MaxIdleConns = 300
+	tuned.MaxIdleConnsPerHost = 64
+	tuned.IdleConnTimeout = 45 * time.Second
+	tuned.DialTimeout = 3 * time.Second
+	tuned.TLSHandshakeTimeout = 4 * time.Second
+
+	manager, err := newMockManager(&Config{Backends: map[string]BackendConfig{
+		"tuned":   tuned,
+		"default": mockBackendConfig(srv),
+	}})
+	if err != nil {
+		t.Fatalf("Failed to create manager: %v", err)
+	}
+
+	transportOf := func(id string) (*http.Transport, *net.Dialer) {
+		b, _ := manager.GetBackend(id)
+		client, ok := b.S3Client.Options().HTTPClient.(*awshttp.BuildableClient)
+		if !ok {
+			t.Fatalf("Expected BuildableClient for backend %s, got %T", id, b.S3Client.Options().HTTPClient)
+		}
+		return client.GetTransport(), client.GetDialer()
+	}
+
+	transport, dialer := transportOf("tuned")
+	if transport.MaxIdleConns != 300 || transport.MaxIdleConnsPerHost != 64 {
+		t.Errorf("Expected idle conns 300/64, got %d/%d", transport.MaxIdleConns, transport.MaxIdleConnsPerHost)
+	}
+	if transport.IdleConnTimeout != 45*time.Second || transport.TLSHandshakeTimeout != 4*time.Second {
+		t.Errorf("Expected timeouts 45s/4s, got %v/%v", transport.IdleConnTimeout, transport.TLSHandshakeTimeout)
+	}
+	if dialer.Timeout != 3*time.Second {
+		t.Errorf("Expected dial timeout 3s, got %v", dialer.Timeout)
+	}
+
+	// Без настроек используются значения AWS SDK по умолчанию
+	defaults := awshttp.NewBuildableClient()
+	transport, dialer = transportOf("default")
+	if transport.MaxIdleConnsPerHost != defaults.GetTransport().MaxIdleConnsPerHost ||
+		transport.IdleConnTimeout != defaults.GetTransport().IdleConnTimeout ||
+		dialer.Timeout != defaults.GetDialer().Timeout {
+		t.Error("Expected AWS SDK default transport settings for backend without overrides")
+	}
+}
+
+func TestMaintenanceWindows(t *testing.T) {
+	server1 := s3mock.NewServer("test-bucket")
+	defer server1.Close()
+	server2 := s3mock.NewServer("test-bucket")
+	defer server2.Close()
+
+	maintained := mockBackendConfig(server1)
+	maintained.MaintenanceWindows = []MaintenanceWindow{
+		{Days: []string{"sat"}, Start: "23:00", End: "01:30"},
+		{Start: "12:00", End: "12:15"},
+	}
+	manager, err := newMockManager(&Config{Backends: map[string]BackendConfig{
+		"backend-1": maintained,
+		"backend-2": mockBackendConfig(server2),
+	}})
+	if err != nil {
+		t.Fatalf("Failed to create manager: %v", err)
+	}
+
+	tests := []struct {
+		name     string
+		now      time.Time
+		expected int
+	}{
+		{"Saturday night window", time.Date(2024, 6, 1, 23, 30, 0, 0, time.UTC), 1},
+		{"Window continues after midnight", time.Date(2024, 6, 2, 1, 0, 0, 0, time.UTC), 1},
+		{"After night window", time.Date(2024, 6, 2, 1, 30, 0, 0, time.UTC), 2},
+		{"Friday night is not a window", time.Date(2024, 5, 31, 23, 30, 0, 0, time.UTC), 2},
+		{"Daily window", time.Date(2024, 6, 4, 12, 5, 0, 0, time.UTC), 1},
+		{"Daily window in another time zone", time.Date(2024, 6, 4, 15, 5, 0, 0, time.FixedZone("UTC+3", 3*3600)), 1},
+		{"Outside windows", time.Date(2024, 6, 4, 12, 15, 0, 0, time.UTC), 2},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			manager.now = func() time.Time { return tt.now }
+
+			live := manager.GetLiveBackends()
+			if len(live) != tt.expected {
+				t.Fatalf("Expected %d live backends, got %d", tt.expected, len(live))
+			}
+			if tt.expected == 1 && live[0].ID != "backend-2" {
+				t.Errorf("Expected backend-1 to be excluded during maintenance, got %s", live[0].ID)
+			}
+		})
+	}
+}
+
+func TestMaintenanceWindowValidation(t *testing.T) {
+	invalid := []MaintenanceWindow{
+		{Start: "25:00", End: "01:00"},
+		{Start: "10:00", End: "10:00"},
+		{Days: []string{"someday"}, Start: "10:00", End: "11:00"},
+	}
+	for _, window := range invalid {
+		cfg := BackendConfig{Endpoint: "http://localhost", Region: "us-east-1", Bucket: "b", AccessKey: "a", SecretKey: "s",
+			MaintenanceWindows: []MaintenanceWindow{window}}
+		if err := cfg.Validate(); err == nil {
+			t.Errorf("Expected validation error for window %+v", window)
+		}
+	}
+}
+
+func TestBackendCustomCA(t *testing.T) {
+	srv := s3mock.NewTLSServer("test-bucket")
+	defer srv.Close()
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	if err := os.WriteFile(caFile, caPEM, 0600); err != nil {
+		t.Fatalf("Failed to write CA bundle: %v", err)
+	}
+
+	withCA := mockBackendConfig(srv)
+	withCA.CABundleFile = caFile
+	skipVerify := mockBackendConfig(srv)
+	skipVerify.InsecureSkipVerify = true
+
+	manager, err := newMockManager(&Config{Backends: map[string]BackendConfig{
+		"with-ca":     withCA,
+		"skip-verify": skipVerify,
+		"default":     mockBackendConfig(srv),
+	}})
+	if err != nil {
+		t.Fatalf("Failed to create manager: %v", err)
+	}
+
+	headBucket := func(id string) error {
+		b, _ := manager.GetBackend(id)
+		_, err := b.S3Client.HeadBucket(context.Background(), &s3.HeadBucketInput{Bucket: aws.String("test-bucket")})
+		return err
+	}
+
+	if err := headBucket("with-ca"); err != nil {
+		t.Errorf("Expected backend with custom CA to verify the server certificate, got %v", err)
+	}
+	if err := headBucket("skip-verify"); err != nil {
+		t.Errorf("Expected backend with insecure_skip_verify to connect, got %v", err)
+	}
+	if err := headBucket("default"); err == nil {
+		t.Error("Expected certificate verification error without custom CA")
+	}
+}
+
+func TestBackendCABundleValidation(t *testing.T) {
+	cfg := BackendConfig{Endpoint: "https://localhost", Region: "us-east-1", Bucket: "b", AccessKey: "a", SecretKey: "s",
+		CABundleFile: filepath.Join(t.TempDir(), "missing.pem")}
+	if err := cfg.Validate(); err == nil {
+		t.Error("Expected validation error for missing ca_bundle_file")
+	}
+
+	notPEM := filepath.Join(t.TempDir(), "not-pem.txt")
+	if err := os.WriteFile(notPEM, []byte("not a certificate"), 0600); err != nil {
+		t.Fatalf("Failed to write file: %v", err)
+	}
+	cfg.CABundleFile = notPEM
+	if _, err := newMockManager(&Config{Backends: map[string]BackendConfig{"backend-1": cfg}}); err == nil {
+		t.Error("Expected error for ca_bundle_file without PEM certificates")
+	}
+}
+
+func TestBackendRateLimit(t *testing.T) {
+	srv := s3mock.NewServer("test-bucket")
+	defer srv.Close()
+
+	queued := mockBackendConfig(srv)
+	queued.MaxRequestsPerSecond = 10
+	queued.RateLimitBurst = 1
+	strict := mockBackendConfig(srv)
+	strict.MaxRequestsPerSecond = 1
+	strict.RateLimitWait = 10 * time.Millisecond
+
+	manager, err := newMockManager(&Config{Backends: map[string]BackendConfig{
+		"queued":    queued,
+		"strict":    strict,
+		"unlimited": mockBackendConfig(srv),
+	}})
+	if err != nil {
+		t.Fatalf("Failed to create manager: %v", err)
+	}
+
+	headBucket := func(id string) error {
+		b, _ := manager.GetBackend(id)
+		_, err := b.S3Client.HeadBucket(context.Background(), &s3.HeadBucketInput{Bucket: aws.String("test-bucket")})
+		return err
+	}
+
+	// Запросы сверх частоты ждут в очереди: 4 запроса при 10 rps и емкости 1 - не меньше 300ms
+	start := time.Now()
+	for i := 0; i < 4; i++ {
+		if err := headBucket("queued"); err != nil {
+			t.Fatalf("Expected queued request to succeed, got %v", err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 250*time.Millisecond {
+		t.Errorf("Expected requests to be throttled, 4 requests took %v", elapsed)
+	}
+
+	// Запрос, не дождавшийся токена, отклоняется только для своего бэкенда
+	rejected := testutil.ToFloat64(manager.metrics.BackendRateLimitedTotal.WithLabelValues("strict"))
+	if err := headBucket("strict"); err != nil {
+		t.Fatalf("Expected first request to succeed, got %v", err)
+	}
+	if err := headBucket("strict"); !errors.Is(err, ErrRateLimited) {
+		t.Errorf("Expected ErrRateLimited, got %v", err)
+	}
+	if got := testutil.ToFloat64(manager.metrics.BackendRateLimitedTotal.WithLabelValues("strict")); got != rejected+1 {
+		t.Errorf("Expected rate limited counter to grow by 1, got %v -> %v", rejected, got)
+	}
+	for i := 0; i < 5; i++ {
+		if err := headBucket("unlimited"); err != nil {
+			t.Errorf("Expected backend without limit to be unaffected, got %v", err)
+		}
+	}
+
+	// Активные проверки не подпадают под ограничение
+	b, _ := manager.GetBackend("strict")
+	if err := manager.probeBackend(context.Background(), b); err != nil {
+		t.Errorf("Expected health check to bypass rate limit, got %v", err)
+	}
+}
+
+// newProbingManager создает менеджер с одним бэкендом в состоянии PROBING и заданным ProbeBudget
+func newProbingManager(t *testing.T, budget int) (*Manager, *Backend) {
+	t.Helper()
+	srv := s3mock.NewServer("test-bucket")
+	t.Cleanup(srv.Close)
+
+	managerConfig := DefaultManagerConfig()
+	managerConfig.InitialState = StateProbing
+	managerConfig.ProbeBudget = budget
+	manager, err := newMockManager(&Config{Manager: managerConfig, Backends: map[string]BackendConfig{"backend-1": mockBackendConfig(srv)}})
+	if err != nil {
+		t.Fatalf("Failed to create manager: %v", err)
+	}
+	b, _ := manager.GetBackend("backend-1")
+	return manager, b
+}
+
+func TestProbeBudget(t *testing.T) {
+	t.Run("Disabled", func(t *testing.T) {
+		manager, _ := newProbingManager(t, 0)
+		if live := manager.GetLiveBackends(); len(live) != 0 {
+			t.Errorf("Expected probing backend to receive no traffic without probe budget, got %d", len(live))
+		}
+	})
+
+	t.Run("Limits concurrent requests", func(t *testing.T) {
+		manager, _ := newProbingManager(t, 2)
+
+		for i := 0; i < 2; i++ {
+			if live := manager.GetLiveBackends(); len(live) != 1 {
+				t.Fatalf("Expected probe slot %d to be granted, got %d live backends", i+1, len(live))
+			}
+		}
+		if live := manager.GetLiveBackends(); len(live) != 0 {
+			t.Fatalf("Expected probe budget to be exhausted, got %d live backends", len(live))
+		}
+
+		// Проверка готовности не занимает слоты
+		if manager.HasLiveBackends() {
+			t.Error("Expected probing backend not to be reported as live")
+		}
+
+		// Завершенный запрос освобождает слот
+		manager.ReportFailure(&BackendResult{BackendID: "backend-1", Err: context.Canceled})
+		if live := manager.GetLiveBackends(); len(live) != 1 {
+			t.Errorf("Expected released slot to be granted again, got %d live backends", len(live))
+		}
+	})
+
+	t.Run("Successes recover backend", func(t *testing.T) {
+		manager, b := newProbingManager(t, 1)
+
+		for i := 0; i < manager.config.SuccessThreshold; i++ {
+			if live := manager.GetLiveBackends(); len(live) != 1 {
+				t.Fatalf("Expected probe slot, got %d live backends", len(live))
+			}
+			if b.GetState() != StateProbing {
+				t.Fatalf("Expected PROBING before %d successes, got %s", manager.config.SuccessThreshold, b.GetState())
+			}
+			manager.ReportSuccess(&BackendResult{BackendID: "backend-1"})
+		}
+		if b.GetState() != StateUp {
+			t.Errorf("Expected UP after %d successful requests, got %s", manager.config.SuccessThreshold, b.GetState())
+		}
+	})
+
+	t.Run("Failure returns backend to DOWN", func(t *testing.T) {
+		manager, b := newProbingManager(t, 1)
+
+		manager.GetLiveBackends()
+		manager.ReportFailure(&BackendResult{BackendID: "backend-1", Err: fmt.Errorf("connection reset")})
+		if b.GetState() != StateDown {
+			t.Errorf("Expected DOWN after failed probe request, got %s", b.GetState())
+		}
+		if live := manager.GetLiveBackends(); len(live) != 0 {
+			t.Errorf("Expected DOWN backend to receive no traffic, got %d", len(live))
+		}
+	})
+}
+
+func TestBackendRequestID(t *testing.T) {
+	srv := s3mock.NewServer("test-bucket")
+	defer srv.Close()
+
+	managerConfig := DefaultManagerConfig()
+	managerConfig.InitialState = StateUp
+	managerConfig.RequestIDHeader = "X-S3proxy-Request-Id"
+	manager, err := newMockManager(&Config{Manager: managerConfig, Backends: map[string]BackendConfig{"backend-1": mockBackendConfig(srv)}})
+	if err != nil {
+		t.Fatalf("Failed to create manager: %v", err)
+	}
+	b, _ := manager.GetBackend("backend-1")
+
+	var buf bytes.Buffer
+	level := logger.GetGlobalLevel()
+	logger.SetGlobalOutput(&buf)
+	logger.SetGlobalLevel(logger.DEBUG)
+	defer func() {
+		logger.SetGlobalOutput(os.Stdout)
+		logger.SetGlobalLevel(level)
+	}()
+
+	ctx := apigw.ContextWithRequestID(context.Background(), "PROXYREQ1")
+	if _, err := b.S3Client.HeadBucket(ctx, &s3.HeadBucketInput{Bucket: aws.String("test-bucket")}); err != nil {
+		t.Fatalf("HeadBucket failed: %v", err)
+	}
+
+	// Идентификатор запроса прокси передается бэкенду
+	requests := srv.Requests()
+	if got := requests[len(requests)-1].Header.Get("X-S3proxy-Request-Id"); got != "PROXYREQ1" {
+		t.Errorf("Expected proxy request id to be propagated, got %q", got)
+	}
+	// Идентификатор запроса бэкенда попадает в лог вместе с идентификатором прокси
+	if log := buf.String(); !strings.Contains(log, "[PROXYREQ1] Backend backend-1 HeadBucket") || !strings.Contains(log, "backend request id MOCK") {
+		t.Errorf("Expected backend request id in log, got:\n%s", log)
+	}
+
+	// Для ошибок идентификатор бэкенда сохраняется в результате
+	srv.SetIntercept(func(w http.ResponseWriter, r *http.Request) bool {
+		w.WriteHeader(http.StatusInternalServerError)
+		return true
+	})
+	_, err = b.S3Client.HeadBucket(ctx, &s3.HeadBucketInput{Bucket: aws.String("test-bucket")})
+	if err == nil {
+		t.Fatal("Expected HeadBucket to fail")
+	}
+	result := &BackendResult{BackendID: "backend-1", Err: err}
+	manager.ReportFailure(result)
+	if !strings.HasPrefix(result.BackendRequestID, "MOCK") {
+		t.Errorf("Expected backend request id in result, got %q", result.BackendRequestID)
+	}
+	if !strings.Contains(buf.String(), fmt.Sprintf("backend request id: %q", result.BackendRequestID)) {
+		t.Errorf("Expected backend request id in failure log, got:\n%s", buf.String())
+	}
+}
+
+func TestStatusHandler(t *testing.T) {
+	srv := s3mock.NewServer("test-bucket")
+	defer srv.Close()
+
+	manager, err := newMockManager(&Config{Backends: map[string]BackendConfig{
+		"backend-a": mockBackendConfig(srv),
+		"backend-b": mockBackendConfig(srv),
+	}})
+	if err != nil {
+		t.Fatalf("Failed to create manager: %v", err)
+	}
+
+	// backend-b отвечает 403 на активную проверку
+	srv.SetIntercept(func(w http.ResponseWriter, r *http.Request) bool {
+		w.WriteHeader(http.StatusForbidden)
+		return true
+	})
+	b, _ := manager.GetBackend("backend-b")
+	manager.checkBackend(b)
+	srv.SetIntercept(nil)
+
+	rec := httptest.NewRecorder()
+	manager.StatusHandler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/backends", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", rec.Code)
+	}
+	if ct := rec.Header().Get("Content-Type"); ct != "application/json" {
+		t.Errorf("Expected application/json, got %q", ct)
+	}
+
+	var statuses []map[string]interface{}
+	if err := json.Unmarshal(rec.Body.Bytes(), &statuses); err != nil {
+		t.Fatalf("Failed to decode response %s: %v", rec.Body.String(), err)
+	}
+	if len(statuses) != 2 {
+		t.Fatalf("Expected 2 backends, got %d", len(statuses))
+	}
+	for _, field := range []string{"id", "endpoint", "state", "consecutive_failures", "last_error", "last_error_class", "last_check_time"} {
+		if _, ok := statuses[0][field]; !ok {
+			t.Errorf("Expected field %q in backend status, got %v", field, statuses[0])
+		}
+	}
+
+	healthy, failed := statuses[0], statuses[1]
+	if healthy["id"] != "backend-a" || healthy["state"] != "UP" || healthy["last_error"] != "" || healthy["last_check_time"] != nil {
+		t.Errorf("Unexpected status of healthy backend: %v", healthy)
+	}
+	if failed["id"] != "backend-b" || failed["endpoint"] != srv.URL || failed["consecutive_failures"] != float64(1) {
+		t.Errorf("Unexpected status of failed backend: %v", failed)
+	}
+	if failed["last_error"] == "" || failed["last_error_class"] != "Forbidden" || failed["last_check_time"] == nil {
+		t.Errorf("Expected last error of failed backend, got %v", failed)
+	}
+
+	// Метрика последней ошибки помечена классом ошибки и сбрасывается после успешной проверки
+	if got := testutil.ToFloat64(manager.metrics.BackendLastError.WithLabelValues("backend-b", "Forbidden")); got != 1 {
+		t.Errorf("Expected last error gauge 1, got %v", got)
+	}
+	manager.checkBackend(b)
+	if n := manager.metrics.BackendLastError.DeletePartialMatch(map[string]string{"backend": "backend-b"}); n != 0 {
+		t.Errorf("Expected last error series to be removed after recovery, got %d", n)
+	}
+}
+
+func TestSubscribeStateChanges(t *testing.T) {
+	manager, b := newProbingManager(t, 0)
+
+	first := manager.Subscribe()
+	second := manager.Subscribe()
+
+	// Успешные проверки переводят бэкенд из PROBING в UP
+	for i := 0; i < manager.config.SuccessThreshold; i++ {
+		manager.checkBackend(b)
+	}
+	if b.GetState() != StateUp {
+		t.Fatalf("Expected UP after successful health checks, got %s", b.GetState())
+	}
+
+	for i, sub := range []<-chan BackendStateChange{first, second} {
+		select {
+		case change := <-sub:
+			if change.BackendID != "backend-1" || change.Old != StateProbing || change.New != StateUp || change.Time.IsZero() {
+				t.Errorf("Subscriber %d: unexpected event %+v", i+1, change)
+			}
+		case <-time.After(time.Second):
+			t.Fatalf("Subscriber %d: expected state change event", i+1)
+		}
+	}
+
+	// После отписки канал закрыт, остальные подписчики продолжают получать события
+	manager.Unsubscribe(first)
+	if _, ok := <-first; ok {
+		t.Error("Expected unsubscribed channel to be closed")
+	}
+	manager.ReportFailure(&BackendResult{BackendID: "backend-1", Err: fmt.Errorf("connection reset")})
+	for i := 1; i < manager.config.CircuitBreakerThreshold; i++ {
+		manager.ReportFailure(&BackendResult{BackendID: "backend-1", Err: fmt.Errorf("connection reset")})
+	}
+	select {
+	case change := <-second:
+		if change.Old != StateUp || change.New != StateDown {
+			t.Errorf("Expected UP -> DOWN event, got %+v", change)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("Expected circuit breaker transition to be published")
+	}
+
+	// Подписчик, который не читает канал, не блокирует переходы
+	states := []BackendState{StateProbing, StateUp}
+	for i := 0; i < stateChangeBuffer+1; i++ {
+		b.mu.Lock()
+		setBackendState(manager, b, states[i%2])
+		b.mu.Unlock()
+	}
+	if n := len(second); n != stateChangeBuffer {
+		t.Errorf("Expected subscriber buffer to be full with %d events, got %d", stateChangeBuffer, n)
+	}
+}
+
+func TestReadWarmup(t *testing.T) {
+	servers := []*s3mock.Server{s3mock.NewServer("test-bucket"), s3mock.NewServer("test-bucket")}
+	for _, srv := range servers {
+		t.Cleanup(srv.Close)
+	}
+	managerConfig := DefaultManagerConfig()
+	managerConfig.InitialState = StateUp
+	managerConfig.ReadWarmup = time.Minute
+	manager, err := newMockManager(&Config{Manager: managerConfig, Backends: map[string]BackendConfig{
+		"backend-1": mockBackendConfig(servers[0]),
+		"backend-2": mockBackendConfig(servers[1]),
+	}})
+	if err != nil {
+		t.Fatalf("Failed to create manager: %v", err)
+	}
+	now := time.Now()
+	manager.now = func() time.Time { return now }
+
+	readIDs := func() []string {
+		var ids []string
+		for _, b := range manager.GetReadBackends() {
+			ids = append(ids, b.ID)
+		}
+		sort.Strings(ids)
+		return ids
+	}
+
+	// Бэкенды, начавшие работу в UP, прогрева не проходят
+	if ids := readIDs(); len(ids) != 2 {
+		t.Fatalf("Expected both initial UP backends to serve reads, got %v", ids)
+	}
+
+	// backend-2 отказал и восстановился: запись идет на него сразу, чтение - после прогрева
+	b2, _ := manager.GetBackend("backend-2")
+	for _, state := range []BackendState{StateDown, StateProbing, StateUp} {
+		b2.mu.Lock()
+		setBackendState(manager, b2, state)
+		b2.mu.Unlock()
+	}
+	if live := manager.GetLiveBackends(); len(live) != 2 {
+		t.Errorf("Expected warming backend to receive writes, got %d live backends", len(live))
+	}
+	if ids := readIDs(); len(ids) != 1 || ids[0] != "backend-1" {
+		t.Errorf("Expected just-promoted backend-2 to be excluded from reads, got %v", ids)
+	}
+
+	now = now.Add(30 * time.Second)
+	if ids := readIDs(); len(ids) != 1 {
+		t.Errorf("Expected backend-2 to be still warming up, got %v", ids)
+	}
+
+	now = now.Add(31 * time.Second)
+	if ids := readIDs(); len(ids) != 2 {
+		t.Errorf("Expected backend-2 to serve reads after warm-up, got %v", ids)
+	}
+
+	// Если прогреваются все живые бэкенды, чтения не отключаются
+	b1, _ := manager.GetBackend("backend-1")
+	b1.mu.Lock()
+	setBackendState(manager, b1, StateDown)
+	b1.mu.Unlock()
+	b2.mu.Lock()
+	setBackendState(manager, b2, StateDown)
+	setBackendState(manager, b2, StateUp)
+	b2.mu.Unlock()
+	if ids := readIDs(); len(ids) != 1 || ids[0] != "backend-2" {
+		t.Errorf("Expected warming backend-2 to serve reads when it is the only live backend, got %v", ids)
+	}
+}
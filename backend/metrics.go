@@ -1,26 +1,47 @@
 package backend
 
 import (
+	"sync"
+
 	"github.com/prometheus/client_golang/prometheus"
 	"github.com/prometheus/client_golang/prometheus/promauto"
 )
 
 type Metrics struct {
 	// Метрики бэкендов
-	BackendState         *prometheus.GaugeVec     // Текущее состояние бэкенда (1=UP, 0.5=PROBING, 0=DOWN)
+	BackendState         *prometheus.GaugeVec     // Текущее состояние бэкенда (1=UP, 0.5=PROBING, 0=DOWN, -1=UNAUTHORIZED)
 	BackendRequestsTotal *prometheus.CounterVec   // Количество запросов к конкретным бэкендам
 	BackendLatency       *prometheus.HistogramVec // Латентность запросов к бэкендам
 	BackendBytesRead     *prometheus.CounterVec   // Количество прочитанных байт с бэкендов
 	BackendBytesWrite    *prometheus.CounterVec   // Количество записанных байт в бэкендов
+
+	BackendRateLimitedTotal *prometheus.CounterVec // Запросы, отклоненные ограничителем частоты бэкенда
+	BackendLastError        *prometheus.GaugeVec   // Класс последней ошибки бэкенда (1 - текущий класс)
 }
 
+var (
+	metricsOnce     sync.Once
+	metricsInstance *Metrics
+)
+
+// NewMetrics возвращает метрики модуля. Метрики регистрируются в глобальном
+// реестре Prometheus один раз: повторная регистрация коллектора с тем же именем
+// приводит к панике, поэтому несколько менеджеров (например, в тестах) разделяют
+// один и тот же набор коллекторов. Так же устроены NewMetrics остальных модулей.
 func NewMetrics() *Metrics {
+	metricsOnce.Do(func() {
+		metricsInstance = newMetrics()
+	})
+	return metricsInstance
+}
+
+func newMetrics() *Metrics {
 	return &Metrics{
 		// Метрики бэкендов
 		BackendState: promauto.NewGaugeVec(
 			prometheus.GaugeOpts{
 				Name: "s3proxy_backend_state",
-				Help: "Current state of a backend (1=UP, 0.5=PROBING, 0=DOWN)",
+				Help: "Current state of a backend (1=UP, 0.5=PROBING, 0=DOWN, -1=UNAUTHORIZED)",
 			},
 			[]string{"backend"},
 		),
@@ -53,5 +74,19 @@ func NewMetrics() *Metrics {
 			},
 			[]string{"backend"},
 		),
+		BackendRateLimitedTotal: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "s3proxy_backend_rate_limited_total",
+				Help: "Total number of backend requests rejected by the per-backend rate limiter",
+			},
+			[]string{"backend"},
+		),
+		BackendLastError: promauto.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "s3proxy_backend_last_error",
+				Help: "Class of the last backend error (1 for the current class, no series while the backend has no error)",
+			},
+			[]string{"backend", "class"},
+		),
 	}
 }
@@ -0,0 +1,127 @@
+package backend
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/aws/smithy-go/middleware"
+)
+
+// ErrRateLimited возвращается запросом к бэкенду, который не получил токен
+// ограничителя частоты за отведенное время. Ошибка учитывается как отказ только
+// этого бэкенда.
+var ErrRateLimited = errors.New("backend request rate limit exceeded")
+
+// defaultRateLimitWait - сколько запрос по умолчанию ждет токен ограничителя
+const defaultRateLimitWait = time.Second
+
+// rateLimiter - ограничитель частоты запросов к бэкенду (token bucket). Запросы,
+// которым не хватило токена, ждут своей очереди, но не дольше wait и дедлайна контекста.
+type rateLimiter struct {
+	mu     sync.Mutex
+	rate   float64 // Токенов в секунду
+	burst  float64 // Емкость ведра
+	tokens float64 // Доступные токены; отрицательное значение - очередь ожидающих
+	last   time.Time
+	wait   time.Duration
+}
+
+// newRateLimiter создает ограничитель по настройкам бэкенда или возвращает nil,
+// если MaxRequestsPerSecond не задан
+func newRateLimiter(cfg BackendConfig) *rateLimiter {
+	if cfg.MaxRequestsPerSecond <= 0 {
+		return nil
+	}
+	burst := float64(cfg.RateLimitBurst)
+	if burst <= 0 {
+		burst = math.Max(1, math.Ceil(cfg.MaxRequestsPerSecond))
+	}
+	wait := cfg.RateLimitWait
+	if wait <= 0 {
+		wait = defaultRateLimitWait
+	}
+	return &rateLimiter{
+		rate:   cfg.MaxRequestsPerSecond,
+		burst:  burst,
+		tokens: burst,
+		last:   time.Now(),
+		wait:   wait,
+	}
+}
+
+// Wait занимает токен, дожидаясь его не дольше wait и дедлайна ctx.
+// Если токен не успевает освободиться, возвращает ErrRateLimited сразу, не ожидая.
+func (l *rateLimiter) Wait(ctx context.Context) error {
+	l.mu.Lock()
+	now := time.Now()
+	l.tokens = math.Min(l.burst, l.tokens+now.Sub(l.last).Seconds()*l.rate)
+	l.last = now
+
+	l.tokens--
+	var delay time.Duration
+	if l.tokens < 0 {
+		delay = time.Duration(-l.tokens / l.rate * float64(time.Second))
+	}
+
+	maxWait := l.wait
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < maxWait {
+		maxWait = time.Until(deadline)
+	}
+	if delay > maxWait {
+		l.tokens++
+		l.mu.Unlock()
+		return ErrRateLimited
+	}
+	l.mu.Unlock()
+
+	if delay == 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		// Возвращаем токен, который так и не был использован
+		l.mu.Lock()
+		l.tokens++
+		l.mu.Unlock()
+		return ctx.Err()
+	}
+}
+
+// rateLimitExemptKey - ключ контекста запросов, не подпадающих под ограничение частоты
+type rateLimitExemptKey struct{}
+
+// withoutRateLimit помечает контекст запроса, который не должен ждать токен
+// ограничителя (активные проверки через основной клиент)
+func withoutRateLimit(ctx context.Context) context.Context {
+	return context.WithValue(ctx, rateLimitExemptKey{}, true)
+}
+
+// rateLimitMiddleware возвращает опцию S3 клиента, которая перед каждой попыткой
+// запроса занимает токен ограничителя бэкенда
+func rateLimitMiddleware(backendID string, limiter *rateLimiter, metrics *Metrics) func(*middleware.Stack) error {
+	return func(stack *middleware.Stack) error {
+		return stack.Finalize.Add(middleware.FinalizeMiddlewareFunc("S3ProxyRateLimit",
+			func(ctx context.Context, in middleware.FinalizeInput, next middleware.FinalizeHandler) (
+				middleware.FinalizeOutput, middleware.Metadata, error,
+			) {
+				if exempt, _ := ctx.Value(rateLimitExemptKey{}).(bool); !exempt {
+					if err := limiter.Wait(ctx); err != nil {
+						if errors.Is(err, ErrRateLimited) {
+							metrics.BackendRateLimitedTotal.WithLabelValues(backendID).Inc()
+							err = fmt.Errorf("%w: backend %s", err, backendID)
+						}
+						return middleware.FinalizeOutput{}, middleware.Metadata{}, err
+					}
+				}
+				return next.HandleFinalize(ctx, in)
+			}), middleware.After)
+	}
+}
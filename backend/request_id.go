@@ -0,0 +1,69 @@
+package backend
+
+import (
+	"context"
+	"errors"
+
+	"s3proxy/apigw"
+	"s3proxy/logger"
+
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
+	"github.com/aws/smithy-go/middleware"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+)
+
+// backendRequestIDHeader - заголовок ответа S3 с идентификатором запроса на стороне бэкенда
+const backendRequestIDHeader = "X-Amz-Request-Id"
+
+// BackendRequestID возвращает идентификатор запроса бэкенда (x-amz-request-id) из ошибки
+// AWS SDK или пустую строку, если бэкенд не ответил
+func BackendRequestID(err error) string {
+	var respErr *awshttp.ResponseError
+	if errors.As(err, &respErr) {
+		return respErr.ServiceRequestID()
+	}
+	return ""
+}
+
+// requestIDMiddleware возвращает опцию S3 клиента, которая передает бэкенду идентификатор
+// клиентского запроса прокси в заголовке header (если он задан), отмечает обращение
+// к бэкенду для буфера последних запросов (apigw.RecordBackend) и пишет в лог идентификатор
+// запроса, назначенный бэкендом. Идентификаторы бэкенда только логируются и не попадают
+// в метки метрик, чтобы не раздувать число временных рядов.
+func requestIDMiddleware(backendID, header string) func(*middleware.Stack) error {
+	return func(stack *middleware.Stack) error {
+		if header != "" {
+			err := stack.Build.Add(middleware.BuildMiddlewareFunc("S3ProxyPropagateRequestID",
+				func(ctx context.Context, in middleware.BuildInput, next middleware.BuildHandler) (
+					middleware.BuildOutput, middleware.Metadata, error,
+				) {
+					if requestID := apigw.RequestIDFromContext(ctx); requestID != "" {
+						if req, ok := in.Request.(*smithyhttp.Request); ok {
+							req.Header.Set(header, requestID)
+						}
+					}
+					return next.HandleBuild(ctx, in)
+				}), middleware.After)
+			if err != nil {
+				return err
+			}
+		}
+
+		return stack.Deserialize.Add(middleware.DeserializeMiddlewareFunc("S3ProxyCaptureRequestID",
+			func(ctx context.Context, in middleware.DeserializeInput, next middleware.DeserializeHandler) (
+				middleware.DeserializeOutput, middleware.Metadata, error,
+			) {
+				apigw.RecordBackend(ctx, backendID)
+				out, metadata, err := next.HandleDeserialize(ctx, in)
+				if resp, ok := out.RawResponse.(*smithyhttp.Response); ok {
+					if backendRequestID := resp.Header.Get(backendRequestIDHeader); backendRequestID != "" {
+						logger.Debug("[%s] Backend %s %s: status %d, backend request id %s",
+							apigw.RequestIDFromContext(ctx), backendID, awsmiddleware.GetOperationName(ctx),
+							resp.StatusCode, backendRequestID)
+					}
+				}
+				return out, metadata, err
+			}), middleware.After)
+	}
+}
@@ -0,0 +1,113 @@
+package backend
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sort"
+	"time"
+
+	"s3proxy/logger"
+
+	"github.com/aws/smithy-go"
+)
+
+// BackendStatus - снимок состояния бэкенда для административного эндпоинта /backends
+type BackendStatus struct {
+	ID                  string     `json:"id"`
+	Endpoint            string     `json:"endpoint"`
+	State               string     `json:"state"`
+	ConsecutiveFailures int        `json:"consecutive_failures"`
+	LastError           string     `json:"last_error"`
+	LastErrorClass      string     `json:"last_error_class"`
+	LastCheckTime       *time.Time `json:"last_check_time"` // nil - активных проверок еще не было
+}
+
+// ErrorClass возвращает класс ошибки бэкенда: Timeout, Canceled, RateLimited, код ошибки S3
+// (например, AccessDenied), HTTP<код> или NetworkError. Для nil возвращает пустую строку.
+// Число классов ограничено, поэтому класс можно использовать в метках метрик.
+func ErrorClass(err error) string {
+	switch {
+	case err == nil:
+		return ""
+	case errors.Is(err, context.DeadlineExceeded):
+		return "Timeout"
+	case errors.Is(err, context.Canceled):
+		return "Canceled"
+	case errors.Is(err, ErrRateLimited):
+		return "RateLimited"
+	}
+
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) && apiErr.ErrorCode() != "" {
+		return apiErr.ErrorCode()
+	}
+
+	var httpErr interface{ HTTPStatusCode() int }
+	if errors.As(err, &httpErr) {
+		return fmt.Sprintf("HTTP%d", httpErr.HTTPStatusCode())
+	}
+
+	return "NetworkError"
+}
+
+// setLastError сохраняет последнюю ошибку бэкенда и обновляет метрику
+// s3proxy_backend_last_error. Вызывается под backend.mu.
+func setLastError(m *Manager, backend *Backend, err error) {
+	backend.lastError = err
+	m.metrics.BackendLastError.DeletePartialMatch(map[string]string{"backend": backend.ID})
+	if err != nil {
+		m.metrics.BackendLastError.WithLabelValues(backend.ID, ErrorClass(err)).Set(1)
+	}
+}
+
+// Status возвращает снимок состояния бэкенда (потокобезопасно)
+func (b *Backend) Status() BackendStatus {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+
+	status := BackendStatus{
+		ID:                  b.ID,
+		Endpoint:            b.Config.Endpoint,
+		State:               b.state.String(),
+		ConsecutiveFailures: b.consecutiveFailures,
+		LastErrorClass:      ErrorClass(b.lastError),
+	}
+	if b.lastError != nil {
+		status.LastError = b.lastError.Error()
+	}
+	if !b.lastCheckTime.IsZero() {
+		lastCheck := b.lastCheckTime
+		status.LastCheckTime = &lastCheck
+	}
+	return status
+}
+
+// GetBackendStatuses возвращает снимки состояния всех бэкендов, отсортированные по ID
+func (m *Manager) GetBackendStatuses() []BackendStatus {
+	backends := m.GetAllBackends()
+	statuses := make([]BackendStatus, 0, len(backends))
+	for _, backend := range backends {
+		statuses = append(statuses, backend.Status())
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].ID < statuses[j].ID })
+	return statuses
+}
+
+// StatusHandler возвращает HTTP обработчик, который отдает состояние бэкендов в JSON.
+// Подключается к серверу мониторинга по пути /backends.
+func (m *Manager) StatusHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(m.GetBackendStatuses()); err != nil {
+			logger.Error("Failed to write backend status: %v", err)
+		}
+	})
+}
@@ -0,0 +1,40 @@
+package backend
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+)
+
+// newBackendTLSConfig создает TLS конфигурацию транспорта бэкенда из CABundleFile и
+// InsecureSkipVerify. Возвращает nil, если ни один параметр не задан: тогда транспорт
+// использует настройки AWS SDK и системные корневые сертификаты.
+func newBackendTLSConfig(cfg BackendConfig) (*tls.Config, error) {
+	if cfg.CABundleFile == "" && !cfg.InsecureSkipVerify {
+		return nil, nil
+	}
+
+	tlsConfig := &tls.Config{
+		MinVersion:         tls.VersionTLS12,
+		InsecureSkipVerify: cfg.InsecureSkipVerify,
+	}
+
+	if cfg.CABundleFile != "" {
+		pem, err := os.ReadFile(cfg.CABundleFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read ca_bundle_file: %w", err)
+		}
+		// Сертификаты из файла дополняют системные, а не заменяют их
+		pool, err := x509.SystemCertPool()
+		if err != nil || pool == nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("ca_bundle_file %s contains no PEM certificates", cfg.CABundleFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	return tlsConfig, nil
+}
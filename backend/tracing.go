@@ -0,0 +1,66 @@
+package backend
+
+import (
+	"context"
+	"errors"
+
+	awsmiddleware "github.com/aws/aws-sdk-go-v2/aws/middleware"
+	"github.com/aws/smithy-go/middleware"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracerName - имя трассировщика вызовов бэкендов
+const tracerName = "s3proxy/backend"
+
+// tracingMiddleware возвращает опцию S3 клиента, которая оборачивает каждую операцию
+// с бэкендом (вместе с повторами и ожиданием ограничителя частоты) в дочерний спан
+// с идентификатором бэкенда и HTTP статусом ответа. Вызовы вне трассируемого запроса
+// (проверки здоровья, фоновые задачи) спанов не создают.
+func tracingMiddleware(backendID string) func(*middleware.Stack) error {
+	return func(stack *middleware.Stack) error {
+		return stack.Initialize.Add(middleware.InitializeMiddlewareFunc("S3ProxyTracing",
+			func(ctx context.Context, in middleware.InitializeInput, next middleware.InitializeHandler) (
+				middleware.InitializeOutput, middleware.Metadata, error,
+			) {
+				if !trace.SpanContextFromContext(ctx).IsValid() {
+					return next.HandleInitialize(ctx, in)
+				}
+
+				operation := middleware.GetOperationName(ctx)
+				ctx, span := otel.Tracer(tracerName).Start(ctx, "backend "+operation,
+					trace.WithSpanKind(trace.SpanKindClient),
+					trace.WithAttributes(
+						attribute.String("backend.id", backendID),
+						attribute.String("s3.operation", operation),
+					))
+				defer span.End()
+
+				out, metadata, err := next.HandleInitialize(ctx, in)
+				if status := ResponseStatus(metadata, err); status != 0 {
+					span.SetAttributes(attribute.Int("http.response.status_code", status))
+				}
+				if err != nil {
+					span.RecordError(err)
+					span.SetStatus(codes.Error, ErrorClass(err))
+				}
+				return out, metadata, err
+			}), middleware.After)
+	}
+}
+
+// ResponseStatus возвращает HTTP статус ответа бэкенда по метаданным результата операции
+// SDK (ResultMetadata) или по ошибке, либо 0, если ответа не было
+func ResponseStatus(metadata middleware.Metadata, err error) int {
+	if resp, ok := awsmiddleware.GetRawResponse(metadata).(*smithyhttp.Response); ok && resp != nil {
+		return resp.StatusCode
+	}
+	var statusErr interface{ HTTPStatusCode() int }
+	if errors.As(err, &statusErr) {
+		return statusErr.HTTPStatusCode()
+	}
+	return 0
+}
@@ -14,6 +14,10 @@ const (
 	StateUp      BackendState = "UP"      // Бэкенд полностью работоспособен
 	StateDown    BackendState = "DOWN"    // Бэкенд недоступен
 	StateProbing BackendState = "PROBING" // Промежуточное состояние - проверка восстановления
+
+	// StateUnauthorized - бэкенд доступен, но отвечает 403 на активные проверки (неверные
+	// ключи или права). Как и DOWN, не получает запросов. Включается ManagerConfig.ForbiddenAsUnauthorized.
+	StateUnauthorized BackendState = "UNAUTHORIZED"
 )
 
 // String возвращает строковое представление состояния
@@ -30,6 +34,8 @@ func (s BackendState) ToFloat64() float64 {
 		return 0.5
 	case StateDown:
 		return 0.0
+	case StateUnauthorized:
+		return -1.0
 	default:
 		return 0.0
 	}
@@ -42,6 +48,59 @@ type BackendConfig struct {
 	Bucket    string `yaml:"bucket"`     // Имя бакета на этом бэкенде
 	AccessKey string `yaml:"access_key"` // Access Key для аутентификации
 	SecretKey string `yaml:"secret_key"` // Secret Key для аутентификации
+
+	// HealthCheckKey - ключ объекта для активной проверки через HeadObject (опционально).
+	// Если не задан, проверка выполняется через HeadBucket.
+	HealthCheckKey string `yaml:"health_check_key"`
+
+	// AutoCreateBucket - создавать бакет бэкенда, если PUT вернул NoSuchBucket,
+	// и повторять PUT. По умолчанию выключено.
+	AutoCreateBucket bool `yaml:"auto_create_bucket"`
+
+	// Weight - приоритет бэкенда при hedged-чтении (hedge_delay): запросы отправляются
+	// сначала бэкендам с большим весом. По умолчанию 0.
+	Weight int `yaml:"weight"`
+
+	// StreamingSigningRegion - регион, которым подписываются запросы потокового PUT клиента
+	// (StreamingPutClient, создается для HTTP бэкендов), если он отличается от региона данных.
+	// Пусто - используется Region.
+	StreamingSigningRegion string `yaml:"streaming_signing_region"`
+
+	// MaxAttempts - число попыток каждого запроса к бэкенду в AWS SDK, включая первую.
+	// 0 - значение по умолчанию SDK (3), 1 - без повторов. Не влияет на отдельный
+	// клиент активных проверок, который запросы не повторяет.
+	MaxAttempts int `yaml:"max_attempts"`
+
+	// Настройки HTTP транспорта S3 клиентов бэкенда. 0 - значение по умолчанию AWS SDK.
+	MaxIdleConns        int           `yaml:"max_idle_conns"`          // Простаивающих соединений всего
+	MaxIdleConnsPerHost int           `yaml:"max_idle_conns_per_host"` // Простаивающих соединений к эндпоинту
+	IdleConnTimeout     time.Duration `yaml:"idle_conn_timeout"`       // Время жизни простаивающего соединения
+	DialTimeout         time.Duration `yaml:"dial_timeout"`            // Таймаут установки TCP соединения
+	TLSHandshakeTimeout time.Duration `yaml:"tls_handshake_timeout"`   // Таймаут TLS handshake
+
+	// CABundleFile - PEM файл с сертификатами CA, которым подписан TLS сертификат бэкенда
+	// (дополняет системные корневые сертификаты). InsecureSkipVerify отключает проверку
+	// сертификата бэкенда - только для тестовых стендов.
+	CABundleFile       string `yaml:"ca_bundle_file"`
+	InsecureSkipVerify bool   `yaml:"insecure_skip_verify"`
+
+	// MaxRequestsPerSecond - ограничение частоты запросов к бэкенду (token bucket) для всех
+	// запросов чтения и записи, 0 - без ограничения. RateLimitBurst - емкость ведра
+	// (0 - MaxRequestsPerSecond, округленное вверх). Запрос ждет токен не дольше
+	// RateLimitWait (0 - 1s), иначе завершается ошибкой ErrRateLimited.
+	MaxRequestsPerSecond float64       `yaml:"max_requests_per_second"`
+	RateLimitBurst       int           `yaml:"rate_limit_burst"`
+	RateLimitWait        time.Duration `yaml:"rate_limit_wait"`
+
+	// MaintenanceWindows - плановые окна обслуживания (UTC): во время окна бэкенд
+	// не возвращается из GetLiveBackends, как если бы он был выведен из ротации
+	MaintenanceWindows []MaintenanceWindow `yaml:"maintenance_windows"`
+
+	// MinObjectSize и MaxObjectSize - диапазон размеров объектов (в байтах), которые PUT
+	// записывает на этот бэкенд, например только мелкие объекты на быстрый SSD уровень.
+	// 0 - без ограничения. Объекты неизвестного размера и multipart upload пишутся как обычно.
+	MinObjectSize int64 `yaml:"min_object_size"`
+	MaxObjectSize int64 `yaml:"max_object_size"`
 }
 
 // Backend представляет один S3-бэкенд с его состоянием
@@ -50,6 +109,7 @@ type Backend struct {
 	Config             BackendConfig // Конфигурация бэкенда
 	S3Client           *s3.Client    // Настроенный S3 клиент
 	StreamingPutClient *s3.Client    // Специальный клиент для PUT
+	HealthCheckClient  *s3.Client    // Отдельный клиент активных проверок (nil - используется S3Client)
 
 	// Внутреннее состояние, защищенное мьютексом
 	mu                   sync.RWMutex
@@ -58,10 +118,20 @@ type Backend struct {
 	lastCheckTime        time.Time
 	consecutiveFailures  int // Количество последовательных неудач
 	consecutiveSuccesses int // Количество последовательных успехов
+	consecutiveForbidden int // Количество последовательных ответов 403 на активные проверки
 
 	// Статистика для Circuit Breaker
 	recentFailures int       // Количество неудач в скользящем окне
 	windowStart    time.Time // Начало текущего окна
+
+	// Сглаженная задержка успешных запросов (EWMA), 0 - измерений еще не было
+	averageLatency time.Duration
+
+	// Время выдачи слотов ProbeBudget клиентским запросам в состоянии PROBING
+	probeLeases []time.Time
+
+	// Момент последнего перехода в UP (отсчет ReadWarmup), нулевой - бэкенд начал работу в UP
+	upSince time.Time
 }
 
 // backendResult представляет результат операции на одном бэкенде
@@ -74,6 +144,10 @@ type BackendResult struct {
 	Duration     time.Duration
 	BytesWritten int64
 	BytesRead    int64
+
+	// BackendRequestID - идентификатор запроса на стороне бэкенда (x-amz-request-id).
+	// Для ошибок заполняется менеджером в ReportFailure, если не задан вызывающим.
+	BackendRequestID string
 }
 
 // GetState возвращает текущее состояние бэкенда (потокобезопасно)
@@ -104,6 +178,14 @@ func (b *Backend) GetStats() (consecutiveFailures, consecutiveSuccesses, recentF
 	return b.consecutiveFailures, b.consecutiveSuccesses, b.recentFailures
 }
 
+// GetAverageLatency возвращает сглаженную задержку успешных запросов (потокобезопасно).
+// Ноль означает, что измерений еще не было.
+func (b *Backend) GetAverageLatency() time.Duration {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	return b.averageLatency
+}
+
 // BackendProvider - интерфейс для получения информации о бэкендах
 type BackendProvider interface {
 	// GetLiveBackends возвращает список всех работоспособных бэкендов (UP или PROBING)
@@ -130,3 +212,38 @@ type BackendProvider interface {
 	// IsRunning возвращает true, если менеджер запущен
 	IsRunning() bool
 }
+
+// probeSlotTimeout - через сколько слот ProbeBudget освобождается, даже если о результате
+// запроса не сообщили (например, вызывающий не использовал выданный бэкенд)
+const probeSlotTimeout = time.Minute
+
+// acquireProbeSlot выдает слот ProbeBudget клиентскому запросу, если бэкенд все еще
+// в состоянии PROBING и занято меньше budget слотов
+func (b *Backend) acquireProbeSlot(budget int, now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.state != StateProbing {
+		return false
+	}
+	active := b.probeLeases[:0]
+	for _, issued := range b.probeLeases {
+		if now.Sub(issued) < probeSlotTimeout {
+			active = append(active, issued)
+		}
+	}
+	b.probeLeases = active
+	if len(b.probeLeases) >= budget {
+		return false
+	}
+	b.probeLeases = append(b.probeLeases, now)
+	return true
+}
+
+// releaseProbeSlot освобождает самый старый занятый слот ProbeBudget.
+// Вызывающий должен удерживать b.mu.
+func (b *Backend) releaseProbeSlot() {
+	if len(b.probeLeases) > 0 {
+		b.probeLeases = b.probeLeases[1:]
+	}
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"mime"
 	"os"
 	"time"
 
@@ -10,8 +11,12 @@ import (
 	"s3proxy/apigw"
 	"s3proxy/auth"
 	"s3proxy/backend"
+	"s3proxy/fetch"
+	"s3proxy/logger"
 	"s3proxy/monitoring"
+	"s3proxy/replicator"
 	"s3proxy/routing"
+	"s3proxy/tracing"
 )
 
 // AppConfig содержит полную конфигурацию приложения
@@ -33,6 +38,13 @@ type AppConfig struct {
 
 	// Конфигурация политик маршрутизации
 	Routing routing.Config `yaml:"routing"`
+
+	// Конфигурация трассировки OpenTelemetry
+	Tracing tracing.Config `yaml:"tracing"`
+
+	// Конфигурация репликации операций записи. Не заданные в файле параметры
+	// берутся из replicator.DefaultConfig
+	Replicator replicator.Config `yaml:"replicator"`
 }
 
 // ServerConfig содержит конфигурацию HTTP сервера
@@ -44,11 +56,77 @@ type ServerConfig struct {
 	ReadTimeout   time.Duration `yaml:"read_timeout"`
 	WriteTimeout  time.Duration `yaml:"write_timeout"`
 	UseMock       bool          `yaml:"use_mock"`
+	BaseDomain    string        `yaml:"base_domain"`
+	MaxObjectSize int64         `yaml:"max_object_size"`
+
+	// BucketMode - проверка бакета в запросах: passthrough (по умолчанию) - запросы к любому
+	// бакету передаются бэкендам, strict - обслуживается только VirtualBucket, остальные
+	// получают 404 NoSuchBucket
+	BucketMode string `yaml:"bucket_mode"`
+
+	// MaxRequestsPerClient - максимум одновременных запросов с одного IP клиента (0 - без ограничения).
+	// Запросы сверх лимита получают 503 SlowDown, другие клиенты не затрагиваются.
+	MaxRequestsPerClient int `yaml:"max_requests_per_client"`
+
+	// TrailerChecksum - проверка контрольной суммы из трейлера aws-chunked загрузок: verify (по умолчанию) или ignore
+	TrailerChecksum string `yaml:"trailer_checksum"`
+
+	// BodyLengthCheck - отклонять тело aws-chunked длиннее X-Amz-Decoded-Content-Length (IncompleteBody): verify (по умолчанию) или ignore
+	BodyLengthCheck string `yaml:"body_length_check"`
+
+	// OptionsResponse - ответ на OPTIONS без CORS заголовков: allow (200, по умолчанию) или reject (405)
+	OptionsResponse string `yaml:"options_response"`
+
+	// ListMergeThreshold - число ключей во всех ответах бэкендов, до которого ListObjectsV2
+	// объединяется в памяти; большие и усеченные листинги сливаются потоково (0 - 10000)
+	ListMergeThreshold int `yaml:"list_merge_threshold"`
+
+	// ListCacheTTL - время жизни объединенных листингов ListObjectsV2 в кэше (0 - кэш выключен).
+	// Запись сбрасывается раньше, если через прокси изменился объект под ее префиксом.
+	ListCacheTTL time.Duration `yaml:"list_cache_ttl"`
+
+	// ListCacheSize - максимальное число листингов в кэше (0 - 1000)
+	ListCacheSize int `yaml:"list_cache_size"`
+
+	// MaxConcurrentReads - максимальное число одновременных запросов чтения (GET, HEAD,
+	// листинги) к бэкендам (0 - без ограничения). Запросы сверх лимита ждут очереди.
+	MaxConcurrentReads int `yaml:"max_concurrent_reads"`
+
+	// RetryAfter - значение заголовка Retry-After в ответе 503 ServiceUnavailable, когда
+	// для операции нет доступных бэкендов (0 - 5s)
+	RetryAfter time.Duration `yaml:"retry_after"`
+
+	// RecentRequests - сколько последних запросов (операция, бакет, ключ, статус, время,
+	// бэкенды, ошибка) хранить в памяти и отдавать на /requests сервера мониторинга (0 - выключено)
+	RecentRequests int `yaml:"recent_requests"`
+
+	// Scrub - фоновая сверка копий объектов на бэкендах (ETag и размер по HEAD)
+	Scrub fetch.ScrubConfig `yaml:"scrub"`
+
+	CORS apigw.CORSConfig `yaml:"cors"`
 }
 
 // LoggingConfig содержит конфигурацию логирования
 type LoggingConfig struct {
 	Level string `yaml:"level"`
+
+	// RedactHeaders - дополнительные заголовки, значения которых не выводятся в логи
+	// (Authorization, X-Amz-Content-Sha256 и X-Amz-Security-Token скрываются всегда)
+	RedactHeaders []string `yaml:"redact_headers"`
+
+	// File - запись логов в файл с ротацией вместо stdout
+	File logger.FileConfig `yaml:"file"`
+
+	AccessLog AccessLogConfig `yaml:"access_log"`
+}
+
+// AccessLogConfig содержит конфигурацию журнала доступа
+type AccessLogConfig struct {
+	// Enabled - писать строку журнала доступа на каждый запрос
+	Enabled bool `yaml:"enabled"`
+
+	// File - файл журнала доступа с ротацией (без path - stdout)
+	File logger.FileConfig `yaml:"file"`
 }
 
 // DefaultAppConfig возвращает конфигурацию по умолчанию
@@ -79,8 +157,8 @@ func LoadConfig(filename string) (*AppConfig, error) {
 		return nil, fmt.Errorf("failed to read config file %s: %w", filename, err)
 	}
 
-	// Создаем пустую конфигурацию
-	config := &AppConfig{}
+	// Создаем пустую конфигурацию; параметры репликатора заполняются значениями по умолчанию
+	config := &AppConfig{Replicator: *replicator.DefaultConfig()}
 
 	// Парсим YAML
 	if err := yaml.Unmarshal(data, config); err != nil {
@@ -116,10 +194,84 @@ func (c *AppConfig) Validate() error {
 		return fmt.Errorf("both tls_cert_file and tls_key_file must be specified for TLS")
 	}
 
+	if c.Server.MaxObjectSize < 0 {
+		return fmt.Errorf("server.max_object_size cannot be negative")
+	}
+	if c.Server.MaxRequestsPerClient < 0 {
+		return fmt.Errorf("server.max_requests_per_client cannot be negative")
+	}
+	if c.Server.RecentRequests < 0 {
+		return fmt.Errorf("server.recent_requests cannot be negative")
+	}
+
+	switch c.Server.TrailerChecksum {
+	case "", apigw.TrailerChecksumVerify, apigw.TrailerChecksumIgnore:
+	default:
+		return fmt.Errorf("server.trailer_checksum must be %q or %q, got %q",
+			apigw.TrailerChecksumVerify, apigw.TrailerChecksumIgnore, c.Server.TrailerChecksum)
+	}
+
+	switch c.Server.BodyLengthCheck {
+	case "", apigw.BodyLengthVerify, apigw.BodyLengthIgnore:
+	default:
+		return fmt.Errorf("server.body_length_check must be %q or %q, got %q",
+			apigw.BodyLengthVerify, apigw.BodyLengthIgnore, c.Server.BodyLengthCheck)
+	}
+
+	switch c.Server.OptionsResponse {
+	case "", apigw.OptionsAllow, apigw.OptionsReject:
+	default:
+		return fmt.Errorf("server.options_response must be %q or %q, got %q",
+			apigw.OptionsAllow, apigw.OptionsReject, c.Server.OptionsResponse)
+	}
+
+	if c.Server.ListMergeThreshold < 0 {
+		return fmt.Errorf("server.list_merge_threshold cannot be negative")
+	}
+	if c.Server.ListCacheTTL < 0 {
+		return fmt.Errorf("server.list_cache_ttl cannot be negative")
+	}
+	if c.Server.ListCacheSize < 0 {
+		return fmt.Errorf("server.list_cache_size cannot be negative")
+	}
+	switch c.Server.BucketMode {
+	case "", routing.BucketModePassthrough:
+	case routing.BucketModeStrict:
+		if c.Server.VirtualBucket == "" {
+			return fmt.Errorf("server.bucket_mode %q requires server.virtual_bucket", routing.BucketModeStrict)
+		}
+	default:
+		return fmt.Errorf("server.bucket_mode must be %q or %q, got %q",
+			routing.BucketModePassthrough, routing.BucketModeStrict, c.Server.BucketMode)
+	}
+	if c.Server.MaxConcurrentReads < 0 {
+		return fmt.Errorf("server.max_concurrent_reads cannot be negative")
+	}
+	if c.Server.RetryAfter < 0 {
+		return fmt.Errorf("server.retry_after cannot be negative")
+	}
+	if err := c.Server.Scrub.Validate(); err != nil {
+		return fmt.Errorf("server.scrub: %w", err)
+	}
+
+	// Проверяем CORS конфигурацию
+	if c.Server.CORS.Enabled() && len(c.Server.CORS.AllowedMethods) == 0 {
+		return fmt.Errorf("server.cors.allowed_methods cannot be empty when CORS is enabled")
+	}
+	if c.Server.CORS.MaxAge < 0 {
+		return fmt.Errorf("server.cors.max_age cannot be negative")
+	}
+
 	// Валидируем уровень логирования
 	if !isValidLogLevel(c.Logging.Level) {
 		return fmt.Errorf("invalid logging level: %s", c.Logging.Level)
 	}
+	if err := c.Logging.File.Validate(); err != nil {
+		return fmt.Errorf("logging.file: %w", err)
+	}
+	if err := c.Logging.AccessLog.File.Validate(); err != nil {
+		return fmt.Errorf("logging.access_log.file: %w", err)
+	}
 
 	// Валидируем конфигурации модулей
 	if err := c.Auth.Validate(); err != nil {
@@ -130,21 +282,94 @@ func (c *AppConfig) Validate() error {
 		return fmt.Errorf("backend config: %w", err)
 	}
 
+	if err := c.Routing.Validate(); err != nil {
+		return err
+	}
+	if err := c.validatePolicyBackends("routing.policies", c.Routing.Policies); err != nil {
+		return err
+	}
+	for bucket, policies := range c.Routing.BucketPolicies {
+		if err := c.validatePolicyBackends("routing.bucket_policies."+bucket, policies); err != nil {
+			return err
+		}
+	}
+
 	if err := c.Monitoring.Validate(); err != nil {
 		return fmt.Errorf("monitoring config: %w", err)
 	}
 
+	if err := c.Replicator.Validate(); err != nil {
+		return fmt.Errorf("replicator config: %w", err)
+	}
+
+	if err := c.Tracing.Validate(); err != nil {
+		return fmt.Errorf("tracing config: %w", err)
+	}
+
+	return nil
+}
+
+// validatePolicyBackends проверяет ссылки политик на бэкенды и ограничения их параметров
+func (c *AppConfig) validatePolicyBackends(prefix string, policies routing.Policies) error {
+	for name, policy := range map[string]routing.WriteOperationPolicy{"put": policies.Put, "delete": policies.Delete} {
+		if policy.PreferredBackend != "" {
+			if _, ok := c.Backend.Backends[policy.PreferredBackend]; !ok {
+				return fmt.Errorf("%s.%s.preferred_backend: unknown backend %q", prefix, name, policy.PreferredBackend)
+			}
+		}
+		if policy.PreferredWait < 0 {
+			return fmt.Errorf("%s.%s.preferred_wait cannot be negative", prefix, name)
+		}
+		for contentType, ids := range policy.ContentTypeBackends {
+			// Ключ сравнивается с типом запроса без параметров в нижнем регистре
+			if mediaType, params, err := mime.ParseMediaType(contentType); err != nil || mediaType != contentType || len(params) > 0 {
+				return fmt.Errorf("%s.%s.content_type_backends: invalid content type %q (expected lowercase type/subtype or type/*)", prefix, name, contentType)
+			}
+			if len(ids) == 0 {
+				return fmt.Errorf("%s.%s.content_type_backends.%s: at least one backend is required", prefix, name, contentType)
+			}
+			for _, id := range ids {
+				if _, ok := c.Backend.Backends[id]; !ok {
+					return fmt.Errorf("%s.%s.content_type_backends.%s: unknown backend %q", prefix, name, contentType, id)
+				}
+			}
+		}
+	}
+
+	if policies.Get.MinRespondingBackends > len(c.Backend.Backends) {
+		return fmt.Errorf("%s.get.min_responding_backends (%d) exceeds the number of backends (%d)",
+			prefix, policies.Get.MinRespondingBackends, len(c.Backend.Backends))
+	}
+	if policies.Get.RedirectThreshold < 0 {
+		return fmt.Errorf("%s.get.redirect_threshold cannot be negative", prefix)
+	}
+	if policies.Get.RedirectExpiry < 0 {
+		return fmt.Errorf("%s.get.redirect_expiry cannot be negative", prefix)
+	}
+	for _, id := range policies.Get.ShadowBackends {
+		if _, ok := c.Backend.Backends[id]; !ok {
+			return fmt.Errorf("%s.get.shadow_backends: unknown backend %q", prefix, id)
+		}
+	}
 	return nil
 }
 
 // ToAPIGatewayConfig преобразует в конфигурацию API Gateway
 func (c *AppConfig) ToAPIGatewayConfig() apigw.Config {
 	return apigw.Config{
-		ListenAddress: c.Server.ListenAddress,
-		TLSCertFile:   c.Server.TLSCertFile,
-		TLSKeyFile:    c.Server.TLSKeyFile,
-		ReadTimeout:   c.Server.ReadTimeout,
-		WriteTimeout:  c.Server.WriteTimeout,
+		ListenAddress:        c.Server.ListenAddress,
+		TLSCertFile:          c.Server.TLSCertFile,
+		TLSKeyFile:           c.Server.TLSKeyFile,
+		ReadTimeout:          c.Server.ReadTimeout,
+		WriteTimeout:         c.Server.WriteTimeout,
+		BaseDomain:           c.Server.BaseDomain,
+		MaxObjectSize:        c.Server.MaxObjectSize,
+		MaxRequestsPerClient: c.Server.MaxRequestsPerClient,
+		TrailerChecksum:      c.Server.TrailerChecksum,
+		BodyLengthCheck:      c.Server.BodyLengthCheck,
+		OptionsResponse:      c.Server.OptionsResponse,
+		CORS:                 c.Server.CORS,
+		RecentRequests:       c.Server.RecentRequests,
 	}
 }
 
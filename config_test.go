@@ -0,0 +1,168 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"s3proxy/replicator"
+)
+
+// loadReplicatorTestConfig загружает тестовую конфигурацию с секцией replicator
+func loadReplicatorTestConfig(t *testing.T, section string) (*AppConfig, error) {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeReloadTestConfig(t, path, "one", "OLDKEY:old-secret")
+	content, _ := os.ReadFile(path)
+	content = append(content, []byte("replicator:\n"+section)...)
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+	return LoadConfig(path)
+}
+
+func TestLoadConfig_Replicator(t *testing.T) {
+	config, err := loadReplicatorTestConfig(t, "  bucket_operations: true\n")
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if !config.Replicator.BucketOperations {
+		t.Error("Expected replicator.bucket_operations to be enabled")
+	}
+
+	// Параметры, не заданные в файле, берутся по умолчанию
+	defaults := replicator.DefaultConfig()
+	if config.Replicator.OperationTimeout != defaults.OperationTimeout {
+		t.Errorf("Expected default operation_timeout %v, got %v", defaults.OperationTimeout, config.Replicator.OperationTimeout)
+	}
+	if config.Replicator.MultipartUploadTTL != defaults.MultipartUploadTTL {
+		t.Errorf("Expected default multipart_upload_ttl %v, got %v", defaults.MultipartUploadTTL, config.Replicator.MultipartUploadTTL)
+	}
+
+	// Конфигурация без секции replicator получает значения по умолчанию целиком
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeReloadTestConfig(t, path, "one", "OLDKEY:old-secret")
+	config, err = LoadConfig(path)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if config.Replicator != *defaults {
+		t.Errorf("Expected default replicator config, got %+v", config.Replicator)
+	}
+}
+
+func TestLoadConfig_AckAllTimeout(t *testing.T) {
+	config, err := loadReplicatorTestConfig(t, "  ack_all_timeout: 10s\n")
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if config.Replicator.AckAllTimeout != 10*time.Second {
+		t.Errorf("Expected ack_all_timeout 10s, got %v", config.Replicator.AckAllTimeout)
+	}
+
+	if _, err := loadReplicatorTestConfig(t, "  ack_all_timeout: -1s\n"); err == nil {
+		t.Error("Expected negative ack_all_timeout to be rejected")
+	}
+}
+
+func TestLoadConfig_ConcurrencyLimits(t *testing.T) {
+	config, err := loadReplicatorTestConfig(t, "  max_concurrent_puts: 10\n  max_concurrent_deletes: 20\n  max_concurrent_parts: 30\n  max_concurrent_bucket_ops: 2\n")
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	limits := []int{config.Replicator.MaxConcurrentPuts, config.Replicator.MaxConcurrentDeletes,
+		config.Replicator.MaxConcurrentParts, config.Replicator.MaxConcurrentBucketOps}
+	for i, expected := range []int{10, 20, 30, 2} {
+		if limits[i] != expected {
+			t.Errorf("Expected limits %v, got %v", []int{10, 20, 30, 2}, limits)
+			break
+		}
+	}
+
+	if _, err := loadReplicatorTestConfig(t, "  max_concurrent_parts: -1\n"); err == nil {
+		t.Error("Expected negative max_concurrent_parts to be rejected")
+	}
+}
+
+func TestLoadConfig_Buffering(t *testing.T) {
+	config, err := loadReplicatorTestConfig(t, "  spill_threshold: 1048576\n  spill_dir: /var/tmp\n  clone_buffer_size: 0\n"+
+		"  max_buffered_part_size: 65536\n  check_bucket_empty_before_delete: true\n")
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if config.Replicator.SpillThreshold != 1048576 || config.Replicator.SpillDir != "/var/tmp" {
+		t.Errorf("Expected spill_threshold 1048576 in /var/tmp, got %d in %q", config.Replicator.SpillThreshold, config.Replicator.SpillDir)
+	}
+	// Явный 0 заменяет значение по умолчанию
+	if config.Replicator.CloneBufferSize != 0 {
+		t.Errorf("Expected clone_buffer_size 0, got %d", config.Replicator.CloneBufferSize)
+	}
+	if config.Replicator.MaxBufferedPartSize != 65536 {
+		t.Errorf("Expected max_buffered_part_size 65536, got %d", config.Replicator.MaxBufferedPartSize)
+	}
+	if !config.Replicator.CheckBucketEmptyBeforeDelete {
+		t.Error("Expected replicator.check_bucket_empty_before_delete to be enabled")
+	}
+
+	if _, err := loadReplicatorTestConfig(t, "  spill_threshold: -1\n"); err == nil {
+		t.Error("Expected negative spill_threshold to be rejected")
+	}
+}
+
+func TestLoadConfig_VerifyETag(t *testing.T) {
+	config, err := loadReplicatorTestConfig(t, "  verify_etag: true\n")
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if !config.Replicator.VerifyETag {
+		t.Error("Expected replicator.verify_etag to be enabled")
+	}
+}
+
+func TestLoadConfig_ContentType(t *testing.T) {
+	config, err := loadReplicatorTestConfig(t, "  guess_content_type: true\n  default_content_type: application/octet-stream\n")
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if !config.Replicator.GuessContentType {
+		t.Error("Expected replicator.guess_content_type to be enabled")
+	}
+	if config.Replicator.DefaultContentType != "application/octet-stream" {
+		t.Errorf("Expected default_content_type application/octet-stream, got %q", config.Replicator.DefaultContentType)
+	}
+
+	_, err = loadReplicatorTestConfig(t, "  default_content_type: \"not a type\"\n")
+	if err == nil || !strings.Contains(err.Error(), "default_content_type") {
+		t.Errorf("Expected default_content_type validation error, got %v", err)
+	}
+}
+
+func TestLoadConfig_AsyncReplication(t *testing.T) {
+	config, err := loadReplicatorTestConfig(t, "  async_replication: true\n  async_queue_size: 50\n  async_max_attempts: 10\n")
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	if !config.Replicator.AsyncReplication {
+		t.Error("Expected replicator.async_replication to be enabled")
+	}
+	if config.Replicator.AsyncQueueSize != 50 || config.Replicator.AsyncMaxAttempts != 10 {
+		t.Errorf("Expected async_queue_size 50 and async_max_attempts 10, got %d and %d",
+			config.Replicator.AsyncQueueSize, config.Replicator.AsyncMaxAttempts)
+	}
+
+	// Задержки очереди проверяются только при включенной репликации
+	_, err = loadReplicatorTestConfig(t, "  async_replication: true\n  async_retry_delay: 1m\n  async_max_retry_delay: 1s\n")
+	if err == nil || !strings.Contains(err.Error(), "async_max_retry_delay") {
+		t.Errorf("Expected async_max_retry_delay validation error, got %v", err)
+	}
+}
+
+func TestLoadConfig_InvalidReplicator(t *testing.T) {
+	_, err := loadReplicatorTestConfig(t, "  operation_timeout: 0s\n")
+	if err == nil || !strings.Contains(err.Error(), "replicator config: operation_timeout") {
+		t.Fatalf("Expected replicator validation error, got %v", err)
+	}
+}
@@ -15,3 +15,8 @@ func NewStubCache() *StubCache {
 func (s *StubCache) Get(bucket, key string) (*apigw.S3Response, bool) {
 	return nil, false
 }
+
+// Stats для заглушки всегда возвращает пустой кэш.
+func (s *StubCache) Stats() (int, int64) {
+	return 0, 0
+}
@@ -0,0 +1,28 @@
+package fetch
+
+import "context"
+
+// SetMaxConcurrentRequests ограничивает число одновременных запросов Fetcher к бэкендам.
+// Запрос занимает место до получения ответа бэкенда: передача тела GET клиенту
+// в лимит не входит. 0 - без ограничения (по умолчанию).
+func (f *Fetcher) SetMaxConcurrentRequests(limit int) {
+	if limit <= 0 {
+		f.requests = nil
+		return
+	}
+	f.requests = make(chan struct{}, limit)
+}
+
+// acquire занимает место для запроса к бэкенду. Возвращает функцию освобождения
+// или ошибку контекста, если место не освободилось до его завершения.
+func (f *Fetcher) acquire(ctx context.Context) (func(), error) {
+	if f.requests == nil {
+		return func() {}, nil
+	}
+	select {
+	case f.requests <- struct{}{}:
+		return func() { <-f.requests }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
@@ -0,0 +1,77 @@
+package fetch
+
+import (
+	"errors"
+	"net/http"
+
+	"s3proxy/apigw"
+
+	"github.com/aws/smithy-go"
+	smithyhttp "github.com/aws/smithy-go/transport/http"
+)
+
+// s3ErrorMessages - стандартные сообщения S3 для кодов ошибок, которые формирует Fetcher
+var s3ErrorMessages = map[string]string{
+	"NoSuchKey":    "The specified key does not exist.",
+	"NoSuchBucket": "The specified bucket does not exist.",
+	"NoSuchUpload": "The specified upload does not exist. The upload ID may be invalid, or the upload may have been aborted or completed.",
+	"AccessDenied": "Access Denied",
+
+	"PreconditionFailed": "At least one of the pre-conditions you specified did not hold",
+	"InvalidRange":       "The requested range is not satisfiable",
+}
+
+// s3ErrorResponse формирует ответ с XML телом ошибки S3 (Code, Message, Resource, RequestId),
+// как ResponseWriter для ошибок записи. Ответ формируется целиком здесь, поэтому Error не устанавливается.
+func s3ErrorResponse(req *apigw.S3Request, statusCode int, code string) *apigw.S3Response {
+	return apigw.NewErrorResponse(req.RequestID, apigw.ResourcePath(req.Bucket, req.Key),
+		statusCode, code, s3ErrorMessages[code])
+}
+
+// missingResourceCode возвращает код ошибки 404 для запроса: NoSuchBucket для операций
+// с бакетом, NoSuchKey для операций с объектом
+func missingResourceCode(req *apigw.S3Request) string {
+	if req.Key == "" {
+		return "NoSuchBucket"
+	}
+	return "NoSuchKey"
+}
+
+// handleS3Error переводит ошибку бэкенда в ответ клиенту. Для частых ошибок (нет объекта,
+// бакета, загрузки, нет доступа, не выполнено условие или Range) формируется ответ S3
+// с исходным статусом, остальные возвращаются как 500.
+func (f *Fetcher) handleS3Error(req *apigw.S3Request, err error) *apigw.S3Response {
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		switch code := apiErr.ErrorCode(); code {
+		case "NotFound":
+			// HEAD не возвращает тело, и SDK не знает, чего именно нет
+			return s3ErrorResponse(req, http.StatusNotFound, missingResourceCode(req))
+		case "NoSuchKey", "NoSuchBucket", "NoSuchUpload":
+			return s3ErrorResponse(req, http.StatusNotFound, code)
+		case "AccessDenied", "Forbidden":
+			return s3ErrorResponse(req, http.StatusForbidden, "AccessDenied")
+		}
+	}
+	// Ответы на условия и Range клиента SDK возвращает ошибками; клиенту они отдаются
+	// с исходным статусом
+	var respErr interface {
+		HTTPStatusCode() int
+		HTTPResponse() *smithyhttp.Response
+	}
+	if errors.As(err, &respErr) {
+		switch respErr.HTTPStatusCode() {
+		case http.StatusNotModified:
+			var header http.Header
+			if resp := respErr.HTTPResponse(); resp != nil && resp.Response != nil {
+				header = resp.Header
+			}
+			return notModifiedResponse(header)
+		case http.StatusPreconditionFailed:
+			return s3ErrorResponse(req, http.StatusPreconditionFailed, "PreconditionFailed")
+		case http.StatusRequestedRangeNotSatisfiable:
+			return s3ErrorResponse(req, http.StatusRequestedRangeNotSatisfiable, "InvalidRange")
+		}
+	}
+	return &apigw.S3Response{StatusCode: http.StatusInternalServerError, Error: err}
+}
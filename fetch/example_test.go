@@ -10,13 +10,9 @@ import (
 	"s3proxy/apigw"
 	"s3proxy/backend"
 	"s3proxy/fetch"
-	"s3proxy/monitoring"
 	"s3proxy/routing"
 )
 
-// Глобальный экземпляр метрик для примеров
-var globalMetrics = monitoring.NewMetrics()
-
 // ExampleFetcher демонстрирует основное использование Fetching Module
 func ExampleFetcher() {
 	// 1. Создание зависимостей
@@ -42,7 +38,7 @@ func ExampleFetcher() {
 		},
 	}
 	
-	backendManager, err := backend.NewManager(backendConfig, globalMetrics)
+	backendManager, err := backend.NewManager(backendConfig)
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -51,7 +47,7 @@ func ExampleFetcher() {
 	cache := fetch.NewStubCache()
 	
 	// 2. Создание Fetcher
-	fetcher := fetch.NewFetcher(backendManager, cache, globalMetrics)
+	fetcher := fetch.NewFetcher(backendManager, cache, "my-bucket")
 	
 	// 3. Создание тестового запроса
 	req := &apigw.S3Request{
@@ -88,10 +84,13 @@ func ExampleFetcher() {
 	response = fetcher.ListObjects(context.Background(), listReq)
 	fmt.Printf("LIST Objects response status: %d\n", response.StatusCode)
 	
+	// Менеджер не запущен, поэтому бэкенды остаются в состоянии PROBING
+	// и не считаются живыми - Fetcher отвечает 503.
+
 	// Output:
-	// GET Object response status: 404
-	// HEAD Object response status: 404
-	// LIST Objects response status: 200
+	// GET Object response status: 503
+	// HEAD Object response status: 503
+	// LIST Objects response status: 503
 }
 
 // ExampleFetcher_withCache демонстрирует работу с кэшем
@@ -110,10 +109,10 @@ func ExampleFetcher_withCache() {
 		},
 	}
 	
-	backendManager, _ := backend.NewManager(backendConfig, globalMetrics)
+	backendManager, _ := backend.NewManager(backendConfig)
 	cache := fetch.NewStubCache() // Заглушка всегда возвращает "не найдено"
 	
-	fetcher := fetch.NewFetcher(backendManager, cache, globalMetrics)
+	fetcher := fetch.NewFetcher(backendManager, cache, "my-bucket")
 	
 	// Запрос к объекту (кэш промахнется, пойдем к бэкендам)
 	req := &apigw.S3Request{
@@ -131,7 +130,7 @@ func ExampleFetcher_withCache() {
 	fmt.Printf("Cache miss response status: %d\n", response.StatusCode)
 	
 	// Output:
-	// Cache miss response status: 404
+	// Cache miss response status: 503
 }
 
 // ExampleFetcher_strategies демонстрирует различные стратегии чтения
@@ -149,10 +148,10 @@ func ExampleFetcher_strategies() {
 		},
 	}
 	
-	backendManager, _ := backend.NewManager(backendConfig, globalMetrics)
+	backendManager, _ := backend.NewManager(backendConfig)
 	cache := fetch.NewStubCache()
 	
-	fetcher := fetch.NewFetcher(backendManager, cache, globalMetrics)
+	fetcher := fetch.NewFetcher(backendManager, cache, "my-bucket")
 	
 	req := &apigw.S3Request{
 		Operation: apigw.GetObject,
@@ -174,8 +173,8 @@ func ExampleFetcher_strategies() {
 	fmt.Printf("Newest strategy response status: %d\n", response.StatusCode)
 	
 	// Output:
-	// First strategy response status: 404
-	// Newest strategy response status: 404
+	// First strategy response status: 503
+	// Newest strategy response status: 503
 }
 
 // ExampleProxyContinuationToken демонстрирует работу с токенами пагинации
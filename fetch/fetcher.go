@@ -1,20 +1,26 @@
 package fetch
 
 import (
+	"bytes"
 	"context"
-	"errors"
+	"encoding/xml"
 	"fmt"
 	"io"
 	"net/http"
+	"sort"
+	"strconv"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
-	"github.com/aws/smithy-go"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 
 	"s3proxy/apigw"
 	"s3proxy/backend"
+	"s3proxy/logger"
 	"s3proxy/routing"
 )
 
@@ -27,6 +33,26 @@ type Fetcher struct {
 	backendProvider *backend.Manager
 	cache           Cache
 	virtualBucket   string
+	metrics         *Metrics
+
+	// listMergeThreshold - порог быстрого пути объединения ListObjectsV2 (см. SetListMergeThreshold)
+	listMergeThreshold int
+
+	// newestFallbackUntil - бакет -> до какого момента (UnixNano) "newest" заменяется на "first"
+	newestFallbackUntil sync.Map
+
+	// uploadIDs переводит идентификаторы multipart upload бэкендов в идентификаторы прокси
+	uploadIDs UploadIDResolver
+
+	// listCache - кэш объединенных листингов (nil - выключен, см. SetListCache)
+	listCache *listCache
+
+	// requests - семафор одновременных запросов к бэкендам (nil - без ограничения,
+	// см. SetMaxConcurrentRequests)
+	requests chan struct{}
+
+	// retryAfter - Retry-After ответа 503, когда нет живых бэкендов (см. SetRetryAfter)
+	retryAfter time.Duration
 }
 
 // NewFetcher создает новый экземпляр Fetcher
@@ -35,131 +61,258 @@ func NewFetcher(provider *backend.Manager, cache Cache, virtualBucket string) *F
 		backendProvider: provider,
 		cache:           cache,
 		virtualBucket:   virtualBucket,
+		metrics:         NewMetrics(),
 	}
 }
 
 // --- Публичные методы-диспетчеры ---
 
 func (f *Fetcher) GetObject(ctx context.Context, req *apigw.S3Request, policy routing.ReadOperationPolicy) *apigw.S3Response {
-	if response, found := f.cache.Get(req.Bucket, req.Key); found {
-		return response
+	// Кэш хранит объекты целиком: ответ на Range или условие клиента дает только бэкенд
+	if !hasGetConditions(req) {
+		if response, found := f.lookupCache(req, "GET"); found {
+			return response
+		}
 	}
-	backends := f.backendProvider.GetLiveBackends()
+	backends, shadows := splitShadowBackends(f.backendProvider.GetReadBackends(), policy)
 	if len(backends) == 0 {
-		return f.noBackendsResponse()
+		return f.noBackendsResponse(req)
+	}
+
+	// "first" и "quorum" опрашивают не больше MaxReadFanout бэкендов
+	strategy := f.readStrategy(req, policy, "GET")
+	if strategy == "first" || strategy == "quorum" {
+		backends = selectReadBackends(backends, policy.MaxReadFanout)
+	}
+
+	// Большой объект отдается редиректом до того, как GET начат на бэкенде.
+	// Range и условные GET всегда проксируются: их ответ не совпадает с объектом целиком
+	if policy.RedirectThreshold > 0 && !hasGetConditions(req) {
+		if response, servedBy := f.redirectLargeObject(ctx, req, backends, strategy, policy); response != nil {
+			if policy.ExposeServedBy {
+				setServedBy(response, servedBy)
+			}
+			return response
+		}
+	}
+
+	var tracker *responseTracker
+	if policy.MinRespondingBackends > 0 {
+		ctx, tracker = withResponseTracker(ctx, policy.MinRespondingBackends, len(backends))
 	}
 
-	switch policy.Strategy {
+	var response *apigw.S3Response
+	var servedBy *backend.Backend
+	switch strategy {
 	case "first":
-		return f.executeFirst(ctx, req, backends, f.performGetObject, "GET", "object not found on any backend")
+		if policy.HedgeDelay > 0 {
+			response, servedBy = f.executeHedged(ctx, req, backends, f.performGetObject, "GET", "NoSuchKey", policy.HedgeDelay)
+		} else {
+			response, servedBy = f.executeFirst(ctx, req, backends, f.performGetObject, "GET", "NoSuchKey", policy.CancelLosers)
+		}
 	case "newest":
-		return f.executeNewest(ctx, req, backends, true) // true -> выполнить GET после HEAD
+		response, servedBy = f.executeNewest(ctx, req, backends, true, policy) // true -> выполнить GET после HEAD
+	case "quorum":
+		response, servedBy = f.executeQuorum(ctx, req, backends, true)
 	default:
-		return f.unknownStrategyResponse(policy.Strategy)
+		return f.unknownStrategyResponse(strategy)
+	}
+	if tracker != nil {
+		response = f.enforceMinResponding(ctx, req, response, tracker, policy, "GET")
+	}
+
+	f.startShadowReads(req, shadows, f.performGetObject, "GET", response, policy)
+	if policy.ExposeServedBy {
+		setServedBy(response, servedBy)
 	}
+	return response
 }
 
 func (f *Fetcher) HeadObject(ctx context.Context, req *apigw.S3Request, policy routing.ReadOperationPolicy) *apigw.S3Response {
-	if response, found := f.cache.Get(req.Bucket, req.Key); found {
+	if response, found := f.lookupCache(req, "HEAD"); found {
 		response.Body = nil // Убираем тело для HEAD
 		return response
 	}
-	backends := f.backendProvider.GetLiveBackends()
+	backends, shadows := splitShadowBackends(f.backendProvider.GetReadBackends(), policy)
 	if len(backends) == 0 {
-		return f.noBackendsResponse()
+		return f.noBackendsResponse(req)
+	}
+
+	// "first" и "quorum" опрашивают не больше MaxReadFanout бэкендов
+	strategy := f.readStrategy(req, policy, "HEAD")
+	if strategy == "first" || strategy == "quorum" {
+		backends = selectReadBackends(backends, policy.MaxReadFanout)
+	}
+	var tracker *responseTracker
+	if policy.MinRespondingBackends > 0 {
+		ctx, tracker = withResponseTracker(ctx, policy.MinRespondingBackends, len(backends))
 	}
 
-	switch policy.Strategy {
+	var response *apigw.S3Response
+	var servedBy *backend.Backend
+	switch strategy {
 	case "first":
-		return f.executeFirst(ctx, req, backends, f.performHeadObject, "HEAD", "object not found on any backend")
+		if policy.HedgeDelay > 0 {
+			response, servedBy = f.executeHedged(ctx, req, backends, f.performHeadObject, "HEAD", "NoSuchKey", policy.HedgeDelay)
+		} else {
+			response, servedBy = f.executeFirst(ctx, req, backends, f.performHeadObject, "HEAD", "NoSuchKey", policy.CancelLosers)
+		}
 	case "newest":
-		return f.executeNewest(ctx, req, backends, false) // false -> не выполнять GET, вернуть результат HEAD
+		response, servedBy = f.executeNewest(ctx, req, backends, false, policy) // false -> не выполнять GET, вернуть результат HEAD
+	case "quorum":
+		response, servedBy = f.executeQuorum(ctx, req, backends, false)
 	default:
-		return f.unknownStrategyResponse(policy.Strategy)
+		return f.unknownStrategyResponse(strategy)
+	}
+	if tracker != nil {
+		response = f.enforceMinResponding(ctx, req, response, tracker, policy, "HEAD")
+	}
+
+	f.startShadowReads(req, shadows, f.performHeadObject, "HEAD", response, policy)
+	if policy.ExposeServedBy {
+		setServedBy(response, servedBy)
+	}
+	return response
+}
+
+// lookupCache ищет объект в кэше и учитывает попадание или промах в метриках операции
+func (f *Fetcher) lookupCache(req *apigw.S3Request, operation string) (*apigw.S3Response, bool) {
+	response, found := f.cache.Get(req.Bucket, req.Key)
+	if found {
+		f.metrics.CacheHitsTotal.WithLabelValues(operation).Inc()
+	} else {
+		f.metrics.CacheMissesTotal.WithLabelValues(operation).Inc()
 	}
+	if stats, ok := f.cache.(CacheStats); ok {
+		entries, size := stats.Stats()
+		f.metrics.CacheEntries.Set(float64(entries))
+		f.metrics.CacheSizeBytes.Set(float64(size))
+	}
+	return response, found
 }
 
 func (f *Fetcher) HeadBucket(ctx context.Context, req *apigw.S3Request) *apigw.S3Response {
-	backends := f.backendProvider.GetLiveBackends()
+	backends := f.backendProvider.GetReadBackends()
+	if len(backends) == 0 {
+		return f.noBackendsResponse(req)
+	}
+	response, _ := f.executeFirst(ctx, req, backends, f.performHeadBucket, "HEAD_BUCKET", "NoSuchBucket", false)
+	return response
+}
+
+// GetObjectTagging возвращает теги объекта с первого ответившего бэкенда.
+// Теги реплицируются вместе с PUT/DELETE ?tagging, поэтому достаточно одного ответа.
+func (f *Fetcher) GetObjectTagging(ctx context.Context, req *apigw.S3Request) *apigw.S3Response {
+	backends := f.backendProvider.GetReadBackends()
 	if len(backends) == 0 {
-		return f.noBackendsResponse()
+		return f.noBackendsResponse(req)
 	}
-	return f.executeFirst(ctx, req, backends, f.performHeadBucket, "HEAD_BUCKET", "bucket not found on any backend")
+	response, _ := f.executeFirst(ctx, req, backends, f.performGetObjectTagging, "GET_TAGGING", "NoSuchKey", false)
+	return response
 }
 
 // ... другие методы List* можно отрефакторить аналогично, если они имеют схожие стратегии ...
 // (Оставляю их как есть для краткости, так как они не были причиной паники)
 func (f *Fetcher) ListObjects(ctx context.Context, req *apigw.S3Request) *apigw.S3Response {
-	backends := f.backendProvider.GetLiveBackends()
+	backends := f.backendProvider.GetReadBackends()
 	if len(backends) == 0 {
-		return &apigw.S3Response{
-			StatusCode: http.StatusServiceUnavailable,
-			Headers:    make(http.Header),
-			Error:      fmt.Errorf("no live backends available"),
-		}
+		return f.noBackendsResponse(req)
 	}
 
-	return f.listObjects(ctx, req, backends)
+	if f.listCache == nil {
+		response, _ := f.listObjects(ctx, req, backends)
+		return response
+	}
+
+	key := listCacheKey(req)
+	if body, found := f.listCache.get(key); found {
+		logger.Debug("ListObjects: served %s/%s* from listing cache", req.Bucket, req.Query.Get("prefix"))
+		f.metrics.CacheHitsTotal.WithLabelValues("LIST_OBJECTS").Inc()
+		return cachedListResponse(body)
+	}
+	f.metrics.CacheMissesTotal.WithLabelValues("LIST_OBJECTS").Inc()
+
+	generation := f.listCache.currentGeneration()
+	// Листинг без ответа хотя бы одного бэкенда не кэшируется: он неполон на весь TTL
+	response, complete := f.listObjects(ctx, req, backends)
+	if complete && response.StatusCode == http.StatusOK && response.Error == nil && response.Body != nil {
+		cache, bucket, prefix := f.listCache, req.Bucket, req.Query.Get("prefix")
+		response.Body = &listCachingBody{
+			ReadCloser: response.Body,
+			store: func(body []byte) {
+				cache.put(generation, key, bucket, prefix, bytes.Clone(body))
+			},
+		}
+	}
+	return response
 }
 
 func (f *Fetcher) ListBuckets(ctx context.Context, req *apigw.S3Request) *apigw.S3Response {
-	backends := f.backendProvider.GetLiveBackends()
+	backends := f.backendProvider.GetReadBackends()
 	if len(backends) == 0 {
-		return &apigw.S3Response{
-			StatusCode: http.StatusServiceUnavailable,
-			Headers:    make(http.Header),
-			Error:      fmt.Errorf("no live backends available"),
-		}
+		return f.noBackendsResponse(req)
 	}
 
 	return f.listBuckets(ctx, req, backends)
 }
 
-func (f *Fetcher) ListMultipartUploads(ctx context.Context, req *apigw.S3Request) *apigw.S3Response { /* ... */
-	return nil
+func (f *Fetcher) ListMultipartUploads(ctx context.Context, req *apigw.S3Request) *apigw.S3Response {
+	backends := f.backendProvider.GetReadBackends()
+	if len(backends) == 0 {
+		return f.noBackendsResponse(req)
+	}
+
+	return f.listMultipartUploads(ctx, req, backends)
 }
 
 // --- Универсальные исполнители стратегий ---
 
-// executeFirst выполняет операцию op на всех бэкендах параллельно и возвращает первый успешный результат.
-// ВАЖНО: Эта версия НЕ отменяет остальные запросы, позволяя им завершиться для сбора
-// статистики (пассивного health-check'а).
-func (f *Fetcher) executeFirst(ctx context.Context, req *apigw.S3Request, backends []*backend.Backend, op backendOperation, methodName, notFoundMsg string) *apigw.S3Response {
-	// НЕ создаем context.WithCancel, чтобы все запросы могли завершиться.
+// executeFirst выполняет операцию op на всех бэкендах параллельно и возвращает первый успешный результат
+// вместе с бэкендом, который его вернул (nil, если успешных ответов не было).
+// ВАЖНО: по умолчанию остальные запросы НЕ отменяются, чтобы они завершились для сбора
+// статистики (пассивного health-check'а). С cancelLosers у каждого запроса свой контекст,
+// и после первого успеха запросы проигравших отменяются: для больших объектов это
+// избавляет от скачивания тела со всех бэкендов. Отмененный запрос учитывается
+// как нейтральный (не влияет на Circuit Breaker).
+func (f *Fetcher) executeFirst(ctx context.Context, req *apigw.S3Request, backends []*backend.Backend, op backendOperation, methodName, notFoundCode string, cancelLosers bool) (*apigw.S3Response, *backend.Backend) {
+	// Без cancelLosers НЕ создаем context.WithCancel, чтобы все запросы могли завершиться.
 	
 	// Буферизованный канал критически важен, чтобы предотвратить утечку горутин.
 	// Медленные горутины смогут записать результат и завершиться.
-	resultChan := make(chan *apigw.S3Response, len(backends))
+	type firstResult struct {
+		index    int
+		response *apigw.S3Response
+		backend  *backend.Backend
+	}
+	resultChan := make(chan firstResult, len(backends))
 	var wg sync.WaitGroup
 
-	for _, be := range backends {
+	cancels := make([]context.CancelFunc, len(backends))
+	var decided atomic.Bool // Победитель уже выбран
+
+	for i, be := range backends {
+		opCtx := ctx
+		if cancelLosers {
+			opCtx, cancels[i] = context.WithCancel(ctx)
+		}
 		wg.Add(1)
-		go func(b *backend.Backend) {
+		go func(index int, opCtx context.Context, b *backend.Backend) {
 			defer wg.Done()
 			start := time.Now()
 			
-			// Передаем родительский контекст `ctx` без изменений.
-			response := op(ctx, req, b)
-			latency := time.Since(start)
-
-			var bytesRead int64
-			if counter, ok := response.Body.(*bytesCountingReader); ok && counter != nil {
-				bytesRead = counter.totalRead
-			}
-
-			isSuccess := response.Error == nil && response.StatusCode >= 200 && response.StatusCode < 300
-			if isSuccess {
-				f.backendProvider.ReportSuccess(&backend.BackendResult{
-					BackendID: b.ID, Method: methodName, StatusCode: response.StatusCode, Duration: latency, BytesRead: bytesRead,
-				})
-				// Просто отправляем результат. Так как канал буферизованный, это не заблокирует горутину.
-				resultChan <- response
-			} else {
+			response := op(opCtx, req, b)
+			if cancelLosers && decided.Load() && opCtx.Err() != nil && ctx.Err() == nil && !isSuccessResponse(response) {
+				// Запрос отменен нами после выбора победителя: это не ошибка бэкенда
 				f.backendProvider.ReportFailure(&backend.BackendResult{
-					BackendID: b.ID, Method: methodName, StatusCode: response.StatusCode, Err: response.Error, Duration: latency, BytesRead: bytesRead,
+					BackendID: b.ID, Method: methodName, StatusCode: response.StatusCode, Err: context.Canceled, Duration: time.Since(start),
 				})
+				return
 			}
-		}(be)
+			if f.reportReadResult(b, methodName, response, time.Since(start)) {
+				// Просто отправляем результат. Так как канал буферизованный, это не заблокирует горутину.
+				resultChan <- firstResult{index: index, response: response, backend: b}
+			}
+		}(i, opCtx, be)
 	}
 
 	// Эта горутина нужна только для того, чтобы закрыть канал после завершения всех воркеров.
@@ -169,21 +322,219 @@ func (f *Fetcher) executeFirst(ctx context.Context, req *apigw.S3Request, backen
 		close(resultChan)
 	}()
 
-	// Ждем первый успешный ответ из канала.
-	if res := <-resultChan; res != nil {
+	// Тела опоздавших успешных ответов никому не нужны. Канал буферизован на все бэкенды,
+	// поэтому воркеры не блокируются и без этой горутины, она только закрывает тела.
+	drainLate := func() {
+		go func() {
+			for late := range resultChan {
+				if late.response.Body != nil {
+					late.response.Body.Close()
+				}
+			}
+		}()
+	}
+	cancelAll := func() {
+		for _, cancel := range cancels {
+			if cancel != nil {
+				cancel()
+			}
+		}
+	}
+
+	// Ждем первый успешный ответ из канала, но не дольше, чем клиент ждет ответа:
+	// медленно падающие бэкенды не должны задерживать отмененный запрос.
+	var res firstResult
+	var ok bool
+	select {
+	case res, ok = <-resultChan:
+	case <-ctx.Done():
+		logger.Debug("executeFirst: %s canceled before any backend answered: %v", methodName, ctx.Err())
+		cancelAll()
+		drainLate()
+		return &apigw.S3Response{StatusCode: http.StatusInternalServerError, Error: ctx.Err()}, nil
+	}
+
+	if ok {
 		// Мы получили самый быстрый ответ.
-		// НЕ вызываем cancel(), а просто возвращаем его.
-		// Остальные горутины продолжат работать в фоне и отправлять отчеты.
-		return res
+		if !cancelLosers {
+			// НЕ вызываем cancel(), а просто возвращаем его.
+			// Остальные горутины продолжат работать в фоне и отправлять отчеты.
+			return res.response, res.backend
+		}
+
+		decided.Store(true)
+		for i, cancel := range cancels {
+			if i != res.index {
+				cancel()
+			}
+		}
+		drainLate()
+		return res.response, res.backend
 	}
 
+	cancelAll()
 	// Сюда мы попадем, только если канал был закрыт и в нем не было ни одного успешного ответа.
-	return &apigw.S3Response{StatusCode: http.StatusNotFound, Error: errors.New(notFoundMsg)}
+	return s3ErrorResponse(req, http.StatusNotFound, notFoundCode), nil
+}
+
+// reportReadResult сообщает результат операции чтения в Backend Manager.
+// Возвращает true, если бэкенд ответил успехом.
+func (f *Fetcher) reportReadResult(b *backend.Backend, methodName string, response *apigw.S3Response, latency time.Duration) bool {
+	var bytesRead int64
+	if counter, ok := response.Body.(*bytesCountingReader); ok && counter != nil {
+		bytesRead = counter.totalRead
+	}
+
+	isSuccess := isSuccessResponse(response)
+	if isSuccess {
+		f.backendProvider.ReportSuccess(&backend.BackendResult{
+			BackendID: b.ID, Method: methodName, StatusCode: response.StatusCode, Duration: latency, BytesRead: bytesRead,
+		})
+	} else {
+		f.backendProvider.ReportFailure(&backend.BackendResult{
+			BackendID: b.ID, Method: methodName, StatusCode: response.StatusCode, Err: response.Error, Duration: latency, BytesRead: bytesRead,
+		})
+	}
+	return isSuccess
+}
+
+// isSuccessResponse сообщает, что бэкенд ответил успешно: 2xx без ошибки или окончательный
+// ответ на условный GET и GET с Range (304, 412, 416). Такие ответы не означают сбой бэкенда
+// и отдаются клиенту как есть, а не заменяются на 404 из-за отсутствия успешных ответов.
+func isSuccessResponse(response *apigw.S3Response) bool {
+	if response.Error != nil {
+		return false
+	}
+	if response.StatusCode >= 200 && response.StatusCode < 300 {
+		return true
+	}
+	return isConditionalResponse(response)
+}
+
+// executeHedged выполняет операцию op с задержкой между бэкендами (hedged-чтение).
+// Бэкенды опрашиваются по убыванию веса: следующий запускается, если предыдущие не ответили
+// за delay или ответили ошибкой. Возвращается первый успешный ответ, остальные запросы
+// отменяются. У каждого запроса свой контекст: отмена проигравших не обрывает тело победителя.
+func (f *Fetcher) executeHedged(ctx context.Context, req *apigw.S3Request, backends []*backend.Backend, op backendOperation, methodName, notFoundCode string, delay time.Duration) (*apigw.S3Response, *backend.Backend) {
+	type hedgedResult struct {
+		index    int
+		response *apigw.S3Response
+		success  bool
+	}
+
+	ordered := orderByWeight(backends)
+	results := make(chan hedgedResult, len(ordered))
+	cancels := make([]context.CancelFunc, 0, len(ordered))
+
+	launch := func() {
+		index := len(cancels)
+		opCtx, cancel := context.WithCancel(ctx)
+		cancels = append(cancels, cancel)
+		go func(b *backend.Backend) {
+			start := time.Now()
+			response := op(opCtx, req, b)
+			results <- hedgedResult{index: index, response: response, success: f.reportReadResult(b, methodName, response, time.Since(start))}
+		}(ordered[index])
+	}
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	resetTimer := func() {
+		if !timer.Stop() {
+			select {
+			case <-timer.C:
+			default:
+			}
+		}
+		timer.Reset(delay)
+	}
+
+	launch()
+	pending := 1
+	for pending > 0 {
+		select {
+		case res := <-results:
+			pending--
+			if res.success {
+				for i, cancel := range cancels {
+					if i != res.index {
+						cancel()
+					}
+				}
+				// Тела опоздавших успешных ответов никому не нужны
+				go func(remaining int) {
+					for ; remaining > 0; remaining-- {
+						if late := <-results; late.success && late.response.Body != nil {
+							late.response.Body.Close()
+						}
+					}
+				}(pending)
+				logger.Debug("executeHedged: %s served by backend %s after %d request(s)", methodName, ordered[res.index].ID, len(cancels))
+				return res.response, ordered[res.index]
+			}
+			// Ошибка: не ждем задержку и сразу опрашиваем следующий бэкенд
+			if len(cancels) < len(ordered) {
+				launch()
+				pending++
+				resetTimer()
+			}
+		case <-timer.C:
+			if len(cancels) < len(ordered) {
+				launch()
+				pending++
+				timer.Reset(delay)
+			}
+		}
+	}
+
+	for _, cancel := range cancels {
+		cancel()
+	}
+	return s3ErrorResponse(req, http.StatusNotFound, notFoundCode), nil
+}
+
+// orderByWeight упорядочивает бэкенды по убыванию веса, при равном весе - по возрастанию
+// сглаженной задержки (как selectReadBackends)
+func orderByWeight(backends []*backend.Backend) []*backend.Backend {
+	latencies := make(map[string]time.Duration, len(backends))
+	for _, b := range backends {
+		latencies[b.ID] = b.GetAverageLatency()
+	}
+
+	sorted := make([]*backend.Backend, len(backends))
+	copy(sorted, backends)
+	sort.Slice(sorted, func(i, j int) bool {
+		if wi, wj := sorted[i].Config.Weight, sorted[j].Config.Weight; wi != wj {
+			return wi > wj
+		}
+		li, lj := latencies[sorted[i].ID], latencies[sorted[j].ID]
+		if (li == 0) != (lj == 0) {
+			return lj == 0
+		}
+		if li != lj {
+			return li < lj
+		}
+		return sorted[i].ID < sorted[j].ID
+	})
+	return sorted
 }
 
 // executeNewest находит самый новый объект среди всех бэкендов и либо возвращает его (performGet=true),
 // либо возвращает результат HEAD запроса к нему (performGet=false).
-func (f *Fetcher) executeNewest(ctx context.Context, req *apigw.S3Request, backends []*backend.Backend, performGet bool) *apigw.S3Response {
+// Если policy.ReadRepair=true, бэкенды без объекта или с устаревшей копией восстанавливаются в фоне.
+// Длительность фазы HEAD сообщается observeNewestHeadPhase (деградация до "first" под нагрузкой).
+// Вторым значением возвращается бэкенд с самой новой версией (nil, если объект не найден).
+func (f *Fetcher) executeNewest(ctx context.Context, req *apigw.S3Request, backends []*backend.Backend, performGet bool, policy routing.ReadOperationPolicy) (*apigw.S3Response, *backend.Backend) {
+	// С одним бэкендом выбирать не из чего и восстанавливать нечего: фаза HEAD
+	// перед GET только удвоила бы число запросов
+	if performGet && len(backends) == 1 {
+		response := f.performGetObject(ctx, req, backends[0])
+		if !isSuccessResponse(response) {
+			return response, nil
+		}
+		return response, backends[0]
+	}
+
 	type headResult struct {
 		response     *apigw.S3Response
 		backend      *backend.Backend
@@ -194,15 +545,17 @@ func (f *Fetcher) executeNewest(ctx context.Context, req *apigw.S3Request, backe
 	var wg sync.WaitGroup
 
 	// Фаза 1: HEAD запросы ко всем бэкендам
+	headStart := time.Now()
 	for _, be := range backends {
 		wg.Add(1)
 		go func(b *backend.Backend) {
 			defer wg.Done()
 			response := f.performHeadObject(ctx, req, b)
+			var lastModified time.Time
 			if response.Error == nil && response.StatusCode == http.StatusOK {
-				lastModified, _ := time.Parse(time.RFC1123, response.Headers.Get("Last-Modified"))
-				resultsChan <- headResult{response: response, backend: b, lastModified: lastModified}
+				lastModified, _ = time.Parse(time.RFC1123, response.Headers.Get("Last-Modified"))
 			}
+			resultsChan <- headResult{response: response, backend: b, lastModified: lastModified}
 		}(be)
 	}
 
@@ -213,101 +566,423 @@ func (f *Fetcher) executeNewest(ctx context.Context, req *apigw.S3Request, backe
 
 	// Фаза 2: Находим самый новый объект
 	var newest *headResult
+	var found []headResult
+	var missing []*backend.Backend
 	for result := range resultsChan {
+		if result.response.Error != nil || result.response.StatusCode != http.StatusOK {
+			// Кандидатами на восстановление считаются только бэкенды, уверенно ответившие 404.
+			// Бэкенд, вернувший ошибку, может хранить актуальную копию.
+			if result.response.StatusCode == http.StatusNotFound {
+				missing = append(missing, result.backend)
+			}
+			continue
+		}
+		found = append(found, result)
 		if newest == nil || result.lastModified.After(newest.lastModified) {
 			resCopy := result // Копируем, чтобы избежать проблем с замыканием
 			newest = &resCopy
 		}
 	}
+	f.observeNewestHeadPhase(req, policy, time.Since(headStart))
 
 	if newest == nil {
-		return &apigw.S3Response{StatusCode: http.StatusNotFound, Error: fmt.Errorf("object not found on any backend")}
+		return s3ErrorResponse(req, http.StatusNotFound, "NoSuchKey"), nil
+	}
+
+	if policy.ReadRepair {
+		stale := missing
+		for _, result := range found {
+			if result.lastModified.Before(newest.lastModified) {
+				stale = append(stale, result.backend)
+			}
+		}
+		if len(stale) > 0 {
+			f.repairStaleBackends(req.Key, newest.backend, stale)
+		}
 	}
 
 	// Фаза 3: Выполняем GET (если нужно) или возвращаем результат HEAD
 	if performGet {
-		return f.performGetObject(ctx, req, newest.backend)
+		return f.performGetObject(ctx, req, newest.backend), newest.backend
 	}
-	return newest.response
+	return newest.response, newest.backend
 }
 
-// --- Функции для выполнения конкретных S3 операций ---
+// executeQuorum отдает объект, только если большинство бэкендов согласны в его ETag.
+// Все бэкенды опрашиваются HEAD запросом; бэкенд, ответивший 404, голосует за отсутствие объекта,
+// а бэкенд, ответивший ошибкой, не голосует, но учитывается в общем числе.
+// Если большинства нет, возвращается 409 с описанием ответов каждого бэкенда.
+// Вторым значением возвращается бэкенд, с которого отдан объект (nil, если объект не отдан).
+func (f *Fetcher) executeQuorum(ctx context.Context, req *apigw.S3Request, backends []*backend.Backend, performGet bool) (*apigw.S3Response, *backend.Backend) {
+	type headResult struct {
+		response *apigw.S3Response
+		backend  *backend.Backend
+	}
 
-func (f *Fetcher) performGetObject(ctx context.Context, req *apigw.S3Request, backend *backend.Backend) *apigw.S3Response {
-	input := &s3.GetObjectInput{Bucket: aws.String(backend.Config.Bucket), Key: aws.String(req.Key)}
-	result, err := backend.S3Client.GetObject(ctx, input)
+	resultsChan := make(chan headResult, len(backends))
+	var wg sync.WaitGroup
+
+	// Фаза 1: HEAD запросы ко всем бэкендам
+	for _, be := range backends {
+		wg.Add(1)
+		go func(b *backend.Backend) {
+			defer wg.Done()
+			resultsChan <- headResult{response: f.performHeadObject(ctx, req, b), backend: b}
+		}(be)
+	}
+
+	go func() {
+		wg.Wait()
+		close(resultsChan)
+	}()
+
+	// Фаза 2: Подсчет голосов
+	const notFoundVote = "404 Not Found"
+	votes := make(map[string][]headResult)
+	answers := make(map[string]string, len(backends))
+	for result := range resultsChan {
+		switch {
+		case result.response.Error == nil && result.response.StatusCode == http.StatusOK:
+			etag := result.response.Headers.Get("ETag")
+			votes[etag] = append(votes[etag], result)
+			answers[result.backend.ID] = etag
+		case result.response.StatusCode == http.StatusNotFound:
+			votes[notFoundVote] = append(votes[notFoundVote], result)
+			answers[result.backend.ID] = notFoundVote
+		default:
+			answers[result.backend.ID] = fmt.Sprintf("error: %v", result.response.Error)
+		}
+	}
+
+	quorum := len(backends)/2 + 1
+	for vote, results := range votes {
+		if len(results) < quorum {
+			continue
+		}
+		if vote == notFoundVote {
+			return s3ErrorResponse(req, http.StatusNotFound, "NoSuchKey"), nil
+		}
+
+		// Фаза 3: Выполняем GET (если нужно) или возвращаем результат HEAD
+		logger.Debug("[%s] Quorum reached for object %s: %d of %d backends agree on ETag %s", req.RequestID, req.Key, len(results), len(backends), vote)
+		if performGet {
+			return f.performGetObject(ctx, req, results[0].backend), results[0].backend
+		}
+		return results[0].response, results[0].backend
+	}
+
+	logger.Warn("[%s] Quorum not reached for object %s: %d backends, quorum %d", req.RequestID, req.Key, len(backends), quorum)
+	return f.quorumConflictResponse(req, quorum, answers), nil
+}
+
+// --- Read repair ---
+
+// readRepairTimeout ограничивает время копирования одного объекта на отставший бэкенд
+const readRepairTimeout = 5 * time.Minute
+
+// repairStaleBackends запускает фоновое копирование объекта key с бэкенда source
+// на каждый из бэкендов stale. Не блокирует вызывающего.
+func (f *Fetcher) repairStaleBackends(key string, source *backend.Backend, stale []*backend.Backend) {
+	for _, target := range stale {
+		logger.Info("Read repair: copying object %s from backend %s to stale backend %s", key, source.ID, target.ID)
+		go f.repairBackend(key, source, target)
+	}
+}
+
+// repairBackend читает объект с source и записывает его на target.
+// Результат записи сообщается менеджеру бэкендов как результат PUT на target.
+func (f *Fetcher) repairBackend(key string, source, target *backend.Backend) {
+	// Контекст клиентского запроса к этому моменту уже может быть завершен
+	ctx, cancel := context.WithTimeout(context.Background(), readRepairTimeout)
+	defer cancel()
+
+	getStart := time.Now()
+	object, err := source.S3Client.GetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(source.Config.Bucket),
+		Key:    aws.String(key),
+	})
 	if err != nil {
-		return f.handleS3Error(err)
+		logger.Warn("Read repair: failed to read object %s from backend %s: %v", key, source.ID, err)
+		f.backendProvider.ReportFailure(&backend.BackendResult{
+			BackendID: source.ID, Method: "GET", Err: err, Duration: time.Since(getStart),
+		})
+		return
 	}
-	headers := make(http.Header)
-	if result.ContentType != nil {
-		headers.Set("Content-Type", *result.ContentType)
+	defer object.Body.Close()
+
+	body := &bytesCountingReader{reader: object.Body}
+	input := &s3.PutObjectInput{
+		Bucket:        aws.String(target.Config.Bucket),
+		Key:           aws.String(key),
+		Body:          body,
+		ContentLength: object.ContentLength,
+		ContentType:   object.ContentType,
+		Metadata:      object.Metadata,
 	}
-	if result.ContentLength != nil {
-		headers.Set("Content-Length", fmt.Sprintf("%d", *result.ContentLength))
+
+	// Тело не поддерживает Seek, поэтому используем клиент для стриминга, если он есть
+	client := target.S3Client
+	if target.StreamingPutClient != nil {
+		client = target.StreamingPutClient
 	}
-	if result.LastModified != nil {
-		headers.Set("Last-Modified", result.LastModified.Format(time.RFC1123))
+
+	putStart := time.Now()
+	response, err := client.PutObject(ctx, input)
+	result := &backend.BackendResult{
+		BackendID:    target.ID,
+		Method:       "PUT",
+		Response:     response,
+		Err:          err,
+		Duration:     time.Since(putStart),
+		BytesWritten: body.totalRead,
 	}
-	if result.ETag != nil {
-		headers.Set("ETag", *result.ETag)
+	if err != nil {
+		logger.Warn("Read repair: failed to write object %s to backend %s: %v", key, target.ID, err)
+		f.backendProvider.ReportFailure(result)
+		return
 	}
+	logger.Info("Read repair: object %s restored on backend %s (%d bytes)", key, target.ID, body.totalRead)
+	f.backendProvider.ReportSuccess(result)
+}
+
+// --- Функции для выполнения конкретных S3 операций ---
+
+func (f *Fetcher) performGetObject(ctx context.Context, req *apigw.S3Request, backend *backend.Backend) (response *apigw.S3Response) {
+	defer func() { trackResponse(ctx, backend, response) }()
+	input := &s3.GetObjectInput{Bucket: aws.String(backend.Config.Bucket), Key: aws.String(req.Key)}
+	input.ChecksumMode = checksumMode(req)
+	setGetConditions(input, req)
+	release, err := f.acquire(ctx)
+	if err != nil {
+		return f.handleS3Error(req, err)
+	}
+	defer release()
+	result, err := backend.S3Client.GetObject(ctx, input)
+	if err != nil {
+		return f.handleS3Error(req, err)
+	}
+	headers := getObjectHeaders(result)
 
 	return &apigw.S3Response{
-		StatusCode: http.StatusOK,
+		StatusCode: backendStatus(result.ResultMetadata),
 		Headers:    headers,
 		Body:       &bytesCountingReader{reader: result.Body},
 	}
 }
 
-func (f *Fetcher) performHeadObject(ctx context.Context, req *apigw.S3Request, backend *backend.Backend) *apigw.S3Response {
+func (f *Fetcher) performHeadObject(ctx context.Context, req *apigw.S3Request, backend *backend.Backend) (response *apigw.S3Response) {
+	defer func() { trackResponse(ctx, backend, response) }()
 	input := &s3.HeadObjectInput{Bucket: aws.String(backend.Config.Bucket), Key: aws.String(req.Key)}
-	result, err := backend.S3Client.HeadObject(ctx, input)
+	input.ChecksumMode = checksumMode(req)
+	release, err := f.acquire(ctx)
 	if err != nil {
-		return f.handleS3Error(err)
+		return f.handleS3Error(req, err)
 	}
-	headers := make(http.Header)
-	if result.ContentType != nil {
-		headers.Set("Content-Type", *result.ContentType)
-	}
-	if result.ContentLength != nil {
-		headers.Set("Content-Length", fmt.Sprintf("%d", *result.ContentLength))
-	}
-	if result.LastModified != nil {
-		headers.Set("Last-Modified", result.LastModified.Format(time.RFC1123))
-	}
-	if result.ETag != nil {
-		headers.Set("ETag", *result.ETag)
+	defer release()
+	result, err := backend.S3Client.HeadObject(ctx, input)
+	if err != nil {
+		return f.handleS3Error(req, err)
 	}
+	headers := headObjectHeaders(result)
 
-	return &apigw.S3Response{StatusCode: http.StatusOK, Headers: headers}
+	return &apigw.S3Response{StatusCode: backendStatus(result.ResultMetadata), Headers: headers}
 }
 
 func (f *Fetcher) performHeadBucket(ctx context.Context, req *apigw.S3Request, backend *backend.Backend) *apigw.S3Response {
 	input := &s3.HeadBucketInput{Bucket: aws.String(backend.Config.Bucket)}
-	_, err := backend.S3Client.HeadBucket(ctx, input)
+	release, err := f.acquire(ctx)
+	if err != nil {
+		return f.handleS3Error(req, err)
+	}
+	defer release()
+	_, err = backend.S3Client.HeadBucket(ctx, input)
 	if err != nil {
-		return f.handleS3Error(err)
+		return f.handleS3Error(req, err)
 	}
 	return &apigw.S3Response{StatusCode: http.StatusOK}
 }
 
+func (f *Fetcher) performGetObjectTagging(ctx context.Context, req *apigw.S3Request, backend *backend.Backend) *apigw.S3Response {
+	input := &s3.GetObjectTaggingInput{Bucket: aws.String(backend.Config.Bucket), Key: aws.String(req.Key)}
+	release, err := f.acquire(ctx)
+	if err != nil {
+		return f.handleS3Error(req, err)
+	}
+	defer release()
+	result, err := backend.S3Client.GetObjectTagging(ctx, input)
+	if err != nil {
+		return f.handleS3Error(req, err)
+	}
+
+	tagging := apigw.Tagging{Xmlns: "http://s3.amazonaws.com/doc/2006-03-01/", TagSet: make([]apigw.Tag, 0, len(result.TagSet))}
+	for _, tag := range result.TagSet {
+		tagging.TagSet = append(tagging.TagSet, apigw.Tag{Key: aws.ToString(tag.Key), Value: aws.ToString(tag.Value)})
+	}
+	payload, err := xml.Marshal(tagging)
+	if err != nil {
+		return &apigw.S3Response{StatusCode: http.StatusInternalServerError, Error: err}
+	}
+	body := append([]byte(xml.Header), payload...)
+
+	headers := make(http.Header)
+	headers.Set("Content-Type", "application/xml")
+	headers.Set("Content-Length", fmt.Sprintf("%d", len(body)))
+	if result.VersionId != nil {
+		headers.Set("x-amz-version-id", *result.VersionId)
+	}
+
+	return &apigw.S3Response{
+		StatusCode: http.StatusOK,
+		Headers:    headers,
+		Body:       io.NopCloser(bytes.NewReader(body)),
+	}
+}
+
 // --- Вспомогательные функции ---
 
-func (f *Fetcher) handleS3Error(err error) *apigw.S3Response {
-	var apiErr smithy.APIError
-	if errors.As(err, &apiErr) {
-		switch apiErr.ErrorCode() {
-		case "NotFound", "NoSuchKey", "NoSuchBucket":
-			return &apigw.S3Response{StatusCode: http.StatusNotFound, Error: err}
+// checksumMode передает бэкенду x-amz-checksum-mode клиента: как и S3, контрольные суммы
+// объекта (x-amz-checksum-*) возвращаются только клиентам, запросившим их значением ENABLED
+func checksumMode(req *apigw.S3Request) types.ChecksumMode {
+	if strings.EqualFold(req.Headers.Get("x-amz-checksum-mode"), string(types.ChecksumModeEnabled)) {
+		return types.ChecksumModeEnabled
+	}
+	return ""
+}
+
+// redirectLargeObject выполняет HEAD той же стратегией, что и GET, и для объекта размером
+// не меньше RedirectThreshold возвращает редирект 307 на presigned URL бэкенда, ответившего
+// на HEAD, и сам этот бэкенд. Так GET большого объекта на бэкенде не начинается вовсе.
+// Возвращает nil, если объект нужно проксировать: HEAD не вернул 200, объект меньше порога
+// или URL сформировать не удалось.
+func (f *Fetcher) redirectLargeObject(ctx context.Context, req *apigw.S3Request, backends []*backend.Backend, strategy string, policy routing.ReadOperationPolicy) (*apigw.S3Response, *backend.Backend) {
+	var tracker *responseTracker
+	if policy.MinRespondingBackends > 0 {
+		ctx, tracker = withResponseTracker(ctx, policy.MinRespondingBackends, len(backends))
+	}
+
+	var head *apigw.S3Response
+	var servedBy *backend.Backend
+	switch strategy {
+	case "first":
+		if policy.HedgeDelay > 0 {
+			head, servedBy = f.executeHedged(ctx, req, backends, f.performHeadObject, "HEAD", "NoSuchKey", policy.HedgeDelay)
+		} else {
+			head, servedBy = f.executeFirst(ctx, req, backends, f.performHeadObject, "HEAD", "NoSuchKey", policy.CancelLosers)
 		}
-		// Можно добавить другие коды ошибок S3
+	case "newest":
+		head, servedBy = f.executeNewest(ctx, req, backends, false, policy)
+	case "quorum":
+		head, servedBy = f.executeQuorum(ctx, req, backends, false)
+	}
+	if tracker != nil {
+		head = f.enforceMinResponding(ctx, req, head, tracker, policy, "HEAD")
+	}
+
+	if head == nil || head.StatusCode != http.StatusOK || servedBy == nil || head.Headers == nil {
+		return nil, nil
+	}
+	size, err := strconv.ParseInt(head.Headers.Get("Content-Length"), 10, 64)
+	if err != nil || size < policy.RedirectThreshold {
+		return nil, nil
 	}
-	return &apigw.S3Response{StatusCode: http.StatusInternalServerError, Error: err}
+
+	expiry := policy.RedirectExpiry
+	if expiry <= 0 {
+		expiry = routing.DefaultRedirectExpiry
+	}
+	presigned, err := s3.NewPresignClient(servedBy.S3Client).PresignGetObject(ctx, &s3.GetObjectInput{
+		Bucket: aws.String(servedBy.Config.Bucket),
+		Key:    aws.String(req.Key),
+	}, s3.WithPresignExpires(expiry))
+	if err != nil {
+		logger.Warn("redirectLargeObject: failed to presign GET of %s on backend %s, proxying instead: %v", req.Key, servedBy.ID, err)
+		return nil, nil
+	}
+
+	logger.Debug("redirectLargeObject: redirecting GET of %s (%d bytes) to backend %s", req.Key, size, servedBy.ID)
+
+	headers := make(http.Header)
+	headers.Set("Location", presigned.URL)
+	headers.Set("Content-Length", "0")
+	return &apigw.S3Response{StatusCode: http.StatusTemporaryRedirect, Headers: headers}, servedBy
+}
+
+// ServedByHeader - заголовок ответа с ID бэкенда, ответ которого отдан клиенту
+const ServedByHeader = "X-Amz-Proxy-Served-By"
+
+// setServedBy добавляет в ответ заголовок ServedByHeader.
+// Ничего не делает, если ответ отдан не бэкендом (кэш, ошибка стратегии).
+func setServedBy(response *apigw.S3Response, servedBy *backend.Backend) {
+	if response == nil || servedBy == nil {
+		return
+	}
+	if response.Headers == nil {
+		response.Headers = make(http.Header)
+	}
+	response.Headers.Set(ServedByHeader, servedBy.ID)
+}
+
+// selectReadBackends оставляет не более limit бэкендов с наименьшей сглаженной задержкой.
+// Задержка измеряется только по успешным запросам, поэтому бэкенды без измерений идут
+// последними: иначе бэкенд, который только отвечает ошибками, всегда попадал бы в выборку,
+// вытесняя исправные. Новые бэкенды получают оценку на записи, которая идет на все бэкенды.
+// limit <= 0 означает отсутствие ограничения.
+func selectReadBackends(backends []*backend.Backend, limit int) []*backend.Backend {
+	if limit <= 0 || len(backends) <= limit {
+		return backends
+	}
+
+	latencies := make(map[string]time.Duration, len(backends))
+	for _, b := range backends {
+		latencies[b.ID] = b.GetAverageLatency()
+	}
+
+	sorted := make([]*backend.Backend, len(backends))
+	copy(sorted, backends)
+	sort.Slice(sorted, func(i, j int) bool {
+		li, lj := latencies[sorted[i].ID], latencies[sorted[j].ID]
+		if (li == 0) != (lj == 0) {
+			return lj == 0
+		}
+		if li != lj {
+			return li < lj
+		}
+		return sorted[i].ID < sorted[j].ID
+	})
+
+	return sorted[:limit]
+}
+
+// quorumConflictResponse формирует ответ 409 с перечислением ответов бэкендов.
+// Ответ формируется целиком здесь, поэтому Error не устанавливается.
+func (f *Fetcher) quorumConflictResponse(req *apigw.S3Request, quorum int, answers map[string]string) *apigw.S3Response {
+	ids := make([]string, 0, len(answers))
+	for id := range answers {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	details := make([]string, 0, len(ids))
+	for _, id := range ids {
+		details = append(details, fmt.Sprintf("%s: %s", id, answers[id]))
+	}
+
+	message := fmt.Sprintf("No %d of %d backends agree on ETag of object %s (%s)",
+		quorum, len(answers), req.Key, strings.Join(details, "; "))
+	return apigw.NewErrorResponse(req.RequestID, apigw.ResourcePath(req.Bucket, req.Key),
+		http.StatusConflict, "ObjectQuorumNotReached", message)
+}
+
+// SetRetryAfter задает Retry-After ответа 503, когда нет живых бэкендов
+// (0 - apigw.DefaultRetryAfter)
+func (f *Fetcher) SetRetryAfter(retryAfter time.Duration) {
+	f.retryAfter = retryAfter
 }
 
-func (f *Fetcher) noBackendsResponse() *apigw.S3Response {
-	return &apigw.S3Response{StatusCode: http.StatusServiceUnavailable, Error: fmt.Errorf("no live backends available")}
+// noBackendsResponse возвращает 503 ServiceUnavailable с Retry-After: читать не с чего
+func (f *Fetcher) noBackendsResponse(req *apigw.S3Request) *apigw.S3Response {
+	return apigw.NewServiceUnavailableResponse(req.RequestID, apigw.ResourcePath(req.Bucket, req.Key),
+		"No live backends available", f.retryAfter)
 }
 
 func (f *Fetcher) unknownStrategyResponse(strategy string) *apigw.S3Response {
@@ -1,65 +1,37 @@
 package fetch
 
 import (
+	"bytes"
 	"context"
+	"encoding/base64"
+	"encoding/xml"
+	"errors"
+	"fmt"
 	"io"
 	"net/http"
 	"net/url"
+	"sort"
 	"strings"
 	"testing"
+	"time"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/prometheus/client_golang/prometheus/testutil"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 
 	"s3proxy/apigw"
 	"s3proxy/backend"
+	"s3proxy/internal/backendtest"
+	"s3proxy/internal/s3mock"
 	"s3proxy/routing"
 )
 
 // Mock implementations
 
-type MockBackendProvider struct {
-	mock.Mock
-}
-
-func (m *MockBackendProvider) GetLiveBackends() []*backend.Backend {
-	args := m.Called()
-	return args.Get(0).([]*backend.Backend)
-}
-
-func (m *MockBackendProvider) GetAllBackends() []*backend.Backend {
-	args := m.Called()
-	return args.Get(0).([]*backend.Backend)
-}
-
-func (m *MockBackendProvider) GetBackend(id string) (*backend.Backend, bool) {
-	args := m.Called(id)
-	return args.Get(0).(*backend.Backend), args.Bool(1)
-}
-
-func (m *MockBackendProvider) ReportSuccess(backendID string) {
-	m.Called(backendID)
-}
-
-func (m *MockBackendProvider) ReportFailure(backendID string, err error) {
-	m.Called(backendID, err)
-}
-
-func (m *MockBackendProvider) Start() error {
-	args := m.Called()
-	return args.Error(0)
-}
-
-func (m *MockBackendProvider) Stop() error {
-	args := m.Called()
-	return args.Error(0)
-}
-
-func (m *MockBackendProvider) IsRunning() bool {
-	args := m.Called()
-	return args.Bool(0)
-}
-
 type MockCache struct {
 	mock.Mock
 }
@@ -72,35 +44,21 @@ func (m *MockCache) Get(bucket, key string) (*apigw.S3Response, bool) {
 	return args.Get(0).(*apigw.S3Response), args.Bool(1)
 }
 
-type MockMetrics struct {
-	mock.Mock
-}
-
-func (m *MockMetrics) ObserveBackendRequestLatency(backendID, operation string, latency float64) {
-	m.Called(backendID, operation, latency)
-}
-
-func (m *MockMetrics) IncrementBackendRequestsTotal(backendID, operation, result string) {
-	m.Called(backendID, operation, result)
-}
-
-func (m *MockMetrics) AddBackendBytesRead(backendID string, bytes int64) {
-	m.Called(backendID, bytes)
-}
-
 // Helper functions
 
-func createTestBackend(id string) *backend.Backend {
-	return &backend.Backend{
-		ID: id,
-		Config: backend.BackendConfig{
-			Endpoint:  "https://s3.amazonaws.com",
-			Region:    "us-east-1",
-			Bucket:    "test-bucket",
-			AccessKey: "test-access-key",
-			SecretKey: "test-secret-key",
-		},
-	}
+// assertNoBackendsResponse проверяет ответ на запрос без живых бэкендов: 503 с XML
+// ошибкой ServiceUnavailable и заголовком Retry-After
+func assertNoBackendsResponse(t *testing.T, response *apigw.S3Response, retryAfter string) {
+	t.Helper()
+	require.Equal(t, http.StatusServiceUnavailable, response.StatusCode)
+	assert.Nil(t, response.Error)
+	assert.Equal(t, retryAfter, response.Headers.Get("Retry-After"))
+	assert.Equal(t, "application/xml", response.Headers.Get("Content-Type"))
+	require.NotNil(t, response.Body)
+	body, err := io.ReadAll(response.Body)
+	require.NoError(t, err)
+	assert.Contains(t, string(body), "<Code>ServiceUnavailable</Code>")
+	assert.Contains(t, string(body), "<Message>No live backends available</Message>")
 }
 
 func createTestRequest(operation apigw.S3Operation, bucket, key string) *apigw.S3Request {
@@ -117,25 +75,22 @@ func createTestRequest(operation apigw.S3Operation, bucket, key string) *apigw.S
 // Tests
 
 func TestNewFetcher(t *testing.T) {
-	mockProvider := &MockBackendProvider{}
+	manager, _ := backendtest.NewServers(t, 1, backend.StateUp)
 	mockCache := &MockCache{}
-	mockMetrics := &MockMetrics{}
 
-	fetcher := NewFetcher(mockProvider, mockCache, mockMetrics, "test-bucket")
+	fetcher := NewFetcher(manager, mockCache, "test-bucket")
 
 	assert.NotNil(t, fetcher)
-	assert.Equal(t, mockProvider, fetcher.backendProvider)
+	assert.Equal(t, manager, fetcher.backendProvider)
 	assert.Equal(t, mockCache, fetcher.cache)
-	assert.Equal(t, mockMetrics, fetcher.metrics)
 	assert.Equal(t, "test-bucket", fetcher.virtualBucket)
 }
 
 func TestFetcher_GetObject_CacheHit(t *testing.T) {
-	mockProvider := &MockBackendProvider{}
+	manager, _ := backendtest.NewServers(t, 1, backend.StateUp)
 	mockCache := &MockCache{}
-	mockMetrics := &MockMetrics{}
 
-	fetcher := NewFetcher(mockProvider, mockCache, mockMetrics, "test-bucket")
+	fetcher := NewFetcher(manager, mockCache, "test-bucket")
 
 	// Настраиваем мок кэша для возврата результата
 	cachedResponse := &apigw.S3Response{
@@ -156,44 +111,33 @@ func TestFetcher_GetObject_CacheHit(t *testing.T) {
 }
 
 func TestFetcher_GetObject_NoLiveBackends(t *testing.T) {
-	mockProvider := &MockBackendProvider{}
+	manager, _ := backendtest.NewServers(t, 1, backend.StateDown)
 	mockCache := &MockCache{}
-	mockMetrics := &MockMetrics{}
 
-	fetcher := NewFetcher(mockProvider, mockCache, mockMetrics, "test-bucket")
+	fetcher := NewFetcher(manager, mockCache, "test-bucket")
 
 	// Настраиваем мок кэша для промаха
 	mockCache.On("Get", "test-bucket", "test-key").Return(nil, false)
 
-	// Настраиваем мок провайдера для возврата пустого списка бэкендов
-	mockProvider.On("GetLiveBackends").Return([]*backend.Backend{})
-
 	req := createTestRequest(apigw.GetObject, "test-bucket", "test-key")
 	policy := routing.ReadOperationPolicy{Strategy: "first"}
 
 	response := fetcher.GetObject(context.Background(), req, policy)
 
-	assert.Equal(t, http.StatusServiceUnavailable, response.StatusCode)
-	assert.NotNil(t, response.Error)
-	assert.Contains(t, response.Error.Error(), "no live backends available")
+	assertNoBackendsResponse(t, response, "5")
 
 	mockCache.AssertExpectations(t)
-	mockProvider.AssertExpectations(t)
 }
 
 func TestFetcher_GetObject_UnknownStrategy(t *testing.T) {
-	mockProvider := &MockBackendProvider{}
+	manager, _ := backendtest.NewServers(t, 1, backend.StateUp)
 	mockCache := &MockCache{}
-	mockMetrics := &MockMetrics{}
 
-	fetcher := NewFetcher(mockProvider, mockCache, mockMetrics, "test-bucket")
+	fetcher := NewFetcher(manager, mockCache, "test-bucket")
 
 	// Настраиваем мок кэша для промаха
 	mockCache.On("Get", "test-bucket", "test-key").Return(nil, false)
 	
-	// Добавляем мок для GetLiveBackends, хотя он не должен вызываться из-за неизвестной стратегии
-	backend1 := createTestBackend("backend1")
-	mockProvider.On("GetLiveBackends").Return([]*backend.Backend{backend1})
 
 	req := createTestRequest(apigw.GetObject, "test-bucket", "test-key")
 	policy := routing.ReadOperationPolicy{Strategy: "unknown"}
@@ -208,11 +152,10 @@ func TestFetcher_GetObject_UnknownStrategy(t *testing.T) {
 }
 
 func TestFetcher_HeadObject_CacheHit(t *testing.T) {
-	mockProvider := &MockBackendProvider{}
+	manager, _ := backendtest.NewServers(t, 1, backend.StateUp)
 	mockCache := &MockCache{}
-	mockMetrics := &MockMetrics{}
 
-	fetcher := NewFetcher(mockProvider, mockCache, mockMetrics, "test-bucket")
+	fetcher := NewFetcher(manager, mockCache, "test-bucket")
 
 	// Настраиваем мок кэша для возврата результата
 	cachedResponse := &apigw.S3Response{
@@ -234,102 +177,919 @@ func TestFetcher_HeadObject_CacheHit(t *testing.T) {
 	mockCache.AssertExpectations(t)
 }
 
+// waitForObject ждет, пока объект key появится на сервере
+func waitForObject(t *testing.T, server *s3mock.Server, key string) *s3mock.Object {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if obj, ok := server.GetObject(key); ok {
+			return obj
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	t.Fatalf("object %s was not restored on backend", key)
+	return nil
+}
+
+func TestFetcher_GetObject_NewestReadRepair(t *testing.T) {
+	manager, servers := backendtest.NewServers(t, 2, backend.StateUp)
+	fetcher := NewFetcher(manager, NewStubCache(), "test-bucket")
+
+	servers[0].SetObject("test-key", []byte("fresh content"), time.Time{})
+
+	req := createTestRequest(apigw.GetObject, "test-bucket", "test-key")
+	policy := routing.ReadOperationPolicy{Strategy: "newest", ReadRepair: true}
+
+	response := fetcher.GetObject(context.Background(), req, policy)
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	body, err := io.ReadAll(response.Body)
+	assert.NoError(t, err)
+	response.Body.Close()
+	assert.Equal(t, "fresh content", string(body))
+
+	// Восстановление выполняется в фоне после ответа клиенту
+	repaired := waitForObject(t, servers[1], "test-key")
+	assert.Equal(t, "fresh content", string(repaired.Data))
+	assert.Equal(t, 1, servers[1].CountRequests(http.MethodPut))
+	assert.Equal(t, 0, servers[0].CountRequests(http.MethodPut))
+}
+
+func TestFetcher_GetObject_NewestSingleBackendSkipsHead(t *testing.T) {
+	manager, servers := backendtest.NewServers(t, 1, backend.StateUp)
+	fetcher := NewFetcher(manager, NewStubCache(), "test-bucket")
+
+	servers[0].SetObject("test-key", []byte("content"), time.Time{})
+	policy := routing.ReadOperationPolicy{Strategy: "newest"}
+
+	req := createTestRequest(apigw.GetObject, "test-bucket", "test-key")
+	response := fetcher.GetObject(context.Background(), req, policy)
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	body, err := io.ReadAll(response.Body)
+	assert.NoError(t, err)
+	response.Body.Close()
+	assert.Equal(t, "content", string(body))
+	assert.Equal(t, 1, servers[0].CountRequests(http.MethodGet))
+	assert.Equal(t, 0, servers[0].CountRequests(http.MethodHead))
+
+	req = createTestRequest(apigw.GetObject, "test-bucket", "missing-key")
+	response = fetcher.GetObject(context.Background(), req, policy)
+	assert.Equal(t, http.StatusNotFound, response.StatusCode)
+	assert.Equal(t, 0, servers[0].CountRequests(http.MethodHead))
+}
+
+func TestFetcher_MaxConcurrentRequests(t *testing.T) {
+	manager, servers := backendtest.NewServers(t, 1, backend.StateUp)
+	fetcher := NewFetcher(manager, NewStubCache(), "test-bucket")
+	fetcher.SetMaxConcurrentRequests(1)
+	servers[0].SetObject("test-key", []byte("content"), time.Time{})
+
+	// GET занимает единственное место, пока бэкенд не ответит
+	arrived := make(chan struct{})
+	unblock := make(chan struct{})
+	servers[0].SetIntercept(func(w http.ResponseWriter, r *http.Request) bool {
+		if r.Method == http.MethodGet {
+			close(arrived)
+			<-unblock
+		}
+		return false
+	})
+	policy := routing.ReadOperationPolicy{Strategy: "first"}
+	getDone := make(chan *apigw.S3Response, 1)
+	go func() {
+		getDone <- fetcher.GetObject(context.Background(), createTestRequest(apigw.GetObject, "test-bucket", "test-key"), policy)
+	}()
+	<-arrived
+
+	headDone := make(chan *apigw.S3Response, 1)
+	go func() {
+		headDone <- fetcher.HeadObject(context.Background(), createTestRequest(apigw.HeadObject, "test-bucket", "test-key"), policy)
+	}()
+	select {
+	case <-headDone:
+		t.Fatal("HEAD must wait while the limit is taken by GET")
+	case <-time.After(50 * time.Millisecond):
+	}
+	assert.Equal(t, 0, servers[0].CountRequests(http.MethodHead))
+
+	close(unblock)
+	response := <-getDone
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	response.Body.Close()
+	assert.Equal(t, http.StatusOK, (<-headDone).StatusCode)
+	assert.Equal(t, 1, servers[0].CountRequests(http.MethodHead))
+}
+
+func TestFetcher_GetObject_NewestReadRepairStaleCopy(t *testing.T) {
+	manager, servers := backendtest.NewServers(t, 2, backend.StateUp)
+	fetcher := NewFetcher(manager, NewStubCache(), "test-bucket")
+
+	servers[0].SetObject("test-key", []byte("old content"), time.Now().Add(-time.Hour))
+	servers[1].SetObject("test-key", []byte("new content"), time.Time{})
+
+	req := createTestRequest(apigw.HeadObject, "test-bucket", "test-key")
+	policy := routing.ReadOperationPolicy{Strategy: "newest", ReadRepair: true}
+
+	response := fetcher.HeadObject(context.Background(), req, policy)
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if obj, _ := servers[0].GetObject("test-key"); string(obj.Data) == "new content" {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	obj, _ := servers[0].GetObject("test-key")
+	assert.Equal(t, "new content", string(obj.Data))
+}
+
+func TestFetcher_GetObject_NewestWithoutReadRepair(t *testing.T) {
+	manager, servers := backendtest.NewServers(t, 2, backend.StateUp)
+	fetcher := NewFetcher(manager, NewStubCache(), "test-bucket")
+
+	servers[0].SetObject("test-key", []byte("content"), time.Time{})
+
+	req := createTestRequest(apigw.GetObject, "test-bucket", "test-key")
+	policy := routing.ReadOperationPolicy{Strategy: "newest"}
+
+	response := fetcher.GetObject(context.Background(), req, policy)
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	response.Body.Close()
+
+	time.Sleep(100 * time.Millisecond)
+	_, found := servers[1].GetObject("test-key")
+	assert.False(t, found)
+	assert.Equal(t, 0, servers[1].CountRequests(http.MethodPut))
+}
+
+func TestFetcher_GetObject_QuorumMatching(t *testing.T) {
+	manager, servers := backendtest.NewServers(t, 3, backend.StateUp)
+	fetcher := NewFetcher(manager, NewStubCache(), "test-bucket")
+
+	servers[0].SetObject("test-key", []byte("agreed content"), time.Time{})
+	servers[1].SetObject("test-key", []byte("agreed content"), time.Time{})
+	servers[2].SetObject("test-key", []byte("diverged content"), time.Time{})
+
+	req := createTestRequest(apigw.GetObject, "test-bucket", "test-key")
+	policy := routing.ReadOperationPolicy{Strategy: "quorum"}
+
+	response := fetcher.GetObject(context.Background(), req, policy)
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	assert.NoError(t, response.Error)
+	body, err := io.ReadAll(response.Body)
+	assert.NoError(t, err)
+	response.Body.Close()
+	assert.Equal(t, "agreed content", string(body))
+
+	// GET выполняется только на одном из бэкендов большинства
+	assert.Equal(t, 0, servers[2].CountRequests(http.MethodGet))
+}
+
+func TestFetcher_GetObject_QuorumMismatch(t *testing.T) {
+	manager, servers := backendtest.NewServers(t, 3, backend.StateUp)
+	fetcher := NewFetcher(manager, NewStubCache(), "test-bucket")
+
+	servers[0].SetObject("test-key", []byte("content 1"), time.Time{})
+	servers[1].SetObject("test-key", []byte("content 2"), time.Time{})
+
+	req := createTestRequest(apigw.GetObject, "test-bucket", "test-key")
+	policy := routing.ReadOperationPolicy{Strategy: "quorum"}
+
+	response := fetcher.GetObject(context.Background(), req, policy)
+	assert.Equal(t, http.StatusConflict, response.StatusCode)
+	assert.Nil(t, response.Error)
+	body, err := io.ReadAll(response.Body)
+	assert.NoError(t, err)
+	assert.Contains(t, string(body), "<Code>ObjectQuorumNotReached</Code>")
+	assert.Contains(t, string(body), "backend-1: ")
+	assert.Contains(t, string(body), "backend-3: 404 Not Found")
+
+	// Объект не отдается ни с одного бэкенда
+	for _, server := range servers {
+		assert.Equal(t, 0, server.CountRequests(http.MethodGet))
+	}
+}
+
+func TestFetcher_HeadObject_QuorumNotFound(t *testing.T) {
+	manager, servers := backendtest.NewServers(t, 3, backend.StateUp)
+	fetcher := NewFetcher(manager, NewStubCache(), "test-bucket")
+
+	servers[0].SetObject("test-key", []byte("content"), time.Time{})
+
+	req := createTestRequest(apigw.HeadObject, "test-bucket", "test-key")
+	policy := routing.ReadOperationPolicy{Strategy: "quorum"}
+
+	response := fetcher.HeadObject(context.Background(), req, policy)
+	assert.Equal(t, http.StatusNotFound, response.StatusCode)
+	body, _ := io.ReadAll(response.Body)
+	assert.Contains(t, string(body), "<Code>NoSuchKey</Code>")
+}
+
+func TestFetcher_GetObject_RangePassthrough(t *testing.T) {
+	for _, strategy := range []string{"first", "newest", "quorum"} {
+		t.Run(strategy, func(t *testing.T) {
+			manager, servers := backendtest.NewServers(t, 2, backend.StateUp)
+			fetcher := NewFetcher(manager, NewStubCache(), "test-bucket")
+			for _, server := range servers {
+				server.SetObject("test-key", []byte("partial content"), time.Time{})
+			}
+
+			req := createTestRequest(apigw.GetObject, "test-bucket", "test-key")
+			req.Headers.Set("Range", "bytes=0-6")
+			response := fetcher.GetObject(context.Background(), req, routing.ReadOperationPolicy{Strategy: strategy})
+
+			require.Equal(t, http.StatusPartialContent, response.StatusCode)
+			assert.NoError(t, response.Error)
+			assert.Equal(t, "bytes 0-6/15", response.Headers.Get("Content-Range"))
+			assert.Equal(t, "7", response.Headers.Get("Content-Length"))
+			body, err := io.ReadAll(response.Body)
+			require.NoError(t, err)
+			response.Body.Close()
+			assert.Equal(t, "partial", string(body))
+		})
+	}
+}
+
+func TestFetcher_GetObject_ConditionalStatuses(t *testing.T) {
+	manager, servers := backendtest.NewServers(t, 2, backend.StateUp)
+	fetcher := NewFetcher(manager, NewStubCache(), "test-bucket")
+	for _, server := range servers {
+		server.SetObject("test-key", []byte("content"), time.Time{})
+	}
+	policy := routing.ReadOperationPolicy{Strategy: "first"}
+	object, _ := servers[0].GetObject("test-key")
+	etag := object.ETag
+
+	// If-None-Match с текущим ETag: 304 без тела
+	req := createTestRequest(apigw.GetObject, "test-bucket", "test-key")
+	req.Headers.Set("If-None-Match", etag)
+	response := fetcher.GetObject(context.Background(), req, policy)
+	assert.Equal(t, http.StatusNotModified, response.StatusCode)
+	assert.NoError(t, response.Error)
+	assert.Nil(t, response.Body)
+
+	// If-Match с другим ETag: 412 PreconditionFailed
+	req = createTestRequest(apigw.GetObject, "test-bucket", "test-key")
+	req.Headers.Set("If-Match", `"other"`)
+	response = fetcher.GetObject(context.Background(), req, policy)
+	require.Equal(t, http.StatusPreconditionFailed, response.StatusCode)
+	body, _ := io.ReadAll(response.Body)
+	assert.Contains(t, string(body), "<Code>PreconditionFailed</Code>")
+
+	// Range за пределами объекта: 416 InvalidRange
+	req = createTestRequest(apigw.GetObject, "test-bucket", "test-key")
+	req.Headers.Set("Range", "bytes=100-200")
+	response = fetcher.GetObject(context.Background(), req, policy)
+	require.Equal(t, http.StatusRequestedRangeNotSatisfiable, response.StatusCode)
+	body, _ = io.ReadAll(response.Body)
+	assert.Contains(t, string(body), "<Code>InvalidRange</Code>")
+
+	// Ответы на условия клиента не считаются сбоями бэкендов
+	for _, b := range manager.GetAllBackends() {
+		assert.Equal(t, backend.StateUp, b.GetState())
+	}
+}
+
+func TestFetcher_GetObject_UserMetadata(t *testing.T) {
+	manager, servers := backendtest.NewServers(t, 1, backend.StateUp)
+	fetcher := NewFetcher(manager, NewStubCache(), "test-bucket")
+
+	servers[0].SetObject("test-key", []byte("content"), time.Time{})
+	servers[0].SetObjectMetadata("test-key", map[string]string{"owner": "alice"})
+
+	req := createTestRequest(apigw.GetObject, "test-bucket", "test-key")
+	response := fetcher.GetObject(context.Background(), req, routing.ReadOperationPolicy{Strategy: "first"})
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	response.Body.Close()
+	assert.Equal(t, "alice", response.Headers.Get("x-amz-meta-owner"))
+	assert.Equal(t, "binary/octet-stream", response.Headers.Get("Content-Type"))
+
+	req = createTestRequest(apigw.HeadObject, "test-bucket", "test-key")
+	response = fetcher.HeadObject(context.Background(), req, routing.ReadOperationPolicy{Strategy: "first"})
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	assert.Equal(t, "alice", response.Headers.Get("x-amz-meta-owner"))
+}
+
+func TestFetcher_HeadObject_ChecksumMode(t *testing.T) {
+	manager, servers := backendtest.NewServers(t, 1, backend.StateUp)
+	fetcher := NewFetcher(manager, NewStubCache(), "test-bucket")
+
+	// Бэкенд, как S3, отдает контрольную сумму только при x-amz-checksum-mode: ENABLED
+	servers[0].SetIntercept(func(w http.ResponseWriter, r *http.Request) bool {
+		if r.Method != http.MethodHead {
+			return false
+		}
+		if r.Header.Get("x-amz-checksum-mode") == "ENABLED" {
+			w.Header().Set("x-amz-checksum-crc32", "DUoRhQ==")
+			w.Header().Set("x-amz-checksum-type", "FULL_OBJECT")
+		}
+		w.Header().Set("Content-Length", "7")
+		w.WriteHeader(http.StatusOK)
+		return true
+	})
+
+	req := createTestRequest(apigw.HeadObject, "test-bucket", "test-key")
+	response := fetcher.HeadObject(context.Background(), req, routing.ReadOperationPolicy{Strategy: "first"})
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	assert.Empty(t, response.Headers.Get("x-amz-checksum-crc32"))
+
+	req = createTestRequest(apigw.HeadObject, "test-bucket", "test-key")
+	req.Headers.Set("x-amz-checksum-mode", "ENABLED")
+	response = fetcher.HeadObject(context.Background(), req, routing.ReadOperationPolicy{Strategy: "first"})
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	assert.Equal(t, "DUoRhQ==", response.Headers.Get("x-amz-checksum-crc32"))
+	assert.Equal(t, "FULL_OBJECT", response.Headers.Get("x-amz-checksum-type"))
+}
+
+func TestFetcher_GetObject_NoSuchKey(t *testing.T) {
+	manager, _ := backendtest.NewServers(t, 2, backend.StateUp)
+	fetcher := NewFetcher(manager, NewStubCache(), "test-bucket")
+
+	req := createTestRequest(apigw.GetObject, "test-bucket", "missing-key")
+	req.RequestID = "req-404"
+	response := fetcher.GetObject(context.Background(), req, routing.ReadOperationPolicy{Strategy: "first"})
+	assert.Equal(t, http.StatusNotFound, response.StatusCode)
+	assert.Nil(t, response.Error)
+	assert.Equal(t, "application/xml", response.Headers.Get("Content-Type"))
+
+	body, err := io.ReadAll(response.Body)
+	assert.NoError(t, err)
+	var s3Error apigw.S3Error
+	assert.NoError(t, xml.Unmarshal(body, &s3Error))
+	assert.Equal(t, "NoSuchKey", s3Error.Code)
+	assert.Equal(t, "The specified key does not exist.", s3Error.Message)
+	assert.Equal(t, "/test-bucket/missing-key", s3Error.Resource)
+	assert.Equal(t, "req-404", s3Error.RequestID)
+}
+
+// queriedServers возвращает число серверов, получивших хотя бы один запрос method
+func queriedServers(servers []*s3mock.Server, method string) int {
+	queried := 0
+	for _, server := range servers {
+		if server.CountRequests(method) > 0 {
+			queried++
+		}
+	}
+	return queried
+}
+
+func TestFetcher_GetObject_MaxReadFanout(t *testing.T) {
+	manager, servers := backendtest.NewServers(t, 5, backend.StateUp)
+	fetcher := NewFetcher(manager, NewStubCache(), "test-bucket")
+
+	for _, server := range servers {
+		server.SetObject("test-key", []byte("content"), time.Time{})
+	}
+
+	req := createTestRequest(apigw.GetObject, "test-bucket", "test-key")
+	policy := routing.ReadOperationPolicy{Strategy: "first", MaxReadFanout: 2}
+
+	response := fetcher.GetObject(context.Background(), req, policy)
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	response.Body.Close()
+
+	// executeFirst не дожидается остальных запросов - даем им завершиться
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, 2, queriedServers(servers, http.MethodGet))
+
+	// Для quorum большинство считается среди опрошенных бэкендов
+	policy.Strategy = "quorum"
+	for _, server := range servers {
+		server.ResetRequests()
+	}
+	response = fetcher.HeadObject(context.Background(), req, policy)
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	assert.Equal(t, 2, queriedServers(servers, http.MethodHead))
+}
+
+func TestSelectReadBackends(t *testing.T) {
+	manager, _ := backendtest.NewServers(t, 4, backend.StateUp)
+	backends := manager.GetLiveBackends()
+
+	manager.ReportSuccess(&backend.BackendResult{BackendID: "backend-1", Duration: 300 * time.Millisecond})
+	manager.ReportSuccess(&backend.BackendResult{BackendID: "backend-2", Duration: 100 * time.Millisecond})
+	manager.ReportSuccess(&backend.BackendResult{BackendID: "backend-3", Duration: 200 * time.Millisecond})
+
+	// backend-4 отвечает только ошибками: задержка не измерена, и он выбирается последним
+	manager.ReportFailure(&backend.BackendResult{BackendID: "backend-4", Err: errors.New("timeout"), Duration: time.Second})
+	selected := selectReadBackends(backends, 2)
+	assert.Len(t, selected, 2)
+	assert.Equal(t, "backend-2", selected[0].ID)
+	assert.Equal(t, "backend-3", selected[1].ID)
+
+	selected = selectReadBackends(backends, 3)
+	assert.Equal(t, "backend-1", selected[2].ID)
+
+	// Без ограничения возвращаются все бэкенды
+	assert.Len(t, selectReadBackends(backends, 0), 4)
+	assert.Len(t, selectReadBackends(backends, 10), 4)
+}
+
+func TestFetcher_ServedByHeader(t *testing.T) {
+	manager, servers := backendtest.NewServers(t, 3, backend.StateUp)
+	fetcher := NewFetcher(manager, NewStubCache(), "test-bucket")
+
+	// Объект есть только на backend-2, поэтому first выбирает его
+	servers[1].SetObject("test-key", []byte("content"), time.Time{})
+	// Самая новая версия лежит на backend-3
+	servers[2].SetObject("newest-key", []byte("new"), time.Time{})
+	servers[0].SetObject("newest-key", []byte("old"), time.Now().Add(-time.Hour))
+
+	req := createTestRequest(apigw.GetObject, "test-bucket", "test-key")
+	policy := routing.ReadOperationPolicy{Strategy: "first", ExposeServedBy: true}
+
+	response := fetcher.GetObject(context.Background(), req, policy)
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	response.Body.Close()
+	assert.Equal(t, "backend-2", response.Headers.Get(ServedByHeader))
+
+	req = createTestRequest(apigw.HeadObject, "test-bucket", "newest-key")
+	policy.Strategy = "newest"
+	response = fetcher.HeadObject(context.Background(), req, policy)
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	assert.Equal(t, "backend-3", response.Headers.Get(ServedByHeader))
+
+	// По умолчанию заголовок не добавляется
+	policy.ExposeServedBy = false
+	response = fetcher.HeadObject(context.Background(), req, policy)
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	assert.Empty(t, response.Headers.Get(ServedByHeader))
+}
+
 func TestFetcher_HeadBucket_NoLiveBackends(t *testing.T) {
-	mockProvider := &MockBackendProvider{}
+	manager, _ := backendtest.NewServers(t, 1, backend.StateDown)
 	mockCache := &MockCache{}
-	mockMetrics := &MockMetrics{}
 
-	fetcher := NewFetcher(mockProvider, mockCache, mockMetrics, "test-bucket")
-
-	// Настраиваем мок провайдера для возврата пустого списка бэкендов
-	mockProvider.On("GetLiveBackends").Return([]*backend.Backend{})
+	fetcher := NewFetcher(manager, mockCache, "test-bucket")
 
 	req := createTestRequest(apigw.HeadBucket, "test-bucket", "")
 
 	response := fetcher.HeadBucket(context.Background(), req)
 
-	assert.Equal(t, http.StatusServiceUnavailable, response.StatusCode)
-	assert.NotNil(t, response.Error)
-	assert.Contains(t, response.Error.Error(), "no live backends available")
-
-	mockProvider.AssertExpectations(t)
+	assertNoBackendsResponse(t, response, "5")
 }
 
 func TestFetcher_ListObjects_NoLiveBackends(t *testing.T) {
-	mockProvider := &MockBackendProvider{}
+	manager, _ := backendtest.NewServers(t, 1, backend.StateDown)
 	mockCache := &MockCache{}
-	mockMetrics := &MockMetrics{}
 
-	fetcher := NewFetcher(mockProvider, mockCache, mockMetrics, "test-bucket")
+	fetcher := NewFetcher(manager, mockCache, "test-bucket")
+
+	req := createTestRequest(apigw.ListObjectsV2, "test-bucket", "")
+
+	response := fetcher.ListObjects(context.Background(), req)
+
+	assertNoBackendsResponse(t, response, "5")
+}
+
+func TestFetcher_ListObjects_DelimiterMergesCommonPrefixes(t *testing.T) {
+	manager, servers := backendtest.NewServers(t, 2, backend.StateUp)
+	fetcher := NewFetcher(manager, NewStubCache(), "test-bucket")
 
-	// Настраиваем мок провайдера для возврата пустого списка бэкендов
-	mockProvider.On("GetLiveBackends").Return([]*backend.Backend{})
+	servers[0].SetObject("a.txt", []byte("a"), time.Time{})
+	servers[0].SetObject("dir1/x", []byte("x"), time.Time{})
+	servers[1].SetObject("dir1/y", []byte("y"), time.Time{})
+	servers[1].SetObject("dir2/z", []byte("z"), time.Time{})
 
 	req := createTestRequest(apigw.ListObjectsV2, "test-bucket", "")
+	req.Query.Set("delimiter", "/")
 
 	response := fetcher.ListObjects(context.Background(), req)
+	assert.Equal(t, http.StatusOK, response.StatusCode)
 
-	assert.Equal(t, http.StatusServiceUnavailable, response.StatusCode)
-	assert.NotNil(t, response.Error)
-	assert.Contains(t, response.Error.Error(), "no live backends available")
+	body, err := io.ReadAll(response.Body)
+	assert.NoError(t, err)
+	response.Body.Close()
+
+	var result ListObjectsV2Result
+	assert.NoError(t, xml.Unmarshal(body, &result))
+
+	assert.Equal(t, "/", result.Delimiter)
+	assert.Len(t, result.Contents, 1)
+	assert.Equal(t, []CommonPrefix{{Prefix: "dir1/"}, {Prefix: "dir2/"}}, result.CommonPrefixes)
+	// KeyCount учитывает и объекты, и CommonPrefixes
+	assert.Equal(t, int32(3), result.KeyCount)
+}
+
+func TestFetcher_ListObjects_MaxKeysPaginatesMergedListing(t *testing.T) {
+	manager, servers := backendtest.NewServers(t, 2, backend.StateUp)
+	fetcher := NewFetcher(manager, NewStubCache(), "test-bucket")
+
+	// Ключи бэкендов чередуются и частично совпадают
+	for _, key := range []string{"a", "c", "d", "f", "g", "dir/1", "dir/2"} {
+		servers[0].SetObject(key, []byte(key), time.Time{})
+	}
+	for _, key := range []string{"b", "c", "e", "h", "dir/3"} {
+		servers[1].SetObject(key, []byte(key), time.Time{})
+	}
+
+	for _, tc := range []struct {
+		delimiter string
+		expected  []string
+	}{
+		{"", []string{"a", "b", "c", "d", "dir/1", "dir/2", "dir/3", "e", "f", "g", "h"}},
+		{"/", []string{"a", "b", "c", "d", "dir/", "e", "f", "g", "h"}},
+	} {
+		t.Run(fmt.Sprintf("delimiter=%q", tc.delimiter), func(t *testing.T) {
+			var seen []string
+			token := ""
+			for page := 0; ; page++ {
+				if page > len(tc.expected) {
+					t.Fatalf("Pagination did not finish after %d pages", page)
+				}
+				req := createTestRequest(apigw.ListObjectsV2, "test-bucket", "")
+				req.Query.Set("max-keys", "3")
+				if tc.delimiter != "" {
+					req.Query.Set("delimiter", tc.delimiter)
+				}
+				if token != "" {
+					req.Query.Set("continuation-token", token)
+				}
+
+				response := fetcher.ListObjects(context.Background(), req)
+				assert.Equal(t, http.StatusOK, response.StatusCode)
+				body, err := io.ReadAll(response.Body)
+				assert.NoError(t, err)
+				response.Body.Close()
+
+				var result ListObjectsV2Result
+				assert.NoError(t, xml.Unmarshal(body, &result))
+
+				var entries []string
+				for _, obj := range result.Contents {
+					entries = append(entries, obj.Key)
+				}
+				for _, cp := range result.CommonPrefixes {
+					entries = append(entries, cp.Prefix)
+				}
+				assert.LessOrEqual(t, len(entries), 3)
+				assert.Equal(t, int32(len(entries)), result.KeyCount)
+				seen = append(seen, entries...)
+
+				if !result.IsTruncated {
+					assert.Empty(t, result.NextContinuationToken)
+					break
+				}
+				// Полная страница, пока листинг не закончился
+				assert.Len(t, entries, 3)
+				assert.NotEmpty(t, result.NextContinuationToken)
+				token = result.NextContinuationToken
+			}
+			sort.Strings(seen)
+			assert.Equal(t, tc.expected, seen)
+		})
+	}
+}
+
+// listObjectsPage выполняет ListObjectsV2 и возвращает разобранный ответ
+func listObjectsPage(t *testing.T, fetcher *Fetcher, query url.Values) ListObjectsV2Result {
+	t.Helper()
+	req := createTestRequest(apigw.ListObjectsV2, "test-bucket", "")
+	for name, values := range query {
+		req.Query[name] = values
+	}
+	response := fetcher.ListObjects(context.Background(), req)
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	body, err := io.ReadAll(response.Body)
+	assert.NoError(t, err)
+	response.Body.Close()
+
+	var result ListObjectsV2Result
+	assert.NoError(t, xml.Unmarshal(body, &result))
+	return result
+}
+
+func TestFetcher_ListObjects_ContinuationTokenReturnsEachKeyOnce(t *testing.T) {
+	manager, servers := backendtest.NewServers(t, 3, backend.StateUp)
+	fetcher := NewFetcher(manager, NewStubCache(), "test-bucket")
+
+	// Ключи распределены по бэкендам с перекрытием: часть есть на всех, часть на одном
+	var expected []string
+	for i := 0; i < 30; i++ {
+		key := fmt.Sprintf("key-%02d", i)
+		expected = append(expected, key)
+		for b, server := range servers {
+			if i%3 == b || i%5 == 0 {
+				server.SetObject(key, []byte(key), time.Time{})
+			}
+		}
+	}
+
+	for _, maxKeys := range []int{1, 2, 4, 7} {
+		t.Run(fmt.Sprintf("max-keys=%d", maxKeys), func(t *testing.T) {
+			var seen []string
+			query := url.Values{"max-keys": {fmt.Sprint(maxKeys)}}
+			for page := 0; ; page++ {
+				if page > len(expected) {
+					t.Fatalf("Pagination did not finish after %d pages", page)
+				}
+				result := listObjectsPage(t, fetcher, query)
+				for _, obj := range result.Contents {
+					seen = append(seen, obj.Key)
+				}
+				if !result.IsTruncated {
+					break
+				}
+				query.Set("continuation-token", result.NextContinuationToken)
+			}
+			// Страницы идут подряд: ключи отсортированы и не повторяются
+			assert.Equal(t, expected, seen)
+		})
+	}
 
-	mockProvider.AssertExpectations(t)
+	// Токен бэкенда указывает раньше последнего отданного клиенту ключа:
+	// уже отданные ключи отбрасываются
+	tokens := make(map[string]string)
+	for i := range servers {
+		tokens[fmt.Sprintf("backend-%d", i+1)] = base64.StdEncoding.EncodeToString([]byte("key-02"))
+	}
+	token, err := (&ProxyContinuationToken{BackendTokens: tokens, LastKey: "key-05"}).encode()
+	assert.NoError(t, err)
+	result := listObjectsPage(t, fetcher, url.Values{"max-keys": {"5"}, "continuation-token": {token}})
+	var keys []string
+	for _, obj := range result.Contents {
+		keys = append(keys, obj.Key)
+	}
+	assert.Equal(t, []string{"key-06", "key-07", "key-08", "key-09", "key-10"}, keys)
+	assert.True(t, result.IsTruncated)
+}
+
+func TestFetcher_ListObjects_ListCache(t *testing.T) {
+	manager, servers := backendtest.NewServers(t, 1, backend.StateUp)
+	fetcher := NewFetcher(manager, NewStubCache(), "test-bucket")
+	fetcher.SetListCache(time.Minute, 0)
+
+	servers[0].SetObject("docs/a.txt", []byte("a"), time.Time{})
+
+	listQuery := func(query url.Values) ListObjectsV2Result {
+		t.Helper()
+		req := createTestRequest(apigw.ListObjectsV2, "test-bucket", "")
+		req.Query = query
+		response := fetcher.ListObjects(context.Background(), req)
+		assert.Equal(t, http.StatusOK, response.StatusCode)
+		body, err := io.ReadAll(response.Body)
+		assert.NoError(t, err)
+		response.Body.Close()
+
+		var result ListObjectsV2Result
+		assert.NoError(t, xml.Unmarshal(body, &result))
+		return result
+	}
+	list := func(prefix, delimiter string) ListObjectsV2Result {
+		t.Helper()
+		query := url.Values{"prefix": {prefix}}
+		if delimiter != "" {
+			query.Set("delimiter", delimiter)
+		}
+		return listQuery(query)
+	}
+	backendLists := func() int { return servers[0].CountRequests(http.MethodGet) }
+
+	t.Run("hit within TTL", func(t *testing.T) {
+		first := list("docs/", "")
+		assert.Len(t, first.Contents, 1)
+		assert.Equal(t, 1, backendLists())
+
+		second := list("docs/", "")
+		assert.Equal(t, first, second)
+		assert.Equal(t, 1, backendLists(), "second listing should be served from cache")
+	})
+
+	t.Run("distinct keys for delimiters", func(t *testing.T) {
+		before := backendLists()
+		list("docs/", "/")
+		assert.Equal(t, before+1, backendLists(), "listing with delimiter must not reuse the listing without it")
+		list("docs/", "/")
+		assert.Equal(t, before+1, backendLists())
+	})
+
+	t.Run("invalidated by write to prefix", func(t *testing.T) {
+		servers[0].SetObject("docs/b.txt", []byte("b"), time.Time{})
+
+		// Запись вне префикса листинг не сбрасывает
+		fetcher.InvalidateListings("test-bucket", "other/c.txt")
+		before := backendLists()
+		assert.Len(t, list("docs/", "").Contents, 1)
+		assert.Equal(t, before, backendLists())
+
+		fetcher.InvalidateListings("test-bucket", "docs/b.txt")
+		assert.Len(t, list("docs/", "").Contents, 2)
+		assert.Equal(t, before+1, backendLists())
+	})
+
+	t.Run("distinct keys for start-after", func(t *testing.T) {
+		keys := func(startAfter string) []string {
+			t.Helper()
+			var keys []string
+			for _, obj := range listQuery(url.Values{"prefix": {"docs/"}, "start-after": {startAfter}}).Contents {
+				keys = append(keys, obj.Key)
+			}
+			return keys
+		}
+
+		assert.Equal(t, []string{"docs/a.txt", "docs/b.txt"}, keys("docs/0"))
+		assert.Equal(t, []string{"docs/b.txt"}, keys("docs/a.txt"))
+		// Повторные запросы отдаются из кэша, каждый своей записью
+		before := backendLists()
+		assert.Equal(t, []string{"docs/a.txt", "docs/b.txt"}, keys("docs/0"))
+		assert.Equal(t, []string{"docs/b.txt"}, keys("docs/a.txt"))
+		assert.Equal(t, before, backendLists())
+	})
+}
+
+func TestFetcher_ListObjects_ListCacheSkipsIncomplete(t *testing.T) {
+	manager, servers := backendtest.NewServers(t, 2, backend.StateUp)
+	fetcher := NewFetcher(manager, NewStubCache(), "test-bucket")
+	fetcher.SetListCache(time.Minute, 0)
+
+	servers[0].SetObject("docs/a.txt", []byte("a"), time.Time{})
+	servers[1].SetObject("docs/b.txt", []byte("b"), time.Time{})
+	servers[1].SetIntercept(func(w http.ResponseWriter, r *http.Request) bool {
+		w.WriteHeader(http.StatusInternalServerError)
+		return true
+	})
+
+	list := func() int {
+		t.Helper()
+		req := createTestRequest(apigw.ListObjectsV2, "test-bucket", "")
+		req.Query.Set("prefix", "docs/")
+		response := fetcher.ListObjects(context.Background(), req)
+		assert.Equal(t, http.StatusOK, response.StatusCode)
+		body, err := io.ReadAll(response.Body)
+		assert.NoError(t, err)
+		response.Body.Close()
+
+		var result ListObjectsV2Result
+		assert.NoError(t, xml.Unmarshal(body, &result))
+		return len(result.Contents)
+	}
+
+	// Листинг без ответа второго бэкенда неполон и не кэшируется
+	assert.Equal(t, 1, list())
+	assert.Equal(t, 1, list())
+	assert.Equal(t, 2, servers[0].CountRequests(http.MethodGet))
+
+	// После восстановления бэкенда клиент сразу получает полный листинг
+	servers[1].SetIntercept(nil)
+	assert.Equal(t, 2, list())
+	assert.Equal(t, 2, list())
+	assert.Equal(t, 3, servers[0].CountRequests(http.MethodGet))
 }
 
 func TestFetcher_ListBuckets_NoLiveBackends(t *testing.T) {
-	mockProvider := &MockBackendProvider{}
+	manager, _ := backendtest.NewServers(t, 1, backend.StateDown)
 	mockCache := &MockCache{}
-	mockMetrics := &MockMetrics{}
-
-	fetcher := NewFetcher(mockProvider, mockCache, mockMetrics, "test-bucket")
 
-	// Настраиваем мок провайдера для возврата пустого списка бэкендов
-	mockProvider.On("GetLiveBackends").Return([]*backend.Backend{})
+	fetcher := NewFetcher(manager, mockCache, "test-bucket")
 
 	req := createTestRequest(apigw.ListBuckets, "", "")
 
 	response := fetcher.ListBuckets(context.Background(), req)
 
-	assert.Equal(t, http.StatusServiceUnavailable, response.StatusCode)
-	assert.NotNil(t, response.Error)
-	assert.Contains(t, response.Error.Error(), "no live backends available")
-
-	mockProvider.AssertExpectations(t)
+	assertNoBackendsResponse(t, response, "5")
 }
 
 func TestFetcher_ListMultipartUploads_NoLiveBackends(t *testing.T) {
-	mockProvider := &MockBackendProvider{}
+	manager, _ := backendtest.NewServers(t, 1, backend.StateDown)
 	mockCache := &MockCache{}
-	mockMetrics := &MockMetrics{}
-
-	fetcher := NewFetcher(mockProvider, mockCache, mockMetrics, "test-bucket")
 
-	// Настраиваем мок провайдера для возврата пустого списка бэкендов
-	mockProvider.On("GetLiveBackends").Return([]*backend.Backend{})
+	fetcher := NewFetcher(manager, mockCache, "test-bucket")
 
 	req := createTestRequest(apigw.ListMultipartUploads, "test-bucket", "")
 
 	response := fetcher.ListMultipartUploads(context.Background(), req)
 
-	assert.Equal(t, http.StatusServiceUnavailable, response.StatusCode)
-	assert.NotNil(t, response.Error)
-	assert.Contains(t, response.Error.Error(), "no live backends available")
+	assertNoBackendsResponse(t, response, "5")
+}
+
+func TestFetcher_NoLiveBackends_RetryAfter(t *testing.T) {
+	manager, _ := backendtest.NewServers(t, 1, backend.StateDown)
+	fetcher := NewFetcher(manager, NewStubCache(), "test-bucket")
+	fetcher.SetRetryAfter(1500 * time.Millisecond)
+
+	// Retry-After округляется вверх до целых секунд
+	req := createTestRequest(apigw.GetObject, "test-bucket", "test-key")
+	response := fetcher.GetObject(context.Background(), req, routing.ReadOperationPolicy{Strategy: "first"})
+	assertNoBackendsResponse(t, response, "2")
+
+	req = createTestRequest(apigw.ListObjectsV2, "test-bucket", "")
+	response = fetcher.ListObjects(context.Background(), req)
+	assertNoBackendsResponse(t, response, "2")
+}
+
+// mapUploadIDResolver - UploadIDResolver поверх карты proxy upload id -> (backendID -> uploadID)
+type mapUploadIDResolver map[string]map[string]string
+
+func (r mapUploadIDResolver) ProxyUploadID(backendID, backendUploadID string) (string, bool) {
+	for proxyUploadID, backendUploads := range r {
+		if backendUploads[backendID] == backendUploadID {
+			return proxyUploadID, true
+		}
+	}
+	return "", false
+}
+
+func (r mapUploadIDResolver) BackendUploadIDs(proxyUploadID string) (map[string]string, bool) {
+	backendUploads, ok := r[proxyUploadID]
+	return backendUploads, ok
+}
+
+func TestFetcher_ListMultipartUploads_TwoBackends(t *testing.T) {
+	manager, _ := backendtest.NewServers(t, 2, backend.StateUp)
+	fetcher := NewFetcher(manager, &MockCache{}, "test-bucket")
+
+	// Загрузка "proxy-1" создана через прокси на обоих бэкендах, "orphan" - только на
+	// втором в обход прокси и в списке появиться не должна
+	resolver := mapUploadIDResolver{"proxy-1": {}}
+	for _, id := range []string{"backend-1", "backend-2"} {
+		b, _ := manager.GetBackend(id)
+		out, err := b.S3Client.CreateMultipartUpload(context.Background(), &s3.CreateMultipartUploadInput{
+			Bucket: aws.String("test-bucket"), Key: aws.String("docs/big.bin"),
+		})
+		assert.NoError(t, err)
+		resolver["proxy-1"][id] = aws.ToString(out.UploadId)
+	}
+	b2, _ := manager.GetBackend("backend-2")
+	_, err := b2.S3Client.CreateMultipartUpload(context.Background(), &s3.CreateMultipartUploadInput{
+		Bucket: aws.String("test-bucket"), Key: aws.String("docs/orphan.bin"),
+	})
+	assert.NoError(t, err)
+	fetcher.SetUploadIDResolver(resolver)
+
+	req := createTestRequest(apigw.ListMultipartUploads, "test-bucket", "")
+	req.Query.Set("prefix", "docs/")
+	response := fetcher.ListMultipartUploads(context.Background(), req)
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+
+	var result ListMultipartUploadsResult
+	body, _ := io.ReadAll(response.Body)
+	assert.NoError(t, xml.Unmarshal(body, &result))
+	assert.Equal(t, "test-bucket", result.Bucket)
+	assert.Equal(t, "docs/", result.Prefix)
+	assert.False(t, result.IsTruncated)
+	if assert.Len(t, result.Uploads, 1) {
+		assert.Equal(t, "docs/big.bin", result.Uploads[0].Key)
+		assert.Equal(t, "proxy-1", result.Uploads[0].UploadId)
+	}
+}
+
+func TestFetcher_ListParts(t *testing.T) {
+	manager, _ := backendtest.NewServers(t, 2, backend.StateUp)
+	fetcher := NewFetcher(manager, &MockCache{}, "test-bucket")
+
+	// Загрузка "proxy-1" существует только на втором бэкенде
+	b2, _ := manager.GetBackend("backend-2")
+	created, err := b2.S3Client.CreateMultipartUpload(context.Background(), &s3.CreateMultipartUploadInput{
+		Bucket: aws.String("test-bucket"), Key: aws.String("big.bin"),
+	})
+	assert.NoError(t, err)
+	for _, part := range []int32{1, 2} {
+		_, err := b2.S3Client.UploadPart(context.Background(), &s3.UploadPartInput{
+			Bucket: aws.String("test-bucket"), Key: aws.String("big.bin"), UploadId: created.UploadId,
+			PartNumber: aws.Int32(part), Body: strings.NewReader(strings.Repeat("x", int(part)*10)),
+		})
+		assert.NoError(t, err)
+	}
+	fetcher.SetUploadIDResolver(mapUploadIDResolver{"proxy-1": {"backend-2": aws.ToString(created.UploadId)}})
+
+	t.Run("known upload", func(t *testing.T) {
+		req := createTestRequest(apigw.ListParts, "test-bucket", "big.bin")
+		req.Query.Set("uploadId", "proxy-1")
+		response := fetcher.ListParts(context.Background(), req)
+		assert.Equal(t, http.StatusOK, response.StatusCode)
+
+		var result ListPartsResult
+		body, _ := io.ReadAll(response.Body)
+		assert.NoError(t, xml.Unmarshal(body, &result))
+		assert.Equal(t, "big.bin", result.Key)
+		assert.Equal(t, "proxy-1", result.UploadId)
+		if assert.Len(t, result.Parts, 2) {
+			assert.Equal(t, int32(1), result.Parts[0].PartNumber)
+			assert.Equal(t, int64(10), result.Parts[0].Size)
+			assert.NotEmpty(t, result.Parts[0].ETag)
+			assert.Equal(t, int32(2), result.Parts[1].PartNumber)
+			assert.Equal(t, int64(20), result.Parts[1].Size)
+		}
+	})
 
-	mockProvider.AssertExpectations(t)
+	t.Run("unknown upload", func(t *testing.T) {
+		req := createTestRequest(apigw.ListParts, "test-bucket", "big.bin")
+		req.Query.Set("uploadId", "proxy-unknown")
+		response := fetcher.ListParts(context.Background(), req)
+		assert.Equal(t, http.StatusNotFound, response.StatusCode)
+
+		body, _ := io.ReadAll(response.Body)
+		assert.Contains(t, string(body), "<Code>NoSuchUpload</Code>")
+	})
 }
 
 func TestBytesCountingReader(t *testing.T) {
-	mockMetrics := &MockMetrics{}
 	content := "test content for counting"
 	reader := &bytesCountingReader{
-		reader:    io.NopCloser(strings.NewReader(content)),
-		backendID: "test-backend",
-		metrics:   mockMetrics,
+		reader: io.NopCloser(strings.NewReader(content)),
 	}
 
-	// Настраиваем мок метрик
-	mockMetrics.On("AddBackendBytesRead", "test-backend", int64(len(content))).Return()
-
 	// Читаем все содержимое
 	buf := make([]byte, 1024)
 	totalRead := 0
@@ -343,12 +1103,10 @@ func TestBytesCountingReader(t *testing.T) {
 	}
 
 	assert.Equal(t, len(content), totalRead)
+	assert.Equal(t, int64(len(content)), reader.totalRead)
 
-	// Закрываем reader - должна быть вызвана метрика
 	err := reader.Close()
 	assert.NoError(t, err)
-
-	mockMetrics.AssertExpectations(t)
 }
 
 func TestStubCache(t *testing.T) {
@@ -371,3 +1129,557 @@ func TestProxyContinuationToken(t *testing.T) {
 	assert.Equal(t, "token1", token.BackendTokens["backend1"])
 	assert.Equal(t, "token2", token.BackendTokens["backend2"])
 }
+
+func TestFetcher_GetObjectTagging(t *testing.T) {
+	manager, servers := backendtest.NewServers(t, 1, backend.StateUp)
+	fetcher := NewFetcher(manager, NewStubCache(), "test-bucket")
+
+	servers[0].SetObject("test-key", []byte("data"), time.Time{})
+	servers[0].SetObjectTags("test-key", map[string]string{"env": "prod"})
+
+	req := createTestRequest(apigw.GetObjectTagging, "test-bucket", "test-key")
+	response := fetcher.GetObjectTagging(context.Background(), req)
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	assert.Equal(t, "application/xml", response.Headers.Get("Content-Type"))
+
+	body, err := io.ReadAll(response.Body)
+	assert.NoError(t, err)
+	response.Body.Close()
+
+	var tagging apigw.Tagging
+	assert.NoError(t, xml.Unmarshal(body, &tagging))
+	assert.Equal(t, []apigw.Tag{{Key: "env", Value: "prod"}}, tagging.TagSet)
+}
+
+// peakWriter запоминает наибольший блок, записанный за один вызов Write
+type peakWriter struct {
+	total int
+	peak  int
+}
+
+func (w *peakWriter) Write(p []byte) (int, error) {
+	w.total += len(p)
+	if len(p) > w.peak {
+		w.peak = len(p)
+	}
+	return len(p), nil
+}
+
+func TestMergeListObjectsV2Results_StreamsLargeListing(t *testing.T) {
+	const objectCount = 20000
+
+	output := &s3.ListObjectsV2Output{}
+	for i := 0; i < objectCount; i++ {
+		output.Contents = append(output.Contents, types.Object{
+			Key:          aws.String(fmt.Sprintf("dir/object-%06d.txt", i)),
+			LastModified: aws.Time(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)),
+			ETag:         aws.String(`"d41d8cd98f00b204e9800998ecf8427e"`),
+			Size:         aws.Int64(int64(i)),
+		})
+	}
+	results := []opResult[*s3.ListObjectsV2Output]{{Backend: &backend.Backend{ID: "backend-1"}, Result: output}}
+
+	fetcher := &Fetcher{}
+	req := createTestRequest(apigw.ListObjectsV2, "test-bucket", "")
+	req.Query.Set("max-keys", "100000")
+
+	response := fetcher.mergeListObjectsV2Results(req, results)
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	assert.Empty(t, response.Headers.Get("Content-Length"))
+
+	var body strings.Builder
+	writer := &peakWriter{}
+	_, err := io.Copy(io.MultiWriter(writer, &body), response.Body)
+	assert.NoError(t, err)
+	response.Body.Close()
+
+	// Тело передается небольшими блоками, а не одним буфером со всем XML
+	assert.Greater(t, writer.total, 1<<20)
+	assert.Less(t, writer.peak, 64*1024)
+
+	var result ListObjectsV2Result
+	assert.NoError(t, xml.Unmarshal([]byte(body.String()), &result))
+	assert.Len(t, result.Contents, objectCount)
+	assert.Equal(t, int32(objectCount), result.KeyCount)
+}
+
+func TestWriteListObjectsV2Result_MatchesMarshal(t *testing.T) {
+	result := &ListObjectsV2Result{
+		Name:                  "test-bucket",
+		Prefix:                "dir/",
+		Delimiter:             "/",
+		KeyCount:              2,
+		MaxKeys:               1000,
+		IsTruncated:           true,
+		NextContinuationToken: "token",
+		Contents: []Object{
+			{Key: "dir/a.txt", LastModified: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), ETag: `"etag"`, Size: 1},
+		},
+		CommonPrefixes: []CommonPrefix{{Prefix: "dir/sub/"}},
+	}
+
+	var streamed strings.Builder
+	assert.NoError(t, writeListObjectsV2Result(&streamed, result))
+
+	expected, err := xml.MarshalIndent(result, "", "  ")
+	assert.NoError(t, err)
+	assert.Equal(t, xml.Header+string(expected), streamed.String())
+}
+
+func TestFetcher_GetObject_RedirectLargeObject(t *testing.T) {
+	manager, servers := backendtest.NewServers(t, 1, backend.StateUp)
+	fetcher := NewFetcher(manager, NewStubCache(), "test-bucket")
+
+	servers[0].SetObject("large-key", bytes.Repeat([]byte("a"), 1024), time.Time{})
+	servers[0].SetObject("small-key", []byte("small"), time.Time{})
+
+	policy := routing.ReadOperationPolicy{Strategy: "first", RedirectThreshold: 1024}
+
+	response := fetcher.GetObject(context.Background(), createTestRequest(apigw.GetObject, "test-bucket", "large-key"), policy)
+	assert.Equal(t, http.StatusTemporaryRedirect, response.StatusCode)
+	assert.Nil(t, response.Body)
+
+	location, err := url.Parse(response.Headers.Get("Location"))
+	assert.NoError(t, err)
+	endpoint, _ := url.Parse(servers[0].URL)
+	assert.Equal(t, endpoint.Host, location.Host)
+	assert.Equal(t, "/test-bucket/large-key", location.Path)
+	assert.NotEmpty(t, location.Query().Get("X-Amz-Signature"))
+	assert.Equal(t, "900", location.Query().Get("X-Amz-Expires"))
+	// Решение принимается по HEAD, GET на бэкенде не начинается
+	assert.Equal(t, 0, servers[0].CountRequests(http.MethodGet))
+
+	// Range-запрос большого объекта проксируется
+	req := createTestRequest(apigw.GetObject, "test-bucket", "large-key")
+	req.Headers.Set("Range", "bytes=0-9")
+	response = fetcher.GetObject(context.Background(), req, policy)
+	assert.Equal(t, http.StatusPartialContent, response.StatusCode)
+	data, _ := io.ReadAll(response.Body)
+	response.Body.Close()
+	assert.Equal(t, "aaaaaaaaaa", string(data))
+
+	// Объекты меньше порога проксируются
+	response = fetcher.GetObject(context.Background(), createTestRequest(apigw.GetObject, "test-bucket", "small-key"), policy)
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	data, _ = io.ReadAll(response.Body)
+	response.Body.Close()
+	assert.Equal(t, "small", string(data))
+}
+
+func TestFetcher_GetObject_HedgedSlowPrimary(t *testing.T) {
+	primary := s3mock.NewServer("test-bucket")
+	t.Cleanup(primary.Close)
+	secondary := s3mock.NewServer("test-bucket")
+	t.Cleanup(secondary.Close)
+
+	primaryConfig := backendtest.BackendConfig(primary)
+	primaryConfig.Weight = 10
+	config := &backend.Config{
+		Manager: backend.DefaultManagerConfig(),
+		Backends: map[string]backend.BackendConfig{
+			"primary":   primaryConfig,
+			"secondary": backendtest.BackendConfig(secondary),
+		},
+	}
+	config.Manager.InitialState = backend.StateUp
+	manager, err := backendtest.NewManager(config)
+	if err != nil {
+		t.Fatalf("Failed to create backend manager: %v", err)
+	}
+	fetcher := NewFetcher(manager, NewStubCache(), "test-bucket")
+
+	primary.SetObject("test-key", []byte("primary"), time.Time{})
+	secondary.SetObject("test-key", []byte("secondary"), time.Time{})
+
+	// Основной бэкенд отвечает только после отмены запроса
+	primaryCanceled := make(chan struct{})
+	primary.SetIntercept(func(w http.ResponseWriter, r *http.Request) bool {
+		select {
+		case <-r.Context().Done():
+			close(primaryCanceled)
+		case <-time.After(5 * time.Second):
+		}
+		return false
+	})
+
+	policy := routing.ReadOperationPolicy{Strategy: "first", HedgeDelay: 50 * time.Millisecond, ExposeServedBy: true}
+	start := time.Now()
+	response := fetcher.GetObject(context.Background(), createTestRequest(apigw.GetObject, "test-bucket", "test-key"), policy)
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	assert.Equal(t, "secondary", response.Headers.Get(ServedByHeader))
+	data, _ := io.ReadAll(response.Body)
+	response.Body.Close()
+	assert.Equal(t, "secondary", string(data))
+	assert.Less(t, time.Since(start), 2*time.Second)
+
+	// Запрос к проигравшему основному бэкенду отменяется
+	select {
+	case <-primaryCanceled:
+	case <-time.After(2 * time.Second):
+		t.Error("Expected the request to the slow primary to be canceled")
+	}
+
+	// Без задержки основной бэкенд с большим весом опрашивается первым, и второй не нужен
+	primary.SetIntercept(nil)
+	secondary.ResetRequests()
+	policy.HedgeDelay = time.Second
+	response = fetcher.GetObject(context.Background(), createTestRequest(apigw.GetObject, "test-bucket", "test-key"), policy)
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	assert.Equal(t, "primary", response.Headers.Get(ServedByHeader))
+	response.Body.Close()
+	assert.Equal(t, 0, secondary.CountRequests(http.MethodGet))
+}
+
+func TestFetcher_GetObject_ShadowReadMismatch(t *testing.T) {
+	manager, servers := backendtest.NewServers(t, 2, backend.StateUp)
+	fetcher := NewFetcher(manager, NewStubCache(), "test-bucket")
+
+	servers[0].SetObject("shadow-key", []byte("primary data"), time.Time{})
+	servers[1].SetObject("shadow-key", []byte("stale"), time.Time{})
+
+	mismatches := fetcher.metrics.ShadowMismatchesTotal.WithLabelValues("backend-2", "GET", "size")
+	before := testutil.ToFloat64(mismatches)
+
+	policy := routing.ReadOperationPolicy{Strategy: "first", ShadowBackends: []string{"backend-2"}}
+	response := fetcher.GetObject(context.Background(), createTestRequest(apigw.GetObject, "test-bucket", "shadow-key"), policy)
+
+	// Клиент получает ответ основного бэкенда независимо от теневого
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	data, _ := io.ReadAll(response.Body)
+	response.Body.Close()
+	assert.Equal(t, "primary data", string(data))
+
+	deadline := time.Now().Add(5 * time.Second)
+	for testutil.ToFloat64(mismatches) <= before && time.Now().Before(deadline) {
+		time.Sleep(10 * time.Millisecond)
+	}
+	assert.Equal(t, before+1, testutil.ToFloat64(mismatches), "shadow size mismatch must be recorded")
+}
+
+func TestFetcher_GetObject_CancelLosers(t *testing.T) {
+	manager, servers := backendtest.NewServers(t, 2, backend.StateUp)
+	fetcher := NewFetcher(manager, NewStubCache(), "test-bucket")
+
+	for _, server := range servers {
+		server.SetObject("test-key", []byte("test data"), time.Time{})
+	}
+
+	// Второй бэкенд не отвечает, пока запрос не будет отменен
+	loserCanceled := make(chan struct{})
+	servers[1].SetIntercept(func(w http.ResponseWriter, r *http.Request) bool {
+		select {
+		case <-r.Context().Done():
+			close(loserCanceled)
+		case <-time.After(5 * time.Second):
+		}
+		return false
+	})
+
+	policy := routing.ReadOperationPolicy{Strategy: "first", CancelLosers: true}
+	response := fetcher.GetObject(context.Background(), createTestRequest(apigw.GetObject, "test-bucket", "test-key"), policy)
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+
+	select {
+	case <-loserCanceled:
+	case <-time.After(2 * time.Second):
+		t.Fatal("Request to the losing backend was not canceled")
+	}
+
+	// Отмена проигравшего не обрывает тело победителя
+	data, err := io.ReadAll(response.Body)
+	response.Body.Close()
+	assert.NoError(t, err)
+	assert.Equal(t, "test data", string(data))
+
+	// Отмененный запрос не считается сбоем бэкенда
+	loser, _ := manager.GetBackend("backend-2")
+	assert.Equal(t, backend.StateUp, loser.GetState())
+	failures, _, _ := loser.GetStats()
+	assert.Equal(t, 0, failures)
+}
+
+func TestFetcher_ExecuteFirst_ContextCanceled(t *testing.T) {
+	manager, _ := backendtest.NewServers(t, 2, backend.StateUp)
+	fetcher := NewFetcher(manager, NewStubCache(), "test-bucket")
+
+	// Бэкенды "падают" медленно и не реагируют на отмену контекста
+	release := make(chan struct{})
+	finished := make(chan struct{}, 2)
+	hanging := func(ctx context.Context, req *apigw.S3Request, b *backend.Backend) *apigw.S3Response {
+		defer func() { finished <- struct{}{} }()
+		<-release
+		return &apigw.S3Response{StatusCode: http.StatusInternalServerError, Error: fmt.Errorf("backend %s failed", b.ID)}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	start := time.Now()
+	req := createTestRequest(apigw.GetObject, "test-bucket", "test-key")
+	response, served := fetcher.executeFirst(ctx, req, manager.GetLiveBackends(), hanging, "GetObject", "NoSuchKey", false)
+
+	// Отмененный клиентом запрос завершается сразу, не дожидаясь бэкендов
+	assert.Less(t, time.Since(start), time.Second)
+	assert.Nil(t, served)
+	assert.Equal(t, http.StatusInternalServerError, response.StatusCode)
+	assert.ErrorIs(t, response.Error, context.Canceled)
+
+	// Зависшие воркеры завершаются, как только бэкенды ответят
+	close(release)
+	for i := 0; i < 2; i++ {
+		select {
+		case <-finished:
+		case <-time.After(2 * time.Second):
+			t.Fatal("Backend worker did not finish after the backend answered")
+		}
+	}
+}
+
+// sizedMockCache - мок кэша, сообщающий свой размер
+type sizedMockCache struct {
+	*MockCache
+	entries int
+	size    int64
+}
+
+func (c *sizedMockCache) Stats() (int, int64) {
+	return c.entries, c.size
+}
+
+func TestFetcher_CacheMetrics(t *testing.T) {
+	manager, _ := backendtest.NewServers(t, 1, backend.StateUp)
+	cache := &sizedMockCache{MockCache: &MockCache{}, entries: 3, size: 1024}
+	fetcher := NewFetcher(manager, cache, "test-bucket")
+
+	cachedResponse := &apigw.S3Response{
+		StatusCode: http.StatusOK,
+		Headers:    make(http.Header),
+		Body:       io.NopCloser(strings.NewReader("cached content")),
+	}
+	cache.On("Get", "test-bucket", "cached-key").Return(cachedResponse, true)
+	cache.On("Get", "test-bucket", "missing-key").Return(nil, false)
+
+	hits := fetcher.metrics.CacheHitsTotal.WithLabelValues("GET")
+	misses := fetcher.metrics.CacheMissesTotal.WithLabelValues("HEAD")
+	hitsBefore, missesBefore := testutil.ToFloat64(hits), testutil.ToFloat64(misses)
+
+	policy := routing.ReadOperationPolicy{Strategy: "first"}
+	fetcher.GetObject(context.Background(), createTestRequest(apigw.GetObject, "test-bucket", "cached-key"), policy)
+	fetcher.HeadObject(context.Background(), createTestRequest(apigw.HeadObject, "test-bucket", "missing-key"), policy)
+
+	assert.Equal(t, hitsBefore+1, testutil.ToFloat64(hits))
+	assert.Equal(t, missesBefore+1, testutil.ToFloat64(misses))
+	assert.Equal(t, float64(3), testutil.ToFloat64(fetcher.metrics.CacheEntries))
+	assert.Equal(t, float64(1024), testutil.ToFloat64(fetcher.metrics.CacheSizeBytes))
+	cache.AssertExpectations(t)
+}
+
+// overlappingListResults создает ответы backends бэкендов по keys ключей каждый: ключи
+// бэкендов пересекаются наполовину, у общих ключей разное время изменения
+func overlappingListResults(backends, keys int) []opResult[*s3.ListObjectsV2Output] {
+	base := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	results := make([]opResult[*s3.ListObjectsV2Output], 0, backends)
+	for b := 0; b < backends; b++ {
+		output := &s3.ListObjectsV2Output{
+			CommonPrefixes: []types.CommonPrefix{{Prefix: aws.String(fmt.Sprintf("dir-%d/", b%2))}},
+		}
+		for i := 0; i < keys; i++ {
+			n := b*keys/2 + i
+			output.Contents = append(output.Contents, types.Object{
+				Key:          aws.String(fmt.Sprintf("object-%08d", n)),
+				LastModified: aws.Time(base.Add(time.Duration((n+b)%3) * time.Hour)),
+				ETag:         aws.String(fmt.Sprintf(`"etag-%d-%d"`, b, n)),
+				Size:         aws.Int64(int64(b)),
+			})
+		}
+		results = append(results, opResult[*s3.ListObjectsV2Output]{
+			Backend: &backend.Backend{ID: fmt.Sprintf("backend-%d", b+1)},
+			Result:  output,
+		})
+	}
+	return results
+}
+
+// mergeListBody объединяет результаты с заданным порогом быстрого пути и возвращает тело ответа
+func mergeListBody(tb testing.TB, threshold int, results []opResult[*s3.ListObjectsV2Output]) []byte {
+	fetcher := &Fetcher{}
+	fetcher.SetListMergeThreshold(threshold)
+	req := createTestRequest(apigw.ListObjectsV2, "test-bucket", "")
+	req.Query.Set("max-keys", "100000")
+
+	response := fetcher.mergeListObjectsV2Results(req, results)
+	defer response.Body.Close()
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		tb.Fatalf("Failed to read listing: %v", err)
+	}
+	return body
+}
+
+func TestMergeListObjectsV2Results_FastPathMatchesStreaming(t *testing.T) {
+	for _, backends := range []int{1, 3} {
+		t.Run(fmt.Sprintf("%d backends", backends), func(t *testing.T) {
+			results := overlappingListResults(backends, 500)
+
+			fast := mergeListBody(t, 100000, results)
+			streamed := mergeListBody(t, 1, results)
+			assert.Equal(t, string(fast), string(streamed))
+
+			var result ListObjectsV2Result
+			assert.NoError(t, xml.Unmarshal(streamed, &result))
+			expectedKeys := 500 + (backends-1)*250
+			assert.Len(t, result.Contents, expectedKeys)
+			assert.Equal(t, int32(expectedKeys+len(result.CommonPrefixes)), result.KeyCount)
+			assert.True(t, sort.SliceIsSorted(result.Contents, func(i, j int) bool {
+				return result.Contents[i].Key < result.Contents[j].Key
+			}))
+		})
+	}
+
+	// Для общего ключа выбирается самая новая версия
+	results := overlappingListResults(2, 4)
+	var result ListObjectsV2Result
+	assert.NoError(t, xml.Unmarshal(mergeListBody(t, 1, results), &result))
+	assert.Equal(t, "object-00000002", result.Contents[2].Key)
+	assert.Equal(t, `"etag-0-2"`, result.Contents[2].ETag)
+	assert.Equal(t, "object-00000003", result.Contents[3].Key)
+	assert.Equal(t, `"etag-1-3"`, result.Contents[3].ETag)
+}
+
+func BenchmarkMergeListObjectsV2Results(b *testing.B) {
+	results := overlappingListResults(3, 5000)
+	for _, bm := range []struct {
+		name      string
+		threshold int
+	}{
+		{"FastPath", 100000},
+		{"Streaming", 1},
+	} {
+		b.Run(bm.name, func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				mergeListBody(b, bm.threshold, results)
+			}
+		})
+	}
+}
+
+func TestFetcher_GetObject_NewestFallbackToFirst(t *testing.T) {
+	manager, servers := backendtest.NewServers(t, 2, backend.StateUp)
+	fetcher := NewFetcher(manager, NewStubCache(), "test-bucket")
+
+	for _, server := range servers {
+		server.SetObject("test-key", []byte("content"), time.Time{})
+	}
+	// Второй бэкенд медленно отвечает на HEAD
+	servers[1].SetIntercept(func(w http.ResponseWriter, r *http.Request) bool {
+		if r.Method == http.MethodHead {
+			time.Sleep(150 * time.Millisecond)
+		}
+		return false
+	})
+
+	req := createTestRequest(apigw.GetObject, "test-bucket", "test-key")
+	policy := routing.ReadOperationPolicy{Strategy: "newest", NewestFallbackLatency: 50 * time.Millisecond, NewestFallbackCooldown: time.Minute}
+	fallbacks := fetcher.metrics.NewestFallbacksTotal.WithLabelValues("GET")
+	before := testutil.ToFloat64(fallbacks)
+
+	// Первое чтение выполняется стратегией newest и замеряет медленную фазу HEAD
+	response := fetcher.GetObject(context.Background(), req, policy)
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	response.Body.Close()
+	assert.Equal(t, 2, queriedServers(servers, http.MethodHead))
+	assert.Equal(t, before, testutil.ToFloat64(fallbacks))
+
+	// Следующие чтения деградируют до first: без фазы HEAD
+	for _, server := range servers {
+		server.ResetRequests()
+	}
+	response = fetcher.GetObject(context.Background(), req, policy)
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	response.Body.Close()
+	assert.Equal(t, 0, queriedServers(servers, http.MethodHead))
+	assert.Equal(t, before+1, testutil.ToFloat64(fallbacks))
+
+	// Окно деградации действует только для бакета, где фаза HEAD была медленной
+	for _, server := range servers {
+		server.ResetRequests()
+	}
+	response = fetcher.GetObject(context.Background(), createTestRequest(apigw.GetObject, "other-bucket", "test-key"), policy)
+	if response.Body != nil {
+		response.Body.Close()
+	}
+	assert.Equal(t, 2, queriedServers(servers, http.MethodHead))
+	assert.Equal(t, before+1, testutil.ToFloat64(fallbacks))
+	for _, server := range servers {
+		server.ResetRequests()
+	}
+
+	// Без порога деградация не применяется
+	policy.NewestFallbackLatency = 0
+	response = fetcher.GetObject(context.Background(), req, policy)
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	response.Body.Close()
+	assert.Equal(t, 2, queriedServers(servers, http.MethodHead))
+	assert.Equal(t, before+1, testutil.ToFloat64(fallbacks))
+}
+
+func TestFetcher_ListObjectVersions(t *testing.T) {
+	manager, servers := backendtest.NewServers(t, 2, backend.StateUp)
+	fetcher := NewFetcher(manager, NewStubCache(), "test-bucket")
+
+	servers[0].SetObject("a", []byte("a"), time.Time{})
+	servers[0].SetObject("b", []byte("b"), time.Time{})
+	servers[1].SetObject("b", []byte("b"), time.Time{})
+	servers[1].SetObject("c", []byte("c"), time.Time{})
+
+	listVersions := func(query url.Values) ListVersionsResult {
+		t.Helper()
+		req := createTestRequest(apigw.ListObjectVersions, "test-bucket", "")
+		req.Query = query
+		response := fetcher.ListObjectVersions(context.Background(), req)
+		assert.Equal(t, http.StatusOK, response.StatusCode)
+		body, err := io.ReadAll(response.Body)
+		assert.NoError(t, err)
+		response.Body.Close()
+
+		var result ListVersionsResult
+		assert.NoError(t, xml.Unmarshal(body, &result))
+		return result
+	}
+	keys := func(result ListVersionsResult) []string {
+		var keys []string
+		for _, version := range result.Versions {
+			assert.Equal(t, "null", version.VersionId)
+			assert.True(t, version.IsLatest)
+			keys = append(keys, version.Key)
+		}
+		return keys
+	}
+
+	first := listVersions(url.Values{"versions": {""}, "max-keys": {"2"}})
+	assert.Equal(t, []string{"a", "b"}, keys(first))
+	assert.True(t, first.IsTruncated)
+	assert.Equal(t, "b", first.NextKeyMarker)
+
+	second := listVersions(url.Values{"versions": {""}, "key-marker": {first.NextKeyMarker}, "version-id-marker": {first.NextVersionIdMarker}})
+	assert.Equal(t, []string{"c"}, keys(second))
+	assert.False(t, second.IsTruncated)
+	assert.Equal(t, "b", second.KeyMarker)
+}
+
+func TestFetcher_GetBucketVersioning(t *testing.T) {
+	manager, _ := backendtest.NewServers(t, 1, backend.StateUp)
+	fetcher := NewFetcher(manager, NewStubCache(), "test-bucket")
+
+	req := createTestRequest(apigw.GetBucketVersioning, "test-bucket", "")
+	response := fetcher.GetBucketVersioning(context.Background(), req)
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	body, err := io.ReadAll(response.Body)
+	assert.NoError(t, err)
+	response.Body.Close()
+
+	var config VersioningConfiguration
+	assert.NoError(t, xml.Unmarshal(body, &config))
+	assert.Equal(t, "", config.Status)
+}
@@ -0,0 +1,192 @@
+package fetch
+
+import (
+	"bytes"
+	"container/list"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"s3proxy/apigw"
+	"s3proxy/logger"
+)
+
+// listCacheMaxEntryBytes - листинги с XML больше этого размера не кэшируются,
+// чтобы кэш не удерживал в памяти огромные ответы
+const listCacheMaxEntryBytes = 1 << 20
+
+// defaultListCacheEntries - число записей кэша листингов по умолчанию
+const defaultListCacheEntries = 1000
+
+// listCache - кэш объединенных ответов ListObjectsV2 с коротким TTL и вытеснением LRU.
+// Запись удаляется при записи в бакет ключа, попадающего под ее префикс.
+type listCache struct {
+	mu         sync.Mutex
+	ttl        time.Duration
+	maxEntries int
+	entries    map[string]*list.Element
+	lru        *list.List // Front - последняя использованная запись
+
+	// generation увеличивается при каждой инвалидации. Ответ, собранный до инвалидации,
+	// мог не увидеть записанный объект, поэтому в кэш не попадает.
+	generation uint64
+}
+
+// listCacheEntry - закэшированный XML листинга
+type listCacheEntry struct {
+	key     string
+	bucket  string
+	prefix  string
+	body    []byte
+	expires time.Time
+}
+
+// SetListCache включает кэш листингов ListObjectsV2: объединенный XML хранится ttl
+// в пределах maxEntries записей (0 - defaultListCacheEntries). ttl <= 0 выключает кэш.
+// Записи, префикс которых покрывает измененный ключ, сбрасываются InvalidateListings.
+func (f *Fetcher) SetListCache(ttl time.Duration, maxEntries int) {
+	if ttl <= 0 {
+		f.listCache = nil
+		return
+	}
+	if maxEntries <= 0 {
+		maxEntries = defaultListCacheEntries
+	}
+	f.listCache = &listCache{
+		ttl:        ttl,
+		maxEntries: maxEntries,
+		entries:    make(map[string]*list.Element),
+		lru:        list.New(),
+	}
+}
+
+// InvalidateListings сбрасывает закэшированные листинги бакета, которые могли включать key.
+// Пустой key сбрасывает все листинги бакета (создание и удаление бакета).
+func (f *Fetcher) InvalidateListings(bucket, key string) {
+	if f.listCache != nil {
+		f.listCache.invalidate(bucket, key)
+	}
+}
+
+// listCacheKey возвращает ключ кэша для параметров запроса листинга
+func listCacheKey(req *apigw.S3Request) string {
+	return strings.Join([]string{
+		req.Bucket,
+		req.Query.Get("prefix"),
+		req.Query.Get("delimiter"),
+		req.Query.Get("max-keys"),
+		req.Query.Get("continuation-token"),
+		req.Query.Get("start-after"),
+	}, "\x00")
+}
+
+// get возвращает XML листинга, если запись есть и не истекла
+func (c *listCache) get(key string) ([]byte, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[key]
+	if !ok {
+		return nil, false
+	}
+	entry := elem.Value.(*listCacheEntry)
+	if time.Now().After(entry.expires) {
+		c.remove(elem)
+		return nil, false
+	}
+	c.lru.MoveToFront(elem)
+	return entry.body, true
+}
+
+// currentGeneration возвращает номер поколения для последующего put
+func (c *listCache) currentGeneration() uint64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.generation
+}
+
+// put сохраняет листинг, если с момента generation не было инвалидаций
+func (c *listCache) put(generation uint64, key, bucket, prefix string, body []byte) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if generation != c.generation {
+		logger.Debug("listCache: listing %q was invalidated while being built, not caching", prefix)
+		return
+	}
+	if elem, ok := c.entries[key]; ok {
+		c.remove(elem)
+	}
+	c.entries[key] = c.lru.PushFront(&listCacheEntry{
+		key:     key,
+		bucket:  bucket,
+		prefix:  prefix,
+		body:    body,
+		expires: time.Now().Add(c.ttl),
+	})
+	for c.lru.Len() > c.maxEntries {
+		c.remove(c.lru.Back())
+	}
+}
+
+// invalidate удаляет записи бакета, префикс которых покрывает key
+func (c *listCache) invalidate(bucket, key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.generation++
+	for _, elem := range c.entries {
+		entry := elem.Value.(*listCacheEntry)
+		if entry.bucket == bucket && (key == "" || strings.HasPrefix(key, entry.prefix)) {
+			c.remove(elem)
+		}
+	}
+}
+
+// remove удаляет запись (вызывается под c.mu)
+func (c *listCache) remove(elem *list.Element) {
+	c.lru.Remove(elem)
+	delete(c.entries, elem.Value.(*listCacheEntry).key)
+}
+
+// cachedListResponse формирует ответ из закэшированного XML
+func cachedListResponse(body []byte) *apigw.S3Response {
+	headers := make(http.Header)
+	headers.Set("Content-Type", "application/xml")
+	headers.Set("Content-Length", fmt.Sprintf("%d", len(body)))
+	return &apigw.S3Response{
+		StatusCode: http.StatusOK,
+		Headers:    headers,
+		Body:       io.NopCloser(bytes.NewReader(body)),
+	}
+}
+
+// listCachingBody передает XML листинга клиенту потоком и копирует его в буфер.
+// Прочитанный до конца ответ не больше listCacheMaxEntryBytes сохраняется в кэш.
+type listCachingBody struct {
+	io.ReadCloser
+	buf      bytes.Buffer
+	overflow bool
+	store    func([]byte)
+}
+
+// Read реализует io.Reader
+func (b *listCachingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if !b.overflow {
+		if b.buf.Len()+n > listCacheMaxEntryBytes {
+			b.overflow = true
+			b.buf = bytes.Buffer{}
+		} else {
+			b.buf.Write(p[:n])
+		}
+	}
+	if err == io.EOF && !b.overflow && b.store != nil {
+		b.store(b.buf.Bytes())
+		b.store = nil
+	}
+	return n, err
+}
@@ -0,0 +1,153 @@
+package fetch
+
+import (
+	"sort"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// defaultListMergeThreshold - число ключей во всех ответах бэкендов, до которого
+// листинг объединяется в памяти (быстрый путь)
+const defaultListMergeThreshold = 10000
+
+// SetListMergeThreshold задает порог быстрого пути объединения ListObjectsV2:
+// если ни один бэкенд не вернул усеченный результат и ключей в сумме не больше
+// threshold, список объединяется в памяти. Иначе используется потоковое слияние,
+// которое не строит промежуточных коллекций. 0 - значение по умолчанию.
+func (f *Fetcher) SetListMergeThreshold(threshold int) {
+	f.listMergeThreshold = threshold
+}
+
+// useListFastPath сообщает, что результаты бэкендов можно объединить в памяти
+func (f *Fetcher) useListFastPath(lists [][]types.Object, truncated bool) bool {
+	threshold := f.listMergeThreshold
+	if threshold <= 0 {
+		threshold = defaultListMergeThreshold
+	}
+	total := 0
+	for _, list := range lists {
+		total += len(list)
+	}
+	return !truncated && total <= threshold
+}
+
+// toObject преобразует объект из ответа SDK в элемент Contents
+func toObject(objSDK types.Object) Object {
+	return Object{
+		Key:          aws.ToString(objSDK.Key),
+		LastModified: aws.ToTime(objSDK.LastModified),
+		ETag:         aws.ToString(objSDK.ETag),
+		Size:         aws.ToInt64(objSDK.Size),
+		StorageClass: string(objSDK.StorageClass),
+	}
+}
+
+// mergeObjectsInMemory объединяет небольшие списки объектов: один непустой список
+// (типичный случай для реплик с одинаковым содержимым) используется как есть, несколько -
+// сортируются вместе, а дубликаты ключей схлопываются в самый новый объект.
+func mergeObjectsInMemory(lists [][]types.Object) []Object {
+	var nonEmpty [][]types.Object
+	total := 0
+	for _, list := range lists {
+		if len(list) > 0 {
+			nonEmpty = append(nonEmpty, list)
+			total += len(list)
+		}
+	}
+
+	objects := make([]Object, 0, total)
+	for _, list := range nonEmpty {
+		for _, objSDK := range list {
+			objects = append(objects, toObject(objSDK))
+		}
+	}
+	if len(nonEmpty) <= 1 {
+		return objects
+	}
+
+	// Стабильная сортировка сохраняет порядок бэкендов для одинаковых ключей
+	sort.SliceStable(objects, func(i, j int) bool { return objects[i].Key < objects[j].Key })
+	merged := objects[:0]
+	for _, obj := range objects {
+		if last := len(merged) - 1; last >= 0 && merged[last].Key == obj.Key {
+			if obj.LastModified.After(merged[last].LastModified) {
+				merged[last] = obj
+			}
+			continue
+		}
+		merged = append(merged, obj)
+	}
+	return merged
+}
+
+// objectMerger выполняет потоковое k-way слияние отсортированных по ключу списков
+// объектов бэкендов (S3 возвращает ключи в лексикографическом порядке). Дубликаты
+// ключей схлопываются в самый новый объект. Кроме позиций в списках память не выделяется.
+type objectMerger struct {
+	lists [][]types.Object
+	pos   []int
+}
+
+// newObjectMerger создает слияние списков объектов
+func newObjectMerger(lists [][]types.Object) *objectMerger {
+	return &objectMerger{lists: lists, pos: make([]int, len(lists))}
+}
+
+// Next возвращает следующий объект объединенного списка; false - списки исчерпаны
+func (m *objectMerger) Next() (Object, bool) {
+	var minKey string
+	found := false
+	for i, list := range m.lists {
+		if m.pos[i] >= len(list) {
+			continue
+		}
+		if key := aws.ToString(list[m.pos[i]].Key); !found || key < minKey {
+			minKey, found = key, true
+		}
+	}
+	if !found {
+		return Object{}, false
+	}
+
+	var result Object
+	taken := false
+	for i, list := range m.lists {
+		if m.pos[i] >= len(list) || aws.ToString(list[m.pos[i]].Key) != minKey {
+			continue
+		}
+		obj := toObject(list[m.pos[i]])
+		if !taken || obj.LastModified.After(result.LastModified) {
+			result, taken = obj, true
+		}
+		m.pos[i]++
+	}
+	return result, true
+}
+
+// Count возвращает число объектов объединенного списка, не меняя позицию слияния
+func (m *objectMerger) Count() int {
+	counter := newObjectMerger(m.lists)
+	count := 0
+	for _, ok := counter.Next(); ok; _, ok = counter.Next() {
+		count++
+	}
+	return count
+}
+
+// listContents возвращает списки объектов успешных ответов бэкендов и признак усечения
+func listContents(results []opResult[*s3.ListObjectsV2Output]) ([][]types.Object, bool) {
+	lists := make([][]types.Object, 0, len(results))
+	truncated := false
+	for _, res := range results {
+		if res.Error != nil || res.Result == nil {
+			continue
+		}
+		lists = append(lists, res.Result.Contents)
+		if aws.ToBool(res.Result.IsTruncated) {
+			truncated = true
+		}
+	}
+	return lists, truncated
+}
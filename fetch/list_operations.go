@@ -3,7 +3,6 @@ package fetch
 import (
 	"bytes"
 	"context"
-	"encoding/json"
 	"encoding/xml"
 	"fmt"
 	"io"
@@ -12,7 +11,6 @@ import (
 	"sync"
 	"time"
 	"strconv"
-	"encoding/base64"
 	"errors"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
@@ -47,15 +45,22 @@ type Bucket struct {
 }
 
 type ListObjectsV2Result struct {
-	XMLName               xml.Name `xml:"ListBucketResult"`
-	Name                  string   `xml:"Name"`
-	Prefix                string   `xml:"Prefix,omitempty"`
-	KeyCount              int32    `xml:"KeyCount"`
-	MaxKeys               int32    `xml:"MaxKeys"`
-	IsTruncated           bool     `xml:"IsTruncated"`
-	ContinuationToken     string   `xml:"ContinuationToken,omitempty"`
-	NextContinuationToken string   `xml:"NextContinuationToken,omitempty"`
-	Contents              []Object `xml:"Contents"`
+	XMLName               xml.Name       `xml:"ListBucketResult"`
+	Name                  string         `xml:"Name"`
+	Prefix                string         `xml:"Prefix,omitempty"`
+	Delimiter             string         `xml:"Delimiter,omitempty"`
+	KeyCount              int32          `xml:"KeyCount"`
+	MaxKeys               int32          `xml:"MaxKeys"`
+	IsTruncated           bool           `xml:"IsTruncated"`
+	ContinuationToken     string         `xml:"ContinuationToken,omitempty"`
+	NextContinuationToken string         `xml:"NextContinuationToken,omitempty"`
+	Contents              []Object       `xml:"Contents"`
+	CommonPrefixes        []CommonPrefix `xml:"CommonPrefixes,omitempty"`
+}
+
+// CommonPrefix - "каталог", в который свернуты ключи при листинге с delimiter
+type CommonPrefix struct {
+	Prefix string `xml:"Prefix"`
 }
 
 type Object struct {
@@ -83,27 +88,39 @@ func aggregateAndMerge[T any](
 	backends []*backend.Backend,
 	provider *backend.Manager, // <- ЗАВИСИМОСТЬ ПЕРЕДАЕТСЯ ЯВНО
 	methodName string,
-	performOp func(context.Context, *apigw.S3Request, *backend.Backend, string) opResult[T],
+	performOp func(context.Context, *apigw.S3Request, *backend.Backend, listResume) opResult[T],
 	mergeOp func(*apigw.S3Request, []opResult[T]) *apigw.S3Response,
 ) *apigw.S3Response {
 	logger.Debug("aggregateAndMerge: Started for operation '%s' with %d backends.", methodName, len(backends))
 	
-	// 1. Декодирование токена пагинации
-	backendTokens := make(map[string]string)
+	// 1. Декодирование токена пагинации: без токена все бэкенды начинают с начала
+	// (или с start-after клиента), с токеном - каждый со своей позиции
+	var proxyToken *ProxyContinuationToken
 	if tokenStr := req.Query.Get("continuation-token"); tokenStr != "" {
 		logger.Debug("aggregateAndMerge: Found continuation token, attempting to decode: %s", tokenStr)
-		var proxyToken ProxyContinuationToken
-		if data, err := base64.StdEncoding.DecodeString(tokenStr); err == nil {
-			if json.Unmarshal(data, &proxyToken) == nil {
-				backendTokens = proxyToken.BackendTokens
-				logger.Debug("aggregateAndMerge: Successfully decoded tokens for backends: %v", backendTokens)
-			} else {
-				logger.Error("aggregateAndMerge: Failed to unmarshal JSON from continuation token.")
-			}
+		token, err := decodeProxyContinuationToken(tokenStr)
+		if err != nil {
+			logger.Error("aggregateAndMerge: Failed to decode continuation token: %v", err)
+		} else {
+			proxyToken = token
+			logger.Debug("aggregateAndMerge: Successfully decoded tokens for backends: %v, start-after: %v",
+				token.BackendTokens, token.BackendStartAfter)
+		}
+	}
+	resumes := make(map[string]listResume, len(backends))
+	active := make([]*backend.Backend, 0, len(backends))
+	for _, b := range backends {
+		if proxyToken == nil {
+			resumes[b.ID] = listResume{StartAfter: req.Query.Get("start-after")}
+		} else if resume, done := proxyToken.resumeFor(b.ID); !done {
+			resumes[b.ID] = resume
 		} else {
-			logger.Error("aggregateAndMerge: Failed to decode base64 continuation token: %v", err)
+			logger.Debug("aggregateAndMerge: Backend %s has no more results, skipping.", b.ID)
+			continue
 		}
+		active = append(active, b)
 	}
+	backends = active
 
 	// 2. Параллельные запросы
 	resultsChan := make(chan opResult[T], len(backends))
@@ -115,7 +132,7 @@ func aggregateAndMerge[T any](
 			start := time.Now()
 			
 			logger.Debug("aggregateAndMerge: Starting '%s' for backend %s.", methodName, b.ID)
-			result := performOp(ctx, req, b, backendTokens[b.ID])
+			result := performOp(ctx, req, b, resumes[b.ID])
 			latency := time.Since(start)
 			
 			if result.Error == nil {
@@ -159,25 +176,37 @@ func aggregateAndMerge[T any](
 
 // --- Реализация ListObjectsV2 через универсальный агрегатор ---
 
-// listObjects теперь просто вызывает standalone-функцию aggregateAndMerge
-func (f *Fetcher) listObjects(ctx context.Context, req *apigw.S3Request, backends []*backend.Backend) *apigw.S3Response {
-	return aggregateAndMerge(
+// listObjects теперь просто вызывает standalone-функцию aggregateAndMerge.
+// complete сообщает, что ответили все опрошенные бэкенды: ответы с ошибками в слияние
+// не попадают, и листинг без них может быть неполным даже со статусом 200.
+func (f *Fetcher) listObjects(ctx context.Context, req *apigw.S3Request, backends []*backend.Backend) (response *apigw.S3Response, complete bool) {
+	complete = true
+	response = aggregateAndMerge(
 		ctx, req, backends,
 		f.backendProvider, // <- Передаем зависимость
 		"LIST_OBJECTS",
 		f.performListObjectsV2,
-		f.mergeListObjectsV2Results,
+		func(req *apigw.S3Request, results []opResult[*s3.ListObjectsV2Output]) *apigw.S3Response {
+			for _, res := range results {
+				if res.Error != nil {
+					complete = false
+				}
+			}
+			return f.mergeListObjectsV2Results(req, results)
+		},
 	)
+	return response, complete
 }
 
 // performListObjectsV2 - это метод, который будет передан в aggregateAndMerge
-func (f *Fetcher) performListObjectsV2(ctx context.Context, req *apigw.S3Request, b *backend.Backend, token string) opResult[*s3.ListObjectsV2Output] {
+func (f *Fetcher) performListObjectsV2(ctx context.Context, req *apigw.S3Request, b *backend.Backend, resume listResume) opResult[*s3.ListObjectsV2Output] {
 	input := &s3.ListObjectsV2Input{
 		Bucket: aws.String(b.Config.Bucket),
 	}
 	if p := req.Query.Get("prefix"); p != "" { input.Prefix = aws.String(p) }
 	if d := req.Query.Get("delimiter"); d != "" { input.Delimiter = aws.String(d) }
-	if t := token; t != "" { input.ContinuationToken = aws.String(t) }
+	if t := resume.Token; t != "" { input.ContinuationToken = aws.String(t) }
+	if sa := resume.StartAfter; sa != "" { input.StartAfter = aws.String(sa) }
 	if maxKeysStr := req.Query.Get("max-keys"); maxKeysStr != "" {
 		if maxKeys, err := strconv.ParseInt(maxKeysStr, 10, 32); err == nil && maxKeys > 0 {
 			input.MaxKeys = aws.Int32(int32(maxKeys))
@@ -194,6 +223,11 @@ func (f *Fetcher) performListObjectsV2(ctx context.Context, req *apigw.S3Request
 		aws.ToInt32(input.MaxKeys),
 	)
 
+	release, err := f.acquire(ctx)
+	if err != nil {
+		return opResult[*s3.ListObjectsV2Output]{Backend: b, Error: err}
+	}
+	defer release()
 	result, err := b.S3Client.ListObjectsV2(ctx, input)
 
 	// Добавляем логирование результата сразу после получения
@@ -214,77 +248,179 @@ func (f *Fetcher) performListObjectsV2(ctx context.Context, req *apigw.S3Request
 
 // mergeListObjectsV2Results - это метод, который также передается в aggregateAndMerge
 func (f *Fetcher) mergeListObjectsV2Results(req *apigw.S3Request, results []opResult[*s3.ListObjectsV2Output]) *apigw.S3Response {
-	objectsMap := make(map[string]Object)
-	newBackendTokens := make(map[string]string)
-	isTruncated := false
+	prefixes := listCommonPrefixes(results)
 
-	for _, res := range results {
-		if res.Error != nil || res.Result == nil {
-			continue
-		}
-		for _, objSDK := range res.Result.Contents {
-			key := aws.ToString(objSDK.Key)
-			newObj := Object{
-				Key:          key,
-				LastModified: aws.ToTime(objSDK.LastModified),
-				ETag:         aws.ToString(objSDK.ETag),
-				Size:         aws.ToInt64(objSDK.Size),
-				StorageClass: string(objSDK.StorageClass),
-			}
-			if existing, exists := objectsMap[key]; !exists || newObj.LastModified.After(existing.LastModified) {
-				objectsMap[key] = newObj
-			}
-		}
-		if aws.ToBool(res.Result.IsTruncated) {
-			isTruncated = true
-			if token := aws.ToString(res.Result.NextContinuationToken); token != "" {
-				newBackendTokens[res.Backend.ID] = token
-			}
-		}
+	maxKeys, _ := strconv.ParseInt(req.Query.Get("max-keys"), 10, 32)
+	if maxKeys <= 0 { maxKeys = 1000 }
+
+	// Ключи и префиксы, уже отданные клиенту на предыдущих страницах, отбрасываются:
+	// бэкенд, продолжающий со своего токена, не знает границы объединенного листинга
+	lists, anyTruncated := listContents(results)
+	after := listedUpTo(req)
+	lists = dropListedObjects(lists, after)
+	prefixes = dropListedPrefixes(prefixes, after)
+
+	// Страница объединенного листинга содержит не больше max-keys элементов и не заходит
+	// за последний ключ усеченных ответов бэкендов
+	page := computeListPage(results, lists, prefixes, int(maxKeys))
+	if page.limited && page.boundary < after {
+		page.boundary = after
+	}
+	lists = trimObjects(lists, page)
+	prefixes = trimPrefixes(prefixes, page)
+
+	// Небольшие полные листинги объединяются в памяти, большие - потоковым слиянием
+	// отсортированных ответов бэкендов прямо при записи XML
+	var finalObjects []Object
+	var merger *objectMerger
+	objectCount := 0
+	if f.useListFastPath(lists, anyTruncated) {
+		finalObjects = mergeObjectsInMemory(lists)
+		objectCount = len(finalObjects)
+	} else {
+		merger = newObjectMerger(lists)
+		objectCount = merger.Count()
 	}
+	logger.Debug("mergeListObjectsV2Results: merged %d objects (fast path: %t, truncated: %t)", objectCount, merger == nil, page.truncated)
 
-	finalObjects := make([]Object, 0, len(objectsMap))
-	for _, obj := range objectsMap {
-		finalObjects = append(finalObjects, obj)
+	finalPrefixes := make([]CommonPrefix, 0, len(prefixes))
+	for _, prefix := range prefixes {
+		finalPrefixes = append(finalPrefixes, CommonPrefix{Prefix: prefix})
 	}
-	sort.Slice(finalObjects, func(i, j int) bool { return finalObjects[i].Key < finalObjects[j].Key })
 
 	var nextTokenStr string
-	if isTruncated && len(newBackendTokens) > 0 {
-		proxyToken := ProxyContinuationToken{BackendTokens: newBackendTokens}
-		if tokenBytes, err := json.Marshal(proxyToken); err == nil {
-			nextTokenStr = base64.StdEncoding.EncodeToString(tokenBytes)
+	if page.truncated {
+		token, err := nextListToken(results, page).encode()
+		if err != nil {
+			logger.Error("mergeListObjectsV2Results: failed to encode continuation token: %v", err)
 		}
+		nextTokenStr = token
 	}
-	
-	maxKeys, _ := strconv.ParseInt(req.Query.Get("max-keys"), 10, 32)
-	if maxKeys <= 0 { maxKeys = 1000 }
-	
+
+	// KeyCount в S3 учитывает и объекты, и CommonPrefixes
+	keyCount := objectCount + len(finalPrefixes)
+
 	finalResult := ListObjectsV2Result{
 		Name:                  req.Bucket,
 		Prefix:                req.Query.Get("prefix"),
+		Delimiter:             req.Query.Get("delimiter"),
 		MaxKeys:               int32(maxKeys),
-		KeyCount:              int32(len(finalObjects)),
-		IsTruncated:           nextTokenStr != "", // Более надежная проверка
+		KeyCount:              int32(keyCount),
+		IsTruncated:           page.truncated,
 		ContinuationToken:     req.Query.Get("continuation-token"),
 		NextContinuationToken: nextTokenStr,
 		Contents:              finalObjects,
+		CommonPrefixes:        finalPrefixes,
 	}
 
-	xmlData, err := xml.MarshalIndent(finalResult, "", "  ")
-	if err != nil {
-		return &apigw.S3Response{StatusCode: http.StatusInternalServerError, Error: err}
-	}
-	
+	// Объединенный список может быть очень большим, поэтому XML не собирается в памяти,
+	// а пишется в тело ответа по мере отправки (без Content-Length, chunked)
 	headers := make(http.Header)
 	headers.Set("Content-Type", "application/xml")
-	headers.Set("Content-Length", strconv.Itoa(len(xmlData)))
 
 	return &apigw.S3Response{
 		StatusCode: http.StatusOK,
 		Headers:    headers,
-		Body:       io.NopCloser(bytes.NewReader(xmlData)),
+		Body:       streamListObjectsV2Result(&finalResult, merger),
+	}
+}
+
+// listCommonPrefixes возвращает отсортированное объединение CommonPrefixes ответов бэкендов
+func listCommonPrefixes(results []opResult[*s3.ListObjectsV2Output]) []string {
+	prefixesMap := make(map[string]struct{})
+	for _, res := range results {
+		if res.Error != nil || res.Result == nil {
+			continue
+		}
+		for _, cp := range res.Result.CommonPrefixes {
+			prefixesMap[aws.ToString(cp.Prefix)] = struct{}{}
+		}
+	}
+	prefixes := make([]string, 0, len(prefixesMap))
+	for prefix := range prefixesMap {
+		prefixes = append(prefixes, prefix)
+	}
+	sort.Strings(prefixes)
+	return prefixes
+}
+
+// streamListObjectsV2Result возвращает тело ответа, в которое XML результата
+// записывается фоновой горутиной через pipe. Закрытие тела клиентом прерывает запись.
+// Если merger не nil, элементы Contents берутся из него.
+func streamListObjectsV2Result(result *ListObjectsV2Result, merger *objectMerger) io.ReadCloser {
+	pr, pw := io.Pipe()
+	go func() {
+		pw.CloseWithError(writeMergedListObjectsV2Result(pw, result, merger))
+	}()
+	return pr
+}
+
+// writeListObjectsV2Result пишет XML ListBucketResult поэлементно. Результат совпадает
+// с xml.MarshalIndent(result, "", "  "), но буферизуется не больше одного элемента.
+func writeListObjectsV2Result(w io.Writer, result *ListObjectsV2Result) error {
+	return writeMergedListObjectsV2Result(w, result, nil)
+}
+
+// writeMergedListObjectsV2Result пишет XML ListBucketResult; элементы Contents берутся
+// из merger, если он задан, иначе из result.Contents
+func writeMergedListObjectsV2Result(w io.Writer, result *ListObjectsV2Result, merger *objectMerger) error {
+	if _, err := io.WriteString(w, xml.Header); err != nil {
+		return err
+	}
+
+	enc := xml.NewEncoder(w)
+	enc.Indent("", "  ")
+
+	root := xml.StartElement{Name: xml.Name{Local: "ListBucketResult"}}
+	if err := enc.EncodeToken(root); err != nil {
+		return err
+	}
+
+	fields := []struct {
+		name      string
+		value     interface{}
+		omitEmpty bool
+	}{
+		{"Name", result.Name, false},
+		{"Prefix", result.Prefix, true},
+		{"Delimiter", result.Delimiter, true},
+		{"KeyCount", result.KeyCount, false},
+		{"MaxKeys", result.MaxKeys, false},
+		{"IsTruncated", result.IsTruncated, false},
+		{"ContinuationToken", result.ContinuationToken, true},
+		{"NextContinuationToken", result.NextContinuationToken, true},
+	}
+	for _, field := range fields {
+		if field.omitEmpty && field.value == "" {
+			continue
+		}
+		if err := enc.EncodeElement(field.value, xml.StartElement{Name: xml.Name{Local: field.name}}); err != nil {
+			return err
+		}
+	}
+
+	for _, obj := range result.Contents {
+		if err := enc.EncodeElement(obj, xml.StartElement{Name: xml.Name{Local: "Contents"}}); err != nil {
+			return err
+		}
+	}
+	if merger != nil {
+		for obj, ok := merger.Next(); ok; obj, ok = merger.Next() {
+			if err := enc.EncodeElement(obj, xml.StartElement{Name: xml.Name{Local: "Contents"}}); err != nil {
+				return err
+			}
+		}
+	}
+	for _, prefix := range result.CommonPrefixes {
+		if err := enc.EncodeElement(prefix, xml.StartElement{Name: xml.Name{Local: "CommonPrefixes"}}); err != nil {
+			return err
+		}
+	}
+
+	if err := enc.EncodeToken(root.End()); err != nil {
+		return err
 	}
+	return enc.Flush()
 }
 
 // listBuckets выполняет операцию LIST BUCKETS
@@ -0,0 +1,210 @@
+package fetch
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"sort"
+
+	"s3proxy/apigw"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// listResume - откуда бэкенд продолжает листинг на запрошенной странице
+type listResume struct {
+	Token      string // NextContinuationToken бэкенда с предыдущей страницы
+	StartAfter string // Ключ, после которого продолжить, если токена нет
+}
+
+// decodeProxyContinuationToken разбирает continuation-token, выданный клиенту прокси
+func decodeProxyContinuationToken(value string) (*ProxyContinuationToken, error) {
+	data, err := base64.StdEncoding.DecodeString(value)
+	if err != nil {
+		return nil, err
+	}
+	var token ProxyContinuationToken
+	if err := json.Unmarshal(data, &token); err != nil {
+		return nil, err
+	}
+	return &token, nil
+}
+
+// encode возвращает токен в виде, который отдается клиенту в NextContinuationToken
+func (t *ProxyContinuationToken) encode() (string, error) {
+	data, err := json.Marshal(t)
+	if err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(data), nil
+}
+
+// resumeFor возвращает точку продолжения листинга бэкенда. done - бэкенд отдал
+// все свои ключи на предыдущих страницах, и запрашивать его больше не нужно.
+func (t *ProxyContinuationToken) resumeFor(backendID string) (resume listResume, done bool) {
+	if token, ok := t.BackendTokens[backendID]; ok {
+		return listResume{Token: token}, false
+	}
+	if startAfter, ok := t.BackendStartAfter[backendID]; ok {
+		return listResume{StartAfter: startAfter}, false
+	}
+	return listResume{}, true
+}
+
+// listedUpTo возвращает ключ, до которого включительно листинг уже отдан клиенту:
+// LastKey из continuation-token или start-after первой страницы
+func listedUpTo(req *apigw.S3Request) string {
+	tokenStr := req.Query.Get("continuation-token")
+	if tokenStr == "" {
+		return req.Query.Get("start-after")
+	}
+	token, err := decodeProxyContinuationToken(tokenStr)
+	if err != nil {
+		return ""
+	}
+	return token.LastKey
+}
+
+// dropListedObjects отбрасывает из отсортированных списков объектов ключи не дальше after
+func dropListedObjects(lists [][]types.Object, after string) [][]types.Object {
+	if after == "" {
+		return lists
+	}
+	remaining := make([][]types.Object, len(lists))
+	for i, list := range lists {
+		n := sort.Search(len(list), func(j int) bool { return aws.ToString(list[j].Key) > after })
+		remaining[i] = list[n:]
+	}
+	return remaining
+}
+
+// dropListedPrefixes отбрасывает из отсортированного списка префиксы не дальше after.
+// Ключи после start-after, свернутые по delimiter, дают префикс, уже отданный клиенту.
+func dropListedPrefixes(prefixes []string, after string) []string {
+	if after == "" {
+		return prefixes
+	}
+	return prefixes[sort.SearchStrings(prefixes, after+"\x00"):]
+}
+
+// listPage описывает, какая часть объединенного листинга попадает в ответ клиенту
+type listPage struct {
+	boundary  string // Последний ключ или префикс страницы (если limited)
+	limited   bool   // В страницу попадают только элементы не дальше boundary
+	truncated bool   // Листинг продолжается на следующей странице
+}
+
+// includes сообщает, попадает ли ключ или префикс в страницу
+func (p listPage) includes(entry string) bool {
+	return !p.limited || entry <= p.boundary
+}
+
+// lastListEntry возвращает последний (наибольший) ключ или префикс ответа бэкенда
+func lastListEntry(output *s3.ListObjectsV2Output) string {
+	last := ""
+	if n := len(output.Contents); n > 0 {
+		last = aws.ToString(output.Contents[n-1].Key)
+	}
+	if n := len(output.CommonPrefixes); n > 0 {
+		if prefix := aws.ToString(output.CommonPrefixes[n-1].Prefix); prefix > last {
+			last = prefix
+		}
+	}
+	return last
+}
+
+// computeListPage определяет границу страницы объединенного листинга. Элементы после
+// последнего элемента усеченного ответа бэкенда в страницу не попадают: у этого бэкенда
+// перед ними могут быть еще не полученные ключи. Из оставшихся элементов в страницу
+// попадают первые maxKeys объектов и префиксов в порядке сортировки, как в S3.
+func computeListPage(results []opResult[*s3.ListObjectsV2Output], lists [][]types.Object, prefixes []string, maxKeys int) listPage {
+	var page listPage
+	for _, res := range results {
+		if res.Error != nil || res.Result == nil || !aws.ToBool(res.Result.IsTruncated) {
+			continue
+		}
+		page.truncated = true
+		if last := lastListEntry(res.Result); !page.limited || last < page.boundary {
+			page.boundary, page.limited = last, true
+		}
+	}
+
+	merger := newObjectMerger(lists)
+	obj, hasObj := merger.Next()
+	next, count := 0, 0
+	last := ""
+	for {
+		hasPrefix := next < len(prefixes)
+		if !hasObj && !hasPrefix {
+			return page
+		}
+		var entry string
+		if hasObj && (!hasPrefix || obj.Key < prefixes[next]) {
+			entry = obj.Key
+			obj, hasObj = merger.Next()
+		} else {
+			entry = prefixes[next]
+			next++
+		}
+		if !page.includes(entry) {
+			return page
+		}
+		if count == maxKeys {
+			// Элементов больше maxKeys: страница заканчивается на maxKeys-м
+			page.boundary, page.limited, page.truncated = last, true, true
+			return page
+		}
+		count++
+		last = entry
+	}
+}
+
+// trimObjects отрезает от отсортированных списков объектов ключи за границей страницы
+func trimObjects(lists [][]types.Object, page listPage) [][]types.Object {
+	if !page.limited {
+		return lists
+	}
+	trimmed := make([][]types.Object, len(lists))
+	for i, list := range lists {
+		n := sort.Search(len(list), func(j int) bool { return aws.ToString(list[j].Key) > page.boundary })
+		trimmed[i] = list[:n]
+	}
+	return trimmed
+}
+
+// trimPrefixes отрезает от отсортированного списка префиксов префиксы за границей страницы
+func trimPrefixes(prefixes []string, page listPage) []string {
+	if !page.limited {
+		return prefixes
+	}
+	return prefixes[:sort.SearchStrings(prefixes, page.boundary+"\x00")]
+}
+
+// nextListToken формирует токен следующей страницы. Бэкенд, все элементы ответа которого
+// вошли в страницу, продолжает со своего NextContinuationToken или исчерпан, если его ответ
+// не усечен. Остальные бэкенды продолжают с ключа после границы страницы.
+func nextListToken(results []opResult[*s3.ListObjectsV2Output], page listPage) *ProxyContinuationToken {
+	token := &ProxyContinuationToken{
+		BackendTokens:     make(map[string]string),
+		BackendStartAfter: make(map[string]string),
+		LastKey:           page.boundary,
+	}
+	for _, res := range results {
+		id := res.Backend.ID
+		switch {
+		case res.Error != nil || res.Result == nil:
+			// Бэкенд не ответил: на следующей странице он продолжит с границы
+			token.BackendStartAfter[id] = page.boundary
+		case !page.includes(lastListEntry(res.Result)):
+			token.BackendStartAfter[id] = page.boundary
+		case aws.ToBool(res.Result.IsTruncated):
+			if next := aws.ToString(res.Result.NextContinuationToken); next != "" {
+				token.BackendTokens[id] = next
+			} else {
+				token.BackendStartAfter[id] = page.boundary
+			}
+		}
+	}
+	return token
+}
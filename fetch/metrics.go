@@ -0,0 +1,99 @@
+package fetch
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Metrics - метрики модуля чтения
+type Metrics struct {
+	ShadowReadsTotal      *prometheus.CounterVec // Количество теневых чтений по бэкендам
+	ShadowMismatchesTotal *prometheus.CounterVec // Расхождения теневых чтений с основным ответом
+
+	CacheHitsTotal   *prometheus.CounterVec // Попадания в кэш по операциям
+	CacheMissesTotal *prometheus.CounterVec // Промахи кэша по операциям
+	CacheEntries     prometheus.Gauge       // Текущее число записей в кэше
+	CacheSizeBytes   prometheus.Gauge       // Текущий размер кэша в байтах
+
+	NewestFallbacksTotal *prometheus.CounterVec // Чтения "newest", выполненные стратегией "first" из-за медленной фазы HEAD
+
+	MinRespondingViolationsTotal *prometheus.CounterVec // Чтения, на которые ответило меньше min_responding_backends бэкендов
+
+	ScrubMismatches prometheus.Gauge // Ключи с расходящимися копиями в последнем проходе сверки
+}
+
+var (
+	metricsOnce     sync.Once
+	metricsInstance *Metrics
+)
+
+// NewMetrics возвращает метрики модуля, общие для всех экземпляров (см. backend.NewMetrics)
+func NewMetrics() *Metrics {
+	metricsOnce.Do(func() {
+		metricsInstance = &Metrics{
+			ShadowReadsTotal: promauto.NewCounterVec(
+				prometheus.CounterOpts{
+					Name: "s3proxy_shadow_reads_total",
+					Help: "Total number of reads mirrored to shadow backends",
+				},
+				[]string{"backend", "method"},
+			),
+			ShadowMismatchesTotal: promauto.NewCounterVec(
+				prometheus.CounterOpts{
+					Name: "s3proxy_shadow_read_mismatches_total",
+					Help: "Total number of shadow reads that differ from the primary response",
+				},
+				[]string{"backend", "method", "field"},
+			),
+			CacheHitsTotal: promauto.NewCounterVec(
+				prometheus.CounterOpts{
+					Name: "s3proxy_cache_hits_total",
+					Help: "Total number of reads served from the cache",
+				},
+				[]string{"operation"},
+			),
+			CacheMissesTotal: promauto.NewCounterVec(
+				prometheus.CounterOpts{
+					Name: "s3proxy_cache_misses_total",
+					Help: "Total number of reads not found in the cache",
+				},
+				[]string{"operation"},
+			),
+			CacheEntries: promauto.NewGauge(
+				prometheus.GaugeOpts{
+					Name: "s3proxy_cache_entries",
+					Help: "Current number of entries in the cache",
+				},
+			),
+			CacheSizeBytes: promauto.NewGauge(
+				prometheus.GaugeOpts{
+					Name: "s3proxy_cache_size_bytes",
+					Help: "Current size of the cache in bytes",
+				},
+			),
+			NewestFallbacksTotal: promauto.NewCounterVec(
+				prometheus.CounterOpts{
+					Name: "s3proxy_newest_fallbacks_total",
+					Help: "Total number of newest-strategy reads served with the first strategy because of slow HEAD phases",
+				},
+				[]string{"operation"},
+			),
+			MinRespondingViolationsTotal: promauto.NewCounterVec(
+				prometheus.CounterOpts{
+					Name: "s3proxy_min_responding_violations_total",
+					Help: "Total number of reads answered by fewer backends than min_responding_backends",
+				},
+				[]string{"operation", "action"},
+			),
+			ScrubMismatches: promauto.NewGauge(
+				prometheus.GaugeOpts{
+					Name: "s3proxy_scrub_mismatches",
+					Help: "Number of keys whose copies differed across backends in the last completed scrub pass",
+				},
+			),
+		}
+	})
+	return metricsInstance
+}
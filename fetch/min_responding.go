@@ -0,0 +1,127 @@
+package fetch
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+
+	"s3proxy/apigw"
+	"s3proxy/backend"
+	"s3proxy/logger"
+	"s3proxy/routing"
+)
+
+// DegradedReadHeader - заголовок ответа, отданного при ответе меньшего числа бэкендов,
+// чем требует min_responding_backends (min_responding_action: flag). Значение -
+// "<ответило>/<требуется>".
+const DegradedReadHeader = "X-Amz-Proxy-Degraded-Read"
+
+// Действия при нехватке ответивших бэкендов
+const (
+	minRespondingReject = "reject"
+	minRespondingFlag   = "flag"
+)
+
+// responseTrackerKey - ключ контекста, под которым чтение передает responseTracker
+type responseTrackerKey struct{}
+
+// responseTracker считает бэкенды, ответившие на GET/HEAD объекта. Ответом считаются
+// успех и 404: бэкенд доступен и знает состояние объекта. Ошибки и таймауты не считаются.
+type responseTracker struct {
+	mu        sync.Mutex
+	required  int
+	total     int
+	responded map[string]bool
+	finished  map[string]bool
+	done      chan struct{} // Закрывается, когда ответили required бэкендов или завершились все
+}
+
+// withResponseTracker возвращает контекст, в котором performGetObject и performHeadObject
+// отмечают ответы бэкендов. total - число опрашиваемых бэкендов.
+func withResponseTracker(ctx context.Context, required, total int) (context.Context, *responseTracker) {
+	tracker := &responseTracker{
+		required:  required,
+		total:     total,
+		responded: make(map[string]bool),
+		finished:  make(map[string]bool),
+		done:      make(chan struct{}),
+	}
+	if total == 0 {
+		close(tracker.done)
+	}
+	return context.WithValue(ctx, responseTrackerKey{}, tracker), tracker
+}
+
+// trackResponse отмечает ответ бэкенда в responseTracker контекста (если он есть).
+// Повторный запрос к тому же бэкенду (GET после HEAD) учитывается один раз.
+func trackResponse(ctx context.Context, b *backend.Backend, response *apigw.S3Response) {
+	tracker, ok := ctx.Value(responseTrackerKey{}).(*responseTracker)
+	if !ok {
+		return
+	}
+	tracker.mu.Lock()
+	defer tracker.mu.Unlock()
+
+	if isSuccessResponse(response) || response.StatusCode == http.StatusNotFound {
+		tracker.responded[b.ID] = true
+	}
+	tracker.finished[b.ID] = true
+	if tracker.isDone() {
+		select {
+		case <-tracker.done:
+		default:
+			close(tracker.done)
+		}
+	}
+}
+
+// isDone сообщает, что ждать больше нечего (вызывается под mu)
+func (t *responseTracker) isDone() bool {
+	return len(t.responded) >= t.required || len(t.finished) >= t.total
+}
+
+// wait ждет, пока ответят required бэкендов или завершатся запросы ко всем,
+// и возвращает число ответивших
+func (t *responseTracker) wait(ctx context.Context) int {
+	select {
+	case <-t.done:
+	case <-ctx.Done():
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return len(t.responded)
+}
+
+// enforceMinResponding проверяет, что на чтение ответили не меньше MinRespondingBackends
+// бэкендов. Стратегия "first" возвращает первый успешный ответ, поэтому здесь дожидаются
+// ответов остальных. При нехватке ответ заменяется на 503 или помечается DegradedReadHeader.
+func (f *Fetcher) enforceMinResponding(ctx context.Context, req *apigw.S3Request, response *apigw.S3Response, tracker *responseTracker, policy routing.ReadOperationPolicy, operation string) *apigw.S3Response {
+	responded := tracker.wait(ctx)
+	if responded >= tracker.required {
+		return response
+	}
+
+	action := policy.MinRespondingAction
+	if action == "" {
+		action = minRespondingReject
+	}
+	f.metrics.MinRespondingViolationsTotal.WithLabelValues(operation, action).Inc()
+	logger.Warn("[%s] %s %s/%s: only %d of required %d backends responded (%s)",
+		req.RequestID, operation, req.Bucket, req.Key, responded, tracker.required, action)
+
+	if action == minRespondingFlag {
+		if response.Headers == nil {
+			response.Headers = make(http.Header)
+		}
+		response.Headers.Set(DegradedReadHeader, fmt.Sprintf("%d/%d", responded, tracker.required))
+		return response
+	}
+
+	if response.Body != nil {
+		response.Body.Close()
+	}
+	return apigw.NewErrorResponse(req.RequestID, apigw.ResourcePath(req.Bucket, req.Key),
+		http.StatusServiceUnavailable, "ServiceUnavailable",
+		fmt.Sprintf("Only %d of %d required backends responded. Please retry.", responded, tracker.required))
+}
@@ -0,0 +1,69 @@
+package fetch
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"s3proxy/apigw"
+	"s3proxy/backend"
+	"s3proxy/internal/backendtest"
+	"s3proxy/routing"
+)
+
+// newPartitionedFetcher возвращает Fetcher над двумя бэкендами, из которых отвечает только первый:
+// второй отвечает ошибкой 500, как бэкенд за обрывом сети
+func newPartitionedFetcher(t *testing.T) *Fetcher {
+	t.Helper()
+	manager, servers := backendtest.NewServers(t, 2, backend.StateUp)
+	servers[0].SetObject("test-key", []byte("test data"), time.Now())
+	servers[1].SetObject("test-key", []byte("test data"), time.Now())
+	servers[1].SetIntercept(func(w http.ResponseWriter, r *http.Request) bool {
+		w.WriteHeader(http.StatusInternalServerError)
+		return true
+	})
+	return NewFetcher(manager, NewStubCache(), "test-bucket")
+}
+
+func TestFetcher_MinRespondingBackends(t *testing.T) {
+	for _, strategy := range []string{"first", "newest", "quorum"} {
+		t.Run(strategy+"/reject", func(t *testing.T) {
+			fetcher := newPartitionedFetcher(t)
+			policy := routing.ReadOperationPolicy{Strategy: strategy, MinRespondingBackends: 2}
+
+			response := fetcher.GetObject(context.Background(), createTestRequest(apigw.GetObject, "test-bucket", "test-key"), policy)
+			assert.Equal(t, http.StatusServiceUnavailable, response.StatusCode)
+			body, _ := io.ReadAll(response.Body)
+			assert.Contains(t, string(body), "<Code>ServiceUnavailable</Code>")
+			assert.Contains(t, string(body), "Only 1 of 2 required backends responded")
+		})
+	}
+
+	t.Run("flag", func(t *testing.T) {
+		fetcher := newPartitionedFetcher(t)
+		policy := routing.ReadOperationPolicy{Strategy: "first", MinRespondingBackends: 2, MinRespondingAction: "flag"}
+
+		response := fetcher.HeadObject(context.Background(), createTestRequest(apigw.HeadObject, "test-bucket", "test-key"), policy)
+		assert.Equal(t, http.StatusOK, response.StatusCode)
+		assert.Equal(t, "1/2", response.Headers.Get(DegradedReadHeader))
+	})
+
+	t.Run("enough backends responded", func(t *testing.T) {
+		manager, servers := backendtest.NewServers(t, 2, backend.StateUp)
+		// Объект есть только на одном бэкенде: 404 второго - тоже ответ
+		servers[0].SetObject("test-key", []byte("test data"), time.Now())
+		fetcher := NewFetcher(manager, NewStubCache(), "test-bucket")
+		policy := routing.ReadOperationPolicy{Strategy: "first", MinRespondingBackends: 2}
+
+		response := fetcher.GetObject(context.Background(), createTestRequest(apigw.GetObject, "test-bucket", "test-key"), policy)
+		assert.Equal(t, http.StatusOK, response.StatusCode)
+		assert.Empty(t, response.Headers.Get(DegradedReadHeader))
+		data, _ := io.ReadAll(response.Body)
+		response.Body.Close()
+		assert.Equal(t, "test data", string(data))
+	})
+}
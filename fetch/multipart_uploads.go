@@ -0,0 +1,378 @@
+package fetch
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+
+	"s3proxy/apigw"
+	"s3proxy/backend"
+	"s3proxy/logger"
+)
+
+// defaultMaxUploads - значение max-uploads по умолчанию (как в S3)
+const defaultMaxUploads = 1000
+
+// ListMultipartUploadsResult представляет результат операции ListMultipartUploads
+type ListMultipartUploadsResult struct {
+	XMLName            xml.Name       `xml:"ListMultipartUploadsResult"`
+	Bucket             string         `xml:"Bucket"`
+	KeyMarker          string         `xml:"KeyMarker"`
+	UploadIdMarker     string         `xml:"UploadIdMarker"`
+	NextKeyMarker      string         `xml:"NextKeyMarker,omitempty"`
+	NextUploadIdMarker string         `xml:"NextUploadIdMarker,omitempty"`
+	Prefix             string         `xml:"Prefix,omitempty"`
+	Delimiter          string         `xml:"Delimiter,omitempty"`
+	MaxUploads         int32          `xml:"MaxUploads"`
+	IsTruncated        bool           `xml:"IsTruncated"`
+	Uploads            []Upload       `xml:"Upload"`
+	CommonPrefixes     []CommonPrefix `xml:"CommonPrefixes,omitempty"`
+}
+
+// Upload - незавершенная multipart загрузка в ответе ListMultipartUploads
+type Upload struct {
+	Key          string    `xml:"Key"`
+	UploadId     string    `xml:"UploadId"`
+	Initiated    time.Time `xml:"Initiated"`
+	StorageClass string    `xml:"StorageClass,omitempty"`
+}
+
+// SetUploadIDResolver задает источник идентификаторов multipart upload прокси.
+// Без него ListMultipartUploads отдает идентификаторы бэкендов как есть.
+func (f *Fetcher) SetUploadIDResolver(resolver UploadIDResolver) {
+	f.uploadIDs = resolver
+}
+
+// listMultipartUploads собирает незавершенные загрузки со всех бэкендов через aggregateAndMerge
+func (f *Fetcher) listMultipartUploads(ctx context.Context, req *apigw.S3Request, backends []*backend.Backend) *apigw.S3Response {
+	return aggregateAndMerge(
+		ctx, req, backends,
+		f.backendProvider,
+		"LIST_MULTIPART_UPLOADS",
+		f.performListMultipartUploads,
+		f.mergeListMultipartUploadsResults,
+	)
+}
+
+// performListMultipartUploads запрашивает список загрузок у одного бэкенда
+func (f *Fetcher) performListMultipartUploads(ctx context.Context, req *apigw.S3Request, b *backend.Backend, _ listResume) opResult[*s3.ListMultipartUploadsOutput] {
+	input := &s3.ListMultipartUploadsInput{
+		Bucket: aws.String(b.Config.Bucket),
+	}
+	if p := req.Query.Get("prefix"); p != "" {
+		input.Prefix = aws.String(p)
+	}
+	if d := req.Query.Get("delimiter"); d != "" {
+		input.Delimiter = aws.String(d)
+	}
+	// upload-id-marker содержит идентификатор прокси, непонятный бэкендам. В этом случае
+	// загрузки ключа key-marker нужны целиком, и маркеры применяются после перевода
+	// идентификаторов (см. mergeListMultipartUploadsResults).
+	if k := req.Query.Get("key-marker"); k != "" && req.Query.Get("upload-id-marker") == "" {
+		input.KeyMarker = aws.String(k)
+	}
+	if maxUploads := parseMaxUploads(req); maxUploads != defaultMaxUploads {
+		input.MaxUploads = aws.Int32(maxUploads)
+	}
+
+	logger.Debug("performListMultipartUploads: Sending request to backend %s: Bucket=%s, Prefix='%s', KeyMarker='%s'",
+		b.ID, aws.ToString(input.Bucket), aws.ToString(input.Prefix), aws.ToString(input.KeyMarker))
+
+	release, err := f.acquire(ctx)
+	if err != nil {
+		return opResult[*s3.ListMultipartUploadsOutput]{Backend: b, Error: err}
+	}
+	defer release()
+	result, err := b.S3Client.ListMultipartUploads(ctx, input)
+	if err != nil {
+		logger.Error("performListMultipartUploads: Received error from backend %s: %v", b.ID, err)
+	} else if result != nil {
+		logger.Debug("performListMultipartUploads: Received response from backend %s: Uploads=%d, IsTruncated=%v",
+			b.ID, len(result.Uploads), aws.ToBool(result.IsTruncated))
+	}
+
+	return opResult[*s3.ListMultipartUploadsOutput]{Backend: b, Result: result, Error: err}
+}
+
+// mergeListMultipartUploadsResults объединяет ответы бэкендов. Идентификаторы загрузок
+// бэкендов переводятся в идентификаторы прокси, с которыми клиент может продолжить
+// загрузку; загрузки, неизвестные прокси (созданные в обход него или с истекшим
+// маппингом), пропускаются. Одна загрузка прокси, созданная на нескольких бэкендах,
+// попадает в список один раз.
+func (f *Fetcher) mergeListMultipartUploadsResults(req *apigw.S3Request, results []opResult[*s3.ListMultipartUploadsOutput]) *apigw.S3Response {
+	keyMarker := req.Query.Get("key-marker")
+	uploadIDMarker := req.Query.Get("upload-id-marker")
+	maxUploads := parseMaxUploads(req)
+
+	uploadsMap := make(map[string]Upload)
+	prefixesMap := make(map[string]struct{})
+	// Если бэкенд усек свой список, ключи после его NextKeyMarker могут быть неполными:
+	// объединенный результат обрезается по наименьшему такому маркеру
+	truncatedAt := ""
+	backendTruncated := false
+	isTruncated := false
+	succeeded := 0
+
+	for _, res := range results {
+		if res.Error != nil || res.Result == nil {
+			continue
+		}
+		succeeded++
+		if aws.ToBool(res.Result.IsTruncated) {
+			isTruncated = true
+			if next := aws.ToString(res.Result.NextKeyMarker); next != "" && (!backendTruncated || next < truncatedAt) {
+				truncatedAt = next
+				backendTruncated = true
+			}
+		}
+		for _, cp := range res.Result.CommonPrefixes {
+			prefixesMap[aws.ToString(cp.Prefix)] = struct{}{}
+		}
+		for _, u := range res.Result.Uploads {
+			uploadID := aws.ToString(u.UploadId)
+			if f.uploadIDs != nil {
+				proxyUploadID, found := f.uploadIDs.ProxyUploadID(res.Backend.ID, uploadID)
+				if !found {
+					logger.Debug("mergeListMultipartUploadsResults: upload %s of %s on backend %s is unknown to proxy, skipping",
+						uploadID, aws.ToString(u.Key), res.Backend.ID)
+					continue
+				}
+				uploadID = proxyUploadID
+			}
+
+			upload := Upload{
+				Key:          aws.ToString(u.Key),
+				UploadId:     uploadID,
+				Initiated:    aws.ToTime(u.Initiated).UTC(),
+				StorageClass: string(u.StorageClass),
+			}
+			dedupKey := upload.Key + "\x00" + upload.UploadId
+			if existing, ok := uploadsMap[dedupKey]; ok && !upload.Initiated.Before(existing.Initiated) {
+				continue
+			}
+			uploadsMap[dedupKey] = upload
+		}
+	}
+
+	if succeeded == 0 && len(results) > 0 {
+		return &apigw.S3Response{
+			StatusCode: http.StatusServiceUnavailable,
+			Headers:    make(http.Header),
+			Error:      fmt.Errorf("all backends failed to list multipart uploads"),
+		}
+	}
+
+	uploads := make([]Upload, 0, len(uploadsMap))
+	for _, upload := range uploadsMap {
+		if keyMarker != "" && (upload.Key < keyMarker ||
+			upload.Key == keyMarker && (uploadIDMarker == "" || upload.UploadId <= uploadIDMarker)) {
+			continue
+		}
+		if backendTruncated && upload.Key > truncatedAt {
+			continue
+		}
+		uploads = append(uploads, upload)
+	}
+	sort.Slice(uploads, func(i, j int) bool {
+		if uploads[i].Key != uploads[j].Key {
+			return uploads[i].Key < uploads[j].Key
+		}
+		return uploads[i].UploadId < uploads[j].UploadId
+	})
+
+	prefixes := make([]CommonPrefix, 0, len(prefixesMap))
+	for prefix := range prefixesMap {
+		if backendTruncated && prefix > truncatedAt {
+			continue
+		}
+		prefixes = append(prefixes, CommonPrefix{Prefix: prefix})
+	}
+	sort.Slice(prefixes, func(i, j int) bool { return prefixes[i].Prefix < prefixes[j].Prefix })
+
+	if len(uploads) > int(maxUploads) {
+		uploads = uploads[:maxUploads]
+		isTruncated = true
+	}
+
+	result := ListMultipartUploadsResult{
+		Bucket:         req.Bucket,
+		KeyMarker:      keyMarker,
+		UploadIdMarker: uploadIDMarker,
+		Prefix:         req.Query.Get("prefix"),
+		Delimiter:      req.Query.Get("delimiter"),
+		MaxUploads:     maxUploads,
+		IsTruncated:    isTruncated,
+		Uploads:        uploads,
+		CommonPrefixes: prefixes,
+	}
+	if isTruncated && len(uploads) > 0 {
+		last := uploads[len(uploads)-1]
+		result.NextKeyMarker = last.Key
+		result.NextUploadIdMarker = last.UploadId
+	} else if isTruncated {
+		result.NextKeyMarker = truncatedAt
+	}
+	logger.Debug("mergeListMultipartUploadsResults: merged %d uploads, IsTruncated=%v", len(uploads), isTruncated)
+
+	xmlData, err := xml.Marshal(result)
+	if err != nil {
+		return &apigw.S3Response{
+			StatusCode: http.StatusInternalServerError,
+			Headers:    make(http.Header),
+			Error:      err,
+		}
+	}
+
+	headers := make(http.Header)
+	headers.Set("Content-Type", "application/xml")
+	headers.Set("Content-Length", fmt.Sprintf("%d", len(xmlData)))
+
+	return &apigw.S3Response{
+		StatusCode: http.StatusOK,
+		Headers:    headers,
+		Body:       io.NopCloser(bytes.NewReader(xmlData)),
+	}
+}
+
+// parseMaxUploads возвращает max-uploads запроса (1..1000, по умолчанию 1000)
+func parseMaxUploads(req *apigw.S3Request) int32 {
+	maxUploads, err := strconv.ParseInt(req.Query.Get("max-uploads"), 10, 32)
+	if err != nil || maxUploads <= 0 || maxUploads > defaultMaxUploads {
+		return defaultMaxUploads
+	}
+	return int32(maxUploads)
+}
+
+// ListPartsResult представляет результат операции ListParts
+type ListPartsResult struct {
+	XMLName              xml.Name `xml:"ListPartsResult"`
+	Bucket               string   `xml:"Bucket"`
+	Key                  string   `xml:"Key"`
+	UploadId             string   `xml:"UploadId"`
+	PartNumberMarker     string   `xml:"PartNumberMarker"`
+	NextPartNumberMarker string   `xml:"NextPartNumberMarker,omitempty"`
+	MaxParts             int32    `xml:"MaxParts"`
+	IsTruncated          bool     `xml:"IsTruncated"`
+	StorageClass         string   `xml:"StorageClass,omitempty"`
+	Parts                []Part   `xml:"Part"`
+}
+
+// Part - загруженная часть в ответе ListParts
+type Part struct {
+	PartNumber   int32     `xml:"PartNumber"`
+	LastModified time.Time `xml:"LastModified"`
+	ETag         string    `xml:"ETag"`
+	Size         int64     `xml:"Size"`
+}
+
+// ListParts возвращает загруженные части multipart upload. Идентификатор загрузки прокси
+// переводится в идентификаторы бэкендов через UploadIDResolver; части реплицируются
+// на все бэкенды загрузки, поэтому отдается список первого ответившего из них.
+func (f *Fetcher) ListParts(ctx context.Context, req *apigw.S3Request) *apigw.S3Response {
+	uploadID := req.Query.Get("uploadId")
+
+	backendUploads := make(map[string]string)
+	if f.uploadIDs != nil {
+		var found bool
+		if backendUploads, found = f.uploadIDs.BackendUploadIDs(uploadID); !found {
+			logger.Debug("ListParts: upload %s is unknown to proxy", uploadID)
+			return s3ErrorResponse(req, http.StatusNotFound, "NoSuchUpload")
+		}
+	}
+
+	var backends []*backend.Backend
+	for _, b := range f.backendProvider.GetReadBackends() {
+		if _, ok := backendUploads[b.ID]; ok || f.uploadIDs == nil {
+			backends = append(backends, b)
+		}
+	}
+	if len(backends) == 0 {
+		return f.noBackendsResponse(req)
+	}
+
+	op := func(ctx context.Context, req *apigw.S3Request, b *backend.Backend) *apigw.S3Response {
+		backendUploadID, ok := backendUploads[b.ID]
+		if !ok {
+			backendUploadID = uploadID
+		}
+		return f.performListParts(ctx, req, b, backendUploadID)
+	}
+	response, _ := f.executeFirst(ctx, req, backends, op, "LIST_PARTS", "NoSuchUpload", false)
+	return response
+}
+
+// performListParts запрашивает части загрузки backendUploadID у одного бэкенда.
+// В ответе клиенту идентификатор загрузки остается идентификатором прокси.
+func (f *Fetcher) performListParts(ctx context.Context, req *apigw.S3Request, b *backend.Backend, backendUploadID string) *apigw.S3Response {
+	input := &s3.ListPartsInput{
+		Bucket:   aws.String(b.Config.Bucket),
+		Key:      aws.String(req.Key),
+		UploadId: aws.String(backendUploadID),
+	}
+	if marker := req.Query.Get("part-number-marker"); marker != "" {
+		input.PartNumberMarker = aws.String(marker)
+	}
+	if maxParts, err := strconv.ParseInt(req.Query.Get("max-parts"), 10, 32); err == nil && maxParts > 0 {
+		input.MaxParts = aws.Int32(int32(maxParts))
+	}
+
+	release, err := f.acquire(ctx)
+	if err != nil {
+		return f.handleS3Error(req, err)
+	}
+	defer release()
+	result, err := b.S3Client.ListParts(ctx, input)
+	if err != nil {
+		return f.handleS3Error(req, err)
+	}
+
+	listResult := ListPartsResult{
+		Bucket:               req.Bucket,
+		Key:                  req.Key,
+		UploadId:             req.Query.Get("uploadId"),
+		PartNumberMarker:     aws.ToString(result.PartNumberMarker),
+		NextPartNumberMarker: aws.ToString(result.NextPartNumberMarker),
+		MaxParts:             aws.ToInt32(result.MaxParts),
+		IsTruncated:          aws.ToBool(result.IsTruncated),
+		StorageClass:         string(result.StorageClass),
+		Parts:                make([]Part, 0, len(result.Parts)),
+	}
+	if listResult.PartNumberMarker == "" {
+		listResult.PartNumberMarker = "0"
+	}
+	if listResult.MaxParts == 0 {
+		listResult.MaxParts = 1000
+	}
+	for _, p := range result.Parts {
+		listResult.Parts = append(listResult.Parts, Part{
+			PartNumber:   aws.ToInt32(p.PartNumber),
+			LastModified: aws.ToTime(p.LastModified).UTC(),
+			ETag:         aws.ToString(p.ETag),
+			Size:         aws.ToInt64(p.Size),
+		})
+	}
+
+	xmlData, err := xml.Marshal(listResult)
+	if err != nil {
+		return &apigw.S3Response{StatusCode: http.StatusInternalServerError, Error: err}
+	}
+
+	headers := make(http.Header)
+	headers.Set("Content-Type", "application/xml")
+	headers.Set("Content-Length", fmt.Sprintf("%d", len(xmlData)))
+
+	return &apigw.S3Response{
+		StatusCode: http.StatusOK,
+		Headers:    headers,
+		Body:       io.NopCloser(bytes.NewReader(xmlData)),
+	}
+}
@@ -0,0 +1,41 @@
+package fetch
+
+import (
+	"time"
+
+	"s3proxy/apigw"
+	"s3proxy/logger"
+	"s3proxy/routing"
+)
+
+// readStrategy возвращает стратегию чтения с учетом деградации "newest" до "first":
+// пока действует окно бакета после медленной фазы HEAD (NewestFallbackLatency), чтения
+// выполняются стратегией "first", а каждая замена учитывается в метрике.
+// Окно ведется по бакету: у бакетов разные политики, и медленные HEAD одного бакета
+// не должны отключать "newest" для других
+func (f *Fetcher) readStrategy(req *apigw.S3Request, policy routing.ReadOperationPolicy, operation string) string {
+	if policy.Strategy != "newest" || policy.NewestFallbackLatency <= 0 {
+		return policy.Strategy
+	}
+	until, ok := f.newestFallbackUntil.Load(req.Bucket)
+	if !ok || time.Now().UnixNano() >= until.(int64) {
+		return policy.Strategy
+	}
+	f.metrics.NewestFallbacksTotal.WithLabelValues(operation).Inc()
+	return "first"
+}
+
+// observeNewestHeadPhase включает для бакета запроса деградацию "newest" до "first"
+// на NewestFallbackCooldown, если фаза HEAD запросов заняла больше NewestFallbackLatency
+func (f *Fetcher) observeNewestHeadPhase(req *apigw.S3Request, policy routing.ReadOperationPolicy, elapsed time.Duration) {
+	if policy.NewestFallbackLatency <= 0 || elapsed <= policy.NewestFallbackLatency {
+		return
+	}
+	cooldown := policy.NewestFallbackCooldown
+	if cooldown <= 0 {
+		cooldown = routing.DefaultNewestFallbackCooldown
+	}
+	logger.Warn("Newest strategy HEAD phase for bucket %s took %v (threshold %v), falling back to first strategy for %v",
+		req.Bucket, elapsed, policy.NewestFallbackLatency, cooldown)
+	f.newestFallbackUntil.Store(req.Bucket, time.Now().Add(cooldown).UnixNano())
+}
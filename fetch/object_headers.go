@@ -0,0 +1,153 @@
+package fetch
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// objectHeaderFields - поля ответа GET/HEAD бэкенда, которые передаются клиенту заголовками
+type objectHeaderFields struct {
+	ContentType             *string
+	ContentLength           *int64
+	ContentRange            *string
+	AcceptRanges            *string
+	LastModified            *time.Time
+	ETag                    *string
+	CacheControl            *string
+	ContentDisposition      *string
+	ContentEncoding         *string
+	ContentLanguage         *string
+	Expires                 *string
+	VersionID               *string
+	StorageClass            string
+	ServerSideEncryption    string
+	WebsiteRedirectLocation *string
+	Metadata                map[string]string
+
+	// Контрольные суммы объекта; бэкенд возвращает их только при ChecksumMode ENABLED
+	ChecksumCRC32     *string
+	ChecksumCRC32C    *string
+	ChecksumCRC64NVME *string
+	ChecksumSHA1      *string
+	ChecksumSHA256    *string
+	ChecksumType      string
+}
+
+// getObjectHeaders возвращает заголовки ответа клиенту для результата GetObject
+func getObjectHeaders(result *s3.GetObjectOutput) http.Header {
+	return objectHeaderFields{
+		ContentType:             result.ContentType,
+		ContentLength:           result.ContentLength,
+		ContentRange:            result.ContentRange,
+		AcceptRanges:            result.AcceptRanges,
+		LastModified:            result.LastModified,
+		ETag:                    result.ETag,
+		CacheControl:            result.CacheControl,
+		ContentDisposition:      result.ContentDisposition,
+		ContentEncoding:         result.ContentEncoding,
+		ContentLanguage:         result.ContentLanguage,
+		Expires:                 expiresValue(result.ExpiresString, result.Expires),
+		VersionID:               result.VersionId,
+		StorageClass:            string(result.StorageClass),
+		ServerSideEncryption:    string(result.ServerSideEncryption),
+		WebsiteRedirectLocation: result.WebsiteRedirectLocation,
+		Metadata:                result.Metadata,
+		ChecksumCRC32:           result.ChecksumCRC32,
+		ChecksumCRC32C:          result.ChecksumCRC32C,
+		ChecksumCRC64NVME:       result.ChecksumCRC64NVME,
+		ChecksumSHA1:            result.ChecksumSHA1,
+		ChecksumSHA256:          result.ChecksumSHA256,
+		ChecksumType:            string(result.ChecksumType),
+	}.headers()
+}
+
+// headObjectHeaders возвращает заголовки ответа клиенту для результата HeadObject
+func headObjectHeaders(result *s3.HeadObjectOutput) http.Header {
+	return objectHeaderFields{
+		ContentType:             result.ContentType,
+		ContentLength:           result.ContentLength,
+		ContentRange:            result.ContentRange,
+		AcceptRanges:            result.AcceptRanges,
+		LastModified:            result.LastModified,
+		ETag:                    result.ETag,
+		CacheControl:            result.CacheControl,
+		ContentDisposition:      result.ContentDisposition,
+		ContentEncoding:         result.ContentEncoding,
+		ContentLanguage:         result.ContentLanguage,
+		Expires:                 expiresValue(result.ExpiresString, result.Expires),
+		VersionID:               result.VersionId,
+		StorageClass:            string(result.StorageClass),
+		ServerSideEncryption:    string(result.ServerSideEncryption),
+		WebsiteRedirectLocation: result.WebsiteRedirectLocation,
+		Metadata:                result.Metadata,
+		ChecksumCRC32:           result.ChecksumCRC32,
+		ChecksumCRC32C:          result.ChecksumCRC32C,
+		ChecksumCRC64NVME:       result.ChecksumCRC64NVME,
+		ChecksumSHA1:            result.ChecksumSHA1,
+		ChecksumSHA256:          result.ChecksumSHA256,
+		ChecksumType:            string(result.ChecksumType),
+	}.headers()
+}
+
+// expiresValue возвращает значение Expires как его прислал бэкенд. SDK разбирает заголовок
+// в Expires, только если это корректная дата, поэтому в первую очередь берется исходная строка.
+func expiresValue(raw *string, parsed *time.Time) *string {
+	if raw != nil {
+		return raw
+	}
+	if parsed != nil {
+		value := parsed.UTC().Format(http.TimeFormat)
+		return &value
+	}
+	return nil
+}
+
+// headers формирует заголовки ответа. Пользовательские метаданные передаются
+// заголовками x-amz-meta-<ключ>.
+func (f objectHeaderFields) headers() http.Header {
+	headers := make(http.Header)
+	setHeader := func(name string, value *string) {
+		if value != nil {
+			headers.Set(name, *value)
+		}
+	}
+
+	setHeader("Content-Type", f.ContentType)
+	if f.ContentLength != nil {
+		headers.Set("Content-Length", fmt.Sprintf("%d", *f.ContentLength))
+	}
+	setHeader("Content-Range", f.ContentRange)
+	setHeader("Accept-Ranges", f.AcceptRanges)
+	if f.LastModified != nil {
+		headers.Set("Last-Modified", f.LastModified.Format(time.RFC1123))
+	}
+	setHeader("ETag", f.ETag)
+	setHeader("Cache-Control", f.CacheControl)
+	setHeader("Content-Disposition", f.ContentDisposition)
+	setHeader("Content-Encoding", f.ContentEncoding)
+	setHeader("Content-Language", f.ContentLanguage)
+	setHeader("Expires", f.Expires)
+	setHeader("x-amz-version-id", f.VersionID)
+	if f.StorageClass != "" {
+		headers.Set("x-amz-storage-class", f.StorageClass)
+	}
+	if f.ServerSideEncryption != "" {
+		headers.Set("x-amz-server-side-encryption", f.ServerSideEncryption)
+	}
+	setHeader("x-amz-website-redirect-location", f.WebsiteRedirectLocation)
+	setHeader("x-amz-checksum-crc32", f.ChecksumCRC32)
+	setHeader("x-amz-checksum-crc32c", f.ChecksumCRC32C)
+	setHeader("x-amz-checksum-crc64nvme", f.ChecksumCRC64NVME)
+	setHeader("x-amz-checksum-sha1", f.ChecksumSHA1)
+	setHeader("x-amz-checksum-sha256", f.ChecksumSHA256)
+	if f.ChecksumType != "" {
+		headers.Set("x-amz-checksum-type", f.ChecksumType)
+	}
+	for key, value := range f.Metadata {
+		headers.Set("x-amz-meta-"+key, value)
+	}
+	return headers
+}
@@ -0,0 +1,273 @@
+package fetch
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"s3proxy/apigw"
+	"s3proxy/backend"
+	"s3proxy/logger"
+)
+
+// DefaultScrubKeysPerSecond - скорость сверки по умолчанию (ключей в секунду)
+const DefaultScrubKeysPerSecond = 10
+
+// scrubPageSize - размер страницы объединенного листинга при обходе ключей
+const scrubPageSize = 1000
+
+// ScrubConfig - фоновая сверка копий объектов на бэкендах
+type ScrubConfig struct {
+	// Interval - пауза между проходами сверки (0 - сверка выключена)
+	Interval time.Duration `yaml:"interval"`
+
+	// Prefix - сверяются только ключи с этим префиксом (пусто - все ключи)
+	Prefix string `yaml:"prefix"`
+
+	// SampleRate - доля проверяемых ключей от 0 до 1 (0 - все ключи)
+	SampleRate float64 `yaml:"sample_rate"`
+
+	// KeysPerSecond - сколько ключей проверять в секунду (0 - 10). Каждая проверка -
+	// HEAD на все бэкенды, поэтому сверка не отнимает у клиентов больше этой доли запросов.
+	KeysPerSecond float64 `yaml:"keys_per_second"`
+}
+
+// Enabled сообщает, включена ли сверка
+func (c ScrubConfig) Enabled() bool {
+	return c.Interval > 0
+}
+
+// Validate проверяет параметры сверки
+func (c ScrubConfig) Validate() error {
+	if c.Interval < 0 {
+		return fmt.Errorf("interval cannot be negative")
+	}
+	if c.SampleRate < 0 || c.SampleRate > 1 {
+		return fmt.Errorf("sample_rate must be between 0 and 1")
+	}
+	if c.KeysPerSecond < 0 {
+		return fmt.Errorf("keys_per_second cannot be negative")
+	}
+	return nil
+}
+
+// ScrubCopy - копия ключа на одном бэкенде
+type ScrubCopy struct {
+	BackendID string
+	Exists    bool
+	ETag      string
+	Size      int64
+}
+
+// String описывает копию для логов
+func (c ScrubCopy) String() string {
+	if !c.Exists {
+		return c.BackendID + " missing"
+	}
+	return fmt.Sprintf("%s etag=%s size=%d", c.BackendID, c.ETag, c.Size)
+}
+
+// ScrubMismatch - ключ, копии которого на бэкендах различаются
+type ScrubMismatch struct {
+	Key    string
+	Copies []ScrubCopy
+}
+
+// ScrubResult - итог прохода сверки
+type ScrubResult struct {
+	Checked    int             // Проверено ключей
+	Skipped    int             // Ключей, которые не удалось проверить из-за ошибок бэкендов
+	Mismatches []ScrubMismatch // Ключи с расходящимися копиями
+}
+
+// Scrubber в фоне обходит объединенный листинг и сверяет копии ключей на всех доступных
+// для чтения бэкендах: ключ должен быть на каждом бэкенде с одинаковыми ETag и размером.
+// Расхождения выводятся в лог, их число за последний проход - в метрику s3proxy_scrub_mismatches.
+// Листинг и HEAD выполняются теми же примитивами, что и запросы клиентов, с учетом
+// ограничения одновременных запросов (SetMaxConcurrentRequests).
+type Scrubber struct {
+	fetcher *Fetcher
+	config  ScrubConfig
+	metrics *Metrics
+	sample  func() float64
+}
+
+// NewScrubber создает сверку копий для бэкендов fetcher
+func NewScrubber(fetcher *Fetcher, config ScrubConfig) *Scrubber {
+	if config.KeysPerSecond == 0 {
+		config.KeysPerSecond = DefaultScrubKeysPerSecond
+	}
+	return &Scrubber{
+		fetcher: fetcher,
+		config:  config,
+		metrics: NewMetrics(),
+		sample:  rand.Float64,
+	}
+}
+
+// Run выполняет проходы сверки с паузой Interval между ними, пока не отменен ctx
+func (s *Scrubber) Run(ctx context.Context) {
+	logger.Info("Scrub: started, interval=%v, keys_per_second=%v, sample_rate=%v",
+		s.config.Interval, s.config.KeysPerSecond, s.config.SampleRate)
+	for {
+		start := time.Now()
+		result, err := s.Scrub(ctx)
+		switch {
+		case ctx.Err() != nil:
+			logger.Info("Scrub: stopped")
+			return
+		case err != nil:
+			logger.Error("Scrub: pass failed: %v", err)
+		default:
+			logger.Info("Scrub: pass completed in %v: %d keys checked, %d skipped, %d mismatches",
+				time.Since(start), result.Checked, result.Skipped, len(result.Mismatches))
+		}
+
+		select {
+		case <-ctx.Done():
+			logger.Info("Scrub: stopped")
+			return
+		case <-time.After(s.config.Interval):
+		}
+	}
+}
+
+// Scrub выполняет один проход сверки. Метрика обновляется только по завершении полного
+// прохода; при отмене ctx возвращается частичный результат и ошибка ctx.
+func (s *Scrubber) Scrub(ctx context.Context) (*ScrubResult, error) {
+	result := &ScrubResult{}
+	backends := s.fetcher.backendProvider.GetReadBackends()
+	if len(backends) < 2 {
+		logger.Debug("Scrub: %d backends available for reading, nothing to compare", len(backends))
+		return result, nil
+	}
+
+	ticker := time.NewTicker(time.Duration(float64(time.Second) / s.config.KeysPerSecond))
+	defer ticker.Stop()
+
+	token := ""
+	for {
+		page, err := s.listPage(ctx, backends, token)
+		if err != nil {
+			return result, err
+		}
+		for _, object := range page.Contents {
+			if s.config.SampleRate > 0 && s.sample() >= s.config.SampleRate {
+				continue
+			}
+			select {
+			case <-ctx.Done():
+				return result, ctx.Err()
+			case <-ticker.C:
+			}
+			s.checkKey(ctx, object.Key, backends, result)
+		}
+		if !page.IsTruncated || page.NextContinuationToken == "" {
+			break
+		}
+		token = page.NextContinuationToken
+	}
+
+	s.metrics.ScrubMismatches.Set(float64(len(result.Mismatches)))
+	return result, nil
+}
+
+// listPage запрашивает страницу объединенного листинга ключей всех бэкендов. Кэш листингов
+// не используется: сверка должна видеть текущее состояние бэкендов.
+func (s *Scrubber) listPage(ctx context.Context, backends []*backend.Backend, token string) (*ListObjectsV2Result, error) {
+	query := url.Values{}
+	query.Set("list-type", "2")
+	query.Set("max-keys", strconv.Itoa(scrubPageSize))
+	if s.config.Prefix != "" {
+		query.Set("prefix", s.config.Prefix)
+	}
+	if token != "" {
+		query.Set("continuation-token", token)
+	}
+	req := s.request(ctx, apigw.ListObjectsV2, "")
+	req.Query = query
+
+	response, _ := s.fetcher.listObjects(ctx, req, backends)
+	if response.Body != nil {
+		defer response.Body.Close()
+	}
+	if response.StatusCode != http.StatusOK {
+		if response.Error != nil {
+			return nil, fmt.Errorf("list objects: %w", response.Error)
+		}
+		return nil, fmt.Errorf("list objects: status %d", response.StatusCode)
+	}
+
+	var page ListObjectsV2Result
+	if err := xml.NewDecoder(response.Body).Decode(&page); err != nil {
+		return nil, fmt.Errorf("decode list objects response: %w", err)
+	}
+	return &page, nil
+}
+
+// checkKey выполняет HEAD ключа на каждом бэкенде и добавляет расхождение в result.
+// Ключ пропускается, если хотя бы один бэкенд ответил ошибкой, отличной от 404.
+func (s *Scrubber) checkKey(ctx context.Context, key string, backends []*backend.Backend, result *ScrubResult) {
+	req := s.request(ctx, apigw.HeadObject, key)
+	copies := make([]ScrubCopy, 0, len(backends))
+	for _, b := range backends {
+		response := s.fetcher.performHeadObject(ctx, req, b)
+		switch response.StatusCode {
+		case http.StatusOK:
+			size, _ := strconv.ParseInt(response.Headers.Get("Content-Length"), 10, 64)
+			copies = append(copies, ScrubCopy{
+				BackendID: b.ID,
+				Exists:    true,
+				ETag:      response.Headers.Get("ETag"),
+				Size:      size,
+			})
+		case http.StatusNotFound:
+			copies = append(copies, ScrubCopy{BackendID: b.ID})
+		default:
+			logger.Warn("Scrub: cannot verify key %q on backend %s: status %d, error: %v",
+				key, b.ID, response.StatusCode, response.Error)
+			result.Skipped++
+			return
+		}
+	}
+	result.Checked++
+
+	if copiesMatch(copies) {
+		return
+	}
+	descriptions := make([]string, len(copies))
+	for i, c := range copies {
+		descriptions[i] = c.String()
+	}
+	logger.Warn("Scrub: copies of key %q differ across backends: %s", key, strings.Join(descriptions, ", "))
+	result.Mismatches = append(result.Mismatches, ScrubMismatch{Key: key, Copies: copies})
+}
+
+// copiesMatch сообщает, что ключ есть на всех бэкендах с одинаковыми ETag и размером
+func copiesMatch(copies []ScrubCopy) bool {
+	for _, c := range copies {
+		if !c.Exists || c.ETag != copies[0].ETag || c.Size != copies[0].Size {
+			return false
+		}
+	}
+	return true
+}
+
+// request формирует внутренний запрос сверки к виртуальному бакету
+func (s *Scrubber) request(ctx context.Context, operation apigw.S3Operation, key string) *apigw.S3Request {
+	return &apigw.S3Request{
+		Operation: operation,
+		Bucket:    s.fetcher.virtualBucket,
+		Key:       key,
+		Headers:   make(http.Header),
+		Query:     make(url.Values),
+		Context:   ctx,
+		RequestID: "scrub",
+	}
+}
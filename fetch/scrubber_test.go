@@ -0,0 +1,69 @@
+package fetch
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"s3proxy/backend"
+	"s3proxy/internal/backendtest"
+)
+
+func TestScrubber_MissingKeyRecordedAsMismatch(t *testing.T) {
+	manager, servers := backendtest.NewServers(t, 2, backend.StateUp)
+	now := time.Now()
+	servers[0].SetObject("same.txt", []byte("identical"), now)
+	servers[1].SetObject("same.txt", []byte("identical"), now)
+	servers[0].SetObject("only-first.txt", []byte("lost on second"), now)
+
+	fetcher := NewFetcher(manager, NewStubCache(), "test-bucket")
+	scrubber := NewScrubber(fetcher, ScrubConfig{Interval: time.Hour, KeysPerSecond: 1000})
+
+	result, err := scrubber.Scrub(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 2, result.Checked)
+	assert.Equal(t, 0, result.Skipped)
+	require.Len(t, result.Mismatches, 1)
+
+	mismatch := result.Mismatches[0]
+	assert.Equal(t, "only-first.txt", mismatch.Key)
+	require.Len(t, mismatch.Copies, 2)
+	copies := make(map[string]ScrubCopy)
+	for _, c := range mismatch.Copies {
+		copies[c.BackendID] = c
+	}
+	assert.True(t, copies["backend-1"].Exists)
+	assert.Equal(t, int64(len("lost on second")), copies["backend-1"].Size)
+	assert.False(t, copies["backend-2"].Exists)
+
+	assert.Equal(t, float64(1), testutil.ToFloat64(fetcher.metrics.ScrubMismatches))
+}
+
+func TestScrubber_Cancelled(t *testing.T) {
+	manager, servers := backendtest.NewServers(t, 2, backend.StateUp)
+	for _, server := range servers {
+		server.SetObject("a.txt", []byte("a"), time.Now())
+		server.SetObject("b.txt", []byte("b"), time.Now())
+	}
+
+	fetcher := NewFetcher(manager, NewStubCache(), "test-bucket")
+	// Одна проверка в минуту: проход ждет ограничителя скорости до отмены контекста
+	scrubber := NewScrubber(fetcher, ScrubConfig{Interval: time.Hour, KeysPerSecond: 1.0 / 60})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	result, err := scrubber.Scrub(ctx)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Equal(t, 0, result.Checked)
+}
+
+func TestScrubConfigValidate(t *testing.T) {
+	assert.Error(t, ScrubConfig{Interval: -time.Second}.Validate())
+	assert.Error(t, ScrubConfig{SampleRate: 1.5}.Validate())
+	assert.Error(t, ScrubConfig{KeysPerSecond: -1}.Validate())
+	assert.NoError(t, ScrubConfig{Interval: time.Hour, SampleRate: 0.1, KeysPerSecond: 50}.Validate())
+}
@@ -0,0 +1,102 @@
+package fetch
+
+import (
+	"context"
+	"time"
+
+	"s3proxy/apigw"
+	"s3proxy/backend"
+	"s3proxy/logger"
+	"s3proxy/routing"
+)
+
+// shadowReadTimeout ограничивает время теневого чтения: оно выполняется после ответа
+// клиенту и не должно висеть бесконечно на медленном бэкенде
+const shadowReadTimeout = 30 * time.Second
+
+// shadowSnapshot - поля ответа, которые сравниваются при теневом чтении
+type shadowSnapshot struct {
+	status int
+	etag   string
+	size   string
+}
+
+// snapshotResponse запоминает сравниваемые поля ответа. Снимок делается до отдачи
+// ответа клиенту, потому что заголовки ответа дальше могут меняться.
+func snapshotResponse(response *apigw.S3Response) shadowSnapshot {
+	if response == nil {
+		return shadowSnapshot{}
+	}
+	snapshot := shadowSnapshot{status: response.StatusCode}
+	if response.Headers != nil {
+		snapshot.etag = response.Headers.Get("ETag")
+		snapshot.size = response.Headers.Get("Content-Length")
+	}
+	return snapshot
+}
+
+// splitShadowBackends разделяет живые бэкенды на обслуживающие чтение и теневые (policy.ShadowBackends)
+func splitShadowBackends(backends []*backend.Backend, policy routing.ReadOperationPolicy) (serving, shadows []*backend.Backend) {
+	if len(policy.ShadowBackends) == 0 {
+		return backends, nil
+	}
+	shadowIDs := make(map[string]bool, len(policy.ShadowBackends))
+	for _, id := range policy.ShadowBackends {
+		shadowIDs[id] = true
+	}
+	for _, b := range backends {
+		if shadowIDs[b.ID] {
+			shadows = append(shadows, b)
+		} else {
+			serving = append(serving, b)
+		}
+	}
+	return serving, shadows
+}
+
+// startShadowReads асинхронно повторяет чтение на теневых бэкендах и сравнивает
+// статус, ETag и размер с ответом основного пути. Ответ клиенту от этого не зависит.
+func (f *Fetcher) startShadowReads(req *apigw.S3Request, shadows []*backend.Backend, op backendOperation, methodName string, primary *apigw.S3Response, policy routing.ReadOperationPolicy) {
+	if len(shadows) == 0 {
+		return
+	}
+	expected := snapshotResponse(primary)
+	for _, b := range shadows {
+		go f.shadowRead(req, b, op, methodName, expected, policy.ShadowLogMismatches)
+	}
+}
+
+// shadowRead выполняет теневое чтение на одном бэкенде и учитывает расхождения в метриках
+func (f *Fetcher) shadowRead(req *apigw.S3Request, b *backend.Backend, op backendOperation, methodName string, expected shadowSnapshot, logMismatches bool) {
+	// Контекст клиента к этому моменту может быть уже отменен
+	ctx, cancel := context.WithTimeout(context.Background(), shadowReadTimeout)
+	defer cancel()
+
+	response := op(ctx, req, b)
+	if response != nil && response.Body != nil {
+		response.Body.Close()
+	}
+	actual := snapshotResponse(response)
+
+	f.metrics.ShadowReadsTotal.WithLabelValues(b.ID, methodName).Inc()
+
+	mismatch := func(field string, want, got interface{}) {
+		f.metrics.ShadowMismatchesTotal.WithLabelValues(b.ID, methodName, field).Inc()
+		if logMismatches {
+			logger.Warn("Shadow %s %s/%s on backend %s: %s mismatch, primary=%v shadow=%v",
+				methodName, req.Bucket, req.Key, b.ID, field, want, got)
+		}
+	}
+
+	if actual.status != expected.status {
+		mismatch("status", expected.status, actual.status)
+		// При разных статусах ETag и размер не сравниваются
+		return
+	}
+	if actual.etag != expected.etag {
+		mismatch("etag", expected.etag, actual.etag)
+	}
+	if actual.size != expected.size {
+		mismatch("size", expected.size, actual.size)
+	}
+}
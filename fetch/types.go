@@ -10,6 +10,27 @@ type Cache interface {
 	Get(bucket, key string) (response *apigw.S3Response, found bool)
 }
 
+// CacheStats - необязательный интерфейс кэша для метрик его размера.
+// Если кэш его реализует, Fetcher обновляет gauge'и s3proxy_cache_entries
+// и s3proxy_cache_size_bytes после каждого обращения.
+type CacheStats interface {
+	// Stats возвращает текущее число записей и их суммарный размер в байтах
+	Stats() (entries int, sizeBytes int64)
+}
+
+// UploadIDResolver - интерфейс для перевода идентификаторов multipart upload бэкендов
+// в идентификаторы прокси. Реализуется Replicator'ом, который хранит маппинги
+// ProxyUploadId -> backend uploadIds.
+type UploadIDResolver interface {
+	// ProxyUploadID возвращает идентификатор прокси для загрузки backendUploadID
+	// на бэкенде backendID или false, если прокси об этой загрузке не знает
+	ProxyUploadID(backendID, backendUploadID string) (proxyUploadID string, found bool)
+
+	// BackendUploadIDs возвращает идентификаторы загрузки proxyUploadID на бэкендах
+	// (backendID -> uploadID) или false, если загрузка неизвестна или истекла
+	BackendUploadIDs(proxyUploadID string) (backendUploads map[string]string, found bool)
+}
+
 // Metrics - интерфейс для сбора метрик операций чтения
 // type Metrics interface {
 // 	// ObserveBackendRequestLatency записывает время выполнения запроса к бэкенду
@@ -26,4 +47,11 @@ type Cache interface {
 type ProxyContinuationToken struct {
 	// BackendTokens содержит токены продолжения для каждого бэкенда
 	BackendTokens map[string]string `json:"backend_tokens"`
+	// BackendStartAfter содержит ключ, после которого продолжается листинг бэкенда,
+	// если его ответ не вошел в страницу целиком. Бэкенд, которого нет ни в одном
+	// из списков, уже отдал все ключи.
+	BackendStartAfter map[string]string `json:"backend_start_after,omitempty"`
+	// LastKey - последний ключ или префикс, отданный клиенту. Элементы не дальше него
+	// отбрасываются из ответов бэкендов на следующих страницах.
+	LastKey string `json:"last_key,omitempty"`
 }
@@ -0,0 +1,182 @@
+package fetch
+
+import (
+	"bytes"
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+
+	"s3proxy/apigw"
+	"s3proxy/logger"
+)
+
+// nullVersionID - идентификатор версии объекта в бакете без версионирования
+const nullVersionID = "null"
+
+// ListVersionsResult представляет результат операции ListObjectVersions
+type ListVersionsResult struct {
+	XMLName             xml.Name        `xml:"ListVersionsResult"`
+	Xmlns               string          `xml:"xmlns,attr,omitempty"`
+	Name                string          `xml:"Name"`
+	Prefix              string          `xml:"Prefix"`
+	KeyMarker           string          `xml:"KeyMarker"`
+	VersionIdMarker     string          `xml:"VersionIdMarker"`
+	NextKeyMarker       string          `xml:"NextKeyMarker,omitempty"`
+	NextVersionIdMarker string          `xml:"NextVersionIdMarker,omitempty"`
+	MaxKeys             int32           `xml:"MaxKeys"`
+	Delimiter           string          `xml:"Delimiter,omitempty"`
+	IsTruncated         bool            `xml:"IsTruncated"`
+	Versions            []ObjectVersion `xml:"Version"`
+	CommonPrefixes      []CommonPrefix  `xml:"CommonPrefixes,omitempty"`
+}
+
+// ObjectVersion - версия объекта в ответе ListObjectVersions
+type ObjectVersion struct {
+	Key          string    `xml:"Key"`
+	VersionId    string    `xml:"VersionId"`
+	IsLatest     bool      `xml:"IsLatest"`
+	LastModified time.Time `xml:"LastModified"`
+	ETag         string    `xml:"ETag"`
+	Size         int64     `xml:"Size"`
+	StorageClass string    `xml:"StorageClass,omitempty"`
+}
+
+// VersioningConfiguration представляет результат операции GetBucketVersioning.
+// Пустой Status означает, что версионирование бакета никогда не включалось.
+type VersioningConfiguration struct {
+	XMLName xml.Name `xml:"VersioningConfiguration"`
+	Xmlns   string   `xml:"xmlns,attr,omitempty"`
+	Status  string   `xml:"Status,omitempty"`
+}
+
+// ListObjectVersions отдает листинг бакета в формате ListVersionsResult. Версии объектов
+// через прокси недоступны: бэкенды назначают версиям разные идентификаторы. Поэтому каждый
+// объект объединенного листинга отдается единственной текущей версией "null", как в бакете
+// без версионирования. key-marker продолжает листинг после ключа, version-id-marker не влияет.
+func (f *Fetcher) ListObjectVersions(ctx context.Context, req *apigw.S3Request) *apigw.S3Response {
+	backends := f.backendProvider.GetReadBackends()
+	if len(backends) == 0 {
+		return f.noBackendsResponse(req)
+	}
+	return aggregateAndMerge(
+		ctx, versionsListRequest(req), backends,
+		f.backendProvider,
+		"LIST_OBJECT_VERSIONS",
+		f.performListObjectsV2,
+		f.mergeListObjectVersionsResults,
+	)
+}
+
+// versionsListRequest переводит запрос ListObjectVersions в запрос ListObjectsV2 к бэкендам:
+// key-marker становится start-after
+func versionsListRequest(req *apigw.S3Request) *apigw.S3Request {
+	listReq := *req
+	listReq.Query = url.Values{}
+	for _, name := range []string{"prefix", "delimiter", "max-keys"} {
+		if value := req.Query.Get(name); value != "" {
+			listReq.Query.Set(name, value)
+		}
+	}
+	if marker := req.Query.Get("key-marker"); marker != "" {
+		listReq.Query.Set("start-after", marker)
+	}
+	listReq.Query.Set("version-id-marker", req.Query.Get("version-id-marker"))
+	return &listReq
+}
+
+// mergeListObjectVersionsResults объединяет листинги бэкендов так же, как ListObjectsV2,
+// и оформляет страницу как ListVersionsResult. Следующая страница продолжается с
+// NextKeyMarker, поэтому токены бэкендов не нужны.
+func (f *Fetcher) mergeListObjectVersionsResults(req *apigw.S3Request, results []opResult[*s3.ListObjectsV2Output]) *apigw.S3Response {
+	maxKeys, _ := strconv.ParseInt(req.Query.Get("max-keys"), 10, 32)
+	if maxKeys <= 0 {
+		maxKeys = 1000
+	}
+
+	keyMarker := req.Query.Get("start-after")
+	lists, _ := listContents(results)
+	lists = dropListedObjects(lists, keyMarker)
+	prefixes := dropListedPrefixes(listCommonPrefixes(results), keyMarker)
+
+	page := computeListPage(results, lists, prefixes, int(maxKeys))
+	lists = trimObjects(lists, page)
+	prefixes = trimPrefixes(prefixes, page)
+
+	objects := mergeObjectsInMemory(lists)
+	versions := make([]ObjectVersion, 0, len(objects))
+	for _, obj := range objects {
+		versions = append(versions, ObjectVersion{
+			Key:          obj.Key,
+			VersionId:    nullVersionID,
+			IsLatest:     true,
+			LastModified: obj.LastModified,
+			ETag:         obj.ETag,
+			Size:         obj.Size,
+			StorageClass: obj.StorageClass,
+		})
+	}
+	commonPrefixes := make([]CommonPrefix, 0, len(prefixes))
+	for _, prefix := range prefixes {
+		commonPrefixes = append(commonPrefixes, CommonPrefix{Prefix: prefix})
+	}
+	logger.Debug("mergeListObjectVersionsResults: merged %d objects and %d prefixes (truncated: %t)",
+		len(versions), len(commonPrefixes), page.truncated)
+
+	result := ListVersionsResult{
+		Xmlns:           "http://s3.amazonaws.com/doc/2006-03-01/",
+		Name:            req.Bucket,
+		Prefix:          req.Query.Get("prefix"),
+		KeyMarker:       keyMarker,
+		VersionIdMarker: req.Query.Get("version-id-marker"),
+		MaxKeys:         int32(maxKeys),
+		Delimiter:       req.Query.Get("delimiter"),
+		IsTruncated:     page.truncated,
+		Versions:        versions,
+		CommonPrefixes:  commonPrefixes,
+	}
+	if page.truncated {
+		result.NextKeyMarker = page.boundary
+		result.NextVersionIdMarker = nullVersionID
+	}
+	return xmlResponse(result)
+}
+
+// GetBucketVersioning отвечает, что версионирование бакета не включено: прокси не отдает
+// версии объектов, даже если бэкенды их хранят (см. ListObjectVersions). Существование
+// бакета проверяется HEAD запросом к бэкендам.
+func (f *Fetcher) GetBucketVersioning(ctx context.Context, req *apigw.S3Request) *apigw.S3Response {
+	backends := f.backendProvider.GetReadBackends()
+	if len(backends) == 0 {
+		return f.noBackendsResponse(req)
+	}
+	response, _ := f.executeFirst(ctx, req, backends, f.performHeadBucket, "HEAD_BUCKET", "NoSuchBucket", false)
+	if !isSuccessResponse(response) {
+		return response
+	}
+	return xmlResponse(VersioningConfiguration{Xmlns: "http://s3.amazonaws.com/doc/2006-03-01/"})
+}
+
+// xmlResponse возвращает ответ 200 с телом value в XML
+func xmlResponse(value any) *apigw.S3Response {
+	payload, err := xml.Marshal(value)
+	if err != nil {
+		return &apigw.S3Response{StatusCode: http.StatusInternalServerError, Error: err}
+	}
+	body := append([]byte(xml.Header), payload...)
+
+	headers := make(http.Header)
+	headers.Set("Content-Type", "application/xml")
+	headers.Set("Content-Length", fmt.Sprintf("%d", len(body)))
+	return &apigw.S3Response{
+		StatusCode: http.StatusOK,
+		Headers:    headers,
+		Body:       io.NopCloser(bytes.NewReader(body)),
+	}
+}
@@ -30,7 +30,7 @@ func (h *MockHandler) Handle(req *apigw.S3Request) *apigw.S3Response {
 		return h.handlePutObject(req)
 	case apigw.HeadObject:
 		return h.handleHeadObject(req)
-	case apigw.HeadBucket:
+	case apigw.HeadBucket, apigw.HeadService:
 		return h.handleHeadBucket(req)
 	case apigw.DeleteObject:
 		return h.handleDeleteObject(req)
@@ -0,0 +1,69 @@
+// Package backendtest - помощники тестов, которым нужен backend.Manager с бэкендами
+// на s3mock.Server. Импортируется только тестами.
+package backendtest
+
+import (
+	"fmt"
+	"testing"
+
+	"s3proxy/backend"
+	"s3proxy/internal/s3mock"
+)
+
+// BackendConfig возвращает конфигурацию бэкенда, указывающую на srv.
+// Повторы запросов в SDK отключены, чтобы тесты не зависели от backoff.
+func BackendConfig(srv *s3mock.Server) backend.BackendConfig {
+	return backend.BackendConfig{
+		Endpoint:    srv.URL,
+		Region:      "us-east-1",
+		Bucket:      srv.Bucket,
+		AccessKey:   "mock-access-key",
+		SecretKey:   "mock-secret-key",
+		MaxAttempts: 1,
+	}
+}
+
+// NewManager создает Manager по конфигурации, бэкенды которой обычно указывают
+// на s3mock.Server. Если конфигурация менеджера не задана, все бэкенды стартуют в
+// состоянии UP. Бэкендам без max_attempts повторы SDK отключаются.
+// Активные проверки не запускаются - при необходимости тест вызывает Start сам.
+func NewManager(cfg *backend.Config) (*backend.Manager, error) {
+	if cfg != nil {
+		if cfg.Manager == (backend.ManagerConfig{}) {
+			cfg.Manager = backend.DefaultManagerConfig()
+			cfg.Manager.InitialState = backend.StateUp
+		}
+		for id, backendConfig := range cfg.Backends {
+			if backendConfig.MaxAttempts == 0 {
+				backendConfig.MaxAttempts = 1
+				cfg.Backends[id] = backendConfig
+			}
+		}
+	}
+	return backend.NewManager(cfg)
+}
+
+// NewServers запускает count серверов с бакетом test-bucket и создает Manager с бэкендами
+// backend-1..backend-N в состоянии initialState. Серверы закрываются по завершении теста.
+func NewServers(t testing.TB, count int, initialState backend.BackendState) (*backend.Manager, []*s3mock.Server) {
+	t.Helper()
+
+	config := &backend.Config{
+		Manager:  backend.DefaultManagerConfig(),
+		Backends: make(map[string]backend.BackendConfig),
+	}
+	config.Manager.InitialState = initialState
+
+	servers := make([]*s3mock.Server, count)
+	for i := 0; i < count; i++ {
+		servers[i] = s3mock.NewServer("test-bucket")
+		t.Cleanup(servers[i].Close)
+		config.Backends[fmt.Sprintf("backend-%d", i+1)] = BackendConfig(servers[i])
+	}
+
+	manager, err := NewManager(config)
+	if err != nil {
+		t.Fatalf("Failed to create backend manager: %v", err)
+	}
+	return manager, servers
+}
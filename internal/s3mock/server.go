@@ -0,0 +1,1103 @@
+// Package s3mock - in-memory S3 сервер для тестов модулей, работающих с бэкендами.
+// Пакет внутренний и импортируется только тестами, поэтому httptest не попадает в бинарник прокси.
+package s3mock
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/md5"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"s3proxy/apigw"
+)
+
+// Server - минимальная in-memory реализация S3 API поверх httptest.Server.
+// Предназначена для тестов модулей, которым нужен настоящий S3-совместимый бэкенд
+// (fetch, replicator, routing). Поддерживается только path-style адресация.
+//
+// Реализованы операции с бакетами (HEAD/PUT/DELETE, ListObjectsV2, ListBuckets),
+// с объектами (PUT с If-Match/If-None-Match и проверкой x-amz-checksum-*, GET и HEAD с Range и условиями If-Match/If-None-Match, DELETE, теги ?tagging)
+// и multipart upload.
+type Server struct {
+	*httptest.Server
+
+	// Bucket - бакет, созданный при запуске сервера
+	Bucket string
+
+	mu           sync.Mutex
+	buckets      map[string]map[string]*Object
+	uploads      map[string]*multipartUpload
+	requests     []Request
+	intercept    func(w http.ResponseWriter, r *http.Request) bool
+	nextUploadID int
+	nextReqID    int
+}
+
+// Object - объект, хранящийся в Server
+type Object struct {
+	Data         []byte
+	ETag         string // ETag в кавычках, как его возвращает S3
+	LastModified time.Time
+	ContentType  string
+	Metadata     map[string]string // Пользовательские метаданные (без префикса x-amz-meta-)
+	Tags         map[string]string // Теги объекта (?tagging)
+}
+
+// Request - запись о запросе, полученном Server
+type Request struct {
+	Method string
+	Bucket string
+	Key    string
+	Query  url.Values
+	Header http.Header
+}
+
+// multipartUpload - незавершенная multipart-загрузка
+type multipartUpload struct {
+	bucket    string
+	key       string
+	initiated time.Time
+	parts     map[int]*Object
+}
+
+// NewServer запускает Server с одним пустым бакетом
+func NewServer(bucket string) *Server {
+	m := &Server{
+		Bucket:  bucket,
+		buckets: map[string]map[string]*Object{bucket: {}},
+		uploads: make(map[string]*multipartUpload),
+	}
+	m.Server = httptest.NewServer(http.HandlerFunc(m.serveHTTP))
+	return m
+}
+
+// NewTLSServer создает mock S3 сервер с HTTPS эндпоинтом. Сертификат сервера
+// подписан тестовым CA httptest: клиенту нужен сертификат Certificate() или отключенная проверка.
+func NewTLSServer(bucket string) *Server {
+	m := &Server{
+		Bucket:  bucket,
+		buckets: map[string]map[string]*Object{bucket: {}},
+		uploads: make(map[string]*multipartUpload),
+	}
+	m.Server = httptest.NewTLSServer(http.HandlerFunc(m.serveHTTP))
+	return m
+}
+
+// SetIntercept устанавливает перехватчик запросов. Если перехватчик вернул true,
+// запрос считается обработанным и дальше не передается. Позволяет имитировать
+// задержки, ошибки 5xx и обрывы соединения.
+func (m *Server) SetIntercept(fn func(w http.ResponseWriter, r *http.Request) bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.intercept = fn
+}
+
+// SetObject помещает объект в бакет по умолчанию в обход HTTP API.
+// Нулевое modTime означает текущее время.
+func (m *Server) SetObject(key string, data []byte, modTime time.Time) {
+	if modTime.IsZero() {
+		modTime = time.Now()
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.buckets[m.Bucket][key] = &Object{
+		Data:         append([]byte(nil), data...),
+		ETag:         md5ETag(data),
+		LastModified: modTime.UTC().Truncate(time.Second),
+		ContentType:  "binary/octet-stream",
+		Metadata:     map[string]string{},
+	}
+}
+
+// SetObjectTags заменяет теги объекта в бакете по умолчанию. Возвращает false, если объекта нет.
+func (m *Server) SetObjectTags(key string, tags map[string]string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	obj, ok := m.buckets[m.Bucket][key]
+	if ok {
+		obj.Tags = tags
+	}
+	return ok
+}
+
+// SetObjectMetadata заменяет пользовательские метаданные объекта в бакете по умолчанию
+// (ключи без префикса x-amz-meta-). Возвращает false, если объекта нет.
+func (m *Server) SetObjectMetadata(key string, metadata map[string]string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	obj, ok := m.buckets[m.Bucket][key]
+	if ok {
+		obj.Metadata = metadata
+	}
+	return ok
+}
+
+// GetObject возвращает копию объекта из бакета по умолчанию
+func (m *Server) GetObject(key string) (*Object, bool) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	obj, ok := m.buckets[m.Bucket][key]
+	if !ok {
+		return nil, false
+	}
+	cp := *obj
+	return &cp, true
+}
+
+// AddBucket создает пустой бакет, если его еще нет
+func (m *Server) AddBucket(name string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.buckets[name]; !ok {
+		m.buckets[name] = make(map[string]*Object)
+	}
+}
+
+// HasBucket сообщает, существует ли бакет на сервере
+func (m *Server) HasBucket(name string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, ok := m.buckets[name]
+	return ok
+}
+
+// ObjectCount возвращает количество объектов в бакете по умолчанию
+func (m *Server) ObjectCount() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.buckets[m.Bucket])
+}
+
+// PendingUploads возвращает количество незавершенных multipart-загрузок
+func (m *Server) PendingUploads() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return len(m.uploads)
+}
+
+// Requests возвращает копию журнала полученных запросов
+func (m *Server) Requests() []Request {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return append([]Request(nil), m.requests...)
+}
+
+// CountRequests возвращает количество запросов с указанным HTTP-методом
+func (m *Server) CountRequests(method string) int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	count := 0
+	for _, r := range m.requests {
+		if r.Method == method {
+			count++
+		}
+	}
+	return count
+}
+
+// ResetRequests очищает журнал запросов
+func (m *Server) ResetRequests() {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.requests = nil
+}
+
+// serveHTTP разбирает path-style запрос и вызывает соответствующий обработчик
+func (m *Server) serveHTTP(w http.ResponseWriter, r *http.Request) {
+	bucket, key := splitMockPath(r.URL.Path)
+
+	m.mu.Lock()
+	m.requests = append(m.requests, Request{
+		Method: r.Method,
+		Bucket: bucket,
+		Key:    key,
+		Query:  r.URL.Query(),
+		Header: r.Header.Clone(),
+	})
+	m.nextReqID++
+	w.Header().Set("x-amz-request-id", fmt.Sprintf("MOCK%012d", m.nextReqID))
+	intercept := m.intercept
+	m.mu.Unlock()
+
+	if intercept != nil && intercept(w, r) {
+		return
+	}
+
+	query := r.URL.Query()
+	switch {
+	case bucket == "":
+		if r.Method == http.MethodGet {
+			m.listBuckets(w)
+			return
+		}
+	case key == "":
+		switch r.Method {
+		case http.MethodHead:
+			m.headBucket(w, bucket)
+			return
+		case http.MethodPut:
+			m.createBucket(w, r, bucket)
+			return
+		case http.MethodDelete:
+			m.deleteBucket(w, r, bucket)
+			return
+		case http.MethodGet:
+			if _, ok := query["uploads"]; ok {
+				m.listMultipartUploads(w, r, bucket)
+				return
+			}
+			m.listObjectsV2(w, r, bucket)
+			return
+		}
+	default:
+		switch r.Method {
+		case http.MethodPut:
+			if _, ok := query["tagging"]; ok {
+				m.putObjectTagging(w, r, bucket, key)
+				return
+			}
+			if query.Get("uploadId") != "" {
+				m.uploadPart(w, r, bucket, key)
+				return
+			}
+			m.putObject(w, r, bucket, key)
+			return
+		case http.MethodGet, http.MethodHead:
+			if _, ok := query["tagging"]; ok && r.Method == http.MethodGet {
+				m.getObjectTagging(w, r, bucket, key)
+				return
+			}
+			if query.Get("uploadId") != "" && r.Method == http.MethodGet {
+				m.listParts(w, r, bucket, key)
+				return
+			}
+			m.getObject(w, r, bucket, key)
+			return
+		case http.MethodDelete:
+			if _, ok := query["tagging"]; ok {
+				m.putObjectTagging(w, r, bucket, key)
+				return
+			}
+			if query.Get("uploadId") != "" {
+				m.abortMultipartUpload(w, r, bucket, key)
+				return
+			}
+			m.deleteObject(w, r, bucket, key)
+			return
+		case http.MethodPost:
+			if _, ok := query["uploads"]; ok {
+				m.createMultipartUpload(w, r, bucket, key)
+				return
+			}
+			if query.Get("uploadId") != "" {
+				m.completeMultipartUpload(w, r, bucket, key)
+				return
+			}
+		}
+	}
+
+	writeMockError(w, r, http.StatusNotImplemented, "NotImplemented", "operation is not implemented by mock server")
+}
+
+func (m *Server) listBuckets(w http.ResponseWriter) {
+	type bucketXML struct {
+		Name         string `xml:"Name"`
+		CreationDate string `xml:"CreationDate"`
+	}
+	type result struct {
+		XMLName xml.Name    `xml:"ListAllMyBucketsResult"`
+		Buckets []bucketXML `xml:"Buckets>Bucket"`
+	}
+
+	m.mu.Lock()
+	names := make([]string, 0, len(m.buckets))
+	for name := range m.buckets {
+		names = append(names, name)
+	}
+	m.mu.Unlock()
+	sort.Strings(names)
+
+	res := result{}
+	for _, name := range names {
+		res.Buckets = append(res.Buckets, bucketXML{Name: name, CreationDate: formatMockTime(time.Now())})
+	}
+	writeMockXML(w, http.StatusOK, res)
+}
+
+func (m *Server) headBucket(w http.ResponseWriter, bucket string) {
+	m.mu.Lock()
+	_, ok := m.buckets[bucket]
+	m.mu.Unlock()
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func (m *Server) createBucket(w http.ResponseWriter, r *http.Request, bucket string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.buckets[bucket]; ok {
+		writeMockError(w, r, http.StatusConflict, "BucketAlreadyOwnedByYou", "bucket already exists")
+		return
+	}
+	m.buckets[bucket] = map[string]*Object{}
+	w.Header().Set("Location", "/"+bucket)
+	w.WriteHeader(http.StatusOK)
+}
+
+func (m *Server) deleteBucket(w http.ResponseWriter, r *http.Request, bucket string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	objects, ok := m.buckets[bucket]
+	if !ok {
+		writeMockError(w, r, http.StatusNotFound, "NoSuchBucket", "bucket does not exist")
+		return
+	}
+	if len(objects) > 0 {
+		writeMockError(w, r, http.StatusConflict, "BucketNotEmpty", "bucket is not empty")
+		return
+	}
+	delete(m.buckets, bucket)
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (m *Server) listObjectsV2(w http.ResponseWriter, r *http.Request, bucket string) {
+	type contentXML struct {
+		Key          string `xml:"Key"`
+		LastModified string `xml:"LastModified"`
+		ETag         string `xml:"ETag"`
+		Size         int64  `xml:"Size"`
+		StorageClass string `xml:"StorageClass"`
+	}
+	type prefixXML struct {
+		Prefix string `xml:"Prefix"`
+	}
+	type result struct {
+		XMLName               xml.Name     `xml:"ListBucketResult"`
+		Name                  string       `xml:"Name"`
+		Prefix                string       `xml:"Prefix"`
+		Delimiter             string       `xml:"Delimiter,omitempty"`
+		MaxKeys               int          `xml:"MaxKeys"`
+		KeyCount              int          `xml:"KeyCount"`
+		IsTruncated           bool         `xml:"IsTruncated"`
+		ContinuationToken     string       `xml:"ContinuationToken,omitempty"`
+		NextContinuationToken string       `xml:"NextContinuationToken,omitempty"`
+		StartAfter            string       `xml:"StartAfter,omitempty"`
+		Contents              []contentXML `xml:"Contents"`
+		CommonPrefixes        []prefixXML  `xml:"CommonPrefixes"`
+	}
+
+	query := r.URL.Query()
+	prefix := query.Get("prefix")
+	delimiter := query.Get("delimiter")
+	maxKeys := 1000
+	if v := query.Get("max-keys"); v != "" {
+		if n, err := strconv.Atoi(v); err == nil && n >= 0 {
+			maxKeys = n
+		}
+	}
+	after := query.Get("start-after")
+	if token := query.Get("continuation-token"); token != "" {
+		decoded, err := base64.StdEncoding.DecodeString(token)
+		if err != nil {
+			writeMockError(w, r, http.StatusBadRequest, "InvalidArgument", "invalid continuation token")
+			return
+		}
+		after = string(decoded)
+	}
+
+	m.mu.Lock()
+	objects, ok := m.buckets[bucket]
+	if !ok {
+		m.mu.Unlock()
+		writeMockError(w, r, http.StatusNotFound, "NoSuchBucket", "bucket does not exist")
+		return
+	}
+	keys := make([]string, 0, len(objects))
+	for k := range objects {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	res := result{
+		Name:              bucket,
+		Prefix:            prefix,
+		Delimiter:         delimiter,
+		MaxKeys:           maxKeys,
+		ContinuationToken: query.Get("continuation-token"),
+		StartAfter:        query.Get("start-after"),
+	}
+	seenPrefixes := make(map[string]bool)
+	last := ""
+	for _, k := range keys {
+		if !strings.HasPrefix(k, prefix) || k <= after {
+			continue
+		}
+		entry := k
+		isPrefix := false
+		if delimiter != "" {
+			if idx := strings.Index(k[len(prefix):], delimiter); idx >= 0 {
+				entry = k[:len(prefix)+idx+len(delimiter)]
+				isPrefix = true
+			}
+		}
+		if isPrefix && (seenPrefixes[entry] || entry <= after) {
+			continue
+		}
+		if res.KeyCount >= maxKeys {
+			res.IsTruncated = true
+			break
+		}
+		if isPrefix {
+			seenPrefixes[entry] = true
+			res.CommonPrefixes = append(res.CommonPrefixes, prefixXML{Prefix: entry})
+			last = entry
+		} else {
+			obj := objects[k]
+			res.Contents = append(res.Contents, contentXML{
+				Key:          k,
+				LastModified: formatMockTime(obj.LastModified),
+				ETag:         obj.ETag,
+				Size:         int64(len(obj.Data)),
+				StorageClass: "STANDARD",
+			})
+			last = k
+		}
+		res.KeyCount++
+	}
+	m.mu.Unlock()
+
+	if res.IsTruncated {
+		res.NextContinuationToken = base64.StdEncoding.EncodeToString([]byte(last))
+	}
+	writeMockXML(w, http.StatusOK, res)
+}
+
+func (m *Server) putObject(w http.ResponseWriter, r *http.Request, bucket, key string) {
+	data, err := readMockBody(r)
+	if err != nil {
+		writeMockError(w, r, http.StatusBadRequest, "IncompleteBody", err.Error())
+		return
+	}
+	if header := mockChecksumMismatch(r, data); header != "" {
+		writeMockError(w, r, http.StatusBadRequest, "BadDigest", "the "+header+" you specified did not match the calculated checksum")
+		return
+	}
+
+	obj := &Object{
+		Data:         data,
+		ETag:         md5ETag(data),
+		LastModified: time.Now().UTC().Truncate(time.Second),
+		ContentType:  r.Header.Get("Content-Type"),
+		Metadata:     extractMockMetadata(r.Header),
+	}
+	if obj.ContentType == "" {
+		obj.ContentType = "binary/octet-stream"
+	}
+
+	m.mu.Lock()
+	objects, ok := m.buckets[bucket]
+	var conditionErr string
+	if ok {
+		conditionErr = mockWriteCondition(r, objects[key])
+		if conditionErr == "" {
+			objects[key] = obj
+		}
+	}
+	m.mu.Unlock()
+
+	if !ok {
+		writeMockError(w, r, http.StatusNotFound, "NoSuchBucket", "bucket does not exist")
+		return
+	}
+	switch conditionErr {
+	case "NoSuchKey":
+		writeMockError(w, r, http.StatusNotFound, "NoSuchKey", "the specified key does not exist")
+		return
+	case "PreconditionFailed":
+		writeMockError(w, r, http.StatusPreconditionFailed, "PreconditionFailed", "at least one of the pre-conditions you specified did not hold")
+		return
+	}
+	w.Header().Set("ETag", obj.ETag)
+	w.WriteHeader(http.StatusOK)
+}
+
+// mockChecksumMismatch сверяет тело с заголовками x-amz-checksum-*, как S3. Возвращает
+// заголовок, сумма которого не совпала, или пустую строку.
+func mockChecksumMismatch(r *http.Request, data []byte) string {
+	for _, header := range apigw.ChecksumHeaders {
+		expected := r.Header.Get(header)
+		if expected == "" {
+			continue
+		}
+		hash := apigw.NewChecksumHash(header)
+		hash.Write(data)
+		if base64.StdEncoding.EncodeToString(hash.Sum(nil)) != expected {
+			return header
+		}
+	}
+	return ""
+}
+
+// mockWriteCondition проверяет условия записи If-None-Match: * и If-Match: <etag>
+// относительно текущего объекта. Возвращает код ошибки S3 или пустую строку.
+func mockWriteCondition(r *http.Request, current *Object) string {
+	if r.Header.Get("If-None-Match") == "*" && current != nil {
+		return "PreconditionFailed"
+	}
+	if ifMatch := r.Header.Get("If-Match"); ifMatch != "" {
+		if current == nil {
+			return "NoSuchKey"
+		}
+		if ifMatch != "*" && strings.Trim(ifMatch, `"`) != strings.Trim(current.ETag, `"`) {
+			return "PreconditionFailed"
+		}
+	}
+	return ""
+}
+
+func (m *Server) getObject(w http.ResponseWriter, r *http.Request, bucket, key string) {
+	m.mu.Lock()
+	objects, bucketExists := m.buckets[bucket]
+	var obj *Object
+	if bucketExists {
+		obj = objects[key]
+	}
+	m.mu.Unlock()
+
+	if !bucketExists {
+		writeMockError(w, r, http.StatusNotFound, "NoSuchBucket", "bucket does not exist")
+		return
+	}
+	if obj == nil {
+		writeMockError(w, r, http.StatusNotFound, "NoSuchKey", "the specified key does not exist")
+		return
+	}
+
+	h := w.Header()
+	h.Set("Content-Type", obj.ContentType)
+	h.Set("ETag", obj.ETag)
+	h.Set("Last-Modified", obj.LastModified.Format(http.TimeFormat))
+	h.Set("Accept-Ranges", "bytes")
+	for k, v := range obj.Metadata {
+		h.Set("x-amz-meta-"+k, v)
+	}
+
+	// Условия GET/HEAD: If-Match проверяется раньше If-None-Match, как в S3
+	if ifMatch := r.Header.Get("If-Match"); ifMatch != "" && ifMatch != "*" && ifMatch != obj.ETag {
+		writeMockError(w, r, http.StatusPreconditionFailed, "PreconditionFailed", "at least one of the pre-conditions you specified did not hold")
+		return
+	}
+	if ifNoneMatch := r.Header.Get("If-None-Match"); ifNoneMatch != "" && (ifNoneMatch == "*" || ifNoneMatch == obj.ETag) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	data := obj.Data
+	status := http.StatusOK
+	if rangeHeader := r.Header.Get("Range"); rangeHeader != "" {
+		start, end, ok := parseMockRange(rangeHeader, int64(len(data)))
+		if !ok {
+			writeMockError(w, r, http.StatusRequestedRangeNotSatisfiable, "InvalidRange", "the requested range is not satisfiable")
+			return
+		}
+		h.Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, len(data)))
+		data = data[start : end+1]
+		status = http.StatusPartialContent
+	}
+
+	h.Set("Content-Length", strconv.Itoa(len(data)))
+	w.WriteHeader(status)
+	if r.Method != http.MethodHead {
+		w.Write(data)
+	}
+}
+
+func (m *Server) deleteObject(w http.ResponseWriter, r *http.Request, bucket, key string) {
+	m.mu.Lock()
+	objects, ok := m.buckets[bucket]
+	if ok {
+		delete(objects, key)
+	}
+	m.mu.Unlock()
+
+	if !ok {
+		writeMockError(w, r, http.StatusNotFound, "NoSuchBucket", "bucket does not exist")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// mockTagging - тело GET/PUT ?tagging
+type mockTagging struct {
+	XMLName xml.Name  `xml:"Tagging"`
+	TagSet  []mockTag `xml:"TagSet>Tag"`
+}
+
+type mockTag struct {
+	Key   string `xml:"Key"`
+	Value string `xml:"Value"`
+}
+
+func (m *Server) getObjectTagging(w http.ResponseWriter, r *http.Request, bucket, key string) {
+	m.mu.Lock()
+	var obj *Object
+	if objects, ok := m.buckets[bucket]; ok {
+		obj = objects[key]
+	}
+	var res mockTagging
+	if obj != nil {
+		keys := make([]string, 0, len(obj.Tags))
+		for k := range obj.Tags {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			res.TagSet = append(res.TagSet, mockTag{Key: k, Value: obj.Tags[k]})
+		}
+	}
+	m.mu.Unlock()
+
+	if obj == nil {
+		writeMockError(w, r, http.StatusNotFound, "NoSuchKey", "the specified key does not exist")
+		return
+	}
+	writeMockXML(w, http.StatusOK, res)
+}
+
+// putObjectTagging заменяет теги объекта; для DELETE ?tagging теги удаляются
+func (m *Server) putObjectTagging(w http.ResponseWriter, r *http.Request, bucket, key string) {
+	tags := make(map[string]string)
+	if r.Method == http.MethodPut {
+		var req mockTagging
+		data, err := readMockBody(r)
+		if err == nil {
+			err = xml.Unmarshal(data, &req)
+		}
+		if err != nil {
+			writeMockError(w, r, http.StatusBadRequest, "MalformedXML", "the XML you provided was not well-formed")
+			return
+		}
+		for _, tag := range req.TagSet {
+			tags[tag.Key] = tag.Value
+		}
+	}
+
+	m.mu.Lock()
+	var obj *Object
+	if objects, ok := m.buckets[bucket]; ok {
+		obj = objects[key]
+	}
+	if obj != nil {
+		obj.Tags = tags
+	}
+	m.mu.Unlock()
+
+	if obj == nil {
+		writeMockError(w, r, http.StatusNotFound, "NoSuchKey", "the specified key does not exist")
+		return
+	}
+	if r.Method == http.MethodDelete {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+func (m *Server) createMultipartUpload(w http.ResponseWriter, r *http.Request, bucket, key string) {
+	type result struct {
+		XMLName  xml.Name `xml:"InitiateMultipartUploadResult"`
+		Bucket   string   `xml:"Bucket"`
+		Key      string   `xml:"Key"`
+		UploadID string   `xml:"UploadId"`
+	}
+
+	m.mu.Lock()
+	if _, ok := m.buckets[bucket]; !ok {
+		m.mu.Unlock()
+		writeMockError(w, r, http.StatusNotFound, "NoSuchBucket", "bucket does not exist")
+		return
+	}
+	m.nextUploadID++
+	uploadID := fmt.Sprintf("mock-upload-%d", m.nextUploadID)
+	m.uploads[uploadID] = &multipartUpload{
+		bucket:    bucket,
+		key:       key,
+		initiated: time.Now().UTC(),
+		parts:     make(map[int]*Object),
+	}
+	m.mu.Unlock()
+
+	writeMockXML(w, http.StatusOK, result{Bucket: bucket, Key: key, UploadID: uploadID})
+}
+
+func (m *Server) uploadPart(w http.ResponseWriter, r *http.Request, bucket, key string) {
+	query := r.URL.Query()
+	partNumber, err := strconv.Atoi(query.Get("partNumber"))
+	if err != nil || partNumber < 1 || partNumber > 10000 {
+		writeMockError(w, r, http.StatusBadRequest, "InvalidArgument", "part number must be an integer between 1 and 10000")
+		return
+	}
+
+	data, err := readMockBody(r)
+	if err != nil {
+		writeMockError(w, r, http.StatusBadRequest, "IncompleteBody", err.Error())
+		return
+	}
+
+	m.mu.Lock()
+	upload, ok := m.uploads[query.Get("uploadId")]
+	if ok && upload.bucket == bucket && upload.key == key {
+		upload.parts[partNumber] = &Object{
+			Data:         data,
+			ETag:         md5ETag(data),
+			LastModified: time.Now().UTC(),
+		}
+	}
+	m.mu.Unlock()
+
+	if !ok || upload.bucket != bucket || upload.key != key {
+		writeMockError(w, r, http.StatusNotFound, "NoSuchUpload", "the specified upload does not exist")
+		return
+	}
+	w.Header().Set("ETag", md5ETag(data))
+	w.WriteHeader(http.StatusOK)
+}
+
+func (m *Server) completeMultipartUpload(w http.ResponseWriter, r *http.Request, bucket, key string) {
+	type partXML struct {
+		PartNumber int    `xml:"PartNumber"`
+		ETag       string `xml:"ETag"`
+	}
+	type request struct {
+		Parts []partXML `xml:"Part"`
+	}
+	type result struct {
+		XMLName xml.Name `xml:"CompleteMultipartUploadResult"`
+		Bucket  string   `xml:"Bucket"`
+		Key     string   `xml:"Key"`
+		ETag    string   `xml:"ETag"`
+	}
+
+	body, err := readMockBody(r)
+	if err != nil {
+		writeMockError(w, r, http.StatusBadRequest, "IncompleteBody", err.Error())
+		return
+	}
+	var req request
+	if len(bytes.TrimSpace(body)) > 0 {
+		if err := xml.Unmarshal(body, &req); err != nil {
+			writeMockError(w, r, http.StatusBadRequest, "MalformedXML", err.Error())
+			return
+		}
+	}
+
+	uploadID := r.URL.Query().Get("uploadId")
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	upload, ok := m.uploads[uploadID]
+	if !ok || upload.bucket != bucket || upload.key != key {
+		writeMockError(w, r, http.StatusNotFound, "NoSuchUpload", "the specified upload does not exist")
+		return
+	}
+
+	// Упрощение: пустой список частей означает "все загруженные части по порядку"
+	if len(req.Parts) == 0 {
+		numbers := make([]int, 0, len(upload.parts))
+		for n := range upload.parts {
+			numbers = append(numbers, n)
+		}
+		sort.Ints(numbers)
+		for _, n := range numbers {
+			req.Parts = append(req.Parts, partXML{PartNumber: n, ETag: upload.parts[n].ETag})
+		}
+	}
+
+	var data []byte
+	digests := md5.New()
+	for _, p := range req.Parts {
+		part, ok := upload.parts[p.PartNumber]
+		if !ok || (p.ETag != "" && strings.Trim(p.ETag, `"`) != strings.Trim(part.ETag, `"`)) {
+			writeMockError(w, r, http.StatusBadRequest, "InvalidPart", fmt.Sprintf("part %d is not valid", p.PartNumber))
+			return
+		}
+		data = append(data, part.Data...)
+		sum, _ := hex.DecodeString(strings.Trim(part.ETag, `"`))
+		digests.Write(sum)
+	}
+
+	etag := fmt.Sprintf(`"%s-%d"`, hex.EncodeToString(digests.Sum(nil)), len(req.Parts))
+	m.buckets[bucket][key] = &Object{
+		Data:         data,
+		ETag:         etag,
+		LastModified: time.Now().UTC().Truncate(time.Second),
+		ContentType:  "binary/octet-stream",
+		Metadata:     map[string]string{},
+	}
+	delete(m.uploads, uploadID)
+
+	writeMockXML(w, http.StatusOK, result{Bucket: bucket, Key: key, ETag: etag})
+}
+
+func (m *Server) abortMultipartUpload(w http.ResponseWriter, r *http.Request, bucket, key string) {
+	uploadID := r.URL.Query().Get("uploadId")
+
+	m.mu.Lock()
+	upload, ok := m.uploads[uploadID]
+	if ok && upload.bucket == bucket && upload.key == key {
+		delete(m.uploads, uploadID)
+	}
+	m.mu.Unlock()
+
+	if !ok || upload.bucket != bucket || upload.key != key {
+		writeMockError(w, r, http.StatusNotFound, "NoSuchUpload", "the specified upload does not exist")
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (m *Server) listMultipartUploads(w http.ResponseWriter, r *http.Request, bucket string) {
+	type uploadXML struct {
+		Key       string `xml:"Key"`
+		UploadID  string `xml:"UploadId"`
+		Initiated string `xml:"Initiated"`
+	}
+	type result struct {
+		XMLName     xml.Name    `xml:"ListMultipartUploadsResult"`
+		Bucket      string      `xml:"Bucket"`
+		Prefix      string      `xml:"Prefix"`
+		IsTruncated bool        `xml:"IsTruncated"`
+		Uploads     []uploadXML `xml:"Upload"`
+	}
+
+	prefix := r.URL.Query().Get("prefix")
+	res := result{Bucket: bucket, Prefix: prefix}
+
+	m.mu.Lock()
+	if _, ok := m.buckets[bucket]; !ok {
+		m.mu.Unlock()
+		writeMockError(w, r, http.StatusNotFound, "NoSuchBucket", "bucket does not exist")
+		return
+	}
+	for id, upload := range m.uploads {
+		if upload.bucket == bucket && strings.HasPrefix(upload.key, prefix) {
+			res.Uploads = append(res.Uploads, uploadXML{
+				Key:       upload.key,
+				UploadID:  id,
+				Initiated: formatMockTime(upload.initiated),
+			})
+		}
+	}
+	m.mu.Unlock()
+
+	sort.Slice(res.Uploads, func(i, j int) bool {
+		if res.Uploads[i].Key != res.Uploads[j].Key {
+			return res.Uploads[i].Key < res.Uploads[j].Key
+		}
+		return res.Uploads[i].UploadID < res.Uploads[j].UploadID
+	})
+	writeMockXML(w, http.StatusOK, res)
+}
+
+func (m *Server) listParts(w http.ResponseWriter, r *http.Request, bucket, key string) {
+	type partXML struct {
+		PartNumber   int    `xml:"PartNumber"`
+		LastModified string `xml:"LastModified"`
+		ETag         string `xml:"ETag"`
+		Size         int64  `xml:"Size"`
+	}
+	type result struct {
+		XMLName     xml.Name  `xml:"ListPartsResult"`
+		Bucket      string    `xml:"Bucket"`
+		Key         string    `xml:"Key"`
+		UploadID    string    `xml:"UploadId"`
+		IsTruncated bool      `xml:"IsTruncated"`
+		Parts       []partXML `xml:"Part"`
+	}
+
+	uploadID := r.URL.Query().Get("uploadId")
+	res := result{Bucket: bucket, Key: key, UploadID: uploadID}
+
+	m.mu.Lock()
+	upload, ok := m.uploads[uploadID]
+	if ok && upload.bucket == bucket && upload.key == key {
+		for n, part := range upload.parts {
+			res.Parts = append(res.Parts, partXML{
+				PartNumber:   n,
+				LastModified: formatMockTime(part.LastModified),
+				ETag:         part.ETag,
+				Size:         int64(len(part.Data)),
+			})
+		}
+	}
+	m.mu.Unlock()
+
+	if !ok || upload.bucket != bucket || upload.key != key {
+		writeMockError(w, r, http.StatusNotFound, "NoSuchUpload", "the specified upload does not exist")
+		return
+	}
+	sort.Slice(res.Parts, func(i, j int) bool { return res.Parts[i].PartNumber < res.Parts[j].PartNumber })
+	writeMockXML(w, http.StatusOK, res)
+}
+
+// splitMockPath разбивает path-style путь на бакет и ключ
+func splitMockPath(path string) (bucket, key string) {
+	path = strings.TrimPrefix(path, "/")
+	if idx := strings.Index(path, "/"); idx >= 0 {
+		return path[:idx], path[idx+1:]
+	}
+	return path, ""
+}
+
+// readMockBody читает тело запроса, при необходимости декодируя aws-chunked
+func readMockBody(r *http.Request) ([]byte, error) {
+	isChunked := strings.Contains(r.Header.Get("Content-Encoding"), "aws-chunked") ||
+		strings.HasPrefix(r.Header.Get("X-Amz-Content-Sha256"), "STREAMING-")
+	if !isChunked {
+		return io.ReadAll(r.Body)
+	}
+	return decodeAWSChunked(r.Body)
+}
+
+// decodeAWSChunked декодирует тело в формате aws-chunked: последовательность
+// "<hex-размер>[;chunk-signature=...]\r\n<данные>\r\n", завершающаяся чанком
+// нулевого размера и необязательными трейлерами.
+func decodeAWSChunked(body io.Reader) ([]byte, error) {
+	reader := bufio.NewReader(body)
+	var out bytes.Buffer
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return nil, fmt.Errorf("malformed aws-chunked body: %w", err)
+		}
+		sizeStr := strings.TrimSpace(line)
+		if idx := strings.Index(sizeStr, ";"); idx >= 0 {
+			sizeStr = sizeStr[:idx]
+		}
+		size, err := strconv.ParseInt(sizeStr, 16, 64)
+		if err != nil {
+			return nil, fmt.Errorf("malformed aws-chunked chunk size %q", sizeStr)
+		}
+		if size == 0 {
+			// Трейлеры (например, x-amz-checksum-crc32) игнорируем
+			io.Copy(io.Discard, reader)
+			return out.Bytes(), nil
+		}
+		if _, err := io.CopyN(&out, reader, size); err != nil {
+			return nil, fmt.Errorf("malformed aws-chunked body: %w", err)
+		}
+		if _, err := reader.ReadString('\n'); err != nil {
+			return nil, fmt.Errorf("malformed aws-chunked body: %w", err)
+		}
+	}
+}
+
+// parseMockRange разбирает заголовок Range для одного диапазона байт
+func parseMockRange(header string, size int64) (start, end int64, ok bool) {
+	spec, found := strings.CutPrefix(header, "bytes=")
+	if !found || strings.Contains(spec, ",") {
+		return 0, 0, false
+	}
+	startStr, endStr, found := strings.Cut(spec, "-")
+	if !found {
+		return 0, 0, false
+	}
+
+	if startStr == "" {
+		// Суффиксный диапазон: последние N байт
+		n, err := strconv.ParseInt(endStr, 10, 64)
+		if err != nil || n <= 0 {
+			return 0, 0, false
+		}
+		if n > size {
+			n = size
+		}
+		return size - n, size - 1, size > 0
+	}
+
+	start, err := strconv.ParseInt(startStr, 10, 64)
+	if err != nil || start >= size {
+		return 0, 0, false
+	}
+	end = size - 1
+	if endStr != "" {
+		end, err = strconv.ParseInt(endStr, 10, 64)
+		if err != nil || end < start {
+			return 0, 0, false
+		}
+		if end >= size {
+			end = size - 1
+		}
+	}
+	return start, end, true
+}
+
+// extractMockMetadata извлекает пользовательские метаданные x-amz-meta-*
+func extractMockMetadata(header http.Header) map[string]string {
+	metadata := make(map[string]string)
+	for name, values := range header {
+		lower := strings.ToLower(name)
+		if strings.HasPrefix(lower, "x-amz-meta-") && len(values) > 0 {
+			metadata[strings.TrimPrefix(lower, "x-amz-meta-")] = values[0]
+		}
+	}
+	return metadata
+}
+
+func md5ETag(data []byte) string {
+	sum := md5.Sum(data)
+	return `"` + hex.EncodeToString(sum[:]) + `"`
+}
+
+func formatMockTime(t time.Time) string {
+	return t.UTC().Format("2006-01-02T15:04:05.000Z")
+}
+
+func writeMockXML(w http.ResponseWriter, status int, v interface{}) {
+	data, err := xml.Marshal(v)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/xml")
+	w.WriteHeader(status)
+	w.Write([]byte(xml.Header))
+	w.Write(data)
+}
+
+// writeMockError отправляет ошибку в формате S3. Для HEAD-запросов тело не отправляется.
+func writeMockError(w http.ResponseWriter, r *http.Request, status int, code, message string) {
+	type errorXML struct {
+		XMLName   xml.Name `xml:"Error"`
+		Code      string   `xml:"Code"`
+		Message   string   `xml:"Message"`
+		Resource  string   `xml:"Resource"`
+		RequestID string   `xml:"RequestId"`
+	}
+	if r.Method == http.MethodHead {
+		w.WriteHeader(status)
+		return
+	}
+	writeMockXML(w, status, errorXML{
+		Code:      code,
+		Message:   message,
+		Resource:  r.URL.Path,
+		RequestID: w.Header().Get("x-amz-request-id"),
+	})
+}
@@ -2,6 +2,7 @@ package logger
 
 import (
 	"fmt"
+	"io"
 	"log"
 	"os"
 	"strings"
@@ -68,6 +69,11 @@ func (l *Logger) SetLevel(level LogLevel) {
 	l.level = level
 }
 
+// SetOutput устанавливает назначение вывода логов
+func (l *Logger) SetOutput(w io.Writer) {
+	l.logger.SetOutput(w)
+}
+
 // GetLevel возвращает текущий уровень логирования
 func (l *Logger) GetLevel() LogLevel {
 	return l.level
@@ -109,6 +115,11 @@ func SetGlobalLevel(level LogLevel) {
 	globalLogger.SetLevel(level)
 }
 
+// SetGlobalOutput устанавливает назначение вывода глобального логгера
+func SetGlobalOutput(w io.Writer) {
+	globalLogger.SetOutput(w)
+}
+
 // GetGlobalLevel возвращает уровень глобального логгера
 func GetGlobalLevel() LogLevel {
 	return globalLogger.GetLevel()
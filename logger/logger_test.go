@@ -3,6 +3,8 @@ package logger
 import (
 	"bytes"
 	"log"
+	"net/http"
+	"net/url"
 	"strings"
 	"testing"
 )
@@ -160,3 +162,50 @@ func TestLogLevelString(t *testing.T) {
 		}
 	}
 }
+
+func TestRedactHeaders(t *testing.T) {
+	headers := http.Header{}
+	headers.Set("Authorization", "AWS4-HMAC-SHA256 Credential=AKID/20240101/us-east-1/s3/aws4_request, SignedHeaders=host, Signature=deadbeef")
+	headers.Set("X-Amz-Content-Sha256", "UNSIGNED-PAYLOAD")
+	headers.Set("X-Amz-Security-Token", "session-token")
+	headers.Set("Content-Type", "text/plain")
+
+	redacted := RedactHeaders(headers)
+
+	for _, name := range []string{"Authorization", "X-Amz-Content-Sha256", "X-Amz-Security-Token"} {
+		if got := redacted.Get(name); got != RedactedValue {
+			t.Errorf("Expected %s to be redacted, got %q", name, got)
+		}
+	}
+	if got := redacted.Get("Content-Type"); got != "text/plain" {
+		t.Errorf("Expected Content-Type to be kept, got %q", got)
+	}
+	// Исходные заголовки не изменяются
+	if !strings.Contains(headers.Get("Authorization"), "Signature=deadbeef") {
+		t.Error("Original headers must not be modified")
+	}
+}
+
+func TestAddRedactedHeaders(t *testing.T) {
+	AddRedactedHeaders("x-custom-secret")
+
+	headers := http.Header{}
+	headers.Set("X-Custom-Secret", "value")
+	if got := RedactHeaders(headers).Get("X-Custom-Secret"); got != RedactedValue {
+		t.Errorf("Expected configured header to be redacted, got %q", got)
+	}
+}
+
+func TestRedactQuery(t *testing.T) {
+	query := url.Values{}
+	query.Set("X-Amz-Signature", "deadbeef")
+	query.Set("X-Amz-Expires", "3600")
+
+	redacted := RedactQuery(query)
+	if got := redacted.Get("X-Amz-Signature"); got != RedactedValue {
+		t.Errorf("Expected X-Amz-Signature to be redacted, got %q", got)
+	}
+	if got := redacted.Get("X-Amz-Expires"); got != "3600" {
+		t.Errorf("Expected X-Amz-Expires to be kept, got %q", got)
+	}
+}
@@ -0,0 +1,66 @@
+package logger
+
+import (
+	"net/http"
+	"net/url"
+	"sync"
+)
+
+// RedactedValue подставляется в логи вместо значений чувствительных заголовков
+const RedactedValue = "[REDACTED]"
+
+var (
+	redactMu sync.RWMutex
+
+	// redactedHeaders - заголовки, значения которых не выводятся в логи (в канонической форме)
+	redactedHeaders = map[string]bool{
+		"Authorization":        true,
+		"X-Amz-Content-Sha256": true,
+		"X-Amz-Security-Token": true,
+	}
+
+	// redactedQueryParams - параметры presigned URL, содержащие подпись и временные учетные данные
+	redactedQueryParams = map[string]bool{
+		"X-Amz-Signature":      true,
+		"X-Amz-Security-Token": true,
+	}
+)
+
+// AddRedactedHeaders добавляет заголовки к списку скрываемых в логах
+func AddRedactedHeaders(names ...string) {
+	redactMu.Lock()
+	defer redactMu.Unlock()
+	for _, name := range names {
+		redactedHeaders[http.CanonicalHeaderKey(name)] = true
+	}
+}
+
+// RedactHeaders возвращает копию заголовков, пригодную для вывода в лог:
+// значения чувствительных заголовков заменяются на RedactedValue
+func RedactHeaders(headers http.Header) http.Header {
+	redactMu.RLock()
+	defer redactMu.RUnlock()
+
+	result := make(http.Header, len(headers))
+	for name, values := range headers {
+		if redactedHeaders[http.CanonicalHeaderKey(name)] {
+			result[name] = []string{RedactedValue}
+			continue
+		}
+		result[name] = values
+	}
+	return result
+}
+
+// RedactQuery возвращает копию query параметров с замененными подписью и токеном presigned URL
+func RedactQuery(query url.Values) url.Values {
+	result := make(url.Values, len(query))
+	for name, values := range query {
+		if redactedQueryParams[http.CanonicalHeaderKey(name)] {
+			result[name] = []string{RedactedValue}
+			continue
+		}
+		result[name] = values
+	}
+	return result
+}
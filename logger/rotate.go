@@ -0,0 +1,236 @@
+package logger
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// backupTimeFormat - формат времени ротации в имени архивного файла логов.
+// Имена архивов одного файла сортируются по времени лексикографически.
+const backupTimeFormat = "20060102T150405.000000000"
+
+// FileConfig - запись логов в файл с ротацией
+type FileConfig struct {
+	// Path - путь к файлу логов (пусто - логи выводятся в stdout)
+	Path string `yaml:"path"`
+
+	// MaxSize - размер файла в байтах, после которого он ротируется (0 - без ротации по размеру)
+	MaxSize int64 `yaml:"max_size"`
+
+	// RotateInterval - как часто файл ротируется независимо от размера (0 - без ротации по времени)
+	RotateInterval time.Duration `yaml:"rotate_interval"`
+
+	// MaxBackups - сколько архивных файлов хранить (0 - без ограничения)
+	MaxBackups int `yaml:"max_backups"`
+
+	// MaxAge - сколько хранить архивные файлы (0 - без ограничения)
+	MaxAge time.Duration `yaml:"max_age"`
+}
+
+// Enabled сообщает, задана ли запись в файл
+func (c FileConfig) Enabled() bool {
+	return c.Path != ""
+}
+
+// Validate проверяет параметры ротации
+func (c FileConfig) Validate() error {
+	if c.MaxSize < 0 {
+		return fmt.Errorf("max_size cannot be negative")
+	}
+	if c.RotateInterval < 0 {
+		return fmt.Errorf("rotate_interval cannot be negative")
+	}
+	if c.MaxBackups < 0 {
+		return fmt.Errorf("max_backups cannot be negative")
+	}
+	if c.MaxAge < 0 {
+		return fmt.Errorf("max_age cannot be negative")
+	}
+	return nil
+}
+
+// RotatingFile - io.Writer в файл логов с ротацией по размеру и времени. Текущий файл
+// при ротации переименовывается в <имя>-<время><расширение> рядом с ним, и запись
+// продолжается в новый файл с исходным именем. Безопасен для параллельного использования.
+type RotatingFile struct {
+	config FileConfig
+	now    func() time.Time
+
+	mu       sync.Mutex
+	file     *os.File
+	size     int64
+	openedAt time.Time
+}
+
+// NewRotatingFile открывает файл логов config.Path для дозаписи
+func NewRotatingFile(config FileConfig) (*RotatingFile, error) {
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+	f := &RotatingFile{config: config, now: time.Now}
+	if err := f.open(); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+// open открывает (или создает) файл логов и запоминает его текущий размер
+func (f *RotatingFile) open() error {
+	if dir := filepath.Dir(f.config.Path); dir != "" {
+		if err := os.MkdirAll(dir, 0o755); err != nil {
+			return fmt.Errorf("failed to create log directory: %w", err)
+		}
+	}
+	file, err := os.OpenFile(f.config.Path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o644)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to stat log file: %w", err)
+	}
+	f.file = file
+	f.size = info.Size()
+	f.openedAt = f.now()
+	return nil
+}
+
+// Write записывает p в файл, предварительно ротируя его, если запись превысит MaxSize
+// или с открытия файла прошло RotateInterval
+func (f *RotatingFile) Write(p []byte) (int, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if f.file == nil {
+		return 0, os.ErrClosed
+	}
+	if f.shouldRotate(len(p)) {
+		if err := f.rotate(); err != nil {
+			// Логгер пишет в этот же файл, поэтому ошибка ротации выводится в stderr,
+			// а запись продолжается в текущий файл
+			fmt.Fprintf(os.Stderr, "logger: failed to rotate %s: %v\n", f.config.Path, err)
+		}
+	}
+	if f.file == nil {
+		return 0, os.ErrClosed
+	}
+	n, err := f.file.Write(p)
+	f.size += int64(n)
+	return n, err
+}
+
+// shouldRotate сообщает, нужно ли ротировать непустой файл перед записью n байт
+func (f *RotatingFile) shouldRotate(n int) bool {
+	if f.size == 0 {
+		return false
+	}
+	if f.config.MaxSize > 0 && f.size+int64(n) > f.config.MaxSize {
+		return true
+	}
+	return f.config.RotateInterval > 0 && f.now().Sub(f.openedAt) >= f.config.RotateInterval
+}
+
+// rotate переименовывает текущий файл в архивный, открывает новый и удаляет лишние архивы
+func (f *RotatingFile) rotate() error {
+	if err := f.file.Close(); err != nil {
+		return err
+	}
+	f.file = nil
+	backup := f.backupName(f.now())
+	if err := os.Rename(f.config.Path, backup); err != nil {
+		if openErr := f.open(); openErr != nil {
+			return fmt.Errorf("%v; %v", err, openErr)
+		}
+		return err
+	}
+	if err := f.open(); err != nil {
+		return err
+	}
+	f.removeOldBackups()
+	return nil
+}
+
+// backupName возвращает имя архивного файла для ротации в момент t
+func (f *RotatingFile) backupName(t time.Time) string {
+	prefix, ext := f.backupPattern()
+	return prefix + t.UTC().Format(backupTimeFormat) + ext
+}
+
+// backupPattern возвращает префикс и расширение имен архивных файлов
+func (f *RotatingFile) backupPattern() (prefix, ext string) {
+	ext = filepath.Ext(f.config.Path)
+	return strings.TrimSuffix(f.config.Path, ext) + "-", ext
+}
+
+// backupFile - архивный файл логов и время его ротации
+type backupFile struct {
+	path      string
+	rotatedAt time.Time
+}
+
+// removeOldBackups удаляет архивы сверх MaxBackups и старше MaxAge
+func (f *RotatingFile) removeOldBackups() {
+	if f.config.MaxBackups == 0 && f.config.MaxAge == 0 {
+		return
+	}
+	backups, err := f.listBackups()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "logger: failed to list log backups of %s: %v\n", f.config.Path, err)
+		return
+	}
+
+	now := f.now()
+	for i, backup := range backups {
+		expired := f.config.MaxAge > 0 && now.Sub(backup.rotatedAt) > f.config.MaxAge
+		if (f.config.MaxBackups > 0 && i >= f.config.MaxBackups) || expired {
+			if err := os.Remove(backup.path); err != nil && !os.IsNotExist(err) {
+				fmt.Fprintf(os.Stderr, "logger: failed to remove log backup %s: %v\n", backup.path, err)
+			}
+		}
+	}
+}
+
+// listBackups возвращает архивные файлы логов, начиная с самого нового
+func (f *RotatingFile) listBackups() ([]backupFile, error) {
+	prefix, ext := f.backupPattern()
+	matches, err := filepath.Glob(globEscape(prefix) + "*" + globEscape(ext))
+	if err != nil {
+		return nil, err
+	}
+
+	var backups []backupFile
+	for _, path := range matches {
+		stamp := strings.TrimSuffix(strings.TrimPrefix(path, prefix), ext)
+		rotatedAt, err := time.Parse(backupTimeFormat, stamp)
+		if err != nil {
+			continue // Чужой файл с похожим именем
+		}
+		backups = append(backups, backupFile{path: path, rotatedAt: rotatedAt})
+	}
+	sort.Slice(backups, func(i, j int) bool { return backups[i].rotatedAt.After(backups[j].rotatedAt) })
+	return backups, nil
+}
+
+// globEscape экранирует метасимволы filepath.Match в пути
+func globEscape(path string) string {
+	replacer := strings.NewReplacer(`*`, `\*`, `?`, `\?`, `[`, `\[`, `\`, `\\`)
+	return replacer.Replace(path)
+}
+
+// Close закрывает файл логов
+func (f *RotatingFile) Close() error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.file == nil {
+		return nil
+	}
+	err := f.file.Close()
+	f.file = nil
+	return err
+}
@@ -0,0 +1,136 @@
+package logger
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRotatingFileRotatesBySize(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "s3proxy.log")
+	file, err := NewRotatingFile(FileConfig{Path: path, MaxSize: 100})
+	if err != nil {
+		t.Fatalf("NewRotatingFile failed: %v", err)
+	}
+	defer file.Close()
+
+	line := strings.Repeat("a", 59) + "\n"
+	for i := 0; i < 3; i++ {
+		if _, err := file.Write([]byte(line)); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+	}
+
+	// Каждая вторая строка превысила бы 100 байт: после трех записей два архива и текущий файл
+	backups, err := filepath.Glob(filepath.Join(filepath.Dir(path), "s3proxy-*.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(backups) != 2 {
+		t.Fatalf("Expected 2 backups after size threshold, got %d: %v", len(backups), backups)
+	}
+	for _, backup := range backups {
+		data, err := os.ReadFile(backup)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if string(data) != line {
+			t.Errorf("Backup %s: expected one line, got %q", backup, data)
+		}
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != line {
+		t.Errorf("Expected current file to contain the last line, got %q", data)
+	}
+}
+
+func TestRotatingFileMaxBackups(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "s3proxy.log")
+	file, err := NewRotatingFile(FileConfig{Path: path, MaxSize: 10, MaxBackups: 2})
+	if err != nil {
+		t.Fatalf("NewRotatingFile failed: %v", err)
+	}
+	defer file.Close()
+
+	for i := 0; i < 5; i++ {
+		if _, err := file.Write([]byte("0123456789")); err != nil {
+			t.Fatalf("Write failed: %v", err)
+		}
+	}
+
+	backups, _ := filepath.Glob(filepath.Join(dir, "s3proxy-*.log"))
+	if len(backups) != 2 {
+		t.Errorf("Expected 2 backups to be kept, got %d: %v", len(backups), backups)
+	}
+}
+
+func TestRotatingFileRotatesByInterval(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "s3proxy.log")
+	file, err := NewRotatingFile(FileConfig{Path: path, RotateInterval: time.Hour})
+	if err != nil {
+		t.Fatalf("NewRotatingFile failed: %v", err)
+	}
+	defer file.Close()
+
+	now := time.Now()
+	file.now = func() time.Time { return now }
+	file.openedAt = now
+
+	file.Write([]byte("first\n"))
+	now = now.Add(30 * time.Minute)
+	file.Write([]byte("second\n"))
+	if backups, _ := filepath.Glob(filepath.Join(dir, "s3proxy-*.log")); len(backups) != 0 {
+		t.Fatalf("Expected no rotation before interval, got %v", backups)
+	}
+
+	now = now.Add(30 * time.Minute)
+	file.Write([]byte("third\n"))
+	backups, _ := filepath.Glob(filepath.Join(dir, "s3proxy-*.log"))
+	if len(backups) != 1 {
+		t.Fatalf("Expected rotation after interval, got %v", backups)
+	}
+	if data, _ := os.ReadFile(path); string(data) != "third\n" {
+		t.Errorf("Expected new file to contain only the last line, got %q", data)
+	}
+}
+
+func TestLoggerOutputToRotatingFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "s3proxy.log")
+	file, err := NewRotatingFile(FileConfig{Path: path})
+	if err != nil {
+		t.Fatalf("NewRotatingFile failed: %v", err)
+	}
+	defer file.Close()
+
+	logger := &Logger{level: INFO, logger: log.New(os.Stdout, "", log.LstdFlags)}
+	logger.SetOutput(file)
+	logger.Info("written to file")
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(data), "[INFO] written to file") {
+		t.Errorf("Expected log line in file, got %q", data)
+	}
+}
+
+func TestFileConfigValidate(t *testing.T) {
+	if err := (FileConfig{Path: "x.log", MaxSize: -1}).Validate(); err == nil {
+		t.Error("Expected error for negative max_size")
+	}
+	if err := (FileConfig{Path: "x.log", MaxAge: -time.Hour}).Validate(); err == nil {
+		t.Error("Expected error for negative max_age")
+	}
+	if err := (FileConfig{Path: "x.log", MaxSize: 1 << 20, MaxBackups: 3, MaxAge: time.Hour}).Validate(); err != nil {
+		t.Errorf("Unexpected error: %v", err)
+	}
+}
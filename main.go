@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"log"
 	"os"
@@ -18,6 +19,7 @@ import (
 	"s3proxy/monitoring"
 	"s3proxy/replicator"
 	"s3proxy/routing"
+	"s3proxy/tracing"
 )
 
 func main() {
@@ -53,18 +55,38 @@ func main() {
 		os.Exit(1)
 	}
 
-	// Применяем переопределения из командной строки
-	applyCommandLineOverrides(config,
-		*listenAddr, *tlsCert, *tlsKey, *readTimeout, *writeTimeout,
-		*useMock, *logLevel, *metricsAddr, *disableMetrics)
+	// Применяем переопределения из командной строки (повторно - при каждой перезагрузке конфигурации)
+	overrides := func(c *AppConfig) {
+		applyCommandLineOverrides(c,
+			*listenAddr, *tlsCert, *tlsKey, *readTimeout, *writeTimeout,
+			*useMock, *logLevel, *metricsAddr, *disableMetrics)
+	}
+	overrides(config)
+
+	// Направляем логи в файл с ротацией, если он задан
+	if config.Logging.File.Enabled() {
+		logFile, err := logger.NewRotatingFile(config.Logging.File)
+		if err != nil {
+			log.Fatalf("Failed to open log file: %v", err)
+		}
+		defer logFile.Close()
+		logger.SetGlobalOutput(logFile)
+	}
 
 	// Устанавливаем уровень логирования
 	level := logger.ParseLogLevel(config.Logging.Level)
 	logger.SetGlobalLevel(level)
+	logger.AddRedactedHeaders(config.Logging.RedactHeaders...)
 
 	logger.Info("S3 Proxy API Gateway starting...")
 	logger.Info("Log level: %s", level.String())
 
+	// Настраиваем трассировку (без коллектора спаны не создаются)
+	shutdownTracing, err := tracing.Setup(context.Background(), &config.Tracing)
+	if err != nil {
+		log.Fatalf("Failed to set up tracing: %v", err)
+	}
+
 	// Получаем метрики для передачи в модули (если мониторинг включен)
 	// TODO: Метрики должны определяться в модулях, а не в mnitoring
 	// var metrics *monitoring.Metrics
@@ -95,20 +117,14 @@ func main() {
 		logger.Info("Backend manager disabled")
 	}
 
-	// Создаем и запускаем модуль мониторинга
+	// Создаем модуль мониторинга (запускается после создания API Gateway,
+	// чтобы зарегистрировать его эндпоинты)
 	var monitor *monitoring.Monitor
 	if !*disableMetrics && config.Monitoring.Enabled {
 		monitor, err = monitoring.New(&config.Monitoring, backendManager)
 		if err != nil {
 			log.Fatalf("Failed to create monitoring module: %v", err)
 		}
-
-		err = monitor.Start()
-		if err != nil {
-			log.Fatalf("Failed to start monitoring module: %v", err)
-		}
-
-		logger.Info("Monitoring enabled on %s", config.Monitoring.ListenAddress)
 	} else {
 		logger.Info("Monitoring disabled")
 	}
@@ -116,8 +132,14 @@ func main() {
 	// Создаем конфигурацию API Gateway
 	gatewayConfig := config.ToAPIGatewayConfig()
 
+	// Контекст фоновых задач (сверка копий), отменяется при остановке
+	backgroundCtx, stopBackground := context.WithCancel(context.Background())
+	defer stopBackground()
+
 	// Создаем обработчик в зависимости от конфигурации
 	var handler apigw.RequestHandler
+	var authenticator auth.Authenticator
+	var engine *routing.Engine
 	if config.Server.UseMock {
 		logger.Info("Using Mock Handler (for testing)")
 		handler = handlers.NewMockHandler()
@@ -125,26 +147,57 @@ func main() {
 		logger.Info("Using Policy & Routing Engine")
 
 		// Создаем аутентификатор
-		authenticator, err := auth.NewAuthenticatorFromConfig(&config.Auth)
+		authenticator, err = auth.NewAuthenticatorFromConfig(&config.Auth)
 		if err != nil {
 			log.Fatalf("Failed to create authenticator: %v", err)
 		}
 
 		// Логируем доступные учетные данные
-		logger.Info("Authentication configured with %d users:", len(config.Auth.Static.Users))
-		for _, user := range config.Auth.Static.Users {
-			logger.Info("  - %s (%s)", user.DisplayName, user.AccessKey)
+		if config.Auth.Static != nil {
+			users, _ := config.Auth.Static.AllUsers()
+			logger.Info("Authentication configured with %d users:", len(users))
+			for _, user := range users {
+				logger.Info("  - %s (%s)", user.DisplayName, user.AccessKey)
+			}
+		}
+		if config.Auth.HTTP != nil {
+			logger.Info("Authentication delegated to key service %s", config.Auth.HTTP.Endpoint)
 		}
 
-		// Создаем реальные исполнители
-		// Replicator для операций записи
-		replicatorConfig := replicator.DefaultConfig() // Используем конфигурацию по умолчанию для replicator
-		//backendAdapter := replicator.NewBackendAdapter(backendManager)
-		replicatorInstance := replicator.NewReplicator(backendManager, replicatorConfig)
+		// Создаем реальные исполнители. При отключенных бэкендах исполнителей нет,
+		// и Engine отвечает 503 на операции с бэкендами.
+		var replicatorExecutor routing.ReplicationExecutor
+		var fetcherExecutor routing.FetchingExecutor
+		if backendManager != nil {
+			// Replicator для операций записи
+			replicatorConfig := config.Replicator
+			if replicatorConfig.RetryAfter == 0 {
+				replicatorConfig.RetryAfter = config.Server.RetryAfter
+			}
+			//backendAdapter := replicator.NewBackendAdapter(backendManager)
+			replicatorInstance := replicator.NewReplicator(backendManager, &replicatorConfig)
+			replicatorExecutor = replicatorInstance
+			if monitor != nil {
+				monitor.Handle("/multipart/abort", replicatorInstance.UploadAbortHandler())
+			}
 
-		// Fetcher для операций чтения
-		cache := fetch.NewStubCache() // Пока используем заглушку кэша
-		fetcherInstance := fetch.NewFetcher(backendManager, cache, config.Server.VirtualBucket)
+			// Fetcher для операций чтения
+			cache := fetch.NewStubCache() // Пока используем заглушку кэша
+			fetcher := fetch.NewFetcher(backendManager, cache, config.Server.VirtualBucket)
+			fetcher.SetListMergeThreshold(config.Server.ListMergeThreshold)
+			fetcher.SetListCache(config.Server.ListCacheTTL, config.Server.ListCacheSize)
+			fetcher.SetMaxConcurrentRequests(config.Server.MaxConcurrentReads)
+			fetcher.SetRetryAfter(config.Server.RetryAfter)
+			fetcher.SetUploadIDResolver(replicatorInstance)
+			fetcherExecutor = fetcher
+
+			// Фоновая сверка копий объектов на бэкендах
+			if config.Server.Scrub.Enabled() {
+				go fetch.NewScrubber(fetcher, config.Server.Scrub).Run(backgroundCtx)
+			}
+		} else {
+			logger.Warn("Backends are disabled: S3 operations will be answered with 503")
+		}
 
 		// Логируем политики маршрутизации``
 		logger.Info("Routing policies configured:")
@@ -153,13 +206,44 @@ func main() {
 		logger.Info("  GET operations: strategy=%s", config.Routing.Policies.Get.Strategy)
 
 		// Создаем Policy & Routing Engine
-		engine := routing.NewEngine(authenticator, replicatorInstance, fetcherInstance, &config.Routing)
+		engine = routing.NewEngine(authenticator, replicatorExecutor, fetcherExecutor, &config.Routing)
+		if config.Server.BucketMode == routing.BucketModeStrict {
+			engine.SetVirtualBucket(config.Server.VirtualBucket)
+			logger.Info("Serving only virtual bucket %q", config.Server.VirtualBucket)
+		}
 		handler = engine
 	}
 
+	// Журнал доступа пишется отдельно от логов: в свой файл или в stdout
+	if config.Logging.AccessLog.Enabled {
+		gatewayConfig.AccessLog = os.Stdout
+		if config.Logging.AccessLog.File.Enabled() {
+			accessLogFile, err := logger.NewRotatingFile(config.Logging.AccessLog.File)
+			if err != nil {
+				log.Fatalf("Failed to open access log file: %v", err)
+			}
+			defer accessLogFile.Close()
+			gatewayConfig.AccessLog = accessLogFile
+		}
+	}
+
 	// Создаем и запускаем API Gateway
 	gateway := apigw.New(gatewayConfig, handler)
 
+	// Запускаем модуль мониторинга
+	if monitor != nil {
+		if recent := gateway.RecentRequests(); recent != nil {
+			monitor.Handle("/requests", recent)
+		}
+
+		err = monitor.Start()
+		if err != nil {
+			log.Fatalf("Failed to start monitoring module: %v", err)
+		}
+
+		logger.Info("Monitoring enabled on %s", config.Monitoring.ListenAddress)
+	}
+
 	logger.Info("Configuration:")
 	logger.Info("  Listen Address: %s", gatewayConfig.ListenAddress)
 	logger.Info("  Read Timeout: %v", gatewayConfig.ReadTimeout)
@@ -176,6 +260,27 @@ func main() {
 	sigChan := make(chan os.Signal, 1)
 	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
 
+	// SIGHUP перечитывает файл конфигурации и применяет изменения на лету
+	reloader := &configReloader{
+		path:          *configFile,
+		overrides:     overrides,
+		current:       config,
+		authenticator: authenticator,
+		engine:        engine,
+	}
+	hupChan := make(chan os.Signal, 1)
+	signal.Notify(hupChan, syscall.SIGHUP)
+	go func() {
+		for range hupChan {
+			logger.Info("Received SIGHUP, reloading configuration from %s", *configFile)
+			if err := reloader.Reload(); err != nil {
+				logger.Error("Configuration reload failed, keeping current configuration: %v", err)
+				continue
+			}
+			logger.Info("Configuration reloaded successfully")
+		}
+	}()
+
 	// Запускаем API Gateway в отдельной горутине
 	go func() {
 		if err := gateway.Start(); err != nil {
@@ -209,8 +314,14 @@ func main() {
 
 	// Запускаем graceful shutdown в отдельной горутине
 	go func() {
+		// Останавливаем фоновые задачи
+		stopBackground()
+
 		// Останавливаем API Gateway
-		if err := gateway.Stop(shutdownCtx); err != nil {
+		// Активные запросы дорабатывают до конца, но не дольше таймаута
+		if err := gateway.Stop(shutdownCtx); errors.Is(err, apigw.ErrShutdownTimeout) {
+			logger.Warn("API Gateway stopped before all requests completed: %v", err)
+		} else if err != nil {
 			logger.Error("Error stopping API Gateway: %v", err)
 		}
 
@@ -221,6 +332,11 @@ func main() {
 			}
 		}
 
+		// Отправляем накопленные спаны
+		if err := shutdownTracing(shutdownCtx); err != nil {
+			logger.Error("Error flushing traces: %v", err)
+		}
+
 		close(done)
 	}()
 
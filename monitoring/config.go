@@ -27,6 +27,10 @@ type Config struct {
 	
 	// SystemMetricsInterval - интервал сбора системных метрик
 	SystemMetricsInterval time.Duration `yaml:"system_metrics_interval"`
+
+	// EnablePprof - отдавать профили net/http/pprof по /debug/pprof/ (по умолчанию выключено).
+	// Профили раскрывают внутреннее состояние процесса, адрес мониторинга не должен быть публичным.
+	EnablePprof bool `yaml:"enable_pprof"`
 }
 
 // DefaultConfig возвращает конфигурацию по умолчанию
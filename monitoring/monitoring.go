@@ -3,6 +3,7 @@ package monitoring
 import (
 	"context"
 	"fmt"
+	"net/http"
 
 	"s3proxy/backend"
 	"s3proxy/logger"
@@ -77,6 +78,11 @@ func (m *Monitor) Stop(ctx context.Context) error {
 	return nil
 }
 
+// Handle добавляет эндпоинт на сервер мониторинга. Вызывается до Start.
+func (m *Monitor) Handle(pattern string, handler http.Handler) {
+	m.server.Handle(pattern, handler)
+}
+
 // GetConfig возвращает конфигурацию мониторинга
 func (m *Monitor) GetConfig() *Config {
 	return m.config
@@ -3,10 +3,9 @@ package monitoring
 import (
 	"context"
 	"net/http"
+	"net/http/httptest"
 	"testing"
 	"time"
-
-	"github.com/prometheus/client_golang/prometheus"
 )
 
 func TestDefaultConfig(t *testing.T) {
@@ -99,54 +98,9 @@ func TestConfigValidation(t *testing.T) {
 	}
 }
 
-func TestNewMetrics(t *testing.T) {
-	// Создаем отдельный registry для тестов
-	registry := prometheus.NewRegistry()
-	
-	// Временно заменяем default registerer
-	oldRegisterer := prometheus.DefaultRegisterer
-	prometheus.DefaultRegisterer = registry
-	defer func() {
-		prometheus.DefaultRegisterer = oldRegisterer
-	}()
-	
-	metrics := NewMetrics()
-	
-	if metrics == nil {
-		t.Fatal("Expected metrics to be created")
-	}
-	
-	// Проверяем, что все основные метрики созданы
-	if metrics.RequestsTotal == nil {
-		t.Error("Expected RequestsTotal to be created")
-	}
-	
-	if metrics.RequestLatency == nil {
-		t.Error("Expected RequestLatency to be created")
-	}
-	
-	if metrics.BackendState == nil {
-		t.Error("Expected BackendState to be created")
-	}
-	
-	if metrics.CacheHitsTotal == nil {
-		t.Error("Expected CacheHitsTotal to be created")
-	}
-}
-
 func TestNewMonitor(t *testing.T) {
-	// Создаем отдельный registry для тестов
-	registry := prometheus.NewRegistry()
-	
-	// Временно заменяем default registerer
-	oldRegisterer := prometheus.DefaultRegisterer
-	prometheus.DefaultRegisterer = registry
-	defer func() {
-		prometheus.DefaultRegisterer = oldRegisterer
-	}()
-	
 	// Тест с конфигурацией по умолчанию
-	monitor, err := New(nil)
+	monitor, err := New(nil, nil)
 	if err != nil {
 		t.Fatalf("Expected no error creating monitor, got: %v", err)
 	}
@@ -158,10 +112,6 @@ func TestNewMonitor(t *testing.T) {
 	if !monitor.IsEnabled() {
 		t.Error("Expected monitor to be enabled by default")
 	}
-	
-	if monitor.GetMetrics() == nil {
-		t.Error("Expected metrics to be available")
-	}
 }
 
 func TestNewMonitorWithInvalidConfig(t *testing.T) {
@@ -173,7 +123,7 @@ func TestNewMonitorWithInvalidConfig(t *testing.T) {
 		WriteTimeout:  30 * time.Second,
 	}
 	
-	_, err := New(invalidConfig)
+	_, err := New(invalidConfig, nil)
 	if err == nil {
 		t.Error("Expected error creating monitor with invalid config")
 	}
@@ -184,7 +134,7 @@ func TestMonitorDisabled(t *testing.T) {
 		Enabled: false,
 	}
 	
-	monitor, err := New(config)
+	monitor, err := New(config, nil)
 	if err != nil {
 		t.Fatalf("Expected no error creating disabled monitor, got: %v", err)
 	}
@@ -209,16 +159,6 @@ func TestMonitorDisabled(t *testing.T) {
 }
 
 func TestMonitorStartStop(t *testing.T) {
-	// Создаем отдельный registry для тестов
-	registry := prometheus.NewRegistry()
-	
-	// Временно заменяем default registerer
-	oldRegisterer := prometheus.DefaultRegisterer
-	prometheus.DefaultRegisterer = registry
-	defer func() {
-		prometheus.DefaultRegisterer = oldRegisterer
-	}()
-	
 	// Используем другой порт для тестов, чтобы избежать конфликтов
 	config := &Config{
 		Enabled:               true,
@@ -230,7 +170,7 @@ func TestMonitorStartStop(t *testing.T) {
 		SystemMetricsInterval: 1 * time.Second,
 	}
 	
-	monitor, err := New(config)
+	monitor, err := New(config, nil)
 	if err != nil {
 		t.Fatalf("Expected no error creating monitor, got: %v", err)
 	}
@@ -254,77 +194,34 @@ func TestMonitorStartStop(t *testing.T) {
 	}
 }
 
-func TestHealthEndpoint(t *testing.T) {
-	// Создаем отдельный registry для тестов
-	registry := prometheus.NewRegistry()
-	
-	// Временно заменяем default registerer
-	oldRegisterer := prometheus.DefaultRegisterer
-	prometheus.DefaultRegisterer = registry
-	defer func() {
-		prometheus.DefaultRegisterer = oldRegisterer
-	}()
-	
-	config := &Config{
-		Enabled:               true,
-		ListenAddress:         ":0",
-		MetricsPath:           "/metrics",
-		ReadTimeout:           5 * time.Second,
-		WriteTimeout:          5 * time.Second,
-		EnableSystemMetrics:   false,
-		SystemMetricsInterval: 1 * time.Second,
-	}
-	
-	monitor, err := New(config)
+func TestHealthEndpoints(t *testing.T) {
+	monitor, err := New(DefaultConfig(), nil)
 	if err != nil {
 		t.Fatalf("Expected no error creating monitor, got: %v", err)
 	}
-	
-	server := NewServer(config, monitor.GetMetrics())
-	
-	// Тестируем health handler напрямую
-	req, err := http.NewRequest("GET", "/health", nil)
-	if err != nil {
-		t.Fatalf("Failed to create request: %v", err)
-	}
-	
-	// Используем ResponseRecorder для тестирования
-	rr := &testResponseWriter{
-		header: make(http.Header),
-		body:   make([]byte, 0),
-	}
-	
-	server.healthHandler(rr, req)
-	
-	if rr.statusCode != http.StatusOK {
-		t.Errorf("Expected status code %d, got %d", http.StatusOK, rr.statusCode)
-	}
-	
-	expectedContentType := "application/json"
-	if contentType := rr.header.Get("Content-Type"); contentType != expectedContentType {
-		t.Errorf("Expected Content-Type %s, got %s", expectedContentType, contentType)
-	}
-}
+	handler := monitor.server.handler()
 
-// testResponseWriter - простая реализация http.ResponseWriter для тестов
-type testResponseWriter struct {
-	header     http.Header
-	body       []byte
-	statusCode int
-}
-
-func (w *testResponseWriter) Header() http.Header {
-	return w.header
-}
-
-func (w *testResponseWriter) Write(data []byte) (int, error) {
-	w.body = append(w.body, data...)
-	if w.statusCode == 0 {
-		w.statusCode = http.StatusOK
+	for _, path := range []string{"/health/live", "/health/ready"} {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		if rec.Code != http.StatusOK {
+			t.Errorf("Expected status %d for %s, got %d", http.StatusOK, path, rec.Code)
+		}
+		if contentType := rec.Header().Get("Content-Type"); contentType != "application/json" {
+			t.Errorf("Expected Content-Type application/json for %s, got %s", path, contentType)
+		}
 	}
-	return len(data), nil
-}
 
-func (w *testResponseWriter) WriteHeader(statusCode int) {
-	w.statusCode = statusCode
+	// После начала остановки сервис перестает быть готовым, но остается живым
+	monitor.InitiateShutdown()
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/health/ready", nil))
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("Expected status %d for /health/ready during shutdown, got %d", http.StatusServiceUnavailable, rec.Code)
+	}
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/health/live", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected status %d for /health/live during shutdown, got %d", http.StatusOK, rec.Code)
+	}
 }
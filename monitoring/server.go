@@ -4,6 +4,7 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"net/http/pprof"
 	"sync/atomic"
 
 	"s3proxy/backend"
@@ -19,6 +20,9 @@ type Server struct {
 	backendManager    *backend.Manager
 	shuttingDown      atomic.Bool
 
+	// Дополнительные эндпоинты других модулей (см. Handle)
+	handlers map[string]http.Handler
+
 	// Канал для остановки сбора системных метрик
 	stopSystemMetrics chan struct{}
 }
@@ -33,11 +37,18 @@ func NewServer(config *Config, backendManager *backend.Manager) *Server {
 		config:            config,
 		backendManager:    backendManager,
 		stopSystemMetrics: make(chan struct{}),
+		handlers:          make(map[string]http.Handler),
 	}
 	s.shuttingDown.Store(false)
 	return s
 }
 
+// Handle добавляет на сервер мониторинга эндпоинт другого модуля (например, /requests
+// API Gateway). Вызывается до Start.
+func (s *Server) Handle(pattern string, handler http.Handler) {
+	s.handlers[pattern] = handler
+}
+
 // Start запускает HTTP сервер для метрик
 func (s *Server) Start() error {
 	if !s.config.Enabled {
@@ -47,20 +58,10 @@ func (s *Server) Start() error {
 
 	logger.Info("Starting metrics server on %s", s.config.ListenAddress)
 
-	// Создаем HTTP мультиплексор
-	mux := http.NewServeMux()
-
-	// Регистрируем обработчик метрик
-	mux.Handle(s.config.MetricsPath, promhttp.Handler())
-
-	// Добавляем health check эндпоинты
-	mux.HandleFunc("/health/live", s.liveHealthHandler)
-	mux.HandleFunc("/health/ready", s.readyHealthHandler)
-
 	// Создаем HTTP сервер
 	s.server = &http.Server{
 		Addr:         s.config.ListenAddress,
-		Handler:      mux,
+		Handler:      s.handler(),
 		ReadTimeout:  s.config.ReadTimeout,
 		WriteTimeout: s.config.WriteTimeout,
 	}
@@ -76,6 +77,40 @@ func (s *Server) Start() error {
 	return nil
 }
 
+// handler возвращает мультиплексор эндпоинтов сервера мониторинга
+func (s *Server) handler() http.Handler {
+	// Создаем HTTP мультиплексор
+	mux := http.NewServeMux()
+
+	// Регистрируем обработчик метрик
+	mux.Handle(s.config.MetricsPath, promhttp.Handler())
+
+	// Добавляем health check эндпоинты
+	mux.HandleFunc("/health/live", s.liveHealthHandler)
+	mux.HandleFunc("/health/ready", s.readyHealthHandler)
+
+	// Состояние бэкендов для операторов (последняя ошибка, время проверки)
+	if s.backendManager != nil {
+		mux.Handle("/backends", s.backendManager.StatusHandler())
+	}
+
+	for pattern, handler := range s.handlers {
+		mux.Handle(pattern, handler)
+	}
+
+	// Профилирование (горутины, heap, CPU) для диагностики утечек без пересборки
+	if s.config.EnablePprof {
+		logger.Warn("pprof endpoints are enabled on %s/debug/pprof/", s.config.ListenAddress)
+		mux.HandleFunc("/debug/pprof/", pprof.Index)
+		mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+		mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+		mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+		mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	}
+
+	return mux
+}
+
 // Stop останавливает HTTP сервер метрик
 func (s *Server) Stop(ctx context.Context) error {
 	if !s.config.Enabled || s.server == nil {
@@ -110,7 +145,7 @@ func (s *Server) readyHealthHandler(w http.ResponseWriter, r *http.Request) {
 	}
 
 	// Проверяем, есть ли живые бэкенды (если backendManager доступен)
-	if s.backendManager != nil && len(s.backendManager.GetLiveBackends()) == 0 {
+	if s.backendManager != nil && !s.backendManager.HasLiveBackends() {
 		w.WriteHeader(http.StatusServiceUnavailable)
 		fmt.Fprintf(w, `{"status":"no live backends"}`)
 		return
@@ -0,0 +1,39 @@
+package monitoring
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestServer_Pprof(t *testing.T) {
+	tests := []struct {
+		name     string
+		enabled  bool
+		expected int
+	}{
+		{name: "disabled by default", enabled: false, expected: http.StatusNotFound},
+		{name: "enabled", enabled: true, expected: http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := DefaultConfig()
+			config.EnablePprof = tt.enabled
+			server := NewServer(config, nil)
+
+			rec := httptest.NewRecorder()
+			server.handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/pprof/", nil))
+			if rec.Code != tt.expected {
+				t.Errorf("Expected status %d for /debug/pprof/, got %d", tt.expected, rec.Code)
+			}
+
+			// Остальные эндпоинты доступны независимо от pprof
+			rec = httptest.NewRecorder()
+			server.handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/health/live", nil))
+			if rec.Code != http.StatusOK {
+				t.Errorf("Expected status 200 for /health/live, got %d", rec.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+
+	"s3proxy/auth"
+	"s3proxy/logger"
+	"s3proxy/routing"
+)
+
+// configReloader перечитывает файл конфигурации (по SIGHUP) и применяет
+// изменения, которые безопасно менять на лету: учетные данные, политики
+// маршрутизации и уровень логирования. Остальные изменения требуют перезапуска
+// и только логируются.
+type configReloader struct {
+	path      string
+	overrides func(*AppConfig) // Переопределения из командной строки, применяемые поверх файла
+	current   *AppConfig       // Действующая конфигурация
+
+	authenticator auth.Authenticator // nil в режиме mock
+	engine        *routing.Engine    // nil в режиме mock
+}
+
+// Reload загружает и валидирует конфигурацию, затем применяет ее.
+// При ошибке действующая конфигурация не меняется.
+func (r *configReloader) Reload() error {
+	config, err := LoadConfig(r.path)
+	if err != nil {
+		return err
+	}
+	if r.overrides != nil {
+		r.overrides(config)
+		if err := config.Validate(); err != nil {
+			return fmt.Errorf("invalid configuration: %w", err)
+		}
+	}
+
+	for _, section := range restartRequiredChanges(r.current, config) {
+		logger.Warn("Config reload: change of %s requires restart, skipped", section)
+	}
+
+	// Учетные данные применяются первыми: это единственный шаг, который может завершиться ошибкой.
+	// Применяются всегда, так как могли измениться файлы credentials_files
+	if r.authenticator != nil {
+		if err := auth.ReloadAuthenticator(r.authenticator, &config.Auth); err != nil {
+			return fmt.Errorf("failed to reload credentials: %w", err)
+		}
+		// Анонимный доступ включается только при старте: без него аутентификатор не обернут
+		if _, wrapped := r.authenticator.(*auth.AnonymousAuthenticator); !wrapped && config.Auth.AllowAnonymous {
+			logger.Warn("Config reload: enabling auth.allow_anonymous requires restart, skipped")
+			config.Auth.AllowAnonymous = false
+		}
+		r.current.Auth = config.Auth
+		if config.Auth.Static != nil {
+			users, _ := config.Auth.Static.AllUsers()
+			logger.Info("Config reload: authentication updated with %d users", len(users))
+		}
+	}
+
+	if r.engine != nil && (!reflect.DeepEqual(r.current.Routing.Policies, config.Routing.Policies) ||
+		!reflect.DeepEqual(r.current.Routing.BucketPolicies, config.Routing.BucketPolicies)) {
+		r.engine.UpdatePolicies(&config.Routing)
+		r.current.Routing.Policies = config.Routing.Policies
+		r.current.Routing.BucketPolicies = config.Routing.BucketPolicies
+		logger.Info("Config reload: routing policies updated (put ack=%s, delete ack=%s, get strategy=%s, %d bucket overrides)",
+			config.Routing.Policies.Put.AckLevel, config.Routing.Policies.Delete.AckLevel, config.Routing.Policies.Get.Strategy,
+			len(config.Routing.BucketPolicies))
+	}
+
+	if r.current.Logging.Level != config.Logging.Level {
+		logger.SetGlobalLevel(logger.ParseLogLevel(config.Logging.Level))
+		logger.Info("Config reload: log level changed from %s to %s", r.current.Logging.Level, config.Logging.Level)
+	}
+	// Список скрываемых заголовков можно только расширить
+	logger.AddRedactedHeaders(config.Logging.RedactHeaders...)
+	logFile, accessLog := r.current.Logging.File, r.current.Logging.AccessLog
+	r.current.Logging = config.Logging
+	r.current.Logging.File, r.current.Logging.AccessLog = logFile, accessLog
+
+	return nil
+}
+
+// restartRequiredChanges возвращает разделы конфигурации, изменения в которых
+// нельзя применить без перезапуска
+func restartRequiredChanges(current, next *AppConfig) []string {
+	var changed []string
+	check := func(name string, a, b interface{}) {
+		if !reflect.DeepEqual(a, b) {
+			changed = append(changed, name)
+		}
+	}
+
+	check("server.listen_address", current.Server.ListenAddress, next.Server.ListenAddress)
+	check("server.virtual_bucket", current.Server.VirtualBucket, next.Server.VirtualBucket)
+	check("server.bucket_mode", current.Server.BucketMode, next.Server.BucketMode)
+	check("server.tls_cert_file", current.Server.TLSCertFile, next.Server.TLSCertFile)
+	check("server.tls_key_file", current.Server.TLSKeyFile, next.Server.TLSKeyFile)
+	check("server.read_timeout", current.Server.ReadTimeout, next.Server.ReadTimeout)
+	check("server.write_timeout", current.Server.WriteTimeout, next.Server.WriteTimeout)
+	check("server.use_mock", current.Server.UseMock, next.Server.UseMock)
+	check("server.base_domain", current.Server.BaseDomain, next.Server.BaseDomain)
+	check("server.max_object_size", current.Server.MaxObjectSize, next.Server.MaxObjectSize)
+	check("server.max_requests_per_client", current.Server.MaxRequestsPerClient, next.Server.MaxRequestsPerClient)
+	check("server.retry_after", current.Server.RetryAfter, next.Server.RetryAfter)
+	check("server.scrub", current.Server.Scrub, next.Server.Scrub)
+	check("server.cors", current.Server.CORS, next.Server.CORS)
+	check("logging.file", current.Logging.File, next.Logging.File)
+	check("logging.access_log", current.Logging.AccessLog, next.Logging.AccessLog)
+	check("backend", current.Backend, next.Backend)
+	check("monitoring", current.Monitoring, next.Monitoring)
+	check("tracing", current.Tracing, next.Tracing)
+	check("replicator", current.Replicator, next.Replicator)
+	check("routing.region", current.Routing.Region, next.Routing.Region)
+
+	return changed
+}
@@ -0,0 +1,267 @@
+package main
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+
+	"s3proxy/apigw"
+	"s3proxy/auth"
+	"s3proxy/logger"
+	"s3proxy/routing"
+)
+
+const reloadTestConfig = `
+server:
+  listen_address: ":9000"
+  read_timeout: 30s
+  write_timeout: 30s
+logging:
+  level: info
+auth:
+  provider: static
+  static:
+    users:
+{{USERS}}
+backend:
+  manager:
+    health_check_interval: 10s
+    check_timeout: 2s
+    failure_threshold: 3
+    success_threshold: 2
+    circuit_breaker_window: 60s
+    circuit_breaker_threshold: 5
+    initial_state: UP
+  backends:
+    minio:
+      endpoint: http://localhost:9001
+      region: us-east-1
+      bucket: data
+      access_key: minio
+      secret_key: minio-secret
+routing:
+  policies:
+    put:
+      ack: {{PUT_ACK}}
+    delete:
+      ack: all
+    get:
+      strategy: first
+`
+
+// writeReloadTestConfig записывает конфигурацию с заданными пользователями ("access:secret")
+func writeReloadTestConfig(t *testing.T, path, putAck string, users ...string) {
+	t.Helper()
+
+	var usersYAML strings.Builder
+	for _, user := range users {
+		parts := strings.SplitN(user, ":", 2)
+		usersYAML.WriteString("      - access_key: " + parts[0] + "\n")
+		usersYAML.WriteString("        secret_key: " + parts[1] + "\n")
+		usersYAML.WriteString("        display_name: " + parts[0] + "\n")
+	}
+
+	content := strings.ReplaceAll(reloadTestConfig, "{{USERS}}", strings.TrimRight(usersYAML.String(), "\n"))
+	content = strings.ReplaceAll(content, "{{PUT_ACK}}", putAck)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+}
+
+// newSignedRequest создает подписанный SigV4 GET запрос и разбирает его так же, как API Gateway
+func newSignedRequest(t *testing.T, accessKey, secretKey string) *apigw.S3Request {
+	t.Helper()
+
+	const payloadHash = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+	r := httptest.NewRequest("GET", "http://localhost:9000/my-bucket/object.txt", nil)
+	r.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	creds := aws.Credentials{AccessKeyID: accessKey, SecretAccessKey: secretKey}
+	if err := v4.NewSigner().SignHTTP(context.Background(), creds, r, payloadHash, "s3", "us-east-1", time.Now()); err != nil {
+		t.Fatalf("Failed to sign request: %v", err)
+	}
+
+	req, err := apigw.NewRequestParser("").Parse(r)
+	if err != nil {
+		t.Fatalf("Failed to parse request: %v", err)
+	}
+	return req
+}
+
+func TestConfigReloader_Credentials(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeReloadTestConfig(t, path, "one", "OLDKEY:old-secret")
+
+	config, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	authenticator, err := auth.NewAuthenticatorFromConfig(&config.Auth)
+	if err != nil {
+		t.Fatalf("Failed to create authenticator: %v", err)
+	}
+	reloader := &configReloader{path: path, current: config, authenticator: authenticator}
+
+	if _, err := authenticator.Authenticate(newSignedRequest(t, "NEWKEY", "new-secret")); err != auth.ErrInvalidAccessKeyID {
+		t.Fatalf("Expected ErrInvalidAccessKeyID before reload, got %v", err)
+	}
+
+	writeReloadTestConfig(t, path, "one", "NEWKEY:new-secret")
+	if err := reloader.Reload(); err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+
+	identity, err := authenticator.Authenticate(newSignedRequest(t, "NEWKEY", "new-secret"))
+	if err != nil {
+		t.Fatalf("Expected new user to authenticate after reload, got %v", err)
+	}
+	if identity.AccessKey != "NEWKEY" {
+		t.Errorf("Expected identity NEWKEY, got %s", identity.AccessKey)
+	}
+
+	// Удаленный пользователь больше не проходит аутентификацию
+	if _, err := authenticator.Authenticate(newSignedRequest(t, "OLDKEY", "old-secret")); err != auth.ErrInvalidAccessKeyID {
+		t.Errorf("Expected ErrInvalidAccessKeyID for removed user, got %v", err)
+	}
+}
+
+func TestConfigReloader_InvalidConfigKeepsCurrent(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeReloadTestConfig(t, path, "one", "OLDKEY:old-secret")
+
+	config, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	authenticator, err := auth.NewAuthenticatorFromConfig(&config.Auth)
+	if err != nil {
+		t.Fatalf("Failed to create authenticator: %v", err)
+	}
+	reloader := &configReloader{path: path, current: config, authenticator: authenticator}
+
+	// Пользователь без секретного ключа не проходит валидацию
+	writeReloadTestConfig(t, path, "one", "NEWKEY:")
+	if err := reloader.Reload(); err == nil {
+		t.Fatal("Expected reload of invalid config to fail")
+	}
+
+	if _, err := authenticator.Authenticate(newSignedRequest(t, "OLDKEY", "old-secret")); err != nil {
+		t.Errorf("Expected old credentials to remain valid, got %v", err)
+	}
+}
+
+func TestConfigReloader_PoliciesAndLogLevel(t *testing.T) {
+	defer logger.SetGlobalLevel(logger.INFO)
+
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeReloadTestConfig(t, path, "one", "OLDKEY:old-secret")
+
+	config, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	engine := routing.NewEngine(nil, routing.NewMockReplicationExecutor(), routing.NewMockFetchingExecutor(), &config.Routing)
+	reloader := &configReloader{path: path, current: config, engine: engine}
+
+	writeReloadTestConfig(t, path, "all", "OLDKEY:old-secret")
+	content, _ := os.ReadFile(path)
+	content = []byte(strings.Replace(string(content), "level: info", "level: debug", 1))
+	content = []byte(strings.Replace(string(content), `":9000"`, `":9100"`, 1))
+	if err := os.WriteFile(path, content, 0644); err != nil {
+		t.Fatalf("Failed to write config: %v", err)
+	}
+
+	if err := reloader.Reload(); err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+
+	if ack := reloader.current.Routing.Policies.Put.AckLevel; ack != "all" {
+		t.Errorf("Expected put policy ack 'all' after reload, got %q", ack)
+	}
+	if logger.GetGlobalLevel() != logger.DEBUG {
+		t.Errorf("Expected log level DEBUG after reload, got %s", logger.GetGlobalLevel())
+	}
+	// Адрес прослушивания не меняется на лету
+	if config.Server.ListenAddress != ":9000" {
+		t.Errorf("Expected listen address to stay ':9000', got %q", config.Server.ListenAddress)
+	}
+}
+
+// policyRecorder запоминает политику последнего PUT, переданного Engine исполнителю
+type policyRecorder struct {
+	*routing.MockReplicationExecutor
+	put routing.WriteOperationPolicy
+}
+
+func (p *policyRecorder) PutObject(ctx context.Context, req *apigw.S3Request, policy routing.WriteOperationPolicy) *apigw.S3Response {
+	p.put = policy
+	return p.MockReplicationExecutor.PutObject(ctx, req, policy)
+}
+
+// allowAllAuthenticator пропускает любой запрос
+type allowAllAuthenticator struct{}
+
+func (allowAllAuthenticator) Authenticate(req *apigw.S3Request) (*auth.UserIdentity, error) {
+	return &auth.UserIdentity{DisplayName: "test-user", AccessKey: "OLDKEY"}, nil
+}
+
+func TestConfigReloader_BucketPolicies(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeBucketPolicy := func(ack string) {
+		t.Helper()
+		writeReloadTestConfig(t, path, "one", "OLDKEY:old-secret")
+		content, _ := os.ReadFile(path)
+		content = append(content, []byte("  bucket_policies:\n    special:\n      put:\n        ack: "+ack+"\n")...)
+		if err := os.WriteFile(path, content, 0644); err != nil {
+			t.Fatalf("Failed to write config: %v", err)
+		}
+	}
+	writeBucketPolicy("none")
+
+	config, err := LoadConfig(path)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+	recorder := &policyRecorder{MockReplicationExecutor: routing.NewMockReplicationExecutor()}
+	engine := routing.NewEngine(allowAllAuthenticator{}, recorder, routing.NewMockFetchingExecutor(), &config.Routing)
+	reloader := &configReloader{path: path, current: config, engine: engine}
+
+	putAck := func() string {
+		t.Helper()
+		engine.Handle(&apigw.S3Request{
+			Operation: apigw.PutObject,
+			Bucket:    "special",
+			Key:       "object.txt",
+			Headers:   make(http.Header),
+			Query:     make(url.Values),
+			Context:   context.Background(),
+		})
+		return recorder.put.AckLevel
+	}
+	if ack := putAck(); ack != "none" {
+		t.Fatalf("Expected bucket override ack 'none' before reload, got %q", ack)
+	}
+
+	// Меняется только переопределение бакета
+	writeBucketPolicy("all")
+	if err := reloader.Reload(); err != nil {
+		t.Fatalf("Reload failed: %v", err)
+	}
+
+	if ack := putAck(); ack != "all" {
+		t.Errorf("Expected bucket override ack 'all' after reload, got %q", ack)
+	}
+	if ack := reloader.current.Routing.BucketPolicies["special"].Put.AckLevel; ack != "all" {
+		t.Errorf("Expected current config to store bucket override ack 'all', got %q", ack)
+	}
+}
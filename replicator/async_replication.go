@@ -0,0 +1,451 @@
+package replicator
+
+import (
+	"context"
+	"errors"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"s3proxy/apigw"
+	"s3proxy/backend"
+	"s3proxy/logger"
+	"s3proxy/routing"
+)
+
+// replicationQueue - очередь асинхронной репликации (Config.AsyncReplication). Объекты,
+// записанные с ack=one не на все бэкенды, хранятся во временных файлах AsyncQueueDir
+// и доставляются отставшим бэкендам в фоне, когда те в состоянии UP. Очередь живет
+// в памяти процесса: при остановке недоставленные объекты теряются.
+type replicationQueue struct {
+	mu       sync.Mutex
+	jobs     map[string]*replicationJob            // Ожидающие доставки объекты по "bucket/key"
+	inflight map[string]map[*asyncReplica]struct{} // Выполняющиеся записи ack=one по "bucket/key"
+
+	wake    chan struct{}
+	stop    chan struct{}
+	done    chan struct{}
+	metrics *Metrics
+
+	// ctx отменяется при остановке очереди и прерывает выполняющиеся доставки
+	ctx    context.Context
+	cancel context.CancelFunc
+}
+
+// replicationJob - объект, ожидающий доставки на отставшие бэкенды
+type replicationJob struct {
+	req         *apigw.S3Request // Ключ, заголовки и размер для PutObject
+	path        string           // Временный файл с телом объекта
+	pending     []string         // ID бэкендов, на которые объект еще не доставлен
+	attempts    int              // Неудачные попытки доставки
+	nextAttempt time.Time
+
+	// cancel прерывает выполняющуюся доставку (nil - доставка не выполняется).
+	// Вызывается, когда объект удаляется из очереди, в том числе при перезаписи ключа.
+	cancel context.CancelFunc
+}
+
+// asyncReplica - копия тела PUT ack=one во временном файле и результаты записи на бэкенды.
+// Копия пишется по мере передачи тела бэкендам (io.TeeReader).
+type asyncReplica struct {
+	bucket, key string
+	file        *os.File
+	size        int64
+	writeErr    error // Ошибка записи копии: объект не ставится в очередь
+
+	mu         sync.Mutex
+	succeeded  map[string]bool
+	rejected   map[string]bool // Бэкенды, отклонившие условную запись (If-Match/If-None-Match)
+	badDigest  bool
+	superseded bool // Ключ перезаписан более новой операцией
+}
+
+// replicationJobID возвращает ключ очереди для объекта
+func replicationJobID(bucket, key string) string {
+	return bucket + "/" + key
+}
+
+// startReplicationQueue создает очередь асинхронной репликации и запускает ее обработчик
+func (r *Replicator) startReplicationQueue() {
+	ctx, cancel := context.WithCancel(context.Background())
+	r.replicationQueue = &replicationQueue{
+		jobs:     make(map[string]*replicationJob),
+		inflight: make(map[string]map[*asyncReplica]struct{}),
+		wake:     make(chan struct{}, 1),
+		stop:     make(chan struct{}),
+		done:     make(chan struct{}),
+		metrics:  NewMetrics(),
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+	go r.runReplicationQueue()
+	logger.Info("Asynchronous replication enabled: queue size %d, retry delay %v..%v",
+		r.config.AsyncQueueSize, r.config.AsyncRetryDelay, r.config.AsyncMaxRetryDelay)
+}
+
+// stopReplicationQueue останавливает обработчик и удаляет файлы недоставленных объектов
+func (r *Replicator) stopReplicationQueue() {
+	q := r.replicationQueue
+	close(q.stop)
+	q.cancel()
+	<-q.done
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.jobs) > 0 {
+		logger.Warn("Replication queue stopped with %d undelivered objects", len(q.jobs))
+	}
+	for id, job := range q.jobs {
+		q.removeJob(id, job, "shutdown")
+	}
+}
+
+// newAsyncReplica создает временный файл для копии тела PUT ack=one. Копия регистрируется
+// как выполняющаяся запись ключа, чтобы более новая операция могла ее отменить.
+func (r *Replicator) newAsyncReplica(req *apigw.S3Request) (*asyncReplica, error) {
+	file, err := os.CreateTemp(r.config.AsyncQueueDir, "s3proxy-replica-*")
+	if err != nil {
+		return nil, err
+	}
+	replica := &asyncReplica{
+		bucket:    req.Bucket,
+		key:       req.Key,
+		file:      file,
+		succeeded: make(map[string]bool),
+		rejected:  make(map[string]bool),
+	}
+
+	q := r.replicationQueue
+	id := replicationJobID(req.Bucket, req.Key)
+	q.mu.Lock()
+	if q.inflight[id] == nil {
+		q.inflight[id] = make(map[*asyncReplica]struct{})
+	}
+	q.inflight[id][replica] = struct{}{}
+	q.mu.Unlock()
+	return replica, nil
+}
+
+// Write реализует io.Writer для io.TeeReader. Ошибка записи копии не прерывает
+// передачу тела бэкендам: копия просто не будет поставлена в очередь.
+func (c *asyncReplica) Write(p []byte) (int, error) {
+	if c.writeErr == nil {
+		var n int
+		n, c.writeErr = c.file.Write(p)
+		c.size += int64(n)
+	}
+	return len(p), nil
+}
+
+// record запоминает результат записи на бэкенд
+func (c *asyncReplica) record(result *backend.BackendResult) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if result.Err == nil {
+		c.succeeded[result.BackendID] = true
+	} else if errors.Is(result.Err, ErrBadDigest) {
+		c.badDigest = true
+	} else if isPreconditionFailedError(result.Err) {
+		c.rejected[result.BackendID] = true
+	}
+}
+
+// supersedeReplication отменяет доставку объекта из очереди (выполняющаяся доставка
+// прерывается) и постановку в очередь копий выполняющихся записей ack=one: ключ
+// перезаписывается или удаляется новой операцией, и старая версия не должна попасть
+// на отставшие бэкенды после нее
+func (r *Replicator) supersedeReplication(bucket, key string) {
+	q := r.replicationQueue
+	if q == nil {
+		return
+	}
+	id := replicationJobID(bucket, key)
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if job, ok := q.jobs[id]; ok {
+		logger.Debug("supersedeReplication: %s was overwritten, dropping queued replication", id)
+		q.removeJob(id, job, "")
+	}
+	for replica := range q.inflight[id] {
+		replica.mu.Lock()
+		replica.superseded = true
+		replica.mu.Unlock()
+	}
+}
+
+// finishAsyncReplica вызывается после завершения записи PUT ack=one на все бэкенды.
+// Если объект записан хотя бы на один бэкенд, но не на все (бэкенд был недоступен
+// или ответил ошибкой), копия ставится в очередь доставки отставшим бэкендам.
+func (r *Replicator) finishAsyncReplica(req *apigw.S3Request, policy routing.WriteOperationPolicy, replica *asyncReplica, aborted bool) {
+	q := r.replicationQueue
+	id := replicationJobID(replica.bucket, replica.key)
+
+	q.mu.Lock()
+	delete(q.inflight[id], replica)
+	if len(q.inflight[id]) == 0 {
+		delete(q.inflight, id)
+	}
+	q.mu.Unlock()
+
+	replica.file.Close()
+	discard := func() {
+		if err := os.Remove(replica.file.Name()); err != nil {
+			logger.Warn("finishAsyncReplica: failed to remove %s: %v", replica.file.Name(), err)
+		}
+	}
+
+	replica.mu.Lock()
+	succeeded, rejected, badDigest, superseded := replica.succeeded, replica.rejected, replica.badDigest, replica.superseded
+	replica.mu.Unlock()
+	switch {
+	case aborted || badDigest || len(succeeded) == 0:
+		// Объект не считается записанным: реплицировать нечего
+		discard()
+		return
+	case superseded:
+		logger.Debug("finishAsyncReplica: %s was overwritten during the write, not queueing", id)
+		discard()
+		return
+	case replica.writeErr != nil:
+		logger.Error("finishAsyncReplica: failed to buffer %s for replication: %v", id, replica.writeErr)
+		discard()
+		return
+	}
+
+	// Отставшие - бэкенды, на которые объект должен был попасть по политике, но не попал.
+	// Бэкенд, отклонивший условную запись, хранит другую версию: ее не перезаписываем.
+	var pending []string
+	for _, b := range selectBySize(req, selectWriteBackends(req, policy, r.backendProvider.GetAllBackends())) {
+		if !succeeded[b.ID] && !rejected[b.ID] {
+			pending = append(pending, b.ID)
+		}
+	}
+	if len(pending) == 0 {
+		discard()
+		return
+	}
+
+	// Условие записи уже проверено при приеме объекта, доставка безусловна
+	headers := req.Headers.Clone()
+	headers.Del("If-Match")
+	headers.Del("If-None-Match")
+	job := &replicationJob{
+		req: &apigw.S3Request{
+			Bucket:        req.Bucket,
+			Key:           req.Key,
+			Headers:       headers,
+			ContentLength: replica.size,
+		},
+		path:        replica.file.Name(),
+		pending:     pending,
+		nextAttempt: time.Now(),
+	}
+
+	q.mu.Lock()
+	if old, ok := q.jobs[id]; ok {
+		q.removeJob(id, old, "")
+	}
+	if len(q.jobs) >= r.config.AsyncQueueSize {
+		q.mu.Unlock()
+		logger.Error("finishAsyncReplica: replication queue is full (%d objects), %s will not be replicated to %v",
+			r.config.AsyncQueueSize, id, pending)
+		q.metrics.ReplicationQueueDropped.WithLabelValues("queue_full").Inc()
+		discard()
+		return
+	}
+	q.jobs[id] = job
+	q.metrics.ReplicationQueueDepth.Inc()
+	q.mu.Unlock()
+
+	logger.Info("finishAsyncReplica: %s queued for replication to %v", id, pending)
+	select {
+	case q.wake <- struct{}{}:
+	default:
+	}
+}
+
+// removeJob удаляет объект из очереди и его файл и прерывает выполняющуюся доставку.
+// Непустой reason учитывается в метрике удаленных без доставки. Вызывается под q.mu.
+func (q *replicationQueue) removeJob(id string, job *replicationJob, reason string) {
+	if job.cancel != nil {
+		job.cancel()
+	}
+	delete(q.jobs, id)
+	q.metrics.ReplicationQueueDepth.Dec()
+	if reason != "" {
+		q.metrics.ReplicationQueueDropped.WithLabelValues(reason).Inc()
+	}
+	if err := os.Remove(job.path); err != nil {
+		logger.Warn("replicationQueue: failed to remove %s: %v", job.path, err)
+	}
+}
+
+// runReplicationQueue доставляет объекты из очереди, пока очередь не остановлена
+func (r *Replicator) runReplicationQueue() {
+	q := r.replicationQueue
+	defer close(q.done)
+
+	wait := r.config.AsyncRetryDelay
+	for {
+		timer := time.NewTimer(wait)
+		select {
+		case <-q.stop:
+			timer.Stop()
+			return
+		case <-q.wake:
+			timer.Stop()
+		case <-timer.C:
+		}
+		wait = r.processReplicationQueue()
+	}
+}
+
+// processReplicationQueue выполняет доставку объектов, время попытки которых наступило,
+// и возвращает, через сколько наступит следующая попытка
+func (r *Replicator) processReplicationQueue() time.Duration {
+	q := r.replicationQueue
+	now := time.Now()
+
+	q.mu.Lock()
+	var due []*replicationJob
+	for _, job := range q.jobs {
+		if !job.nextAttempt.After(now) {
+			due = append(due, job)
+		}
+	}
+	q.mu.Unlock()
+
+	for _, job := range due {
+		select {
+		case <-q.stop:
+			return r.config.AsyncRetryDelay
+		default:
+		}
+		r.deliverReplicationJob(job)
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	wait := r.config.AsyncMaxRetryDelay
+	for _, job := range q.jobs {
+		if until := time.Until(job.nextAttempt); until < wait {
+			wait = until
+		}
+	}
+	if wait < 0 {
+		wait = 0
+	}
+	return wait
+}
+
+// deliverReplicationJob пробует записать объект на отставшие бэкенды в состоянии UP.
+// Недоступные бэкенды ожидают восстановления без учета попыток; после неудачной записи
+// следующая попытка откладывается с экспоненциально растущей задержкой. Запись на бэкенд
+// ограничена OperationTimeout и прерывается при удалении объекта из очереди и остановке очереди.
+func (r *Replicator) deliverReplicationJob(job *replicationJob) {
+	q := r.replicationQueue
+	id := replicationJobID(job.req.Bucket, job.req.Key)
+
+	q.mu.Lock()
+	if q.jobs[id] != job {
+		// Объект перезаписан, пока ждал доставки
+		q.mu.Unlock()
+		return
+	}
+	ctx, cancel := context.WithCancel(q.ctx)
+	job.cancel = cancel
+	q.mu.Unlock()
+	defer cancel()
+
+	file, err := os.Open(job.path)
+	if err != nil {
+		logger.Error("deliverReplicationJob: failed to open %s for %s: %v", job.path, id, err)
+		q.mu.Lock()
+		if q.jobs[id] == job {
+			q.removeJob(id, job, "file_error")
+		}
+		q.mu.Unlock()
+		return
+	}
+	defer file.Close()
+
+	var remaining []string
+	failed := false
+	for _, backendID := range job.pending {
+		b, ok := r.backendProvider.GetBackend(backendID)
+		if !ok {
+			logger.Warn("deliverReplicationJob: backend %s no longer exists, skipping %s", backendID, id)
+			continue
+		}
+		if b.GetState() != backend.StateUp || b.Config.InMaintenance(time.Now()) {
+			remaining = append(remaining, backendID)
+			continue
+		}
+
+		if ctx.Err() != nil {
+			// Объект перезаписан или очередь остановлена
+			break
+		}
+
+		r.semaphores.put <- struct{}{}
+		putCtx, cancelPut := context.WithTimeout(ctx, r.config.OperationTimeout)
+		result := r.putToBackend(putCtx, b, job.req, io.NewSectionReader(file, 0, job.req.ContentLength))
+		cancelPut()
+		<-r.semaphores.put
+		if ctx.Err() != nil {
+			logger.Debug("deliverReplicationJob: replication of %s to backend %s cancelled", id, backendID)
+			break
+		}
+		r.reportBackendResult(result)
+
+		if result.Err != nil {
+			logger.Warn("deliverReplicationJob: failed to replicate %s to backend %s (attempt %d): %v",
+				id, backendID, job.attempts+1, result.Err)
+			remaining = append(remaining, backendID)
+			failed = true
+			continue
+		}
+		logger.Info("deliverReplicationJob: %s replicated to backend %s", id, backendID)
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	job.cancel = nil
+	if q.jobs[id] != job {
+		// Объект перезаписан во время доставки
+		return
+	}
+	job.pending = remaining
+	if len(remaining) == 0 {
+		q.removeJob(id, job, "")
+		return
+	}
+
+	delay := r.config.AsyncRetryDelay
+	if failed {
+		job.attempts++
+		if r.config.AsyncMaxAttempts > 0 && job.attempts >= r.config.AsyncMaxAttempts {
+			logger.Error("deliverReplicationJob: giving up on %s after %d attempts, backends %v stay out of sync",
+				id, job.attempts, remaining)
+			q.removeJob(id, job, "max_attempts")
+			return
+		}
+		for i := 1; i < job.attempts && delay < r.config.AsyncMaxRetryDelay; i++ {
+			delay *= 2
+		}
+		delay = min(delay, r.config.AsyncMaxRetryDelay)
+	}
+	job.nextAttempt = time.Now().Add(delay)
+}
+
+// queuedReplications возвращает число объектов в очереди асинхронной репликации
+func (r *Replicator) queuedReplications() int {
+	if r.replicationQueue == nil {
+		return 0
+	}
+	r.replicationQueue.mu.Lock()
+	defer r.replicationQueue.mu.Unlock()
+	return len(r.replicationQueue.jobs)
+}
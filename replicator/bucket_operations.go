@@ -0,0 +1,230 @@
+package replicator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+
+	"s3proxy/apigw"
+	"s3proxy/backend"
+	"s3proxy/logger"
+	"s3proxy/routing"
+)
+
+// bucketOperation выполняет операцию без тела на одном бэкенде
+// (операции над бакетом, установка и удаление тегов объекта)
+type bucketOperation func(ctx context.Context, b *backend.Backend) *backend.BackendResult
+
+// bucketErrorClassifier сообщает, что ошибка бэкенда означает достигнутый результат операции
+type bucketErrorClassifier func(err error) bool
+
+// isBucketAlreadyExistsError возвращает true, если бакет уже существует на бэкенде
+func isBucketAlreadyExistsError(err error) bool {
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) {
+		switch apiErr.ErrorCode() {
+		case "BucketAlreadyOwnedByYou", "BucketAlreadyExists":
+			return true
+		}
+	}
+	return false
+}
+
+// isBucketNotEmptyError возвращает true, если бакет бэкенда не удален, потому что в нем есть объекты
+func isBucketNotEmptyError(err error) bool {
+	var apiErr smithy.APIError
+	return errors.As(err, &apiErr) && apiErr.ErrorCode() == "BucketNotEmpty"
+}
+
+// bucketNotEmptyMessage - стандартное сообщение S3 для ошибки BucketNotEmpty
+const bucketNotEmptyMessage = "The bucket you tried to delete is not empty"
+
+// performBucketSync выполняет операцию над бакетом (или тегами объекта) на всех бэкендах (для ack=one и ack=all).
+// Бэкенды отображают виртуальный бакет на свой собственный (BackendConfig.Bucket),
+// поэтому операция всегда применяется к бакету из конфигурации бэкенда.
+// Ошибки, для которых accepted возвращает true, считаются успехом и не влияют на состояние бэкенда.
+func (r *Replicator) performBucketSync(opCtx *operationContext, backends []*backend.Backend, policy routing.WriteOperationPolicy, op bucketOperation, accepted bucketErrorClassifier, success *apigw.S3Response) *apigw.S3Response {
+	logger.Debug("performBucketSync: starting %s for %d backends with policy %s", opCtx.operation, len(backends), policy.AckLevel)
+
+	resultsChan := make(chan *backend.BackendResult, len(backends))
+
+	var wg sync.WaitGroup
+	for _, backend_iter := range backends {
+		wg.Add(1)
+		go func(b *backend.Backend) {
+			defer wg.Done()
+
+			r.semaphores.bucket <- struct{}{}
+			defer func() { <-r.semaphores.bucket }()
+
+			// Для политики 'one' операции должны продолжаться в фоне,
+			// даже если исходный запрос завершился.
+			backendCtx := opCtx.ctx
+			if policy.AckLevel == "one" {
+				backendCtx = context.WithoutCancel(opCtx.ctx)
+			}
+
+			result := op(backendCtx, b)
+			if result.Err != nil && accepted != nil && accepted(result.Err) {
+				logger.Info("performBucketSync: %s on backend %s treated as success: %v", opCtx.operation, b.ID, result.Err)
+				result.Err = nil
+			}
+			r.reportBackendResult(result)
+
+			resultsChan <- result
+		}(backend_iter)
+	}
+
+	go func() {
+		wg.Wait()
+		close(resultsChan)
+	}()
+
+	results := r.withAckAllTimeout(opCtx, resultsChan, policy, backends)
+	results = r.withPreferredBackend(opCtx, results, policy, backends)
+	return r.aggregateBucketResults(opCtx, results, policy, len(backends), success)
+}
+
+// aggregateBucketResults агрегирует результаты операций над бакетом
+func (r *Replicator) aggregateBucketResults(opCtx *operationContext, resultsChan <-chan *backend.BackendResult, policy routing.WriteOperationPolicy, totalBackends int, success *apigw.S3Response) *apigw.S3Response {
+	successCount := 0
+	notEmptyCount := 0
+	var lastError error
+
+	for result := range resultsChan {
+		if result.Err != nil {
+			lastError = result.Err
+			if isBucketNotEmptyError(result.Err) {
+				notEmptyCount++
+			}
+			logger.Debug("aggregateBucketResults: %s failed on backend %s: %v", opCtx.operation, result.BackendID, result.Err)
+			continue
+		}
+
+		successCount++
+		logger.Debug("aggregateBucketResults: %s succeeded on backend %s (%d/%d)", opCtx.operation, result.BackendID, successCount, totalBackends)
+
+		if policy.AckLevel == "one" {
+			return success
+		}
+	}
+
+	if policy.AckLevel == "all" && successCount == totalBackends {
+		return success
+	}
+
+	logger.Error("aggregateBucketResults: %s succeeded on %d of %d backends with policy %s", opCtx.operation, successCount, totalBackends, policy.AckLevel)
+	if notEmptyCount > 0 {
+		// Бакет не пуст хотя бы на одном бэкенде: это ошибка клиента, а не отказ бэкендов.
+		// На бэкендах, где бакет был пуст, он уже удален - об этом сообщается в тексте ошибки.
+		message := bucketNotEmptyMessage
+		if successCount > 0 {
+			message = fmt.Sprintf("%s on %d of %d backends; it was deleted on %d backend(s)",
+				bucketNotEmptyMessage, notEmptyCount, totalBackends, successCount)
+		}
+		return r.createErrorResponse(opCtx.requestID, opCtx.Resource(), http.StatusConflict, "BucketNotEmpty", message)
+	}
+	if successCount == 0 && lastError != nil {
+		return r.createErrorResponse(opCtx.requestID, opCtx.Resource(), http.StatusServiceUnavailable, "ServiceUnavailable", lastError.Error())
+	}
+	return r.createErrorResponse(opCtx.requestID, opCtx.Resource(), http.StatusInternalServerError, "InternalError",
+		fmt.Sprintf("Operation %s succeeded on %d of %d backends", opCtx.operation, successCount, totalBackends))
+}
+
+// performCreateBucketOnBackend создает бакет бэкенда
+func (r *Replicator) performCreateBucketOnBackend(ctx context.Context, b *backend.Backend) *backend.BackendResult {
+	startTime := time.Now()
+
+	ctx, cancel := context.WithTimeout(ctx, r.config.OperationTimeout)
+	defer cancel()
+
+	input := &s3.CreateBucketInput{Bucket: aws.String(b.Config.Bucket)}
+	// us-east-1 не принимает LocationConstraint
+	if b.Config.Region != "" && b.Config.Region != "us-east-1" {
+		input.CreateBucketConfiguration = &types.CreateBucketConfiguration{
+			LocationConstraint: types.BucketLocationConstraint(b.Config.Region),
+		}
+	}
+
+	logger.Debug("performCreateBucketOnBackend: creating bucket %s on backend %s", b.Config.Bucket, b.ID)
+	response, err := b.S3Client.CreateBucket(ctx, input)
+	if err != nil {
+		logger.Error("performCreateBucketOnBackend: failed on backend %s: %v", b.ID, err)
+	}
+
+	return &backend.BackendResult{
+		BackendID:  b.ID,
+		Method:     "CREATE_BUCKET",
+		Response:   response,
+		Err:        err,
+		Duration:   time.Since(startTime),
+		StatusCode: http.StatusOK,
+	}
+}
+
+// findNonEmptyBucket проверяет через ListObjectsV2 (max-keys=1), есть ли объекты в бакетах
+// бэкендов, и возвращает ID первого бэкенда с непустым бакетом. Бэкенды, на которых
+// проверка не удалась, не учитываются: их ответ определит само удаление.
+func (r *Replicator) findNonEmptyBucket(opCtx *operationContext, backends []*backend.Backend) (string, bool) {
+	ctx, cancel := context.WithTimeout(opCtx.ctx, r.config.OperationTimeout)
+	defer cancel()
+
+	nonEmpty := make(chan string, len(backends))
+	var wg sync.WaitGroup
+	for _, b := range backends {
+		wg.Add(1)
+		go func(b *backend.Backend) {
+			defer wg.Done()
+			output, err := b.S3Client.ListObjectsV2(ctx, &s3.ListObjectsV2Input{
+				Bucket:  aws.String(b.Config.Bucket),
+				MaxKeys: aws.Int32(1),
+			})
+			if err != nil {
+				logger.Warn("findNonEmptyBucket: failed to list bucket %s on backend %s: %v", b.Config.Bucket, b.ID, err)
+				return
+			}
+			if len(output.Contents) > 0 {
+				nonEmpty <- b.ID
+			}
+		}(b)
+	}
+	wg.Wait()
+	close(nonEmpty)
+
+	id, ok := <-nonEmpty
+	return id, ok
+}
+
+// performDeleteBucketOnBackend удаляет бакет бэкенда
+func (r *Replicator) performDeleteBucketOnBackend(ctx context.Context, b *backend.Backend) *backend.BackendResult {
+	startTime := time.Now()
+
+	ctx, cancel := context.WithTimeout(ctx, r.config.OperationTimeout)
+	defer cancel()
+
+	logger.Debug("performDeleteBucketOnBackend: deleting bucket %s on backend %s", b.Config.Bucket, b.ID)
+	response, err := b.S3Client.DeleteBucket(ctx, &s3.DeleteBucketInput{Bucket: aws.String(b.Config.Bucket)})
+	if err != nil {
+		logger.Error("performDeleteBucketOnBackend: failed on backend %s: %v", b.ID, err)
+	} else {
+		// Следующая запись снова создаст бакет (AutoCreateBucket)
+		r.readyBuckets.Delete(readyBucketKey(b))
+	}
+
+	return &backend.BackendResult{
+		BackendID:  b.ID,
+		Method:     "DELETE_BUCKET",
+		Response:   response,
+		Err:        err,
+		Duration:   time.Since(startTime),
+		StatusCode: http.StatusNoContent,
+	}
+}
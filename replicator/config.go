@@ -2,6 +2,7 @@ package replicator
 
 import (
 	"fmt"
+	"mime"
 	"time"
 )
 
@@ -15,10 +16,25 @@ type Config struct {
 	
 	// MaxConcurrentOperations - максимальное количество одновременных операций
 	MaxConcurrentOperations int `yaml:"max_concurrent_operations"`
+
+	// Ограничения одновременных запросов к бэкендам по категориям операций: у каждой
+	// категории свой семафор, и всплеск одной (например, загрузка частей) не задерживает
+	// остальные. 0 - MaxConcurrentOperations.
+	MaxConcurrentPuts      int `yaml:"max_concurrent_puts"`       // PutObject и доставка из очереди репликации
+	MaxConcurrentDeletes   int `yaml:"max_concurrent_deletes"`    // DeleteObject
+	MaxConcurrentParts     int `yaml:"max_concurrent_parts"`      // UploadPart
+	MaxConcurrentBucketOps int `yaml:"max_concurrent_bucket_ops"` // CreateBucket и DeleteBucket
 	
 	// OperationTimeout - таймаут для операций с бэкендами
 	OperationTimeout time.Duration `yaml:"operation_timeout"`
 	
+	// AckAllTimeout - сколько агрегация результатов ждет ответа всех бэкендов при ack=all.
+	// Не ответившие за это время бэкенды считаются неуспешными, и клиент сразу получает
+	// ошибку, не дожидаясь OperationTimeout зависшего бэкенда. 0 - ждать без ограничения:
+	// OperationTimeout ограничивает каждую попытку записи на бэкенд (для PUT - вместе с приемом
+	// тела от клиента), а не ожидание ответа в целом, поэтому клиент может ждать дольше.
+	AckAllTimeout time.Duration `yaml:"ack_all_timeout"`
+	
 	// RetryAttempts - количество попыток повтора при ошибках
 	RetryAttempts int `yaml:"retry_attempts"`
 	
@@ -27,6 +43,89 @@ type Config struct {
 	
 	// BufferSize - размер буфера для потоковых операций
 	BufferSize int `yaml:"buffer_size"`
+
+	// CloneBufferSize - объем тела PUT или потоковой части multipart upload в байтах,
+	// который каждый бэкенд может отставать от самого быстрого. Быстрые бэкенды не ждут
+	// медленного, пока его отставание меньше буфера, а при ack=one ответ возвращается,
+	// пока медленный бэкенд дочитывает буфер. 0 - синхронная передача через io.Pipe
+	// (скорость самого медленного бэкенда).
+	CloneBufferSize int64 `yaml:"clone_buffer_size"`
+
+	// SpillThreshold - размер тела PUT в байтах, начиная с которого при нескольких бэкендах
+	// тело сначала целиком записывается во временный файл, а каждый бэкенд читает файл
+	// независимо. Скорости бэкендов не влияют друг на друга, память не зависит от размера
+	// объекта, но запись на бэкенды начинается после приема всего тела. 0 - выключено.
+	SpillThreshold int64 `yaml:"spill_threshold"`
+
+	// SpillDir - каталог для временных файлов SpillThreshold (по умолчанию системный)
+	SpillDir string `yaml:"spill_dir"`
+
+	// MaxBufferedPartSize - максимальный размер части multipart upload, которая буферизуется
+	// в памяти целиком перед отправкой на бэкенды. Части большего размера (или неизвестного
+	// размера) передаются потоком так же, как тело PUT (см. CloneBufferSize).
+	// 0 - всегда передавать потоком.
+	MaxBufferedPartSize int64 `yaml:"max_buffered_part_size"`
+
+	// AbortOnClientDisconnect - при отключении клиента до окончания передачи тела PUT
+	// прерывать запись на бэкенды и удалять частично записанные объекты
+	AbortOnClientDisconnect bool `yaml:"abort_on_client_disconnect"`
+
+	// BucketOperations - выполнять CreateBucket и DeleteBucket клиентов. Операции применяются
+	// к бакетам из конфигурации бэкендов независимо от имени в запросе, поэтому по умолчанию
+	// выключены (ответ 403 AccessDenied); включать их стоит вместе с bucket_mode: strict,
+	// в котором запрос к чужому бакету получает NoSuchBucket.
+	BucketOperations bool `yaml:"bucket_operations"`
+
+	// IdempotentCreateBucket - считать ответы BucketAlreadyOwnedByYou/BucketAlreadyExists
+	// успешным созданием бакета (бакет на бэкенде уже есть)
+	IdempotentCreateBucket bool `yaml:"idempotent_create_bucket"`
+
+	// VerifyETag - при ack=all вычислять MD5 тела PUT в прокси и сверять с ETag, который
+	// вернул каждый бэкенд (кроме объектов SSE-KMS и SSE-C). Бэкенд с другим ETag
+	// считается неуспешным, и запись завершается ошибкой.
+	VerifyETag bool `yaml:"verify_etag"`
+
+	// GuessContentType - если клиент не передал Content-Type в PUT или CreateMultipartUpload,
+	// определять его по расширению ключа (index.html - text/html). Иначе бэкенд сохраняет
+	// объект как binary/octet-stream, и браузер не сможет его отобразить.
+	GuessContentType bool `yaml:"guess_content_type"`
+
+	// DefaultContentType - Content-Type записи без заголовка, если его не удалось определить
+	// по расширению (или GuessContentType выключен). Пусто - заголовок не передается.
+	DefaultContentType string `yaml:"default_content_type"`
+
+	// CheckBucketEmptyBeforeDelete - перед DeleteBucket проверять, что бакет пуст на всех
+	// бэкендах, и при наличии объектов хотя бы на одном отвечать 409 BucketNotEmpty, ничего
+	// не удаляя. Без проверки пустые бакеты удаляются, а непустые остаются, и клиент тоже
+	// получает BucketNotEmpty.
+	CheckBucketEmptyBeforeDelete bool `yaml:"check_bucket_empty_before_delete"`
+
+	// AsyncReplication - при ack=one копировать тело PUT во временный файл и после ответа
+	// клиенту доставлять объект бэкендам, которые были недоступны или ответили ошибкой,
+	// с повторами до их восстановления. Очередь хранится в памяти процесса.
+	AsyncReplication bool `yaml:"async_replication"`
+
+	// AsyncQueueSize - максимальное число объектов в очереди асинхронной репликации.
+	// Объекты сверх лимита не реплицируются (метрика s3proxy_replication_queue_dropped_total).
+	AsyncQueueSize int `yaml:"async_queue_size"`
+
+	// AsyncQueueDir - каталог временных файлов очереди (по умолчанию системный)
+	AsyncQueueDir string `yaml:"async_queue_dir"`
+
+	// AsyncRetryDelay - задержка перед повтором доставки после ошибки; удваивается
+	// с каждой неудачной попыткой до AsyncMaxRetryDelay
+	AsyncRetryDelay time.Duration `yaml:"async_retry_delay"`
+
+	// AsyncMaxRetryDelay - максимальная задержка между попытками доставки
+	AsyncMaxRetryDelay time.Duration `yaml:"async_max_retry_delay"`
+
+	// AsyncMaxAttempts - число неудачных попыток доставки, после которого объект удаляется
+	// из очереди. 0 - повторять, пока объект не будет доставлен или перезаписан.
+	AsyncMaxAttempts int `yaml:"async_max_attempts"`
+
+	// RetryAfter - значение заголовка Retry-After в ответе 503, когда для операции нет
+	// доступных бэкендов. 0 - apigw.DefaultRetryAfter.
+	RetryAfter time.Duration `yaml:"retry_after"`
 }
 
 // DefaultConfig возвращает конфигурацию по умолчанию
@@ -36,9 +135,17 @@ func DefaultConfig() *Config {
 		CleanupInterval:         1 * time.Hour,   // Очистка каждый час
 		MaxConcurrentOperations: 100,             // Максимум 100 одновременных операций
 		OperationTimeout:        30 * time.Second, // 30 секунд на операцию
+		AckAllTimeout:           0,               // ack=all ждет ответа всех бэкендов без отдельного предела
 		RetryAttempts:           3,               // 3 попытки
 		RetryDelay:              1 * time.Second, // 1 секунда между попытками
 		BufferSize:              32 * 1024,       // 32KB буфер
+		CloneBufferSize:         8 * 1024 * 1024, // Бэкенд может отставать на 8MB
+		MaxBufferedPartSize:     8 * 1024 * 1024, // Части до 8MB буферизуются
+		AbortOnClientDisconnect: true,            // Не оставляем обрезанные объекты
+		IdempotentCreateBucket:  true,            // Повторное создание бакета - не ошибка
+		AsyncQueueSize:          1000,            // До 1000 объектов в очереди репликации
+		AsyncRetryDelay:         5 * time.Second, // Первый повтор доставки через 5 секунд
+		AsyncMaxRetryDelay:      5 * time.Minute, // Повторы не реже раза в 5 минут
 	}
 }
 
@@ -56,10 +163,22 @@ func (c *Config) Validate() error {
 		return fmt.Errorf("max_concurrent_operations must be positive")
 	}
 	
+	if c.MaxConcurrentPuts < 0 || c.MaxConcurrentDeletes < 0 || c.MaxConcurrentParts < 0 || c.MaxConcurrentBucketOps < 0 {
+		return fmt.Errorf("max_concurrent_puts, max_concurrent_deletes, max_concurrent_parts and max_concurrent_bucket_ops must be non-negative")
+	}
+	
 	if c.OperationTimeout <= 0 {
 		return fmt.Errorf("operation_timeout must be positive")
 	}
 	
+	if c.AckAllTimeout < 0 {
+		return fmt.Errorf("ack_all_timeout must be non-negative")
+	}
+
+	if c.RetryAfter < 0 {
+		return fmt.Errorf("retry_after must be non-negative")
+	}
+	
 	if c.RetryAttempts < 0 {
 		return fmt.Errorf("retry_attempts must be non-negative")
 	}
@@ -71,6 +190,39 @@ func (c *Config) Validate() error {
 	if c.BufferSize <= 0 {
 		return fmt.Errorf("buffer_size must be positive")
 	}
+
+	if c.CloneBufferSize < 0 {
+		return fmt.Errorf("clone_buffer_size must be non-negative")
+	}
+
+	if c.SpillThreshold < 0 {
+		return fmt.Errorf("spill_threshold must be non-negative")
+	}
+
+	if c.MaxBufferedPartSize < 0 {
+		return fmt.Errorf("max_buffered_part_size must be non-negative")
+	}
+
+	if c.AsyncReplication {
+		if c.AsyncQueueSize <= 0 {
+			return fmt.Errorf("async_queue_size must be positive")
+		}
+		if c.AsyncRetryDelay <= 0 {
+			return fmt.Errorf("async_retry_delay must be positive")
+		}
+		if c.AsyncMaxRetryDelay < c.AsyncRetryDelay {
+			return fmt.Errorf("async_max_retry_delay must not be less than async_retry_delay")
+		}
+		if c.AsyncMaxAttempts < 0 {
+			return fmt.Errorf("async_max_attempts must be non-negative")
+		}
+	}
+
+	if c.DefaultContentType != "" {
+		if _, _, err := mime.ParseMediaType(c.DefaultContentType); err != nil {
+			return fmt.Errorf("default_content_type %q is not a valid media type: %w", c.DefaultContentType, err)
+		}
+	}
 	
 	return nil
 }
@@ -0,0 +1,37 @@
+package replicator
+
+import (
+	"mime"
+	"net/http"
+	"path"
+
+	"s3proxy/apigw"
+	"s3proxy/logger"
+)
+
+// fillContentType задает Content-Type записи, для которой клиент его не указал:
+// при GuessContentType - по расширению ключа, иначе (или если расширение неизвестно) -
+// DefaultContentType. Заголовок выставляется в запросе до выбора бэкендов, поэтому
+// учитывается и маршрутизацией по content_type_backends.
+func (r *Replicator) fillContentType(req *apigw.S3Request) {
+	if req.Headers.Get("Content-Type") != "" {
+		return
+	}
+
+	contentType := ""
+	if r.config.GuessContentType {
+		contentType = mime.TypeByExtension(path.Ext(req.Key))
+	}
+	if contentType == "" {
+		contentType = r.config.DefaultContentType
+	}
+	if contentType == "" {
+		return
+	}
+
+	logger.Debug("[%s] No Content-Type for %s, using %q", req.RequestID, req.Key, contentType)
+	if req.Headers == nil {
+		req.Headers = make(http.Header)
+	}
+	req.Headers.Set("Content-Type", contentType)
+}
@@ -0,0 +1,55 @@
+package replicator
+
+import (
+	"mime"
+	"strings"
+
+	"s3proxy/apigw"
+	"s3proxy/backend"
+	"s3proxy/logger"
+	"s3proxy/routing"
+)
+
+// contentTypeBackends возвращает набор бэкендов политики для Content-Type запроса:
+// сначала ищется точное совпадение типа, затем группа "тип/*". false - тип не
+// настроен, запись идет на все бэкенды.
+func contentTypeBackends(req *apigw.S3Request, policy routing.WriteOperationPolicy) ([]string, bool) {
+	if len(policy.ContentTypeBackends) == 0 {
+		return nil, false
+	}
+	mediaType, _, err := mime.ParseMediaType(req.Headers.Get("Content-Type"))
+	if err != nil {
+		return nil, false
+	}
+	if ids, ok := policy.ContentTypeBackends[mediaType]; ok {
+		return ids, true
+	}
+	if slash := strings.IndexByte(mediaType, '/'); slash > 0 {
+		if ids, ok := policy.ContentTypeBackends[mediaType[:slash]+"/*"]; ok {
+			return ids, true
+		}
+	}
+	return nil, false
+}
+
+// selectWriteBackends оставляет из живых бэкендов те, на которые политика направляет
+// объекты с Content-Type запроса. Если тип не настроен, возвращаются все живые бэкенды.
+func selectWriteBackends(req *apigw.S3Request, policy routing.WriteOperationPolicy, liveBackends []*backend.Backend) []*backend.Backend {
+	ids, ok := contentTypeBackends(req, policy)
+	if !ok {
+		return liveBackends
+	}
+
+	selected := make([]*backend.Backend, 0, len(ids))
+	for _, b := range liveBackends {
+		for _, id := range ids {
+			if b.ID == id {
+				selected = append(selected, b)
+				break
+			}
+		}
+	}
+	logger.Debug("[%s] Content-Type %q routed to backends %v (%d live)",
+		req.RequestID, req.Headers.Get("Content-Type"), ids, len(selected))
+	return selected
+}
@@ -29,14 +29,15 @@ func (r *Replicator) performDeleteSync(opCtx *operationContext, req *apigw.S3Req
 			defer wg.Done()
 			
 			// Ограничиваем количество одновременных операций
-			r.semaphore <- struct{}{}
-			defer func() { <-r.semaphore }()
+			r.semaphores.delete <- struct{}{}
+			defer func() { <-r.semaphores.delete }()
 			
 			// Для политики 'one' операции должны продолжаться в фоне,
-			// даже если исходный запрос завершился. Используем фоновый контекст.
+			// даже если исходный запрос завершился. Используем контекст без отмены
+			// (идентификатор запроса сохраняется).
 			backendCtx := opCtx.ctx
 			if policy.AckLevel == "one" {
-				backendCtx = context.Background()
+				backendCtx = context.WithoutCancel(opCtx.ctx)
 			}
 
 			result := r.performDeleteFromBackend(backendCtx, b, req)
@@ -54,7 +55,9 @@ func (r *Replicator) performDeleteSync(opCtx *operationContext, req *apigw.S3Req
 	}()
 	
 	// Агрегируем результаты в соответствии с политикой
-	return r.aggregateDeleteResults(resultsChan, policy, len(backends))
+	results := r.withAckAllTimeout(opCtx, resultsChan, policy, backends)
+	results = r.withPreferredBackend(opCtx, results, policy, backends)
+	return r.aggregateDeleteResults(opCtx, results, policy, len(backends))
 }
 
 // performDeleteFromBackend выполняет DELETE операцию на одном бэкенде
@@ -110,7 +113,7 @@ func (r *Replicator) performDeleteFromBackend(ctx context.Context, b *backend.Ba
 }
 
 // aggregateDeleteResults агрегирует результаты DELETE операций
-func (r *Replicator) aggregateDeleteResults(resultsChan <-chan *backend.BackendResult, policy routing.WriteOperationPolicy, totalBackends int) *apigw.S3Response {
+func (r *Replicator) aggregateDeleteResults(opCtx *operationContext, resultsChan <-chan *backend.BackendResult, policy routing.WriteOperationPolicy, totalBackends int) *apigw.S3Response {
 	successCount := 0
 	errorCount := 0
 	var firstSuccessResult *backend.BackendResult
@@ -148,7 +151,7 @@ func (r *Replicator) aggregateDeleteResults(resultsChan <-chan *backend.BackendR
 			return r.convertDeleteResultToResponse(firstSuccessResult)
 		} else {
 			logger.Error("aggregateDeleteResults: not all backends succeeded for ack=all policy (%d/%d)", successCount, totalBackends)
-			return r.createErrorResponse(http.StatusInternalServerError, "InternalError", "Failed to delete object from all backends")
+			return r.createErrorResponse(opCtx.requestID, opCtx.Resource(), http.StatusInternalServerError, "InternalError", "Failed to delete object from all backends")
 		}
 	}
 	
@@ -156,14 +159,14 @@ func (r *Replicator) aggregateDeleteResults(resultsChan <-chan *backend.BackendR
 	if policy.AckLevel == "one" && successCount == 0 {
 		logger.Error("aggregateDeleteResults: no backends succeeded for ack=one policy")
 		if lastError != nil {
-			return r.createErrorResponse(http.StatusServiceUnavailable, "ServiceUnavailable", lastError.Error())
+			return r.createErrorResponse(opCtx.requestID, opCtx.Resource(), http.StatusServiceUnavailable, "ServiceUnavailable", lastError.Error())
 		}
-		return r.createErrorResponse(http.StatusServiceUnavailable, "ServiceUnavailable", "Failed to delete from any backend")
+		return r.createErrorResponse(opCtx.requestID, opCtx.Resource(), http.StatusServiceUnavailable, "ServiceUnavailable", "Failed to delete from any backend")
 	}
 	
 	// Не должны сюда попасть
 	logger.Error("aggregateDeleteResults: unexpected code path reached")
-	return r.createErrorResponse(http.StatusInternalServerError, "InternalError", "Unexpected error in result aggregation")
+	return r.createErrorResponse(opCtx.requestID, opCtx.Resource(), http.StatusInternalServerError, "InternalError", "Unexpected error in result aggregation")
 }
 
 // convertDeleteResultToResponse преобразует результат DELETE в S3Response
@@ -0,0 +1,93 @@
+package replicator
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"s3proxy/backend"
+)
+
+// maxErrorSampleLength - максимальная длина примера сообщения об ошибке в сводке
+const maxErrorSampleLength = 200
+
+// errorSummary собирает ошибки бэкендов одной операции по классам, чтобы в ответе
+// и логах было видно все причины сбоя (например, один бэкенд не ответил вовремя,
+// а другой вернул 403), а не только последнюю ошибку.
+type errorSummary struct {
+	total   int
+	classes map[string]*errorClassSummary
+}
+
+// errorClassSummary - ошибки одного класса: количество и первый пример
+type errorClassSummary struct {
+	count     int
+	backendID string
+	sample    string
+}
+
+// add учитывает ошибку бэкенда из результата операции
+func (s *errorSummary) add(result *backend.BackendResult) {
+	if result == nil || result.Err == nil {
+		return
+	}
+	if s.classes == nil {
+		s.classes = make(map[string]*errorClassSummary)
+	}
+
+	s.total++
+	class := classifyError(result.Err)
+	if summary, ok := s.classes[class]; ok {
+		summary.count++
+		return
+	}
+
+	sample := result.Err.Error()
+	if len(sample) > maxErrorSampleLength {
+		sample = sample[:maxErrorSampleLength] + "..."
+	}
+	s.classes[class] = &errorClassSummary{count: 1, backendID: result.BackendID, sample: sample}
+}
+
+// Empty сообщает, что ошибок не было
+func (s *errorSummary) Empty() bool {
+	return s.total == 0
+}
+
+// String формирует сводку вида
+// "2 backend error(s): AccessDenied x1 (backend-2: ...); Timeout x1 (backend-1: ...)"
+func (s *errorSummary) String() string {
+	if s.total == 0 {
+		return "no backend errors"
+	}
+
+	names := make([]string, 0, len(s.classes))
+	for name := range s.classes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	parts := make([]string, 0, len(names))
+	for _, name := range names {
+		summary := s.classes[name]
+		parts = append(parts, fmt.Sprintf("%s x%d (%s: %s)", name, summary.count, summary.backendID, summary.sample))
+	}
+	return fmt.Sprintf("%d backend error(s): %s", s.total, strings.Join(parts, "; "))
+}
+
+// classifyError определяет класс ошибки бэкенда: к классам backend.ErrorClass добавляются
+// ошибки самого репликатора (таймаут ack=all, BadDigest, несовпадение ETag, проверка после записи)
+func classifyError(err error) string {
+	switch {
+	case errors.Is(err, errAckAllTimeout):
+		return "Timeout"
+	case errors.Is(err, ErrBadDigest):
+		return "BadDigest"
+	case errors.Is(err, ErrETagMismatch):
+		return "ETagMismatch"
+	case errors.Is(err, ErrReadAfterWriteMismatch):
+		return "ReadAfterWriteMismatch"
+	}
+	return backend.ErrorClass(err)
+}
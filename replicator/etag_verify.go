@@ -0,0 +1,105 @@
+package replicator
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"strings"
+	"sync"
+
+	"s3proxy/apigw"
+	"s3proxy/backend"
+	"s3proxy/logger"
+	"s3proxy/routing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+)
+
+// ErrETagMismatch возвращается для бэкенда, ETag которого не совпал с MD5 тела объекта,
+// вычисленным прокси (см. Config.VerifyETag)
+var ErrETagMismatch = errors.New("backend ETag does not match MD5 of the object")
+
+// bodyDigest вычисляет MD5 тела PUT один раз, при чтении исходного тела клиента
+type bodyDigest struct {
+	reader io.Reader
+
+	mu   sync.Mutex
+	hash hash.Hash
+}
+
+// newBodyDigest создает обертку над телом запроса, вычисляющую MD5
+func newBodyDigest(reader io.Reader) *bodyDigest {
+	return &bodyDigest{reader: reader, hash: md5.New()}
+}
+
+// Read реализует io.Reader
+func (d *bodyDigest) Read(p []byte) (int, error) {
+	n, err := d.reader.Read(p)
+	d.mu.Lock()
+	d.hash.Write(p[:n])
+	d.mu.Unlock()
+	return n, err
+}
+
+// ETag возвращает ожидаемый ETag объекта (MD5 в кавычках). Значение окончательное,
+// когда тело прочитано до конца - например, после успешной записи на любой бэкенд.
+func (d *bodyDigest) ETag() string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	return `"` + hex.EncodeToString(d.hash.Sum(nil)) + `"`
+}
+
+// shouldVerifyETag сообщает, что ETag бэкендов нужно сверять с MD5 тела: проверка включена,
+// политика ack=all и ETag объекта равен MD5 (нет шифрования SSE-KMS или SSE-C)
+func (r *Replicator) shouldVerifyETag(req *apigw.S3Request, policy routing.WriteOperationPolicy) bool {
+	if !r.config.VerifyETag || policy.AckLevel != "all" {
+		return false
+	}
+	if strings.HasPrefix(req.Headers.Get("X-Amz-Server-Side-Encryption"), "aws:kms") ||
+		req.Headers.Get("X-Amz-Server-Side-Encryption-Customer-Algorithm") != "" {
+		logger.Debug("shouldVerifyETag: ETag of SSE-KMS/SSE-C object %s is not an MD5, skipping verification", req.Key)
+		return false
+	}
+	return true
+}
+
+// withETagVerification сверяет ETag успешных ответов бэкендов с MD5 тела, вычисленным прокси.
+// Ответ с другим ETag превращается в ошибку ErrETagMismatch, и при ack=all запись
+// завершается ошибкой. Ответы без ETag и объекты, зашифрованные бэкендом через KMS, не проверяются.
+func (r *Replicator) withETagVerification(opCtx *operationContext, resultsChan <-chan *backend.BackendResult, digest *bodyDigest) <-chan *backend.BackendResult {
+	if digest == nil {
+		return resultsChan
+	}
+
+	out := make(chan *backend.BackendResult, cap(resultsChan))
+	go func() {
+		defer close(out)
+		for result := range resultsChan {
+			output, ok := result.Response.(*s3.PutObjectOutput)
+			if result.Err != nil || !ok || output.ETag == nil || isKMSEncrypted(output.ServerSideEncryption) {
+				out <- result
+				continue
+			}
+
+			if expected := digest.ETag(); aws.ToString(output.ETag) != expected {
+				logger.Error("[%s] %s: ETag %s from backend %s does not match computed %s",
+					opCtx.requestID, opCtx.operation, aws.ToString(output.ETag), result.BackendID, expected)
+				mismatch := *result
+				mismatch.Err = fmt.Errorf("%w: got %s, expected %s", ErrETagMismatch, aws.ToString(output.ETag), expected)
+				result = &mismatch
+			}
+			out <- result
+		}
+	}()
+	return out
+}
+
+// isKMSEncrypted сообщает, что бэкенд зашифровал объект через KMS, и его ETag не равен MD5
+func isKMSEncrypted(sse types.ServerSideEncryption) bool {
+	return sse == types.ServerSideEncryptionAwsKms || sse == types.ServerSideEncryptionAwsKmsDsse
+}
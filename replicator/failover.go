@@ -0,0 +1,118 @@
+package replicator
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"net/http"
+	"sort"
+	"time"
+
+	"s3proxy/apigw"
+	"s3proxy/backend"
+	"s3proxy/logger"
+	"s3proxy/routing"
+)
+
+// performPutFailover выполняет PUT в режиме failover (ack=one, WriteOperationPolicy.Failover):
+// объект записывается на первый бэкенд в порядке failoverOrder, а следующий бэкенд
+// пробуется, только если предыдущий ответил ошибкой. Тело запроса принимается целиком
+// (в память или во временный файл при SpillThreshold), чтобы его можно было отправить повторно.
+func (r *Replicator) performPutFailover(opCtx *operationContext, req *apigw.S3Request, backends []*backend.Backend, policy routing.WriteOperationPolicy) *apigw.S3Response {
+	ordered := failoverOrder(backends, policy.PreferredBackend)
+	logger.Debug("[%s] performPutFailover: %d candidate backends, primary %s", opCtx.requestID, len(ordered), ordered[0].ID)
+
+	readers, cleanup, err := r.bufferFailoverBody(req, len(ordered))
+	if err != nil {
+		logger.Error("[%s] performPutFailover: failed to receive request body: %v", opCtx.requestID, err)
+		return r.createErrorResponse(opCtx.requestID, opCtx.Resource(), http.StatusBadRequest, "IncompleteBody",
+			"You did not provide the number of bytes specified by the Content-Length HTTP header")
+	}
+	defer cleanup()
+
+	var errs errorSummary
+	for i, b := range ordered {
+		r.semaphores.put <- struct{}{}
+		result := r.putToBackend(opCtx.ctx, b, req, readers[i])
+		<-r.semaphores.put
+		r.reportBackendResult(result)
+
+		if result.Err == nil {
+			if i > 0 {
+				logger.Warn("[%s] performPutFailover: %s/%s written to failover backend %s: %s",
+					opCtx.requestID, req.Bucket, req.Key, b.ID, errs.String())
+			}
+			return r.convertPutResultToResponse(result)
+		}
+
+		// Тело не совпало с контрольной суммой клиента - ошибка клиента, другой бэкенд ее не исправит
+		if errors.Is(result.Err, ErrBadDigest) {
+			return r.createErrorResponse(opCtx.requestID, opCtx.Resource(), http.StatusBadRequest, "BadDigest", badDigestMessage(result.Err))
+		}
+		// Условие записи не выполнено: следующий бэкенд мог бы принять запись вопреки ему
+		if isPreconditionFailedError(result.Err) {
+			return r.createErrorResponse(opCtx.requestID, opCtx.Resource(), http.StatusPreconditionFailed, "PreconditionFailed", preconditionFailedMessage)
+		}
+		errs.add(result)
+		if opCtx.ctx.Err() != nil {
+			break
+		}
+		logger.Warn("[%s] performPutFailover: backend %s failed: %v", opCtx.requestID, b.ID, result.Err)
+	}
+
+	logger.Error("[%s] performPutFailover: no backends succeeded: %s", opCtx.requestID, errs.String())
+	return r.createErrorResponse(opCtx.requestID, opCtx.Resource(), http.StatusServiceUnavailable, "ServiceUnavailable",
+		"Failed to write to any backend: "+errs.String())
+}
+
+// bufferFailoverBody принимает тело запроса и возвращает count reader'ов над ним - по одному
+// на попытку записи. Тела от SpillThreshold записываются во временный файл, остальные
+// хранятся в памяти. Возвращаемую функцию нужно вызвать после завершения всех попыток.
+func (r *Replicator) bufferFailoverBody(req *apigw.S3Request, count int) ([]io.Reader, func(), error) {
+	readers := make([]io.Reader, count)
+	if req.Body == nil {
+		for i := range readers {
+			readers[i] = bytes.NewReader(nil)
+		}
+		return readers, func() {}, nil
+	}
+	if r.config.SpillThreshold > 0 && count > 1 && req.ContentLength >= r.config.SpillThreshold {
+		return r.spillBody(req.Body, count)
+	}
+
+	data, err := io.ReadAll(req.Body)
+	if err != nil {
+		return nil, nil, err
+	}
+	for i := range readers {
+		readers[i] = bytes.NewReader(data)
+	}
+	return readers, func() {}, nil
+}
+
+// failoverOrder упорядочивает бэкенды для записи в режиме failover: preferred (если задан
+// и доступен) первым, далее по убыванию веса, при равном весе - по возрастанию
+// сглаженной задержки
+func failoverOrder(backends []*backend.Backend, preferred string) []*backend.Backend {
+	latencies := make(map[string]time.Duration, len(backends))
+	for _, b := range backends {
+		latencies[b.ID] = b.GetAverageLatency()
+	}
+
+	sorted := make([]*backend.Backend, len(backends))
+	copy(sorted, backends)
+	sort.Slice(sorted, func(i, j int) bool {
+		if pi, pj := sorted[i].ID == preferred, sorted[j].ID == preferred; pi != pj {
+			return pi
+		}
+		if wi, wj := sorted[i].Config.Weight, sorted[j].Config.Weight; wi != wj {
+			return wi > wj
+		}
+		li, lj := latencies[sorted[i].ID], latencies[sorted[j].ID]
+		if li != lj {
+			return li < lj
+		}
+		return sorted[i].ID < sorted[j].ID
+	})
+	return sorted
+}
@@ -0,0 +1,49 @@
+package replicator
+
+import (
+	"sync"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Metrics - метрики модуля репликации
+type Metrics struct {
+	ReplicationQueueDepth   prometheus.Gauge       // Объекты в очереди асинхронной репликации
+	ReplicationQueueDropped *prometheus.CounterVec // Объекты, удаленные из очереди без доставки
+	MultipartUploadsAborted *prometheus.CounterVec // Загрузки, прерванные прокси (истек TTL или принудительно)
+}
+
+var (
+	metricsOnce     sync.Once
+	metricsInstance *Metrics
+)
+
+// NewMetrics возвращает метрики модуля, общие для всех экземпляров (см. backend.NewMetrics)
+func NewMetrics() *Metrics {
+	metricsOnce.Do(func() {
+		metricsInstance = &Metrics{
+			ReplicationQueueDepth: promauto.NewGauge(
+				prometheus.GaugeOpts{
+					Name: "s3proxy_replication_queue_depth",
+					Help: "Current number of objects waiting for asynchronous replication to lagging backends",
+				},
+			),
+			ReplicationQueueDropped: promauto.NewCounterVec(
+				prometheus.CounterOpts{
+					Name: "s3proxy_replication_queue_dropped_total",
+					Help: "Total number of objects removed from the replication queue without delivery",
+				},
+				[]string{"reason"},
+			),
+			MultipartUploadsAborted: promauto.NewCounterVec(
+				prometheus.CounterOpts{
+					Name: "s3proxy_multipart_uploads_aborted_total",
+					Help: "Total number of multipart uploads aborted on backends by the proxy itself",
+				},
+				[]string{"reason"},
+			),
+		}
+	})
+	return metricsInstance
+}
@@ -0,0 +1,82 @@
+package replicator
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"s3proxy/apigw"
+	"s3proxy/logger"
+)
+
+// Причины прерывания загрузки прокси (метка reason метрики s3proxy_multipart_uploads_aborted_total)
+const (
+	abortReasonExpired = "expired"
+	abortReasonForced  = "forced"
+)
+
+// abortExpiredUpload прерывает на бэкендах загрузку, маппинг которой удален по истечении
+// MultipartUploadTTL. Без этого загруженные части оставались бы на бэкендах и занимали место.
+func (r *Replicator) abortExpiredUpload(mapping *multipartUploadMapping) {
+	logger.Info("Multipart upload %s of %s/%s expired, aborting it on backends",
+		mapping.ProxyUploadID, mapping.Bucket, mapping.Key)
+	r.abortUploadOnBackends(context.Background(), mapping, abortReasonExpired)
+}
+
+// AbortUpload принудительно прерывает загрузку прокси proxyUploadID на всех ее бэкендах
+// и удаляет маппинг. Возвращает ErrUploadNotFound для неизвестного идентификатора
+// и ErrUploadCompleting, если загрузка сейчас завершается.
+func (r *Replicator) AbortUpload(ctx context.Context, proxyUploadID string) error {
+	mapping, err := r.multipartStore.TakeMapping(proxyUploadID)
+	if err != nil {
+		return err
+	}
+	logger.Info("Force-aborting multipart upload %s of %s/%s", proxyUploadID, mapping.Bucket, mapping.Key)
+	r.abortUploadOnBackends(ctx, mapping, abortReasonForced)
+	return nil
+}
+
+// abortUploadOnBackends отправляет AbortMultipartUpload живым бэкендам загрузки. Части
+// на недоступных бэкендах остаются: их удалит только правило жизненного цикла бакета.
+func (r *Replicator) abortUploadOnBackends(ctx context.Context, mapping *multipartUploadMapping, reason string) {
+	opCtx := newOperationContext(ctx, "ABORT_MULTIPART_UPLOAD", mapping.Bucket, mapping.Key)
+	req := &apigw.S3Request{Bucket: mapping.Bucket, Key: mapping.Key, Context: ctx}
+
+	targetBackends := r.filterBackendsForUpload(r.backendProvider.GetLiveBackends(), mapping)
+	if skipped := len(mapping.BackendUploads) - len(targetBackends); skipped > 0 {
+		logger.Warn("Multipart upload %s: %d of %d backends are unavailable, their parts are not aborted",
+			mapping.ProxyUploadID, skipped, len(mapping.BackendUploads))
+	}
+
+	r.performAbortMultipartUpload(opCtx, req, targetBackends, mapping)
+	NewMetrics().MultipartUploadsAborted.WithLabelValues(reason).Inc()
+}
+
+// UploadAbortHandler возвращает HTTP обработчик принудительного прерывания загрузки:
+// POST ?upload_id=<идентификатор прокси>. Подключается к серверу мониторинга.
+func (r *Replicator) UploadAbortHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		uploadID := req.URL.Query().Get("upload_id")
+		if uploadID == "" {
+			http.Error(w, "upload_id is required", http.StatusBadRequest)
+			return
+		}
+
+		err := r.AbortUpload(req.Context(), uploadID)
+		switch {
+		case err == nil:
+			w.WriteHeader(http.StatusNoContent)
+		case errors.Is(err, ErrUploadNotFound):
+			http.Error(w, err.Error(), http.StatusNotFound)
+		case errors.Is(err, ErrUploadCompleting):
+			http.Error(w, err.Error(), http.StatusConflict)
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
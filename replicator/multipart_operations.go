@@ -1,6 +1,7 @@
 package replicator
 
 import (
+	"bytes"
 	"context"
 	"fmt"
 	"io"
@@ -13,6 +14,7 @@ import (
 	"github.com/aws/aws-sdk-go-v2/aws"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go/middleware"
 	"s3proxy/apigw"
 	"s3proxy/logger"
 	"s3proxy/routing"
@@ -83,8 +85,8 @@ func (r *Replicator) performCreateMultipartUpload(ctx context.Context, b *backen
 func (r *Replicator) performUploadPartAsync(ctx context.Context, req *apigw.S3Request, backends []*backend.Backend, mapping *multipartUploadMapping, partNumber string, opCtx *operationContext) {
 	logger.Debug("performUploadPartAsync: starting async UploadPart for %d backends", len(backends))
 	
-	// Клонируем reader для каждого бэкенда
-	readers, err := r.readerCloner.Clone(req.Body, len(backends))
+	// Готовим тело части для каждого бэкенда
+	readers, err := r.clonePartBody(req, len(backends))
 	if err != nil {
 		logger.Error("performUploadPartAsync: failed to clone reader: %v", err)
 		return
@@ -109,11 +111,11 @@ func (r *Replicator) performUploadPartAsync(ctx context.Context, req *apigw.S3Re
 func (r *Replicator) performUploadPartSync(opCtx *operationContext, req *apigw.S3Request, backends []*backend.Backend, mapping *multipartUploadMapping, partNumber string, policy routing.WriteOperationPolicy) *apigw.S3Response {
 	logger.Debug("performUploadPartSync: starting sync UploadPart for %d backends with policy %s", len(backends), policy.AckLevel)
 	
-	// Клонируем reader для каждого бэкенда
-	readers, err := r.readerCloner.Clone(req.Body, len(backends))
+	// Готовим тело части для каждого бэкенда
+	readers, err := r.clonePartBody(req, len(backends))
 	if err != nil {
 		logger.Error("performUploadPartSync: failed to clone reader: %v", err)
-		return r.createErrorResponse(http.StatusInternalServerError, "InternalError", "Failed to prepare request body")
+		return r.createErrorResponse(opCtx.requestID, opCtx.Resource(), http.StatusInternalServerError, "InternalError", "Failed to prepare request body")
 	}
 	
 	// Создаем канал для результатов
@@ -127,8 +129,8 @@ func (r *Replicator) performUploadPartSync(opCtx *operationContext, req *apigw.S
 			defer wg.Done()
 			
 			// Ограничиваем количество одновременных операций
-			r.semaphore <- struct{}{}
-			defer func() { <-r.semaphore }()
+			r.semaphores.part <- struct{}{}
+			defer func() { <-r.semaphores.part }()
 			
 			result := r.performUploadPartToBackend(opCtx.ctx, b, req, reader, mapping, partNumber)
 			r.reportBackendResult(result)
@@ -145,7 +147,32 @@ func (r *Replicator) performUploadPartSync(opCtx *operationContext, req *apigw.S
 	}()
 	
 	// Агрегируем результаты в соответствии с политикой
-	return r.aggregateUploadPartResults(resultsChan, policy, len(backends))
+	results := r.withAckAllTimeout(opCtx, resultsChan, policy, backends)
+	results = r.withPreferredBackend(opCtx, results, policy, backends)
+	return r.aggregateUploadPartResults(opCtx, results, policy, len(backends))
+}
+
+// clonePartBody готовит тело части для каждого бэкенда. Части известного размера
+// не больше MaxBufferedPartSize читаются в память целиком, и каждый бэкенд получает
+// собственный reader над общим буфером. Большие части передаются потоком через
+// readerCloner, как тело PUT, чтобы не держать их в памяти целиком.
+func (r *Replicator) clonePartBody(req *apigw.S3Request, count int) ([]io.Reader, error) {
+	if req.ContentLength <= 0 || req.ContentLength > r.config.MaxBufferedPartSize {
+		logger.Debug("clonePartBody: streaming part of size %d to %d backends", req.ContentLength, count)
+		return r.readerCloner.Clone(req.Body, count)
+	}
+
+	data := make([]byte, req.ContentLength)
+	if _, err := io.ReadFull(req.Body, data); err != nil {
+		return nil, fmt.Errorf("failed to buffer part body: %w", err)
+	}
+
+	logger.Debug("clonePartBody: buffered part of size %d for %d backends", len(data), count)
+	readers := make([]io.Reader, count)
+	for i := range readers {
+		readers[i] = bytes.NewReader(data)
+	}
+	return readers, nil
 }
 
 // performUploadPartToBackend выполняет UploadPart на одном бэкенде
@@ -191,7 +218,21 @@ func (r *Replicator) performUploadPartToBackend(ctx context.Context, b *backend.
 		PartNumber: aws.Int32(int32(partNum)),
 		Body:       countingReader,
 	}
+	if req.ContentLength > 0 {
+		uploadInput.ContentLength = aws.Int64(req.ContentLength)
+	}
 	
+	// Буферизованная часть передается как есть: SDK может вычислить ее контрольную сумму,
+	// а повторная попытка начинает чтение с начала буфера.
+	// Для потоковой части нужен клиент, не вычисляющий SHA256 тела заранее.
+	clientToUse := b.S3Client
+	bufferedBody, buffered := body.(io.ReadSeeker)
+	if buffered {
+		uploadInput.Body = bufferedBody
+	} else if b.StreamingPutClient != nil {
+		clientToUse = b.StreamingPutClient
+	}
+
 	logger.Debug("performUploadPartToBackend: sending UploadPart to backend %s, uploadId=%s, partNumber=%d", b.ID, backendUploadID, partNum)
 	
 	// Выполняем запрос с повторами
@@ -201,9 +242,12 @@ func (r *Replicator) performUploadPartToBackend(ctx context.Context, b *backend.
 		if attempt > 0 {
 			logger.Debug("performUploadPartToBackend: retry attempt %d for backend %s", attempt, b.ID)
 			time.Sleep(r.config.RetryDelay)
+			if buffered {
+				bufferedBody.Seek(0, io.SeekStart)
+			}
 		}
 		
-		response, err = b.S3Client.UploadPart(ctx, uploadInput)
+		response, err = clientToUse.UploadPart(ctx, uploadInput)
 		if err == nil {
 			break
 		}
@@ -213,6 +257,9 @@ func (r *Replicator) performUploadPartToBackend(ctx context.Context, b *backend.
 	
 	duration := time.Since(startTime)
 	bytesWritten := countingReader.Count()
+	if buffered && err == nil {
+		bytesWritten = req.ContentLength
+	}
 	
 	if err != nil {
 		logger.Error("performUploadPartToBackend: failed on backend %s after %d attempts: %v", b.ID, r.config.RetryAttempts+1, err)
@@ -232,7 +279,7 @@ func (r *Replicator) performUploadPartToBackend(ctx context.Context, b *backend.
 }
 
 // aggregateUploadPartResults агрегирует результаты UploadPart операций
-func (r *Replicator) aggregateUploadPartResults(resultsChan <-chan *backend.BackendResult, policy routing.WriteOperationPolicy, totalBackends int) *apigw.S3Response {
+func (r *Replicator) aggregateUploadPartResults(opCtx *operationContext, resultsChan <-chan *backend.BackendResult, policy routing.WriteOperationPolicy, totalBackends int) *apigw.S3Response {
 	successCount := 0
 	errorCount := 0
 	var firstSuccessResult *backend.BackendResult
@@ -270,7 +317,7 @@ func (r *Replicator) aggregateUploadPartResults(resultsChan <-chan *backend.Back
 			return r.convertUploadPartResultToResponse(firstSuccessResult)
 		} else {
 			logger.Error("aggregateUploadPartResults: not all backends succeeded for ack=all policy (%d/%d)", successCount, totalBackends)
-			return r.createErrorResponse(http.StatusInternalServerError, "InternalError", "Failed to upload part to all backends")
+			return r.createErrorResponse(opCtx.requestID, opCtx.Resource(), http.StatusInternalServerError, "InternalError", "Failed to upload part to all backends")
 		}
 	}
 	
@@ -278,14 +325,14 @@ func (r *Replicator) aggregateUploadPartResults(resultsChan <-chan *backend.Back
 	if policy.AckLevel == "one" && successCount == 0 {
 		logger.Error("aggregateUploadPartResults: no backends succeeded for ack=one policy")
 		if lastError != nil {
-			return r.createErrorResponse(http.StatusServiceUnavailable, "ServiceUnavailable", lastError.Error())
+			return r.createErrorResponse(opCtx.requestID, opCtx.Resource(), http.StatusServiceUnavailable, "ServiceUnavailable", lastError.Error())
 		}
-		return r.createErrorResponse(http.StatusServiceUnavailable, "ServiceUnavailable", "Failed to upload part to any backend")
+		return r.createErrorResponse(opCtx.requestID, opCtx.Resource(), http.StatusServiceUnavailable, "ServiceUnavailable", "Failed to upload part to any backend")
 	}
 	
 	// Не должны сюда попасть
 	logger.Error("aggregateUploadPartResults: unexpected code path reached")
-	return r.createErrorResponse(http.StatusInternalServerError, "InternalError", "Unexpected error in result aggregation")
+	return r.createErrorResponse(opCtx.requestID, opCtx.Resource(), http.StatusInternalServerError, "InternalError", "Unexpected error in result aggregation")
 }
 
 // convertUploadPartResultToResponse преобразует результат UploadPart в S3Response
@@ -313,6 +360,8 @@ func (r *Replicator) performCompleteMultipartUploadSync(opCtx *operationContext,
 	
 	// Запускаем горутины для каждого бэкенда
 	var wg sync.WaitGroup
+	var mu sync.Mutex
+	completed := make(map[string]bool, len(backends))
 	for _, backend_iter := range backends {
 		wg.Add(1)
 		go func(b *backend.Backend) {
@@ -321,19 +370,32 @@ func (r *Replicator) performCompleteMultipartUploadSync(opCtx *operationContext,
 			result := r.performCompleteMultipartUploadToBackend(opCtx.ctx, b, req, mapping)
 			r.reportBackendResult(result)
 			//r.updateMetrics(b.ID, "complete_multipart_upload", result)
+			if result.Err == nil {
+				mu.Lock()
+				completed[b.ID] = true
+				mu.Unlock()
+			}
 			
 			resultsChan <- result
 		}(backend_iter)
 	}
 	
-	// Горутина для закрытия канала после завершения всех операций
+	// Горутина для закрытия канала после завершения всех операций. Маппинг остается для
+	// бэкендов, на которых Complete не выполнен (ошибка или бэкенд недоступен): их части
+	// прервет очистка по TTL или /multipart/abort, либо клиент повторит Complete.
 	go func() {
 		wg.Wait()
+		if remaining := r.multipartStore.EndComplete(mapping.ProxyUploadID, completed); remaining > 0 {
+			logger.Warn("CompleteMultipartUpload %s: upload is not completed on %d of %d backends, keeping it for retry or abort",
+				mapping.ProxyUploadID, remaining, len(mapping.BackendUploads))
+		}
 		close(resultsChan)
 	}()
 	
 	// Агрегируем результаты в соответствии с политикой
-	return r.aggregateCompleteMultipartUploadResults(resultsChan, policy, len(backends))
+	results := r.withAckAllTimeout(opCtx, resultsChan, policy, backends)
+	results = r.withPreferredBackend(opCtx, results, policy, backends)
+	return r.aggregateCompleteMultipartUploadResults(opCtx, results, policy, len(backends))
 }
 
 // performCompleteMultipartUploadToBackend выполняет CompleteMultipartUpload на одном бэкенде
@@ -391,6 +453,10 @@ func (r *Replicator) performCompleteMultipartUploadToBackend(ctx context.Context
 	}
 	
 	duration := time.Since(startTime)
+	var metadata middleware.Metadata
+	if response != nil {
+		metadata = response.ResultMetadata
+	}
 	
 	if err != nil {
 		logger.Error("performCompleteMultipartUploadToBackend: failed on backend %s after %d attempts: %v", b.ID, r.config.RetryAttempts+1, err)
@@ -399,15 +465,16 @@ func (r *Replicator) performCompleteMultipartUploadToBackend(ctx context.Context
 	}
 	
 	return &backend.BackendResult{
-		BackendID: b.ID,
-		Response:  response,
-		Err:       err,
-		Duration:  duration,
+		BackendID:  b.ID,
+		Response:   response,
+		StatusCode: backend.ResponseStatus(metadata, err),
+		Err:        err,
+		Duration:   duration,
 	}
 }
 
 // aggregateCompleteMultipartUploadResults агрегирует результаты CompleteMultipartUpload операций
-func (r *Replicator) aggregateCompleteMultipartUploadResults(resultsChan <-chan *backend.BackendResult, policy routing.WriteOperationPolicy, totalBackends int) *apigw.S3Response {
+func (r *Replicator) aggregateCompleteMultipartUploadResults(opCtx *operationContext, resultsChan <-chan *backend.BackendResult, policy routing.WriteOperationPolicy, totalBackends int) *apigw.S3Response {
 	successCount := 0
 	errorCount := 0
 	var firstSuccessResult *backend.BackendResult
@@ -445,7 +512,7 @@ func (r *Replicator) aggregateCompleteMultipartUploadResults(resultsChan <-chan
 			return r.convertCompleteMultipartUploadResultToResponse(firstSuccessResult)
 		} else {
 			logger.Error("aggregateCompleteMultipartUploadResults: not all backends succeeded for ack=all policy (%d/%d)", successCount, totalBackends)
-			return r.createErrorResponse(http.StatusInternalServerError, "InternalError", "Failed to complete multipart upload on all backends")
+			return r.createErrorResponse(opCtx.requestID, opCtx.Resource(), http.StatusInternalServerError, "InternalError", "Failed to complete multipart upload on all backends")
 		}
 	}
 	
@@ -453,14 +520,14 @@ func (r *Replicator) aggregateCompleteMultipartUploadResults(resultsChan <-chan
 	if policy.AckLevel == "one" && successCount == 0 {
 		logger.Error("aggregateCompleteMultipartUploadResults: no backends succeeded for ack=one policy")
 		if lastError != nil {
-			return r.createErrorResponse(http.StatusServiceUnavailable, "ServiceUnavailable", lastError.Error())
+			return r.createErrorResponse(opCtx.requestID, opCtx.Resource(), http.StatusServiceUnavailable, "ServiceUnavailable", lastError.Error())
 		}
-		return r.createErrorResponse(http.StatusServiceUnavailable, "ServiceUnavailable", "Failed to complete multipart upload on any backend")
+		return r.createErrorResponse(opCtx.requestID, opCtx.Resource(), http.StatusServiceUnavailable, "ServiceUnavailable", "Failed to complete multipart upload on any backend")
 	}
 	
 	// Не должны сюда попасть
 	logger.Error("aggregateCompleteMultipartUploadResults: unexpected code path reached")
-	return r.createErrorResponse(http.StatusInternalServerError, "InternalError", "Unexpected error in result aggregation")
+	return r.createErrorResponse(opCtx.requestID, opCtx.Resource(), http.StatusInternalServerError, "InternalError", "Unexpected error in result aggregation")
 }
 
 // convertCompleteMultipartUploadResultToResponse преобразует результат CompleteMultipartUpload в S3Response
@@ -492,14 +559,14 @@ func (r *Replicator) convertCompleteMultipartUploadResultToResponse(result *back
 		headers.Set("Content-Length", fmt.Sprintf("%d", len(body)))
 		
 		return &apigw.S3Response{
-			StatusCode: http.StatusOK,
+			StatusCode: successStatus(result, http.StatusOK),
 			Headers:    headers,
 			Body:       io.NopCloser(strings.NewReader(body)),
 		}
 	}
 	
 	return &apigw.S3Response{
-		StatusCode: http.StatusOK,
+		StatusCode: successStatus(result, http.StatusOK),
 		Headers:    headers,
 	}
 }
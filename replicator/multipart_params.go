@@ -0,0 +1,38 @@
+package replicator
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"s3proxy/apigw"
+)
+
+// maxPartNumber - наибольший номер части multipart upload в S3
+const maxPartNumber = 10000
+
+// uploadIDParam извлекает uploadId из query запроса. Пустое или отсутствующее значение - ошибка.
+func uploadIDParam(req *apigw.S3Request) (string, error) {
+	if uploadID := req.Query.Get("uploadId"); uploadID != "" {
+		return uploadID, nil
+	}
+	return "", fmt.Errorf("uploadId must not be empty")
+}
+
+// partNumberParam извлекает partNumber из query запроса и проверяет, что это число от 1 до 10000
+func partNumberParam(req *apigw.S3Request) (string, error) {
+	partNumber := req.Query.Get("partNumber")
+	if partNumber == "" {
+		return "", fmt.Errorf("partNumber is required")
+	}
+	n, err := strconv.Atoi(partNumber)
+	if err != nil || n < 1 || n > maxPartNumber {
+		return "", fmt.Errorf("part number must be an integer between 1 and %d, got %q", maxPartNumber, partNumber)
+	}
+	return strconv.Itoa(n), nil
+}
+
+// invalidArgumentResponse формирует ответ 400 InvalidArgument на некорректный параметр запроса
+func (r *Replicator) invalidArgumentResponse(req *apigw.S3Request, err error) *apigw.S3Response {
+	return r.createErrorResponse(req.RequestID, apigw.ResourcePath(req.Bucket, req.Key), http.StatusBadRequest, "InvalidArgument", err.Error())
+}
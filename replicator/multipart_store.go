@@ -3,6 +3,7 @@ package replicator
 import (
 	"crypto/rand"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"sync"
 	"time"
@@ -10,6 +11,12 @@ import (
 	"s3proxy/logger"
 )
 
+// ErrUploadNotFound - у прокси нет маппинга для идентификатора multipart upload
+var ErrUploadNotFound = errors.New("multipart upload not found")
+
+// ErrUploadCompleting - загрузка завершается (CompleteMultipartUpload) и не может быть прервана
+var ErrUploadCompleting = errors.New("multipart upload is being completed")
+
 // MultipartStore управляет маппингами multipart upload
 type MultipartStore struct {
 	mu       sync.RWMutex
@@ -17,6 +24,9 @@ type MultipartStore struct {
 	config   *Config
 	stopChan chan struct{}
 	wg       sync.WaitGroup
+
+	// Вызывается для каждого маппинга, удаленного по истечении TTL (см. SetExpireHandler)
+	onExpire func(*multipartUploadMapping)
 }
 
 // NewMultipartStore создает новое хранилище multipart маппингов
@@ -82,6 +92,96 @@ func (ms *MultipartStore) GetMapping(proxyUploadID string) (*multipartUploadMapp
 	return mapping, true
 }
 
+// ProxyUploadID ищет ProxyUploadID по идентификатору загрузки на бэкенде
+func (ms *MultipartStore) ProxyUploadID(backendID, backendUploadID string) (string, bool) {
+	ms.mu.RLock()
+	defer ms.mu.RUnlock()
+
+	now := time.Now()
+	for proxyUploadID, mapping := range ms.mappings {
+		if mapping.BackendUploads[backendID] == backendUploadID &&
+			now.Sub(mapping.CreatedAt) <= ms.config.MultipartUploadTTL {
+			return proxyUploadID, true
+		}
+	}
+	return "", false
+}
+
+// BeginComplete возвращает маппинг для CompleteMultipartUpload и отмечает, что загрузка
+// завершается: очистка и принудительное прерывание ее больше не затрагивают.
+// После завершения маппинг передается в EndComplete.
+func (ms *MultipartStore) BeginComplete(proxyUploadID string) (*multipartUploadMapping, bool) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	mapping, exists := ms.mappings[proxyUploadID]
+	if !exists || time.Since(mapping.CreatedAt) > ms.config.MultipartUploadTTL {
+		return nil, false
+	}
+	mapping.completing = true
+	return mapping, true
+}
+
+// EndComplete снимает отметку BeginComplete и удаляет из маппинга бэкенды, на которых
+// загрузка завершена. Если незавершенных бэкендов не осталось, маппинг удаляется; иначе
+// загрузка снова видна очистке по TTL, принудительному прерыванию и повтору Complete.
+// Возвращает число оставшихся бэкендов.
+func (ms *MultipartStore) EndComplete(proxyUploadID string, completed map[string]bool) int {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	mapping, exists := ms.mappings[proxyUploadID]
+	if !exists {
+		return 0
+	}
+	// Маппинг заменяется копией: завершающие операции читают BackendUploads без блокировки
+	remaining := make(map[string]string, len(mapping.BackendUploads))
+	for backendID, uploadID := range mapping.BackendUploads {
+		if !completed[backendID] {
+			remaining[backendID] = uploadID
+		}
+	}
+	if len(remaining) == 0 {
+		delete(ms.mappings, proxyUploadID)
+		logger.Debug("Deleted multipart mapping: %s", proxyUploadID)
+		return 0
+	}
+	ms.mappings[proxyUploadID] = &multipartUploadMapping{
+		ProxyUploadID:  mapping.ProxyUploadID,
+		BackendUploads: remaining,
+		CreatedAt:      mapping.CreatedAt,
+		Bucket:         mapping.Bucket,
+		Key:            mapping.Key,
+	}
+	return len(remaining)
+}
+
+// TakeMapping удаляет маппинг и возвращает его, чтобы прервать загрузку на бэкендах.
+// Маппинг с истекшим TTL, еще не удаленный очисткой, тоже возвращается. Завершаемую
+// загрузку не трогает (ErrUploadCompleting).
+func (ms *MultipartStore) TakeMapping(proxyUploadID string) (*multipartUploadMapping, error) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+
+	mapping, exists := ms.mappings[proxyUploadID]
+	if !exists {
+		return nil, ErrUploadNotFound
+	}
+	if mapping.completing {
+		return nil, ErrUploadCompleting
+	}
+	delete(ms.mappings, proxyUploadID)
+	return mapping, nil
+}
+
+// SetExpireHandler задает обработчик маппингов, удаляемых очисткой по истечении TTL.
+// Обработчик вызывается из горутины очистки вне блокировки хранилища.
+func (ms *MultipartStore) SetExpireHandler(handler func(*multipartUploadMapping)) {
+	ms.mu.Lock()
+	defer ms.mu.Unlock()
+	ms.onExpire = handler
+}
+
 // DeleteMapping удаляет маппинг
 func (ms *MultipartStore) DeleteMapping(proxyUploadID string) {
 	ms.mu.Lock()
@@ -151,26 +251,29 @@ func (ms *MultipartStore) startCleanup() {
 	}()
 }
 
-// cleanup удаляет устаревшие маппинги
+// cleanup удаляет устаревшие маппинги и передает их обработчику истечения. Завершаемые
+// загрузки пропускаются: их маппинг удалит CompleteMultipartUpload.
 func (ms *MultipartStore) cleanup() {
 	ms.mu.Lock()
-	defer ms.mu.Unlock()
-	
 	now := time.Now()
-	var expiredKeys []string
-	
+	var expired []*multipartUploadMapping
 	for key, mapping := range ms.mappings {
-		if now.Sub(mapping.CreatedAt) > ms.config.MultipartUploadTTL {
-			expiredKeys = append(expiredKeys, key)
+		if now.Sub(mapping.CreatedAt) > ms.config.MultipartUploadTTL && !mapping.completing {
+			expired = append(expired, mapping)
+			delete(ms.mappings, key)
 		}
 	}
-	
-	for _, key := range expiredKeys {
-		delete(ms.mappings, key)
+	onExpire := ms.onExpire
+	ms.mu.Unlock()
+
+	if len(expired) == 0 {
+		return
 	}
-	
-	if len(expiredKeys) > 0 {
-		logger.Debug("Cleaned up %d expired multipart mappings", len(expiredKeys))
+	logger.Debug("Cleaned up %d expired multipart mappings", len(expired))
+	if onExpire != nil {
+		for _, mapping := range expired {
+			onExpire(mapping)
+		}
 	}
 }
 
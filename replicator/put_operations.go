@@ -1,7 +1,9 @@
 package replicator
 
 import (
+	"bytes"
 	"context"
+	"errors"
 	"io"
 	"net/http"
 	"strings"
@@ -14,60 +16,216 @@ import (
 	"s3proxy/routing"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
+	v4 "github.com/aws/aws-sdk-go-v2/aws/signer/v4"
+	awshttp "github.com/aws/aws-sdk-go-v2/aws/transport/http"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+	"github.com/aws/smithy-go/middleware"
 )
 
 // performPutSync выполняет PUT операцию синхронно (для ack=one и ack=all)
 func (r *Replicator) performPutSync(opCtx *operationContext, req *apigw.S3Request, backends []*backend.Backend, policy routing.WriteOperationPolicy) *apigw.S3Response {
 	logger.Debug("performPutSync: starting sync PUT for %d backends with policy %s", len(backends), policy.AckLevel)
 
-	// Клонируем reader для каждого бэкенда
-	readers, err := r.readerCloner.Clone(req.Body, len(backends))
+	// Для политики 'one' операции должны продолжаться в фоне,
+	// даже если исходный запрос завершился. Используем контекст запроса без отмены
+	// (идентификатор запроса сохраняется). Для 'all' мы хотим отменить операции,
+	// если клиент отключается, поэтому используем контекст исходного запроса.
+	baseCtx := opCtx.ctx
+	if policy.AckLevel == "one" {
+		baseCtx = context.WithoutCancel(opCtx.ctx)
+	}
+	writeCtx, cancelWrites := context.WithCancel(baseCtx)
+
+	// Отслеживаем отключение клиента до окончания передачи тела
+	var body io.Reader = req.Body
+	var clientBody *clientBodyReader
+	bodyDone := make(chan struct{})
+	if r.config.AbortOnClientDisconnect && req.Body != nil {
+		clientBody = newClientBodyReader(opCtx.ctx, req.Body, req.ContentLength, func() {
+			logger.Warn("performPutSync: client disconnected before the body of %s/%s was fully received, aborting backend writes",
+				req.Bucket, req.Key)
+			cancelWrites()
+		})
+		body = clientBody
+		go clientBody.watch(bodyDone)
+	}
+
+	// MD5 тела для сверки с ETag бэкендов вычисляется один раз, до клонирования
+	var digest *bodyDigest
+	if body != nil && r.shouldVerifyETag(req, policy) {
+		digest = newBodyDigest(body)
+		body = digest
+	}
+
+	// Для асинхронной репликации тело копируется во временный файл по мере передачи бэкендам
+	var replica *asyncReplica
+	if r.replicationQueue != nil && policy.AckLevel == "one" {
+		var err error
+		if replica, err = r.newAsyncReplica(req); err != nil {
+			logger.Error("performPutSync: failed to create replication buffer, %s/%s will not be queued: %v", req.Bucket, req.Key, err)
+		} else if body != nil {
+			body = io.TeeReader(body, replica)
+		}
+	}
+
+	// Клонируем reader для каждого бэкенда. Большие тела сначала записываются во временный файл
+	var readers []io.Reader
+	var err error
+	removeSpill := func() {}
+	if r.shouldSpill(req, len(backends)) {
+		readers, removeSpill, err = r.spillBody(body, len(backends))
+	} else {
+		readers, err = r.readerCloner.Clone(body, len(backends))
+	}
 	if err != nil {
+		cancelWrites()
+		close(bodyDone)
+		if replica != nil {
+			r.finishAsyncReplica(req, policy, replica, true)
+		}
+		if clientBody != nil && clientBody.Aborted() {
+			return r.createErrorResponse(opCtx.requestID, opCtx.Resource(), http.StatusBadRequest, "IncompleteBody",
+				"You did not provide the number of bytes specified by the Content-Length HTTP header")
+		}
 		logger.Error("performPutSync: failed to clone reader: %v", err)
-		return r.createErrorResponse(http.StatusInternalServerError, "InternalError", "Failed to prepare request body")
+		return r.createErrorResponse(opCtx.requestID, opCtx.Resource(), http.StatusInternalServerError, "InternalError", "Failed to prepare request body")
 	}
 
 	// Создаем канал для результатов
 	resultsChan := make(chan *backend.BackendResult, len(backends))
 
+	// Записи, которые бэкенды могли зафиксировать (для удаления обрезанных объектов при обрыве)
+	committed := make([]*committedPut, len(backends))
+
 	// Запускаем горутины для каждого бэкенда
 	var wg sync.WaitGroup
 	for i, backend_iter := range backends {
 		wg.Add(1)
-		go func(b *backend.Backend, reader io.Reader) {
+		go func(i int, b *backend.Backend, reader io.Reader) {
 			defer wg.Done()
 
 			// Ограничиваем количество одновременных операций
-			r.semaphore <- struct{}{}
-			defer func() { <-r.semaphore }()
-
-			// Для политики 'one' операции должны продолжаться в фоне,
-			// даже если исходный запрос завершился. Используем фоновый контекст.
-			// Для 'all' мы хотим отменить операции, если клиент отключается,
-			// поэтому используем контекст исходного запроса.
-			backendCtx := opCtx.ctx
-			if policy.AckLevel == "one" {
-				backendCtx = context.Background()
+			r.semaphores.put <- struct{}{}
+			defer func() { <-r.semaphores.put }()
+
+			result := r.putToBackend(writeCtx, b, req, reader)
+			// Бэкенд больше не читает тело: освобождаем клонирующую горутину
+			switch clone := reader.(type) {
+			case *io.PipeReader:
+				clone.Close()
+			case *chunkQueue:
+				clone.Close()
 			}
-
-			result := r.performPutToBackend(backendCtx, b, req, reader)
 			r.reportBackendResult(result)
+			if replica != nil {
+				replica.record(result)
+			}
+			if result.Err == nil || result.StatusCode == 0 {
+				committed[i] = newCommittedPut(b, result)
+			}
 			//r.updateMetrics(b.ID, "put_object", result)
 
 			resultsChan <- result
-		}(backend_iter, readers[i])
+		}(i, backend_iter, readers[i])
 	}
 
 	// Горутина для закрытия канала после завершения всех операций
 	go func() {
 		wg.Wait()
+		close(bodyDone)
+		cancelWrites()
+		removeSpill()
+
+		// При обрыве бэкенд мог подтвердить запись обрезанного объекта
+		if clientBody != nil && clientBody.Aborted() {
+			r.cleanupPartialObjects(opCtx, req, committed)
+		}
+
+		// Отставшие бэкенды получат объект из очереди асинхронной репликации
+		if replica != nil {
+			r.finishAsyncReplica(req, policy, replica, clientBody != nil && clientBody.Aborted())
+		}
+
 		close(resultsChan)
 	}()
 
+	// Результаты проходят проверки до ограничений ожидания, чтобы ack=one и ack=all
+	// учитывали уже проверенные ответы
+	results := r.withETagVerification(opCtx, resultsChan, digest)
+	results = r.withWriteVerification(opCtx, results, req, policy, backends)
+	results = r.withAckAllTimeout(opCtx, results, policy, backends)
+	results = r.withPreferredBackend(opCtx, results, policy, backends)
+
 	// Агрегируем результаты в соответствии с политикой
-	return r.aggregatePutResults(resultsChan, policy, len(backends))
+	response := r.aggregatePutResults(opCtx, results, policy, len(backends))
+
+	if clientBody != nil && clientBody.Aborted() {
+		return r.createErrorResponse(opCtx.requestID, opCtx.Resource(), http.StatusBadRequest, "IncompleteBody",
+			"You did not provide the number of bytes specified by the Content-Length HTTP header")
+	}
+
+	return response
+}
+
+// committedPut - запись PUT, которую бэкенд мог зафиксировать: он ответил успехом или
+// ответ не получен (запрос отменен при обрыве). ETag известен только из успешного ответа,
+// bytes - число переданных бэкенду байт тела.
+type committedPut struct {
+	backend *backend.Backend
+	etag    string
+	bytes   int64
+}
+
+// newCommittedPut запоминает запись PUT на бэкенд
+func newCommittedPut(b *backend.Backend, result *backend.BackendResult) *committedPut {
+	put := &committedPut{backend: b, bytes: result.BytesWritten}
+	if output, ok := result.Response.(*s3.PutObjectOutput); ok && output != nil && output.ETag != nil {
+		put.etag = *output.ETag
+	}
+	return put
+}
+
+// cleanupPartialObjects в фоне удаляет обрезанные объекты, зафиксированные бэкендами во время
+// прерванной клиентом загрузки. Удаляется только объект, записанный этим запросом: HEAD
+// показывает объект не старше начала операции с ETag из ответа на PUT (если он получен)
+// и размером, равным числу переданных бэкенду байт. Объекты параллельных записей и бэкенды,
+// отклонившие запись ответом с ошибкой, не трогаем.
+func (r *Replicator) cleanupPartialObjects(opCtx *operationContext, req *apigw.S3Request, committed []*committedPut) {
+	// Last-Modified имеет точность до секунды
+	startedAt := opCtx.startTime.Truncate(time.Second)
+
+	for _, put := range committed {
+		if put == nil {
+			continue
+		}
+		go func(put *committedPut) {
+			b := put.backend
+			ctx, cancel := context.WithTimeout(context.Background(), r.config.OperationTimeout)
+			defer cancel()
+
+			head, err := b.S3Client.HeadObject(ctx, &s3.HeadObjectInput{
+				Bucket: aws.String(b.Config.Bucket),
+				Key:    aws.String(req.Key),
+			})
+			if err != nil || head.LastModified == nil || head.LastModified.Before(startedAt) {
+				// Объекта нет, он старше прерванной загрузки или проверка не удалась
+				return
+			}
+			if (put.etag != "" && aws.ToString(head.ETag) != put.etag) || aws.ToInt64(head.ContentLength) != put.bytes {
+				logger.Debug("cleanupPartialObjects: %s/%s on backend %s was replaced by another write, keeping it", req.Bucket, req.Key, b.ID)
+				return
+			}
+
+			logger.Warn("cleanupPartialObjects: deleting partial object %s/%s on backend %s", req.Bucket, req.Key, b.ID)
+			result := r.performDeleteFromBackend(context.Background(), b, req)
+			r.reportBackendResult(result)
+			if result.Err != nil {
+				logger.Error("cleanupPartialObjects: failed to delete partial object %s on backend %s: %v", req.Key, b.ID, result.Err)
+			}
+		}(put)
+	}
 }
 
 // buildPutObjectInput инкапсулирует сложную логику преобразования
@@ -81,7 +239,8 @@ func (r *Replicator) buildPutObjectInput(req *apigw.S3Request, body io.Reader, b
 		Body:   body,
 	}
 
-	// 2. Явно указываем ContentLength.
+	// 2. Явно указываем ContentLength. Тело неизвестной длины (Transfer-Encoding: chunked)
+	// передается без него, см. streamingPutOptions.
 	if req.ContentLength > 0 {
 		putInput.ContentLength = aws.Int64(req.ContentLength)
 	}
@@ -101,17 +260,66 @@ func (r *Replicator) buildPutObjectInput(req *apigw.S3Request, body io.Reader, b
 		case "Content-Type":
 			putInput.ContentType = aws.String(value)
 		case "Content-Encoding":
-			putInput.ContentEncoding = aws.String(value)
+			// aws-chunked снимает шлюз, бэкенду передается уже декодированное тело
+			if encoding := stripAWSChunkedEncoding(value); encoding != "" {
+				putInput.ContentEncoding = aws.String(encoding)
+			}
 		case "Content-Md5":
 			putInput.ContentMD5 = aws.String(value)
+		// Условная запись: If-None-Match: * - только создание, If-Match: <etag> - только
+		// перезапись известной версии. Условие проверяет каждый бэкенд.
+		case "If-Match":
+			putInput.IfMatch = aws.String(value)
+		case "If-None-Match":
+			putInput.IfNoneMatch = aws.String(value)
+		// Контрольные суммы x-amz-checksum-* сверяет и прокси (CountingReader), и бэкенд,
+		// который сохраняет их вместе с объектом
+		case "X-Amz-Checksum-Crc32":
+			putInput.ChecksumCRC32 = aws.String(value)
+		case "X-Amz-Checksum-Crc32c":
+			putInput.ChecksumCRC32C = aws.String(value)
+		case "X-Amz-Checksum-Crc64nvme":
+			putInput.ChecksumCRC64NVME = aws.String(value)
+		case "X-Amz-Checksum-Sha1":
+			putInput.ChecksumSHA1 = aws.String(value)
+		case "X-Amz-Checksum-Sha256":
+			putInput.ChecksumSHA256 = aws.String(value)
 		case "Cache-Control":
 			putInput.CacheControl = aws.String(value)
 		case "X-Amz-Storage-Class":
 			putInput.StorageClass = types.StorageClass(value)
+		// Серверное шифрование (SSE-S3, SSE-KMS, SSE-C) передается бэкенду как есть
+		case "X-Amz-Server-Side-Encryption":
+			putInput.ServerSideEncryption = types.ServerSideEncryption(value)
+		case "X-Amz-Server-Side-Encryption-Aws-Kms-Key-Id":
+			putInput.SSEKMSKeyId = aws.String(value)
+		case "X-Amz-Server-Side-Encryption-Context":
+			putInput.SSEKMSEncryptionContext = aws.String(value)
+		case "X-Amz-Server-Side-Encryption-Bucket-Key-Enabled":
+			putInput.BucketKeyEnabled = aws.Bool(strings.EqualFold(value, "true"))
+		case "X-Amz-Server-Side-Encryption-Customer-Algorithm":
+			putInput.SSECustomerAlgorithm = aws.String(value)
+		case "X-Amz-Server-Side-Encryption-Customer-Key":
+			putInput.SSECustomerKey = aws.String(value)
+		case "X-Amz-Server-Side-Encryption-Customer-Key-Md5":
+			putInput.SSECustomerKeyMD5 = aws.String(value)
+		// Object Lock (WORM): режим и срок хранения применяет бэкенд с включенной блокировкой объектов
+		case "X-Amz-Object-Lock-Mode":
+			putInput.ObjectLockMode = types.ObjectLockMode(value)
+		case "X-Amz-Object-Lock-Retain-Until-Date":
+			if retainUntil, err := time.Parse(time.RFC3339, value); err == nil {
+				putInput.ObjectLockRetainUntilDate = aws.Time(retainUntil)
+			} else {
+				// Без даты бэкенд отклонит запрос с режимом блокировки, а не сохранит объект без нее
+				logger.Warn("buildPutObjectInput: invalid x-amz-object-lock-retain-until-date %q: %v", value, err)
+			}
+		case "X-Amz-Object-Lock-Legal-Hold":
+			putInput.ObjectLockLegalHoldStatus = types.ObjectLockLegalHoldStatus(value)
 		// Если клиент прислал SHA256 хэш, доверяем ему. Это экономит чтение потока.
 		// НЕ используем для streaming-клиента, так как он вычисляет его сам.
 		case "X-Amz-Content-Sha256":
-			if !isStreamingClient {
+			// Явно переданная x-amz-checksum-sha256 имеет приоритет
+			if !isStreamingClient && !strings.HasPrefix(value, "STREAMING-") && req.Headers.Get("X-Amz-Checksum-Sha256") == "" {
 				putInput.ChecksumSHA256 = aws.String(value)
 			}
 		// Игнорируем заголовки, относящиеся к аутентификации и транспорту
@@ -132,6 +340,124 @@ func (r *Replicator) buildPutObjectInput(req *apigw.S3Request, body io.Reader, b
 	return putInput
 }
 
+// hasUnknownLength сообщает, что размер тела PUT неизвестен: клиент передает его
+// с Transfer-Encoding: chunked без Content-Length и x-amz-decoded-content-length
+func hasUnknownLength(req *apigw.S3Request) bool {
+	return req.ContentLength < 0 && req.Body != nil
+}
+
+// streamingPutOptions переключает PutObject обычного клиента на потоковую передачу тела
+// неизвестной длины, как у StreamingPutClient: тело подписывается как UNSIGNED-PAYLOAD и
+// контрольная сумма не вычисляется. Иначе по HTTPS SDK оборачивает тело в aws-chunked с
+// контрольной суммой в трейлере, а такое тело без x-amz-decoded-content-length бэкенд
+// отклоняет. Тело читается по мере отправки, поэтому память при записи на N бэкендов
+// ограничена буферами клонирования.
+func streamingPutOptions(o *s3.Options) {
+	o.RequestChecksumCalculation = aws.RequestChecksumCalculationWhenRequired
+	o.APIOptions = append(o.APIOptions, v4.SwapComputePayloadSHA256ForUnsignedPayloadMiddleware)
+}
+
+// stripAWSChunkedEncoding удаляет aws-chunked из значения Content-Encoding
+func stripAWSChunkedEncoding(value string) string {
+	var encodings []string
+	for _, encoding := range strings.Split(value, ",") {
+		encoding = strings.TrimSpace(encoding)
+		if encoding != "" && !strings.EqualFold(encoding, "aws-chunked") {
+			encodings = append(encodings, encoding)
+		}
+	}
+	return strings.Join(encodings, ",")
+}
+
+// putToBackend отправляет объект на бэкенд. Для бэкендов с AutoCreateBucket, в чей бакет
+// еще не было успешной записи, при ошибке NoSuchBucket бакет создается и PUT повторяется.
+// Для повтора прочитанная часть тела запоминается (не больше MaxBufferedPartSize);
+// если бэкенд успел прочитать больше, повтор невозможен и возвращается исходная ошибка.
+// Если бакет, в который запись уже удавалась, удален в обход прокси, PUT завершается
+// ошибкой NoSuchBucket, а следующий снова создает бакет.
+func (r *Replicator) putToBackend(ctx context.Context, b *backend.Backend, req *apigw.S3Request, body io.Reader) *backend.BackendResult {
+	readyKey := readyBucketKey(b)
+	if !b.Config.AutoCreateBucket {
+		return r.performPutToBackend(ctx, b, req, body)
+	}
+	if _, ready := r.readyBuckets.Load(readyKey); ready {
+		result := r.performPutToBackend(ctx, b, req, body)
+		if isNoSuchBucketError(result.Err) {
+			logger.Warn("putToBackend: bucket %s disappeared from backend %s, it will be created on the next write", b.Config.Bucket, b.ID)
+			r.readyBuckets.Delete(readyKey)
+		}
+		return result
+	}
+
+	recorder := &replayRecorder{reader: body, limit: r.config.MaxBufferedPartSize}
+	result := r.performPutToBackend(ctx, b, req, recorder)
+	if result.Err == nil {
+		r.readyBuckets.Store(readyKey, struct{}{})
+		return result
+	}
+	if !isNoSuchBucketError(result.Err) {
+		return result
+	}
+
+	logger.Warn("putToBackend: bucket %s does not exist on backend %s, creating it", b.Config.Bucket, b.ID)
+	created := r.performCreateBucketOnBackend(ctx, b)
+	if created.Err != nil && !isBucketAlreadyExistsError(created.Err) {
+		logger.Error("putToBackend: failed to create bucket %s on backend %s: %v", b.Config.Bucket, b.ID, created.Err)
+		return result
+	}
+	r.readyBuckets.Store(readyKey, struct{}{})
+
+	replay, ok := recorder.Replay()
+	if !ok {
+		logger.Warn("putToBackend: cannot retry PUT of %s on backend %s: body already sent exceeds max_buffered_part_size", req.Key, b.ID)
+		return result
+	}
+	logger.Info("putToBackend: bucket %s created on backend %s, retrying PUT of %s", b.Config.Bucket, b.ID, req.Key)
+	return r.performPutToBackend(ctx, b, req, replay)
+}
+
+// readyBucketKey возвращает ключ бакета бэкенда в readyBuckets
+func readyBucketKey(b *backend.Backend) string {
+	return b.ID + "/" + b.Config.Bucket
+}
+
+// isNoSuchBucketError возвращает true, если бакета нет на бэкенде
+func isNoSuchBucketError(err error) bool {
+	var apiErr smithy.APIError
+	return errors.As(err, &apiErr) && apiErr.ErrorCode() == "NoSuchBucket"
+}
+
+// replayRecorder запоминает прочитанные данные (не больше limit байт),
+// чтобы тело можно было отправить повторно
+type replayRecorder struct {
+	reader   io.Reader
+	limit    int64
+	buf      bytes.Buffer
+	overflow bool
+}
+
+func (rr *replayRecorder) Read(p []byte) (int, error) {
+	n, err := rr.reader.Read(p)
+	if n > 0 && !rr.overflow {
+		if int64(rr.buf.Len()+n) > rr.limit {
+			rr.overflow = true
+			rr.buf = bytes.Buffer{}
+		} else {
+			rr.buf.Write(p[:n])
+		}
+	}
+	return n, err
+}
+
+// Replay возвращает тело для повтора: прочитанные данные и непрочитанный остаток.
+// false - прочитано больше limit байт, повтор невозможен
+func (rr *replayRecorder) Replay() (io.Reader, bool) {
+	if rr.overflow {
+		return nil, false
+	}
+	return io.MultiReader(bytes.NewReader(rr.buf.Bytes()), rr.reader), true
+}
+
 // performPutToBackend выполняет ОДНУ попытку отправки объекта на бэкенд.
 // Логика повторных попыток (retries) должна быть реализована на уровне выше.
 func (r *Replicator) performPutToBackend(ctx context.Context, b *backend.Backend, req *apigw.S3Request, body io.Reader) *backend.BackendResult {
@@ -141,17 +467,29 @@ func (r *Replicator) performPutToBackend(ctx context.Context, b *backend.Backend
 	ctx, cancel := context.WithTimeout(ctx, r.config.OperationTimeout)
 	defer cancel()
 
-	// Оборачиваем тело для подсчета байт
+	// Оборачиваем тело для подсчета байт и проверки Content-MD5 и x-amz-checksum-*
 	countingReader := NewCountingReader(body)
+	if contentMD5 := req.Headers.Get("Content-Md5"); contentMD5 != "" {
+		countingReader.ExpectMD5(contentMD5)
+	}
+	for _, header := range apigw.ChecksumHeaders {
+		if value := req.Headers.Get(header); value != "" {
+			countingReader.ExpectChecksum(header, value)
+		}
+	}
 
 	// 1. Собираем запрос с помощью новой функции-хелпера
 	putInput := r.buildPutObjectInput(req, countingReader, b)
 
 	// 2. Выбираем правильный S3 клиент (обычный или для стриминга)
 	clientToUse := b.S3Client
+	var optFns []func(*s3.Options)
 	if b.StreamingPutClient != nil {
 		logger.Debug("Using dedicated streaming client for PutObject on backend %s", b.ID)
 		clientToUse = b.StreamingPutClient
+	} else if hasUnknownLength(req) {
+		logger.Debug("Streaming PutObject of unknown length to backend %s with UNSIGNED-PAYLOAD", b.ID)
+		optFns = append(optFns, streamingPutOptions)
 	}
 
 	logger.Debug(
@@ -160,11 +498,23 @@ func (r *Replicator) performPutToBackend(ctx context.Context, b *backend.Backend
 	)
 
 	// 3. Выполняем ОДНУ попытку запроса
-	response, err := clientToUse.PutObject(ctx, putInput)
+	response, err := clientToUse.PutObject(ctx, putInput, optFns...)
 
 	duration := time.Since(startTime)
 	bytesWritten := countingReader.Count()
 
+	// Ошибку бэкенда, не дочитавшего тело, не подменяем: сумма части тела ничего не говорит
+	var metadata middleware.Metadata
+	if response != nil {
+		metadata = response.ResultMetadata
+	}
+	statusCode := backend.ResponseStatus(metadata, err)
+	if countingReader.DigestMismatch() && (err == nil || countingReader.badDigest) {
+		err = countingReader.DigestError()
+		logger.Error("performPutToBackend: checksum mismatch for %s on backend %s: %v", req.Key, b.ID, err)
+		statusCode = http.StatusBadRequest
+	}
+
 	// 4. Логируем результат и возвращаем его
 	if err != nil {
 		logger.Error("performPutToBackend: failed on backend %s: %v", b.ID, err)
@@ -177,17 +527,20 @@ func (r *Replicator) performPutToBackend(ctx context.Context, b *backend.Backend
 		Method:       "PUT",
 		Response:     response,
 		Err:          err,
+		StatusCode:   statusCode,
 		Duration:     duration,
 		BytesWritten: bytesWritten,
 	}
 }
 
 // aggregatePutResults агрегирует результаты PUT операций
-func (r *Replicator) aggregatePutResults(resultsChan <-chan *backend.BackendResult, policy routing.WriteOperationPolicy, totalBackends int) *apigw.S3Response {
+func (r *Replicator) aggregatePutResults(opCtx *operationContext, resultsChan <-chan *backend.BackendResult, policy routing.WriteOperationPolicy, totalBackends int) *apigw.S3Response {
 	successCount := 0
 	errorCount := 0
 	var firstSuccessResult *backend.BackendResult
-	var lastError error
+	var errs errorSummary
+	var badDigest error
+	preconditionFailed := false
 
 	logger.Debug("aggregatePutResults: waiting for results with policy %s", policy.AckLevel)
 
@@ -207,36 +560,58 @@ func (r *Replicator) aggregatePutResults(resultsChan <-chan *backend.BackendResu
 			}
 		} else {
 			errorCount++
-			lastError = result.Err
+			errs.add(result)
+			if errors.Is(result.Err, ErrBadDigest) {
+				badDigest = result.Err
+			}
+			if isPreconditionFailedError(result.Err) {
+				preconditionFailed = true
+			}
 			logger.Debug("aggregatePutResults: error from backend %s: %v (%d/%d)", result.BackendID, result.Err, errorCount, totalBackends)
 		}
 	}
 
 	logger.Debug("aggregatePutResults: final results - success: %d, errors: %d", successCount, errorCount)
 
+	// Данные, не совпавшие с контрольной суммой клиента, не должны считаться записанными:
+	// при ack=all достаточно одного такого бэкенда, чтобы отклонить запись
+	if badDigest != nil && (policy.AckLevel == "all" || successCount == 0) {
+		return r.createErrorResponse(opCtx.requestID, opCtx.Resource(), http.StatusBadRequest, "BadDigest", badDigestMessage(badDigest))
+	}
+
+	// Условие записи (If-Match/If-None-Match) не выполнено: при ack=all запись отклоняется,
+	// если условие не выполнено хотя бы на одном бэкенде
+	if preconditionFailed && (policy.AckLevel == "all" || successCount == 0) {
+		logger.Warn("[%s] aggregatePutResults: write precondition failed for %s (%d/%d backends succeeded)",
+			opCtx.requestID, opCtx.Resource(), successCount, totalBackends)
+		return r.createErrorResponse(opCtx.requestID, opCtx.Resource(), http.StatusPreconditionFailed, "PreconditionFailed", preconditionFailedMessage)
+	}
+
 	// Логика для ack=all
 	if policy.AckLevel == "all" {
 		if successCount == totalBackends {
 			logger.Debug("aggregatePutResults: all backends succeeded for ack=all policy")
 			return r.convertPutResultToResponse(firstSuccessResult)
 		} else {
-			logger.Error("aggregatePutResults: not all backends succeeded for ack=all policy (%d/%d)", successCount, totalBackends)
-			return r.createErrorResponse(http.StatusInternalServerError, "InternalError", "Failed to replicate object to all backends")
+			logger.Error("aggregatePutResults: not all backends succeeded for ack=all policy (%d/%d): %s", successCount, totalBackends, errs.String())
+			return r.createErrorResponse(opCtx.requestID, opCtx.Resource(), http.StatusInternalServerError, "InternalError",
+				"Failed to replicate object to all backends: "+errs.String())
 		}
 	}
 
 	// Если мы дошли сюда при ack=one, значит ни один бэкенд не ответил успехом
 	if policy.AckLevel == "one" && successCount == 0 {
-		logger.Error("aggregatePutResults: no backends succeeded for ack=one policy")
-		if lastError != nil {
-			return r.createErrorResponse(http.StatusServiceUnavailable, "ServiceUnavailable", lastError.Error())
+		logger.Error("aggregatePutResults: no backends succeeded for ack=one policy: %s", errs.String())
+		if !errs.Empty() {
+			return r.createErrorResponse(opCtx.requestID, opCtx.Resource(), http.StatusServiceUnavailable, "ServiceUnavailable",
+				"Failed to write to any backend: "+errs.String())
 		}
-		return r.createErrorResponse(http.StatusServiceUnavailable, "ServiceUnavailable", "Failed to write to any backend")
+		return r.createErrorResponse(opCtx.requestID, opCtx.Resource(), http.StatusServiceUnavailable, "ServiceUnavailable", "Failed to write to any backend")
 	}
 
 	// Не должны сюда попасть
 	logger.Error("aggregatePutResults: unexpected code path reached")
-	return r.createErrorResponse(http.StatusInternalServerError, "InternalError", "Unexpected error in result aggregation")
+	return r.createErrorResponse(opCtx.requestID, opCtx.Resource(), http.StatusInternalServerError, "InternalError", "Unexpected error in result aggregation")
 }
 
 // convertPutResultToResponse преобразует результат PUT в S3Response
@@ -250,16 +625,61 @@ func (r *Replicator) convertPutResultToResponse(result *backend.BackendResult) *
 		if putOutput.VersionId != nil {
 			headers.Set("x-amz-version-id", *putOutput.VersionId)
 		}
+		if putOutput.ServerSideEncryption != "" {
+			headers.Set("x-amz-server-side-encryption", string(putOutput.ServerSideEncryption))
+		}
+		if putOutput.SSEKMSKeyId != nil {
+			headers.Set("x-amz-server-side-encryption-aws-kms-key-id", *putOutput.SSEKMSKeyId)
+		}
+		if putOutput.SSEKMSEncryptionContext != nil {
+			headers.Set("x-amz-server-side-encryption-context", *putOutput.SSEKMSEncryptionContext)
+		}
+		if aws.ToBool(putOutput.BucketKeyEnabled) {
+			headers.Set("x-amz-server-side-encryption-bucket-key-enabled", "true")
+		}
+		if putOutput.SSECustomerAlgorithm != nil {
+			headers.Set("x-amz-server-side-encryption-customer-algorithm", *putOutput.SSECustomerAlgorithm)
+		}
+		if putOutput.SSECustomerKeyMD5 != nil {
+			headers.Set("x-amz-server-side-encryption-customer-key-md5", *putOutput.SSECustomerKeyMD5)
+		}
 	}
 
 	return &apigw.S3Response{
-		StatusCode: http.StatusOK,
+		StatusCode: successStatus(result, http.StatusOK),
 		Headers:    headers,
 	}
 }
 
+// successStatus возвращает статус успешного ответа бэкенда из результата операции или
+// fallback, если бэкенд не сообщил статус 2xx (например, клиент без HTTP в тестах)
+func successStatus(result *backend.BackendResult, fallback int) int {
+	if result != nil && result.StatusCode >= 200 && result.StatusCode < 300 {
+		return result.StatusCode
+	}
+	return fallback
+}
+
+// preconditionFailedMessage - стандартное сообщение S3 для ошибки PreconditionFailed
+const preconditionFailedMessage = "At least one of the pre-conditions you specified did not hold"
+
+// isPreconditionFailedError сообщает, что бэкенд отклонил условную запись (412)
+func isPreconditionFailedError(err error) bool {
+	var apiErr smithy.APIError
+	if errors.As(err, &apiErr) && apiErr.ErrorCode() == "PreconditionFailed" {
+		return true
+	}
+	var respErr *awshttp.ResponseError
+	return errors.As(err, &respErr) && respErr.HTTPStatusCode() == http.StatusPreconditionFailed
+}
+
 // reportBackendResult сообщает результат операции в Backend Manager
 func (r *Replicator) reportBackendResult(result *backend.BackendResult) {
+	// Несовпадение контрольной суммы, невыполненное условие записи и удаление непустого
+	// бакета - ошибки клиента, а не бэкенда
+	if errors.Is(result.Err, ErrBadDigest) || isPreconditionFailedError(result.Err) || isBucketNotEmptyError(result.Err) {
+		return
+	}
 	if result.Err != nil {
 		r.backendProvider.ReportFailure(result)
 	} else {
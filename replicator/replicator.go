@@ -2,11 +2,14 @@ package replicator
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
+	"maps"
 	"net/http"
 	"strings"
 	"sync"
+	"time"
 
 	//"github.com/aws/aws-sdk-go-v2/service/s3"
 	"s3proxy/apigw"
@@ -29,8 +32,14 @@ type Replicator struct {
 	readerCloner   ReaderCloner
 	config         *Config
 
-	// Семафор для ограничения количества одновременных операций
-	semaphore chan struct{}
+	// Семафоры для ограничения количества одновременных операций по категориям
+	semaphores operationSemaphores
+
+	// Бакеты бэкендов с AutoCreateBucket, в которые уже удалась запись ("<backendID>/<bucket>")
+	readyBuckets sync.Map
+
+	// Очередь асинхронной репликации на отставшие бэкенды (nil - выключена)
+	replicationQueue *replicationQueue
 }
 
 // NewReplicator создает новый экземпляр репликатора
@@ -39,17 +48,32 @@ func NewReplicator(provider *backend.Manager, config *Config) *Replicator {
 		config = DefaultConfig()
 	}
 
+	// Тело PUT и большие части multipart upload клонируются в ограниченные очереди, чтобы
+	// медленный бэкенд не задерживал остальные, пока отстает не больше чем на CloneBufferSize
+	var readerCloner ReaderCloner = &PipeReaderCloner{}
+	if config.CloneBufferSize > 0 {
+		readerCloner = &QueueReaderCloner{ChunkSize: config.BufferSize, MaxBuffered: config.CloneBufferSize}
+	}
+
 	replicator := &Replicator{
 		backendProvider: provider,
 		//metrics:         metrics,
 		multipartStore: NewMultipartStore(config),
-		readerCloner:   &PipeReaderCloner{},
+		readerCloner:   readerCloner,
 		config:         config,
-		semaphore:      make(chan struct{}, config.MaxConcurrentOperations),
+		semaphores:     newOperationSemaphores(config),
 	}
 
-	logger.Info("Replicator initialized with config: max_concurrent=%d, timeout=%v",
-		config.MaxConcurrentOperations, config.OperationTimeout)
+	// Загрузки с истекшим маппингом прерываются на бэкендах, чтобы не копить части
+	replicator.multipartStore.SetExpireHandler(replicator.abortExpiredUpload)
+
+	if config.AsyncReplication {
+		replicator.startReplicationQueue()
+	}
+
+	logger.Info("Replicator initialized with config: max_concurrent=%d (put=%d, delete=%d, part=%d, bucket=%d), timeout=%v",
+		config.MaxConcurrentOperations, cap(replicator.semaphores.put), cap(replicator.semaphores.delete),
+		cap(replicator.semaphores.part), cap(replicator.semaphores.bucket), config.OperationTimeout)
 
 	return replicator
 }
@@ -57,24 +81,51 @@ func NewReplicator(provider *backend.Manager, config *Config) *Replicator {
 // Stop останавливает репликатор
 func (r *Replicator) Stop() {
 	r.multipartStore.Stop()
+	if r.replicationQueue != nil {
+		r.stopReplicationQueue()
+	}
 	logger.Info("Replicator stopped")
 }
 
+// ProxyUploadID возвращает идентификатор multipart upload прокси по идентификатору
+// загрузки на бэкенде. Используется Fetcher'ом в ListMultipartUploads.
+func (r *Replicator) ProxyUploadID(backendID, backendUploadID string) (string, bool) {
+	return r.multipartStore.ProxyUploadID(backendID, backendUploadID)
+}
+
+// BackendUploadIDs возвращает идентификаторы загрузок на бэкендах для ProxyUploadID.
+// Используется Fetcher'ом в ListParts.
+func (r *Replicator) BackendUploadIDs(proxyUploadID string) (map[string]string, bool) {
+	mapping, exists := r.multipartStore.GetMapping(proxyUploadID)
+	if !exists {
+		return nil, false
+	}
+	return maps.Clone(mapping.BackendUploads), true
+}
+
 // PutObject выполняет репликацию PUT операции
 func (r *Replicator) PutObject(ctx context.Context, req *apigw.S3Request, policy routing.WriteOperationPolicy) *apigw.S3Response {
 	opCtx := newOperationContext(ctx, "PUT_OBJECT", req.Bucket, req.Key)
 
-	logger.Debug("PutObject: bucket=%s, key=%s, policy=%+v", req.Bucket, req.Key, policy)
+	logger.Debug("[%s] PutObject: bucket=%s, key=%s, policy=%+v", req.RequestID, req.Bucket, req.Key, policy)
 
-	// Получаем живые бэкенды
-	liveBackends := r.backendProvider.GetLiveBackends()
+	// Более старая версия объекта не должна доставляться из очереди после этой записи
+	r.supersedeReplication(req.Bucket, req.Key)
+
+	// Получаем живые бэкенды (с учетом маршрутизации по Content-Type и размеру объекта)
+	r.fillContentType(req)
+	liveBackends := selectBySize(req, selectWriteBackends(req, policy, r.backendProvider.GetLiveBackends()))
 	if len(liveBackends) == 0 {
 		logger.Warn("PutObject: no live backends available")
-		return r.createErrorResponse(http.StatusServiceUnavailable, "ServiceUnavailable", "No available backends")
+		return r.noBackendsResponse(req, "No available backends")
 	}
 
 	logger.Debug("PutObject: using %d backends", len(liveBackends))
 
+	if policy.AckLevel == "one" && policy.Failover {
+		return r.performPutFailover(opCtx, req, liveBackends, policy)
+	}
+
 	// Синхронное выполнение для ack=one и ack=all
 	return r.performPutSync(opCtx, req, liveBackends, policy)
 }
@@ -83,29 +134,130 @@ func (r *Replicator) PutObject(ctx context.Context, req *apigw.S3Request, policy
 func (r *Replicator) DeleteObject(ctx context.Context, req *apigw.S3Request, policy routing.WriteOperationPolicy) *apigw.S3Response {
 	opCtx := newOperationContext(ctx, "DELETE_OBJECT", req.Bucket, req.Key)
 
-	logger.Debug("DeleteObject: bucket=%s, key=%s, policy=%+v", req.Bucket, req.Key, policy)
+	logger.Debug("[%s] DeleteObject: bucket=%s, key=%s, policy=%+v", req.RequestID, req.Bucket, req.Key, policy)
+
+	// Удаленный объект не должен появиться на отставших бэкендах из очереди
+	r.supersedeReplication(req.Bucket, req.Key)
 
 	// Получаем живые бэкенды
 	liveBackends := r.backendProvider.GetLiveBackends()
 	if len(liveBackends) == 0 {
 		logger.Warn("DeleteObject: no live backends available")
-		return r.createErrorResponse(http.StatusServiceUnavailable, "ServiceUnavailable", "No available backends")
+		return r.noBackendsResponse(req, "No available backends")
 	}
 
 	// Синхронное выполнение для ack=one и ack=all
 	return r.performDeleteSync(opCtx, req, liveBackends, policy)
 }
 
+// CreateBucket создает бакет на всех бэкендах
+func (r *Replicator) CreateBucket(ctx context.Context, req *apigw.S3Request, policy routing.WriteOperationPolicy) *apigw.S3Response {
+	opCtx := newOperationContext(ctx, "CREATE_BUCKET", req.Bucket, "")
+
+	logger.Debug("[%s] CreateBucket: bucket=%s, policy=%+v", req.RequestID, req.Bucket, policy)
+
+	if !r.config.BucketOperations {
+		return r.bucketOperationsDisabledResponse(req)
+	}
+
+	liveBackends := r.backendProvider.GetLiveBackends()
+	if len(liveBackends) == 0 {
+		logger.Warn("CreateBucket: no live backends available")
+		return r.noBackendsResponse(req, "No available backends")
+	}
+
+	headers := make(http.Header)
+	headers.Set("Location", "/"+req.Bucket)
+	success := &apigw.S3Response{StatusCode: http.StatusOK, Headers: headers}
+
+	var accepted bucketErrorClassifier
+	if r.config.IdempotentCreateBucket {
+		accepted = isBucketAlreadyExistsError
+	}
+
+	return r.performBucketSync(opCtx, liveBackends, policy, r.performCreateBucketOnBackend, accepted, success)
+}
+
+// DeleteBucket удаляет бакет на всех бэкендах
+func (r *Replicator) DeleteBucket(ctx context.Context, req *apigw.S3Request, policy routing.WriteOperationPolicy) *apigw.S3Response {
+	opCtx := newOperationContext(ctx, "DELETE_BUCKET", req.Bucket, "")
+
+	logger.Debug("[%s] DeleteBucket: bucket=%s, policy=%+v", req.RequestID, req.Bucket, policy)
+
+	if !r.config.BucketOperations {
+		return r.bucketOperationsDisabledResponse(req)
+	}
+
+	liveBackends := r.backendProvider.GetLiveBackends()
+	if len(liveBackends) == 0 {
+		logger.Warn("DeleteBucket: no live backends available")
+		return r.noBackendsResponse(req, "No available backends")
+	}
+
+	// Бакет, который не пуст хотя бы на одном бэкенде, не удаляется нигде
+	if r.config.CheckBucketEmptyBeforeDelete {
+		if id, found := r.findNonEmptyBucket(opCtx, liveBackends); found {
+			logger.Info("[%s] DeleteBucket: bucket is not empty on backend %s, not deleting", req.RequestID, id)
+			return r.createErrorResponse(req.RequestID, apigw.ResourcePath(req.Bucket, ""), http.StatusConflict, "BucketNotEmpty", bucketNotEmptyMessage)
+		}
+	}
+
+	success := &apigw.S3Response{StatusCode: http.StatusNoContent, Headers: make(http.Header)}
+
+	return r.performBucketSync(opCtx, liveBackends, policy, r.performDeleteBucketOnBackend, nil, success)
+}
+
+// PutObjectTagging устанавливает теги объекта на всех бэкендах
+func (r *Replicator) PutObjectTagging(ctx context.Context, req *apigw.S3Request, policy routing.WriteOperationPolicy) *apigw.S3Response {
+	opCtx := newOperationContext(ctx, "PUT_OBJECT_TAGGING", req.Bucket, req.Key)
+
+	logger.Debug("[%s] PutObjectTagging: bucket=%s, key=%s, policy=%+v", req.RequestID, req.Bucket, req.Key, policy)
+
+	tags, code, message := parseTagging(req.Body)
+	if code != "" {
+		return r.createErrorResponse(req.RequestID, apigw.ResourcePath(req.Bucket, req.Key), http.StatusBadRequest, code, message)
+	}
+
+	liveBackends := r.backendProvider.GetLiveBackends()
+	if len(liveBackends) == 0 {
+		logger.Warn("PutObjectTagging: no live backends available")
+		return r.noBackendsResponse(req, "No available backends")
+	}
+
+	success := &apigw.S3Response{StatusCode: http.StatusOK, Headers: make(http.Header)}
+
+	return r.performBucketSync(opCtx, liveBackends, policy, r.putObjectTaggingOperation(req, tags), nil, success)
+}
+
+// DeleteObjectTagging удаляет теги объекта на всех бэкендах
+func (r *Replicator) DeleteObjectTagging(ctx context.Context, req *apigw.S3Request, policy routing.WriteOperationPolicy) *apigw.S3Response {
+	opCtx := newOperationContext(ctx, "DELETE_OBJECT_TAGGING", req.Bucket, req.Key)
+
+	logger.Debug("[%s] DeleteObjectTagging: bucket=%s, key=%s, policy=%+v", req.RequestID, req.Bucket, req.Key, policy)
+
+	liveBackends := r.backendProvider.GetLiveBackends()
+	if len(liveBackends) == 0 {
+		logger.Warn("DeleteObjectTagging: no live backends available")
+		return r.noBackendsResponse(req, "No available backends")
+	}
+
+	success := &apigw.S3Response{StatusCode: http.StatusNoContent, Headers: make(http.Header)}
+
+	return r.performBucketSync(opCtx, liveBackends, policy, r.deleteObjectTaggingOperation(req), nil, success)
+}
+
 // CreateMultipartUpload инициирует multipart upload на всех бэкендах
 func (r *Replicator) CreateMultipartUpload(ctx context.Context, req *apigw.S3Request, policy routing.WriteOperationPolicy) *apigw.S3Response {
 	opCtx := newOperationContext(ctx, "CREATE_MULTIPART_UPLOAD", req.Bucket, req.Key)
 
-	logger.Debug("CreateMultipartUpload: bucket=%s, key=%s", req.Bucket, req.Key)
+	logger.Debug("[%s] CreateMultipartUpload: bucket=%s, key=%s", req.RequestID, req.Bucket, req.Key)
 
-	// Получаем живые бэкенды
-	liveBackends := r.backendProvider.GetLiveBackends()
+	// Получаем живые бэкенды (с учетом маршрутизации по Content-Type). Части и завершение
+	// загрузки идут на бэкенды, выбранные здесь.
+	r.fillContentType(req)
+	liveBackends := selectWriteBackends(req, policy, r.backendProvider.GetLiveBackends())
 	if len(liveBackends) == 0 {
-		return r.createErrorResponse(http.StatusServiceUnavailable, "ServiceUnavailable", "No available backends")
+		return r.noBackendsResponse(req, "No available backends")
 	}
 
 	// Создаем multipart upload на всех бэкендах
@@ -147,14 +299,14 @@ func (r *Replicator) CreateMultipartUpload(ctx context.Context, req *apigw.S3Req
 	// Проверяем результаты
 	if len(backendUploads) == 0 {
 		logger.Error("CreateMultipartUpload: failed on all backends")
-		return r.createErrorResponse(http.StatusInternalServerError, "InternalError", "Failed to create multipart upload on any backend")
+		return r.createErrorResponse(req.RequestID, apigw.ResourcePath(req.Bucket, req.Key), http.StatusInternalServerError, "InternalError", "Failed to create multipart upload on any backend")
 	}
 
 	// Создаем маппинг
 	proxyUploadID, err := r.multipartStore.CreateMapping(req.Bucket, req.Key, backendUploads)
 	if err != nil {
 		logger.Error("CreateMultipartUpload: failed to create mapping: %v", err)
-		return r.createErrorResponse(http.StatusInternalServerError, "InternalError", "Failed to create upload mapping")
+		return r.createErrorResponse(req.RequestID, apigw.ResourcePath(req.Bucket, req.Key), http.StatusInternalServerError, "InternalError", "Failed to create upload mapping")
 	}
 
 	logger.Info("CreateMultipartUpload: created proxy upload ID %s for %d backends", proxyUploadID, len(backendUploads))
@@ -168,21 +320,27 @@ func (r *Replicator) UploadPart(ctx context.Context, req *apigw.S3Request, polic
 	opCtx := newOperationContext(ctx, "UPLOAD_PART", req.Bucket, req.Key)
 
 	// Извлекаем параметры из query
-	uploadID := req.Query["uploadId"][0]
-	partNumber := req.Query["partNumber"][0]
+	uploadID, err := uploadIDParam(req)
+	if err != nil {
+		return r.invalidArgumentResponse(req, err)
+	}
+	partNumber, err := partNumberParam(req)
+	if err != nil {
+		return r.invalidArgumentResponse(req, err)
+	}
 
-	logger.Debug("UploadPart: bucket=%s, key=%s, uploadId=%s, partNumber=%s", req.Bucket, req.Key, uploadID, partNumber)
+	logger.Debug("[%s] UploadPart: bucket=%s, key=%s, uploadId=%s, partNumber=%s", req.RequestID, req.Bucket, req.Key, uploadID, partNumber)
 
 	// Получаем маппинг
 	mapping, exists := r.multipartStore.GetMapping(uploadID)
 	if !exists {
-		return r.createErrorResponse(http.StatusNotFound, "NoSuchUpload", "The specified multipart upload does not exist")
+		return r.createErrorResponse(req.RequestID, apigw.ResourcePath(req.Bucket, req.Key), http.StatusNotFound, "NoSuchUpload", "The specified multipart upload does not exist")
 	}
 
 	// Получаем живые бэкенды
 	liveBackends := r.backendProvider.GetLiveBackends()
 	if len(liveBackends) == 0 {
-		return r.createErrorResponse(http.StatusServiceUnavailable, "ServiceUnavailable", "No available backends")
+		return r.noBackendsResponse(req, "No available backends")
 	}
 
 	// Фильтруем бэкенды, которые участвуют в этом upload
@@ -194,7 +352,7 @@ func (r *Replicator) UploadPart(ctx context.Context, req *apigw.S3Request, polic
 	}
 
 	if len(targetBackends) == 0 {
-		return r.createErrorResponse(http.StatusServiceUnavailable, "ServiceUnavailable", "No available backends for this upload")
+		return r.noBackendsResponse(req, "No available backends for this upload")
 	}
 
 	// Синхронное выполнение
@@ -204,16 +362,20 @@ func (r *Replicator) UploadPart(ctx context.Context, req *apigw.S3Request, polic
 // CompleteMultipartUpload завершает multipart upload
 func (r *Replicator) CompleteMultipartUpload(ctx context.Context, req *apigw.S3Request, policy routing.WriteOperationPolicy) *apigw.S3Response {
 	opCtx := newOperationContext(ctx, "COMPLETE_MULTIPART_UPLOAD", req.Bucket, req.Key)
+	r.supersedeReplication(req.Bucket, req.Key)
 
 	// Извлекаем uploadId из query
-	uploadID := req.Query["uploadId"][0]
+	uploadID, err := uploadIDParam(req)
+	if err != nil {
+		return r.invalidArgumentResponse(req, err)
+	}
 
-	logger.Debug("CompleteMultipartUpload: bucket=%s, key=%s, uploadId=%s", req.Bucket, req.Key, uploadID)
+	logger.Debug("[%s] CompleteMultipartUpload: bucket=%s, key=%s, uploadId=%s", req.RequestID, req.Bucket, req.Key, uploadID)
 
 	// Получаем маппинг
 	mapping, exists := r.multipartStore.GetMapping(uploadID)
 	if !exists {
-		return r.createErrorResponse(http.StatusNotFound, "NoSuchUpload", "The specified multipart upload does not exist")
+		return r.createErrorResponse(req.RequestID, apigw.ResourcePath(req.Bucket, req.Key), http.StatusNotFound, "NoSuchUpload", "The specified multipart upload does not exist")
 	}
 
 	// Получаем живые бэкенды
@@ -221,16 +383,18 @@ func (r *Replicator) CompleteMultipartUpload(ctx context.Context, req *apigw.S3R
 	targetBackends := r.filterBackendsForUpload(liveBackends, mapping)
 
 	if len(targetBackends) == 0 {
-		return r.createErrorResponse(http.StatusServiceUnavailable, "ServiceUnavailable", "No available backends for this upload")
+		return r.noBackendsResponse(req, "No available backends for this upload")
 	}
 
-	// Complete всегда выполняется синхронно (критическая операция)
-	response := r.performCompleteMultipartUploadSync(opCtx, req, targetBackends, mapping, policy)
-
-	// Удаляем маппинг после завершения (успешного или нет)
-	r.multipartStore.DeleteMapping(uploadID)
+	// Пока загрузка завершается, очистка по TTL и принудительное прерывание ее не затрагивают.
+	// Маппинга уже может не быть, если загрузку только что прервали.
+	if _, exists := r.multipartStore.BeginComplete(uploadID); !exists {
+		return r.createErrorResponse(req.RequestID, apigw.ResourcePath(req.Bucket, req.Key), http.StatusNotFound, "NoSuchUpload", "The specified multipart upload does not exist")
+	}
 
-	return response
+	// Complete всегда выполняется синхронно (критическая операция). Маппинг обновляется
+	// после ответа всех бэкендов (см. performCompleteMultipartUploadSync)
+	return r.performCompleteMultipartUploadSync(opCtx, req, targetBackends, mapping, policy)
 }
 
 // AbortMultipartUpload отменяет multipart upload
@@ -238,9 +402,12 @@ func (r *Replicator) AbortMultipartUpload(ctx context.Context, req *apigw.S3Requ
 	opCtx := newOperationContext(ctx, "ABORT_MULTIPART_UPLOAD", req.Bucket, req.Key)
 
 	// Извлекаем uploadId из query
-	uploadID := req.Query["uploadId"][0]
+	uploadID, err := uploadIDParam(req)
+	if err != nil {
+		return r.invalidArgumentResponse(req, err)
+	}
 
-	logger.Debug("AbortMultipartUpload: bucket=%s, key=%s, uploadId=%s", req.Bucket, req.Key, uploadID)
+	logger.Debug("[%s] AbortMultipartUpload: bucket=%s, key=%s, uploadId=%s", req.RequestID, req.Bucket, req.Key, uploadID)
 
 	// Получаем маппинг
 	mapping, exists := r.multipartStore.GetMapping(uploadID)
@@ -273,23 +440,144 @@ func (r *Replicator) filterBackendsForUpload(liveBackends []*backend.Backend, ma
 	return targetBackends
 }
 
-// createErrorResponse создает ответ об ошибке
-func (r *Replicator) createErrorResponse(statusCode int, errorCode, message string) *apigw.S3Response {
-	body := fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
-<Error>
-    <Code>%s</Code>
-    <Message>%s</Message>
-</Error>`, errorCode, message)
+// errAckAllTimeout - бэкенд не ответил за AckAllTimeout
+var errAckAllTimeout = errors.New("backend did not respond within ack_all_timeout")
 
-	headers := make(http.Header)
-	headers.Set("Content-Type", "application/xml")
-	headers.Set("Content-Length", fmt.Sprintf("%d", len(body)))
+// withAckAllTimeout ограничивает ожидание результатов для ack=all значением AckAllTimeout.
+// Результаты пересылаются из resultsChan; по истечении таймаута для каждого не ответившего
+// бэкенда добавляется результат с ошибкой errAckAllTimeout, и канал закрывается.
+// Сами операции на зависших бэкендах продолжаются до отмены контекста запроса.
+func (r *Replicator) withAckAllTimeout(opCtx *operationContext, resultsChan <-chan *backend.BackendResult, policy routing.WriteOperationPolicy, backends []*backend.Backend) <-chan *backend.BackendResult {
+	if policy.AckLevel != "all" || r.config.AckAllTimeout <= 0 {
+		return resultsChan
+	}
 
-	return &apigw.S3Response{
-		StatusCode: statusCode,
-		Headers:    headers,
-		Body:       io.NopCloser(strings.NewReader(body)),
+	out := make(chan *backend.BackendResult, len(backends))
+	go func() {
+		defer close(out)
+
+		timer := time.NewTimer(r.config.AckAllTimeout)
+		defer timer.Stop()
+
+		responded := make(map[string]bool, len(backends))
+		for {
+			select {
+			case result, ok := <-resultsChan:
+				if !ok {
+					return
+				}
+				responded[result.BackendID] = true
+				out <- result
+			case <-timer.C:
+				for _, b := range backends {
+					if responded[b.ID] {
+						continue
+					}
+					logger.Warn("[%s] %s: backend %s did not respond within ack_all_timeout %v",
+						opCtx.requestID, opCtx.operation, b.ID, r.config.AckAllTimeout)
+					out <- &backend.BackendResult{
+						BackendID: b.ID,
+						Err:       fmt.Errorf("%w (%v)", errAckAllTimeout, r.config.AckAllTimeout),
+						Duration:  opCtx.Duration(),
+					}
+				}
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// withPreferredBackend для ack=one с заданным PreferredBackend придерживает успешные
+// результаты остальных бэкендов, пока не ответит предпочтительный бэкенд или не истечет
+// PreferredWait. Так подтверждение получает предпочтительный бэкенд, если он отвечает
+// быстро, а медленный предпочтительный бэкенд не задерживает запись дольше PreferredWait.
+// Ошибки пересылаются сразу: они не приводят к подтверждению.
+func (r *Replicator) withPreferredBackend(opCtx *operationContext, resultsChan <-chan *backend.BackendResult, policy routing.WriteOperationPolicy, backends []*backend.Backend) <-chan *backend.BackendResult {
+	if policy.AckLevel != "one" || policy.PreferredBackend == "" {
+		return resultsChan
+	}
+	preferredPresent := false
+	for _, b := range backends {
+		if b.ID == policy.PreferredBackend {
+			preferredPresent = true
+			break
+		}
 	}
+	if !preferredPresent {
+		return resultsChan
+	}
+
+	wait := policy.PreferredWait
+	if wait <= 0 {
+		wait = routing.DefaultPreferredWait
+	}
+
+	out := make(chan *backend.BackendResult, len(backends))
+	go func() {
+		defer close(out)
+
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+
+		var held []*backend.BackendResult
+		release := func() {
+			for _, result := range held {
+				out <- result
+			}
+			held = nil
+		}
+
+		for {
+			select {
+			case result, ok := <-resultsChan:
+				if !ok {
+					release()
+					return
+				}
+				if result.BackendID == policy.PreferredBackend {
+					out <- result
+					release()
+					for result := range resultsChan {
+						out <- result
+					}
+					return
+				}
+				if result.Err != nil {
+					out <- result
+					continue
+				}
+				held = append(held, result)
+			case <-timer.C:
+				logger.Debug("[%s] %s: preferred backend %s did not respond within %v, acknowledging other backends",
+					opCtx.requestID, opCtx.operation, policy.PreferredBackend, wait)
+				release()
+				for result := range resultsChan {
+					out <- result
+				}
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// createErrorResponse создает ответ об ошибке
+func (r *Replicator) createErrorResponse(requestID, resource string, statusCode int, errorCode, message string) *apigw.S3Response {
+	return apigw.NewErrorResponse(requestID, resource, statusCode, errorCode, message)
+}
+
+// noBackendsResponse создает ответ 503 ServiceUnavailable с Retry-After для запроса,
+// которому не нашлось доступных бэкендов
+func (r *Replicator) noBackendsResponse(req *apigw.S3Request, message string) *apigw.S3Response {
+	return apigw.NewServiceUnavailableResponse(req.RequestID, apigw.ResourcePath(req.Bucket, req.Key), message, r.config.RetryAfter)
+}
+
+// bucketOperationsDisabledResponse создает ответ 403 AccessDenied на CreateBucket/DeleteBucket,
+// когда операции с бакетами выключены (BucketOperations)
+func (r *Replicator) bucketOperationsDisabledResponse(req *apigw.S3Request) *apigw.S3Response {
+	logger.Info("[%s] Rejecting %s on bucket %q: bucket operations are disabled", req.RequestID, req.Operation, req.Bucket)
+	return r.createErrorResponse(req.RequestID, apigw.ResourcePath(req.Bucket, ""), http.StatusForbidden, "AccessDenied", "Bucket operations are disabled on this proxy")
 }
 
 // createSuccessResponse создает успешный ответ
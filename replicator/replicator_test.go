@@ -1,69 +1,35 @@
 package replicator
 
 import (
+	"bytes"
 	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"errors"
+	"hash/crc32"
 	"fmt"
 	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+
 	"s3proxy/apigw"
+	"s3proxy/backend"
+	"s3proxy/internal/backendtest"
+	"s3proxy/internal/s3mock"
 	"s3proxy/routing"
 )
 
-// MockBackendProvider для тестов
-type MockBackendProvider struct {
-	backends        []*Backend
-	successReports  []string
-	failureReports  map[string]error
-}
-
-func NewMockBackendProvider(backendCount int) *MockBackendProvider {
-	backends := make([]*Backend, backendCount)
-	for i := 0; i < backendCount; i++ {
-		backends[i] = &Backend{
-			ID: fmt.Sprintf("backend-%d", i+1),
-		}
-	}
-	
-	return &MockBackendProvider{
-		backends:       backends,
-		successReports: make([]string, 0),
-		failureReports: make(map[string]error),
-	}
-}
-
-func (m *MockBackendProvider) GetLiveBackends() []*Backend {
-	return m.backends
-}
-
-func (m *MockBackendProvider) GetAllBackends() []*Backend {
-	return m.backends
-}
-
-func (m *MockBackendProvider) GetBackend(id string) (*Backend, bool) {
-	for _, b := range m.backends {
-		if b.ID == id {
-			return b, true
-		}
-	}
-	return nil, false
-}
-
-func (m *MockBackendProvider) ReportSuccess(backendID string) {
-	m.successReports = append(m.successReports, backendID)
-}
-
-func (m *MockBackendProvider) ReportFailure(backendID string, err error) {
-	m.failureReports[backendID] = err
-}
-
-func (m *MockBackendProvider) Start() error { return nil }
-func (m *MockBackendProvider) Stop() error { return nil }
-func (m *MockBackendProvider) IsRunning() bool { return true }
-
 func TestDefaultConfig(t *testing.T) {
 	config := DefaultConfig()
 	
@@ -108,6 +74,24 @@ func TestConfigValidation(t *testing.T) {
 			},
 			expectError: true,
 		},
+		{
+			name: "Negative ack all timeout",
+			config: func() *Config {
+				c := DefaultConfig()
+				c.AckAllTimeout = -time.Second
+				return c
+			}(),
+			expectError: true,
+		},
+		{
+			name: "Negative retry after",
+			config: func() *Config {
+				c := DefaultConfig()
+				c.RetryAfter = -time.Second
+				return c
+			}(),
+			expectError: true,
+		},
 		{
 			name: "Invalid max concurrent operations",
 			config: &Config{
@@ -137,10 +121,10 @@ func TestConfigValidation(t *testing.T) {
 }
 
 func TestNewReplicator(t *testing.T) {
-	provider := NewMockBackendProvider(2)
+	provider, _ := backendtest.NewServers(t, 2, backend.StateUp)
 	config := DefaultConfig()
 	
-	replicator := NewReplicator(provider, nil, config)
+	replicator := NewReplicator(provider, config)
 	
 	if replicator == nil {
 		t.Fatal("Expected replicator to be created")
@@ -288,6 +272,14 @@ func TestMultipartStore(t *testing.T) {
 		t.Errorf("Expected 2 backend uploads, got %d", len(mapping.BackendUploads))
 	}
 	
+	// Тест обратного поиска по идентификатору загрузки на бэкенде
+	if id, found := store.ProxyUploadID("backend-2", "upload-2"); !found || id != proxyUploadID {
+		t.Errorf("Expected proxy upload ID %s for backend-2/upload-2, got %q (found=%v)", proxyUploadID, id, found)
+	}
+	if _, found := store.ProxyUploadID("backend-1", "upload-2"); found {
+		t.Error("Expected upload-2 to be unknown for backend-1")
+	}
+	
 	// Тест TTL
 	time.Sleep(150 * time.Millisecond) // Ждем истечения TTL
 	
@@ -311,10 +303,10 @@ func TestMultipartStore(t *testing.T) {
 }
 
 func TestCreateErrorResponse(t *testing.T) {
-	provider := NewMockBackendProvider(1)
-	replicator := NewReplicator(provider, nil, nil)
+	provider, _ := backendtest.NewServers(t, 1, backend.StateUp)
+	replicator := NewReplicator(provider, nil)
 	
-	response := replicator.createErrorResponse(404, "NoSuchKey", "The specified key does not exist")
+	response := replicator.createErrorResponse("0123456789ABCDEF", "/test-bucket/test-key", 404, "NoSuchKey", "The specified key does not exist")
 	
 	if response.StatusCode != 404 {
 		t.Errorf("Expected status code 404, got %d", response.StatusCode)
@@ -331,9 +323,15 @@ func TestCreateErrorResponse(t *testing.T) {
 	}
 	
 	bodyStr := string(body)
+	if !strings.Contains(bodyStr, "<RequestId>0123456789ABCDEF</RequestId>") {
+		t.Errorf("Expected body to contain request ID, got: %s", bodyStr)
+	}
 	if !strings.Contains(bodyStr, "NoSuchKey") {
 		t.Errorf("Expected body to contain 'NoSuchKey', got: %s", bodyStr)
 	}
+	if !strings.Contains(bodyStr, "<Resource>/test-bucket/test-key</Resource>") {
+		t.Errorf("Expected body to contain resource, got: %s", bodyStr)
+	}
 	
 	if !strings.Contains(bodyStr, "The specified key does not exist") {
 		t.Errorf("Expected body to contain error message, got: %s", bodyStr)
@@ -341,8 +339,8 @@ func TestCreateErrorResponse(t *testing.T) {
 }
 
 func TestCreateSuccessResponse(t *testing.T) {
-	provider := NewMockBackendProvider(1)
-	replicator := NewReplicator(provider, nil, nil)
+	provider, _ := backendtest.NewServers(t, 1, backend.StateUp)
+	replicator := NewReplicator(provider, nil)
 	
 	req := &apigw.S3Request{
 		Bucket: "test-bucket",
@@ -372,9 +370,9 @@ func TestCreateSuccessResponse(t *testing.T) {
 }
 
 func TestPutObjectNoBackends(t *testing.T) {
-	// Создаем provider без бэкендов
-	provider := NewMockBackendProvider(0)
-	replicator := NewReplicator(provider, nil, nil)
+	// Создаем provider без живых бэкендов
+	provider, _ := backendtest.NewServers(t, 1, backend.StateDown)
+	replicator := NewReplicator(provider, nil)
 	
 	req := &apigw.S3Request{
 		Bucket: "test-bucket",
@@ -389,6 +387,30 @@ func TestPutObjectNoBackends(t *testing.T) {
 	if response.StatusCode != 503 {
 		t.Errorf("Expected status code 503, got %d", response.StatusCode)
 	}
+	if retryAfter := response.Headers.Get("Retry-After"); retryAfter != "5" {
+		t.Errorf("Expected Retry-After 5, got %q", retryAfter)
+	}
+	body, _ := io.ReadAll(response.Body)
+	if !strings.Contains(string(body), "<Code>ServiceUnavailable</Code>") {
+		t.Errorf("Expected ServiceUnavailable error body, got %s", body)
+	}
+}
+
+func TestNoBackendsRetryAfter(t *testing.T) {
+	provider, _ := backendtest.NewServers(t, 1, backend.StateDown)
+	config := DefaultConfig()
+	config.RetryAfter = 30 * time.Second
+	replicator := NewReplicator(provider, config)
+
+	req := &apigw.S3Request{Bucket: "test-bucket", Key: "test-key"}
+	response := replicator.DeleteObject(context.Background(), req, routing.WriteOperationPolicy{AckLevel: "one"})
+
+	if response.StatusCode != 503 {
+		t.Fatalf("Expected status code 503, got %d", response.StatusCode)
+	}
+	if retryAfter := response.Headers.Get("Retry-After"); retryAfter != "30" {
+		t.Errorf("Expected Retry-After 30, got %q", retryAfter)
+	}
 }
 
 func TestOperationContext(t *testing.T) {
@@ -415,3 +437,2296 @@ func TestOperationContext(t *testing.T) {
 		t.Errorf("Expected duration >= 10ms, got %v", duration)
 	}
 }
+
+// disconnectingBody отдает часть данных, после чего ждет отключения клиента.
+// Обрыв соединения выглядит для читателя как обычный EOF.
+type disconnectingBody struct {
+	ctx  context.Context
+	data []byte
+	sent bool
+}
+
+func (b *disconnectingBody) Read(p []byte) (int, error) {
+	if !b.sent {
+		b.sent = true
+		return copy(p, b.data), nil
+	}
+	<-b.ctx.Done()
+	return 0, io.EOF
+}
+
+func TestPutObjectClientDisconnect(t *testing.T) {
+	provider, servers := backendtest.NewServers(t, 2, backend.StateUp)
+
+	// Первый бэкенд фиксирует объект, не дожидаясь конца тела,
+	// как хранилище, сохраняющее обрезанные данные
+	committed := make(chan struct{})
+	servers[0].SetIntercept(func(w http.ResponseWriter, r *http.Request) bool {
+		if r.Method != http.MethodPut {
+			return false
+		}
+		http.NewResponseController(w).EnableFullDuplex()
+		partial := make([]byte, 5)
+		io.ReadFull(r.Body, partial)
+		servers[0].SetObject("partial.bin", partial, time.Time{})
+		w.WriteHeader(http.StatusOK)
+		close(committed)
+		return true
+	})
+
+	replicator := NewReplicator(provider, nil)
+	defer replicator.Stop()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	req := &apigw.S3Request{
+		Bucket:        "test-bucket",
+		Key:           "partial.bin",
+		Body:          io.NopCloser(&disconnectingBody{ctx: ctx, data: []byte("hello")}),
+		ContentLength: 10,
+	}
+
+	responseChan := make(chan *apigw.S3Response, 1)
+	go func() {
+		responseChan <- replicator.PutObject(ctx, req, routing.WriteOperationPolicy{AckLevel: "one"})
+	}()
+
+	select {
+	case <-committed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Backend did not receive the upload")
+	}
+
+	// Клиент отключается посреди загрузки
+	cancel()
+
+	select {
+	case <-responseChan:
+	case <-time.After(10 * time.Second):
+		t.Fatal("PutObject did not return after client disconnect")
+	}
+
+	// Ни один бэкенд не должен сохранить обрезанный объект
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		if servers[0].ObjectCount() == 0 && servers[1].ObjectCount() == 0 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	for i, srv := range servers {
+		if _, exists := srv.GetObject("partial.bin"); exists {
+			t.Errorf("Backend %d retained a partial object", i+1)
+		}
+	}
+	if servers[0].CountRequests(http.MethodDelete) == 0 {
+		t.Error("Expected a cleanup DELETE on the backend that committed the partial object")
+	}
+}
+
+func TestPutObjectClientDisconnectKeepsConcurrentWrites(t *testing.T) {
+	provider, servers := backendtest.NewServers(t, 2, backend.StateUp)
+
+	// Пока клиент передает тело, на первый бэкенд успевает записаться полный объект
+	// параллельного PUT; второй бэкенд отклоняет запись, но хранит свежий объект
+	complete := []byte("complete object")
+	servers[1].SetObject("shared.bin", complete, time.Now())
+	received := make(chan struct{}, 2)
+	for i, server := range servers {
+		i, server := i, server
+		server.SetIntercept(func(w http.ResponseWriter, r *http.Request) bool {
+			if r.Method != http.MethodPut {
+				return false
+			}
+			http.NewResponseController(w).EnableFullDuplex()
+			partial := make([]byte, 5)
+			io.ReadFull(r.Body, partial)
+			if i == 0 {
+				server.SetObject("shared.bin", complete, time.Time{})
+				w.WriteHeader(http.StatusOK)
+			} else {
+				w.WriteHeader(http.StatusInternalServerError)
+			}
+			received <- struct{}{}
+			return true
+		})
+	}
+
+	replicator := NewReplicator(provider, nil)
+	defer replicator.Stop()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	req := &apigw.S3Request{
+		Bucket:        "test-bucket",
+		Key:           "shared.bin",
+		Body:          io.NopCloser(&disconnectingBody{ctx: ctx, data: []byte("hello")}),
+		ContentLength: 10,
+	}
+	responseChan := make(chan *apigw.S3Response, 1)
+	go func() {
+		responseChan <- replicator.PutObject(ctx, req, routing.WriteOperationPolicy{AckLevel: "one"})
+	}()
+	for range servers {
+		select {
+		case <-received:
+		case <-time.After(5 * time.Second):
+			t.Fatal("Backends did not receive the upload")
+		}
+	}
+	cancel()
+	select {
+	case <-responseChan:
+	case <-time.After(10 * time.Second):
+		t.Fatal("PutObject did not return after client disconnect")
+	}
+
+	// Проверка после обрыва выполняется в фоне
+	time.Sleep(200 * time.Millisecond)
+	for i, srv := range servers {
+		if obj, exists := srv.GetObject("shared.bin"); !exists || !bytes.Equal(obj.Data, complete) {
+			t.Errorf("Backend %d: complete object of a concurrent write must be kept", i+1)
+		}
+		if srv.CountRequests(http.MethodDelete) != 0 {
+			t.Errorf("Backend %d: unexpected cleanup DELETE", i+1)
+		}
+	}
+}
+
+func TestPutObjectReplicatesToAllBackends(t *testing.T) {
+	provider, servers := backendtest.NewServers(t, 2, backend.StateUp)
+	replicator := NewReplicator(provider, nil)
+	defer replicator.Stop()
+
+	data := "replicated data"
+	req := &apigw.S3Request{
+		Bucket:        "test-bucket",
+		Key:           "object.txt",
+		Body:          io.NopCloser(strings.NewReader(data)),
+		ContentLength: int64(len(data)),
+		Headers:       http.Header{},
+	}
+
+	response := replicator.PutObject(context.Background(), req, routing.WriteOperationPolicy{AckLevel: "all"})
+	if response.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status code 200, got %d", response.StatusCode)
+	}
+
+	for i, srv := range servers {
+		obj, exists := srv.GetObject("object.txt")
+		if !exists {
+			t.Errorf("Backend %d: object not found", i+1)
+			continue
+		}
+		if string(obj.Data) != data {
+			t.Errorf("Backend %d: expected %q, got %q", i+1, data, string(obj.Data))
+		}
+	}
+}
+
+// newBucketTestManager создает менеджер, бэкенды которого настроены на бакет bucket
+func newBucketTestManager(t *testing.T, count int, bucket string) (*backend.Manager, []*s3mock.Server) {
+	t.Helper()
+
+	config := &backend.Config{Backends: make(map[string]backend.BackendConfig)}
+	servers := make([]*s3mock.Server, count)
+	for i := 0; i < count; i++ {
+		servers[i] = s3mock.NewServer("test-bucket")
+		t.Cleanup(servers[i].Close)
+		backendConfig := backendtest.BackendConfig(servers[i])
+		backendConfig.Bucket = bucket
+		config.Backends[fmt.Sprintf("backend-%d", i+1)] = backendConfig
+	}
+
+	manager, err := backendtest.NewManager(config)
+	if err != nil {
+		t.Fatalf("Failed to create backend manager: %v", err)
+	}
+	return manager, servers
+}
+
+// bucketOperationsConfig возвращает конфигурацию по умолчанию с включенными операциями с бакетами
+func bucketOperationsConfig() *Config {
+	config := DefaultConfig()
+	config.BucketOperations = true
+	return config
+}
+
+func TestBucketOperationsDisabledByDefault(t *testing.T) {
+	provider, servers := newBucketTestManager(t, 2, "test-bucket")
+	replicator := NewReplicator(provider, nil)
+	defer replicator.Stop()
+
+	policy := routing.WriteOperationPolicy{AckLevel: "all"}
+	for _, response := range []*apigw.S3Response{
+		replicator.CreateBucket(context.Background(), &apigw.S3Request{Operation: apigw.CreateBucket, Bucket: "other-bucket", Headers: http.Header{}}, policy),
+		replicator.DeleteBucket(context.Background(), &apigw.S3Request{Operation: apigw.DeleteBucket, Bucket: "other-bucket", Headers: http.Header{}}, policy),
+	} {
+		if response.StatusCode != http.StatusForbidden {
+			t.Fatalf("Expected status code 403, got %d", response.StatusCode)
+		}
+		body, _ := io.ReadAll(response.Body)
+		if !strings.Contains(string(body), "<Code>AccessDenied</Code>") {
+			t.Errorf("Expected AccessDenied error, got %s", body)
+		}
+	}
+
+	// Бакеты бэкендов не затронуты: запросы к ним не отправлялись
+	for i, srv := range servers {
+		if !srv.HasBucket("test-bucket") {
+			t.Errorf("Backend %d: bucket must not be deleted", i+1)
+		}
+		if count := len(srv.Requests()); count != 0 {
+			t.Errorf("Backend %d: expected no requests, got %d", i+1, count)
+		}
+	}
+}
+
+func TestCreateBucketOnAllBackends(t *testing.T) {
+	provider, servers := newBucketTestManager(t, 3, "new-bucket")
+	replicator := NewReplicator(provider, bucketOperationsConfig())
+	defer replicator.Stop()
+
+	req := &apigw.S3Request{Operation: apigw.CreateBucket, Bucket: "new-bucket", Headers: http.Header{}}
+
+	response := replicator.CreateBucket(context.Background(), req, routing.WriteOperationPolicy{AckLevel: "all"})
+	if response.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status code 200, got %d", response.StatusCode)
+	}
+	if location := response.Headers.Get("Location"); location != "/new-bucket" {
+		t.Errorf("Expected Location /new-bucket, got %q", location)
+	}
+
+	for i, srv := range servers {
+		if !srv.HasBucket("new-bucket") {
+			t.Errorf("Backend %d: bucket was not created", i+1)
+		}
+	}
+}
+
+func TestDeleteBucketOnAllBackends(t *testing.T) {
+	provider, servers := newBucketTestManager(t, 2, "test-bucket")
+	replicator := NewReplicator(provider, bucketOperationsConfig())
+	defer replicator.Stop()
+
+	req := &apigw.S3Request{Operation: apigw.DeleteBucket, Bucket: "test-bucket", Headers: http.Header{}}
+
+	response := replicator.DeleteBucket(context.Background(), req, routing.WriteOperationPolicy{AckLevel: "all"})
+	if response.StatusCode != http.StatusNoContent {
+		t.Fatalf("Expected status code 204, got %d", response.StatusCode)
+	}
+
+	for i, srv := range servers {
+		if srv.HasBucket("test-bucket") {
+			t.Errorf("Backend %d: bucket was not deleted", i+1)
+		}
+	}
+}
+
+func TestDeleteBucketPartialFailure(t *testing.T) {
+	provider, servers := newBucketTestManager(t, 2, "test-bucket")
+	replicator := NewReplicator(provider, bucketOperationsConfig())
+	defer replicator.Stop()
+
+	// На втором бэкенде бакет не пуст, поэтому удаление там завершится ошибкой
+	servers[1].SetObject("object.txt", []byte("data"), time.Time{})
+
+	req := &apigw.S3Request{Operation: apigw.DeleteBucket, Bucket: "test-bucket", Headers: http.Header{}}
+
+	response := replicator.DeleteBucket(context.Background(), req, routing.WriteOperationPolicy{AckLevel: "all"})
+	if response.StatusCode == http.StatusNoContent {
+		t.Fatal("Expected failure when bucket is not deleted on all backends")
+	}
+	if servers[0].HasBucket("test-bucket") {
+		t.Error("Backend 1: empty bucket should have been deleted")
+	}
+}
+
+func TestDeleteBucketNotEmpty(t *testing.T) {
+	for _, check := range []bool{false, true} {
+		t.Run(fmt.Sprintf("check_bucket_empty_before_delete=%t", check), func(t *testing.T) {
+			provider, servers := newBucketTestManager(t, 2, "test-bucket")
+			config := bucketOperationsConfig()
+			config.CheckBucketEmptyBeforeDelete = check
+			replicator := NewReplicator(provider, config)
+			defer replicator.Stop()
+
+			// Объект есть только на втором бэкенде
+			servers[1].SetObject("object.txt", []byte("data"), time.Time{})
+
+			req := &apigw.S3Request{Operation: apigw.DeleteBucket, Bucket: "test-bucket", Headers: http.Header{}}
+			response := replicator.DeleteBucket(context.Background(), req, routing.WriteOperationPolicy{AckLevel: "all"})
+			if response.StatusCode != http.StatusConflict {
+				t.Fatalf("Expected status code 409, got %d", response.StatusCode)
+			}
+			body, _ := io.ReadAll(response.Body)
+			if !strings.Contains(string(body), "<Code>BucketNotEmpty</Code>") {
+				t.Errorf("Expected BucketNotEmpty error, got %s", body)
+			}
+
+			// С проверкой пустой бакет первого бэкенда не удаляется
+			if got := servers[0].HasBucket("test-bucket"); got != check {
+				t.Errorf("Backend 1: expected bucket exists=%t, got %t", check, got)
+			}
+			if !servers[1].HasBucket("test-bucket") {
+				t.Error("Backend 2: non-empty bucket must not be deleted")
+			}
+
+			// BucketNotEmpty не считается сбоем бэкенда
+			be, _ := provider.GetBackend("backend-2")
+			if failures, _, _ := be.GetStats(); failures != 0 {
+				t.Errorf("Expected no failures reported for backend-2, got %d", failures)
+			}
+		})
+	}
+}
+
+func TestCreateBucketAlreadyOwnedByYou(t *testing.T) {
+	provider, servers := newBucketTestManager(t, 2, "new-bucket")
+	replicator := NewReplicator(provider, bucketOperationsConfig())
+	defer replicator.Stop()
+
+	// На первом бэкенде бакет уже существует
+	servers[0].AddBucket("new-bucket")
+
+	req := &apigw.S3Request{Operation: apigw.CreateBucket, Bucket: "new-bucket", Headers: http.Header{}}
+
+	response := replicator.CreateBucket(context.Background(), req, routing.WriteOperationPolicy{AckLevel: "all"})
+	if response.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status code 200, got %d", response.StatusCode)
+	}
+	for i, srv := range servers {
+		if !srv.HasBucket("new-bucket") {
+			t.Errorf("Backend %d: bucket does not exist", i+1)
+		}
+	}
+
+	// Ответ BucketAlreadyOwnedByYou не считается сбоем бэкенда
+	be, _ := provider.GetBackend("backend-1")
+	if failures, _, _ := be.GetStats(); failures != 0 {
+		t.Errorf("Expected no failures reported for backend-1, got %d", failures)
+	}
+}
+
+func TestCreateBucketAlreadyOwnedByYouStrict(t *testing.T) {
+	provider, servers := newBucketTestManager(t, 2, "new-bucket")
+	config := bucketOperationsConfig()
+	config.IdempotentCreateBucket = false
+	replicator := NewReplicator(provider, config)
+	defer replicator.Stop()
+
+	servers[0].AddBucket("new-bucket")
+
+	req := &apigw.S3Request{Operation: apigw.CreateBucket, Bucket: "new-bucket", Headers: http.Header{}}
+
+	response := replicator.CreateBucket(context.Background(), req, routing.WriteOperationPolicy{AckLevel: "all"})
+	if response.StatusCode == http.StatusOK {
+		t.Fatal("Expected failure when idempotent create bucket is disabled")
+	}
+}
+
+// startMultipartUpload создает multipart upload через репликатор и возвращает proxy upload ID
+func startMultipartUpload(t *testing.T, replicator *Replicator, key string) string {
+	t.Helper()
+
+	response := replicator.CreateMultipartUpload(context.Background(), &apigw.S3Request{
+		Bucket:  "test-bucket",
+		Key:     key,
+		Headers: http.Header{},
+	}, routing.WriteOperationPolicy{AckLevel: "all"})
+	if response.StatusCode != http.StatusOK {
+		t.Fatalf("CreateMultipartUpload failed with status %d", response.StatusCode)
+	}
+
+	body, _ := io.ReadAll(response.Body)
+	_, rest, _ := strings.Cut(string(body), "<UploadId>")
+	uploadID, _, found := strings.Cut(rest, "</UploadId>")
+	if !found {
+		t.Fatalf("UploadId not found in response: %s", body)
+	}
+	return uploadID
+}
+
+// newUploadPartRequest создает запрос UploadPart с телом data
+func newUploadPartRequest(key, uploadID string, data []byte) *apigw.S3Request {
+	return &apigw.S3Request{
+		Bucket:        "test-bucket",
+		Key:           key,
+		Query:         map[string][]string{"uploadId": {uploadID}, "partNumber": {"1"}},
+		Headers:       http.Header{},
+		Body:          io.NopCloser(bytes.NewReader(data)),
+		ContentLength: int64(len(data)),
+	}
+}
+
+// capturePartBody перехватывает UploadPart на сервере: ждет release (если задан),
+// вычитывает тело и передает его размер в received
+func capturePartBody(server *s3mock.Server, release <-chan struct{}, received chan<- int) {
+	server.SetIntercept(func(w http.ResponseWriter, r *http.Request) bool {
+		if r.Method != http.MethodPut || r.URL.Query().Get("partNumber") == "" {
+			return false
+		}
+		if release != nil {
+			<-release
+		}
+		data, _ := io.ReadAll(r.Body)
+		received <- len(data)
+		r.Body = io.NopCloser(bytes.NewReader(data))
+		return false
+	})
+}
+
+func TestUploadPartLargePartSlowBackend(t *testing.T) {
+	provider, servers := backendtest.NewServers(t, 3, backend.StateUp)
+
+	// Очередь медленного бэкенда вмещает часть целиком
+	config := DefaultConfig()
+	config.MaxBufferedPartSize = 1024
+	config.CloneBufferSize = 32 * 1024 * 1024
+	replicator := NewReplicator(provider, config)
+	defer replicator.Stop()
+
+	uploadID := startMultipartUpload(t, replicator, "large.bin")
+
+	// Третий бэкенд не читает тело, пока тест его не отпустит
+	release := make(chan struct{})
+	fastReceived := make(chan int, 2)
+	slowReceived := make(chan int, 1)
+	capturePartBody(servers[0], nil, fastReceived)
+	capturePartBody(servers[1], nil, fastReceived)
+	capturePartBody(servers[2], release, slowReceived)
+
+	// Часть заметно больше буферов сокетов, чтобы общий pipe гарантированно остановился
+	part := bytes.Repeat([]byte("0123456789abcdef"), 1<<20) // 16MB
+	responseChan := make(chan *apigw.S3Response, 1)
+	go func() {
+		responseChan <- replicator.UploadPart(context.Background(),
+			newUploadPartRequest("large.bin", uploadID, part), routing.WriteOperationPolicy{AckLevel: "one"})
+	}()
+
+	// Быстрые бэкенды получают часть целиком, пока медленный стоит
+	for i := 0; i < 2; i++ {
+		select {
+		case n := <-fastReceived:
+			if n != len(part) {
+				t.Errorf("Fast backend received %d bytes, expected %d", n, len(part))
+			}
+		case <-time.After(10 * time.Second):
+			close(release)
+			t.Fatal("Fast backends are blocked by the slow backend")
+		}
+	}
+
+	select {
+	case response := <-responseChan:
+		if response.StatusCode != http.StatusOK {
+			t.Errorf("Expected status 200, got %d", response.StatusCode)
+		}
+	case <-time.After(10 * time.Second):
+		close(release)
+		t.Fatal("UploadPart with ack=one did not return while the slow backend was stalled")
+	}
+
+	// Медленный бэкенд в итоге получает часть полностью
+	close(release)
+	select {
+	case n := <-slowReceived:
+		if n != len(part) {
+			t.Errorf("Slow backend received %d bytes, expected %d", n, len(part))
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("Slow backend did not receive the part")
+	}
+}
+
+// progressReader считает байты, прочитанные из тела запроса
+type progressReader struct {
+	reader io.Reader
+	read   atomic.Int64
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.reader.Read(b)
+	p.read.Add(int64(n))
+	return n, err
+}
+
+func TestUploadPartLargePartBoundedBuffering(t *testing.T) {
+	provider, servers := backendtest.NewServers(t, 3, backend.StateUp)
+
+	config := DefaultConfig()
+	config.MaxBufferedPartSize = 1024
+	config.CloneBufferSize = 1024 * 1024
+	replicator := NewReplicator(provider, config)
+	defer replicator.Stop()
+
+	uploadID := startMultipartUpload(t, replicator, "large.bin")
+
+	release := make(chan struct{})
+	received := make(chan int, 3)
+	capturePartBody(servers[0], nil, received)
+	capturePartBody(servers[1], nil, received)
+	capturePartBody(servers[2], release, received)
+
+	part := bytes.Repeat([]byte("0123456789abcdef"), 2<<20) // 32MB
+	req := newUploadPartRequest("large.bin", uploadID, part)
+	body := &progressReader{reader: bytes.NewReader(part)}
+	req.Body = io.NopCloser(body)
+
+	responseChan := make(chan *apigw.S3Response, 1)
+	go func() {
+		responseChan <- replicator.UploadPart(context.Background(), req, routing.WriteOperationPolicy{AckLevel: "one"})
+	}()
+
+	// Пока медленный бэкенд стоит, чтение части останавливается: в памяти не больше
+	// clone_buffer_size на бэкенд (плюс буферы сокетов), а не вся часть
+	var read int64
+	for stable := 0; stable < 5; {
+		time.Sleep(100 * time.Millisecond)
+		if current := body.read.Load(); current == read {
+			stable++
+		} else {
+			read, stable = current, 0
+		}
+	}
+	if read >= int64(len(part)) {
+		close(release)
+		t.Fatalf("Part was read entirely (%d bytes) while the slow backend was stalled", read)
+	}
+	select {
+	case <-responseChan:
+		close(release)
+		t.Fatal("UploadPart must not complete while the slow backend's buffer is full")
+	default:
+	}
+
+	// После того как медленный бэкенд отпущен, часть получают все бэкенды
+	close(release)
+	for i := 0; i < 3; i++ {
+		select {
+		case n := <-received:
+			if n != len(part) {
+				t.Errorf("Backend received %d bytes, expected %d", n, len(part))
+			}
+		case <-time.After(10 * time.Second):
+			t.Fatal("Backends did not receive the part after the slow backend was released")
+		}
+	}
+	if response := <-responseChan; response.StatusCode != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", response.StatusCode)
+	}
+}
+
+func TestUploadPartBufferedPart(t *testing.T) {
+	provider, servers := backendtest.NewServers(t, 2, backend.StateUp)
+	replicator := NewReplicator(provider, nil)
+	defer replicator.Stop()
+
+	uploadID := startMultipartUpload(t, replicator, "small.bin")
+
+	received := make(chan int, 2)
+	capturePartBody(servers[0], nil, received)
+	capturePartBody(servers[1], nil, received)
+
+	part := []byte("small buffered part")
+	response := replicator.UploadPart(context.Background(),
+		newUploadPartRequest("small.bin", uploadID, part), routing.WriteOperationPolicy{AckLevel: "all"})
+	if response.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status 200, got %d", response.StatusCode)
+	}
+
+	for i := 0; i < 2; i++ {
+		if n := <-received; n != len(part) {
+			t.Errorf("Backend received %d bytes, expected %d", n, len(part))
+		}
+	}
+}
+
+func TestQueueReaderCloner(t *testing.T) {
+	cloner := &QueueReaderCloner{ChunkSize: 4}
+	data := "data for independent readers"
+
+	readers, err := cloner.Clone(strings.NewReader(data), 3)
+	if err != nil {
+		t.Fatalf("Failed to clone reader: %v", err)
+	}
+
+	// Первые два читателя дочитывают до конца, не дожидаясь третьего
+	for i := 0; i < 2; i++ {
+		done := make(chan string, 1)
+		go func(r io.Reader) {
+			result, _ := io.ReadAll(r)
+			done <- string(result)
+		}(readers[i])
+
+		select {
+		case result := <-done:
+			if result != data {
+				t.Errorf("Reader %d: expected %q, got %q", i, data, result)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Reader %d is blocked by an idle reader", i)
+		}
+	}
+
+	result, err := io.ReadAll(readers[2])
+	if err != nil || string(result) != data {
+		t.Errorf("Idle reader: expected %q, got %q (err: %v)", data, string(result), err)
+	}
+}
+
+func TestQueueReaderClonerBounded(t *testing.T) {
+	cloner := &QueueReaderCloner{ChunkSize: 4, MaxBuffered: 8}
+	data := strings.Repeat("0123456789", 4)
+
+	readers, err := cloner.Clone(strings.NewReader(data), 2)
+	if err != nil {
+		t.Fatalf("Failed to clone reader: %v", err)
+	}
+
+	// Пока второй читатель простаивает, первый упирается в его заполненную очередь
+	done := make(chan string, 1)
+	go func() {
+		result, _ := io.ReadAll(readers[0])
+		done <- string(result)
+	}()
+
+	select {
+	case <-done:
+		t.Fatal("Fast reader must be throttled once the idle reader's buffer is full")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	result, err := io.ReadAll(readers[1])
+	if err != nil || string(result) != data {
+		t.Errorf("Slow reader: expected %q, got %q (err: %v)", data, string(result), err)
+	}
+
+	select {
+	case result := <-done:
+		if result != data {
+			t.Errorf("Fast reader: expected %q, got %q", data, result)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("Fast reader did not finish after the slow reader drained")
+	}
+}
+
+// slowReader имитирует медленный бэкенд: каждое чтение занимает delay
+type slowReader struct {
+	reader io.Reader
+	delay  time.Duration
+}
+
+func (s *slowReader) Read(p []byte) (int, error) {
+	time.Sleep(s.delay)
+	return s.reader.Read(p)
+}
+
+// BenchmarkClonerSlowBackend измеряет, за какое время быстрый бэкенд получает тело
+// целиком, если второй бэкенд читает медленно. С io.Pipe быстрый бэкенд идет со скоростью
+// медленного, с ограниченными очередями - со своей, пока отставание меньше буфера.
+func BenchmarkClonerSlowBackend(b *testing.B) {
+	const size = 1024 * 1024
+	data := bytes.Repeat([]byte("a"), size)
+
+	cloners := map[string]ReaderCloner{
+		"pipe":    &PipeReaderCloner{},
+		"bounded": &QueueReaderCloner{ChunkSize: 32 * 1024, MaxBuffered: 8 * 1024 * 1024},
+	}
+
+	for name, cloner := range cloners {
+		b.Run(name, func(b *testing.B) {
+			b.SetBytes(size)
+			for i := 0; i < b.N; i++ {
+				readers, err := cloner.Clone(bytes.NewReader(data), 2)
+				if err != nil {
+					b.Fatalf("Failed to clone reader: %v", err)
+				}
+
+				slowDone := make(chan struct{})
+				go func() {
+					io.Copy(io.Discard, &slowReader{reader: readers[1], delay: time.Millisecond})
+					close(slowDone)
+				}()
+
+				io.Copy(io.Discard, readers[0])
+
+				b.StopTimer()
+				<-slowDone
+				b.StartTimer()
+			}
+		})
+	}
+}
+
+func TestPutObjectAckAllTimeout(t *testing.T) {
+	provider, servers := backendtest.NewServers(t, 3, backend.StateUp)
+
+	// Третий бэкенд принимает тело, но не отвечает до конца теста
+	release := make(chan struct{})
+	defer close(release)
+	servers[2].SetIntercept(func(w http.ResponseWriter, r *http.Request) bool {
+		if r.Method != http.MethodPut {
+			return false
+		}
+		io.Copy(io.Discard, r.Body)
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+		return true
+	})
+
+	config := DefaultConfig()
+	config.AckAllTimeout = 200 * time.Millisecond
+	config.RetryAttempts = 0
+	replicator := NewReplicator(provider, config)
+	defer replicator.Stop()
+
+	data := "replicated data"
+	req := &apigw.S3Request{
+		Bucket:        "test-bucket",
+		Key:           "object.txt",
+		Body:          io.NopCloser(strings.NewReader(data)),
+		ContentLength: int64(len(data)),
+		Headers:       http.Header{},
+	}
+
+	start := time.Now()
+	response := replicator.PutObject(context.Background(), req, routing.WriteOperationPolicy{AckLevel: "all"})
+	elapsed := time.Since(start)
+
+	if response.StatusCode != http.StatusInternalServerError {
+		t.Errorf("Expected status code 500, got %d", response.StatusCode)
+	}
+	if elapsed > 2*time.Second {
+		t.Errorf("Expected failure shortly after ack_all_timeout, took %v", elapsed)
+	}
+
+	// Ответившие бэкенды записали объект
+	for i, srv := range servers[:2] {
+		if _, exists := srv.GetObject("object.txt"); !exists {
+			t.Errorf("Backend %d: object not found", i+1)
+		}
+	}
+}
+
+func TestPutObjectPreferredBackend(t *testing.T) {
+	provider, servers := backendtest.NewServers(t, 2, backend.StateUp)
+
+	// Предпочтительный бэкенд отвечает позже второго, но в пределах PreferredWait
+	servers[0].SetIntercept(func(w http.ResponseWriter, r *http.Request) bool {
+		if r.Method != http.MethodPut {
+			return false
+		}
+		io.Copy(io.Discard, r.Body)
+		time.Sleep(100 * time.Millisecond)
+		w.Header().Set("ETag", `"preferred"`)
+		w.WriteHeader(http.StatusOK)
+		return true
+	})
+
+	config := DefaultConfig()
+	config.RetryAttempts = 0
+	replicator := NewReplicator(provider, config)
+	defer replicator.Stop()
+
+	data := "replicated data"
+	req := &apigw.S3Request{
+		Bucket:        "test-bucket",
+		Key:           "object.txt",
+		Body:          io.NopCloser(strings.NewReader(data)),
+		ContentLength: int64(len(data)),
+		Headers:       http.Header{},
+	}
+	policy := routing.WriteOperationPolicy{AckLevel: "one", PreferredBackend: "backend-1", PreferredWait: 2 * time.Second}
+
+	response := replicator.PutObject(context.Background(), req, policy)
+
+	if response.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status code 200, got %d", response.StatusCode)
+	}
+	if etag := response.Headers.Get("ETag"); etag != `"preferred"` {
+		t.Errorf("Expected ETag from preferred backend, got %q", etag)
+	}
+}
+
+func TestPutObjectPreferredBackendSlow(t *testing.T) {
+	provider, servers := backendtest.NewServers(t, 2, backend.StateUp)
+
+	// Предпочтительный бэкенд не отвечает до конца теста
+	release := make(chan struct{})
+	defer close(release)
+	servers[0].SetIntercept(func(w http.ResponseWriter, r *http.Request) bool {
+		if r.Method != http.MethodPut {
+			return false
+		}
+		io.Copy(io.Discard, r.Body)
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+		return true
+	})
+
+	config := DefaultConfig()
+	config.RetryAttempts = 0
+	replicator := NewReplicator(provider, config)
+	defer replicator.Stop()
+
+	data := "replicated data"
+	req := &apigw.S3Request{
+		Bucket:        "test-bucket",
+		Key:           "object.txt",
+		Body:          io.NopCloser(strings.NewReader(data)),
+		ContentLength: int64(len(data)),
+		Headers:       http.Header{},
+	}
+	policy := routing.WriteOperationPolicy{AckLevel: "one", PreferredBackend: "backend-1", PreferredWait: 100 * time.Millisecond}
+
+	start := time.Now()
+	response := replicator.PutObject(context.Background(), req, policy)
+	elapsed := time.Since(start)
+
+	if response.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status code 200, got %d", response.StatusCode)
+	}
+	if elapsed > 2*time.Second {
+		t.Errorf("Expected ack from another backend shortly after preferred_wait, took %v", elapsed)
+	}
+	if _, exists := servers[1].GetObject("object.txt"); !exists {
+		t.Error("Expected object on the second backend")
+	}
+}
+
+func TestPutObjectTaggingReplicatesToAllBackends(t *testing.T) {
+	provider, servers := backendtest.NewServers(t, 2, backend.StateUp)
+	for _, srv := range servers {
+		srv.SetObject("object.txt", []byte("data"), time.Time{})
+	}
+
+	replicator := NewReplicator(provider, DefaultConfig())
+	defer replicator.Stop()
+
+	body := `<Tagging xmlns="http://s3.amazonaws.com/doc/2006-03-01/"><TagSet><Tag><Key>env</Key><Value>prod</Value></Tag></TagSet></Tagging>`
+	req := &apigw.S3Request{
+		Bucket:  "test-bucket",
+		Key:     "object.txt",
+		Body:    io.NopCloser(strings.NewReader(body)),
+		Headers: http.Header{},
+	}
+
+	response := replicator.PutObjectTagging(context.Background(), req, routing.WriteOperationPolicy{AckLevel: "all"})
+	if response.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status code 200, got %d", response.StatusCode)
+	}
+	for i, srv := range servers {
+		obj, _ := srv.GetObject("object.txt")
+		if obj.Tags["env"] != "prod" {
+			t.Errorf("Backend %d: expected tag env=prod, got %v", i+1, obj.Tags)
+		}
+	}
+
+	req.Body = nil
+	response = replicator.DeleteObjectTagging(context.Background(), req, routing.WriteOperationPolicy{AckLevel: "all"})
+	if response.StatusCode != http.StatusNoContent {
+		t.Fatalf("Expected status code 204, got %d", response.StatusCode)
+	}
+	for i, srv := range servers {
+		if obj, _ := srv.GetObject("object.txt"); len(obj.Tags) != 0 {
+			t.Errorf("Backend %d: expected tags to be deleted, got %v", i+1, obj.Tags)
+		}
+	}
+}
+
+func TestPutObjectTaggingMalformedXML(t *testing.T) {
+	provider, servers := backendtest.NewServers(t, 1, backend.StateUp)
+
+	replicator := NewReplicator(provider, DefaultConfig())
+	defer replicator.Stop()
+
+	req := &apigw.S3Request{
+		Bucket:  "test-bucket",
+		Key:     "object.txt",
+		Body:    io.NopCloser(strings.NewReader("<Tagging><TagSet>")),
+		Headers: http.Header{},
+	}
+
+	response := replicator.PutObjectTagging(context.Background(), req, routing.WriteOperationPolicy{AckLevel: "all"})
+	if response.StatusCode != http.StatusBadRequest {
+		t.Errorf("Expected status code 400, got %d", response.StatusCode)
+	}
+	if count := servers[0].CountRequests(http.MethodPut); count != 0 {
+		t.Errorf("Expected no requests to backend, got %d", count)
+	}
+}
+
+func TestPutObjectAutoCreateBucket(t *testing.T) {
+	server := s3mock.NewServer("test-bucket")
+	t.Cleanup(server.Close)
+
+	backendConfig := backendtest.BackendConfig(server)
+	backendConfig.Bucket = "missing-bucket"
+	backendConfig.AutoCreateBucket = true
+	provider, err := backendtest.NewManager(&backend.Config{
+		Backends: map[string]backend.BackendConfig{"backend-1": backendConfig},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create backend manager: %v", err)
+	}
+
+	replicator := NewReplicator(provider, DefaultConfig())
+	defer replicator.Stop()
+
+	data := "replicated data"
+	req := &apigw.S3Request{
+		Bucket:        "test-bucket",
+		Key:           "object.txt",
+		Body:          io.NopCloser(strings.NewReader(data)),
+		ContentLength: int64(len(data)),
+		Headers:       http.Header{},
+	}
+
+	response := replicator.PutObject(context.Background(), req, routing.WriteOperationPolicy{AckLevel: "all"})
+	if response.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status code 200, got %d", response.StatusCode)
+	}
+	if !server.HasBucket("missing-bucket") {
+		t.Fatal("Expected bucket to be created on backend")
+	}
+	if count := server.CountRequests(http.MethodPut); count != 3 {
+		t.Errorf("Expected PUT, CreateBucket and retried PUT (3 requests), got %d", count)
+	}
+
+	// Бакет создается только при первой записи
+	req.Body = io.NopCloser(strings.NewReader(data))
+	response = replicator.PutObject(context.Background(), req, routing.WriteOperationPolicy{AckLevel: "all"})
+	if response.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status code 200, got %d", response.StatusCode)
+	}
+	if count := server.CountRequests(http.MethodPut); count != 4 {
+		t.Errorf("Expected a single PUT for the second write, got %d requests in total", count)
+	}
+}
+
+func TestPutObjectAutoCreateBucketAfterDelete(t *testing.T) {
+	server := s3mock.NewServer("test-bucket")
+	t.Cleanup(server.Close)
+
+	backendConfig := backendtest.BackendConfig(server)
+	backendConfig.Bucket = "missing-bucket"
+	backendConfig.AutoCreateBucket = true
+	provider, err := backendtest.NewManager(&backend.Config{
+		Backends: map[string]backend.BackendConfig{"backend-1": backendConfig},
+	})
+	if err != nil {
+		t.Fatalf("Failed to create backend manager: %v", err)
+	}
+
+	replicator := NewReplicator(provider, bucketOperationsConfig())
+	defer replicator.Stop()
+
+	policy := routing.WriteOperationPolicy{AckLevel: "all"}
+	put := func() int {
+		t.Helper()
+		data := "replicated data"
+		req := &apigw.S3Request{
+			Bucket:        "test-bucket",
+			Key:           "object.txt",
+			Body:          io.NopCloser(strings.NewReader(data)),
+			ContentLength: int64(len(data)),
+			Headers:       http.Header{},
+		}
+		return replicator.PutObject(context.Background(), req, policy).StatusCode
+	}
+	emptyBucket := func() {
+		t.Helper()
+		req := &apigw.S3Request{Bucket: "test-bucket", Key: "object.txt", Headers: http.Header{}}
+		if status := replicator.DeleteObject(context.Background(), req, policy).StatusCode; status != http.StatusNoContent {
+			t.Fatalf("Expected DeleteObject status 204, got %d", status)
+		}
+	}
+
+	if status := put(); status != http.StatusOK {
+		t.Fatalf("Expected status code 200, got %d", status)
+	}
+
+	// Бакет, удаленный через прокси, создается заново при следующей записи
+	emptyBucket()
+	deleteReq := &apigw.S3Request{Bucket: "test-bucket", Headers: http.Header{}}
+	if status := replicator.DeleteBucket(context.Background(), deleteReq, policy).StatusCode; status != http.StatusNoContent {
+		t.Fatalf("Expected DeleteBucket status 204, got %d", status)
+	}
+	if status := put(); status != http.StatusOK {
+		t.Fatalf("Expected PUT after DeleteBucket to recreate the bucket, got %d", status)
+	}
+	if !server.HasBucket("missing-bucket") {
+		t.Fatal("Expected bucket to be recreated on backend")
+	}
+
+	// Бакет, удаленный в обход прокси: PUT получает NoSuchBucket, следующий создает бакет
+	emptyBucket()
+	b := provider.GetAllBackends()[0]
+	if _, err := b.S3Client.DeleteBucket(context.Background(), &s3.DeleteBucketInput{Bucket: aws.String("missing-bucket")}); err != nil {
+		t.Fatalf("Failed to delete bucket out of band: %v", err)
+	}
+	if status := put(); status == http.StatusOK {
+		t.Fatal("Expected PUT into a bucket deleted out of band to fail")
+	}
+	if status := put(); status != http.StatusOK {
+		t.Fatalf("Expected next PUT to recreate the bucket, got %d", status)
+	}
+	if !server.HasBucket("missing-bucket") {
+		t.Error("Expected bucket to be recreated on backend")
+	}
+}
+
+func TestPutObjectNoSuchBucketWithoutAutoCreate(t *testing.T) {
+	provider, servers := newBucketTestManager(t, 1, "missing-bucket")
+
+	replicator := NewReplicator(provider, DefaultConfig())
+	defer replicator.Stop()
+
+	data := "replicated data"
+	req := &apigw.S3Request{
+		Bucket:        "test-bucket",
+		Key:           "object.txt",
+		Body:          io.NopCloser(strings.NewReader(data)),
+		ContentLength: int64(len(data)),
+		Headers:       http.Header{},
+	}
+
+	response := replicator.PutObject(context.Background(), req, routing.WriteOperationPolicy{AckLevel: "all"})
+	if response.StatusCode == http.StatusOK {
+		t.Fatal("Expected PUT to fail without auto_create_bucket")
+	}
+	if servers[0].HasBucket("missing-bucket") {
+		t.Error("Expected bucket not to be created")
+	}
+}
+
+func TestBuildPutObjectInputSSEHeaders(t *testing.T) {
+	r := &Replicator{config: DefaultConfig()}
+	b := &backend.Backend{ID: "backend-1", Config: backend.BackendConfig{Bucket: "backend-bucket"}}
+
+	headers := http.Header{}
+	headers.Set("x-amz-server-side-encryption", "aws:kms")
+	headers.Set("x-amz-server-side-encryption-aws-kms-key-id", "arn:aws:kms:us-east-1:123456789012:key/test")
+	headers.Set("x-amz-server-side-encryption-bucket-key-enabled", "true")
+	headers.Set("x-amz-server-side-encryption-customer-algorithm", "AES256")
+	headers.Set("x-amz-server-side-encryption-customer-key", "c2VjcmV0")
+	headers.Set("x-amz-server-side-encryption-customer-key-MD5", "bWQ1")
+	req := &apigw.S3Request{Bucket: "test-bucket", Key: "object.txt", Headers: headers}
+
+	input := r.buildPutObjectInput(req, strings.NewReader(""), b)
+
+	if input.ServerSideEncryption != types.ServerSideEncryptionAwsKms {
+		t.Errorf("Expected ServerSideEncryption aws:kms, got %q", input.ServerSideEncryption)
+	}
+	if aws.ToString(input.SSEKMSKeyId) != "arn:aws:kms:us-east-1:123456789012:key/test" {
+		t.Errorf("Unexpected SSEKMSKeyId %q", aws.ToString(input.SSEKMSKeyId))
+	}
+	if !aws.ToBool(input.BucketKeyEnabled) {
+		t.Error("Expected BucketKeyEnabled to be set")
+	}
+	if aws.ToString(input.SSECustomerAlgorithm) != "AES256" || aws.ToString(input.SSECustomerKey) != "c2VjcmV0" || aws.ToString(input.SSECustomerKeyMD5) != "bWQ1" {
+		t.Errorf("Unexpected SSE-C fields: %q %q %q",
+			aws.ToString(input.SSECustomerAlgorithm), aws.ToString(input.SSECustomerKey), aws.ToString(input.SSECustomerKeyMD5))
+	}
+	if len(input.Metadata) != 0 {
+		t.Errorf("Expected SSE headers not to leak into metadata, got %v", input.Metadata)
+	}
+}
+
+func TestPutObjectObjectLockHeaders(t *testing.T) {
+	provider, servers := backendtest.NewServers(t, 2, backend.StateUp)
+	replicator := NewReplicator(provider, nil)
+	defer replicator.Stop()
+
+	data := "locked data"
+	req := &apigw.S3Request{
+		Bucket:        "test-bucket",
+		Key:           "locked.txt",
+		Body:          io.NopCloser(strings.NewReader(data)),
+		ContentLength: int64(len(data)),
+		Headers:       http.Header{},
+	}
+	req.Headers.Set("x-amz-object-lock-mode", "COMPLIANCE")
+	req.Headers.Set("x-amz-object-lock-retain-until-date", "2030-01-02T03:04:05Z")
+	req.Headers.Set("x-amz-object-lock-legal-hold", "ON")
+
+	response := replicator.PutObject(context.Background(), req, routing.WriteOperationPolicy{AckLevel: "all"})
+	if response.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status code 200, got %d", response.StatusCode)
+	}
+
+	for i, server := range servers {
+		var put *s3mock.Request
+		for _, r := range server.Requests() {
+			if r.Method == http.MethodPut && r.Key == "locked.txt" {
+				put = &r
+			}
+		}
+		if put == nil {
+			t.Fatalf("Backend %d did not receive PUT", i+1)
+		}
+		if got := put.Header.Get("x-amz-object-lock-mode"); got != "COMPLIANCE" {
+			t.Errorf("Backend %d: expected lock mode COMPLIANCE, got %q", i+1, got)
+		}
+		if got := put.Header.Get("x-amz-object-lock-retain-until-date"); got != "2030-01-02T03:04:05Z" {
+			t.Errorf("Backend %d: expected retain until date 2030-01-02T03:04:05Z, got %q", i+1, got)
+		}
+		if got := put.Header.Get("x-amz-object-lock-legal-hold"); got != "ON" {
+			t.Errorf("Backend %d: expected legal hold ON, got %q", i+1, got)
+		}
+	}
+}
+
+func TestPutObjectGuessContentType(t *testing.T) {
+	provider, servers := backendtest.NewServers(t, 1, backend.StateUp)
+	config := DefaultConfig()
+	config.GuessContentType = true
+	config.DefaultContentType = "application/octet-stream"
+	replicator := NewReplicator(provider, config)
+	defer replicator.Stop()
+
+	tests := []struct {
+		key         string
+		contentType string
+		expected    string
+	}{
+		{"site/index.html", "", "text/html; charset=utf-8"},
+		{"site/style.css", "", "text/css; charset=utf-8"},
+		{"data/blob", "", "application/octet-stream"},
+		{"site/page.html", "text/plain", "text/plain"},
+	}
+	for _, tt := range tests {
+		servers[0].ResetRequests()
+		data := "content"
+		req := &apigw.S3Request{
+			Bucket:        "test-bucket",
+			Key:           tt.key,
+			Body:          io.NopCloser(strings.NewReader(data)),
+			ContentLength: int64(len(data)),
+			Headers:       http.Header{},
+		}
+		if tt.contentType != "" {
+			req.Headers.Set("Content-Type", tt.contentType)
+		}
+
+		response := replicator.PutObject(context.Background(), req, routing.WriteOperationPolicy{AckLevel: "all"})
+		if response.StatusCode != http.StatusOK {
+			t.Fatalf("%s: expected status code 200, got %d", tt.key, response.StatusCode)
+		}
+		var put *s3mock.Request
+		for _, r := range servers[0].Requests() {
+			if r.Method == http.MethodPut && r.Key == tt.key {
+				put = &r
+			}
+		}
+		if put == nil {
+			t.Fatalf("%s: backend did not receive PUT", tt.key)
+		}
+		if got := put.Header.Get("Content-Type"); got != tt.expected {
+			t.Errorf("%s: expected Content-Type %q, got %q", tt.key, tt.expected, got)
+		}
+	}
+
+	// Без настройки Content-Type не выставляется
+	r := &Replicator{config: DefaultConfig()}
+	req := &apigw.S3Request{Key: "index.html", Headers: http.Header{}}
+	r.fillContentType(req)
+	if got := req.Headers.Get("Content-Type"); got != "" {
+		t.Errorf("Expected no Content-Type by default, got %q", got)
+	}
+}
+
+func TestConvertPutResultToResponseSSEHeaders(t *testing.T) {
+	r := &Replicator{config: DefaultConfig()}
+	result := &backend.BackendResult{Response: &s3.PutObjectOutput{
+		ETag:                 aws.String(`"etag"`),
+		ServerSideEncryption: types.ServerSideEncryptionAwsKms,
+		SSEKMSKeyId:          aws.String("key-id"),
+	}}
+
+	response := r.convertPutResultToResponse(result)
+	if got := response.Headers.Get("x-amz-server-side-encryption"); got != "aws:kms" {
+		t.Errorf("Expected x-amz-server-side-encryption aws:kms, got %q", got)
+	}
+	if got := response.Headers.Get("x-amz-server-side-encryption-aws-kms-key-id"); got != "key-id" {
+		t.Errorf("Expected KMS key id in response, got %q", got)
+	}
+}
+
+func TestPutObjectContentMD5Mismatch(t *testing.T) {
+	provider, servers := backendtest.NewServers(t, 2, backend.StateUp)
+	replicator := NewReplicator(provider, nil)
+	defer replicator.Stop()
+
+	data := "replicated data"
+	wrongSum := md5.Sum([]byte("other data"))
+	req := &apigw.S3Request{
+		Bucket:        "test-bucket",
+		Key:           "object.txt",
+		Body:          io.NopCloser(strings.NewReader(data)),
+		ContentLength: int64(len(data)),
+		Headers:       http.Header{},
+	}
+	req.Headers.Set("Content-Md5", base64.StdEncoding.EncodeToString(wrongSum[:]))
+
+	response := replicator.PutObject(context.Background(), req, routing.WriteOperationPolicy{AckLevel: "all"})
+	if response.StatusCode != http.StatusBadRequest {
+		t.Fatalf("Expected status code 400, got %d", response.StatusCode)
+	}
+	body, _ := io.ReadAll(response.Body)
+	if !strings.Contains(string(body), "<Code>BadDigest</Code>") {
+		t.Errorf("Expected BadDigest error, got %s", body)
+	}
+
+	// Несовпадение MD5 - ошибка клиента, бэкенды не должны считаться сбойными
+	if live := provider.GetLiveBackends(); len(live) != len(servers) {
+		t.Errorf("Expected all %d backends to stay live, got %d", len(servers), len(live))
+	}
+}
+
+func TestPutObjectChecksumMismatch(t *testing.T) {
+	provider, servers := backendtest.NewServers(t, 2, backend.StateUp)
+	replicator := NewReplicator(provider, nil)
+	defer replicator.Stop()
+
+	data := "replicated data"
+	checksum := func(data string) string {
+		sum := crc32.ChecksumIEEE([]byte(data))
+		return base64.StdEncoding.EncodeToString([]byte{byte(sum >> 24), byte(sum >> 16), byte(sum >> 8), byte(sum)})
+	}
+	newRequest := func(key, crc string) *apigw.S3Request {
+		req := &apigw.S3Request{
+			Bucket:        "test-bucket",
+			Key:           key,
+			Body:          io.NopCloser(strings.NewReader(data)),
+			ContentLength: int64(len(data)),
+			Headers:       http.Header{},
+		}
+		req.Headers.Set("x-amz-checksum-crc32", crc)
+		return req
+	}
+
+	// Неверная CRC32 отклоняется, объект не считается записанным
+	response := replicator.PutObject(context.Background(), newRequest("wrong.txt", checksum("other data")), routing.WriteOperationPolicy{AckLevel: "all"})
+	if response.StatusCode != http.StatusBadRequest {
+		t.Fatalf("Expected status code 400, got %d", response.StatusCode)
+	}
+	body, _ := io.ReadAll(response.Body)
+	if !strings.Contains(string(body), "<Code>BadDigest</Code>") || !strings.Contains(string(body), "CRC32") {
+		t.Errorf("Expected BadDigest error for CRC32, got %s", body)
+	}
+	for i, server := range servers {
+		if _, ok := server.GetObject("wrong.txt"); ok {
+			t.Errorf("Object with wrong checksum must not be stored on backend %d", i+1)
+		}
+	}
+	if live := provider.GetLiveBackends(); len(live) != len(servers) {
+		t.Errorf("Expected all %d backends to stay live, got %d", len(servers), len(live))
+	}
+
+	// Верная CRC32 передается бэкендам для хранения вместе с объектом
+	response = replicator.PutObject(context.Background(), newRequest("right.txt", checksum(data)), routing.WriteOperationPolicy{AckLevel: "all"})
+	if response.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status code 200, got %d", response.StatusCode)
+	}
+	for i, server := range servers {
+		for _, r := range server.Requests() {
+			if r.Method == http.MethodPut && r.Key == "right.txt" && r.Header.Get("x-amz-checksum-crc32") != checksum(data) {
+				t.Errorf("Expected x-amz-checksum-crc32 %q on backend %d, got %q", checksum(data), i+1, r.Header.Get("x-amz-checksum-crc32"))
+			}
+		}
+	}
+}
+
+func TestPutObjectCreateOnly(t *testing.T) {
+	provider, servers := backendtest.NewServers(t, 2, backend.StateUp)
+	replicator := NewReplicator(provider, nil)
+	defer replicator.Stop()
+
+	put := func(key, data string) *apigw.S3Response {
+		req := &apigw.S3Request{
+			Bucket:        "test-bucket",
+			Key:           key,
+			Body:          io.NopCloser(strings.NewReader(data)),
+			ContentLength: int64(len(data)),
+			Headers:       http.Header{"If-None-Match": []string{"*"}},
+		}
+		return replicator.PutObject(context.Background(), req, routing.WriteOperationPolicy{AckLevel: "all"})
+	}
+
+	// Новый ключ создается на всех бэкендах
+	if response := put("object.txt", "first"); response.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status code 200 for the first write, got %d", response.StatusCode)
+	}
+
+	// Повторное создание отклоняется, объект не перезаписывается
+	response := put("object.txt", "second")
+	if response.StatusCode != http.StatusPreconditionFailed {
+		t.Fatalf("Expected status code 412 for the second write, got %d", response.StatusCode)
+	}
+	body, _ := io.ReadAll(response.Body)
+	if !strings.Contains(string(body), "<Code>PreconditionFailed</Code>") {
+		t.Errorf("Expected PreconditionFailed error, got %s", body)
+	}
+	for i, server := range servers {
+		if object, ok := server.GetObject("object.txt"); !ok || string(object.Data) != "first" {
+			t.Errorf("Expected backend-%d to keep the first version", i+1)
+		}
+	}
+
+	// При ack=all достаточно одного бэкенда, где ключ уже есть
+	servers[1].SetObject("partial.txt", []byte("existing"), time.Time{})
+	if response := put("partial.txt", "new"); response.StatusCode != http.StatusPreconditionFailed {
+		t.Errorf("Expected status code 412 when the key exists on one backend, got %d", response.StatusCode)
+	}
+
+	// Невыполненное условие - ошибка клиента, бэкенды остаются живыми
+	if live := provider.GetLiveBackends(); len(live) != len(servers) {
+		t.Errorf("Expected all %d backends to stay live, got %d", len(servers), len(live))
+	}
+}
+
+func TestPutObjectContentMD5Match(t *testing.T) {
+	provider, servers := backendtest.NewServers(t, 2, backend.StateUp)
+	replicator := NewReplicator(provider, nil)
+	defer replicator.Stop()
+
+	data := "replicated data"
+	sum := md5.Sum([]byte(data))
+	req := &apigw.S3Request{
+		Bucket:        "test-bucket",
+		Key:           "object.txt",
+		Body:          io.NopCloser(strings.NewReader(data)),
+		ContentLength: int64(len(data)),
+		Headers:       http.Header{},
+	}
+	req.Headers.Set("Content-Md5", base64.StdEncoding.EncodeToString(sum[:]))
+
+	response := replicator.PutObject(context.Background(), req, routing.WriteOperationPolicy{AckLevel: "all"})
+	if response.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status code 200, got %d", response.StatusCode)
+	}
+	for i, srv := range servers {
+		if obj, exists := srv.GetObject("object.txt"); !exists || string(obj.Data) != data {
+			t.Errorf("Backend %d: expected object %q to be stored", i+1, data)
+		}
+	}
+}
+
+func TestPutObjectSpillToTempFile(t *testing.T) {
+	provider, servers := backendtest.NewServers(t, 3, backend.StateUp)
+
+	config := DefaultConfig()
+	config.SpillThreshold = 1024 * 1024
+	config.SpillDir = t.TempDir()
+	replicator := NewReplicator(provider, config)
+	defer replicator.Stop()
+
+	data := make([]byte, 10*1024*1024)
+	for i := range data {
+		data[i] = byte(i % 251)
+	}
+	req := &apigw.S3Request{
+		Bucket:        "test-bucket",
+		Key:           "large-object.bin",
+		Body:          io.NopCloser(bytes.NewReader(data)),
+		ContentLength: int64(len(data)),
+		Headers:       http.Header{},
+	}
+
+	response := replicator.PutObject(context.Background(), req, routing.WriteOperationPolicy{AckLevel: "all"})
+	if response.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status code 200, got %d", response.StatusCode)
+	}
+
+	for i, srv := range servers {
+		obj, exists := srv.GetObject("large-object.bin")
+		if !exists {
+			t.Errorf("Backend %d: object not found", i+1)
+			continue
+		}
+		if !bytes.Equal(obj.Data, data) {
+			t.Errorf("Backend %d: stored %d bytes differ from the uploaded %d bytes", i+1, len(obj.Data), len(data))
+		}
+	}
+
+	// Временный файл удаляется после завершения записи на все бэкенды
+	entries, err := os.ReadDir(config.SpillDir)
+	if err != nil {
+		t.Fatalf("Failed to read spill dir: %v", err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("Expected spill file to be removed, found %d entries", len(entries))
+	}
+}
+
+func TestPutObjectUnknownContentLength(t *testing.T) {
+	// Бэкенд по HTTPS пишется обычным клиентом, по HTTP - StreamingPutClient
+	config := &backend.Config{Backends: make(map[string]backend.BackendConfig)}
+	servers := []*s3mock.Server{s3mock.NewTLSServer("test-bucket"), s3mock.NewServer("test-bucket")}
+	for i, srv := range servers {
+		t.Cleanup(srv.Close)
+		backendConfig := backendtest.BackendConfig(srv)
+		backendConfig.InsecureSkipVerify = true
+		config.Backends[fmt.Sprintf("backend-%d", i+1)] = backendConfig
+	}
+	provider, err := backendtest.NewManager(config)
+	if err != nil {
+		t.Fatalf("Failed to create backend manager: %v", err)
+	}
+	replicator := NewReplicator(provider, nil)
+	defer replicator.Stop()
+
+	// Тело приходит частями без Content-Length, как при Transfer-Encoding: chunked
+	chunk := strings.Repeat("0123456789abcdef", 4096)
+	const chunks = 64
+	body, writer := io.Pipe()
+	go func() {
+		for i := 0; i < chunks; i++ {
+			if _, err := writer.Write([]byte(chunk)); err != nil {
+				return
+			}
+		}
+		writer.Close()
+	}()
+	req := &apigw.S3Request{
+		Bucket:        "test-bucket",
+		Key:           "chunked.bin",
+		Body:          body,
+		ContentLength: -1,
+		Headers:       http.Header{},
+	}
+
+	response := replicator.PutObject(context.Background(), req, routing.WriteOperationPolicy{AckLevel: "all"})
+	if response.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status code 200, got %d (%v)", response.StatusCode, response.Error)
+	}
+
+	expected := strings.Repeat(chunk, chunks)
+	for i, srv := range servers {
+		obj, exists := srv.GetObject("chunked.bin")
+		if !exists {
+			t.Errorf("Backend %d: object not found", i+1)
+			continue
+		}
+		if string(obj.Data) != expected {
+			t.Errorf("Backend %d: stored %d bytes differ from the uploaded %d bytes", i+1, len(obj.Data), len(expected))
+		}
+
+		// Тело передается потоком как есть: без Content-Length и без aws-chunked обертки
+		for _, r := range srv.Requests() {
+			if r.Method != http.MethodPut {
+				continue
+			}
+			if value := r.Header.Get("Content-Length"); value != "" {
+				t.Errorf("Backend %d: expected streamed body without Content-Length, got %s", i+1, value)
+			}
+			if value := r.Header.Get("Content-Encoding"); value != "" {
+				t.Errorf("Backend %d: expected body without Content-Encoding, got %s", i+1, value)
+			}
+		}
+	}
+	if puts := servers[0].Requests(); len(puts) == 0 || puts[0].Header.Get("X-Amz-Content-Sha256") != "UNSIGNED-PAYLOAD" {
+		t.Errorf("Expected HTTPS backend to receive UNSIGNED-PAYLOAD")
+	}
+}
+
+func TestPutObjectErrorSummary(t *testing.T) {
+	provider, servers := backendtest.NewServers(t, 2, backend.StateUp)
+
+	// backend-1 не отвечает до истечения таймаута операции, backend-2 отказывает в доступе
+	release := make(chan struct{})
+	defer close(release)
+	servers[0].SetIntercept(func(w http.ResponseWriter, r *http.Request) bool {
+		io.Copy(io.Discard, r.Body)
+		select {
+		case <-release:
+		case <-r.Context().Done():
+		}
+		return true
+	})
+	servers[1].SetIntercept(func(w http.ResponseWriter, r *http.Request) bool {
+		w.Header().Set("Content-Type", "application/xml")
+		w.WriteHeader(http.StatusForbidden)
+		fmt.Fprint(w, `<?xml version="1.0" encoding="UTF-8"?><Error><Code>AccessDenied</Code><Message>Access Denied</Message></Error>`)
+		return true
+	})
+
+	config := DefaultConfig()
+	config.OperationTimeout = 200 * time.Millisecond
+	replicator := NewReplicator(provider, config)
+	defer replicator.Stop()
+
+	data := "replicated data"
+	req := &apigw.S3Request{
+		Bucket:        "test-bucket",
+		Key:           "object.txt",
+		Body:          io.NopCloser(strings.NewReader(data)),
+		ContentLength: int64(len(data)),
+		Headers:       http.Header{},
+	}
+
+	response := replicator.PutObject(context.Background(), req, routing.WriteOperationPolicy{AckLevel: "one"})
+	if response.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("Expected status code 503, got %d", response.StatusCode)
+	}
+
+	body, _ := io.ReadAll(response.Body)
+	for _, expected := range []string{"2 backend error(s)", "AccessDenied x1 (backend-2", "Timeout x1 (backend-1"} {
+		if !strings.Contains(string(body), expected) {
+			t.Errorf("Expected error summary to contain %q, got %s", expected, body)
+		}
+	}
+}
+
+func TestPutObjectVerifyAfterWrite(t *testing.T) {
+	provider, servers := backendtest.NewServers(t, 2, backend.StateUp)
+	replicator := NewReplicator(provider, nil)
+	defer replicator.Stop()
+
+	newRequest := func(data string) *apigw.S3Request {
+		return &apigw.S3Request{
+			Bucket:        "test-bucket",
+			Key:           "object.txt",
+			Body:          io.NopCloser(strings.NewReader(data)),
+			ContentLength: int64(len(data)),
+			Headers:       http.Header{},
+		}
+	}
+	policy := routing.WriteOperationPolicy{AckLevel: "all", VerifyAfterWrite: true}
+
+	// Все бэкенды отдают записанный объект
+	response := replicator.PutObject(context.Background(), newRequest("replicated data"), policy)
+	if response.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status code 200, got %d", response.StatusCode)
+	}
+	for i, server := range servers {
+		heads := 0
+		for _, r := range server.Requests() {
+			if r.Method == http.MethodHead && r.Key == "object.txt" {
+				heads++
+			}
+		}
+		if heads != 1 {
+			t.Errorf("Backend %d: expected 1 HEAD after write, got %d", i+1, heads)
+		}
+	}
+
+	// Второй бэкенд подтвердил запись, но HEAD отдает другую версию объекта
+	servers[1].SetIntercept(func(w http.ResponseWriter, r *http.Request) bool {
+		if r.Method != http.MethodHead {
+			return false
+		}
+		w.Header().Set("ETag", `"00000000000000000000000000000000"`)
+		w.Header().Set("Content-Length", "0")
+		w.WriteHeader(http.StatusOK)
+		return true
+	})
+	response = replicator.PutObject(context.Background(), newRequest("new data"), policy)
+	if response.StatusCode != http.StatusInternalServerError {
+		t.Fatalf("Expected status code 500, got %d", response.StatusCode)
+	}
+	body, _ := io.ReadAll(response.Body)
+	if !strings.Contains(string(body), "ReadAfterWriteMismatch") || !strings.Contains(string(body), "backend-2") {
+		t.Errorf("Expected read-after-write mismatch of backend-2 in error, got %s", body)
+	}
+
+	// Без verify_after_write HEAD не выполняется
+	response = replicator.PutObject(context.Background(), newRequest("new data"), routing.WriteOperationPolicy{AckLevel: "all"})
+	if response.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status code 200 without verification, got %d", response.StatusCode)
+	}
+}
+
+func TestPutObjectVerifyETag(t *testing.T) {
+	provider, servers := backendtest.NewServers(t, 2, backend.StateUp)
+
+	// Второй бэкенд принимает тело, но возвращает чужой ETag
+	servers[1].SetIntercept(func(w http.ResponseWriter, r *http.Request) bool {
+		if r.Method != http.MethodPut {
+			return false
+		}
+		io.Copy(io.Discard, r.Body)
+		w.Header().Set("ETag", `"00000000000000000000000000000000"`)
+		w.WriteHeader(http.StatusOK)
+		return true
+	})
+
+	config := DefaultConfig()
+	config.VerifyETag = true
+	replicator := NewReplicator(provider, config)
+	defer replicator.Stop()
+
+	newRequest := func(data string) *apigw.S3Request {
+		return &apigw.S3Request{
+			Bucket:        "test-bucket",
+			Key:           "object.txt",
+			Body:          io.NopCloser(strings.NewReader(data)),
+			ContentLength: int64(len(data)),
+			Headers:       http.Header{},
+		}
+	}
+
+	response := replicator.PutObject(context.Background(), newRequest("replicated data"), routing.WriteOperationPolicy{AckLevel: "all"})
+	if response.StatusCode != http.StatusInternalServerError {
+		t.Fatalf("Expected status code 500, got %d", response.StatusCode)
+	}
+	body, _ := io.ReadAll(response.Body)
+	if !strings.Contains(string(body), "ETagMismatch") || !strings.Contains(string(body), "backend-2") {
+		t.Errorf("Expected ETag mismatch of backend-2 in error, got %s", body)
+	}
+
+	// ack=one не проверяется: достаточно первого успешного ответа
+	response = replicator.PutObject(context.Background(), newRequest("replicated data"), routing.WriteOperationPolicy{AckLevel: "one"})
+	if response.StatusCode != http.StatusOK {
+		t.Errorf("Expected status code 200 for ack=one, got %d", response.StatusCode)
+	}
+
+	// Совпадающие ETag не мешают записи
+	servers[1].SetIntercept(nil)
+	response = replicator.PutObject(context.Background(), newRequest("replicated data"), routing.WriteOperationPolicy{AckLevel: "all"})
+	if response.StatusCode != http.StatusOK {
+		t.Errorf("Expected status code 200 when ETags match, got %d", response.StatusCode)
+	}
+}
+
+func TestPutObjectContentTypeRouting(t *testing.T) {
+	provider, servers := backendtest.NewServers(t, 3, backend.StateUp)
+
+	replicator := NewReplicator(provider, DefaultConfig())
+	defer replicator.Stop()
+
+	policy := routing.WriteOperationPolicy{
+		AckLevel: "all",
+		ContentTypeBackends: map[string][]string{
+			"image/*":    {"backend-1", "backend-2"},
+			"text/plain": {"backend-3"},
+		},
+	}
+
+	put := func(key, contentType string) {
+		t.Helper()
+		req := &apigw.S3Request{
+			Bucket:        "test-bucket",
+			Key:           key,
+			Body:          io.NopCloser(strings.NewReader("data")),
+			ContentLength: 4,
+			Headers:       http.Header{"Content-Type": []string{contentType}},
+		}
+		if response := replicator.PutObject(context.Background(), req, policy); response.StatusCode != http.StatusOK {
+			t.Fatalf("PUT %s: expected status code 200, got %d", key, response.StatusCode)
+		}
+	}
+
+	// image/png попадает в группу image/* и пишется только на бэкенды изображений
+	put("photo.png", "image/png; charset=binary")
+	for i, expected := range []bool{true, true, false} {
+		if _, exists := servers[i].GetObject("photo.png"); exists != expected {
+			t.Errorf("photo.png on backend-%d: expected exists=%v", i+1, expected)
+		}
+	}
+
+	// Тип без настроенного набора пишется на все бэкенды
+	put("data.bin", "application/octet-stream")
+	for i := range servers {
+		if _, exists := servers[i].GetObject("data.bin"); !exists {
+			t.Errorf("data.bin: expected object on backend-%d", i+1)
+		}
+	}
+}
+
+func TestPutObjectSizeRouting(t *testing.T) {
+	config := &backend.Config{
+		Manager:  backend.DefaultManagerConfig(),
+		Backends: make(map[string]backend.BackendConfig),
+	}
+	config.Manager.InitialState = backend.StateUp
+	servers := make([]*s3mock.Server, 3)
+	for i := range servers {
+		servers[i] = s3mock.NewServer("test-bucket")
+		t.Cleanup(servers[i].Close)
+		config.Backends[fmt.Sprintf("backend-%d", i+1)] = backendtest.BackendConfig(servers[i])
+	}
+	ssd := config.Backends["backend-1"]
+	ssd.MaxObjectSize = 1 << 20 // Только мелкие объекты
+	config.Backends["backend-1"] = ssd
+	capacity := config.Backends["backend-2"]
+	capacity.MinObjectSize = 1 << 20 // Только крупные объекты
+	config.Backends["backend-2"] = capacity
+	provider, err := backendtest.NewManager(config)
+	if err != nil {
+		t.Fatalf("Failed to create backend manager: %v", err)
+	}
+
+	ids := func(backends []*backend.Backend) map[string]bool {
+		result := make(map[string]bool)
+		for _, b := range backends {
+			result[b.ID] = true
+		}
+		return result
+	}
+
+	// Объект в 1GB не попадает на бэкенд мелких объектов
+	large := &apigw.S3Request{Bucket: "test-bucket", Key: "large.bin", ContentLength: 1 << 30}
+	selected := ids(selectBySize(large, provider.GetLiveBackends()))
+	if selected["backend-1"] || !selected["backend-2"] || !selected["backend-3"] || len(selected) != 2 {
+		t.Errorf("Expected 1GB object to go to backend-2 and backend-3, got %v", selected)
+	}
+
+	// Если объект не подходит ни одному бэкенду, запись идет на все
+	ssdOnly, _ := provider.GetBackend("backend-1")
+	if fallback := selectBySize(large, []*backend.Backend{ssdOnly}); len(fallback) != 1 {
+		t.Errorf("Expected fallback to the only backend, got %d backends", len(fallback))
+	}
+
+	// Размер неизвестен - ограничения не применяются
+	unknown := &apigw.S3Request{Bucket: "test-bucket", Key: "stream.bin", ContentLength: -1}
+	if got := selectBySize(unknown, provider.GetLiveBackends()); len(got) != 3 {
+		t.Errorf("Expected all backends for object of unknown size, got %d", len(got))
+	}
+
+	// Мелкий объект записывается на бэкенды без нижней границы размера
+	replicator := NewReplicator(provider, DefaultConfig())
+	defer replicator.Stop()
+	req := &apigw.S3Request{
+		Bucket:        "test-bucket",
+		Key:           "small.txt",
+		Body:          io.NopCloser(strings.NewReader("data")),
+		ContentLength: 4,
+		Headers:       http.Header{},
+	}
+	if response := replicator.PutObject(context.Background(), req, routing.WriteOperationPolicy{AckLevel: "all"}); response.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status code 200, got %d", response.StatusCode)
+	}
+	for i, expected := range []bool{true, false, true} {
+		if _, exists := servers[i].GetObject("small.txt"); exists != expected {
+			t.Errorf("small.txt on backend-%d: expected exists=%v", i+1, expected)
+		}
+	}
+}
+
+func TestOperationSemaphoresAreIndependent(t *testing.T) {
+	provider, servers := backendtest.NewServers(t, 1, backend.StateUp)
+	config := DefaultConfig()
+	config.MaxConcurrentPuts = 1
+	config.MaxConcurrentDeletes = 1
+	replicator := NewReplicator(provider, config)
+	defer replicator.Stop()
+
+	if cap(replicator.semaphores.part) != config.MaxConcurrentOperations {
+		t.Errorf("Expected part limit to default to %d, got %d", config.MaxConcurrentOperations, cap(replicator.semaphores.part))
+	}
+
+	// Лимит PUT исчерпан
+	replicator.semaphores.put <- struct{}{}
+
+	putDone := make(chan *apigw.S3Response, 1)
+	go func() {
+		putDone <- replicator.PutObject(context.Background(), &apigw.S3Request{
+			Bucket:        "test-bucket",
+			Key:           "object.txt",
+			Body:          io.NopCloser(strings.NewReader("data")),
+			ContentLength: 4,
+			Headers:       http.Header{},
+		}, routing.WriteOperationPolicy{AckLevel: "all"})
+	}()
+
+	// DELETE не ждет освобождения лимита PUT
+	deleteDone := make(chan *apigw.S3Response, 1)
+	go func() {
+		deleteDone <- replicator.DeleteObject(context.Background(), &apigw.S3Request{
+			Bucket:  "test-bucket",
+			Key:     "other.txt",
+			Headers: http.Header{},
+		}, routing.WriteOperationPolicy{AckLevel: "all"})
+	}()
+	select {
+	case response := <-deleteDone:
+		if response.StatusCode != http.StatusNoContent {
+			t.Errorf("Expected DELETE status code 204, got %d", response.StatusCode)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("DELETE was blocked by the PUT limit")
+	}
+
+	select {
+	case <-putDone:
+		t.Fatal("PUT must wait while its limit is exhausted")
+	case <-time.After(50 * time.Millisecond):
+	}
+	if servers[0].CountRequests(http.MethodPut) != 0 {
+		t.Error("PUT must not reach the backend while its limit is exhausted")
+	}
+
+	<-replicator.semaphores.put
+	select {
+	case response := <-putDone:
+		if response.StatusCode != http.StatusOK {
+			t.Errorf("Expected PUT status code 200, got %d", response.StatusCode)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("PUT did not complete after the limit was released")
+	}
+}
+
+func TestMultipartMalformedQuery(t *testing.T) {
+	provider, _ := backendtest.NewServers(t, 1, backend.StateUp)
+
+	replicator := NewReplicator(provider, DefaultConfig())
+	defer replicator.Stop()
+
+	policy := routing.WriteOperationPolicy{AckLevel: "all"}
+	operations := map[string]func(context.Context, *apigw.S3Request, routing.WriteOperationPolicy) *apigw.S3Response{
+		"UploadPart":              replicator.UploadPart,
+		"CompleteMultipartUpload": replicator.CompleteMultipartUpload,
+		"AbortMultipartUpload":    replicator.AbortMultipartUpload,
+	}
+
+	tests := []struct {
+		name       string
+		query      url.Values
+		uploadOnly bool // Ошибка только для UploadPart (partNumber не используется остальными)
+	}{
+		{"uploadId present without values", url.Values{"uploadId": {}, "partNumber": {"1"}}, false},
+		{"empty uploadId", url.Values{"uploadId": {""}, "partNumber": {"1"}}, false},
+		{"partNumber present without values", url.Values{"uploadId": {"proxy-1"}, "partNumber": {}}, true},
+		{"non-numeric partNumber", url.Values{"uploadId": {"proxy-1"}, "partNumber": {"abc"}}, true},
+		{"partNumber zero", url.Values{"uploadId": {"proxy-1"}, "partNumber": {"0"}}, true},
+		{"partNumber above 10000", url.Values{"uploadId": {"proxy-1"}, "partNumber": {"10001"}}, true},
+	}
+
+	for _, tt := range tests {
+		for name, op := range operations {
+			if tt.uploadOnly && name != "UploadPart" {
+				continue
+			}
+			t.Run(name+"/"+tt.name, func(t *testing.T) {
+				req := &apigw.S3Request{
+					Bucket:  "test-bucket",
+					Key:     "object.bin",
+					Query:   tt.query,
+					Headers: http.Header{},
+					Body:    io.NopCloser(strings.NewReader("data")),
+				}
+				response := op(context.Background(), req, policy)
+				if response.StatusCode != http.StatusBadRequest {
+					t.Fatalf("Expected status code 400, got %d", response.StatusCode)
+				}
+				body, _ := io.ReadAll(response.Body)
+				if !strings.Contains(string(body), "<Code>InvalidArgument</Code>") {
+					t.Errorf("Expected InvalidArgument error, got %s", body)
+				}
+			})
+		}
+	}
+}
+
+func TestPutObjectFailover(t *testing.T) {
+	config := &backend.Config{
+		Manager:  backend.DefaultManagerConfig(),
+		Backends: make(map[string]backend.BackendConfig),
+	}
+	config.Manager.InitialState = backend.StateUp
+	servers := make([]*s3mock.Server, 3)
+	for i := range servers {
+		servers[i] = s3mock.NewServer("test-bucket")
+		t.Cleanup(servers[i].Close)
+		backendConfig := backendtest.BackendConfig(servers[i])
+		backendConfig.Weight = 10 * (3 - i) // backend-1 - основной
+		config.Backends[fmt.Sprintf("backend-%d", i+1)] = backendConfig
+	}
+	provider, err := backendtest.NewManager(config)
+	if err != nil {
+		t.Fatalf("Failed to create backend manager: %v", err)
+	}
+	replicator := NewReplicator(provider, nil)
+	defer replicator.Stop()
+
+	newRequest := func(key, data string) *apigw.S3Request {
+		return &apigw.S3Request{
+			Bucket:        "test-bucket",
+			Key:           key,
+			Body:          io.NopCloser(strings.NewReader(data)),
+			ContentLength: int64(len(data)),
+			Headers:       http.Header{},
+		}
+	}
+	puts := func(server *s3mock.Server, key string) int {
+		count := 0
+		for _, r := range server.Requests() {
+			if r.Method == http.MethodPut && r.Key == key {
+				count++
+			}
+		}
+		return count
+	}
+	policy := routing.WriteOperationPolicy{AckLevel: "one", Failover: true}
+
+	// Основной бэкенд доступен: объект пишется только на него
+	response := replicator.PutObject(context.Background(), newRequest("primary.txt", "primary data"), policy)
+	if response.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status code 200, got %d", response.StatusCode)
+	}
+	if puts(servers[0], "primary.txt") != 1 || puts(servers[1], "primary.txt") != 0 || puts(servers[2], "primary.txt") != 0 {
+		t.Errorf("Expected a single PUT to the primary backend, got %d/%d/%d",
+			puts(servers[0], "primary.txt"), puts(servers[1], "primary.txt"), puts(servers[2], "primary.txt"))
+	}
+
+	// Основной бэкенд отвечает ошибкой: объект с тем же телом пишется на следующий по весу
+	servers[0].SetIntercept(func(w http.ResponseWriter, r *http.Request) bool {
+		if r.Method != http.MethodPut {
+			return false
+		}
+		io.Copy(io.Discard, r.Body)
+		w.WriteHeader(http.StatusForbidden)
+		w.Write([]byte(`<Error><Code>AccessDenied</Code><Message>Access Denied</Message></Error>`))
+		return true
+	})
+	response = replicator.PutObject(context.Background(), newRequest("failover.txt", "failover data"), policy)
+	if response.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status code 200 from the secondary backend, got %d", response.StatusCode)
+	}
+	if obj, ok := servers[1].GetObject("failover.txt"); !ok || string(obj.Data) != "failover data" {
+		t.Errorf("Expected full body to be written to the secondary backend, got %+v", obj)
+	}
+	if puts(servers[2], "failover.txt") != 0 {
+		t.Error("Expected no write to the third backend after the secondary succeeded")
+	}
+
+	// Все бэкенды отвечают ошибкой
+	for _, server := range servers[1:] {
+		server.SetIntercept(func(w http.ResponseWriter, r *http.Request) bool {
+			if r.Method != http.MethodPut {
+				return false
+			}
+			io.Copy(io.Discard, r.Body)
+			w.WriteHeader(http.StatusForbidden)
+			return true
+		})
+	}
+	response = replicator.PutObject(context.Background(), newRequest("lost.txt", "lost data"), policy)
+	if response.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("Expected status code 503 when all backends fail, got %d", response.StatusCode)
+	}
+}
+
+func TestAsyncReplicationDeliversAfterRecovery(t *testing.T) {
+	config := &backend.Config{
+		Manager:  backend.DefaultManagerConfig(),
+		Backends: make(map[string]backend.BackendConfig),
+	}
+	config.Manager.InitialState = backend.StateUp
+	config.Manager.HealthCheckInterval = 50 * time.Millisecond
+	config.Manager.CheckTimeout = 40 * time.Millisecond
+	config.Manager.FailureThreshold = 1
+	config.Manager.SuccessThreshold = 1
+	servers := make([]*s3mock.Server, 2)
+	for i := range servers {
+		servers[i] = s3mock.NewServer("test-bucket")
+		t.Cleanup(servers[i].Close)
+		config.Backends[fmt.Sprintf("backend-%d", i+1)] = backendtest.BackendConfig(servers[i])
+	}
+	provider, err := backendtest.NewManager(config)
+	if err != nil {
+		t.Fatalf("Failed to create backend manager: %v", err)
+	}
+
+	// backend-2 недоступен: отвечает 503 на все запросы, включая проверки здоровья
+	servers[1].SetIntercept(func(w http.ResponseWriter, r *http.Request) bool {
+		io.Copy(io.Discard, r.Body)
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return true
+	})
+	if err := provider.Start(); err != nil {
+		t.Fatalf("Failed to start backend manager: %v", err)
+	}
+	defer provider.Stop()
+
+	replicatorConfig := DefaultConfig()
+	replicatorConfig.AsyncReplication = true
+	replicatorConfig.AsyncQueueDir = t.TempDir()
+	replicatorConfig.AsyncRetryDelay = 20 * time.Millisecond
+	replicatorConfig.AsyncMaxRetryDelay = 100 * time.Millisecond
+	replicator := NewReplicator(provider, replicatorConfig)
+	defer replicator.Stop()
+
+	waitFor := func(what string, condition func() bool) {
+		t.Helper()
+		deadline := time.Now().Add(5 * time.Second)
+		for !condition() {
+			if time.Now().After(deadline) {
+				t.Fatalf("Timed out waiting for %s", what)
+			}
+			time.Sleep(10 * time.Millisecond)
+		}
+	}
+	waitFor("backend-2 to go down", func() bool {
+		b, _ := provider.GetBackend("backend-2")
+		return b.GetState() == backend.StateDown
+	})
+
+	data := "replicated later"
+	response := replicator.PutObject(context.Background(), &apigw.S3Request{
+		Bucket:        "test-bucket",
+		Key:           "async.txt",
+		Body:          io.NopCloser(strings.NewReader(data)),
+		ContentLength: int64(len(data)),
+		Headers:       http.Header{"Content-Type": []string{"text/plain"}},
+	}, routing.WriteOperationPolicy{AckLevel: "one"})
+	if response.StatusCode != http.StatusOK {
+		t.Fatalf("Expected status code 200, got %d", response.StatusCode)
+	}
+	waitFor("the object to be queued", func() bool { return replicator.queuedReplications() == 1 })
+	if _, ok := servers[1].GetObject("async.txt"); ok {
+		t.Fatal("Object must not reach backend-2 while it is down")
+	}
+
+	// backend-2 восстановился: объект доставляется из очереди
+	servers[1].SetIntercept(nil)
+	waitFor("the object to be replicated to backend-2", func() bool {
+		object, ok := servers[1].GetObject("async.txt")
+		return ok && string(object.Data) == data
+	})
+	waitFor("the queue to drain", func() bool { return replicator.queuedReplications() == 0 })
+
+	entries, err := os.ReadDir(replicatorConfig.AsyncQueueDir)
+	if err != nil {
+		t.Fatalf("Failed to read queue dir: %v", err)
+	}
+	if len(entries) != 0 {
+		t.Errorf("Expected queue dir to be empty after delivery, got %d files", len(entries))
+	}
+}
+
+// TestAsyncReplicationSupersedeCancelsDelivery проверяет, что перезапись ключа прерывает
+// выполняющуюся доставку старой версии, а остановка очереди не ждет зависший бэкенд
+func TestAsyncReplicationSupersedeCancelsDelivery(t *testing.T) {
+	config := &backend.Config{
+		Manager:  backend.DefaultManagerConfig(),
+		Backends: make(map[string]backend.BackendConfig),
+	}
+	config.Manager.InitialState = backend.StateUp
+	config.Manager.FailureThreshold = 100
+	servers := make([]*s3mock.Server, 2)
+	for i := range servers {
+		servers[i] = s3mock.NewServer("test-bucket")
+		t.Cleanup(servers[i].Close)
+		config.Backends[fmt.Sprintf("backend-%d", i+1)] = backendtest.BackendConfig(servers[i])
+	}
+	provider, err := backendtest.NewManager(config)
+	if err != nil {
+		t.Fatalf("Failed to create backend manager: %v", err)
+	}
+
+	// Первая запись на backend-2 отклоняется, доставки из очереди зависают до конца теста
+	var putCount atomic.Int32
+	started := make(chan struct{}, 10)
+	release := make(chan struct{})
+	servers[1].SetIntercept(func(w http.ResponseWriter, r *http.Request) bool {
+		if r.Method != http.MethodPut {
+			return false
+		}
+		if putCount.Add(1) == 1 {
+			io.Copy(io.Discard, r.Body)
+			w.WriteHeader(http.StatusInternalServerError)
+			return true
+		}
+		io.Copy(io.Discard, r.Body)
+		started <- struct{}{}
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return true
+	})
+
+	replicatorConfig := DefaultConfig()
+	replicatorConfig.AsyncReplication = true
+	replicatorConfig.AsyncQueueDir = t.TempDir()
+	replicatorConfig.AsyncRetryDelay = 20 * time.Millisecond
+	replicatorConfig.AsyncMaxRetryDelay = 50 * time.Millisecond
+	replicatorConfig.RetryAttempts = 0
+	replicator := NewReplicator(provider, replicatorConfig)
+	stopped := false
+	t.Cleanup(func() {
+		if !stopped {
+			replicator.Stop()
+		}
+	})
+	// Выполняется до остановки репликатора: зависшие запросы не держат тест при ошибке
+	t.Cleanup(func() { close(release) })
+
+	put := func(key string) {
+		t.Helper()
+		data := "old version"
+		response := replicator.PutObject(context.Background(), &apigw.S3Request{
+			Bucket:        "test-bucket",
+			Key:           key,
+			Body:          io.NopCloser(strings.NewReader(data)),
+			ContentLength: int64(len(data)),
+			Headers:       http.Header{},
+		}, routing.WriteOperationPolicy{AckLevel: "one"})
+		if response.StatusCode != http.StatusOK {
+			t.Fatalf("Expected status code 200, got %d", response.StatusCode)
+		}
+	}
+	waitFor := func(what string, ch <-chan struct{}) {
+		t.Helper()
+		select {
+		case <-ch:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Timed out waiting for %s", what)
+		}
+	}
+
+	// Перезапись ключа прерывает доставку старой версии: доставка освобождает место
+	// в лимите записей, не дожидаясь ответа зависшего бэкенда
+	put("superseded.txt")
+	waitFor("delivery to start", started)
+	replicator.supersedeReplication("test-bucket", "superseded.txt")
+	deadline := time.Now().Add(5 * time.Second)
+	for len(replicator.semaphores.put) > 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("Timed out waiting for superseded delivery to be cancelled")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	// Остановка прерывает доставку на зависший бэкенд, не дожидаясь OperationTimeout
+	putCount.Store(0)
+	put("stop.txt")
+	waitFor("delivery to start", started)
+	done := make(chan struct{})
+	go func() {
+		replicator.Stop()
+		close(done)
+	}()
+	stopped = true
+	waitFor("replicator to stop", done)
+}
+
+func TestExpiredMultipartUploadIsAborted(t *testing.T) {
+	manager, servers := backendtest.NewServers(t, 2, backend.StateUp)
+	config := DefaultConfig()
+	config.MultipartUploadTTL = 100 * time.Millisecond
+	config.CleanupInterval = 50 * time.Millisecond
+	replicator := NewReplicator(manager, config)
+	defer replicator.Stop()
+
+	uploadID := startMultipartUpload(t, replicator, "expiring-key")
+	for i, server := range servers {
+		if server.PendingUploads() != 1 {
+			t.Fatalf("Expected 1 pending upload on backend %d, got %d", i+1, server.PendingUploads())
+		}
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	for servers[0].PendingUploads()+servers[1].PendingUploads() > 0 && time.Now().Before(deadline) {
+		time.Sleep(20 * time.Millisecond)
+	}
+	for i, server := range servers {
+		if server.PendingUploads() != 0 {
+			t.Errorf("Expected expired upload to be aborted on backend %d", i+1)
+		}
+	}
+	if err := replicator.AbortUpload(context.Background(), uploadID); !errors.Is(err, ErrUploadNotFound) {
+		t.Errorf("Expected ErrUploadNotFound for expired upload, got %v", err)
+	}
+}
+
+func TestCompleteMultipartUploadPartialFailureKeepsUpload(t *testing.T) {
+	manager, servers := backendtest.NewServers(t, 2, backend.StateUp)
+	config := DefaultConfig()
+	config.RetryAttempts = 0
+	replicator := NewReplicator(manager, config)
+	defer replicator.Stop()
+
+	// Второй бэкенд не завершает загрузку
+	servers[1].SetIntercept(func(w http.ResponseWriter, r *http.Request) bool {
+		if r.Method != http.MethodPost || !r.URL.Query().Has("uploadId") {
+			return false
+		}
+		w.WriteHeader(http.StatusInternalServerError)
+		return true
+	})
+
+	uploadID := startMultipartUpload(t, replicator, "partial-key")
+	query := url.Values{"uploadId": {uploadID}}
+	response := replicator.CompleteMultipartUpload(context.Background(), &apigw.S3Request{
+		Bucket:  "test-bucket",
+		Key:     "partial-key",
+		Query:   query,
+		Headers: http.Header{},
+	}, routing.WriteOperationPolicy{AckLevel: "all"})
+	if response.StatusCode == http.StatusOK {
+		t.Fatal("Expected CompleteMultipartUpload to fail with ack=all")
+	}
+
+	// Маппинг остался только для незавершенного бэкенда и доступен прерыванию
+	mapping, ok := replicator.multipartStore.GetMapping(uploadID)
+	if !ok {
+		t.Fatal("Expected mapping of partially completed upload to be kept")
+	}
+	if _, ok := mapping.BackendUploads["backend-1"]; ok || len(mapping.BackendUploads) != 1 {
+		t.Errorf("Expected only backend-2 to remain in mapping, got %v", mapping.BackendUploads)
+	}
+	if err := replicator.AbortUpload(context.Background(), uploadID); err != nil {
+		t.Fatalf("Expected partially completed upload to be abortable, got %v", err)
+	}
+	if servers[1].PendingUploads() != 0 {
+		t.Error("Expected upload to be aborted on backend-2")
+	}
+	if _, ok := servers[0].GetObject("partial-key"); !ok {
+		t.Error("Expected object completed on backend-1 to stay")
+	}
+}
+
+func TestForceAbortMultipartUpload(t *testing.T) {
+	manager, servers := backendtest.NewServers(t, 2, backend.StateUp)
+	replicator := NewReplicator(manager, nil)
+	defer replicator.Stop()
+	handler := replicator.UploadAbortHandler()
+
+	abort := func(uploadID string) int {
+		recorder := httptest.NewRecorder()
+		handler.ServeHTTP(recorder, httptest.NewRequest(http.MethodPost, "/multipart/abort?upload_id="+uploadID, nil))
+		return recorder.Code
+	}
+
+	uploadID := startMultipartUpload(t, replicator, "forced-key")
+	if code := abort(uploadID); code != http.StatusNoContent {
+		t.Fatalf("Expected status %d, got %d", http.StatusNoContent, code)
+	}
+	for i, server := range servers {
+		if server.PendingUploads() != 0 {
+			t.Errorf("Expected upload to be aborted on backend %d", i+1)
+		}
+	}
+	if code := abort(uploadID); code != http.StatusNotFound {
+		t.Errorf("Expected status %d for unknown upload, got %d", http.StatusNotFound, code)
+	}
+
+	// Загрузка, которая завершается, не прерывается
+	completing := startMultipartUpload(t, replicator, "completing-key")
+	if _, ok := replicator.multipartStore.BeginComplete(completing); !ok {
+		t.Fatal("Expected mapping for completing upload")
+	}
+	if code := abort(completing); code != http.StatusConflict {
+		t.Errorf("Expected status %d for completing upload, got %d", http.StatusConflict, code)
+	}
+	for i, server := range servers {
+		if server.PendingUploads() != 1 {
+			t.Errorf("Expected completing upload to stay on backend %d", i+1)
+		}
+	}
+}
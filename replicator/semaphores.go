@@ -0,0 +1,27 @@
+package replicator
+
+// operationSemaphores - семафоры, ограничивающие одновременные запросы к бэкендам
+// отдельно для каждой категории операций
+type operationSemaphores struct {
+	put    chan struct{} // PutObject (включая failover) и доставка из очереди репликации
+	delete chan struct{} // DeleteObject
+	part   chan struct{} // UploadPart
+	bucket chan struct{} // CreateBucket и DeleteBucket
+}
+
+// newOperationSemaphores создает семафоры по лимитам конфигурации. Категория без
+// собственного лимита получает MaxConcurrentOperations.
+func newOperationSemaphores(config *Config) operationSemaphores {
+	semaphore := func(limit int) chan struct{} {
+		if limit <= 0 {
+			limit = config.MaxConcurrentOperations
+		}
+		return make(chan struct{}, limit)
+	}
+	return operationSemaphores{
+		put:    semaphore(config.MaxConcurrentPuts),
+		delete: semaphore(config.MaxConcurrentDeletes),
+		part:   semaphore(config.MaxConcurrentParts),
+		bucket: semaphore(config.MaxConcurrentBucketOps),
+	}
+}
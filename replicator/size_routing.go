@@ -0,0 +1,53 @@
+package replicator
+
+import (
+	"s3proxy/apigw"
+	"s3proxy/backend"
+	"s3proxy/logger"
+)
+
+// objectSize возвращает размер тела PUT. false - размер неизвестен (chunked без
+// X-Amz-Decoded-Content-Length или запрос без Content-Length).
+func objectSize(req *apigw.S3Request) (int64, bool) {
+	if req.ContentLength > 0 {
+		return req.ContentLength, true
+	}
+	return 0, req.ContentLength == 0 && req.Headers.Get("Content-Length") == "0"
+}
+
+// acceptsObjectSize сообщает, что объект размером size попадает в диапазон
+// MinObjectSize..MaxObjectSize бэкенда
+func acceptsObjectSize(b *backend.Backend, size int64) bool {
+	if size < b.Config.MinObjectSize {
+		return false
+	}
+	return b.Config.MaxObjectSize == 0 || size <= b.Config.MaxObjectSize
+}
+
+// selectBySize оставляет бэкенды, в диапазон размеров которых попадает объект запроса.
+// Если размер неизвестен или объект не подходит ни одному бэкенду, возвращаются все
+// бэкенды: запись не должна отклоняться из-за настроек уровней хранения.
+func selectBySize(req *apigw.S3Request, backends []*backend.Backend) []*backend.Backend {
+	size, ok := objectSize(req)
+	if !ok {
+		return backends
+	}
+
+	selected := make([]*backend.Backend, 0, len(backends))
+	for _, b := range backends {
+		if acceptsObjectSize(b, size) {
+			selected = append(selected, b)
+		}
+	}
+	if len(selected) == len(backends) {
+		return backends
+	}
+	if len(selected) == 0 {
+		logger.Warn("[%s] No backend accepts %d-byte object %s/%s by size, writing to all %d backends",
+			req.RequestID, size, req.Bucket, req.Key, len(backends))
+		return backends
+	}
+	logger.Debug("[%s] %d-byte object %s/%s routed by size to %d of %d backends",
+		req.RequestID, size, req.Bucket, req.Key, len(selected), len(backends))
+	return selected
+}
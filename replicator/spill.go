@@ -0,0 +1,48 @@
+package replicator
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"s3proxy/apigw"
+	"s3proxy/logger"
+)
+
+// shouldSpill сообщает, что тело PUT нужно передавать бэкендам через временный файл:
+// режим включен, бэкендов несколько и заявленный размер тела не меньше SpillThreshold
+func (r *Replicator) shouldSpill(req *apigw.S3Request, count int) bool {
+	return r.config.SpillThreshold > 0 && count > 1 && req.ContentLength >= r.config.SpillThreshold
+}
+
+// spillBody записывает тело запроса во временный файл в SpillDir и возвращает count
+// независимых reader'ов над ним. Каждый бэкенд читает файл со своей скоростью, а память
+// не зависит от размера объекта. Возвращаемая функция удаляет файл; ее нужно вызвать,
+// когда все бэкенды закончат чтение.
+func (r *Replicator) spillBody(body io.Reader, count int) ([]io.Reader, func(), error) {
+	file, err := os.CreateTemp(r.config.SpillDir, "s3proxy-put-*")
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create spill file: %w", err)
+	}
+
+	remove := func() {
+		file.Close()
+		if err := os.Remove(file.Name()); err != nil {
+			logger.Warn("spillBody: failed to remove spill file %s: %v", file.Name(), err)
+		}
+	}
+
+	size, err := io.Copy(file, body)
+	if err != nil {
+		remove()
+		return nil, nil, fmt.Errorf("failed to spill request body: %w", err)
+	}
+	logger.Debug("spillBody: spilled %d bytes to %s for %d backends", size, file.Name(), count)
+
+	// ReadAt не меняет позицию файла, поэтому reader'ы не мешают друг другу
+	readers := make([]io.Reader, count)
+	for i := range readers {
+		readers[i] = io.NewSectionReader(file, 0, size)
+	}
+	return readers, remove, nil
+}
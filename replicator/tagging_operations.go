@@ -0,0 +1,117 @@
+package replicator
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+
+	"s3proxy/apigw"
+	"s3proxy/backend"
+	"s3proxy/logger"
+)
+
+// Ограничения S3 на теги объекта
+const (
+	maxObjectTags       = 10
+	maxTagKeyLength     = 128
+	maxTagValueLength   = 256
+	maxTaggingBodyBytes = 64 * 1024
+)
+
+// parseTagging читает и проверяет тело PUT ?tagging.
+// Возвращает код и сообщение ошибки S3, если тело некорректно.
+func parseTagging(body io.Reader) ([]types.Tag, string, string) {
+	if body == nil {
+		return nil, "MalformedXML", "The XML you provided was not well-formed or did not validate against our published schema"
+	}
+	data, err := io.ReadAll(io.LimitReader(body, maxTaggingBodyBytes+1))
+	if err != nil || len(data) > maxTaggingBodyBytes {
+		return nil, "MalformedXML", "The XML you provided was not well-formed or did not validate against our published schema"
+	}
+
+	var tagging apigw.Tagging
+	if err := xml.Unmarshal(data, &tagging); err != nil {
+		return nil, "MalformedXML", "The XML you provided was not well-formed or did not validate against our published schema"
+	}
+	if len(tagging.TagSet) > maxObjectTags {
+		return nil, "BadRequest", fmt.Sprintf("Object tags cannot be greater than %d", maxObjectTags)
+	}
+
+	tags := make([]types.Tag, 0, len(tagging.TagSet))
+	seen := make(map[string]bool, len(tagging.TagSet))
+	for _, tag := range tagging.TagSet {
+		if tag.Key == "" || len(tag.Key) > maxTagKeyLength || len(tag.Value) > maxTagValueLength {
+			return nil, "InvalidTag", fmt.Sprintf("The TagKey or TagValue you have provided is invalid: %q", tag.Key)
+		}
+		if seen[tag.Key] {
+			return nil, "InvalidTag", fmt.Sprintf("Cannot provide multiple Tags with the same key: %q", tag.Key)
+		}
+		seen[tag.Key] = true
+		tags = append(tags, types.Tag{Key: aws.String(tag.Key), Value: aws.String(tag.Value)})
+	}
+	return tags, "", ""
+}
+
+// putObjectTaggingOperation возвращает операцию установки тегов объекта на одном бэкенде
+func (r *Replicator) putObjectTaggingOperation(req *apigw.S3Request, tags []types.Tag) bucketOperation {
+	return func(ctx context.Context, b *backend.Backend) *backend.BackendResult {
+		startTime := time.Now()
+
+		ctx, cancel := context.WithTimeout(ctx, r.config.OperationTimeout)
+		defer cancel()
+
+		logger.Debug("putObjectTagging: setting %d tags on %s/%s on backend %s", len(tags), b.Config.Bucket, req.Key, b.ID)
+		response, err := b.S3Client.PutObjectTagging(ctx, &s3.PutObjectTaggingInput{
+			Bucket:  aws.String(b.Config.Bucket),
+			Key:     aws.String(req.Key),
+			Tagging: &types.Tagging{TagSet: tags},
+		})
+		if err != nil {
+			logger.Error("putObjectTagging: failed on backend %s: %v", b.ID, err)
+		}
+
+		return &backend.BackendResult{
+			BackendID:  b.ID,
+			Method:     "PUT_TAGGING",
+			Response:   response,
+			Err:        err,
+			Duration:   time.Since(startTime),
+			StatusCode: http.StatusOK,
+		}
+	}
+}
+
+// deleteObjectTaggingOperation возвращает операцию удаления тегов объекта на одном бэкенде
+func (r *Replicator) deleteObjectTaggingOperation(req *apigw.S3Request) bucketOperation {
+	return func(ctx context.Context, b *backend.Backend) *backend.BackendResult {
+		startTime := time.Now()
+
+		ctx, cancel := context.WithTimeout(ctx, r.config.OperationTimeout)
+		defer cancel()
+
+		logger.Debug("deleteObjectTagging: deleting tags of %s/%s on backend %s", b.Config.Bucket, req.Key, b.ID)
+		response, err := b.S3Client.DeleteObjectTagging(ctx, &s3.DeleteObjectTaggingInput{
+			Bucket: aws.String(b.Config.Bucket),
+			Key:    aws.String(req.Key),
+		})
+		if err != nil {
+			logger.Error("deleteObjectTagging: failed on backend %s: %v", b.ID, err)
+		}
+
+		return &backend.BackendResult{
+			BackendID:  b.ID,
+			Method:     "DELETE_TAGGING",
+			Response:   response,
+			Err:        err,
+			Duration:   time.Since(startTime),
+			StatusCode: http.StatusNoContent,
+		}
+	}
+}
@@ -2,7 +2,14 @@ package replicator
 
 import (
 	"context"
+	"crypto/md5"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash"
 	"io"
+	"strings"
+	"sync"
 	"time"
 
 	"s3proxy/apigw"
@@ -42,6 +49,10 @@ type multipartUploadMapping struct {
 	CreatedAt      time.Time
 	Bucket         string
 	Key            string
+
+	// Выполняется CompleteMultipartUpload: такую загрузку нельзя прерывать
+	// по истечении TTL или принудительно (изменяется под мьютексом MultipartStore)
+	completing bool
 }
 
 // ReaderCloner интерфейс для клонирования io.Reader
@@ -80,14 +91,12 @@ func (c *PipeReaderCloner) Clone(reader io.Reader, count int) ([]io.Reader, erro
 			}
 		}()
 
-		// Создаем MultiWriter для записи во все pipes одновременно
-		multiWriter := io.MultiWriter(func() []io.Writer {
-			writers := make([]io.Writer, len(pipes))
-			for i, pipe := range pipes {
-				writers[i] = pipe
-			}
-			return writers
-		}()...)
+		// Пишем во все pipes одновременно. В отличие от io.MultiWriter, бэкенд,
+		// переставший читать (закрывший свой reader), не блокирует остальные
+		multiWriter := &pipeTeeWriter{
+			pipes:  pipes,
+			closed: make([]bool, len(pipes)),
+		}
 
 		// Копируем данные из исходного reader во все pipes
 		_, err := io.Copy(multiWriter, reader)
@@ -102,10 +111,236 @@ func (c *PipeReaderCloner) Clone(reader io.Reader, count int) ([]io.Reader, erro
 	return readers, nil
 }
 
+// pipeTeeWriter пишет данные во все pipes, пропуская те, чьи читатели закрыты
+type pipeTeeWriter struct {
+	pipes  []*io.PipeWriter
+	closed []bool
+}
+
+// Write реализует io.Writer. Ошибка возвращается, только если закрыты все читатели.
+func (t *pipeTeeWriter) Write(p []byte) (int, error) {
+	active := 0
+	for i, pipe := range t.pipes {
+		if t.closed[i] {
+			continue
+		}
+		if _, err := pipe.Write(p); err != nil {
+			// Читатель закрыт - бэкенд больше не принимает данные
+			t.closed[i] = true
+			continue
+		}
+		active++
+	}
+
+	if active == 0 {
+		return 0, io.ErrClosedPipe
+	}
+	return len(p), nil
+}
+
+// QueueReaderCloner клонирует reader так, что каждый клон читает из собственной очереди.
+// Данные из исходного reader читаются со скоростью самого быстрого потребителя,
+// а отставший потребитель не блокирует остальных: непрочитанные им данные
+// накапливаются в его очереди. Если задан MaxBuffered, очередь каждого потребителя
+// ограничена, и чтение исходного reader приостанавливается, пока самый медленный
+// потребитель не освободит место.
+type QueueReaderCloner struct {
+	// ChunkSize - размер блока, читаемого из исходного reader
+	ChunkSize int
+
+	// MaxBuffered - максимальный объем непрочитанных данных в очереди одного
+	// потребителя в байтах. 0 - без ограничения (в пределах размера тела).
+	MaxBuffered int64
+}
+
+// Clone создает несколько независимых копий io.Reader
+func (c *QueueReaderCloner) Clone(reader io.Reader, count int) ([]io.Reader, error) {
+	if count <= 0 {
+		return nil, nil
+	}
+
+	if count == 1 {
+		return []io.Reader{reader}, nil
+	}
+
+	chunkSize := c.ChunkSize
+	if chunkSize <= 0 {
+		chunkSize = 32 * 1024
+	}
+
+	queues := make([]*chunkQueue, count)
+	readers := make([]io.Reader, count)
+	for i := range queues {
+		queues[i] = newChunkQueue(c.MaxBuffered)
+		readers[i] = queues[i]
+	}
+
+	go func() {
+		for {
+			// Каждый блок - новый срез: очереди хранят ссылки на него до прочтения
+			buf := make([]byte, chunkSize)
+			n, err := reader.Read(buf)
+
+			if n > 0 {
+				active := 0
+				for _, queue := range queues {
+					if queue.push(buf[:n]) {
+						active++
+					}
+				}
+				// Все потребители закрыли свои копии - дальше читать незачем
+				if active == 0 {
+					return
+				}
+			}
+
+			if err != nil {
+				if err == io.EOF {
+					err = nil
+				}
+				for _, queue := range queues {
+					queue.finish(err)
+				}
+				return
+			}
+		}
+	}()
+
+	return readers, nil
+}
+
+// chunkQueue - очередь блоков данных одного потребителя
+type chunkQueue struct {
+	mu       sync.Mutex
+	cond     *sync.Cond
+	chunks   [][]byte
+	buffered int64 // Объем непрочитанных данных
+	limit    int64 // Ограничение buffered, 0 - без ограничения
+	done     bool
+	err      error
+	closed   bool
+}
+
+// newChunkQueue создает пустую очередь с ограничением limit байт (0 - без ограничения)
+func newChunkQueue(limit int64) *chunkQueue {
+	q := &chunkQueue{limit: limit}
+	q.cond = sync.NewCond(&q.mu)
+	return q
+}
+
+// push добавляет блок в очередь, ожидая освобождения места, если очередь заполнена.
+// В пустую очередь блок добавляется всегда. Возвращает false, если потребитель закрыл очередь.
+func (q *chunkQueue) push(chunk []byte) bool {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	for q.limit > 0 && len(q.chunks) > 0 && q.buffered+int64(len(chunk)) > q.limit && !q.closed {
+		q.cond.Wait()
+	}
+	if q.closed {
+		return false
+	}
+	q.chunks = append(q.chunks, chunk)
+	q.buffered += int64(len(chunk))
+	q.cond.Broadcast()
+	return true
+}
+
+// finish отмечает конец данных. err == nil означает штатный EOF.
+func (q *chunkQueue) finish(err error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.done = true
+	q.err = err
+	q.cond.Broadcast()
+}
+
+// Read реализует io.Reader, ожидая поступления данных в очередь
+func (q *chunkQueue) Read(p []byte) (int, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	for len(q.chunks) == 0 && !q.done && !q.closed {
+		q.cond.Wait()
+	}
+
+	if q.closed {
+		return 0, io.ErrClosedPipe
+	}
+
+	if len(q.chunks) > 0 {
+		n := copy(p, q.chunks[0])
+		if n == len(q.chunks[0]) {
+			q.chunks[0] = nil
+			q.chunks = q.chunks[1:]
+		} else {
+			q.chunks[0] = q.chunks[0][n:]
+		}
+		q.buffered -= int64(n)
+		// Место освободилось: будим ожидающего в push
+		q.cond.Broadcast()
+		return n, nil
+	}
+
+	if q.err != nil {
+		return 0, q.err
+	}
+	return 0, io.EOF
+}
+
+// Close освобождает непрочитанные данные. Последующие блоки в очередь не попадают.
+func (q *chunkQueue) Close() error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.closed = true
+	q.chunks = nil
+	q.buffered = 0
+	q.cond.Broadcast()
+	return nil
+}
+
+// ErrBadDigest возвращается, если переданные бэкенду данные не совпали с контрольной
+// суммой клиента (Content-MD5 или x-amz-checksum-*)
+var ErrBadDigest = errors.New("the checksum you specified did not match what was received")
+
+// digestMismatchError - несовпадение тела с контрольной суммой из заголовка header.
+// errors.Is(err, ErrBadDigest) для нее истинно.
+type digestMismatchError struct {
+	header string
+}
+
+func (e *digestMismatchError) Error() string {
+	return fmt.Sprintf("the %s you specified did not match what was received", e.header)
+}
+
+func (e *digestMismatchError) Is(target error) bool {
+	return target == ErrBadDigest
+}
+
+// badDigestMessage возвращает текст ответа BadDigest для ошибки несовпадения контрольной суммы
+func badDigestMessage(err error) string {
+	var mismatch *digestMismatchError
+	if errors.As(err, &mismatch) && mismatch.header != "Content-MD5" {
+		algorithm := strings.ToUpper(strings.TrimPrefix(strings.ToLower(mismatch.header), "x-amz-checksum-"))
+		return fmt.Sprintf("The %s you specified did not match the calculated checksum.", algorithm)
+	}
+	return "The Content-MD5 you specified did not match what we received."
+}
+
+// expectedDigest - контрольная сумма тела, заявленная клиентом
+type expectedDigest struct {
+	header string // Заголовок с суммой: Content-MD5 или x-amz-checksum-*
+	hash   hash.Hash
+	value  string // Ожидаемое значение в base64
+}
+
 // CountingReader оборачивает io.Reader и считает прочитанные байты
 type CountingReader struct {
 	reader io.Reader
 	count  int64
+
+	// Проверка контрольных сумм (см. ExpectMD5, ExpectChecksum)
+	digests   []*expectedDigest
+	badDigest bool // Несовпадение обнаружено при чтении до EOF
 }
 
 // NewCountingReader создает новый CountingReader
@@ -113,10 +348,35 @@ func NewCountingReader(reader io.Reader) *CountingReader {
 	return &CountingReader{reader: reader}
 }
 
+// ExpectMD5 включает подсчет MD5 прочитанных байт и сверку с ожидаемым значением
+// (base64, как в заголовке Content-MD5). При несовпадении вместо EOF возвращается
+// ErrBadDigest, чтобы бэкенд не получил завершенное тело.
+func (cr *CountingReader) ExpectMD5(contentMD5 string) {
+	cr.digests = append(cr.digests, &expectedDigest{header: "Content-MD5", hash: md5.New(), value: contentMD5})
+}
+
+// ExpectChecksum включает сверку прочитанных байт с контрольной суммой из заголовка
+// x-amz-checksum-* так же, как ExpectMD5. Возвращает false, если алгоритм не поддерживается.
+func (cr *CountingReader) ExpectChecksum(header, value string) bool {
+	checksum := apigw.NewChecksumHash(header)
+	if checksum == nil {
+		return false
+	}
+	cr.digests = append(cr.digests, &expectedDigest{header: strings.ToLower(header), hash: checksum, value: value})
+	return true
+}
+
 // Read реализует io.Reader и считает байты
 func (cr *CountingReader) Read(p []byte) (n int, err error) {
 	n, err = cr.reader.Read(p)
 	cr.count += int64(n)
+	for _, digest := range cr.digests {
+		digest.hash.Write(p[:n])
+	}
+	if err == io.EOF && cr.mismatchedDigest() != nil {
+		cr.badDigest = true
+		err = ErrBadDigest
+	}
 	return n, err
 }
 
@@ -125,9 +385,115 @@ func (cr *CountingReader) Count() int64 {
 	return cr.count
 }
 
+// DigestMismatch сообщает, что контрольная сумма прочитанных байт не совпала с ожидаемой.
+// Если тело прочитано не до конца, сверяется сумма уже прочитанной части.
+func (cr *CountingReader) DigestMismatch() bool {
+	return len(cr.digests) > 0 && (cr.badDigest || cr.mismatchedDigest() != nil)
+}
+
+// DigestError возвращает ошибку несовпадения контрольной суммы с указанием заголовка
+// или nil, если все суммы совпали
+func (cr *CountingReader) DigestError() error {
+	if digest := cr.mismatchedDigest(); digest != nil {
+		return &digestMismatchError{header: digest.header}
+	}
+	return nil
+}
+
+// mismatchedDigest возвращает первую не совпавшую контрольную сумму
+func (cr *CountingReader) mismatchedDigest() *expectedDigest {
+	for _, digest := range cr.digests {
+		if base64.StdEncoding.EncodeToString(digest.hash.Sum(nil)) != digest.value {
+			return digest
+		}
+	}
+	return nil
+}
+
+// clientBodyReader оборачивает тело запроса клиента и отслеживает, было ли оно
+// передано полностью. Если клиент отключился (контекст запроса отменен) или тело
+// оборвалось раньше Content-Length, преждевременный EOF превращается в
+// io.ErrUnexpectedEOF, чтобы бэкенды не зафиксировали обрезанный объект.
+type clientBodyReader struct {
+	reader   io.Reader
+	ctx      context.Context
+	expected int64 // Ожидаемый размер тела (0 - неизвестен)
+
+	mu       sync.Mutex
+	read     int64
+	finished bool // Тело дочитано до конца
+	aborted  bool // Передача тела прервана
+	onAbort  func()
+}
+
+// newClientBodyReader создает clientBodyReader. onAbort вызывается один раз
+// при обнаружении обрыва передачи.
+func newClientBodyReader(ctx context.Context, reader io.Reader, expected int64, onAbort func()) *clientBodyReader {
+	return &clientBodyReader{
+		reader:   reader,
+		ctx:      ctx,
+		expected: expected,
+		onAbort:  onAbort,
+	}
+}
+
+// Read реализует io.Reader
+func (c *clientBodyReader) Read(p []byte) (int, error) {
+	n, err := c.reader.Read(p)
+
+	c.mu.Lock()
+	c.read += int64(n)
+	switch {
+	case err == io.EOF:
+		if c.ctx.Err() != nil || (c.expected > 0 && c.read < c.expected) {
+			err = io.ErrUnexpectedEOF
+			c.abortLocked()
+		} else {
+			c.finished = true
+		}
+	case err != nil:
+		c.abortLocked()
+	}
+	c.mu.Unlock()
+
+	return n, err
+}
+
+// watch прерывает передачу, если контекст клиента отменен до окончания тела.
+// Нужен для случая, когда Read заблокирован в ожидании данных от клиента.
+func (c *clientBodyReader) watch(done <-chan struct{}) {
+	select {
+	case <-c.ctx.Done():
+		c.mu.Lock()
+		if !c.finished {
+			c.abortLocked()
+		}
+		c.mu.Unlock()
+	case <-done:
+	}
+}
+
+// Aborted возвращает true, если передача тела была прервана
+func (c *clientBodyReader) Aborted() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.aborted
+}
+
+func (c *clientBodyReader) abortLocked() {
+	if c.aborted {
+		return
+	}
+	c.aborted = true
+	if c.onAbort != nil {
+		c.onAbort()
+	}
+}
+
 // operationContext содержит контекст для выполнения операции
 type operationContext struct {
 	ctx       context.Context
+	requestID string
 	operation string
 	bucket    string
 	key       string
@@ -138,6 +504,7 @@ type operationContext struct {
 func newOperationContext(ctx context.Context, operation, bucket, key string) *operationContext {
 	return &operationContext{
 		ctx:       ctx,
+		requestID: apigw.RequestIDFromContext(ctx),
 		operation: operation,
 		bucket:    bucket,
 		key:       key,
@@ -145,6 +512,11 @@ func newOperationContext(ctx context.Context, operation, bucket, key string) *op
 	}
 }
 
+// Resource возвращает путь ресурса операции для элемента Resource ответа об ошибке
+func (oc *operationContext) Resource() string {
+	return apigw.ResourcePath(oc.bucket, oc.key)
+}
+
 // Duration возвращает время выполнения операции
 func (oc *operationContext) Duration() time.Duration {
 	return time.Since(oc.startTime)
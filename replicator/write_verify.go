@@ -0,0 +1,86 @@
+package replicator
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"s3proxy/apigw"
+	"s3proxy/backend"
+	"s3proxy/logger"
+	"s3proxy/routing"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+)
+
+// ErrReadAfterWriteMismatch возвращается для бэкенда, который после успешного PUT не отдал
+// записанный объект на HEAD или отдал его с другим ETag (см. WriteOperationPolicy.VerifyAfterWrite)
+var ErrReadAfterWriteMismatch = errors.New("object is not readable from backend after write")
+
+// withWriteVerification после успешной записи на бэкенд запрашивает у него HEAD объекта
+// и сверяет ETag с ответом PUT. Бэкенд, на котором объект не читается или читается
+// с другим ETag, считается неуспешным, и при ack=all запись завершается ошибкой.
+// Проверки бэкендов выполняются параллельно по мере поступления результатов.
+func (r *Replicator) withWriteVerification(opCtx *operationContext, resultsChan <-chan *backend.BackendResult, req *apigw.S3Request, policy routing.WriteOperationPolicy, backends []*backend.Backend) <-chan *backend.BackendResult {
+	if !policy.VerifyAfterWrite || policy.AckLevel != "all" {
+		return resultsChan
+	}
+
+	byID := make(map[string]*backend.Backend, len(backends))
+	for _, b := range backends {
+		byID[b.ID] = b
+	}
+
+	out := make(chan *backend.BackendResult, cap(resultsChan))
+	go func() {
+		var wg sync.WaitGroup
+		for result := range resultsChan {
+			output, ok := result.Response.(*s3.PutObjectOutput)
+			b := byID[result.BackendID]
+			if result.Err != nil || !ok || b == nil {
+				out <- result
+				continue
+			}
+			wg.Add(1)
+			go func(result *backend.BackendResult) {
+				defer wg.Done()
+				if err := r.verifyWrittenObject(opCtx.ctx, b, req, output); err != nil {
+					logger.Error("[%s] %s: read-after-write check of %s on backend %s failed: %v",
+						opCtx.requestID, opCtx.operation, req.Key, b.ID, err)
+					failed := *result
+					failed.Err = err
+					result = &failed
+				}
+				out <- result
+			}(result)
+		}
+		wg.Wait()
+		close(out)
+	}()
+	return out
+}
+
+// verifyWrittenObject выполняет HEAD записанного объекта на бэкенде и сравнивает ETag
+// с ответом PUT. Если бэкенд не вернул ETag в ответе PUT, проверяется только наличие объекта.
+func (r *Replicator) verifyWrittenObject(ctx context.Context, b *backend.Backend, req *apigw.S3Request, output *s3.PutObjectOutput) error {
+	ctx, cancel := context.WithTimeout(ctx, r.config.OperationTimeout)
+	defer cancel()
+
+	input := &s3.HeadObjectInput{
+		Bucket: aws.String(b.Config.Bucket),
+		Key:    aws.String(req.Key),
+	}
+	if output.VersionId != nil {
+		input.VersionId = output.VersionId
+	}
+	head, err := b.S3Client.HeadObject(ctx, input)
+	if err != nil {
+		return fmt.Errorf("%w: HEAD failed: %v", ErrReadAfterWriteMismatch, err)
+	}
+	if expected := aws.ToString(output.ETag); expected != "" && aws.ToString(head.ETag) != expected {
+		return fmt.Errorf("%w: got ETag %s, expected %s", ErrReadAfterWriteMismatch, aws.ToString(head.ETag), expected)
+	}
+	return nil
+}
@@ -1,31 +1,52 @@
 package routing
 
 import (
+	"context"
 	"errors"
 	"fmt"
-	"io"
 	"net/http"
-	"strings"
+	"sync"
 
 	"s3proxy/apigw"
 	"s3proxy/auth"
 	"s3proxy/logger"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
+// tracerName - имя трассировщика Policy & Routing Engine
+const tracerName = "s3proxy/routing"
+
 // Engine - это реализация Policy & Routing Engine
 type Engine struct {
 	// Зависимости, внедряемые при создании
 	auth       auth.Authenticator  // Модуль аутентификации
+	authorizer Authorizer          // Дополнительная авторизация (по умолчанию AllowAllAuthorizer)
 	replicator ReplicationExecutor // Модуль для записи
 	fetcher    FetchingExecutor    // Модуль для чтения
 
-	// Конфигурация политик, загружаемая при старте
-	putPolicy    WriteOperationPolicy
-	deletePolicy WriteOperationPolicy
-	getPolicy    ReadOperationPolicy
+	// Конфигурация политик, загружаемая при старте и заменяемая UpdatePolicies
+	mu             sync.RWMutex
+	putPolicy      WriteOperationPolicy
+	deletePolicy   WriteOperationPolicy
+	getPolicy      ReadOperationPolicy
+	bucketPolicies map[string]Policies // Переопределения политик по бакетам
+
+	// Регион, сообщаемый клиентам
+	region string
+
+	// Выполняющиеся записи объектов для ReadOperationPolicy.ReadIsolation
+	writes keyWrites
+
+	// Единственный обслуживаемый бакет в строгом режиме (пусто - passthrough, см. SetVirtualBucket)
+	virtualBucket string
 }
 
-// NewEngine создает новый экземпляр Engine
+// NewEngine создает новый экземпляр Engine. Если replicator или fetcher равен nil
+// (бэкенды отключены), операции с бэкендами завершаются ответом 503.
 func NewEngine(
 	authenticator auth.Authenticator,
 	replicator ReplicationExecutor,
@@ -36,82 +57,158 @@ func NewEngine(
 		config = DefaultConfig()
 	}
 
+	region := config.Region
+	if region == "" {
+		region = DefaultRegion
+	}
+
 	return &Engine{
-		auth:         authenticator,
-		replicator:   replicator,
-		fetcher:      fetcher,
-		putPolicy:    config.Policies.Put,
-		deletePolicy: config.Policies.Delete,
-		getPolicy:    config.Policies.Get,
+		auth:           authenticator,
+		authorizer:     AllowAllAuthorizer{},
+		replicator:     replicator,
+		fetcher:        fetcher,
+		putPolicy:      config.Policies.Put,
+		deletePolicy:   config.Policies.Delete,
+		getPolicy:      config.Policies.Get,
+		bucketPolicies: config.BucketPolicies,
+		region:         region,
 	}
 }
 
+// SetAuthorizer задает дополнительную проверку прав. nil возвращает AllowAllAuthorizer.
+// Вызывается до начала обработки запросов.
+func (e *Engine) SetAuthorizer(authorizer Authorizer) {
+	if authorizer == nil {
+		authorizer = AllowAllAuthorizer{}
+	}
+	e.authorizer = authorizer
+}
+
+// UpdatePolicies заменяет политики маршрутизации на лету (при перезагрузке конфигурации).
+// Запросы, уже переданные исполнителям, завершаются со старыми политиками.
+func (e *Engine) UpdatePolicies(config *Config) {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.putPolicy = config.Policies.Put
+	e.deletePolicy = config.Policies.Delete
+	e.getPolicy = config.Policies.Get
+	e.bucketPolicies = config.BucketPolicies
+}
+
+// policies возвращает текущий снимок политик, действующих для бакета
+func (e *Engine) policies(bucket string) (put, del WriteOperationPolicy, get ReadOperationPolicy) {
+	e.mu.RLock()
+	defer e.mu.RUnlock()
+	global := Policies{Put: e.putPolicy, Delete: e.deletePolicy, Get: e.getPolicy}
+	effective := mergePolicies(global, e.bucketPolicies[bucket])
+	return effective.Put, effective.Delete, effective.Get
+}
+
 // Handle - реализация интерфейса RequestHandler. Это точка входа в модуль
 func (e *Engine) Handle(req *apigw.S3Request) *apigw.S3Response {
-	logger.Debug("Policy & Routing Engine: handling request - Operation: %s, Bucket: %s, Key: %s",
-		req.Operation, req.Bucket, req.Key)
+	logger.Debug("[%s] Policy & Routing Engine: handling request - Operation: %s, Bucket: %s, Key: %s",
+		req.RequestID, req.Operation, req.Bucket, req.Key)
+
+	// Сессии S3 Express One Zone не поддерживаются: такой запрос не пройдет проверку подписи,
+	// поэтому клиенту сразу сообщается, что операция не реализована
+	if req.Operation == apigw.CreateSession || req.UsesSessionAuth() {
+		logger.Info("[%s] Rejecting S3 Express session request: %s on bucket %q", req.RequestID, req.Operation, req.Bucket)
+		return e.createSessionNotSupportedResponse(req)
+	}
 
-	// Шаг 1: Аутентификация
-	logger.Debug("Starting authentication")
-	identity, err := e.auth.Authenticate(req)
-	if err != nil {
-		logger.Debug("Authentication failed: %v", err)
-		// Преобразовать ошибку аутентификации в стандартный S3Response
-		return e.createAuthErrorResponse(err)
+	// Шаги 1-2: аутентификация и авторизация
+	if req.Context == nil {
+		req.Context = context.Background()
 	}
+	_, authSpan := otel.Tracer(tracerName).Start(req.Context, "auth")
+	if resp := e.authenticate(req); resp != nil {
+		authSpan.SetStatus(codes.Error, http.StatusText(resp.StatusCode))
+		authSpan.End()
+		return resp
+	}
+	authSpan.End()
 
-	logger.Debug("Policy & Routing Engine received authenticated request:")
-	logger.Debug("  User: %s (%s)", identity.DisplayName, identity.AccessKey)
-	logger.Debug("  Operation: %s", req.Operation)
-	logger.Debug("  Bucket: %s", req.Bucket)
-	logger.Debug("  Key: %s", req.Key)
+	// Шаг 2a: в строгом режиме обслуживается только виртуальный бакет
+	if resp := e.checkVirtualBucket(req); resp != nil {
+		return resp
+	}
 
-	// Шаг 2: Авторизация (заглушка для будущего)
-	// TODO: Реализовать модуль авторизации
-	// isAuthorized := e.authorizer.Authorize(identity, req)
-	// if !isAuthorized {
-	//     return e.createAuthorizationErrorResponse()
-	// }
-	logger.Debug("Authorization check passed (not implemented yet)")
+	// Маршрутизация и работа с бэкендами - в отдельном спане; его контекст
+	// передается исполнителям, и вызовы бэкендов становятся его дочерними спанами
+	ctx, routeSpan := otel.Tracer(tracerName).Start(req.Context, "route",
+		trace.WithAttributes(attribute.String("s3.operation", req.Operation.String())))
+	defer routeSpan.End()
+	req.Context = ctx
 
 	// Шаг 3: Маршрутизация на основе типа операции
 	logger.Debug("Routing request based on operation: %s", req.Operation)
+	putPolicy, deletePolicy, getPolicy := e.policies(req.Bucket)
+
+	// Без исполнителей (прокси запущен с -disable-backends) операции с бэкендами недоступны
+	if req.Operation != apigw.HeadService && (e.replicator == nil || e.fetcher == nil) {
+		logger.Warn("[%s] Backends are disabled, rejecting %s", req.RequestID, req.Operation)
+		return e.createBackendsDisabledResponse(req)
+	}
 
 	switch req.Operation {
 	// Операции записи - направляем в Replication Module
 	case apigw.PutObject:
-		logger.Debug("Routing to replicator.PutObject with policy: %+v", e.putPolicy)
-		return e.replicator.PutObject(req.Context, req, e.putPolicy)
+		logger.Debug("Routing to replicator.PutObject with policy: %+v", putPolicy)
+		return e.trackWrite(req, func() *apigw.S3Response {
+			return e.invalidateListings(req, req.Key, e.replicator.PutObject(req.Context, req, putPolicy))
+		})
 
 	case apigw.DeleteObject:
-		logger.Debug("Routing to replicator.DeleteObject with policy: %+v", e.deletePolicy)
-		return e.replicator.DeleteObject(req.Context, req, e.deletePolicy)
+		logger.Debug("Routing to replicator.DeleteObject with policy: %+v", deletePolicy)
+		return e.trackWrite(req, func() *apigw.S3Response {
+			return e.invalidateListings(req, req.Key, e.replicator.DeleteObject(req.Context, req, deletePolicy))
+		})
 
 	case apigw.CreateMultipartUpload:
-		logger.Debug("Routing to replicator.CreateMultipartUpload with policy: %+v", e.putPolicy)
-		return e.replicator.CreateMultipartUpload(req.Context, req, e.putPolicy)
+		logger.Debug("Routing to replicator.CreateMultipartUpload with policy: %+v", putPolicy)
+		return e.replicator.CreateMultipartUpload(req.Context, req, putPolicy)
 
 	case apigw.UploadPart:
-		logger.Debug("Routing to replicator.UploadPart with policy: %+v", e.putPolicy)
-		return e.replicator.UploadPart(req.Context, req, e.putPolicy)
+		logger.Debug("Routing to replicator.UploadPart with policy: %+v", putPolicy)
+		return e.replicator.UploadPart(req.Context, req, putPolicy)
 
 	case apigw.CompleteMultipartUpload:
-		logger.Debug("Routing to replicator.CompleteMultipartUpload with policy: %+v", e.putPolicy)
-		return e.replicator.CompleteMultipartUpload(req.Context, req, e.putPolicy)
+		logger.Debug("Routing to replicator.CompleteMultipartUpload with policy: %+v", putPolicy)
+		return e.trackWrite(req, func() *apigw.S3Response {
+			return e.invalidateListings(req, req.Key, e.replicator.CompleteMultipartUpload(req.Context, req, putPolicy))
+		})
 
 	case apigw.AbortMultipartUpload:
-		logger.Debug("Routing to replicator.AbortMultipartUpload with policy: %+v", e.deletePolicy)
-		return e.replicator.AbortMultipartUpload(req.Context, req, e.deletePolicy)
+		logger.Debug("Routing to replicator.AbortMultipartUpload with policy: %+v", deletePolicy)
+		return e.replicator.AbortMultipartUpload(req.Context, req, deletePolicy)
+
+	case apigw.CreateBucket:
+		logger.Debug("Routing to replicator.CreateBucket with policy: %+v", putPolicy)
+		return e.invalidateListings(req, "", e.replicator.CreateBucket(req.Context, req, putPolicy))
+
+	case apigw.DeleteBucket:
+		logger.Debug("Routing to replicator.DeleteBucket with policy: %+v", deletePolicy)
+		return e.invalidateListings(req, "", e.replicator.DeleteBucket(req.Context, req, deletePolicy))
+
+	case apigw.PutObjectTagging:
+		logger.Debug("Routing to replicator.PutObjectTagging with policy: %+v", putPolicy)
+		return e.replicator.PutObjectTagging(req.Context, req, putPolicy)
+
+	case apigw.DeleteObjectTagging:
+		logger.Debug("Routing to replicator.DeleteObjectTagging with policy: %+v", deletePolicy)
+		return e.replicator.DeleteObjectTagging(req.Context, req, deletePolicy)
 
 	// Операции чтения - направляем в Fetching Module
 	case apigw.GetObject:
-		logger.Debug("Routing to fetcher.GetObject with policy: %+v", e.getPolicy)
-		return e.fetcher.GetObject(req.Context, req, e.getPolicy)
+		logger.Debug("Routing to fetcher.GetObject with policy: %+v", getPolicy)
+		e.isolateRead(req, getPolicy)
+		return e.fetcher.GetObject(req.Context, req, getPolicy)
 
 	case apigw.HeadObject:
-		logger.Debug("Routing to fetcher.HeadObject with policy: %+v", e.getPolicy)
+		logger.Debug("Routing to fetcher.HeadObject with policy: %+v", getPolicy)
 		// Для HEAD обычно используется та же политика, что и для GET
-		return e.fetcher.HeadObject(req.Context, req, e.getPolicy)
+		e.isolateRead(req, getPolicy)
+		return e.fetcher.HeadObject(req.Context, req, getPolicy)
 
 	case apigw.HeadBucket:
 		logger.Debug("Routing to fetcher.HeadBucket")
@@ -131,15 +228,68 @@ func (e *Engine) Handle(req *apigw.S3Request) *apigw.S3Response {
 		logger.Debug("Routing to fetcher.ListMultipartUploads")
 		return e.fetcher.ListMultipartUploads(req.Context, req)
 
+	case apigw.ListParts:
+		logger.Debug("Routing to fetcher.ListParts")
+		return e.fetcher.ListParts(req.Context, req)
+
+	case apigw.ListObjectVersions:
+		logger.Debug("Routing to fetcher.ListObjectVersions")
+		return e.fetcher.ListObjectVersions(req.Context, req)
+
+	case apigw.GetBucketVersioning:
+		logger.Debug("Routing to fetcher.GetBucketVersioning")
+		return e.fetcher.GetBucketVersioning(req.Context, req)
+
+	case apigw.GetObjectTagging:
+		logger.Debug("Routing to fetcher.GetObjectTagging")
+		return e.fetcher.GetObjectTagging(req.Context, req)
+
+	// HEAD на корень сервиса не требует обращения к бэкендам
+	case apigw.HeadService:
+		logger.Debug("Answering HEAD / with region %s", e.region)
+		return e.createHeadServiceResponse()
+
 	default:
-		logger.Warn("Unsupported operation: %s", req.Operation)
+		logger.Warn("[%s] Unsupported operation: %s", req.RequestID, req.Operation)
 		// Вернуть ошибку для неподдерживаемых операций
-		return e.createOperationNotImplementedResponse(req.Operation)
+		return e.createOperationNotImplementedResponse(req)
+	}
+}
+
+// authenticate проверяет подпись запроса и права пользователя. Возвращает ответ
+// с ошибкой, если запрос отклонен, или nil.
+func (e *Engine) authenticate(req *apigw.S3Request) *apigw.S3Response {
+	// Шаг 1: Аутентификация
+	logger.Debug("Starting authentication")
+	identity, err := e.auth.Authenticate(req)
+	if err != nil {
+		logger.Debug("[%s] Authentication failed: %v", req.RequestID, err)
+		// Преобразовать ошибку аутентификации в стандартный S3Response
+		return e.createAuthErrorResponse(req, err)
+	}
+	req.AccessKey = identity.AccessKey
+
+	logger.Debug("Policy & Routing Engine received authenticated request:")
+	logger.Debug("  User: %s (%s)", identity.DisplayName, identity.AccessKey)
+	logger.Debug("  Operation: %s", req.Operation)
+	logger.Debug("  Bucket: %s", req.Bucket)
+	logger.Debug("  Key: %s", req.Key)
+
+	// Шаг 2: Авторизация по ограничениям пользователя (бакеты и операции)
+	if err := auth.Authorize(identity, req); err != nil {
+		logger.Info("[%s] Access denied for user %s: %s on bucket %q", req.RequestID, identity.AccessKey, req.Operation, req.Bucket)
+		return e.createAuthErrorResponse(req, err)
 	}
+	if err := e.authorizer.Authorize(identity, req); err != nil {
+		logger.Info("[%s] Authorizer denied user %s: %s on bucket %q: %v", req.RequestID, identity.AccessKey, req.Operation, req.Bucket, err)
+		return e.createAuthErrorResponse(req, auth.ErrAccessDenied)
+	}
+	logger.Debug("Authorization check passed")
+	return nil
 }
 
 // createAuthErrorResponse преобразует ошибку аутентификации в стандартный S3Response
-func (e *Engine) createAuthErrorResponse(err error) *apigw.S3Response {
+func (e *Engine) createAuthErrorResponse(req *apigw.S3Request, err error) *apigw.S3Response {
 	var code string
 	var message string
 	var statusCode int
@@ -159,6 +309,14 @@ func (e *Engine) createAuthErrorResponse(err error) *apigw.S3Response {
 		code = "SignatureDoesNotMatch"
 		message = "The request signature we calculated does not match the signature you provided."
 		statusCode = http.StatusForbidden // 403 - неверная подпись
+	case errors.Is(err, auth.ErrAccessDenied):
+		code = "AccessDenied"
+		message = "Access Denied"
+		statusCode = http.StatusForbidden // 403 - пользователю не разрешен бакет или операция
+	case errors.Is(err, auth.ErrAuthServiceUnavailable):
+		code = "ServiceUnavailable"
+		message = "Authentication service is unavailable. Please retry."
+		statusCode = http.StatusServiceUnavailable // 503 - клиент может повторить запрос
 	case errors.Is(err, auth.ErrRequestExpired):
 		code = "RequestTimeTooSkewed"
 		message = "The difference between the request time and the current time is too large."
@@ -171,48 +329,50 @@ func (e *Engine) createAuthErrorResponse(err error) *apigw.S3Response {
 		statusCode = http.StatusForbidden // 403 - общая ошибка доступа
 	}
 
-	// Создать S3 XML тело ошибки
-	errorBody := e.formatS3ErrorXML(code, message)
+	// Создать S3 XML ответ об ошибке
+	return e.errorResponse(req, statusCode, code, message)
+}
 
-	headers := make(http.Header)
-	headers.Set("Content-Type", "application/xml")
-	headers.Set("Content-Length", fmt.Sprintf("%d", len(errorBody)))
+// createOperationNotImplementedResponse создает ответ для неподдерживаемых операций
+func (e *Engine) createOperationNotImplementedResponse(req *apigw.S3Request) *apigw.S3Response {
+	message := fmt.Sprintf("The operation %s is not implemented", req.Operation)
+	return e.errorResponse(req, http.StatusNotImplemented, "NotImplemented", message)
+}
 
-	return &apigw.S3Response{
-		StatusCode: statusCode,
-		Body:       io.NopCloser(strings.NewReader(errorBody)),
-		Headers:    headers,
-		// Не устанавливаем Error, так как у нас уже есть правильно сформированный ответ
-	}
+// createSessionNotSupportedResponse создает ответ 501 для запросов с сессиями S3 Express One Zone
+func (e *Engine) createSessionNotSupportedResponse(req *apigw.S3Request) *apigw.S3Response {
+	return e.errorResponse(req, http.StatusNotImplemented, "NotImplemented",
+		"S3 Express One Zone sessions (CreateSession, x-amz-s3session-token) are not supported by this proxy")
 }
 
-// createOperationNotImplementedResponse создает ответ для неподдерживаемых операций
-func (e *Engine) createOperationNotImplementedResponse(operation apigw.S3Operation) *apigw.S3Response {
-	code := "NotImplemented"
-	message := fmt.Sprintf("The operation %s is not implemented", operation)
-	statusCode := http.StatusNotImplemented
+// createBackendsDisabledResponse создает ответ 503 для запросов, пришедших при отключенных бэкендах
+func (e *Engine) createBackendsDisabledResponse(req *apigw.S3Request) *apigw.S3Response {
+	return e.errorResponse(req, http.StatusServiceUnavailable, "ServiceUnavailable",
+		"Backends are disabled on this proxy, the operation cannot be served")
+}
 
-	errorBody := e.formatS3ErrorXML(code, message)
+// invalidateListings сбрасывает кэш листингов исполнителя чтения после записи key
+// (пустой key - операция над бакетом). Кэш сбрасывается независимо от результата:
+// при частичном отказе объект мог появиться на части бэкендов.
+func (e *Engine) invalidateListings(req *apigw.S3Request, key string, response *apigw.S3Response) *apigw.S3Response {
+	if invalidator, ok := e.fetcher.(ListingInvalidator); ok {
+		invalidator.InvalidateListings(req.Bucket, key)
+	}
+	return response
+}
 
+// createHeadServiceResponse создает ответ на HEAD /, по которому SDK определяют регион эндпоинта
+func (e *Engine) createHeadServiceResponse() *apigw.S3Response {
 	headers := make(http.Header)
-	headers.Set("Content-Type", "application/xml")
-	headers.Set("Content-Length", fmt.Sprintf("%d", len(errorBody)))
+	headers.Set("x-amz-bucket-region", e.region)
 
 	return &apigw.S3Response{
-		StatusCode: statusCode,
-		Body:       io.NopCloser(strings.NewReader(errorBody)),
+		StatusCode: http.StatusOK,
 		Headers:    headers,
-		// Не устанавливаем Error, так как у нас уже есть правильно сформированный ответ
 	}
 }
 
-// formatS3ErrorXML форматирует ошибку в стандартный S3 XML формат
-func (e *Engine) formatS3ErrorXML(code, message string) string {
-	return fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
-<Error>
-    <Code>%s</Code>
-    <Message>%s</Message>
-    <RequestId>%s</RequestId>
-    <HostId>%s</HostId>
-</Error>`, code, message, "policy-routing-engine", "s3proxy")
+// errorResponse формирует S3 XML ответ об ошибке с Resource и RequestId запроса
+func (e *Engine) errorResponse(req *apigw.S3Request, statusCode int, code, message string) *apigw.S3Response {
+	return apigw.NewErrorResponse(req.RequestID, apigw.ResourcePath(req.Bucket, req.Key), statusCode, code, message)
 }
@@ -2,10 +2,16 @@ package routing
 
 import (
 	"context"
+	"encoding/xml"
+	"errors"
+	"io"
 	"net/http"
 	"net/url"
+	"reflect"
 	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"s3proxy/apigw"
 	"s3proxy/auth"
@@ -15,12 +21,16 @@ import (
 type MockAuthenticator struct {
 	shouldFail bool
 	failError  error
+	identity   *auth.UserIdentity // Если задан, возвращается вместо пользователя по умолчанию
 }
 
 func (m *MockAuthenticator) Authenticate(req *apigw.S3Request) (*auth.UserIdentity, error) {
 	if m.shouldFail {
 		return nil, m.failError
 	}
+	if m.identity != nil {
+		return m.identity, nil
+	}
 	return &auth.UserIdentity{
 		DisplayName: "test-user",
 		AccessKey:   "test-access-key",
@@ -98,6 +108,7 @@ func TestEngine_Handle_AuthenticationFailure(t *testing.T) {
 		Context:   context.Background(),
 		Headers:   make(http.Header),
 		Query:     make(url.Values),
+		RequestID: "0123456789ABCDEF",
 	}
 	
 	resp := engine.Handle(req)
@@ -120,6 +131,9 @@ func TestEngine_Handle_AuthenticationFailure(t *testing.T) {
 		if !strings.Contains(bodyStr, "InvalidAccessKeyId") {
 			t.Errorf("Expected error body to contain 'InvalidAccessKeyId', got: %s", bodyStr)
 		}
+		if !strings.Contains(bodyStr, "<RequestId>0123456789ABCDEF</RequestId>") {
+			t.Errorf("Expected error body to contain request ID, got: %s", bodyStr)
+		}
 	}
 }
 
@@ -136,6 +150,8 @@ func TestEngine_Handle_WriteOperations(t *testing.T) {
 		apigw.UploadPart,
 		apigw.CompleteMultipartUpload,
 		apigw.AbortMultipartUpload,
+		apigw.CreateBucket,
+		apigw.DeleteBucket,
 	}
 	
 	for _, operation := range writeOperations {
@@ -183,6 +199,7 @@ func TestEngine_Handle_ReadOperations(t *testing.T) {
 		apigw.ListObjectsV2,
 		apigw.ListBuckets,
 		apigw.ListMultipartUploads,
+		apigw.ListParts,
 	}
 	
 	for _, operation := range readOperations {
@@ -206,6 +223,11 @@ func TestEngine_Handle_ReadOperations(t *testing.T) {
 			if resp.Error != nil {
 				t.Errorf("Expected no error, got %v", resp.Error)
 			}
+
+			// Пользователь запроса нужен журналу доступа шлюза
+			if req.AccessKey != "test-access-key" {
+				t.Errorf("Expected request access key to be set, got %q", req.AccessKey)
+			}
 		})
 	}
 }
@@ -248,6 +270,46 @@ func TestEngine_Handle_UnsupportedOperation(t *testing.T) {
 	}
 }
 
+func TestEngine_Handle_HeadService(t *testing.T) {
+	auth := &MockAuthenticator{}
+	replicator := NewMockReplicationExecutor()
+	fetcher := NewMockFetchingExecutor()
+
+	tests := []struct {
+		name           string
+		config         *Config
+		expectedRegion string
+	}{
+		{name: "Default region", config: nil, expectedRegion: "us-east-1"},
+		{name: "Configured region", config: &Config{Policies: DefaultConfig().Policies, Region: "eu-central-1"}, expectedRegion: "eu-central-1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			engine := NewEngine(auth, replicator, fetcher, tt.config)
+
+			req := &apigw.S3Request{
+				Operation: apigw.HeadService,
+				Context:   context.Background(),
+				Headers:   make(http.Header),
+				Query:     make(url.Values),
+			}
+
+			resp := engine.Handle(req)
+
+			if resp.StatusCode != http.StatusOK {
+				t.Errorf("Expected status code %d, got %d", http.StatusOK, resp.StatusCode)
+			}
+			if region := resp.Headers.Get("x-amz-bucket-region"); region != tt.expectedRegion {
+				t.Errorf("Expected region %q, got %q", tt.expectedRegion, region)
+			}
+			if resp.Body != nil {
+				t.Error("HEAD response must not have a body")
+			}
+		})
+	}
+}
+
 func TestEngine_AuthErrorMapping(t *testing.T) {
 	replicator := NewMockReplicationExecutor()
 	fetcher := NewMockFetchingExecutor()
@@ -318,3 +380,670 @@ func TestEngine_AuthErrorMapping(t *testing.T) {
 		})
 	}
 }
+
+func TestEngine_UpdatePolicies(t *testing.T) {
+	engine := NewEngine(&MockAuthenticator{}, NewMockReplicationExecutor(), NewMockFetchingExecutor(), nil)
+
+	engine.UpdatePolicies(&Config{
+		Policies: Policies{
+			Put:    WriteOperationPolicy{AckLevel: "all"},
+			Delete: WriteOperationPolicy{AckLevel: "none"},
+			Get:    ReadOperationPolicy{Strategy: "quorum"},
+		},
+	})
+
+	put, del, get := engine.policies("")
+	if put.AckLevel != "all" || del.AckLevel != "none" || get.Strategy != "quorum" {
+		t.Errorf("Expected updated policies, got put=%+v delete=%+v get=%+v", put, del, get)
+	}
+	if engine.region != DefaultRegion {
+		t.Errorf("Expected region to stay %s, got %s", DefaultRegion, engine.region)
+	}
+}
+
+func TestEngine_Handle_AuthorizationDenied(t *testing.T) {
+	authenticator := &MockAuthenticator{identity: &auth.UserIdentity{
+		AccessKey:         "reader",
+		DisplayName:       "read-only",
+		AllowedBuckets:    []string{"public-*"},
+		AllowedOperations: []string{"read"},
+	}}
+	engine := NewEngine(authenticator, NewMockReplicationExecutor(), NewMockFetchingExecutor(), nil)
+
+	tests := []struct {
+		name           string
+		operation      apigw.S3Operation
+		bucket         string
+		expectedStatus int
+	}{
+		{name: "Read-only user is denied PutObject", operation: apigw.PutObject, bucket: "public-data", expectedStatus: http.StatusForbidden},
+		{name: "Read-only user is allowed GetObject", operation: apigw.GetObject, bucket: "public-data", expectedStatus: http.StatusOK},
+		{name: "Bucket outside allowed list", operation: apigw.GetObject, bucket: "private", expectedStatus: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := &apigw.S3Request{
+				Operation: tt.operation,
+				Bucket:    tt.bucket,
+				Key:       "test-key",
+				RequestID: "REQ123",
+				Context:   context.Background(),
+				Headers:   make(http.Header),
+				Query:     make(url.Values),
+				Body:      io.NopCloser(strings.NewReader("data")),
+			}
+
+			resp := engine.Handle(req)
+			if resp.StatusCode != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d", tt.expectedStatus, resp.StatusCode)
+			}
+			if tt.expectedStatus == http.StatusForbidden {
+				body, _ := io.ReadAll(resp.Body)
+				if !strings.Contains(string(body), "<Code>AccessDenied</Code>") {
+					t.Errorf("Expected AccessDenied error body, got %s", body)
+				}
+			}
+		})
+	}
+}
+
+func TestEngine_Handle_AuthServiceUnavailable(t *testing.T) {
+	authenticator := &MockAuthenticator{shouldFail: true, failError: auth.ErrAuthServiceUnavailable}
+	engine := NewEngine(authenticator, NewMockReplicationExecutor(), NewMockFetchingExecutor(), nil)
+
+	req := &apigw.S3Request{
+		Operation: apigw.GetObject,
+		Bucket:    "test-bucket",
+		Key:       "test-key",
+		Context:   context.Background(),
+		Headers:   make(http.Header),
+		Query:     make(url.Values),
+	}
+
+	resp := engine.Handle(req)
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Fatalf("Expected status code %d, got %d", http.StatusServiceUnavailable, resp.StatusCode)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	if !strings.Contains(string(body), "<Code>ServiceUnavailable</Code>") {
+		t.Errorf("Expected ServiceUnavailable error body, got %s", body)
+	}
+}
+
+func TestEngine_Handle_AnonymousAccess(t *testing.T) {
+	authenticator := auth.NewAnonymousAuthenticator(&MockAuthenticator{shouldFail: true, failError: auth.ErrMissingAuthHeader}, nil)
+	engine := NewEngine(authenticator, NewMockReplicationExecutor(), NewMockFetchingExecutor(), nil)
+
+	tests := []struct {
+		name           string
+		operation      apigw.S3Operation
+		expectedStatus int
+	}{
+		{name: "Anonymous GetObject is routed to fetcher", operation: apigw.GetObject, expectedStatus: http.StatusOK},
+		{name: "Anonymous HeadObject is routed to fetcher", operation: apigw.HeadObject, expectedStatus: http.StatusOK},
+		{name: "Anonymous PutObject is denied", operation: apigw.PutObject, expectedStatus: http.StatusForbidden},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := &apigw.S3Request{
+				Operation: tt.operation,
+				Bucket:    "public-data",
+				Key:       "test-key",
+				RequestID: "REQ123",
+				Context:   context.Background(),
+				Headers:   make(http.Header),
+				Query:     make(url.Values),
+				Body:      io.NopCloser(strings.NewReader("data")),
+			}
+
+			resp := engine.Handle(req)
+			if resp.StatusCode != tt.expectedStatus {
+				t.Fatalf("Expected status %d, got %d", tt.expectedStatus, resp.StatusCode)
+			}
+		})
+	}
+}
+
+func TestEngine_Handle_BackendsDisabled(t *testing.T) {
+	// Так Engine создается при запуске с -disable-backends
+	engine := NewEngine(&MockAuthenticator{}, nil, nil, nil)
+
+	for _, op := range []apigw.S3Operation{apigw.PutObject, apigw.GetObject, apigw.HeadObject, apigw.ListBuckets} {
+		t.Run(op.String(), func(t *testing.T) {
+			req := &apigw.S3Request{
+				Operation: op,
+				Bucket:    "test-bucket",
+				Key:       "test-key",
+				Context:   context.Background(),
+				Headers:   make(http.Header),
+				Query:     make(url.Values),
+			}
+
+			resp := engine.Handle(req)
+
+			if resp.StatusCode != http.StatusServiceUnavailable {
+				t.Errorf("Expected status code %d, got %d", http.StatusServiceUnavailable, resp.StatusCode)
+			}
+			body, _ := io.ReadAll(resp.Body)
+			if !strings.Contains(string(body), "<Code>ServiceUnavailable</Code>") {
+				t.Errorf("Expected ServiceUnavailable error, got %s", body)
+			}
+		})
+	}
+}
+
+func TestEngine_Handle_ErrorResponses(t *testing.T) {
+	tests := []struct {
+		name      string
+		engine    *Engine
+		operation apigw.S3Operation
+		status    int
+		code      string
+	}{
+		{
+			name:      "Auth error",
+			engine:    NewEngine(&MockAuthenticator{shouldFail: true, failError: auth.ErrSignatureMismatch}, NewMockReplicationExecutor(), NewMockFetchingExecutor(), nil),
+			operation: apigw.GetObject,
+			status:    http.StatusForbidden,
+			code:      "SignatureDoesNotMatch",
+		},
+		{
+			name:      "Not implemented",
+			engine:    NewEngine(&MockAuthenticator{}, NewMockReplicationExecutor(), NewMockFetchingExecutor(), nil),
+			operation: apigw.UnsupportedOperation,
+			status:    http.StatusNotImplemented,
+			code:      "NotImplemented",
+		},
+		{
+			// Запрос сессии отклоняется до проверки подписи
+			name:      "Create session",
+			engine:    NewEngine(&MockAuthenticator{shouldFail: true, failError: auth.ErrInvalidAccessKeyID}, NewMockReplicationExecutor(), NewMockFetchingExecutor(), nil),
+			operation: apigw.CreateSession,
+			status:    http.StatusNotImplemented,
+			code:      "NotImplemented",
+		},
+		{
+			name:      "Backends disabled",
+			engine:    NewEngine(&MockAuthenticator{}, nil, nil, nil),
+			operation: apigw.GetObject,
+			status:    http.StatusServiceUnavailable,
+			code:      "ServiceUnavailable",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := &apigw.S3Request{
+				Operation: tt.operation,
+				Bucket:    "test-bucket",
+				Key:       "dir/test-key",
+				RequestID: "0123456789ABCDEF",
+				Context:   context.Background(),
+				Headers:   make(http.Header),
+				Query:     make(url.Values),
+			}
+
+			resp := tt.engine.Handle(req)
+
+			if resp.StatusCode != tt.status {
+				t.Errorf("Expected status code %d, got %d", tt.status, resp.StatusCode)
+			}
+			if ct := resp.Headers.Get("Content-Type"); ct != "application/xml" {
+				t.Errorf("Expected Content-Type application/xml, got %q", ct)
+			}
+
+			body, _ := io.ReadAll(resp.Body)
+			var s3Error apigw.S3Error
+			if err := xml.Unmarshal(body, &s3Error); err != nil {
+				t.Fatalf("Malformed error body: %v: %s", err, body)
+			}
+			if s3Error.Code != tt.code {
+				t.Errorf("Expected Code %s, got %s", tt.code, s3Error.Code)
+			}
+			if s3Error.Message == "" {
+				t.Error("Expected non-empty Message")
+			}
+			if s3Error.Resource != "/test-bucket/dir/test-key" {
+				t.Errorf("Expected Resource /test-bucket/dir/test-key, got %q", s3Error.Resource)
+			}
+			if s3Error.RequestID != req.RequestID {
+				t.Errorf("Expected RequestId %s, got %q", req.RequestID, s3Error.RequestID)
+			}
+		})
+	}
+}
+
+// policyRecordingReplicator запоминает политики, с которыми вызывался PutObject
+type policyRecordingReplicator struct {
+	*MockReplicationExecutor
+	ackByBucket map[string]string
+}
+
+func (r *policyRecordingReplicator) PutObject(ctx context.Context, req *apigw.S3Request, policy WriteOperationPolicy) *apigw.S3Response {
+	r.ackByBucket[req.Bucket] = policy.AckLevel
+	return r.MockReplicationExecutor.PutObject(ctx, req, policy)
+}
+
+func TestEngine_Handle_BucketPolicies(t *testing.T) {
+	replicator := &policyRecordingReplicator{
+		MockReplicationExecutor: NewMockReplicationExecutor(),
+		ackByBucket:             make(map[string]string),
+	}
+	config := DefaultConfig()
+	config.BucketPolicies = map[string]Policies{
+		"critical": {Put: WriteOperationPolicy{AckLevel: "all"}},
+	}
+	engine := NewEngine(&MockAuthenticator{}, replicator, NewMockFetchingExecutor(), config)
+
+	for _, bucket := range []string{"critical", "scratch"} {
+		req := &apigw.S3Request{
+			Operation: apigw.PutObject,
+			Bucket:    bucket,
+			Key:       "test-key",
+			Context:   context.Background(),
+			Headers:   make(http.Header),
+			Query:     make(url.Values),
+			Body:      io.NopCloser(strings.NewReader("data")),
+		}
+		engine.Handle(req)
+	}
+
+	if ack := replicator.ackByBucket["critical"]; ack != "all" {
+		t.Errorf("Expected ack=all for bucket with override, got %q", ack)
+	}
+	if ack := replicator.ackByBucket["scratch"]; ack != "one" {
+		t.Errorf("Expected global ack=one for bucket without override, got %q", ack)
+	}
+
+	// Не переопределенные операции наследуют глобальную политику
+	_, del, get := engine.policies("critical")
+	if del.AckLevel != "all" || get.Strategy != "first" {
+		t.Errorf("Expected inherited delete/get policies, got %+v / %+v", del, get)
+	}
+}
+
+func TestConfig_ValidateBucketPolicies(t *testing.T) {
+	config := DefaultConfig()
+	config.BucketPolicies = map[string]Policies{
+		"logs": {Put: WriteOperationPolicy{AckLevel: "most"}},
+	}
+	if err := config.Validate(); err == nil {
+		t.Error("Expected error for unknown ack level in bucket policy")
+	}
+
+	config.BucketPolicies["logs"] = Policies{Get: ReadOperationPolicy{Strategy: "newest"}}
+	if err := config.Validate(); err != nil {
+		t.Errorf("Expected valid config, got %v", err)
+	}
+
+	// Проверка после записи возможна только при ack=all
+	config.BucketPolicies["logs"] = Policies{Put: WriteOperationPolicy{AckLevel: "one", VerifyAfterWrite: true}}
+	if err := config.Validate(); err == nil {
+		t.Error("Expected error for verify_after_write without ack=all")
+	}
+	config.BucketPolicies["logs"] = Policies{Put: WriteOperationPolicy{AckLevel: "all", VerifyAfterWrite: true}}
+	if err := config.Validate(); err != nil {
+		t.Errorf("Expected valid config, got %v", err)
+	}
+
+	// Failover - вариант ack=one и только для PUT
+	config.BucketPolicies["logs"] = Policies{Put: WriteOperationPolicy{AckLevel: "all", Failover: true}}
+	if err := config.Validate(); err == nil {
+		t.Error("Expected error for failover without ack=one")
+	}
+	config.BucketPolicies["logs"] = Policies{Delete: WriteOperationPolicy{AckLevel: "one", Failover: true}}
+	if err := config.Validate(); err == nil {
+		t.Error("Expected error for failover in delete policy")
+	}
+	config.BucketPolicies["logs"] = Policies{Put: WriteOperationPolicy{AckLevel: "one", Failover: true}}
+	if err := config.Validate(); err != nil {
+		t.Errorf("Expected valid config, got %v", err)
+	}
+}
+
+// denyingAuthorizer запрещает все запросы к бакету bucket
+type denyingAuthorizer struct {
+	bucket string
+}
+
+func (a *denyingAuthorizer) Authorize(identity *auth.UserIdentity, req *apigw.S3Request) error {
+	if req.Bucket == a.bucket {
+		return errors.New("bucket is frozen")
+	}
+	return nil
+}
+
+// countingFetcher считает вызовы GetObject
+type countingFetcher struct {
+	*MockFetchingExecutor
+	gets int
+}
+
+func (f *countingFetcher) GetObject(ctx context.Context, req *apigw.S3Request, policy ReadOperationPolicy) *apigw.S3Response {
+	f.gets++
+	return f.MockFetchingExecutor.GetObject(ctx, req, policy)
+}
+
+func TestEngine_Handle_AuthorizerDenies(t *testing.T) {
+	replicator := &policyRecordingReplicator{
+		MockReplicationExecutor: NewMockReplicationExecutor(),
+		ackByBucket:             make(map[string]string),
+	}
+	fetcher := &countingFetcher{MockFetchingExecutor: NewMockFetchingExecutor()}
+	engine := NewEngine(&MockAuthenticator{}, replicator, fetcher, nil)
+	engine.SetAuthorizer(&denyingAuthorizer{bucket: "frozen"})
+
+	for _, op := range []apigw.S3Operation{apigw.PutObject, apigw.GetObject} {
+		req := &apigw.S3Request{
+			Operation: op,
+			Bucket:    "frozen",
+			Key:       "test-key",
+			Context:   context.Background(),
+			Headers:   make(http.Header),
+			Query:     make(url.Values),
+			Body:      io.NopCloser(strings.NewReader("data")),
+		}
+
+		resp := engine.Handle(req)
+
+		if resp.StatusCode != http.StatusForbidden {
+			t.Errorf("%s: expected status code %d, got %d", op, http.StatusForbidden, resp.StatusCode)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		if !strings.Contains(string(body), "<Code>AccessDenied</Code>") {
+			t.Errorf("%s: expected AccessDenied error, got %s", op, body)
+		}
+	}
+
+	if len(replicator.ackByBucket) != 0 {
+		t.Error("Denied PUT must not reach the replicator")
+	}
+	if fetcher.gets != 0 {
+		t.Error("Denied GET must not reach the fetcher")
+	}
+
+	// Запросы к другим бакетам authorizer пропускает
+	req := &apigw.S3Request{
+		Operation: apigw.GetObject,
+		Bucket:    "open",
+		Key:       "test-key",
+		Context:   context.Background(),
+		Headers:   make(http.Header),
+		Query:     make(url.Values),
+	}
+	if resp := engine.Handle(req); resp.StatusCode != http.StatusOK {
+		t.Errorf("Expected allowed request to succeed, got %d", resp.StatusCode)
+	}
+}
+
+// invalidatingFetcher - MockFetchingExecutor с кэшем листингов, записывающий инвалидации
+type invalidatingFetcher struct {
+	*MockFetchingExecutor
+	invalidated []string
+}
+
+func (f *invalidatingFetcher) InvalidateListings(bucket, key string) {
+	f.invalidated = append(f.invalidated, bucket+"/"+key)
+}
+
+func TestEngine_Handle_InvalidatesListings(t *testing.T) {
+	fetcher := &invalidatingFetcher{MockFetchingExecutor: NewMockFetchingExecutor()}
+	engine := NewEngine(&MockAuthenticator{}, NewMockReplicationExecutor(), fetcher, nil)
+
+	tests := []struct {
+		operation apigw.S3Operation
+		expected  []string
+	}{
+		{apigw.PutObject, []string{"test-bucket/docs/a.txt"}},
+		{apigw.DeleteObject, []string{"test-bucket/docs/a.txt"}},
+		{apigw.CompleteMultipartUpload, []string{"test-bucket/docs/a.txt"}},
+		{apigw.DeleteBucket, []string{"test-bucket/"}},
+		{apigw.UploadPart, nil},
+		{apigw.GetObject, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.operation.String(), func(t *testing.T) {
+			fetcher.invalidated = nil
+			req := &apigw.S3Request{
+				Operation: tt.operation,
+				Bucket:    "test-bucket",
+				Key:       "docs/a.txt",
+				Context:   context.Background(),
+				Headers:   make(http.Header),
+				Query:     url.Values{"uploadId": {"test-upload-id"}, "partNumber": {"1"}},
+			}
+			engine.Handle(req)
+			if !reflect.DeepEqual(fetcher.invalidated, tt.expected) {
+				t.Errorf("Expected invalidations %v, got %v", tt.expected, fetcher.invalidated)
+			}
+		})
+	}
+}
+
+func TestConfig_ValidateMinRespondingBackends(t *testing.T) {
+	testCases := []struct {
+		name   string
+		policy ReadOperationPolicy
+		valid  bool
+	}{
+		{"reject", ReadOperationPolicy{Strategy: "first", MinRespondingBackends: 2}, true},
+		{"flag", ReadOperationPolicy{Strategy: "quorum", MinRespondingBackends: 2, MinRespondingAction: "flag"}, true},
+		{"negative", ReadOperationPolicy{Strategy: "first", MinRespondingBackends: -1}, false},
+		{"unknown action", ReadOperationPolicy{Strategy: "first", MinRespondingBackends: 2, MinRespondingAction: "ignore"}, false},
+		{"hedged", ReadOperationPolicy{Strategy: "first", MinRespondingBackends: 2, HedgeDelay: time.Second}, false},
+		{"cancel losers", ReadOperationPolicy{Strategy: "first", MinRespondingBackends: 2, CancelLosers: true}, false},
+		{"fanout too small", ReadOperationPolicy{Strategy: "first", MinRespondingBackends: 3, MaxReadFanout: 2}, false},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			config := DefaultConfig()
+			config.Policies.Get = tc.policy
+			if err := config.Validate(); (err == nil) != tc.valid {
+				t.Errorf("Expected valid=%v, got error %v", tc.valid, err)
+			}
+		})
+	}
+}
+
+// replicaStore - версии объекта на двух бэкендах
+type replicaStore struct {
+	mu       sync.Mutex
+	versions [2]string
+}
+
+func (s *replicaStore) set(i int, version string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.versions[i] = version
+}
+
+// halfwayReplicator записывает версию на первый бэкенд, сообщает об этом в halfway
+// и записывает на второй только после release (ack=all)
+type halfwayReplicator struct {
+	*MockReplicationExecutor
+	store   *replicaStore
+	halfway chan struct{}
+	release chan struct{}
+}
+
+func (r *halfwayReplicator) PutObject(ctx context.Context, req *apigw.S3Request, policy WriteOperationPolicy) *apigw.S3Response {
+	body, _ := io.ReadAll(req.Body)
+	r.store.set(0, string(body))
+	close(r.halfway)
+	<-r.release
+	r.store.set(1, string(body))
+	return &apigw.S3Response{StatusCode: http.StatusOK}
+}
+
+// replicaFetcher отдает версию объекта, если бэкенды согласованы, и 409 иначе
+type replicaFetcher struct {
+	*MockFetchingExecutor
+	store *replicaStore
+}
+
+func (f *replicaFetcher) GetObject(ctx context.Context, req *apigw.S3Request, policy ReadOperationPolicy) *apigw.S3Response {
+	f.store.mu.Lock()
+	defer f.store.mu.Unlock()
+	if f.store.versions[0] != f.store.versions[1] {
+		return &apigw.S3Response{StatusCode: http.StatusConflict}
+	}
+	return &apigw.S3Response{StatusCode: http.StatusOK, Body: io.NopCloser(strings.NewReader(f.store.versions[0]))}
+}
+
+func TestEngine_ReadIsolation(t *testing.T) {
+	run := func(get ReadOperationPolicy) (readDuringWrite, readAfterRelease <-chan *apigw.S3Response) {
+		store := &replicaStore{versions: [2]string{"v1", "v1"}}
+		replicator := &halfwayReplicator{
+			MockReplicationExecutor: NewMockReplicationExecutor(),
+			store:                   store,
+			halfway:                 make(chan struct{}),
+			release:                 make(chan struct{}),
+		}
+		config := DefaultConfig()
+		config.Policies.Put.AckLevel = "all"
+		config.Policies.Get = get
+		engine := NewEngine(&MockAuthenticator{}, replicator, &replicaFetcher{MockFetchingExecutor: NewMockFetchingExecutor(), store: store}, config)
+
+		newRequest := func(op apigw.S3Operation, body string) *apigw.S3Request {
+			return &apigw.S3Request{
+				Operation: op,
+				Bucket:    "test-bucket",
+				Key:       "object.txt",
+				Context:   context.Background(),
+				Headers:   make(http.Header),
+				Query:     make(url.Values),
+				Body:      io.NopCloser(strings.NewReader(body)),
+			}
+		}
+
+		putDone := make(chan struct{})
+		go func() {
+			defer close(putDone)
+			engine.Handle(newRequest(apigw.PutObject, "v2"))
+		}()
+		<-replicator.halfway
+
+		// Чтение во время записи: первый бэкенд уже обновлен, второй - еще нет
+		during := make(chan *apigw.S3Response, 1)
+		go func() { during <- engine.Handle(newRequest(apigw.GetObject, "")) }()
+
+		after := make(chan *apigw.S3Response, 1)
+		go func() {
+			time.Sleep(100 * time.Millisecond)
+			close(replicator.release)
+			<-putDone
+			after <- engine.Handle(newRequest(apigw.GetObject, ""))
+		}()
+		return during, after
+	}
+
+	readBody := func(t *testing.T, resp *apigw.S3Response) string {
+		t.Helper()
+		if resp.StatusCode != http.StatusOK {
+			t.Fatalf("Expected consistent read, got status %d", resp.StatusCode)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		return string(body)
+	}
+
+	t.Run("none", func(t *testing.T) {
+		during, _ := run(ReadOperationPolicy{Strategy: "first"})
+		if resp := <-during; resp.StatusCode != http.StatusConflict {
+			t.Errorf("Expected read without isolation to observe partially written object, got %d", resp.StatusCode)
+		}
+	})
+
+	t.Run("wait", func(t *testing.T) {
+		during, after := run(ReadOperationPolicy{Strategy: "first", ReadIsolation: ReadIsolationWait})
+		select {
+		case resp := <-during:
+			t.Fatalf("Expected read to wait for in-progress write, got %d", resp.StatusCode)
+		case <-time.After(50 * time.Millisecond):
+		}
+		if version := readBody(t, <-during); version != "v2" {
+			t.Errorf("Expected read during write to see the new version once confirmed, got %q", version)
+		}
+		if version := readBody(t, <-after); version != "v2" {
+			t.Errorf("Expected v2 after write, got %q", version)
+		}
+	})
+
+	t.Run("timeout", func(t *testing.T) {
+		during, _ := run(ReadOperationPolicy{Strategy: "first", ReadIsolation: ReadIsolationWait, ReadIsolationTimeout: 10 * time.Millisecond})
+		if resp := <-during; resp.StatusCode != http.StatusConflict {
+			t.Errorf("Expected read to proceed without isolation after timeout, got %d", resp.StatusCode)
+		}
+	})
+}
+
+func TestConfig_ValidateReadIsolation(t *testing.T) {
+	config := DefaultConfig()
+	config.Policies.Get.ReadIsolation = "snapshot"
+	if err := config.Validate(); err == nil {
+		t.Error("Expected error for unknown read_isolation")
+	}
+	config.Policies.Get.ReadIsolation = ReadIsolationWait
+	config.Policies.Get.ReadIsolationTimeout = -time.Second
+	if err := config.Validate(); err == nil {
+		t.Error("Expected error for negative read_isolation_timeout")
+	}
+	config.Policies.Get.ReadIsolationTimeout = time.Second
+	if err := config.Validate(); err != nil {
+		t.Errorf("Expected valid config, got %v", err)
+	}
+}
+
+func TestEngine_Handle_VirtualBucket(t *testing.T) {
+	newRequest := func(bucket string) *apigw.S3Request {
+		return &apigw.S3Request{
+			Operation: apigw.GetObject,
+			Bucket:    bucket,
+			Key:       "test-key",
+			Context:   context.Background(),
+			Headers:   make(http.Header),
+			Query:     make(url.Values),
+		}
+	}
+
+	t.Run("strict", func(t *testing.T) {
+		fetcher := &countingFetcher{MockFetchingExecutor: NewMockFetchingExecutor()}
+		engine := NewEngine(&MockAuthenticator{}, NewMockReplicationExecutor(), fetcher, nil)
+		engine.SetVirtualBucket("virtual")
+
+		resp := engine.Handle(newRequest("other"))
+		if resp.StatusCode != http.StatusNotFound {
+			t.Errorf("Expected status code %d, got %d", http.StatusNotFound, resp.StatusCode)
+		}
+		body, _ := io.ReadAll(resp.Body)
+		if !strings.Contains(string(body), "<Code>NoSuchBucket</Code>") {
+			t.Errorf("Expected NoSuchBucket error, got %s", body)
+		}
+		if fetcher.gets != 0 {
+			t.Error("Request to another bucket must not reach the fetcher")
+		}
+
+		resp = engine.Handle(newRequest("virtual"))
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("Expected status code %d for virtual bucket, got %d", http.StatusOK, resp.StatusCode)
+		}
+		if fetcher.gets != 1 {
+			t.Errorf("Expected 1 GetObject call, got %d", fetcher.gets)
+		}
+	})
+
+	t.Run("passthrough", func(t *testing.T) {
+		fetcher := &countingFetcher{MockFetchingExecutor: NewMockFetchingExecutor()}
+		engine := NewEngine(&MockAuthenticator{}, NewMockReplicationExecutor(), fetcher, nil)
+
+		resp := engine.Handle(newRequest("other"))
+		if resp.StatusCode != http.StatusOK {
+			t.Errorf("Expected status code %d, got %d", http.StatusOK, resp.StatusCode)
+		}
+		if fetcher.gets != 1 {
+			t.Errorf("Expected 1 GetObject call, got %d", fetcher.gets)
+		}
+	})
+}
@@ -116,6 +116,47 @@ func (m *MockReplicationExecutor) AbortMultipartUpload(ctx context.Context, req
 	}
 }
 
+func (m *MockReplicationExecutor) CreateBucket(ctx context.Context, req *apigw.S3Request, policy WriteOperationPolicy) *apigw.S3Response {
+	logger.Debug("MockReplicationExecutor.CreateBucket called with policy: %+v", policy)
+	logger.Info("Mock Replication: CREATE BUCKET %s (ack=%s)", req.Bucket, policy.AckLevel)
+
+	headers := make(http.Header)
+	headers.Set("Location", "/"+req.Bucket)
+
+	return &apigw.S3Response{
+		StatusCode: http.StatusOK,
+		Headers:    headers,
+	}
+}
+
+func (m *MockReplicationExecutor) DeleteBucket(ctx context.Context, req *apigw.S3Request, policy WriteOperationPolicy) *apigw.S3Response {
+	logger.Debug("MockReplicationExecutor.DeleteBucket called with policy: %+v", policy)
+	logger.Info("Mock Replication: DELETE BUCKET %s (ack=%s)", req.Bucket, policy.AckLevel)
+
+	return &apigw.S3Response{
+		StatusCode: http.StatusNoContent,
+	}
+}
+
+func (m *MockReplicationExecutor) PutObjectTagging(ctx context.Context, req *apigw.S3Request, policy WriteOperationPolicy) *apigw.S3Response {
+	logger.Debug("MockReplicationExecutor.PutObjectTagging called with policy: %+v", policy)
+	logger.Info("Mock Replication: PUT TAGGING %s/%s (ack=%s)", req.Bucket, req.Key, policy.AckLevel)
+
+	return &apigw.S3Response{
+		StatusCode: http.StatusOK,
+		Headers:    make(http.Header),
+	}
+}
+
+func (m *MockReplicationExecutor) DeleteObjectTagging(ctx context.Context, req *apigw.S3Request, policy WriteOperationPolicy) *apigw.S3Response {
+	logger.Debug("MockReplicationExecutor.DeleteObjectTagging called with policy: %+v", policy)
+	logger.Info("Mock Replication: DELETE TAGGING %s/%s (ack=%s)", req.Bucket, req.Key, policy.AckLevel)
+
+	return &apigw.S3Response{
+		StatusCode: http.StatusNoContent,
+	}
+}
+
 // MockFetchingExecutor - mock реализация FetchingExecutor для тестирования
 type MockFetchingExecutor struct{}
 
@@ -275,3 +316,111 @@ func (m *MockFetchingExecutor) ListMultipartUploads(ctx context.Context, req *ap
 		Body:       io.NopCloser(strings.NewReader(xmlContent)),
 	}
 }
+
+func (m *MockFetchingExecutor) ListParts(ctx context.Context, req *apigw.S3Request) *apigw.S3Response {
+	logger.Debug("MockFetchingExecutor.ListParts called")
+	logger.Info("Mock Fetching: LIST PARTS %s/%s uploadId=%s", req.Bucket, req.Key, req.Query.Get("uploadId"))
+
+	xmlContent := fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<ListPartsResult xmlns="http://s3.amazonaws.com/doc/2006-03-01/">
+    <Bucket>%s</Bucket>
+    <Key>%s</Key>
+    <UploadId>%s</UploadId>
+    <PartNumberMarker>0</PartNumberMarker>
+    <NextPartNumberMarker>1</NextPartNumberMarker>
+    <MaxParts>1000</MaxParts>
+    <IsTruncated>false</IsTruncated>
+    <Part>
+        <PartNumber>1</PartNumber>
+        <LastModified>2025-06-21T15:00:00.000Z</LastModified>
+        <ETag>"mock-part-etag-1"</ETag>
+        <Size>5242880</Size>
+    </Part>
+</ListPartsResult>`, req.Bucket, req.Key, req.Query.Get("uploadId"))
+
+	headers := make(http.Header)
+	headers.Set("Content-Type", "application/xml")
+	headers.Set("Content-Length", fmt.Sprintf("%d", len(xmlContent)))
+	
+	return &apigw.S3Response{
+		StatusCode: http.StatusOK,
+		Headers:    headers,
+		Body:       io.NopCloser(strings.NewReader(xmlContent)),
+	}
+}
+
+func (m *MockFetchingExecutor) GetObjectTagging(ctx context.Context, req *apigw.S3Request) *apigw.S3Response {
+	logger.Debug("MockFetchingExecutor.GetObjectTagging called")
+	logger.Info("Mock Fetching: GET TAGGING %s/%s", req.Bucket, req.Key)
+
+	xmlContent := `<?xml version="1.0" encoding="UTF-8"?>
+<Tagging xmlns="http://s3.amazonaws.com/doc/2006-03-01/">
+    <TagSet>
+        <Tag>
+            <Key>mock-tag</Key>
+            <Value>mock-value</Value>
+        </Tag>
+    </TagSet>
+</Tagging>`
+
+	headers := make(http.Header)
+	headers.Set("Content-Type", "application/xml")
+	headers.Set("Content-Length", fmt.Sprintf("%d", len(xmlContent)))
+
+	return &apigw.S3Response{
+		StatusCode: http.StatusOK,
+		Headers:    headers,
+		Body:       io.NopCloser(strings.NewReader(xmlContent)),
+	}
+}
+
+func (m *MockFetchingExecutor) ListObjectVersions(ctx context.Context, req *apigw.S3Request) *apigw.S3Response {
+	logger.Debug("MockFetchingExecutor.ListObjectVersions called")
+	logger.Info("Mock Fetching: LIST VERSIONS %s", req.Bucket)
+
+	xmlContent := fmt.Sprintf(`<?xml version="1.0" encoding="UTF-8"?>
+<ListVersionsResult xmlns="http://s3.amazonaws.com/doc/2006-03-01/">
+    <Name>%s</Name>
+    <Prefix></Prefix>
+    <KeyMarker></KeyMarker>
+    <VersionIdMarker></VersionIdMarker>
+    <MaxKeys>1000</MaxKeys>
+    <IsTruncated>false</IsTruncated>
+    <Version>
+        <Key>mock-object.txt</Key>
+        <VersionId>null</VersionId>
+        <IsLatest>true</IsLatest>
+        <LastModified>2025-06-21T15:00:00.000Z</LastModified>
+        <ETag>"mock-etag"</ETag>
+        <Size>1024</Size>
+    </Version>
+</ListVersionsResult>`, req.Bucket)
+
+	headers := make(http.Header)
+	headers.Set("Content-Type", "application/xml")
+	headers.Set("Content-Length", fmt.Sprintf("%d", len(xmlContent)))
+
+	return &apigw.S3Response{
+		StatusCode: http.StatusOK,
+		Headers:    headers,
+		Body:       io.NopCloser(strings.NewReader(xmlContent)),
+	}
+}
+
+func (m *MockFetchingExecutor) GetBucketVersioning(ctx context.Context, req *apigw.S3Request) *apigw.S3Response {
+	logger.Debug("MockFetchingExecutor.GetBucketVersioning called")
+	logger.Info("Mock Fetching: GET VERSIONING %s", req.Bucket)
+
+	xmlContent := `<?xml version="1.0" encoding="UTF-8"?>
+<VersioningConfiguration xmlns="http://s3.amazonaws.com/doc/2006-03-01/"/>`
+
+	headers := make(http.Header)
+	headers.Set("Content-Type", "application/xml")
+	headers.Set("Content-Length", fmt.Sprintf("%d", len(xmlContent)))
+
+	return &apigw.S3Response{
+		StatusCode: http.StatusOK,
+		Headers:    headers,
+		Body:       io.NopCloser(strings.NewReader(xmlContent)),
+	}
+}
@@ -0,0 +1,120 @@
+package routing
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"s3proxy/apigw"
+	"s3proxy/logger"
+)
+
+// Режимы ReadOperationPolicy.ReadIsolation
+const (
+	// ReadIsolationNone - чтение не согласуется с записью того же ключа (по умолчанию)
+	ReadIsolationNone = "none"
+	// ReadIsolationWait - чтение ключа, запись которого выполняется, ждет ее завершения
+	ReadIsolationWait = "wait"
+)
+
+// DefaultReadIsolationTimeout - сколько чтение ждет завершения записи по умолчанию
+const DefaultReadIsolationTimeout = 5 * time.Second
+
+// keyWrites отслеживает выполняющиеся через Engine записи объектов по ключам.
+// Нулевое значение готово к использованию.
+type keyWrites struct {
+	mu     sync.Mutex
+	active map[string]*keyWrite
+}
+
+// keyWrite - выполняющиеся записи одного ключа. done закрывается, когда завершится
+// последняя из них.
+type keyWrite struct {
+	count int
+	done  chan struct{}
+}
+
+// keyWriteID возвращает ключ карты записей для объекта
+func keyWriteID(bucket, key string) string {
+	return bucket + "/" + key
+}
+
+// begin отмечает начало записи объекта. Возвращаемую функцию нужно вызвать, когда
+// исполнитель вернет ответ.
+func (w *keyWrites) begin(bucket, key string) func() {
+	id := keyWriteID(bucket, key)
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.active == nil {
+		w.active = make(map[string]*keyWrite)
+	}
+	write, ok := w.active[id]
+	if !ok {
+		write = &keyWrite{done: make(chan struct{})}
+		w.active[id] = write
+	}
+	write.count++
+
+	return func() {
+		w.mu.Lock()
+		defer w.mu.Unlock()
+		write.count--
+		if write.count == 0 {
+			close(write.done)
+			delete(w.active, id)
+		}
+	}
+}
+
+// wait ждет завершения записей объекта, начатых до вызова, но не дольше timeout
+// и не дольше жизни ctx. Возвращает false, если записи не завершились.
+func (w *keyWrites) wait(ctx context.Context, bucket, key string, timeout time.Duration) bool {
+	w.mu.Lock()
+	write, ok := w.active[keyWriteID(bucket, key)]
+	w.mu.Unlock()
+	if !ok {
+		return true
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case <-write.done:
+		return true
+	case <-timer.C:
+		return false
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// trackWrite выполняет запись объекта, отмечая ее для согласования с чтениями
+func (e *Engine) trackWrite(req *apigw.S3Request, write func() *apigw.S3Response) *apigw.S3Response {
+	done := e.writes.begin(req.Bucket, req.Key)
+	defer done()
+	return write()
+}
+
+// isolateRead при ReadIsolation "wait" придерживает чтение объекта, пока выполняется его
+// запись: иначе чтение может попасть на бэкенды, часть которых уже записала новую версию,
+// а часть - еще нет. Если запись не завершилась за ReadIsolationTimeout, чтение выполняется
+// как обычно.
+func (e *Engine) isolateRead(req *apigw.S3Request, policy ReadOperationPolicy) {
+	if policy.ReadIsolation != ReadIsolationWait {
+		return
+	}
+	timeout := policy.ReadIsolationTimeout
+	if timeout <= 0 {
+		timeout = DefaultReadIsolationTimeout
+	}
+	start := time.Now()
+	if !e.writes.wait(req.Context, req.Bucket, req.Key, timeout) {
+		logger.Warn("[%s] Write of %s/%s did not complete within %v, reading without isolation",
+			req.RequestID, req.Bucket, req.Key, timeout)
+		return
+	}
+	if waited := time.Since(start); waited > time.Millisecond {
+		logger.Debug("[%s] Read of %s/%s waited %v for in-progress write", req.RequestID, req.Bucket, req.Key, waited)
+	}
+}
@@ -2,8 +2,11 @@ package routing
 
 import (
 	"context"
+	"fmt"
+	"time"
 
 	"s3proxy/apigw"
+	"s3proxy/auth"
 )
 
 // WriteOperationPolicy определяет политику для операций записи
@@ -11,13 +14,137 @@ type WriteOperationPolicy struct {
 	// AckLevel определяет, сколько подтверждений ждать
 	// Возможные значения: "none", "one", "all"
 	AckLevel string `yaml:"ack"`
+
+	// PreferredBackend - ID бэкенда, результат которого предпочтительно использовать
+	// для подтверждения при ack=one (например, основного хранилища, из которого затем
+	// читают клиенты). Если он ответит успехом в течение PreferredWait, клиент получит
+	// его ответ, даже если другие бэкенды ответили раньше. Пусто - первый успешный ответ.
+	PreferredBackend string `yaml:"preferred_backend"`
+
+	// PreferredWait - сколько ждать ответа PreferredBackend, прежде чем подтвердить
+	// запись ответом другого бэкенда. 0 - DefaultPreferredWait.
+	PreferredWait time.Duration `yaml:"preferred_wait"`
+
+	// ContentTypeBackends направляет запись объекта на набор бэкендов по его Content-Type
+	// (например, изображения - на один уровень хранения, логи - на другой). Ключ - тип
+	// ("image/png") или группа типов ("image/*"), значение - ID бэкендов. Точное совпадение
+	// важнее группы. Объекты с типом, которого нет в карте, пишутся на все бэкенды.
+	// Учитывается для PUT и CreateMultipartUpload.
+	ContentTypeBackends map[string][]string `yaml:"content_type_backends"`
+
+	// VerifyAfterWrite - при ack=all после успешного PUT запрашивать у каждого бэкенда
+	// HEAD объекта и подтверждать запись клиенту, только если объект читается со всех
+	// бэкендов с ETag из ответа PUT. Увеличивает задержку записи на один HEAD.
+	VerifyAfterWrite bool `yaml:"verify_after_write"`
+
+	// Failover - при ack=one записывать объект только на один бэкенд: сначала на бэкенд
+	// с наибольшим весом (weight; PreferredBackend, если задан, - первым), а следующий
+	// пробовать, только если запись на предыдущий завершилась ошибкой. Вместо записи
+	// на все бэкенды выполняется одна запись. Учитывается только для PUT.
+	Failover bool `yaml:"failover"`
 }
 
+// DefaultPreferredWait - время ожидания PreferredBackend по умолчанию
+const DefaultPreferredWait = 200 * time.Millisecond
+
 // ReadOperationPolicy определяет политику для операций чтения
 type ReadOperationPolicy struct {
 	// Strategy определяет, как выбрать бэкенд для чтения
-	// Возможные значения: "first", "newest"
+	// Возможные значения: "first", "newest", "quorum"
 	Strategy string `yaml:"strategy"`
+
+	// ReadRepair включает фоновое восстановление объекта на отставших бэкендах.
+	// Используется только стратегией "newest": после ответа клиенту самая новая
+	// версия копируется на бэкенды, вернувшие 404 или более старый Last-Modified.
+	ReadRepair bool `yaml:"read_repair"`
+
+	// MaxReadFanout ограничивает число бэкендов, опрашиваемых за одно чтение
+	// стратегиями "first" и "quorum". Выбираются бэкенды с наименьшей задержкой.
+	// 0 - опрашивать все живые бэкенды.
+	MaxReadFanout int `yaml:"max_read_fanout"`
+
+	// ExposeServedBy добавляет в ответы GET/HEAD заголовок x-amz-proxy-served-by
+	// с ID бэкенда, ответ которого отдан клиенту. Раскрывает топологию, по умолчанию выключен.
+	ExposeServedBy bool `yaml:"expose_served_by"`
+
+	// HedgeDelay включает hedged-чтение для стратегии "first": запрос отправляется бэкенду
+	// с наибольшим весом (weight), и если он не ответил за HedgeDelay (или ответил ошибкой),
+	// запрос отправляется следующему и т.д. Первый успешный ответ возвращается, остальные
+	// запросы отменяются. 0 - опрашивать все бэкенды одновременно.
+	HedgeDelay time.Duration `yaml:"hedge_delay"`
+
+	// CancelLosers отменяет запросы стратегии "first" к остальным бэкендам после первого
+	// успешного ответа, чтобы не скачивать тело объекта со всех бэкендов. Отмененные запросы
+	// не влияют на health-check. По умолчанию выключен: все запросы завершаются и дают статистику.
+	CancelLosers bool `yaml:"cancel_losers"`
+
+	// RedirectThreshold - размер объекта в байтах, начиная с которого GET не проксируется,
+	// а клиент получает 307 с presigned URL бэкенда и скачивает объект напрямую.
+	// Бэкенд должен быть доступен клиентам по своему endpoint. 0 - выключено.
+	RedirectThreshold int64 `yaml:"redirect_threshold"`
+
+	// RedirectExpiry - срок действия presigned URL для редиректа (по умолчанию DefaultRedirectExpiry)
+	RedirectExpiry time.Duration `yaml:"redirect_expiry"`
+
+	// ShadowBackends - ID теневых бэкендов. Они не обслуживают чтение, но после ответа
+	// клиенту получают тот же GET/HEAD асинхронно; расхождения статуса, ETag и размера
+	// с основным ответом учитываются в метрике s3proxy_shadow_read_mismatches_total.
+	// Запись на теневые бэкенды реплицируется как обычно.
+	ShadowBackends []string `yaml:"shadow_backends"`
+
+	// ShadowLogMismatches дополнительно пишет расхождения теневых чтений в лог
+	ShadowLogMismatches bool `yaml:"shadow_log_mismatches"`
+
+	// NewestFallbackLatency включает деградацию стратегии "newest" до "first" под нагрузкой:
+	// если фаза HEAD запросов ко всем бэкендам заняла больше этого времени, следующие чтения
+	// того же бакета в течение NewestFallbackCooldown выполняются стратегией "first". 0 - выключено.
+	NewestFallbackLatency time.Duration `yaml:"newest_fallback_latency"`
+
+	// NewestFallbackCooldown - как долго чтения выполняются стратегией "first" после медленной
+	// фазы HEAD, прежде чем "newest" будет опробована снова. 0 - DefaultNewestFallbackCooldown.
+	NewestFallbackCooldown time.Duration `yaml:"newest_fallback_cooldown"`
+
+	// MinRespondingBackends - сколько бэкендов должны ответить на GET/HEAD объекта (успехом
+	// или 404), чтобы ответ был отдан как обычно. Защищает от чтения с единственного доступного
+	// бэкенда, когда остальные отрезаны сетью. Ошибки и таймауты ответом не считаются.
+	// Стратегия "first" дожидается ответов стольких бэкендов. 0 - не проверять.
+	MinRespondingBackends int `yaml:"min_responding_backends"`
+
+	// MinRespondingAction - что делать, если ответило меньше MinRespondingBackends бэкендов:
+	// "reject" (по умолчанию) - ответить 503 ServiceUnavailable, "flag" - отдать ответ
+	// с заголовком x-amz-proxy-degraded-read
+	MinRespondingAction string `yaml:"min_responding_action"`
+
+	// ReadIsolation - согласование GET/HEAD объекта с его записью через прокси (PUT, DELETE,
+	// CompleteMultipartUpload): "none" (по умолчанию) - читать сразу; во время записи ack=all
+	// часть бэкендов может отдавать новую версию, а часть - старую. "wait" - чтение ключа,
+	// запись которого выполняется, ждет ее подтверждения всеми бэкендами и видит новую версию.
+	ReadIsolation string `yaml:"read_isolation"`
+
+	// ReadIsolationTimeout - сколько чтение при ReadIsolation "wait" ждет завершения записи,
+	// прежде чем читать без согласования. 0 - DefaultReadIsolationTimeout.
+	ReadIsolationTimeout time.Duration `yaml:"read_isolation_timeout"`
+}
+
+// DefaultNewestFallbackCooldown - время деградации "newest" до "first" по умолчанию
+const DefaultNewestFallbackCooldown = 30 * time.Second
+
+// DefaultRedirectExpiry - срок действия presigned URL для редиректа по умолчанию
+const DefaultRedirectExpiry = 15 * time.Minute
+
+// Authorizer - дополнительная проверка прав, вызываемая Engine после аутентификации
+// и встроенной проверки ограничений пользователя (бакеты и операции).
+// Ошибка означает отказ: клиент получает 403 AccessDenied, запрос не передается исполнителям.
+type Authorizer interface {
+	Authorize(identity *auth.UserIdentity, req *apigw.S3Request) error
+}
+
+// AllowAllAuthorizer разрешает все запросы. Используется, если Authorizer не задан.
+type AllowAllAuthorizer struct{}
+
+// Authorize реализует интерфейс Authorizer
+func (AllowAllAuthorizer) Authorize(*auth.UserIdentity, *apigw.S3Request) error {
+	return nil
 }
 
 // ReplicationExecutor - интерфейс для модуля, выполняющего запись на бэкенды
@@ -39,6 +166,18 @@ type ReplicationExecutor interface {
 	
 	// AbortMultipartUpload отменяет multipart upload
 	AbortMultipartUpload(ctx context.Context, req *apigw.S3Request, policy WriteOperationPolicy) *apigw.S3Response
+
+	// CreateBucket создает бакет на бэкендах
+	CreateBucket(ctx context.Context, req *apigw.S3Request, policy WriteOperationPolicy) *apigw.S3Response
+
+	// DeleteBucket удаляет бакет на бэкендах
+	DeleteBucket(ctx context.Context, req *apigw.S3Request, policy WriteOperationPolicy) *apigw.S3Response
+
+	// PutObjectTagging устанавливает теги объекта на бэкендах
+	PutObjectTagging(ctx context.Context, req *apigw.S3Request, policy WriteOperationPolicy) *apigw.S3Response
+
+	// DeleteObjectTagging удаляет теги объекта на бэкендах
+	DeleteObjectTagging(ctx context.Context, req *apigw.S3Request, policy WriteOperationPolicy) *apigw.S3Response
 }
 
 // FetchingExecutor - интерфейс для модуля, выполняющего чтение с бэкендов
@@ -60,6 +199,26 @@ type FetchingExecutor interface {
 	
 	// ListMultipartUploads выполняет операцию LIST MULTIPART UPLOADS
 	ListMultipartUploads(ctx context.Context, req *apigw.S3Request) *apigw.S3Response
+
+	// ListParts возвращает загруженные части multipart upload
+	ListParts(ctx context.Context, req *apigw.S3Request) *apigw.S3Response
+
+	// ListObjectVersions возвращает список версий объектов бакета (GET ?versions)
+	ListObjectVersions(ctx context.Context, req *apigw.S3Request) *apigw.S3Response
+
+	// GetBucketVersioning возвращает состояние версионирования бакета (GET ?versioning)
+	GetBucketVersioning(ctx context.Context, req *apigw.S3Request) *apigw.S3Response
+
+	// GetObjectTagging возвращает теги объекта (со стратегией "first")
+	GetObjectTagging(ctx context.Context, req *apigw.S3Request) *apigw.S3Response
+}
+
+// ListingInvalidator - необязательный интерфейс FetchingExecutor с кэшем листингов.
+// Engine вызывает его после операций, меняющих состав объектов бакета.
+type ListingInvalidator interface {
+	// InvalidateListings сбрасывает листинги бакета, которые могли включать key
+	// (пустой key - все листинги бакета)
+	InvalidateListings(bucket, key string)
 }
 
 // Policies содержит все политики для различных операций
@@ -72,8 +231,118 @@ type Policies struct {
 // Config содержит конфигурацию для Policy & Routing Engine
 type Config struct {
 	Policies Policies `yaml:"policies"`
+
+	// BucketPolicies - переопределения политик для отдельных бакетов (имя бакета -> политики).
+	// Политика операции, которая в переопределении не задана (пустые ack или strategy),
+	// берется из Policies.
+	BucketPolicies map[string]Policies `yaml:"bucket_policies"`
+
+	// Region - регион, который прокси сообщает клиентам (заголовок x-amz-bucket-region
+	// в ответе на HEAD /). Пустое значение означает us-east-1.
+	Region string `yaml:"region"`
+}
+
+// PoliciesFor возвращает политики, действующие для бакета: переопределения из
+// BucketPolicies поверх глобальных Policies
+func (c *Config) PoliciesFor(bucket string) Policies {
+	return mergePolicies(c.Policies, c.BucketPolicies[bucket])
+}
+
+// mergePolicies накладывает заданные в override политики операций на base
+func mergePolicies(base, override Policies) Policies {
+	if override.Put.AckLevel != "" {
+		base.Put = override.Put
+	}
+	if override.Delete.AckLevel != "" {
+		base.Delete = override.Delete
+	}
+	if override.Get.Strategy != "" {
+		base.Get = override.Get
+	}
+	return base
+}
+
+// Validate проверяет уровни подтверждения и стратегии чтения в глобальных политиках
+// и переопределениях бакетов
+func (c *Config) Validate() error {
+	if err := c.Policies.validate("routing.policies"); err != nil {
+		return err
+	}
+	for bucket, policies := range c.BucketPolicies {
+		if bucket == "" {
+			return fmt.Errorf("routing.bucket_policies: bucket name cannot be empty")
+		}
+		if err := policies.validate("routing.bucket_policies." + bucket); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// validate проверяет значения ack и strategy. Пустые значения допустимы:
+// в переопределениях они означают наследование глобальной политики.
+func (p Policies) validate(prefix string) error {
+	for name, ack := range map[string]string{"put": p.Put.AckLevel, "delete": p.Delete.AckLevel} {
+		switch ack {
+		case "", "none", "one", "all":
+		default:
+			return fmt.Errorf("%s.%s.ack must be one of none, one, all, got %q", prefix, name, ack)
+		}
+	}
+	if p.Put.VerifyAfterWrite && p.Put.AckLevel != "all" {
+		return fmt.Errorf("%s.put.verify_after_write requires ack=all", prefix)
+	}
+	if p.Put.Failover && p.Put.AckLevel != "one" {
+		return fmt.Errorf("%s.put.failover requires ack=one", prefix)
+	}
+	if p.Delete.Failover {
+		return fmt.Errorf("%s.delete.failover is only supported for put", prefix)
+	}
+	switch p.Get.Strategy {
+	case "", "first", "newest", "quorum":
+	default:
+		return fmt.Errorf("%s.get.strategy must be one of first, newest, quorum, got %q", prefix, p.Get.Strategy)
+	}
+	switch p.Get.ReadIsolation {
+	case "", ReadIsolationNone, ReadIsolationWait:
+	default:
+		return fmt.Errorf("%s.get.read_isolation must be one of none, wait, got %q", prefix, p.Get.ReadIsolation)
+	}
+	if p.Get.ReadIsolationTimeout < 0 {
+		return fmt.Errorf("%s.get.read_isolation_timeout cannot be negative", prefix)
+	}
+	if p.Get.NewestFallbackLatency < 0 || p.Get.NewestFallbackCooldown < 0 {
+		return fmt.Errorf("%s.get.newest_fallback_latency and newest_fallback_cooldown cannot be negative", prefix)
+	}
+	return p.Get.validateMinResponding(prefix + ".get")
 }
 
+// validateMinResponding проверяет min_responding_backends и его совместимость с настройками,
+// при которых часть бэкендов заведомо не отвечает
+func (p ReadOperationPolicy) validateMinResponding(prefix string) error {
+	if p.MinRespondingBackends < 0 {
+		return fmt.Errorf("%s.min_responding_backends cannot be negative", prefix)
+	}
+	switch p.MinRespondingAction {
+	case "", "reject", "flag":
+	default:
+		return fmt.Errorf("%s.min_responding_action must be one of reject, flag, got %q", prefix, p.MinRespondingAction)
+	}
+	if p.MinRespondingBackends <= 1 {
+		return nil
+	}
+	if p.HedgeDelay > 0 || p.CancelLosers {
+		return fmt.Errorf("%s.min_responding_backends cannot be combined with hedge_delay or cancel_losers", prefix)
+	}
+	if p.MaxReadFanout > 0 && p.MaxReadFanout < p.MinRespondingBackends {
+		return fmt.Errorf("%s.max_read_fanout (%d) is less than min_responding_backends (%d)", prefix, p.MaxReadFanout, p.MinRespondingBackends)
+	}
+	return nil
+}
+
+// DefaultRegion - регион по умолчанию, как у AWS S3
+const DefaultRegion = "us-east-1"
+
 // DefaultConfig возвращает конфигурацию по умолчанию
 func DefaultConfig() *Config {
 	return &Config{
@@ -88,5 +357,6 @@ func DefaultConfig() *Config {
 				Strategy: "first",
 			},
 		},
+		Region: DefaultRegion,
 	}
 }
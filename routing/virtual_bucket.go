@@ -0,0 +1,34 @@
+package routing
+
+import (
+	"net/http"
+
+	"s3proxy/apigw"
+	"s3proxy/logger"
+)
+
+// Режимы проверки имени бакета в запросах (server.bucket_mode)
+const (
+	// BucketModePassthrough - запросы к любому бакету обслуживаются бакетами бэкендов (по умолчанию)
+	BucketModePassthrough = "passthrough"
+	// BucketModeStrict - обслуживаются только запросы к виртуальному бакету прокси
+	BucketModeStrict = "strict"
+)
+
+// SetVirtualBucket включает строгий режим: запросы к бакету, отличному от bucket,
+// получают NoSuchBucket, как если бы бакета не было. Пустое имя возвращает режим
+// passthrough. Вызывается до начала обработки запросов.
+func (e *Engine) SetVirtualBucket(bucket string) {
+	e.virtualBucket = bucket
+}
+
+// checkVirtualBucket возвращает ошибку NoSuchBucket для запроса к бакету, отличному
+// от виртуального. Запросы без бакета (ListBuckets, HEAD /) не проверяются.
+func (e *Engine) checkVirtualBucket(req *apigw.S3Request) *apigw.S3Response {
+	if e.virtualBucket == "" || req.Bucket == "" || req.Bucket == e.virtualBucket {
+		return nil
+	}
+	logger.Debug("[%s] Rejecting %s on bucket %q: only virtual bucket %q is served",
+		req.RequestID, req.Operation, req.Bucket, e.virtualBucket)
+	return e.errorResponse(req, http.StatusNotFound, "NoSuchBucket", "The specified bucket does not exist")
+}
@@ -0,0 +1,33 @@
+package tracing
+
+import "fmt"
+
+// Config содержит конфигурацию трассировки OpenTelemetry
+type Config struct {
+	// Endpoint - адрес OTLP/HTTP коллектора (например, "localhost:4318").
+	// Пустой адрес выключает трассировку: спаны создаются no-op провайдером.
+	Endpoint string `yaml:"endpoint"`
+
+	// Insecure - отправлять спаны по HTTP без TLS
+	Insecure bool `yaml:"insecure"`
+
+	// ServiceName - имя сервиса в ресурсе спанов (по умолчанию "s3proxy")
+	ServiceName string `yaml:"service_name"`
+
+	// SampleRatio - доля трассируемых запросов от 0 до 1 (0 - все запросы).
+	// Решение о сэмплировании входящего traceparent сохраняется.
+	SampleRatio float64 `yaml:"sample_ratio"`
+}
+
+// Enabled возвращает true, если задан коллектор
+func (c *Config) Enabled() bool {
+	return c.Endpoint != ""
+}
+
+// Validate проверяет корректность конфигурации
+func (c *Config) Validate() error {
+	if c.SampleRatio < 0 || c.SampleRatio > 1 {
+		return fmt.Errorf("sample_ratio must be between 0 and 1, got %v", c.SampleRatio)
+	}
+	return nil
+}
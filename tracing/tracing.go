@@ -0,0 +1,59 @@
+// Package tracing настраивает трассировку OpenTelemetry. Спаны создают сами модули
+// (apigw, routing, backend) через глобальный TracerProvider, поэтому без Setup или
+// с пустым Endpoint трассировка ничего не стоит: используется no-op провайдер.
+package tracing
+
+import (
+	"context"
+	"fmt"
+
+	"s3proxy/logger"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+// defaultServiceName - имя сервиса в спанах по умолчанию
+const defaultServiceName = "s3proxy"
+
+// Setup устанавливает глобальный TracerProvider с экспортом спанов в OTLP/HTTP коллектор
+// и пропагатор W3C Trace Context. Возвращает функцию, которая отправляет накопленные
+// спаны и останавливает провайдер. Если коллектор не задан, ничего не меняет.
+func Setup(ctx context.Context, cfg *Config) (func(context.Context) error, error) {
+	if !cfg.Enabled() {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	options := []otlptracehttp.Option{otlptracehttp.WithEndpoint(cfg.Endpoint)}
+	if cfg.Insecure {
+		options = append(options, otlptracehttp.WithInsecure())
+	}
+	exporter, err := otlptracehttp.New(ctx, options...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OTLP exporter: %w", err)
+	}
+
+	serviceName := cfg.ServiceName
+	if serviceName == "" {
+		serviceName = defaultServiceName
+	}
+	ratio := cfg.SampleRatio
+	if ratio == 0 {
+		ratio = 1
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(resource.NewSchemaless(attribute.String("service.name", serviceName))),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(ratio))),
+	)
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	logger.Info("Tracing enabled: exporting spans to %s (service %s, sample ratio %v)", cfg.Endpoint, serviceName, ratio)
+	return provider.Shutdown, nil
+}
@@ -0,0 +1,139 @@
+package tracing_test
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"s3proxy/apigw"
+	"s3proxy/auth"
+	"s3proxy/backend"
+	"s3proxy/fetch"
+	"s3proxy/internal/backendtest"
+	"s3proxy/internal/s3mock"
+	"s3proxy/routing"
+	"s3proxy/tracing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+// setupRecorder устанавливает глобальный провайдер, записывающий спаны в память
+func setupRecorder(t *testing.T) *tracetest.SpanRecorder {
+	t.Helper()
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	prevProvider, prevPropagator := otel.GetTracerProvider(), otel.GetTextMapPropagator()
+	otel.SetTracerProvider(provider)
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+	t.Cleanup(func() {
+		otel.SetTracerProvider(prevProvider)
+		otel.SetTextMapPropagator(prevPropagator)
+	})
+	return recorder
+}
+
+// newTestGateway собирает шлюз, Engine и Fetcher поверх одного mock бэкенда с объектом "key"
+func newTestGateway(t *testing.T) *apigw.Gateway {
+	t.Helper()
+	server := s3mock.NewServer("test-bucket")
+	t.Cleanup(server.Close)
+	server.SetObject("key", []byte("hello"), time.Now())
+
+	manager, err := backendtest.NewManager(&backend.Config{
+		Backends: map[string]backend.BackendConfig{"backend-1": backendtest.BackendConfig(server)},
+	})
+	require.NoError(t, err)
+
+	fetcher := fetch.NewFetcher(manager, fetch.NewStubCache(), "test-bucket")
+	engine := routing.NewEngine(auth.NewAnonymousAuthenticator(nil, nil),
+		routing.NewMockReplicationExecutor(), fetcher, nil)
+	return apigw.New(apigw.DefaultConfig(), engine)
+}
+
+// findSpan возвращает завершенный спан с указанным именем
+func findSpan(t *testing.T, spans []sdktrace.ReadOnlySpan, name string) sdktrace.ReadOnlySpan {
+	t.Helper()
+	for _, span := range spans {
+		if span.Name() == name {
+			return span
+		}
+	}
+	t.Fatalf("span %q not recorded", name)
+	return nil
+}
+
+// attributeValue возвращает значение атрибута спана
+func attributeValue(span sdktrace.ReadOnlySpan, key attribute.Key) attribute.Value {
+	for _, kv := range span.Attributes() {
+		if kv.Key == key {
+			return kv.Value
+		}
+	}
+	return attribute.Value{}
+}
+
+func TestTracing_SpanTree(t *testing.T) {
+	recorder := setupRecorder(t)
+	gw := newTestGateway(t)
+
+	w := httptest.NewRecorder()
+	gw.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/test-bucket/key", nil))
+	require.Equal(t, http.StatusOK, w.Code)
+
+	spans := recorder.Ended()
+	root := findSpan(t, spans, "s3proxy GET")
+	authSpan := findSpan(t, spans, "auth")
+	route := findSpan(t, spans, "route")
+	backendSpan := findSpan(t, spans, "backend GetObject")
+
+	// gateway -> {auth, route}, route -> backend
+	assert.False(t, root.Parent().IsValid(), "gateway span must be the root")
+	assert.Equal(t, root.SpanContext().SpanID(), authSpan.Parent().SpanID())
+	assert.Equal(t, root.SpanContext().SpanID(), route.Parent().SpanID())
+	assert.Equal(t, route.SpanContext().SpanID(), backendSpan.Parent().SpanID())
+	for _, span := range []sdktrace.ReadOnlySpan{authSpan, route, backendSpan} {
+		assert.Equal(t, root.SpanContext().TraceID(), span.SpanContext().TraceID())
+	}
+
+	assert.Equal(t, "GET_OBJECT", attributeValue(root, "s3.operation").AsString())
+	assert.Equal(t, int64(http.StatusOK), attributeValue(root, "http.response.status_code").AsInt64())
+	assert.Equal(t, "backend-1", attributeValue(backendSpan, "backend.id").AsString())
+	assert.Equal(t, int64(http.StatusOK), attributeValue(backendSpan, "http.response.status_code").AsInt64())
+}
+
+func TestTracing_PropagatesIncomingTraceparent(t *testing.T) {
+	recorder := setupRecorder(t)
+	gw := newTestGateway(t)
+
+	req := httptest.NewRequest(http.MethodHead, "/test-bucket/key", nil)
+	req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	gw.ServeHTTP(httptest.NewRecorder(), req)
+
+	root := findSpan(t, recorder.Ended(), "s3proxy HEAD")
+	assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", root.SpanContext().TraceID().String())
+	assert.Equal(t, "00f067aa0ba902b7", root.Parent().SpanID().String())
+}
+
+func TestSetup_DisabledWithoutEndpoint(t *testing.T) {
+	prev := otel.GetTracerProvider()
+
+	shutdown, err := tracing.Setup(context.Background(), &tracing.Config{})
+	require.NoError(t, err)
+	assert.NoError(t, shutdown(context.Background()))
+	assert.Equal(t, prev, otel.GetTracerProvider(), "provider must stay no-op without endpoint")
+}
+
+func TestConfig_Validate(t *testing.T) {
+	assert.NoError(t, (&tracing.Config{SampleRatio: 0.5}).Validate())
+	assert.Error(t, (&tracing.Config{SampleRatio: 1.5}).Validate())
+	assert.Error(t, (&tracing.Config{SampleRatio: -0.1}).Validate())
+}